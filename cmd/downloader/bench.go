@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/app"
+	"github.com/Extrarius/29.09.2025/internal/downloader"
+)
+
+// runBench — команда "bench --url URL --parallel N": замер скорости
+// скачивания тем же стеком, что у сервиса (internal/downloader: слоты хостов,
+// ограничение скорости, пул буферов, предел памяти, запись через .part), —
+// чтобы подобрать WORKERS, HOST_CONCURRENCY и COPY_BUFFER_SIZE под свой канал.
+//
+// --parallel и --buffer-sizes принимают списки через запятую: замеряется
+// каждая комбинация. Прогон — --count загрузок (по умолчанию 2×parallel)
+// или, если задана, --duration. Файлы пишутся во временный каталог (--dir,
+// по умолчанию системный) и сразу удаляются. По каждому прогону печатаются
+// скорость (МБ/с), задержки загрузки и первого байта (p50/p90/p99/max).
+// Параллельность выше HOST_CONCURRENCY (или hosts.<host>.concurrency)
+// упирается в лимит хоста — это тоже видно по результату.
+func runBench(args []string) error {
+	var (
+		rawURL, parallelList, bufferList, dir string
+		count                                 int
+		duration                              time.Duration
+	)
+	conf, err := parseFlags("bench", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&rawURL, "url", "", "ссылка для замера (обязательно)")
+		fs.StringVar(&parallelList, "parallel", "", "одновременных загрузок, можно список: 1,4,16 (по умолчанию WORKERS)")
+		fs.StringVar(&bufferList, "buffer-sizes", "", "блоки копирования, можно список: 32KB,256KB,1MB (по умолчанию COPY_BUFFER_SIZE)")
+		fs.IntVar(&count, "count", 0, "загрузок в прогоне (по умолчанию 2×parallel)")
+		fs.DurationVar(&duration, "duration", 0, "длительность прогона вместо --count")
+		fs.StringVar(&dir, "dir", "", "каталог для временных файлов (по умолчанию системный)")
+	})
+	if err != nil {
+		return err
+	}
+	if rawURL == "" {
+		return errors.New("не задан --url")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("некорректная ссылка %q", rawURL)
+	}
+	if !conf.HostAllowed(u.Hostname()) {
+		return fmt.Errorf("host not allowed: %s", u.Hostname())
+	}
+	parallels, err := parseIntList(parallelList, max(1, conf.Workers))
+	if err != nil {
+		return fmt.Errorf("--parallel: %w", err)
+	}
+	buffers, err := parseSizeList(bufferList, conf.CopyBufferSize)
+	if err != nil {
+		return fmt.Errorf("--buffer-sizes: %w", err)
+	}
+	if count < 0 || duration < 0 {
+		return errors.New("--count и --duration должны быть >= 0")
+	}
+
+	tmp, err := os.MkdirTemp(dir, "downloader-bench-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("bench %s: HOST_CONCURRENCY=%d, BANDWIDTH_LIMIT=%s, RETRIES=%d\n\n", rawURL, conf.HostConcurrency, conf.BandwidthLimit, conf.Retries)
+	const row = "%8v %8v %6v %6v %9v %8v %8v %8v %8v %8v %8v\n"
+	fmt.Printf(row, "parallel", "buffer", "ok", "failed", "MB/s", "p50", "p90", "p99", "max", "ttfb p50", "ttfb p99")
+	succeeded := 0
+	for _, p := range parallels {
+		for _, b := range buffers {
+			n := count
+			if n == 0 && duration == 0 {
+				n = 2 * p
+			}
+			r := benchRun(ctx, conf, b, rawURL, tmp, p, n, duration)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Printf(row, p, b, len(r.took), r.failed,
+				strconv.FormatFloat(float64(r.bytes)/r.elapsed.Seconds()/(1<<20), 'f', 2, 64),
+				percentile(r.took, 0.5), percentile(r.took, 0.9), percentile(r.took, 0.99), percentile(r.took, 1),
+				percentile(r.ttfb, 0.5), percentile(r.ttfb, 0.99))
+			if r.lastErr != nil {
+				fmt.Fprintf(os.Stderr, "последняя ошибка: %v\n", r.lastErr)
+			}
+			succeeded += len(r.took)
+		}
+	}
+	if succeeded == 0 {
+		return errors.New("ни одной успешной загрузки")
+	}
+	return nil
+}
+
+// benchResult — итог одного прогона bench.
+type benchResult struct {
+	took, ttfb []time.Duration // по успешным загрузкам
+	bytes      int64
+	failed     int
+	lastErr    error
+	elapsed    time.Duration
+}
+
+// benchRun скачивает rawURL в dir parallel потоками: n раз или, при n == 0,
+// пока не истечёт duration. Загрузчик создаётся заново — с пустыми
+// соединениями и блоком копирования buffer.
+func benchRun(ctx context.Context, conf app.Config, buffer app.ByteSize, rawURL, dir string, parallel, n int, duration time.Duration) benchResult {
+	loader := downloader.NewDownloader(downloader.Options{
+		ClientTimeout:   conf.ClientTimeout,
+		Retries:         conf.Retries,
+		HostConcurrency: conf.HostConcurrency,
+		HostLimits:      conf.HostLimits,
+		HostProfiles:    conf.DownloaderProfiles(),
+		AllowHost:       conf.HostAllowed,
+		Bandwidth:       int64(conf.BandwidthLimit),
+		WriteRate:       int64(conf.WriteRateLimit),
+		BufferSize:      int(buffer),
+		Preallocate:     conf.Preallocate,
+		BypassCache:     conf.BypassPageCache,
+
+		MaxInflightBytes: int64(conf.MaxInflightBytes),
+		PartDir:          conf.PartDir,
+		FinalizeSync:     conf.FinalizeSync,
+	})
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	var (
+		mu   sync.Mutex
+		res  benchResult
+		next int
+		wg   sync.WaitGroup
+	)
+	started := time.Now()
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				i := next
+				next++
+				mu.Unlock()
+				if (n > 0 && i >= n) || ctx.Err() != nil {
+					return
+				}
+				path := filepath.Join(dir, strconv.Itoa(i))
+				begin := time.Now()
+				var first time.Duration
+				written, err := loader.Do(ctx, downloader.Request{
+					URL:      rawURL,
+					DestPath: path,
+					OnProgress: func(int64) {
+						if first == 0 {
+							first = time.Since(begin)
+						}
+					},
+				})
+				took := time.Since(begin)
+				os.Remove(path)
+
+				mu.Lock()
+				switch {
+				case err == nil:
+					res.took = append(res.took, took)
+					res.ttfb = append(res.ttfb, first)
+					res.bytes += written
+				case ctx.Err() == nil: // обрыв по --duration или сигналу — не ошибка
+					res.failed++
+					res.lastErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	res.elapsed = time.Since(started)
+	return res
+}
+
+// percentile — q-квантиль (0..1) длительностей d по ближайшему рангу; "-" — данных нет.
+func percentile(d []time.Duration, q float64) string {
+	if len(d) == 0 {
+		return "-"
+	}
+	s := slices.Clone(d)
+	slices.Sort(s)
+	return s[int(q*float64(len(s)-1)+0.5)].Round(time.Millisecond).String()
+}
+
+// parseIntList разбирает список положительных чисел через запятую; пусто — def.
+func parseIntList(s string, def int) ([]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return []int{def}, nil
+	}
+	var out []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("ожидается число >= 1, получено %q", v)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// parseSizeList разбирает список размеров через запятую (app.ParseByteSize);
+// пусто — def.
+func parseSizeList(s string, def app.ByteSize) ([]app.ByteSize, error) {
+	if strings.TrimSpace(s) == "" {
+		return []app.ByteSize{def}, nil
+	}
+	var out []app.ByteSize
+	for _, v := range strings.Split(s, ",") {
+		b, err := app.ParseByteSize(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/app"
+	"github.com/Extrarius/29.09.2025/internal/bundle"
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/store"
+)
+
+// runRecover — команда recover: читает WAL, переводит прерванные (RUNNING) файлы
+// в PENDING и сохраняет результат компактизированным журналом.
+// С --dry-run только печатает, что было бы сделано.
+func runRecover(args []string) error {
+	var dryRun bool
+	conf, err := parseFlags("recover", args, func(fs *flag.FlagSet) {
+		fs.BoolVar(&dryRun, "dry-run", false, "только показать изменения, не записывая WAL")
+	})
+	if err != nil {
+		return err
+	}
+	wal, err := store.OpenWAL(conf.DataDir)
+	if err != nil {
+		return err
+	}
+	defer wal.Close()
+
+	tasks, err := wal.RecoverTasks()
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(tasks))
+	for id := range tasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var changed []*core.Task
+	var reset, requeue int
+	for _, id := range ids {
+		t := tasks[id]
+		n := t.ResetInterrupted()
+		if n > 0 {
+			changed = append(changed, t)
+		}
+		reset += n
+		requeue += t.Pending
+		fmt.Printf("%s\t%s\tfiles=%d done=%d failed=%d pending=%d reset=%d\n",
+			t.ID, t.Status, t.Total, t.Done, t.Failed, t.Pending, n)
+	}
+	fmt.Printf("tasks: %d, interrupted files reset: %d, files to enqueue on start: %d\n", len(tasks), reset, requeue)
+	if s := wal.Scheduler(); !s.IsZero() {
+		fmt.Printf("scheduler: drain=%t, paused hosts: %s\n", s.Drain, strings.Join(s.PausedHosts, ", "))
+	}
+
+	if dryRun || len(changed) == 0 {
+		return nil
+	}
+	for _, t := range changed {
+		if err := wal.AppendTask(t); err != nil {
+			return err
+		}
+	}
+	cs, err := wal.Compact()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wal rewritten: %d → %d records\n", cs.RecordsBefore, cs.RecordsAfter)
+	return nil
+}
+
+// runExport — команда export: пишет архив задачи (пакет bundle) из WAL и
+// DOWNLOAD_DIR — метаданные, историю и скачанные файлы.
+func runExport(args []string) (err error) {
+	var taskID, out string
+	conf, err := parseFlags("export", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&taskID, "task", "", "ID задачи")
+		fs.StringVar(&out, "out", "", "файл архива (по умолчанию <ID>.tar, \"-\" — stdout)")
+	})
+	if err != nil {
+		return err
+	}
+	if taskID == "" {
+		return errors.New("не указан -task")
+	}
+	wal, err := store.OpenWAL(conf.DataDir)
+	if err != nil {
+		return err
+	}
+	defer wal.Close()
+	tasks, err := wal.RecoverTasks()
+	if err != nil {
+		return err
+	}
+	t, ok := tasks[taskID]
+	if !ok {
+		return fmt.Errorf("задача %s не найдена", taskID)
+	}
+
+	if out == "" {
+		out = taskID + ".tar"
+	}
+	w := io.Writer(os.Stdout)
+	if out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(out)
+			}
+		}()
+		w = f
+	}
+	if err := bundle.Write(w, t, t.History(), conf.DownloadDir); err != nil {
+		return err
+	}
+	if out != "-" {
+		fmt.Fprintf(os.Stderr, "task %s exported to %s\n", taskID, out)
+	}
+	return nil
+}
+
+// runImport — команда import: распаковывает архив задачи под DOWNLOAD_DIR
+// (при заданном BLOB_DIR — в хранилище по содержимому) и дописывает задачу
+// с историей в WAL. Недокачанные файлы скачаются при следующем запуске сервиса.
+func runImport(args []string) error {
+	var in string
+	conf, err := parseFlags("import", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&in, "in", "", "файл архива (\"-\" — stdin)")
+	})
+	if err != nil {
+		return err
+	}
+	if in == "" {
+		return errors.New("не указан -in")
+	}
+	r := io.Reader(os.Stdin)
+	if in != "-" {
+		f, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	wal, err := store.OpenWAL(conf.DataDir)
+	if err != nil {
+		return err
+	}
+	defer wal.Close()
+	tasks, err := wal.RecoverTasks()
+	if err != nil {
+		return err
+	}
+	b, err := bundle.Read(r, conf.DownloadDir, func(t *core.Task) error {
+		if _, dup := tasks[t.ID]; dup {
+			return fmt.Errorf("задача %s уже есть в WAL", t.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var blobs *store.BlobStore
+	if conf.BlobDir != "" {
+		if blobs, err = store.OpenBlobStore(conf.BlobDir); err != nil {
+			return err
+		}
+	}
+	t := b.Task
+	t.RestoreHistory(b.History)
+	files := 0
+	for _, f := range t.Files {
+		if f.Path == "" {
+			continue
+		}
+		files++
+		if blobs != nil {
+			if sum, err := blobs.Ingest(f.Path); err == nil {
+				f.Blob = sum
+			}
+		}
+	}
+	t.AddEvent(core.TaskEvent{
+		Type:    core.EventImported,
+		Status:  string(t.Status),
+		Message: fmt.Sprintf("%d file(s) from bundle exported at %s", files, b.Header.ExportedAt.Format(time.RFC3339)),
+	})
+	if err := wal.AppendTask(t); err != nil {
+		return err
+	}
+	if err := wal.AppendEvents(t.ID, t.History().Events...); err != nil {
+		return err
+	}
+	fmt.Printf("task %s imported: %d file(s), %d bytes, %d pending, dest %s\n", t.ID, files, b.Bytes, t.Pending, t.DestDir)
+	return nil
+}
+
+// runWALCompact — команда "wal compact": оставляет в журнале по одной записи на задачу.
+func runWALCompact(args []string) error {
+	conf, err := parseFlags("wal compact", args, nil)
+	if err != nil {
+		return err
+	}
+	wal, err := store.OpenWAL(conf.DataDir)
+	if err != nil {
+		return err
+	}
+	defer wal.Close()
+	cs, err := wal.Compact()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("tasks: %d, records: %d → %d, bytes: %d → %d\n",
+		cs.Tasks, cs.RecordsBefore, cs.RecordsAfter, cs.BytesBefore, cs.BytesAfter)
+	return nil
+}
+
+// runGC — команда "gc": убирает из DOWNLOAD_DIR файлы задач, которых нет в WAL,
+// брошенные .part и пустые каталоги (app.GC).
+func runGC(args []string) error {
+	var opts app.GCOptions
+	var verbose bool
+	conf, err := parseFlags("gc", args, func(fs *flag.FlagSet) {
+		fs.BoolVar(&opts.DryRun, "dry-run", false, "только показать, что было бы удалено")
+		fs.DurationVar(&opts.MinAge, "min-age", app.DefaultGCMinAge, "не трогать файлы моложе")
+		fs.BoolVar(&verbose, "v", false, "перечислить удалённые пути")
+	})
+	if err != nil {
+		return err
+	}
+	wal, err := store.OpenWAL(conf.DataDir)
+	if err != nil {
+		return err
+	}
+	defer wal.Close()
+	tasks, err := wal.RecoverTasks()
+	if err != nil {
+		return err
+	}
+	rep, err := app.GC(&conf, tasks, opts)
+	if err != nil {
+		return err
+	}
+	if verbose {
+		for _, p := range rep.Removed {
+			fmt.Println(p)
+		}
+	}
+	for _, e := range rep.Errors {
+		fmt.Fprintln(os.Stderr, e)
+	}
+	verb := "reclaimed"
+	if opts.DryRun {
+		verb = "would reclaim"
+	}
+	fmt.Printf("orphan files: %d, .part files: %d, empty dirs: %d, blobs: %d, %s %d bytes\n",
+		rep.OrphanFiles, rep.PartFiles, rep.EmptyDirs, rep.Blobs, verb, rep.BytesReclaimed)
+	return nil
+}
+
+// runStoreMigrate — команда "store migrate": приводит WAL к текущему формату.
+func runStoreMigrate(args []string) error {
+	conf, err := parseFlags("store migrate", args, nil)
+	if err != nil {
+		return err
+	}
+	wal, err := store.OpenWAL(conf.DataDir)
+	if err != nil {
+		return err
+	}
+	defer wal.Close()
+	migrated, cs, err := wal.Migrate()
+	if err != nil {
+		return err
+	}
+	if !migrated {
+		fmt.Printf("wal format is up to date (version %d)\n", cs.ToVersion)
+		return nil
+	}
+	fmt.Printf("wal migrated: version %d → %d, records: %d → %d\n",
+		cs.FromVersion, cs.ToVersion, cs.RecordsBefore, cs.RecordsAfter)
+	return nil
+}
+
+// runStoreQuery — команда "store query": отбирает задачи прямо из WAL
+// (store.WAL.QueryTasks) и печатает по строке на задачу, по времени создания.
+// Даты — RFC 3339 или YYYY-MM-DD; интервал [from, to).
+func runStoreQuery(args []string) error {
+	var status, tag, from, to, trash string
+	conf, err := parseFlags("store query", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&status, "status", "", "статус задачи (PENDING, RUNNING, COMPLETE, FAILED, …)")
+		fs.StringVar(&tag, "tag", "", "задачи с этим тегом")
+		fs.StringVar(&from, "created-from", "", "созданные не раньше (RFC 3339 или YYYY-MM-DD)")
+		fs.StringVar(&to, "created-to", "", "созданные раньше (RFC 3339 или YYYY-MM-DD)")
+		fs.StringVar(&trash, "trash", "", "задачи в корзине: only — только они, all — вместе с остальными; по умолчанию не показываются")
+	})
+	if err != nil {
+		return err
+	}
+	if !store.ValidTrash(trash) {
+		return fmt.Errorf("-trash: ожидается %s или %s, получено %q", store.TrashOnly, store.TrashAll, trash)
+	}
+	q := store.TaskQuery{Tag: tag, Trash: trash}
+	if q.Status, err = core.ParseTaskStatus(status); err != nil {
+		return fmt.Errorf("-status: %w", err)
+	}
+	if q.CreatedFrom, err = parseQueryTime(from); err != nil {
+		return fmt.Errorf("-created-from: %w", err)
+	}
+	if q.CreatedTo, err = parseQueryTime(to); err != nil {
+		return fmt.Errorf("-created-to: %w", err)
+	}
+
+	wal, err := store.OpenWAL(conf.DataDir)
+	if err != nil {
+		return err
+	}
+	defer wal.Close()
+	tasks, err := wal.QueryTasks(q)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		fmt.Printf("%s\t%s\t%s\tfiles=%d done=%d failed=%d pending=%d tags=%s\n",
+			t.ID, t.CreatedAt.Format(time.RFC3339), t.Status, t.Total, t.Done, t.Failed, t.Pending, strings.Join(t.Tags, ","))
+	}
+	fmt.Printf("tasks: %d\n", len(tasks))
+	return nil
+}
+
+// parseQueryTime разбирает границу интервала для store query; пусто — нулевое время.
+func parseQueryTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ожидается RFC 3339 или YYYY-MM-DD, получено %q", s)
+	}
+	return t, nil
+}
+
+// runHealthcheck — команда healthcheck: запрашивает /readyz (или -path) у
+// локально запущенного сервиса. Возвращает nil только при ответе 200.
+// Предназначена для Docker HEALTHCHECK и exec-проб Kubernetes — без curl в образе.
+func runHealthcheck(args []string) error {
+	var (
+		path    string
+		timeout time.Duration
+	)
+	conf, err := parseFlags("healthcheck", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&path, "path", "/readyz", "проверяемый путь (/readyz или /healthz)")
+		fs.DurationVar(&timeout, "timeout", 3*time.Second, "таймаут запроса")
+	})
+	if err != nil {
+		return err
+	}
+	client, target := healthClient(conf, timeout)
+	resp, err := client.Get(target + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: http %d", path, resp.StatusCode)
+	}
+	return nil
+}
+
+// healthClient строит HTTP-клиент и базовый URL для обращения к сервису
+// по первому адресу из конфигурации: unix-сокет — через собственный dialer,
+// TCP — на loopback (пустой host и 0.0.0.0 заменяются на 127.0.0.1).
+// При включённом TLS проверка сертификата отключена: соединение локальное,
+// а сертификат может быть самоподписанным.
+func healthClient(conf app.Config, timeout time.Duration) (*http.Client, string) {
+	tr := &http.Transport{}
+	scheme := "http"
+	addr := conf.ListenAddrs()[0]
+	host := "localhost"
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+	} else {
+		h, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			h, port = "", conf.Port
+		}
+		if h == "" || h == "0.0.0.0" || h == "::" {
+			h = "127.0.0.1"
+		}
+		host = net.JoinHostPort(h, port)
+		if conf.TLSEnabled() {
+			scheme = "https"
+			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+	}
+	return &http.Client{Transport: tr, Timeout: timeout}, scheme + "://" + host
+}
+
+// runVersion печатает версию сборки, версию Go и ревизию VCS (если известна).
+func runVersion() {
+	fmt.Printf("downloader %s (%s, %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" || s.Key == "vcs.time" || s.Key == "vcs.modified" {
+				fmt.Printf("%s=%s\n", s.Key, s.Value)
+			}
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/Extrarius/29.09.2025/internal/app"
 )
 
 func init() {
@@ -18,10 +22,43 @@ func init() {
 	}
 }
 
+// envErrs накапливает ошибки разбора переменных окружения: вместо молчаливого
+// отката к значению по умолчанию некорректное значение попадает сюда
+// и затем выводится вместе с остальными проблемами конфигурации.
+var envErrs []error
+
+// envError регистрирует некорректное значение переменной key.
+func envError(key, val, want string) {
+	envErrs = append(envErrs, fmt.Errorf("%s: ожидается %s, получено %q", key, want, val))
+}
+
+// lookupEnv — единая точка чтения переменных для всех env-хелперов.
+// Поддерживает соглашение *_FILE (Docker/Kubernetes secrets): если задана
+// переменная KEY_FILE, значение KEY читается из указанного файла
+// (завершающие пробелы и переводы строк отбрасываются).
+// Одновременно заданные KEY и KEY_FILE — ошибка конфигурации (регистрируется в envErrs).
+func lookupEnv(key string) (string, bool) {
+	v, ok := os.LookupEnv(key)
+	file, fileOK := os.LookupEnv(key + "_FILE")
+	if !fileOK || file == "" {
+		return v, ok
+	}
+	if ok && v != "" {
+		envErrs = append(envErrs, fmt.Errorf("%s и %s_FILE заданы одновременно", key, key))
+		return v, ok
+	}
+	secret, err := readSecretFile(file)
+	if err != nil {
+		envErrs = append(envErrs, fmt.Errorf("%s_FILE: %w", key, err))
+		return "", false
+	}
+	return secret, true
+}
+
 // env возвращает значение переменной окружения
 // или значение по умолчанию, если переменная пуста/не задана.
 func env(key, def string) string {
-	if v, ok := os.LookupEnv(key); ok && v != "" {
+	if v, ok := lookupEnv(key); ok && v != "" {
 		return v
 	}
 	return def
@@ -29,22 +66,107 @@ func env(key, def string) string {
 
 // envInt читает целое число из переменной окружения,
 // иначе возвращает значение по умолчанию.
+// Нечисловое значение регистрируется в envErrs.
 func envInt(key string, def int) int {
-	if v, ok := os.LookupEnv(key); ok && v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			return n
+	if v, ok := lookupEnv(key); ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			envError(key, v, "целое число")
+			return def
 		}
+		return n
 	}
 	return def
 }
 
 // envDuration читает длительность (например, "5s", "2m")
 // из переменной окружения или возвращает значение по умолчанию.
+// Неразбираемое значение регистрируется в envErrs.
 func envDuration(key string, def time.Duration) time.Duration {
-	if v, ok := os.LookupEnv(key); ok && v != "" {
-		if d, err := time.ParseDuration(v); err == nil {
-			return d
+	if v, ok := lookupEnv(key); ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			envError(key, v, "длительность (например, 30s, 2m)")
+			return def
+		}
+		return d
+	}
+	return def
+}
+
+// envBandwidth читает скорость ("10MB", "512KB/s", "unlimited", см. app.ParseBandwidth)
+// из переменной окружения или возвращает значение по умолчанию.
+// Неразбираемое значение регистрируется в envErrs.
+func envBandwidth(key string, def app.Bandwidth) app.Bandwidth {
+	if v, ok := lookupEnv(key); ok && v != "" {
+		b, err := app.ParseBandwidth(v)
+		if err != nil {
+			envError(key, v, "скорость (например, 10MB, 512KB/s, unlimited)")
+			return def
+		}
+		return b
+	}
+	return def
+}
+
+// envByteSize читает размер ("256KB", "1MB", см. app.ParseByteSize)
+// из переменной окружения или возвращает значение по умолчанию.
+// Неразбираемое значение регистрируется в envErrs.
+func envByteSize(key string, def app.ByteSize) app.ByteSize {
+	if v, ok := lookupEnv(key); ok && v != "" {
+		b, err := app.ParseByteSize(v)
+		if err != nil {
+			envError(key, v, "размер (например, 256KB, 1MB)")
+			return def
+		}
+		return b
+	}
+	return def
+}
+
+// envBool читает булево значение ("1", "true", "yes", "0", "false", …)
+// из переменной окружения или возвращает значение по умолчанию.
+// Нераспознанное значение регистрируется в envErrs.
+func envBool(key string, def bool) bool {
+	if v, ok := lookupEnv(key); ok && v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
 		}
+		switch v {
+		case "yes", "on", "YES", "ON":
+			return true
+		case "no", "off", "NO", "OFF":
+			return false
+		}
+		envError(key, v, "булево значение (true/false)")
 	}
 	return def
 }
+
+// envList читает список значений, разделённых запятыми или переводами строк
+// (пробелы вокруг элементов, пустые элементы и строки-комментарии "#…" отбрасываются).
+// Для незаданной переменной возвращает def.
+func envList(key string, def []string) []string {
+	v, ok := lookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	return splitList(v)
+}
+
+// splitList разбивает строку по запятым и переводам строк, обрезая пробелы
+// и пропуская пустые элементы и комментарии (удобно для списков в *_FILE).
+func splitList(v string) []string {
+	var out []string
+	for _, line := range strings.Split(v, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, part := range strings.Split(line, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
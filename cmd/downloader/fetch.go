@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/downloader"
+)
+
+// runFetch — команда "fetch URL... --dest DIR": разовое скачивание без HTTP-сервера и WAL.
+//
+// Использует тот же internal/downloader, что и сервис: ретраи (RETRIES),
+// ограничение по хостам (HOST_CONCURRENCY и hosts из файла конфигурации),
+// таймаут клиента, запись через .part + rename. Имена файлов строятся так же,
+// как для задач (core.NewTask), занятые имена получают суффикс "-N".
+// Параллельность — -parallel (по умолчанию WORKERS). SIGINT/SIGTERM прерывают загрузки.
+// Возвращает ошибку, если хотя бы один файл не скачан.
+func runFetch(args []string) error {
+	var (
+		dest     string
+		parallel int
+	)
+	conf, links, err := parseFlagsArgs("fetch", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&dest, "dest", ".", "каталог назначения")
+		fs.IntVar(&parallel, "parallel", 0, "одновременных загрузок (по умолчанию WORKERS)")
+	})
+	if err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		return errors.New("не переданы ссылки")
+	}
+	if parallel <= 0 {
+		parallel = max(1, conf.Workers)
+	}
+	// Только для имён файлов и валидации ссылок; задача никуда не сохраняется.
+	task, err := core.NewTask("", dest, links, conf.Retries)
+	if err != nil {
+		return err
+	}
+	for _, f := range task.Files {
+		if !conf.HostAllowed(f.Host) {
+			return fmt.Errorf("host not allowed: %s", f.Host)
+		}
+	}
+
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	loader := downloader.NewDownloader(downloader.Options{
+		ClientTimeout:   conf.ClientTimeout,
+		Retries:         conf.Retries,
+		HostConcurrency: conf.HostConcurrency,
+		HostLimits:      conf.HostLimits,
+		HostProfiles:    conf.DownloaderProfiles(),
+		AllowHost:       conf.HostAllowed,
+		Bandwidth:       int64(conf.BandwidthLimit),
+		WriteRate:       int64(conf.WriteRateLimit),
+		BufferSize:      int(conf.CopyBufferSize),
+		Preallocate:     conf.Preallocate,
+		BypassCache:     conf.BypassPageCache,
+
+		MaxInflightBytes: int64(conf.MaxInflightBytes),
+		QuarantineDir:    conf.QuarantineDir,
+		PartDir:          conf.PartDir,
+		FinalizeSync:     conf.FinalizeSync,
+	})
+
+	var (
+		mu     sync.Mutex // сериализует выбор имени и вывод
+		wg     sync.WaitGroup
+		failed int
+		sem    = make(chan struct{}, parallel)
+	)
+	for _, f := range task.Files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			mu.Lock()
+			path := downloader.UniquePath(filepath.Join(dest, f.Filename))
+			// резервируем имя, чтобы параллельные загрузки не выбрали тот же путь
+			if fh, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL, 0o644); err == nil {
+				fh.Close()
+			}
+			mu.Unlock()
+
+			started := time.Now()
+			n, err := loader.Fetch(ctx, f.URL, path)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed++
+				os.Remove(path)
+				fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", f.URL, err)
+				return
+			}
+			fmt.Printf("OK   %s → %s (%d bytes, %s)\n", f.URL, path, n, time.Since(started).Round(time.Millisecond))
+		}()
+	}
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d file(s) failed", failed, len(task.Files))
+	}
+	return nil
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Extrarius/29.09.2025/internal/app"
+)
+
+// fileConfig — схема файла конфигурации (YAML или TOML).
+// Незаданные поля не меняют значения по умолчанию.
+// Помимо всего, что умеют переменные окружения, файл поддерживает
+// структурированные настройки: лимиты по хостам, allowlist и ссылки на секреты.
+type fileConfig struct {
+	Port            *string        `yaml:"port" toml:"port"`
+	Listen          []string       `yaml:"listen" toml:"listen"`
+	AdminListen     []string       `yaml:"admin_listen" toml:"admin_listen"`
+	DataDir         *string        `yaml:"data_dir" toml:"data_dir"`
+	DownloadDir     *string        `yaml:"download_dir" toml:"download_dir"`
+	BlobDir         *string        `yaml:"blob_dir" toml:"blob_dir"`
+	QuarantineDir   *string        `yaml:"quarantine_dir" toml:"quarantine_dir"`
+	PartDir         *string        `yaml:"part_dir" toml:"part_dir"`
+	PartLocation    *string        `yaml:"part_location" toml:"part_location"`
+	FinalizeSync    *string        `yaml:"finalize_sync" toml:"finalize_sync"`
+	FixExtensions   *bool          `yaml:"fix_extensions" toml:"fix_extensions"`
+	TaskManifest    *string        `yaml:"task_manifest" toml:"task_manifest"`
+	ErrorLang       *string        `yaml:"error_lang" toml:"error_lang"`
+	DependsPartial  *bool          `yaml:"depends_allow_partial" toml:"depends_allow_partial"`
+	DuplicatePolicy *string        `yaml:"duplicate_policy" toml:"duplicate_policy"`
+	DuplicateWindow *duration      `yaml:"duplicate_window" toml:"duplicate_window"`
+	TaskIDFormat    *string        `yaml:"task_id_format" toml:"task_id_format"`
+	TrashRetention  *duration      `yaml:"trash_retention" toml:"trash_retention"`
+	Workers         *int           `yaml:"workers" toml:"workers"`
+	BandwidthLimit  *app.Bandwidth `yaml:"bandwidth_limit" toml:"bandwidth_limit"`
+	WriteRateLimit  *app.Bandwidth `yaml:"write_rate_limit" toml:"write_rate_limit"`
+	WorkerNice      *int           `yaml:"worker_nice" toml:"worker_nice"`
+	WorkerIOPrio    *string        `yaml:"worker_io_priority" toml:"worker_io_priority"`
+	CopyBufferSize  *app.ByteSize  `yaml:"copy_buffer_size" toml:"copy_buffer_size"`
+	Preallocate     *bool          `yaml:"preallocate" toml:"preallocate"`
+	BypassCache     *bool          `yaml:"bypass_page_cache" toml:"bypass_page_cache"`
+	MaxInflight     *app.ByteSize  `yaml:"max_inflight_bytes" toml:"max_inflight_bytes"`
+	DiskLow         *app.ByteSize  `yaml:"disk_low_watermark" toml:"disk_low_watermark"`
+	DiskHigh        *app.ByteSize  `yaml:"disk_high_watermark" toml:"disk_high_watermark"`
+	DiskGuardNotify []string       `yaml:"disk_guard_notify" toml:"disk_guard_notify"`
+	DiskGuardEmail  *string        `yaml:"disk_guard_email" toml:"disk_guard_email"`
+	HostConcurrency *int           `yaml:"host_concurrency" toml:"host_concurrency"`
+	ClientTimeout   *duration      `yaml:"client_timeout" toml:"client_timeout"`
+	Retries         *int           `yaml:"retries" toml:"retries"`
+	RetryBackoff    *duration      `yaml:"retry_backoff" toml:"retry_backoff"`
+	RetryBackoffMax *duration      `yaml:"retry_backoff_max" toml:"retry_backoff_max"`
+	WALAsyncQueue   *int           `yaml:"wal_async_queue" toml:"wal_async_queue"`
+	ShutdownWait    *duration      `yaml:"shutdown_wait" toml:"shutdown_wait"`
+
+	Admin struct {
+		User         *string `yaml:"user" toml:"user"`
+		Password     *string `yaml:"password" toml:"password"`
+		PasswordFile string  `yaml:"password_file" toml:"password_file"`
+
+		LockoutAttempts *int      `yaml:"lockout_attempts" toml:"lockout_attempts"`
+		LockoutDuration *duration `yaml:"lockout_duration" toml:"lockout_duration"`
+	} `yaml:"admin" toml:"admin"`
+
+	// APIKeys / APIKeysFile — ключи API задач: списком или ссылкой на файл
+	// (по одному на строку либо через запятую).
+	APIKeys     []string `yaml:"api_keys" toml:"api_keys"`
+	APIKeysFile string   `yaml:"api_keys_file" toml:"api_keys_file"`
+
+	// JWT — проверка bearer-токенов внешнего издателя (OIDC), см. JWT_*.
+	JWT struct {
+		Issuer      *string `yaml:"issuer" toml:"issuer"`
+		JWKSURL     *string `yaml:"jwks_url" toml:"jwks_url"`
+		Audience    *string `yaml:"audience" toml:"audience"`
+		RoleClaim   *string `yaml:"role_claim" toml:"role_claim"`
+		TenantClaim *string `yaml:"tenant_claim" toml:"tenant_claim"`
+	} `yaml:"jwt" toml:"jwt"`
+
+	// Limits — лимиты приёма задач (MAX_*), 0 — без ограничения.
+	Limits struct {
+		LinksPerTask          *int `yaml:"links_per_task" toml:"links_per_task"`
+		PendingFilesPerTenant *int `yaml:"pending_files_per_tenant" toml:"pending_files_per_tenant"`
+		TasksPerHour          *int `yaml:"tasks_per_hour" toml:"tasks_per_hour"`
+	} `yaml:"limits" toml:"limits"`
+
+	// SignedURLs — подписанные ссылки на содержимое файлов (SIGNED_URL_*);
+	// ключ — значением или файлом с секретом.
+	SignedURLs struct {
+		Key     *string   `yaml:"key" toml:"key"`
+		KeyFile string    `yaml:"key_file" toml:"key_file"`
+		MaxTTL  *duration `yaml:"max_ttl" toml:"max_ttl"`
+		BaseURL *string   `yaml:"base_url" toml:"base_url"`
+	} `yaml:"signed_urls" toml:"signed_urls"`
+
+	TLS struct {
+		Cert       *string `yaml:"cert" toml:"cert"`
+		Key        *string `yaml:"key" toml:"key"`
+		SelfSigned *bool   `yaml:"self_signed" toml:"self_signed"`
+		ClientCA   *string `yaml:"client_ca" toml:"client_ca"`
+	} `yaml:"tls" toml:"tls"`
+
+	// Hosts — профили хостов: шаблон хоста ("example.com", "*.example.com") →
+	// {concurrency, headers, bandwidth, proxy, max_attempts, retry_backoff}.
+	Hosts map[string]struct {
+		Concurrency  *int              `yaml:"concurrency" toml:"concurrency"`
+		Headers      map[string]string `yaml:"headers" toml:"headers"`
+		Bandwidth    app.Bandwidth     `yaml:"bandwidth" toml:"bandwidth"`
+		Proxy        string            `yaml:"proxy" toml:"proxy"`
+		MaxAttempts  int               `yaml:"max_attempts" toml:"max_attempts"`
+		RetryBackoff duration          `yaml:"retry_backoff" toml:"retry_backoff"`
+	} `yaml:"hosts" toml:"hosts"`
+
+	// S3 — объектное хранилище для dest_dir "s3://bucket/prefix" (S3_*);
+	// ключи — только из окружения (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY).
+	S3 struct {
+		Endpoint  *string `yaml:"endpoint" toml:"endpoint"`
+		Region    *string `yaml:"region" toml:"region"`
+		PathStyle *bool   `yaml:"path_style" toml:"path_style"`
+	} `yaml:"s3" toml:"s3"`
+
+	// Schedule — окна суток со своими лимитами: {from, to, workers, bandwidth};
+	// вне окон действуют workers и bandwidth_limit.
+	Schedule []struct {
+		From      string         `yaml:"from" toml:"from"`
+		To        string         `yaml:"to" toml:"to"`
+		Workers   int            `yaml:"workers" toml:"workers"`
+		Bandwidth *app.Bandwidth `yaml:"bandwidth" toml:"bandwidth"`
+	} `yaml:"schedule" toml:"schedule"`
+
+	// Maintenance — окна обслуживания, на которые очередь уходит в drain:
+	// {from, to, days}; days пусто — каждый день.
+	Maintenance []struct {
+		From string   `yaml:"from" toml:"from"`
+		To   string   `yaml:"to" toml:"to"`
+		Days []string `yaml:"days" toml:"days"`
+	} `yaml:"maintenance" toml:"maintenance"`
+
+	// Sinks — хранилища для выгрузки скачанных файлов: имя → {url, delete_local}.
+	Sinks map[string]struct {
+		URL         string `yaml:"url" toml:"url"`
+		DeleteLocal bool   `yaml:"delete_local" toml:"delete_local"`
+	} `yaml:"sinks" toml:"sinks"`
+
+	// AllowedHosts — разрешённые хосты ссылок ("example.com", "*.cdn.example.com").
+	AllowedHosts []string `yaml:"allowed_hosts" toml:"allowed_hosts"`
+
+	// SignatureKeyrings — файлы открытых ключей OpenPGP для проверки подписей ссылок.
+	SignatureKeyrings []string `yaml:"signature_keyrings" toml:"signature_keyrings"`
+
+	// Watch — приём манифестов из каталога (см. WATCH_DIR).
+	Watch struct {
+		Dir      *string   `yaml:"dir" toml:"dir"`
+		Interval *duration `yaml:"interval" toml:"interval"`
+	} `yaml:"watch" toml:"watch"`
+
+	// QueueURL — общая очередь заданий нескольких экземпляров (QUEUE_URL).
+	QueueURL *string `yaml:"queue_url" toml:"queue_url"`
+	// LeaderLease — аренда лидера в Redis очереди (LEADER_LEASE).
+	LeaderLease *duration `yaml:"leader_lease" toml:"leader_lease"`
+
+	// IngestURL — приём задач из брокера сообщений (INGEST_URL).
+	IngestURL *string `yaml:"ingest_url" toml:"ingest_url"`
+	// EventsURL — публикация событий задач в брокер сообщений (EVENTS_URL).
+	EventsURL *string `yaml:"events_url" toml:"events_url"`
+
+	// SMTPURL — почтовый сервер сводок notify_email (SMTP_URL).
+	SMTPURL *string `yaml:"smtp_url" toml:"smtp_url"`
+	// SMTPFrom — адрес отправителя сводок (SMTP_FROM).
+	SMTPFrom *string `yaml:"smtp_from" toml:"smtp_from"`
+	// Notifiers — уведомители для сводок по завершении задач: имя → адрес
+	// (slack://…, telegram://…).
+	Notifiers map[string]string `yaml:"notifiers" toml:"notifiers"`
+	// NotifyFailures — уведомители сводок по всем задачам с ошибками (NOTIFY_FAILURES).
+	NotifyFailures []string `yaml:"notify_failures" toml:"notify_failures"`
+	// Alerts — правила оповещений: {name, host, failure_rate, min_attempts,
+	// queue_depth, window, notify, email}.
+	Alerts []struct {
+		Name        string   `yaml:"name" toml:"name"`
+		Host        string   `yaml:"host" toml:"host"`
+		FailureRate float64  `yaml:"failure_rate" toml:"failure_rate"`
+		MinAttempts int      `yaml:"min_attempts" toml:"min_attempts"`
+		QueueDepth  int      `yaml:"queue_depth" toml:"queue_depth"`
+		Window      duration `yaml:"window" toml:"window"`
+		Notify      []string `yaml:"notify" toml:"notify"`
+		Email       string   `yaml:"email" toml:"email"`
+	} `yaml:"alerts" toml:"alerts"`
+}
+
+// duration — time.Duration, читаемая из строк вида "30s" в YAML и TOML.
+type duration time.Duration
+
+func (d *duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// configPath находит путь к файлу конфигурации: флаг -config/--config
+// (в любом месте args) либо переменная окружения CONFIG_FILE.
+// Флаг ищется заранее, потому что значения из файла становятся
+// значениями по умолчанию для окружения и остальных флагов.
+func configPath(args []string) string {
+	for i, a := range args {
+		name, val, hasVal := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if !strings.HasPrefix(a, "-") || name != "config" {
+			continue
+		}
+		if hasVal {
+			return val
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return os.Getenv("CONFIG_FILE")
+}
+
+// applyConfigFile накладывает на conf значения из файла path.
+// Формат выбирается по расширению: .yaml/.yml или .toml.
+// Неизвестные ключи считаются ошибкой — опечатки в конфиге не должны молча игнорироваться.
+func applyConfigFile(conf *app.Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var fc fileConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&fc); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), &fc)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if undec := md.Undecoded(); len(undec) > 0 {
+			return fmt.Errorf("%s: неизвестные ключи: %v", path, undec)
+		}
+	default:
+		return fmt.Errorf("%s: неподдерживаемый формат (ожидается .yaml, .yml или .toml)", path)
+	}
+
+	setStr(&conf.Port, fc.Port)
+	if fc.Listen != nil {
+		conf.Listen = fc.Listen
+	}
+	if fc.AdminListen != nil {
+		conf.AdminListen = fc.AdminListen
+	}
+	setStr(&conf.DataDir, fc.DataDir)
+	setStr(&conf.DownloadDir, fc.DownloadDir)
+	setStr(&conf.BlobDir, fc.BlobDir)
+	setStr(&conf.QuarantineDir, fc.QuarantineDir)
+	setStr(&conf.PartDir, fc.PartDir)
+	setStr(&conf.PartLocation, fc.PartLocation)
+	setStr(&conf.FinalizeSync, fc.FinalizeSync)
+	if fc.FixExtensions != nil {
+		conf.FixExtensions = *fc.FixExtensions
+	}
+	setStr(&conf.TaskManifest, fc.TaskManifest)
+	setStr(&conf.ErrorLang, fc.ErrorLang)
+	if fc.DependsPartial != nil {
+		conf.DependsAllowPartial = *fc.DependsPartial
+	}
+	setStr(&conf.DuplicatePolicy, fc.DuplicatePolicy)
+	setDur(&conf.DuplicateWindow, fc.DuplicateWindow)
+	setStr(&conf.TaskIDFormat, fc.TaskIDFormat)
+	setDur(&conf.TrashRetention, fc.TrashRetention)
+	setInt(&conf.Workers, fc.Workers)
+	if fc.BandwidthLimit != nil {
+		conf.BandwidthLimit = *fc.BandwidthLimit
+	}
+	if fc.WriteRateLimit != nil {
+		conf.WriteRateLimit = *fc.WriteRateLimit
+	}
+	setInt(&conf.WorkerNice, fc.WorkerNice)
+	setStr(&conf.WorkerIOPriority, fc.WorkerIOPrio)
+	if fc.CopyBufferSize != nil {
+		conf.CopyBufferSize = *fc.CopyBufferSize
+	}
+	if fc.Preallocate != nil {
+		conf.Preallocate = *fc.Preallocate
+	}
+	if fc.BypassCache != nil {
+		conf.BypassPageCache = *fc.BypassCache
+	}
+	if fc.MaxInflight != nil {
+		conf.MaxInflightBytes = *fc.MaxInflight
+	}
+	if fc.DiskLow != nil {
+		conf.DiskLowWatermark = *fc.DiskLow
+	}
+	if fc.DiskHigh != nil {
+		conf.DiskHighWatermark = *fc.DiskHigh
+	}
+	if fc.DiskGuardNotify != nil {
+		conf.DiskGuardNotify = fc.DiskGuardNotify
+	}
+	setStr(&conf.DiskGuardEmail, fc.DiskGuardEmail)
+	setInt(&conf.HostConcurrency, fc.HostConcurrency)
+	setDur(&conf.ClientTimeout, fc.ClientTimeout)
+	setInt(&conf.Retries, fc.Retries)
+	setDur(&conf.RetryBackoff, fc.RetryBackoff)
+	setDur(&conf.RetryBackoffMax, fc.RetryBackoffMax)
+	setInt(&conf.WALAsyncQueue, fc.WALAsyncQueue)
+	setDur(&conf.ShutdownWait, fc.ShutdownWait)
+
+	setStr(&conf.AdminUser, fc.Admin.User)
+	setStr(&conf.AdminPassword, fc.Admin.Password)
+	if fc.Admin.PasswordFile != "" {
+		secret, err := readSecretFile(fc.Admin.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("admin.password_file: %w", err)
+		}
+		conf.AdminPassword = secret
+	}
+	setInt(&conf.AdminLockoutAttempts, fc.Admin.LockoutAttempts)
+	setDur(&conf.AdminLockoutDuration, fc.Admin.LockoutDuration)
+
+	if fc.APIKeys != nil {
+		conf.APIKeys = fc.APIKeys
+	}
+	if fc.APIKeysFile != "" {
+		keys, err := readSecretFile(fc.APIKeysFile)
+		if err != nil {
+			return fmt.Errorf("api_keys_file: %w", err)
+		}
+		conf.APIKeys = splitList(keys)
+	}
+
+	setStr(&conf.JWTIssuer, fc.JWT.Issuer)
+	setStr(&conf.JWTJWKSURL, fc.JWT.JWKSURL)
+	setStr(&conf.JWTAudience, fc.JWT.Audience)
+	setStr(&conf.JWTRoleClaim, fc.JWT.RoleClaim)
+	setStr(&conf.JWTTenantClaim, fc.JWT.TenantClaim)
+
+	setInt(&conf.MaxLinksPerTask, fc.Limits.LinksPerTask)
+	setInt(&conf.MaxPendingPerTenant, fc.Limits.PendingFilesPerTenant)
+	setInt(&conf.MaxTasksPerHour, fc.Limits.TasksPerHour)
+
+	setStr(&conf.SignedURLKey, fc.SignedURLs.Key)
+	if fc.SignedURLs.KeyFile != "" {
+		secret, err := readSecretFile(fc.SignedURLs.KeyFile)
+		if err != nil {
+			return fmt.Errorf("signed_urls.key_file: %w", err)
+		}
+		conf.SignedURLKey = secret
+	}
+	setDur(&conf.SignedURLMaxTTL, fc.SignedURLs.MaxTTL)
+	setStr(&conf.SignedURLBase, fc.SignedURLs.BaseURL)
+
+	setStr(&conf.TLSCert, fc.TLS.Cert)
+	setStr(&conf.TLSKey, fc.TLS.Key)
+	if fc.TLS.SelfSigned != nil {
+		conf.TLSSelfSigned = *fc.TLS.SelfSigned
+	}
+	setStr(&conf.TLSClientCA, fc.TLS.ClientCA)
+
+	if len(fc.Hosts) > 0 {
+		conf.HostLimits = make(map[string]int, len(fc.Hosts))
+		conf.HostProfiles = make(map[string]app.HostProfile, len(fc.Hosts))
+		for host, h := range fc.Hosts {
+			host = strings.ToLower(host)
+			if h.Concurrency != nil {
+				conf.HostLimits[host] = *h.Concurrency
+			}
+			if len(h.Headers) > 0 || h.Bandwidth != 0 || h.Proxy != "" || h.MaxAttempts != 0 || h.RetryBackoff != 0 {
+				conf.HostProfiles[host] = app.HostProfile{
+					Headers:      h.Headers,
+					Bandwidth:    h.Bandwidth,
+					Proxy:        h.Proxy,
+					MaxAttempts:  h.MaxAttempts,
+					RetryBackoff: time.Duration(h.RetryBackoff),
+				}
+			}
+		}
+	}
+	setStr(&conf.S3Endpoint, fc.S3.Endpoint)
+	setStr(&conf.S3Region, fc.S3.Region)
+	if fc.S3.PathStyle != nil {
+		conf.S3PathStyle = *fc.S3.PathStyle
+	}
+	if fc.Schedule != nil {
+		conf.Schedule = make([]app.ScheduleWindow, 0, len(fc.Schedule))
+		for i, w := range fc.Schedule {
+			from, err := app.ParseClock(w.From)
+			if err != nil {
+				return fmt.Errorf("schedule[%d].from: %w", i, err)
+			}
+			to, err := app.ParseClock(w.To)
+			if err != nil {
+				return fmt.Errorf("schedule[%d].to: %w", i, err)
+			}
+			conf.Schedule = append(conf.Schedule, app.ScheduleWindow{From: from, To: to, Workers: w.Workers, Bandwidth: w.Bandwidth})
+		}
+	}
+	if fc.Maintenance != nil {
+		conf.Maintenance = make([]app.MaintenanceWindow, 0, len(fc.Maintenance))
+		for i, w := range fc.Maintenance {
+			from, err := app.ParseClock(w.From)
+			if err != nil {
+				return fmt.Errorf("maintenance[%d].from: %w", i, err)
+			}
+			to, err := app.ParseClock(w.To)
+			if err != nil {
+				return fmt.Errorf("maintenance[%d].to: %w", i, err)
+			}
+			mw := app.MaintenanceWindow{From: from, To: to}
+			for _, d := range w.Days {
+				day, err := app.ParseWeekday(d)
+				if err != nil {
+					return fmt.Errorf("maintenance[%d].days: %w", i, err)
+				}
+				mw.Days = append(mw.Days, day)
+			}
+			conf.Maintenance = append(conf.Maintenance, mw)
+		}
+	}
+	if len(fc.Sinks) > 0 {
+		conf.Sinks = make(map[string]app.SinkConfig, len(fc.Sinks))
+		for name, s := range fc.Sinks {
+			conf.Sinks[name] = app.SinkConfig{URL: s.URL, DeleteLocal: s.DeleteLocal}
+		}
+	}
+	if fc.AllowedHosts != nil {
+		conf.AllowedHosts = fc.AllowedHosts
+	}
+	if fc.SignatureKeyrings != nil {
+		conf.SignatureKeyrings = fc.SignatureKeyrings
+	}
+	setStr(&conf.WatchDir, fc.Watch.Dir)
+	setStr(&conf.QueueURL, fc.QueueURL)
+	setDur(&conf.LeaderLease, fc.LeaderLease)
+	setStr(&conf.IngestURL, fc.IngestURL)
+	setStr(&conf.EventsURL, fc.EventsURL)
+	setStr(&conf.SMTPURL, fc.SMTPURL)
+	setStr(&conf.SMTPFrom, fc.SMTPFrom)
+	if len(fc.Notifiers) > 0 {
+		conf.Notifiers = fc.Notifiers
+	}
+	if fc.NotifyFailures != nil {
+		conf.NotifyFailures = fc.NotifyFailures
+	}
+	if fc.Alerts != nil {
+		conf.Alerts = make([]app.AlertRule, 0, len(fc.Alerts))
+		for _, r := range fc.Alerts {
+			conf.Alerts = append(conf.Alerts, app.AlertRule{
+				Name:        r.Name,
+				Host:        r.Host,
+				FailureRate: r.FailureRate,
+				MinAttempts: r.MinAttempts,
+				QueueDepth:  r.QueueDepth,
+				Window:      time.Duration(r.Window),
+				Notify:      r.Notify,
+				Email:       r.Email,
+			})
+		}
+	}
+	setDur(&conf.WatchInterval, fc.Watch.Interval)
+	return nil
+}
+
+// readSecretFile читает секрет из файла, отбрасывая завершающие пробелы и переводы строк.
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), " \t\r\n"), nil
+}
+
+func setStr(dst *string, v *string) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func setInt(dst *int, v *int) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func setDur(dst *time.Duration, v *duration) {
+	if v != nil {
+		*dst = time.Duration(*v)
+	}
+}
@@ -1,34 +1,171 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
-	"time"
+	"os"
+	"strings"
 
 	httpapi "github.com/Extrarius/29.09.2025/internal/http"
 
 	"github.com/Extrarius/29.09.2025/internal/app"
 )
 
+// version задаётся при сборке: go build -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+const usage = `Использование: downloader [команда] [флаги]
+
+Команды:
+  serve                 запустить сервис (по умолчанию, если команда не указана)
+  fetch URL... [--dest DIR] [--parallel N]
+                        скачать ссылки без запуска сервиса и WAL
+  bench --url URL [--parallel N,...] [--buffer-sizes S,...] [--count N | --duration D]
+                        замерить скорость и задержки скачивания (подбор WORKERS,
+                        HOST_CONCURRENCY, COPY_BUFFER_SIZE)
+  recover [--dry-run]   восстановить состояние из WAL: прерванные файлы → PENDING
+  export --task ID [--out FILE]
+                        сохранить задачу с файлами в архив (по умолчанию ID.tar, "-" — stdout)
+  import --in FILE      создать задачу из архива export (файлы — под DOWNLOAD_DIR)
+  gc [--dry-run] [--min-age D] [-v]
+                        убрать из DOWNLOAD_DIR файлы удалённых задач, брошенные .part
+                        и пустые каталоги (на ходу — POST /admin/gc)
+  wal compact           компактизировать WAL (одна запись на задачу)
+  store migrate         привести WAL к текущей версии формата
+  store query [--status S] [--tag T] [--created-from D] [--created-to D]
+                        вывести задачи из WAL по фильтру, не загружая сервис
+  healthcheck           проверить готовность запущенного сервиса (/readyz; код выхода 0/1)
+  version               показать версию сборки
+
+Команды recover, export, import, gc, wal и store работают с файлами напрямую —
+запускайте их при остановленном сервисе.
+Флаги конфигурации общие для всех команд: downloader <команда> -h.
+`
+
 func main() {
-	conf := app.Config{
-		Port:            env("PORT", "8080"),
-		DataDir:         env("DATA_DIR", "./data"),
-		DownloadDir:     env("DOWNLOAD_DIR", "./downloads"),
-		Workers:         envInt("WORKERS", 4),
-		HostConcurrency: envInt("HOST_CONCURRENCY", 2),
-		ClientTimeout:   envDuration("CLIENT_TIMEOUT", 60*time.Second),
-		Retries:         envInt("RETRIES", 3),
-		ShutdownWait:    envDuration("SHUTDOWN_WAIT", 20*time.Second),
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "serve":
+		err = runServe(args)
+	case "fetch":
+		err = runFetch(args)
+	case "bench":
+		err = runBench(args)
+	case "recover":
+		err = runRecover(args)
+	case "export":
+		err = runExport(args)
+	case "import":
+		err = runImport(args)
+	case "gc":
+		err = runGC(args)
+	case "wal":
+		err = runSub(cmd, args, map[string]func([]string) error{"compact": runWALCompact})
+	case "store":
+		err = runSub(cmd, args, map[string]func([]string) error{"migrate": runStoreMigrate, "query": runStoreQuery})
+	case "healthcheck":
+		err = runHealthcheck(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+			os.Exit(1)
+		}
+	case "version":
+		runVersion()
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "неизвестная команда %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", cmd, err)
+	}
+}
+
+// runSub выбирает вложенную команду (например, "wal compact") из table.
+func runSub(cmd string, args []string, table map[string]func([]string) error) error {
+	if len(args) == 0 {
+		return fmt.Errorf("не указана подкоманда\n\n%s", usage)
+	}
+	fn, ok := table[args[0]]
+	if !ok {
+		return fmt.Errorf("неизвестная подкоманда %q для %s", args[0], cmd)
 	}
+	return fn(args[1:])
+}
+
+// parseFlags создаёт набор флагов команды name с флагами конфигурации,
+// даёт extra зарегистрировать собственные флаги и разбирает args.
+// Возвращает все проблемы (файл конфигурации, окружение, флаги) одной ошибкой.
+// Позиционные аргументы для таких команд — ошибка.
+func parseFlags(name string, args []string, extra func(fs *flag.FlagSet)) (app.Config, error) {
+	conf, rest, err := parseFlagsArgs(name, args, extra)
+	if err == nil && len(rest) > 0 {
+		err = fmt.Errorf("неожиданные аргументы: %v", rest)
+	}
+	return conf, err
+}
+
+// parseFlagsArgs — как parseFlags, но возвращает позиционные аргументы.
+// Флаги и позиционные аргументы можно перемешивать ("fetch URL -dest DIR URL2"):
+// стандартный flag останавливается на первом позиционном, поэтому разбор продолжается
+// с аргумента после него. Всё после "--" считается позиционным.
+func parseFlagsArgs(name string, args []string, extra func(fs *flag.FlagSet)) (app.Config, []string, error) {
+	conf, configFile, loadErr := loadConfig(args)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	bindConfigFlags(fs, &conf, configFile)
+	if extra != nil {
+		extra(fs)
+	}
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return conf, rest, errors.Join(loadErr, err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		if len(args) > fs.NArg() && args[len(args)-fs.NArg()-1] == "--" {
+			rest = append(rest, fs.Args()...)
+			break
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	return conf, rest, loadErr
+}
+
+// runServe — команда serve: запускает HTTP API, воркеры и восстановление из WAL.
+func runServe(args []string) error {
+	var showConfig bool
+	conf, err := parseFlags("serve", args, func(fs *flag.FlagSet) {
+		fs.BoolVar(&showConfig, "print-config", false, "напечатать эффективную конфигурацию и выйти")
+	})
+	if showConfig && err == nil {
+		return printConfig(os.Stdout, conf)
+	}
+	if err := errors.Join(err, conf.Validate()); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+
 	application, err := app.New(conf)
 	if err != nil {
-		log.Fatalf("app init: %v", err)
+		return fmt.Errorf("app init: %w", err)
 	}
 	defer application.Close()
 
 	router := httpapi.NewRouter(application) //+++
-	if err := application.Serve(router); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("serve: %v", err)
+	adminRouter := httpapi.NewAdminRouter(application)
+	if err := application.Serve(router, adminRouter); err != nil && err != http.ErrServerClosed {
+		return err
 	}
+	return nil
 }
@@ -0,0 +1,348 @@
+// Команда downloaderctl — консольный клиент HTTP API сервиса загрузок.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Extrarius/29.09.2025/pkg/client"
+)
+
+const usage = `Использование: downloaderctl [-server URL] [-api-key KEY] <команда> [флаги]
+
+Команды:
+  submit [-label L] [-dest DIR] [-preserve-path] [-sink NAME] [-file F|-] [-watch] URL...
+                                                             создать задачу
+         [-dry-run [-probe]]                                 только проверить: вердикты по ссылкам
+  watch ID                                                   следить за прогрессом задачи
+  get ID                                                     показать задачу (JSON)
+  list [-status S] [-label TEXT] [-limit N]                  список задач (потоком; -limit 0 — все)
+  retry ID                                                   перезапустить упавшие файлы
+  verify [-dry-run] ID                                       перепроверить файлы, испорченные перекачать
+  clone [-failed] [-watch] ID                                новая задача из ссылок существующей
+  retry-failed [-status S] [-tag T] [-host H] [ID...]        массовый retry по фильтру
+  cancel [-status S] [-tag T] [-host H] [ID...]              отменить незавершённые файлы по фильтру
+
+Окружение: DOWNLOADER_URL (по умолчанию http://localhost:8080), DOWNLOADER_API_KEY.
+`
+
+// ctl — команды поверх pkg/client.
+type ctl struct {
+	c   *client.Client
+	ctx context.Context
+}
+
+func main() {
+	fs := flag.NewFlagSet("downloaderctl", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	server := fs.String("server", envOr("DOWNLOADER_URL", "http://localhost:8080"), "адрес сервиса")
+	apiKey := fs.String("api-key", os.Getenv("DOWNLOADER_API_KEY"), "ключ API")
+	_ = fs.Parse(os.Args[1:])
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	c := &ctl{c: client.New(*server, client.WithAPIKey(*apiKey)), ctx: ctx}
+	cmd, args := fs.Arg(0), fs.Args()[1:]
+
+	var err error
+	switch cmd {
+	case "submit":
+		err = c.submit(args)
+	case "watch":
+		err = withID(args, c.watch)
+	case "get":
+		err = withID(args, c.get)
+	case "list":
+		err = c.list(args)
+	case "retry":
+		err = withID(args, c.retry)
+	case "verify":
+		err = c.verify(args)
+	case "clone":
+		err = c.clone(args)
+	case "retry-failed":
+		err = c.bulk("retry-failed", args, c.c.RetryTasks)
+	case "cancel":
+		err = c.bulk("cancel", args, c.c.CancelTasks)
+	default:
+		fmt.Fprintf(os.Stderr, "неизвестная команда %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd, err)
+		os.Exit(1)
+	}
+}
+
+func withID(args []string, fn func(id string) error) error {
+	if len(args) != 1 {
+		return fmt.Errorf("ожидается ровно один ID задачи")
+	}
+	return fn(args[0])
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// submit создаёт задачу из ссылок в аргументах и/или файле (-file, "-" — stdin).
+func (c *ctl) submit(args []string) error {
+	fs := flag.NewFlagSet("submit", flag.ExitOnError)
+	label := fs.String("label", "", "метка задачи")
+	dest := fs.String("dest", "", "подкаталог назначения")
+	preserve := fs.Bool("preserve-path", false, "раскладывать файлы по <host>/<путь из URL>")
+	sinkName := fs.String("sink", "", "выгрузить файлы в хранилище (имя из секции sinks сервиса)")
+	file := fs.String("file", "", "файл со ссылками (по одной на строку, \"-\" — stdin)")
+	watch := fs.Bool("watch", false, "после создания следить за прогрессом")
+	dryRun := fs.Bool("dry-run", false, "не создавать задачу, а показать, что с ней стало бы")
+	probe := fs.Bool("probe", false, "с -dry-run: проверить доступность каждой ссылки")
+	_ = fs.Parse(args)
+
+	links := append([]string(nil), fs.Args()...)
+	if *file != "" {
+		fromFile, err := readLinks(*file)
+		if err != nil {
+			return err
+		}
+		links = append(links, fromFile...)
+	}
+	if len(links) == 0 {
+		return fmt.Errorf("не переданы ссылки")
+	}
+
+	req := client.CreateTaskRequest{Links: client.URLs(links...), Label: *label, DestDir: *dest, Sink: *sinkName}
+	if *preserve {
+		req.Layout = client.LayoutPreservePath
+	}
+	if *dryRun {
+		return c.dryRun(req, *probe)
+	}
+	id, err := c.c.CreateTask(c.ctx, req)
+	if err != nil {
+		return err
+	}
+	fmt.Println(id)
+	if *watch {
+		return c.watch(id)
+	}
+	return nil
+}
+
+// dryRun печатает результат пробного прогона: строку на ссылку (вердикт, URL,
+// путь или причина) и ошибки уровня задачи; задача не создалась бы — ошибка.
+func (c *ctl) dryRun(req client.CreateTaskRequest, probe bool) error {
+	res, err := c.c.ValidateTask(c.ctx, req, probe)
+	if err != nil {
+		return err
+	}
+	for _, l := range res.Links {
+		detail := l.DestPath
+		switch {
+		case l.Error != "":
+			detail = l.Error
+		case l.DuplicateOf != nil:
+			detail = fmt.Sprintf("как ссылка %d", *l.DuplicateOf)
+		}
+		fmt.Printf("%-11s %s  %s\n", l.Verdict, l.URL, detail)
+	}
+	for _, e := range res.Errors {
+		fmt.Printf("%-11s %s: %s\n", "error", e.Field, e.Error)
+	}
+	if !res.Valid {
+		return fmt.Errorf("задача не была бы создана")
+	}
+	return nil
+}
+
+// readLinks читает ссылки из файла: по одной на строку, пустые строки и "#…" пропускаются.
+func readLinks(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var links []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		links = append(links, line)
+	}
+	return links, sc.Err()
+}
+
+// watch подписывается на изменения задачи (SSE) и рисует строку прогресса,
+// пока задача не перейдёт в конечный статус.
+func (c *ctl) watch(id string) error {
+	var last *client.Task
+	err := c.c.WatchTask(c.ctx, id, func(t *client.Task) error {
+		last = t
+		fmt.Fprintf(os.Stderr, "\r%s", progressLine(t))
+		return nil
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+	for _, f := range last.Files {
+		if f.State == client.FileFailed {
+			fmt.Fprintf(os.Stderr, "  FAILED %s: %s\n", f.URL, f.Error)
+		}
+	}
+	if last.Status != client.TaskComplete {
+		return fmt.Errorf("задача завершилась со статусом %s", last.Status)
+	}
+	return nil
+}
+
+// progressLine рисует строку вида "[#####.....]  50.0% 5/10 files, 12.3 MiB/24.6 MiB  RUNNING"
+// по прогрессу, который считает сервис (progress_percent, total_bytes_*).
+func progressLine(t *client.Task) string {
+	const width = 30
+	filled := int(t.ProgressPercent * width / 100)
+	filled = min(max(filled, 0), width)
+	size := humanBytes(t.TotalBytesDownloaded)
+	if t.TotalBytesExpected > 0 {
+		size += "/" + humanBytes(t.TotalBytesExpected)
+	}
+	return fmt.Sprintf("[%s%s] %5.1f%% %d/%d files, %s  %-8s",
+		strings.Repeat("#", filled), strings.Repeat(".", width-filled),
+		t.ProgressPercent, t.Done+t.Failed+t.Cancelled+t.Skipped+t.Expired, t.Total, size, t.Status)
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func (c *ctl) get(id string) error {
+	t, err := c.c.GetTask(c.ctx, id)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// list печатает задачи таблицей, фильтруя по статусу и подстроке метки на стороне клиента.
+func (c *ctl) list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	status := fs.String("status", "", "фильтр по статусу (PENDING, RUNNING, PAUSED, COMPLETE, FAILED, PARTIAL, CANCELLED)")
+	label := fs.String("label", "", "фильтр по подстроке метки")
+	limit := fs.Int("limit", 100, "сколько задач запросить (0 — все)")
+	_ = fs.Parse(args)
+
+	fmt.Printf("%-24s %-9s %5s %5s %6s  %s\n", "ID", "STATUS", "DONE", "FAIL", "TOTAL", "LABEL")
+	return c.c.EachTask(c.ctx, client.ListOptions{Limit: *limit}, func(t *client.Task) error {
+		if *status != "" && !strings.EqualFold(string(t.Status), *status) {
+			return nil
+		}
+		if *label != "" && !strings.Contains(t.Label, *label) {
+			return nil
+		}
+		fmt.Printf("%-24s %-9s %5d %5d %6d  %s\n", t.ID, t.Status, t.Done, t.Failed, t.Total, t.Label)
+		return nil
+	})
+}
+
+// verify перепроверяет файлы задачи и печатает проблемные.
+func (c *ctl) verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "только отчёт, не перекачивать")
+	_ = fs.Parse(args)
+	return withID(fs.Args(), func(id string) error {
+		res, err := c.c.VerifyTask(c.ctx, id, *dryRun)
+		if err != nil {
+			return err
+		}
+		for _, f := range res.Files {
+			if f.Result != "ok" {
+				fmt.Printf("%-17s %s  %s\n", f.Result, f.Path, f.Detail)
+			}
+		}
+		fmt.Printf("checked %d, ok %d, bad %d, requeued %d\n", res.Checked, res.OK, res.Bad, res.Requeued)
+		return nil
+	})
+}
+
+func (c *ctl) retry(id string) error {
+	n, err := c.c.RetryTask(c.ctx, id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("retried %d file(s)\n", n)
+	return nil
+}
+
+// clone создаёт задачу из ссылок существующей (-failed — только упавшие) и печатает её ID.
+func (c *ctl) clone(args []string) error {
+	fs := flag.NewFlagSet("clone", flag.ExitOnError)
+	failed := fs.Bool("failed", false, "только упавшие файлы")
+	watch := fs.Bool("watch", false, "после создания следить за прогрессом")
+	_ = fs.Parse(args)
+	return withID(fs.Args(), func(id string) error {
+		newID, err := c.c.CloneTask(c.ctx, id, *failed)
+		if err != nil {
+			return err
+		}
+		fmt.Println(newID)
+		if *watch {
+			return c.watch(newID)
+		}
+		return nil
+	})
+}
+
+// bulk выполняет массовую операцию op с фильтром из флагов; ID задач — позиционные аргументы.
+func (c *ctl) bulk(name string, args []string, op func(context.Context, client.TaskFilter) (*client.BulkResult, error)) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	var filter client.TaskFilter
+	fs.StringVar(&filter.Status, "status", "", "только задачи с этим статусом")
+	fs.StringVar(&filter.Tag, "tag", "", "только задачи с этим тегом")
+	fs.StringVar(&filter.Host, "host", "", "только файлы с этого хоста")
+	_ = fs.Parse(args)
+	filter.IDs = fs.Args()
+
+	res, err := op(c.ctx, filter)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d file(s) in %d task(s)\n", res.Files, res.Tasks)
+	for _, id := range res.TaskIDs {
+		fmt.Println(" ", id)
+	}
+	return nil
+}
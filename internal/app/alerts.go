@@ -0,0 +1,440 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/metrics"
+	"github.com/Extrarius/29.09.2025/internal/notify"
+)
+
+// Правила оповещений (секция alerts файла конфигурации) — простые условия,
+// которые сервис проверяет сам и о которых сообщает через уведомители
+// (notifiers) и почту, без внешнего мониторинга:
+//   - доля неудачных загрузок хоста за окно выше порога ("больше 50% ошибок
+//     у cdn.example.com за 10 минут");
+//   - очередь глубже N заданий всё окно ("больше 1000 заданий 15 минут").
+//
+// Правило срабатывает (FIRING) и отменяется (RESOLVED) по одному сообщению на
+// переход. Состояние правил хранится только в памяти: после перезапуска
+// условия оцениваются заново (доля ошибок — по загрузкам с момента запуска),
+// и ещё не доставленные оповещения теряются.
+
+// alertInterval — период проверки правил; alertMinAttempts — сколько загрузок
+// хоста за окно нужно, чтобы судить о доле ошибок (AlertRule.MinAttempts).
+const (
+	alertInterval    = 15 * time.Second
+	alertMinAttempts = 5
+)
+
+// AlertRule — правило оповещения. Задаётся одно условие: FailureRate или
+// QueueDepth.
+type AlertRule struct {
+	Name        string
+	Host        string        // хост или "*.example.com" (только FailureRate); пусто — любой, каждый отдельно
+	FailureRate float64       // доля неудачных загрузок хоста за Window (0..1), выше которой правило срабатывает
+	MinAttempts int           // загрузок хоста за Window, меньше которых доля не оценивается; 0 — alertMinAttempts
+	QueueDepth  int           // заданий в очереди, выше которого правило срабатывает, если держится всё Window
+	Window      time.Duration // окно оценки
+	Notify      []string      // уведомители из секции notifiers
+	Email       string        // адреса через запятую (нужен SMTP_URL)
+}
+
+// minAttempts — действующий порог MinAttempts.
+func (r AlertRule) minAttempts() int64 {
+	if r.MinAttempts > 0 {
+		return int64(r.MinAttempts)
+	}
+	return alertMinAttempts
+}
+
+// Condition описывает условие правила: "failure rate > 50% over 10m (min 5
+// downloads)", "queue depth > 1000 for 15m".
+func (r AlertRule) Condition() string {
+	if r.QueueDepth > 0 {
+		return fmt.Sprintf("queue depth > %d for %s", r.QueueDepth, r.Window)
+	}
+	return fmt.Sprintf("failure rate > %s over %s (min %d downloads)", formatPercent(r.FailureRate), r.Window, r.minAttempts())
+}
+
+// validateAlerts проверяет правила оповещений: уникальные имена, ровно одно
+// условие, окно не короче минуты, получатели — из notifiers и почта при
+// заданном SMTP_URL.
+func validateAlerts(c *Config, add func(format string, args ...any)) {
+	seen := make(map[string]bool, len(c.Alerts))
+	for i, r := range c.Alerts {
+		name := r.Name
+		switch {
+		case name == "":
+			add("alerts[%d].name: не задано имя правила", i)
+			name = fmt.Sprintf("alerts[%d]", i)
+		case seen[name]:
+			add("alerts: имя правила %q повторяется", name)
+		default:
+			seen[name] = true
+			name = "alerts." + name
+		}
+		switch {
+		case r.FailureRate == 0 && r.QueueDepth == 0:
+			add("%s: задайте условие failure_rate или queue_depth", name)
+		case r.FailureRate != 0 && r.QueueDepth != 0:
+			add("%s: failure_rate и queue_depth в одном правиле не сочетаются", name)
+		case r.FailureRate < 0 || r.FailureRate >= 1:
+			add("%s.failure_rate: ожидается доля больше 0 и меньше 1 (0.5 — 50%%), получено %v", name, r.FailureRate)
+		case r.QueueDepth < 0:
+			add("%s.queue_depth: должно быть > 0, получено %d", name, r.QueueDepth)
+		case r.QueueDepth > 0 && r.Host != "":
+			add("%s.host: задаётся только с failure_rate", name)
+		}
+		if r.MinAttempts < 0 {
+			add("%s.min_attempts: должно быть >= 0 (0 — %d), получено %d", name, alertMinAttempts, r.MinAttempts)
+		}
+		if r.Window < time.Minute {
+			add("%s.window: должно быть не меньше 1m, получено %s", name, r.Window)
+		}
+		if len(r.Notify) == 0 && r.Email == "" {
+			add("%s: не заданы получатели (notify или email)", name)
+		}
+		for _, n := range r.Notify {
+			if _, ok := c.Notifiers[n]; !ok {
+				add("%s.notify: уведомитель %q не задан в секции notifiers", name, n)
+			}
+		}
+		if r.Email != "" {
+			if _, err := core.NotifyRecipients(r.Email); err != nil {
+				add("%s.email: %v", name, err)
+			} else if c.SMTPURL == "" {
+				add("%s.email: почта не настроена (SMTP_URL)", name)
+			}
+		}
+	}
+}
+
+// AlertStatus — состояние правила оповещения (для правил по хостам — по
+// каждому хосту, с которым работал загрузчик), см. App.Alerts.
+type AlertStatus struct {
+	Rule      string     `json:"rule"`
+	Host      string     `json:"host,omitempty"`
+	Condition string     `json:"condition"`
+	Firing    bool       `json:"firing"`
+	Value     string     `json:"value"`           // последнее значение: "73% (11 of 15 downloads failed)", "1520 jobs"
+	Since     *time.Time `json:"since,omitempty"` // когда сработало
+	CheckedAt time.Time  `json:"checked_at"`
+}
+
+// hostCounts — накопленные с запуска успехи и неудачи загрузок хоста.
+type hostCounts struct{ successes, failures int64 }
+
+// alertSample — снимок счётчиков хостов (downloader.HostStats) в момент at.
+type alertSample struct {
+	at    time.Time
+	hosts map[string]hostCounts
+}
+
+// alerter — проверка правил оповещений (alertLoop).
+type alerter struct {
+	rules   []AlertRule
+	samples []alertSample        // от старых к новым, не старше самого длинного окна; только в alertLoop
+	deep    map[string]time.Time // правило QueueDepth → с какого момента очередь глубже порога; только в alertLoop
+
+	mu     sync.Mutex
+	states map[string]*AlertStatus // "<правило>\x00<хост>"; под mu
+
+	stop chan struct{}
+	done chan struct{}
+
+	fired metrics.Counter
+}
+
+// newAlerter создаёт проверку правил rules; nil — правила не заданы.
+func newAlerter(rules []AlertRule) *alerter {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &alerter{
+		rules:  rules,
+		deep:   make(map[string]time.Time),
+		states: make(map[string]*AlertStatus),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// startAlerts запускает проверку правил оповещений (если они заданы).
+func (a *App) startAlerts() {
+	if a.alerts == nil {
+		return
+	}
+	go a.alertLoop()
+}
+
+// stopAlerts останавливает alertLoop и ждёт его выхода.
+func (a *App) stopAlerts() {
+	al := a.alerts
+	if al == nil {
+		return
+	}
+	select {
+	case <-al.stop: // Close уже вызывали
+	default:
+		close(al.stop)
+	}
+	<-al.done
+}
+
+// alertLoop каждые alertInterval снимает счётчики и проверяет правила.
+func (a *App) alertLoop() {
+	al := a.alerts
+	defer close(al.done)
+	tick := time.NewTicker(alertInterval)
+	defer tick.Stop()
+	a.checkAlerts(time.Now())
+	for {
+		select {
+		case <-al.stop:
+			return
+		case now := <-tick.C:
+			a.checkAlerts(now)
+		}
+	}
+}
+
+// checkAlerts добавляет снимок счётчиков хостов и оценивает все правила
+// в момент now.
+func (a *App) checkAlerts(now time.Time) {
+	al := a.alerts
+	cur := alertSample{at: now, hosts: make(map[string]hostCounts)}
+	for _, hs := range a.loader.HostStats() {
+		cur.hosts[hs.Host] = hostCounts{successes: hs.Successes, failures: hs.Failures}
+	}
+	al.samples = append(al.samples, cur)
+	var longest time.Duration
+	for _, r := range al.rules {
+		if r.Window > longest {
+			longest = r.Window
+		}
+	}
+	// самый старый нужный снимок — последний, сделанный не позже now-longest
+	for len(al.samples) > 1 && !al.samples[1].at.After(now.Add(-longest)) {
+		al.samples = al.samples[1:]
+	}
+
+	qs := a.dispatcher.Stats()
+	depth := qs.Inbound + qs.Backlog + qs.Delayed + qs.Outbound
+	for _, r := range al.rules {
+		if r.QueueDepth > 0 {
+			a.checkQueueDepth(r, depth, now)
+			continue
+		}
+		base := al.baseline(now.Add(-r.Window))
+		for host, c := range cur.hosts {
+			if r.Host != "" && !core.MatchHost(r.Host, host) {
+				continue
+			}
+			b := base.hosts[host]
+			failed := c.failures - b.failures
+			total := failed + c.successes - b.successes
+			var rate float64
+			if total > 0 {
+				rate = float64(failed) / float64(total)
+			}
+			value := fmt.Sprintf("%s (%d of %d downloads failed)", formatPercent(rate), failed, total)
+			switch {
+			case total >= r.minAttempts() && rate > r.FailureRate:
+				a.setAlert(r, host, true, value, now)
+			case total == 0 || (total >= r.minAttempts() && rate <= r.FailureRate):
+				a.setAlert(r, host, false, value, now)
+			default: // загрузок мало, чтобы судить: состояние не меняется
+				a.touchAlert(r, host, value, now)
+			}
+		}
+	}
+}
+
+// baseline — снимок, от которого считается окно, начинающееся в from:
+// последний не позже from; если сервис работает меньше окна — самый старый.
+func (al *alerter) baseline(from time.Time) alertSample {
+	base := al.samples[0]
+	for _, s := range al.samples[1:] {
+		if s.at.After(from) {
+			break
+		}
+		base = s
+	}
+	return base
+}
+
+// checkQueueDepth оценивает правило по глубине очереди depth: срабатывает,
+// если очередь глубже порога на всех проверках последнего Window.
+func (a *App) checkQueueDepth(r AlertRule, depth int, now time.Time) {
+	al := a.alerts
+	value := fmt.Sprintf("%d jobs", depth)
+	if depth <= r.QueueDepth {
+		delete(al.deep, r.Name)
+		a.setAlert(r, "", false, value, now)
+		return
+	}
+	since, ok := al.deep[r.Name]
+	if !ok {
+		since = now
+		al.deep[r.Name] = now
+	}
+	if now.Sub(since) >= r.Window {
+		a.setAlert(r, "", true, value, now)
+	} else {
+		a.touchAlert(r, "", value, now)
+	}
+}
+
+// alertState возвращает (создавая) состояние правила r по хосту host.
+// Вызывать под al.mu.
+func (al *alerter) alertState(r AlertRule, host string) *AlertStatus {
+	key := r.Name + "\x00" + host
+	st, ok := al.states[key]
+	if !ok {
+		st = &AlertStatus{Rule: r.Name, Host: host, Condition: r.Condition()}
+		al.states[key] = st
+	}
+	return st
+}
+
+// touchAlert обновляет значение правила, не меняя его состояния.
+func (a *App) touchAlert(r AlertRule, host, value string, now time.Time) {
+	al := a.alerts
+	al.mu.Lock()
+	st := al.alertState(r, host)
+	st.Value, st.CheckedAt = value, now.UTC()
+	al.mu.Unlock()
+}
+
+// setAlert переводит правило r по хосту host в состояние firing; при смене
+// состояния пишет в лог и отправляет оповещение (FIRING или RESOLVED).
+func (a *App) setAlert(r AlertRule, host string, firing bool, value string, now time.Time) {
+	al := a.alerts
+	now = now.UTC()
+	al.mu.Lock()
+	st := al.alertState(r, host)
+	st.Value, st.CheckedAt = value, now
+	if st.Firing == firing {
+		al.mu.Unlock()
+		return
+	}
+	st.Firing = firing
+	var since time.Time
+	if st.Since != nil {
+		since = *st.Since
+	}
+	if firing {
+		st.Since = &now
+	} else {
+		st.Since = nil
+	}
+	al.mu.Unlock()
+
+	subject := r.Name
+	if host != "" {
+		subject += " for " + host
+	}
+	if firing {
+		al.fired.Inc()
+		log.Printf("Alert: %s FIRING: %s: %s", subject, r.Condition(), value)
+	} else {
+		log.Printf("Alert: %s RESOLVED after %s: %s", subject, now.Sub(since).Round(time.Second), value)
+	}
+	if a.notifier != nil {
+		a.notifier.enqueueAlert(r, alertMessage(r, host, firing, value, since, now))
+	}
+}
+
+// alertMessage — текст оповещения о срабатывании (firing) или отмене правила.
+// since — когда правило сработало (для отмены).
+func alertMessage(r AlertRule, host string, firing bool, value string, since, now time.Time) notify.Message {
+	state := "RESOLVED"
+	if firing {
+		state = "FIRING"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Alert:     %s\n", r.Name)
+	if host != "" {
+		fmt.Fprintf(&b, "Host:      %s\n", host)
+	}
+	fmt.Fprintf(&b, "Condition: %s\n", r.Condition())
+	fmt.Fprintf(&b, "State:     %s\n", state)
+	fmt.Fprintf(&b, "Value:     %s\n", value)
+	if firing {
+		fmt.Fprintf(&b, "Since:     %s\n", now.Format(time.RFC3339))
+	} else if !since.IsZero() {
+		fmt.Fprintf(&b, "Fired:     %s (lasted %s)\n", since.Format(time.RFC3339), now.Sub(since).Round(time.Second))
+	}
+	subject := fmt.Sprintf("[downloader] %s: %s", state, r.Name)
+	if host != "" {
+		subject += " (" + host + ")"
+	}
+	return notify.Message{Subject: subject, Text: b.String()}
+}
+
+// Alerts возвращает состояние правил оповещений: сначала сработавшие, затем
+// по имени правила и хосту. Пусто — правила не заданы или ещё не проверялись.
+func (a *App) Alerts() []AlertStatus {
+	al := a.alerts
+	if al == nil {
+		return []AlertStatus{}
+	}
+	al.mu.Lock()
+	out := make([]AlertStatus, 0, len(al.states))
+	for _, st := range al.states {
+		out = append(out, *st)
+	}
+	al.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Firing != out[j].Firing {
+			return out[i].Firing
+		}
+		if out[i].Rule != out[j].Rule {
+			return out[i].Rule < out[j].Rule
+		}
+		return out[i].Host < out[j].Host
+	})
+	return out
+}
+
+// alertsFiring — число сработавших правил (метрика).
+func (a *App) alertsFiring() int {
+	al := a.alerts
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	n := 0
+	for _, st := range al.states {
+		if st.Firing {
+			n++
+		}
+	}
+	return n
+}
+
+// formatPercent — доля 0..1 в процентах: 0.5 → "50%", 0.125 → "12.5%".
+func formatPercent(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/10, 'f', -1, 64) + "%"
+}
+
+// alertRecipients — каналы правила r: почта (если задана) и уведомители.
+func alertRecipients(r AlertRule) []string {
+	var out []string
+	if r.Email != "" {
+		out = append(out, core.NotifyChannelEmail)
+	}
+	for _, name := range r.Notify {
+		if !slices.Contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
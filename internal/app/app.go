@@ -3,32 +3,200 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
-	"net/http"
+	"net/url"
 	"os"
-	"os/signal"
 	"path/filepath"
+	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
+	"github.com/Extrarius/29.09.2025/internal/auth"
 	"github.com/Extrarius/29.09.2025/internal/core"
 	"github.com/Extrarius/29.09.2025/internal/downloader"
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+	"github.com/Extrarius/29.09.2025/internal/metrics"
+	"github.com/Extrarius/29.09.2025/internal/notify"
 	"github.com/Extrarius/29.09.2025/internal/queue"
+	"github.com/Extrarius/29.09.2025/internal/sink"
+	"github.com/Extrarius/29.09.2025/internal/storage"
 	"github.com/Extrarius/29.09.2025/internal/store"
 )
 
 type Config struct {
-	Port            string
-	DataDir         string
-	DownloadDir     string
-	Workers         int
-	HostConcurrency int
-	ClientTimeout   time.Duration
-	Retries         int
-	ShutdownWait    time.Duration
+	Port                 string
+	DataDir              string
+	DownloadDir          string
+	BlobDir              string        // контентно-адресуемое хранилище файлов (жёсткие ссылки); пусто — выключено
+	QuarantineDir        string        // карантин недокачанных файлов (downloader.QuarantineInfo); пусто — удаляются
+	PartDir              string        // каталог временных .part-файлов загрузок (PART_LOCATION=global)
+	PartLocation         string        // где .part-файлы: same, task, global (PartPlacement); пусто — по PartDir
+	FinalizeSync         string        // fsync перед тем, как файл считается сохранённым: none, file, full (downloader.Sync*)
+	FixExtensions        bool          // исправлять расширение файла по типу содержимого (core.FixExtension)
+	TaskManifest         string        // опись в каталоге завершённой задачи: json, sha256, both; пусто — не пишется
+	ErrorLang            string        // язык сообщений об ошибках API без Accept-Language: ru, en
+	DependsAllowPartial  bool          // зависимость depends_on в статусе PARTIAL считается выполненной
+	DuplicatePolicy      string        // повторы URL в задаче: allow, dedupe, reject (core.Duplicates*)
+	DuplicateWindow      time.Duration // повторы ищутся и в задачах, созданных за это время; 0 — только внутри задачи
+	TaskIDFormat         string        // формат ID новых задач: timestamp, uuidv7, ulid (core.ID*); пусто — timestamp
+	TrashRetention       time.Duration // DELETE переносит задачу в корзину на этот срок (trash.go); 0 — удаляет сразу
+	S3Endpoint           string        // S3-совместимое хранилище для dest_dir "s3://…"; пусто — AWS
+	S3Region             string        // регион S3; пусто — us-east-1
+	S3PathStyle          bool          // bucket в пути запроса (MinIO и др.), а не в имени хоста
+	Workers              int
+	BandwidthLimit       Bandwidth // общее ограничение скорости загрузок; 0 — без ограничения
+	WriteRateLimit       Bandwidth // общее ограничение скорости записи загрузок в хранилище; 0 — без ограничения
+	WorkerNice           int       // nice потоков воркеров, 0..MaxWorkerNice; 0 — не менять (nice.go)
+	WorkerIOPriority     string    // класс ввода-вывода потоков воркеров: normal, low, idle (IOPriority*); пусто — normal
+	CopyBufferSize       ByteSize  // блок копирования тела ответа в файл; 0 — 32KB (downloader.DefaultBufferSize)
+	Preallocate          bool      // резервировать место под файл по Content-Length (fallocate)
+	BypassPageCache      bool      // вытеснять записанное из кеша страниц (fdatasync + fadvise)
+	MaxInflightBytes     ByteSize  // общий предел памяти под буферы идущих загрузок; 0 — без ограничения
+	DiskLowWatermark     ByteSize  // свободного места в DownloadDir меньше — очередь в drain (diskguard.go); 0 — без защиты
+	DiskHighWatermark    ByteSize  // свободного места больше — выдача возобновляется; 0 — как DiskLowWatermark
+	DiskGuardEmail       string    // адреса оповещений защиты свободного места через запятую (нужен SMTPURL)
+	HostConcurrency      int
+	ClientTimeout        time.Duration
+	Retries              int
+	ShutdownWait         time.Duration
+	AdminUser            string
+	AdminPassword        string
+	AdminLockoutAttempts int           // неудачных входов в админку до блокировки IP; 0 — без блокировки
+	AdminLockoutDuration time.Duration // окно подсчёта неудач и длительность блокировки
+	APIKeys              []string      // ключи доступа к API задач; пусто — API открыт (если не задан JWTIssuer)
+	JWTIssuer            string        // издатель JWT (OIDC); пусто — JWT не принимаются
+	JWTJWKSURL           string        // JWKS издателя; пусто — из OIDC discovery
+	JWTAudience          string        // ожидаемый "aud"; пусто — не проверяется
+	JWTRoleClaim         string        // claim с ролью (путь через точку)
+	JWTTenantClaim       string        // claim с арендатором (путь через точку)
+	SignedURLKey         string        // ключ HMAC подписанных ссылок на файлы; пусто — ссылки не выдаются
+	SignedURLMaxTTL      time.Duration // максимальный срок жизни подписанной ссылки
+	SignedURLBase        string        // публичный адрес сервиса для подписанных ссылок ("https://dl.example.com"); пусто — только путь
+	RetryBackoff         time.Duration // пауза перед первым автоповтором файла (дальше — вдвое больше)
+	RetryBackoffMax      time.Duration // предел паузы между автоповторами
+	WALAsyncQueue        int           // очередь фоновой записи WAL (операций); 0 — запись синхронная
+	QueueURL             string        // общая очередь заданий (redis://...); пусто — очередь в памяти
+	LeaderLease          time.Duration // аренда лидера в Redis QUEUE_URL: работает один экземпляр; 0 — выключено
+	IngestURL            string        // приём задач из брокера (nats://...?subject=...); пусто — выключен
+	EventsURL            string        // публикация событий задач в брокер (nats://...?subject=...); пусто — выключена
+	SMTPURL              string        // почтовый сервер для notify_email (smtp:// или smtps://); пусто — почта выключена
+	SMTPFrom             string        // адрес отправителя сводок
+	MaxLinksPerTask      int           // ссылок в задаче; 0 — только встроенные пределы API
+	MaxPendingPerTenant  int           // незавершённых файлов у арендатора; 0 — без ограничения
+	MaxTasksPerHour      int           // новых задач арендатора в час; 0 — без ограничения
+	TLSCert              string
+	TLSKey               string
+	TLSSelfSigned        bool
+	TLSClientCA          string
+	Listen               []string
+	AdminListen          []string
+	HostLimits           map[string]int         // шаблон хоста → параллельность, перекрывает HostConcurrency
+	HostProfiles         map[string]HostProfile // шаблон хоста → заголовки, скорость, прокси, повторы (hostprofiles.go)
+	Sinks                map[string]SinkConfig  // имя → хранилище для выгрузки файлов (TaskSpec.Sink)
+	Notifiers            map[string]string      // имя → адрес уведомителя (slack://, telegram://), TaskSpec.Notify
+	NotifyFailures       []string               // уведомители сводок по всем задачам, завершившимся с ошибками (FAILED, PARTIAL)
+	DiskGuardNotify      []string               // уведомители оповещений защиты свободного места (diskguard.go)
+	Alerts               []AlertRule            // правила оповещений: доля ошибок хоста, глубина очереди (alerts.go)
+	Schedule             []ScheduleWindow       // окна суток со своими Workers/BandwidthLimit (timetable.go)
+	Maintenance          []MaintenanceWindow    // окна обслуживания: очередь в drain (maintenance.go)
+	AllowedHosts         []string               // пусто — разрешены любые хосты
+	SignatureKeyrings    []string               // файлы открытых ключей OpenPGP для проверки подписей ссылок (signature)
+	WatchDir             string                 // каталог манифестов *.urls/*.json; пусто — выключено
+	WatchInterval        time.Duration          // период опроса WatchDir
 }
 
+// JWTConfig собирает параметры проверки JWT для auth.NewVerifier.
+func (c *Config) JWTConfig() auth.JWTConfig {
+	return auth.JWTConfig{
+		Issuer:      c.JWTIssuer,
+		JWKSURL:     c.JWTJWKSURL,
+		Audience:    c.JWTAudience,
+		RoleClaim:   c.JWTRoleClaim,
+		TenantClaim: c.JWTTenantClaim,
+	}
+}
+
+// Limits — начальные лимиты приёма задач (App.Limits).
+func (c *Config) Limits() Limits {
+	return Limits{
+		LinksPerTask:          c.MaxLinksPerTask,
+		PendingFilesPerTenant: c.MaxPendingPerTenant,
+		TasksPerHour:          c.MaxTasksPerHour,
+	}
+}
+
+// APIAuthEnabled сообщает, что API задач требует аутентификации
+// (заданы API_KEYS или JWT_ISSUER).
+func (c *Config) APIAuthEnabled() bool { return len(c.APIKeys) > 0 || c.JWTIssuer != "" }
+
+// Redacted возвращает копию конфигурации с замаскированными секретами —
+// для печати и логов.
+func (c Config) Redacted() Config {
+	if c.AdminPassword != "" {
+		c.AdminPassword = "***"
+	}
+	if c.SignedURLKey != "" {
+		c.SignedURLKey = "***"
+	}
+	c.SMTPURL = notify.Redact(c.SMTPURL)
+	if len(c.Notifiers) > 0 {
+		notifiers := make(map[string]string, len(c.Notifiers))
+		for name, raw := range c.Notifiers {
+			notifiers[name] = notify.Redact(raw)
+		}
+		c.Notifiers = notifiers
+	}
+	if len(c.APIKeys) > 0 {
+		masked := make([]string, len(c.APIKeys))
+		for i := range masked {
+			masked[i] = "***"
+		}
+		c.APIKeys = masked
+	}
+	if len(c.Sinks) > 0 {
+		sinks := make(map[string]SinkConfig, len(c.Sinks))
+		for name, sc := range c.Sinks {
+			sc.URL = sink.Redact(sc.URL)
+			sinks[name] = sc
+		}
+		c.Sinks = sinks
+	}
+	if len(c.HostProfiles) > 0 {
+		profiles := make(map[string]HostProfile, len(c.HostProfiles))
+		for pattern, p := range c.HostProfiles {
+			if len(p.Headers) > 0 {
+				headers := make(map[string]string, len(p.Headers))
+				for name := range p.Headers {
+					headers[name] = "***" // обычно в них токены и cookie
+				}
+				p.Headers = headers
+			}
+			p.Proxy = notify.Redact(p.Proxy)
+			profiles[pattern] = p
+		}
+		c.HostProfiles = profiles
+	}
+	return c
+}
+
+// HostAllowed проверяет host по списку AllowedHosts.
+// Пустой список разрешает всё. Элемент "*.example.com" разрешает поддомены
+// (но не сам example.com). Порт в host игнорируется, регистр не важен.
+func (c *Config) HostAllowed(host string) bool {
+	if len(c.AllowedHosts) == 0 {
+		return true
+	}
+	for _, pattern := range c.AllowedHosts {
+		if core.MatchHost(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Addr возвращает TCP-адрес по умолчанию, собранный из Port.
 func (c *Config) Addr() string {
 	if c.Port == "" {
 		return ":8080"
@@ -39,31 +207,161 @@ func (c *Config) Addr() string {
 	return ":" + c.Port
 }
 
+// ErrNotFound — задача (или файл) с указанным ID не найдена.
+var ErrNotFound = errors.New("not found")
+
+// ErrTaskExists — задача с таким ID уже есть (импорт архива задачи, AddTask).
+var ErrTaskExists = errors.New("task already exists")
+
+// ErrNoFailedFiles — CloneTask с onlyFailed: в задаче нет упавших файлов.
+var ErrNoFailedFiles = errors.New("no failed files")
+
 type App struct {
-	Conf       Config
-	wal        *store.WAL
+	Conf Config
+	wal  *store.WAL
+	// mu защищает карту tasks и общее состояние сервиса (waiting, limits, …)
+	// и вместе с блокировкой задачи (core.Task.Mutex) — сами задачи:
+	//   - a.mu на запись — можно читать и менять что угодно, блокировки
+	//     задач не нужны (их держат только владельцы a.mu на чтение);
+	//   - a.mu на чтение и блокировка задачи t — можно читать и менять t
+	//     (так работают воркеры: задачи друг другу не мешают);
+	//   - a.mu на чтение — можно читать неизменяемые поля задач (ID,
+	//     CreatedAt, DestDir, Tenant, …), остальное — под блокировкой задачи.
+	// Карта tasks меняется только под a.mu на запись, а искать в ней и
+	// обходить её можно и без a.mu (taskMap): «живая» задача, найденная так,
+	// — только ссылка, её содержимое читается по правилам выше.
+	// Порядок захвата: a.mu, затем задача; a.mu под блокировкой задачи не берётся.
 	mu         sync.RWMutex
-	tasks      map[string]*core.Task
-	dispatcher *queue.Dispatcher
+	tasks      taskMap // задачи по ID, см. taskmap.go
+	dispatcher queue.Queue
 	workersWg  sync.WaitGroup
 	loader     *downloader.Downloader
+	storage    storage.Storage  // куда пишутся файлы: локальный диск или S3 (dest_dir "s3://…")
+	objects    bool             // объектное хранилище настроено (openObjectStore)
+	blobs      *store.BlobStore // хранилище BLOB_DIR; nil — выключено
+	outbox     *store.Outbox    // исходящие события для EVENTS_URL; nil — публикация выключена
+	leader     *leaderElection  // выбор лидера (LEADER_LEASE); nil — выключен
+	uploads    *uploader        // выгрузка в хранилища Conf.Sinks; nil — не настроены
+	notifier   *notifier        // сводки по завершении задач: почта, Slack, Telegram; nil — не настроены
+	alerts     *alerter         // правила оповещений Conf.Alerts (alerts.go); nil — не заданы
+	diskGuard  *diskGuard       // защита свободного места DiskLowWatermark (diskguard.go); nil — выключена
+	startedAt  time.Time
+
+	lastSuccess atomic.Int64 // UnixNano последней успешной загрузки файла, см. HealthDetails
+	stats       taskStats    // счётчики GET /stats, см. stats.go
+
+	wmu         sync.Mutex
+	workers     []WorkerInfo
+	plan        workPlan      // действующие лимиты расписания (timetable.go); под wmu
+	workersWake chan struct{} // закрывается при смене plan.workers; под wmu
+	workersStop chan struct{} // закрывается в Close: выход ждущих воркеров
+
+	imu      sync.Mutex
+	inflight map[string]context.CancelFunc // "<taskID>/<fileID>" → отмена идущей загрузки; под imu
+	fenced   bool                          // аренда лидера потеряна (fenceLeader): новые загрузки сразу отменяются; под imu
+	logins   loginSessions                 // сессии входа задач (TaskSpec.Login), см. login.go
+	waiting  map[string]struct{}           // задачи, ждущие зависимостей depends_on (depends.go); под mu
+
+	adminLockout *authLockout   // неудачные попытки входа в админку, см. lockout.go
+	jwt          *auth.Verifier // nil — JWT не настроены
+
+	limits        Limits                 // действующие лимиты приёма задач; под mu
+	tenantCreates map[string][]time.Time // арендатор → моменты создания задач за последний час; под mu
+
+	recoverStop chan struct{}   // закрывается для остановки фонового восстановления (stopRecovery)
+	recoverDone chan struct{}   // закрывается runRecovery при выходе
+	recovery    recoveryTracker // ход восстановления, см. Recovery
+	loaded      atomic.Bool     // состояние восстановлено из WAL, см. Loaded
+	stopping    atomic.Bool     // начато завершение (Serve получил сигнал или вызван Close)
+	fatal       chan error      // ошибка восстановления, завершающая Serve
+
+	schedMu      sync.Mutex       // упорядочивает переключение и запись режима выдачи (scheduler.go)
+	drainManual  bool             // drain, включённый оператором (SetDrain) или восстановленный из WAL; под schedMu
+	maint        maintenanceState // окно обслуживания (maintenance.go); под schedMu
+	diskLow      bool             // места в DownloadDir меньше нижней границы (diskguard.go); под schedMu
+	modeRestored bool             // режим выдачи восстановлен (restoreScheduler) и ведётся этим экземпляром; под schedMu
+
+	metrics   *metrics.Registry // GET /metrics, см. metrics.go
+	staleJobs metrics.Counter   // задания, отброшенные воркером как устаревшие
+
+	watchStop chan struct{} // закрывается для остановки watchLoop
+	watchDone chan struct{} // закрывается watchLoop при выходе
+
+	ingestStop context.CancelFunc // останавливает ingestLoop
+	ingestDone chan struct{}      // закрывается ingestLoop при выходе
+	ingest     ingestMetrics
+
+	eventsStop context.CancelFunc // останавливает eventsLoop
+	eventsDone chan struct{}      // закрывается eventsLoop при выходе
+	events     eventsMetrics
+
+	scheduleStop chan struct{} // закрывается для остановки scheduleLoop
+	scheduleDone chan struct{} // закрывается scheduleLoop при выходе
+
+	expiryWake chan struct{} // будит expiryLoop (wakeExpiry)
+	expiryStop chan struct{} // закрывается для остановки expiryLoop
+	expiryDone chan struct{} // закрывается expiryLoop при выходе
+
+	partialWake    chan struct{}   // будит partialRetryLoop (wakePartialRetry)
+	partialStop    chan struct{}   // закрывается для остановки partialRetryLoop
+	partialDone    chan struct{}   // закрывается partialRetryLoop при выходе
+	partialRetries metrics.Counter // автоповторы PARTIAL-задач (partialretry.go)
+
+	trashWake chan struct{} // будит trashLoop (wakeTrash); nil — корзина выключена
+	trashStop chan struct{} // закрывается для остановки trashLoop
+	trashDone chan struct{} // закрывается trashLoop при выходе
+
+	usage     *store.UsageLedger // учёт потребления по арендаторам и ключам API (usage.go)
+	usageStop chan struct{}      // закрывается для остановки usageLoop
+	usageDone chan struct{}      // закрывается usageLoop при выходе
 }
 
 // New инициализирует приложение с заданной конфигурацией.
 //
 // Побочные эффекты:
+//   - Проверяет конфигурацию (Config.Validate) и возвращает все проблемы разом.
 //   - Создаёт каталоги conf.DataDir и conf.DownloadDir (0755).
-//   - Открывает WAL в conf.DataDir.
-//   - Восстанавливает незавершённые задачи из WAL (recoverFromWAL).
-//   - Настраивает диспетчер очереди и HTTP-загрузчик.
-//   - Запускает не менее одного фонового воркера (conf.Workers, минимум 1).
+//   - Открывает WAL в conf.DataDir и, если задан conf.BlobDir, хранилище
+//     файлов по содержимому (store.BlobStore, см. blobs.go); если задан
+//     conf.EventsURL — исходящую очередь событий (store.Outbox, см. outbox.go).
+//   - Включает фоновую запись WAL (conf.WALAsyncQueue > 0, store.WAL.StartAsync):
+//     воркеры не ждут диска на каждой смене состояния файла.
+//   - Настраивает очередь заданий (в памяти или общую в Redis, если задан
+//     conf.QueueURL — queue.RedisQueue) и HTTP-загрузчик, пишущий на диск
+//     или, для задач с dest_dir "s3://…", в S3 (storage.New, см. storage.go).
+//   - Запускает не менее одного фонового воркера (conf.Workers, минимум 1;
+//     с расписанием conf.Schedule — столько, сколько нужно самому большому
+//     окну, лишние ждут своего окна, см. timetable.go) и, если настроены
+//     conf.Sinks, исполнителей выгрузки (sinks.go), а при заданных conf.Alerts —
+//     проверку правил оповещений (alerts.go), при conf.DiskLowWatermark —
+//     защиту свободного места (diskguard.go).
+//   - С conf.LeaderLease — сначала ждёт аренду лидера (startLeader, leader.go):
+//     резервный экземпляр не читает WAL и не забирает задания, пока аренду
+//     держит другой.
+//   - Запускает восстановление в фоне (startRecovery, recovery.go): задачи
+//     читаются из WAL (recoverFromWAL) с отчётом о ходе (App.Recovery),
+//     затем восстанавливается режим выдачи — drain и паузы хостов
+//     (restoreScheduler), запускается опрос WatchDir (если задан, см. watch.go)
+//     приём задач из брокера (IngestURL, см. ingest.go) и публикация
+//     событий (EventsURL, см. outbox.go),
+//     сервис объявляется готовым (Ready) и файлы ставятся в очередь вперемешку
+//     по задачам и хостам.
 //
-// Возвращает готовый *App (не забудьте вызвать Close())
-// или ошибку при создании каталогов, открытии WAL либо восстановлении состояния.
+// Не ждёт восстановления: возвращает *App сразу (не забудьте вызвать Close()),
+// чтобы HTTP-сервер поднялся и отвечал на пробы, пока читается большой журнал.
+// Ошибки — валидации конфигурации, создания каталогов, открытия WAL,
+// исходящей очереди событий и подключения к очереди QueueURL; ошибку
+// чтения журнала получает Serve.
 // Поля конфигурации используются так:
-//   - ClientTimeout, Retries, HostConcurrency — параметры загрузчика;
-//   - Workers — число фоновых воркеров (min=1).
+//   - ClientTimeout, Retries, HostConcurrency, BandwidthLimit, CopyBufferSize,
+//     Preallocate, BypassPageCache, MaxInflightBytes, QuarantineDir, PartDir
+//     (при PART_LOCATION=global), FinalizeSync и ключи
+//     SignatureKeyrings (downloader.LoadKeyring) — параметры загрузчика;
+//   - Workers — число фоновых воркеров (min=1), Schedule — его смена по времени суток.
 func New(conf Config) (*App, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
 	if err := os.MkdirAll(conf.DataDir, 0o755); err != nil {
 		return nil, err
 	}
@@ -71,306 +369,1091 @@ func New(conf Config) (*App, error) {
 		return nil, err
 	}
 
+	uploads, err := openSinks(conf.Sinks)
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := openNotifier(&conf)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := openObjectStore(&conf)
+	if err != nil {
+		return nil, err
+	}
+	files := storage.New(objects)
+	keyring, err := downloader.LoadKeyring(conf.SignatureKeyrings)
+	if err != nil {
+		return nil, err
+	}
 	wal, err := store.OpenWAL(conf.DataDir)
 	if err != nil {
 		return nil, err
 	}
+	var blobs *store.BlobStore
+	if conf.BlobDir != "" {
+		if blobs, err = store.OpenBlobStore(conf.BlobDir); err != nil {
+			wal.Close()
+			return nil, err
+		}
+	}
+	var outbox *store.Outbox
+	if conf.EventsURL != "" {
+		if outbox, err = store.OpenOutbox(conf.DataDir); err != nil {
+			wal.Close()
+			return nil, err
+		}
+	}
+	var q queue.Queue = queue.NewDispatcher(10_000, 1024)
+	if conf.QueueURL != "" {
+		// у общей очереди буфер воркеров мал: забранное наперёд не достанется другим экземплярам
+		if q, err = queue.NewRedisQueue(conf.QueueURL, 10_000, max(1, conf.Workers)); err != nil {
+			if outbox != nil {
+				outbox.Close()
+			}
+			wal.Close()
+			return nil, err
+		}
+	}
 
 	a := &App{
 		Conf:       conf,
 		wal:        wal,
-		tasks:      make(map[string]*core.Task, 128),
-		dispatcher: queue.NewDispatcher(10_000, 1024),
+		blobs:      blobs,
+		outbox:     outbox,
+		uploads:    uploads,
+		notifier:   notifier,
+		alerts:     newAlerter(conf.Alerts),
+		diskGuard:  newDiskGuard(&conf),
+		dispatcher: q,
 		loader: downloader.NewDownloader(downloader.Options{
 			ClientTimeout:   conf.ClientTimeout,
 			Retries:         conf.Retries,
 			HostConcurrency: conf.HostConcurrency,
+			HostLimits:      conf.HostLimits,
+			HostProfiles:    conf.DownloaderProfiles(),
+			AllowHost:       conf.HostAllowed,
+			Storage:         files,
+			Bandwidth:       int64(conf.BandwidthLimit),
+			WriteRate:       int64(conf.WriteRateLimit),
+			BufferSize:      int(conf.CopyBufferSize),
+			Preallocate:     conf.Preallocate,
+			BypassCache:     conf.BypassPageCache,
+
+			MaxInflightBytes: int64(conf.MaxInflightBytes),
+			QuarantineDir:    conf.QuarantineDir,
+			PartDir:          globalPartDir(&conf),
+			FinalizeSync:     conf.FinalizeSync,
+			Keyring:          keyring,
 		}),
+		storage:   files,
+		objects:   objects != nil,
+		startedAt: time.Now().UTC(),
+		workers:   make([]WorkerInfo, conf.MaxWorkers()),
+		inflight:  make(map[string]context.CancelFunc),
+		waiting:   make(map[string]struct{}),
+
+		adminLockout: newAuthLockout(conf.AdminLockoutAttempts, conf.AdminLockoutDuration),
+
+		limits:        conf.Limits(),
+		tenantCreates: make(map[string][]time.Time),
+
+		usage: store.OpenUsageLedger(conf.DataDir),
+
+		fatal: make(chan error, 1),
 	}
-	if err := a.recoverFromWAL(); err != nil {
-		return nil, err
+	if jc := conf.JWTConfig(); jc.Enabled() {
+		a.jwt = auth.NewVerifier(jc)
 	}
+	if conf.LeaderLease > 0 {
+		if a.leader, err = newLeaderElection(conf.QueueURL, conf.LeaderLease); err != nil {
+			a.dispatcher.Close()
+			if outbox != nil {
+				outbox.Close()
+			}
+			wal.Close()
+			return nil, err
+		}
+	}
+	a.registerMetrics()
+	for i := range a.workers {
+		a.workers[i].Index = i
+	}
+	a.wal.StartAsync(conf.WALAsyncQueue)
 
-	for i := 0; i < max(1, conf.Workers); i++ {
+	a.workersWake, a.workersStop = make(chan struct{}), make(chan struct{})
+	a.applyPlan(conf.planAt(time.Now()))
+	a.startSchedule()
+	a.startExpiry()
+	a.startPartialRetry()
+	a.startTrash()
+	for i := range a.workers {
 		a.workersWg.Add(1)
 		go a.workerLoop(i)
 	}
+	a.startUploads()
+	a.startNotify()
+	a.startAlerts()
+	a.startDiskGuard()
+	if a.leader != nil {
+		a.startLeader()
+	} else {
+		a.startRecovery()
+	}
 	return a, nil
 }
 
+// Ready сообщает, готов ли сервис принимать работу: состояние восстановлено
+// из WAL (Loaded) и не начато завершение (Serve отмечает его при получении сигнала).
+func (a *App) Ready() bool { return a.loaded.Load() && !a.stopping.Load() }
+
 // Close выполняет корректное завершение приложения.
-// Останавливает диспетчер (закрывает очередь), дожидается завершения
-// всех воркеров и закрывает WAL. Блокирует до полного завершения.
+// Останавливает расписание (timetable.go), проверку правил оповещений (alerts.go), защиту свободного места (diskguard.go), сроки задач (expiry.go), автоповтор PARTIAL-задач (partialretry.go), удаление из корзины (trash.go), опрос WatchDir, приём задач из брокера, публикацию событий и диспетчер
+// (закрывает очередь), дожидается завершения всех воркеров, прерывает выгрузку
+// в хранилища (невыгруженное продолжится после перезапуска) и закрывает исходящую
+// очередь событий, сбрасывает учёт потребления (usage.go) и закрывает WAL, затем снимает аренду лидера (LEADER_LEASE). Блокирует до полного завершения.
 // Возвращает ошибку только от закрытия WAL. Обычно вызывается через defer.
 func (a *App) Close() error {
+	a.stopping.Store(true)
+	a.haltLeader()   // до stopRecovery: лидер больше не запустит восстановление
+	a.stopRecovery() // до stopWatcher: восстановление само запускает опрос WatchDir и приём из брокера
+	a.stopWatcher()
+	a.stopIngest()
+	a.stopEvents()
+	a.stopSchedule()
+	a.stopAlerts()    // до stopNotify: проверка правил ставит оповещения в очередь
+	a.stopDiskGuard() // до stopNotify: тоже ставит оповещения
+	a.stopExpiry()
+	a.stopPartialRetry()
+	a.stopTrash()
 	a.dispatcher.Close()
+	select {
+	case <-a.workersStop: // Close уже вызывали
+	default:
+		close(a.workersStop) // воркерам вне расписания не дождаться OutChan
+	}
 	a.workersWg.Wait()
-	return a.wal.Close()
+	a.stopUploads() // после воркеров: они ставят файлы в очередь выгрузки
+	a.stopNotify()  // после воркеров: они завершают задачи
+	a.stopUsage()   // после воркеров: последний сброс учёта с их попытками
+	if a.outbox != nil {
+		_ = a.outbox.Close() // события уже сброшены в файл при Append
+	}
+	err := a.wal.Close()
+	a.stopLeader() // аренда держится, пока журнал не закрыт
+	return err
 }
 
-// Управление «дренажем» очереди (пауза/возобновление выдачи задач).
-func (a *App) SetDrain(on bool) { a.dispatcher.Drain(on) }
-func (a *App) IsDrain() bool    { return a.dispatcher.IsDrain() }
-
 // recoverFromWAL восстанавливает состояние задач после перезапуска.
 //
 // Делает следующее:
-//   - читает сохранённые задачи из WAL;
+//   - читает сохранённые задачи из WAL, сообщая ход чтения (recoveryProgress);
 //   - все файлы со статусом Running помечает как Pending
-//     (сброс ошибки и временных меток);
-//   - пересчитывает статус задачи (RecomputeStatus) и кладёт её в a.tasks;
-//   - повторно ставит в очередь все Pending-файлы.
+//     (Task.ResetInterrupted: сброс ошибки и временных меток, RecomputeStatus)
+//     и запоминает: в очередь они встанут первыми (Job.Resume);
+//   - поднимает версии задач до времени старта (core.Task.SeedVersion),
+//     чтобы они не повторили версии, уже выданные клиентам до перезапуска;
+//   - незавершённым задачам пишет в историю событие EventRecovered;
+//   - кладёт задачу в a.tasks (задачу в корзине — с пробуждением trashLoop);
+//   - у задач с наступившим за время остановки сроком (ExpiresAt) переводит
+//     не начатые файлы в Expired (expireLocked);
+//   - отложенные автоповторы (NextAttemptAt) сразу откладывает в очереди
+//     до их момента;
+//   - задачи, ждущие зависимостей (Waiting), возвращает в a.waiting, а если
+//     зависимости выполнились до перезапуска — снимает с ожидания;
+//   - остальные Pending-файлы возвращает вперемешку по задачам и хостам
+//     (interleaveRecovered) — их ставит в очередь runRecovery.
 //
-// Вызывать до старта воркеров. Возвращает ошибку, если чтение WAL не удалось.
-func (a *App) recoverFromWAL() error {
-	tasks, err := a.wal.RecoverTasks()
+// Задачи раскладываются под a.mu: HTTP-сервер и воркеры уже работают.
+// Возвращает ошибку, если чтение WAL не удалось или прервано (errRecoveryStopped).
+func (a *App) recoverFromWAL() ([]recoveredFile, error) {
+	tasks, err := a.wal.RecoverTasksProgress(a.recoveryProgress)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	a.recovery.setTasks(len(tasks))
+	seed := uint64(time.Now().UnixMicro())
+	interrupted := make(map[*core.FileItem]bool)
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	for _, t := range tasks {
 		for _, f := range t.Files {
 			if f.State == core.FileRunning {
-				f.State = core.FilePending
-				f.Error = ""
-				f.StartedAt = nil
-				f.FinishedAt = nil
+				interrupted[f] = true
 			}
 		}
-		t.RecomputeStatus()
-		a.tasks[t.ID] = t
-		for i, f := range t.Files {
-			if f.State == core.FilePending {
-				a.dispatcher.InChan() <- queue.Job{TaskID: t.ID, FileIndex: i, Host: f.Host}
+		n := t.ResetInterrupted()
+		t.SeedVersion(seed)
+		if !t.Status.Terminal() {
+			ev := t.AddEvent(core.TaskEvent{
+				Type:    core.EventRecovered,
+				Status:  string(t.Status),
+				Message: fmt.Sprintf("%d interrupted file(s) requeued", n),
+			})
+			a.recordEvents(t, ev)
+		}
+		t.InitLock()
+		a.tasks.put(t)
+		a.stats.added(t)
+		a.retainBlobsLocked(t)
+		if t.TrashedAt != nil {
+			a.wakeTrash() // срок в корзине мог истечь, пока сервис был остановлен
+		}
+		if ch := a.expireLocked(t, time.Now().UTC()); ch.files > 0 {
+			// срок наступил, пока сервис был остановлен
+			_ = a.wal.AppendTask(ch.snap)
+			a.recordEvents(t, ch.events...)
+		}
+		for _, f := range t.Files {
+			if f.State == core.FilePending && f.NextAttemptAt != nil {
+				// пауза перед автоповтором переживает перезапуск
+				a.dispatcher.Schedule(retryJobFor(t, f), *f.NextAttemptAt)
 			}
 		}
 	}
-	return nil
+	now := time.Now().UTC()
+	for id, t := range tasks {
+		if !t.Waiting {
+			continue
+		}
+		if len(a.holdLocked(t)) > 0 {
+			delete(tasks, id) // файлы встанут в очередь в releaseDependents
+			continue
+		}
+		// зависимость завершилась или удалена до перезапуска
+		ch := a.releaseLocked(t, now)
+		_ = a.wal.AppendTask(ch.snap)
+		a.recordEvents(t, ch.events...)
+	}
+	return interleaveRecovered(tasks, interrupted), nil
 }
 
 // AddTask регистрирует новую задачу, отражает её в WAL
 // и ставит в очередь все файлы со статусом Pending.
 //
 // Шаги:
-//  1. Потокобезопасно добавляет t в карту a.tasks.
-//  2. Пытается дописать задачу в WAL (ошибка намеренно игнорируется).
-//  3. Если t.DestDir относительный — нормализует его через filepath.Clean.
-//  4. Для каждого Pending-файла публикует job в диспетчер (в канал InChan).
+//  1. Проверяет, что ID задачи свободен: задача с тем же ID не заменяется,
+//     а возвращается ErrTaskExists.
+//  2. Пишет в историю задачи событие EventCreated и потокобезопасно добавляет t в карту a.tasks.
+//  3. Пытается дописать задачу и событие в WAL (ошибка намеренно игнорируется).
+//  4. Если t.DestDir относительный — нормализует его через filepath.Clean.
+//  5. Для каждого Pending-файла публикует job в диспетчер (в канал InChan).
 //
 // Запись в очередь может блокировать при заполненном канале.
-// Функция не возвращает ошибку.
-func (a *App) AddTask(t *core.Task) {
+func (a *App) AddTask(t *core.Task) error { return a.addTask(t, false) }
+
+// addTask — AddTask с проверкой лимитов (admit=true, см. admitLocked) в той же
+// критической секции, что и регистрация задачи. При превышении лимита задача
+// не регистрируется и возвращается *LimitError.
+func (a *App) addTask(t *core.Task, admit bool) error {
+	msg := fmt.Sprintf("%d file(s)", len(t.Files))
+	if t.ClonedFrom != "" {
+		msg += ", cloned from " + t.ClonedFrom
+	}
+	return a.registerTask(t, admit, core.TaskEvent{Type: core.EventCreated, Status: string(t.Status), Message: msg})
+}
+
+// registerTask — общая часть addTask и ImportTask: регистрирует задачу t с
+// событием ev в истории, пишет её в WAL (вместе со всей историей — у импортированной
+// задачи она непустая) и ставит Pending-файлы в очередь.
+// ErrTaskExists — задача с таким ID уже зарегистрирована; задача, которая не
+// уместится в запись журнала, — store.ErrRecordTooLarge (после перезапуска её
+// было бы не восстановить).
+func (a *App) registerTask(t *core.Task, admit bool, ev core.TaskEvent) error {
+	if err := store.CheckTaskRecord(t); err != nil {
+		return err
+	}
 	a.mu.Lock()
-	a.tasks[t.ID] = t
+	if _, dup := a.tasks.get(t.ID); dup {
+		a.mu.Unlock()
+		return ErrTaskExists
+	}
+	if admit {
+		if err := a.admitLocked(t, time.Now()); err != nil {
+			a.mu.Unlock()
+			return err
+		}
+	}
+	if unmet := a.holdLocked(t); len(unmet) > 0 {
+		ev.Message += ", waiting for " + strings.Join(unmet, ", ")
+	}
+	t.AddEvent(ev)
+	events := t.History().Events
+	t.InitLock()
+	a.tasks.put(t)
+	a.stats.added(t)
+	var jobs []queue.Job
+	if !t.Waiting { // иначе файлы встанут в очередь, когда выполнятся зависимости (releaseDependents)
+		jobs = queueJobsLocked(t)
+	}
 	a.mu.Unlock()
 
+	a.recordUsage(t.Tenant, t.APIKey, time.Now(), store.UsageCounters{Tasks: 1})
 	_ = a.wal.AppendTask(t)
+	a.recordEvents(t, events...)
 
-	if !filepath.IsAbs(t.DestDir) {
+	if !filepath.IsAbs(t.DestDir) && !storage.IsObject(t.DestDir) {
 		t.DestDir = filepath.Clean(t.DestDir)
 	}
 
-	for i, f := range t.Files {
-		if f.State == core.FilePending {
-			a.dispatcher.InChan() <- queue.Job{TaskID: t.ID, FileIndex: i, Host: f.Host}
+	for _, j := range jobs {
+		a.dispatcher.InChan() <- j
+	}
+	if t.ExpiresAt != nil {
+		a.wakeExpiry()
+	}
+	return nil
+}
+
+// requestTag — суффикс строки лога с X-Request-ID задачи (" [request_id=…]");
+// у задач без него (WATCH_DIR, брокер) — пусто.
+func requestTag(requestID string) string {
+	if requestID == "" {
+		return ""
+	}
+	return " [request_id=" + requestID + "]"
+}
+
+// jobFor собирает задание очереди для файла f задачи t
+// (приоритет берётся из задачи на момент постановки).
+func jobFor(t *core.Task, f *core.FileItem) queue.Job {
+	return queue.Job{TaskID: t.ID, FileID: f.ID, Host: f.Host, Priority: t.Priority}
+}
+
+// retryJobFor — jobFor для повтора упавшего файла (автоповтор или ручной retry):
+// такое задание встаёт в голову своего приоритета, а не в хвост backlog.
+func retryJobFor(t *core.Task, f *core.FileItem) queue.Job {
+	j := jobFor(t, f)
+	j.Retry = true
+	return j
+}
+
+// CreateTask собирает задачу по описанию spec и регистрирует её (AddTask).
+//
+// Правила, общие для всех способов создания задач (POST /tasks, импорт, …):
+//   - MaxAttempts файлов = max_attempts профиля хоста (HostProfile) или Conf.Retries
+//     (если не задан у ссылки);
+//   - spec.DestDir (если задан) кладётся под Conf.DownloadDir, иначе — Conf.DownloadDir/<taskID>;
+//     "s3://bucket/prefix" — файлы пишутся прямо в объектное хранилище (без выгрузки в sink);
+//   - хост каждой ссылки (её адрес connect_to и ссылка на подпись), а также URL
+//     входа (spec.Login) должны проходить Conf.HostAllowed;
+//   - ссылки с подписью (signature) — только при заданных SignatureKeyrings;
+//   - сводка на почту (notify_email) — только при заданном SMTPURL;
+//   - задача арендатора spec.Tenant укладывается в лимиты (Limits);
+//   - повторы URL — по политике spec.Duplicates или DUPLICATE_POLICY (applyDuplicates).
+//
+// Возвращает созданную задачу, ошибку валидации или *LimitError
+// (в обоих случаях задача не регистрируется).
+func (a *App) CreateTask(spec core.TaskSpec) (*core.Task, error) {
+	task, err := a.buildTask(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.applyDuplicates(task, a.duplicatePolicy(spec.Duplicates), time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if err := a.addNewTask(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// buildTask — общая часть CreateTask/CloneTask: собирает задачу по spec
+// с новым ID (newTaskID) и применяет правила сервиса, но не регистрирует её.
+func (a *App) buildTask(spec core.TaskSpec) (*core.Task, error) {
+	spec.Links = a.profileAttempts(spec.Links)
+	task, err := core.NewTaskFromSpec(spec, a.Conf.Retries)
+	if err != nil {
+		return nil, err
+	}
+	task.ID = a.newTaskID()
+	task.Tenant, task.APIKey, task.RequestID = spec.Tenant, spec.APIKey, spec.RequestID
+	a.mu.RLock()
+	err = a.checkDependsLocked(task)
+	a.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range task.Files {
+		if !a.Conf.HostAllowed(f.Host) {
+			return nil, i18n.New(i18n.CodeHostNotAllowed, f.Host)
+		}
+		if ip := core.ConnectHost(f.ConnectTo); ip != "" && !a.Conf.HostAllowed(ip) {
+			return nil, i18n.New(i18n.CodeHostNotAllowed, ip)
+		}
+		if f.Signature != "" {
+			if len(a.Conf.SignatureKeyrings) == 0 {
+				return nil, i18n.New(i18n.CodeSignatureNoKeys)
+			}
+			if u, _ := url.Parse(f.Signature); !a.Conf.HostAllowed(u.Host) {
+				return nil, i18n.New(i18n.CodeHostNotAllowed, u.Host)
+			}
+		}
+	}
+	if task.Login != nil && !a.Conf.HostAllowed(task.Login.Host()) {
+		return nil, i18n.New(i18n.CodeHostNotAllowed, task.Login.Host())
+	}
+	if task.Sink != "" && !a.HasSink(task.Sink) {
+		return nil, i18n.New(i18n.CodeSinkUnknown, task.Sink)
+	}
+	if task.NotifyEmail != "" && !a.mailConfigured() {
+		return nil, i18n.New(i18n.CodeNotifyNoSMTP)
+	}
+	for _, name := range task.Notify {
+		if !a.HasNotifier(name) {
+			return nil, i18n.New(i18n.CodeNotifierUnknown, name)
 		}
 	}
+	switch {
+	case storage.IsObject(task.DestDir):
+		if task.DestDir, err = a.objectDestDir(task.DestDir); err != nil {
+			return nil, err
+		}
+		if task.Sink != "" {
+			return nil, i18n.New(i18n.CodeSinkObjectDest)
+		}
+	case task.DestDir == "":
+		task.DestDir = filepath.Join(a.Conf.DownloadDir, task.ID)
+	default:
+		task.DestDir = filepath.Join(a.Conf.DownloadDir, task.DestDir)
+	}
+	return task, nil
+}
+
+// CloneTask создаёт новую задачу из ссылок задачи id (повторный запуск пакета
+// без восстановления исходного запроса).
+//
+// Переносятся метка, теги, приоритет, раскладка, хранилище выгрузки, последовательный режим, вход на сайт-источник,
+// политика автоповтора PARTIAL (без счётчика сделанных повторов)
+// и параметры ссылок (имя файла, dest_subpath, checksum, headers, host_header, connect_to, signature, max_attempts); счётчики и состояния — нет.
+// Срок (ExpiresAt) тоже не переносится: клон — новый запрос, срок исходного обычно уже прошёл.
+// Клон качает в тот же каталог, что и исходная задача (существующие файлы
+// не перезаписываются, см. downloader.UniquePath), и хранит ID источника в ClonedFrom.
+// onlyFailed=true берёт только Failed-файлы; если таких нет — ErrNoFailedFiles.
+// ErrNotFound — задачи нет. Клон принадлежит арендатору исходной задачи
+// и проходит его лимиты (*LimitError); его RequestID — requestID запроса
+// на клонирование, а не исходной задачи.
+func (a *App) CloneTask(id string, onlyFailed bool, requestID string) (*core.Task, error) {
+	a.mu.RLock()
+	src, ok := a.tasks.get(id)
+	if !ok {
+		a.mu.RUnlock()
+		return nil, ErrNotFound
+	}
+	src.Mutex().Lock()
+	spec := core.TaskSpec{
+		Label:    src.Label,
+		Layout:   src.Layout,
+		Tags:     append([]string(nil), src.Tags...),
+		Priority: src.Priority,
+		Sink:     src.Sink,
+		Tenant:   src.Tenant,
+		APIKey:   src.APIKey,
+
+		Sequential:  src.Sequential,
+		Login:       src.Login,
+		NotifyEmail: src.NotifyEmail,
+		Notify:      append([]string(nil), src.Notify...),
+
+		RequestID: requestID,
+	}
+	if src.PartialRetry != nil {
+		pr := src.PartialRetry.PartialRetrySpec // счётчик повторов у клона свой
+		spec.PartialRetry = &pr
+	}
+	if storage.IsObject(src.DestDir) {
+		spec.DestDir = src.DestDir
+	} else if rel, err := filepath.Rel(a.Conf.DownloadDir, src.DestDir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		spec.DestDir = rel
+	}
+	for _, f := range src.Files {
+		if onlyFailed && f.State != core.FileFailed {
+			continue
+		}
+		spec.Links = append(spec.Links, core.LinkSpec{
+			URL:         f.URL,
+			Filename:    f.Filename,
+			DestSubpath: f.DestSubpath,
+			Checksum:    f.Checksum,
+			Headers:     f.Headers,
+			HostHeader:  f.HostHeader,
+			ConnectTo:   f.ConnectTo,
+			Signature:   f.Signature,
+			Range:       f.Range,
+			MaxAttempts: f.MaxAttempts,
+		})
+	}
+	src.Mutex().Unlock()
+	a.mu.RUnlock()
+
+	if len(spec.Links) == 0 {
+		return nil, ErrNoFailedFiles
+	}
+	task, err := a.buildTask(spec)
+	if err != nil {
+		return nil, err
+	}
+	task.ClonedFrom = id
+	if err := a.addNewTask(task); err != nil {
+		return nil, err
+	}
+	return task, nil
 }
 
 // GetTask возвращает задачу по её ID из памяти.
 // Второе значение (ok) показывает, найдена ли задача.
-// Потокобезопасно читает карту задач под RLock.
-// ВАЖНО: возвращается указатель на «живой» объект.
+// Карта читается без a.mu (см. taskMap): вызов не ждёт писателей и не
+// задерживает воркеров.
+// ВАЖНО: возвращается указатель на «живой» объект; для сериализации — TaskSnapshot.
 func (a *App) GetTask(id string) (*core.Task, bool) {
+	return a.tasks.get(id)
+}
+
+// TaskTenant возвращает арендатора задачи id (core.Task.Tenant; пусто —
+// задача создана без арендатора). Арендатор задаётся при создании и не
+// меняется, поэтому читается без блокировки задачи.
+func (a *App) TaskTenant(id string) (string, bool) {
+	t, ok := a.tasks.get(id)
+	if !ok {
+		return "", false
+	}
+	return t.Tenant, true
+}
+
+// TaskSnapshot возвращает копию задачи id, снятую под её блокировкой
+// (core.Task.Clone): её можно сериализовать, не конкурируя с воркерами за
+// «живой» объект. Воркеры других задач при этом не ждут.
+func (a *App) TaskSnapshot(id string) (*core.Task, bool) {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
-	t, ok := a.tasks[id]
-	return t, ok
+	t, ok := a.tasks.get(id)
+	if !ok {
+		return nil, false
+	}
+	t.Mutex().Lock()
+	defer t.Mutex().Unlock()
+	return t.Clone(), true
+}
+
+// FileSnapshot возвращает копию файла fileID задачи taskID, снятую под блокировкой задачи,
+// и версию задачи на тот же момент (для ETag). ok=false — нет такой задачи или файла.
+func (a *App) FileSnapshot(taskID, fileID string) (*core.FileItem, uint64, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	t, ok := a.tasks.get(taskID)
+	if !ok {
+		return nil, 0, false
+	}
+	t.Mutex().Lock()
+	defer t.Mutex().Unlock()
+	f, _ := t.FileByID(fileID)
+	if f == nil {
+		return nil, 0, false
+	}
+	return f.Clone(), t.Version, true
+}
+
+// TaskVersion возвращает текущую версию задачи id (core.Task.Version) —
+// дешёвая проверка «изменилась ли задача» без снятия снимка.
+func (a *App) TaskVersion(id string) (uint64, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	t, ok := a.tasks.get(id)
+	if !ok {
+		return 0, false
+	}
+	t.Mutex().Lock()
+	defer t.Mutex().Unlock()
+	return t.Version, true
+}
+
+// TaskHistory возвращает копию истории событий задачи id (см. core.TaskEvent).
+func (a *App) TaskHistory(id string) (core.TaskHistory, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	t, ok := a.tasks.get(id)
+	if !ok {
+		return core.TaskHistory{}, false
+	}
+	t.Mutex().Lock()
+	defer t.Mutex().Unlock()
+	return t.History(), true
 }
 
 // ListTasks возвращает срез всех задач из памяти.
-// Чтение выполняется под RLock. Порядок не гарантируется (итерация по map).
-// Возвращаются указатели на «живые» объекты.
+// Карта обходится без a.mu, по шардам (см. taskMap). Порядок не гарантируется.
+// Возвращаются указатели на «живые» объекты; для сериализации — TaskSnapshots.
 func (a *App) ListTasks() []*core.Task {
+	return a.tasks.all()
+}
+
+// QueryTasks возвращает задачи из памяти, прошедшие q (GET /tasks?status=…&tag=…),
+// в порядке store.SortTasks — по времени создания, стабильно для постраничного
+// вывода. Пустой q отбирает все задачи. Как и ListTasks, возвращает «живые» объекты
+// (для сериализации — TaskSnapshots).
+//
+// Тот же отбор по файлу журнала, без загрузки задач в память, — store.WAL.QueryTasks.
+func (a *App) QueryTasks(q store.TaskQuery) []*core.Task {
 	a.mu.RLock()
-	defer a.mu.RUnlock()
-	out := make([]*core.Task, 0, len(a.tasks))
-	for _, t := range a.tasks {
-		out = append(out, t)
+	out := make([]*core.Task, 0, a.tasks.len())
+	for _, t := range a.tasks.all() {
+		t.Mutex().Lock()
+		ok := q.Match(t)
+		t.Mutex().Unlock()
+		if ok {
+			out = append(out, t)
+		}
 	}
+	a.mu.RUnlock()
+	store.SortTasks(out)
 	return out
 }
 
+// TaskSnapshots возвращает копии задач tasks (см. TaskSnapshot) в том же
+// порядке — для сериализации списка, найденного ListTasks или QueryTasks.
+// Каждая копия снята целиком под блокировкой своей задачи, так что в ответ
+// не попадёт задача, изменённая воркером посреди кодирования. Удалённые за
+// это время задачи пропускаются.
+func (a *App) TaskSnapshots(tasks []*core.Task) []*core.Task {
+	out := make([]*core.Task, 0, len(tasks))
+	a.mu.RLock()
+	for _, t := range tasks {
+		if !a.tasks.current(t) {
+			continue
+		}
+		t.Mutex().Lock()
+		out = append(out, t.Clone())
+		t.Mutex().Unlock()
+	}
+	a.mu.RUnlock()
+	return out
+}
+
+// RetryFailed перезапускает упавшие файлы задачи id: каждый Failed-файл
+// переходит в Pending с обнулённым счётчиком попыток (получает полный
+// бюджет MaxAttempts заново), состояние фиксируется в WAL, файлы ставятся в очередь.
+// Возвращает число перезапущенных файлов; ok=false — задача не найдена.
+func (a *App) RetryFailed(id string) (n int, ok bool) {
+	a.mu.Lock()
+	t, ok := a.tasks.get(id)
+	if !ok {
+		a.mu.Unlock()
+		return 0, false
+	}
+	ch := retryFailedLocked(t, "", time.Now().UTC())
+	a.mu.Unlock()
+
+	a.commit(ch)
+	return ch.files, true
+}
+
+// taskChange — изменение одной задачи, собранное под a.mu: снимок для WAL,
+// события истории и задания для очереди. Применяется через App.commit уже без блокировки.
+type taskChange struct {
+	snap   *core.Task
+	events []core.TaskEvent
+	jobs   []queue.Job
+	files  int // сколько файлов затронуто
+}
+
+// commit фиксирует изменение в WAL (задача и события) и ставит задания в очередь.
+// nil или пустое изменение (files == 0) ничего не делает.
+func (a *App) commit(ch *taskChange) {
+	if ch == nil || ch.files == 0 {
+		return
+	}
+	_ = a.wal.AppendTask(ch.snap)
+	a.recordEvents(ch.snap, ch.events...)
+	for _, j := range ch.jobs {
+		a.dispatcher.InChan() <- j
+	}
+}
+
+// retryFailedLocked возвращает в Pending упавшие файлы задачи t (только с хоста
+// host, если он задан) с обнулённым счётчиком попыток. Вызывать под a.mu.
+func retryFailedLocked(t *core.Task, host string, now time.Time) *taskChange {
+	return requeueFailedLocked(t, host, "", now)
+}
+
+// requeueFailedLocked — retryFailedLocked с причиной повтора reason для истории
+// файлов и события задачи (пусто — ручной повтор). Вызывать под a.mu.
+func requeueFailedLocked(t *core.Task, host, reason string, now time.Time) *taskChange {
+	fileReason, msg := "manual retry", "%d failed file(s) requeued"
+	if reason != "" {
+		fileReason, msg = reason, "%d failed file(s) requeued, "+reason
+	}
+	ch := &taskChange{}
+	for _, f := range t.Files {
+		if f.State != core.FileFailed || (host != "" && !strings.EqualFold(f.Host, host)) {
+			continue
+		}
+		_ = f.Transition(core.FilePending, fileReason, now)
+		f.Attempts = 0
+		ch.jobs = append(ch.jobs, retryJobFor(t, f))
+	}
+	ch.files = len(ch.jobs)
+	if ch.files == 0 {
+		return ch
+	}
+	ch.events = append(ch.events, t.AddEvent(core.TaskEvent{
+		At:      now,
+		Type:    core.EventRetry,
+		Message: fmt.Sprintf(msg, ch.files),
+	}))
+	if ev, changed := t.RecomputeStatusEvent(now); changed {
+		ch.events = append(ch.events, ev)
+	}
+	ch.snap = t.Clone()
+	return ch
+}
+
+// PatchTask меняет метаданные задачи id (core.TaskPatch: метка, теги,
+// приоритет, MaxAttempts ждущих файлов) и фиксирует задачу в WAL.
+//
+// При смене приоритета ждущие задания задачи пересортировываются в очереди
+// (queue.Dispatcher.Reprioritize). Возвращает копию обновлённой задачи;
+// ErrNotFound — задачи нет; ошибку валидации — патч некорректен (задача не меняется).
+func (a *App) PatchTask(id string, p core.TaskPatch) (*core.Task, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	t, ok := a.tasks.get(id)
+	if !ok {
+		a.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	reprioritize := p.Priority != nil && *p.Priority != t.Priority
+	n := t.Apply(p)
+	msg := strings.Join(p.Fields(), ", ")
+	if p.MaxAttempts != nil {
+		msg += fmt.Sprintf(" (max_attempts=%d for %d pending file(s))", *p.MaxAttempts, n)
+	}
+	ev := t.AddEvent(core.TaskEvent{Type: core.EventPatched, Message: msg})
+	snap := t.Clone()
+	a.mu.Unlock()
+
+	_ = a.wal.AppendTask(snap)
+	a.recordEvents(snap, ev)
+	if reprioritize {
+		a.dispatcher.Reprioritize(id, snap.Priority)
+	}
+	return snap, nil
+}
+
 // workerLoop — основная петля фонового воркера.
 //
-// Читает задания из dispatcher.OutChan() до закрытия канала.
+// Читает задания из dispatcher.OutChan() до закрытия канала; воркер с номером
+// за пределами числа воркеров по расписанию ждёт своего окна (nextJob).
+// С WORKER_NICE/WORKER_IO_PRIORITY воркер сначала понижает приоритет своего
+// потока ОС (lowerWorkerPriority).
 // Для каждого job:
-//   - Под мьютексом валидирует задачу/индекс файла; если файл Pending —
-//     переводит его в Running, сбрасывает ошибку, ставит StartedAt,
-//     пересчитывает статус; фиксирует состояние в WAL.
-//   - Определяет путь сохранения (t.DestDir или Conf.DownloadDir/<taskID>)
-//     и делает uniquePath, чтобы не перезаписать существующий файл.
-//   - Качает через loader.Fetch с контекстом (ClientTimeout*2).
-//   - Под мьютексом отмечает результат: Done/Failed, BytesDownloaded,
-//     ставит FinishedAt, пересчитывает статус; фиксирует в WAL.
-//   - Если была ошибка и Attempts < MaxAttempts — сбрасывает файл обратно в Pending,
-//     чистит таймстемпы, фиксирует в WAL и повторно публикует job в очередь.
+//   - Под мьютексом находит задачу (нет — bounceJob) и файл по ID (Task.FileByID) и переводит его
+//     в Running (FileItem.Transition; не Pending, срок задачи наступил или задача
+//     в корзине — задание пропускается),
+//     пересчитывает статус; фиксирует состояние файла в WAL (AppendFile).
+//   - Определяет путь сохранения (t.DestDir или Conf.DownloadDir/<taskID>,
+//     плюс DestSubpath файла; на диске или в S3) и делает downloader.UniquePathIn,
+//     чтобы не перезаписать существующий файл.
+//   - У задачи со входом на сайт-источник берёт её сессию (taskSession; при
+//     первой загрузке — выполняет вход), ошибка входа — ошибка попытки.
+//   - Качает через loader.Do с контекстом (ClientTimeout*2), функция отмены которого
+//     лежит в a.inflight (CancelTasks прерывает загрузку); по ходу загрузки
+//     обновляет BytesDownloaded/SizeHint файла и прогресс задачи, а итог каждой
+//     HTTP-попытки дописывает в FileItem.AttemptLog (без записи в WAL).
+//   - С FIX_EXTENSIONS сохраняет файл под расширением, подходящим типу
+//     содержимого (fixExtension), и запоминает тип в FileItem.ContentType.
+//   - Под мьютексом отмечает результат переходом в Done/Failed (BytesDownloaded,
+//     FinishedAt, у скачанного — метаданные ответа источника в FileItem.Response).
+//     Если файл за время загрузки ушёл из Running (переход недопустим) —
+//     результат отбрасывается. Если была ошибка, Attempts < MaxAttempts и срок
+//     задачи (ExpiresAt) не наступил — в той же критической секции возвращает
+//     файл в Pending, чтобы задача не «мигала» конечным статусом FAILED/PARTIAL
+//     между попытками.
+//   - Пересчитывает статус, фиксирует файл в WAL (задаче, дошедшей до COMPLETE/PARTIAL,
+//     пишет опись TASK_MANIFEST — writeManifest) и при ретрае откладывает job
+//     в очереди (Dispatcher.Schedule) на паузу retryDelay — RETRY_BACKOFF·2^(N-1)
+//     после N-й неудачи, не больше RETRY_BACKOFF_MAX; момент повтора виден
+//     в FileItem.NextAttemptAt и переживает перезапуск (recoverFromWAL).
 //
-// Завершение: при закрытии OutChan цикл выходит; workersWg.Done()
+// Завершение: при закрытии OutChan (или workersStop — для ждущего окна) цикл выходит; workersWg.Done()
 // сигнализирует, что воркер завершился. Ошибки записи в WAL игнорируются (best-effort).
 func (a *App) workerLoop(idx int) {
 	defer a.workersWg.Done()
-	for job := range a.dispatcher.OutChan() {
-		a.mu.Lock()
-		t, ok := a.tasks[job.TaskID]
-		if !ok || job.FileIndex < 0 || job.FileIndex >= len(t.Files) {
-			a.mu.Unlock()
-			continue
+	if err := a.lowerWorkerPriority(); err != nil && idx == 0 {
+		log.Printf("Worker: %v", err) // ошибка у всех воркеров одна — пишем её один раз
+	}
+	for {
+		job, ok := a.nextJob(idx)
+		if !ok {
+			return
 		}
-		fi := t.Files[job.FileIndex]
-		if fi.State != core.FilePending {
-			a.mu.Unlock()
+		a.dispatcher.Picked(job)
+		a.mu.RLock()
+		t, ok := a.tasks.get(job.TaskID)
+		if !ok {
+			a.mu.RUnlock()
+			a.bounceJob(job)
 			continue
 		}
+		t.Mutex().Lock()
+		fi, _ := t.FileByID(job.FileID)
 		now := time.Now().UTC()
-		fi.State = core.FileRunning
-		fi.Error = ""
-		fi.StartedAt = &now
-		t.RecomputeStatus()
-		a.mu.Unlock()
+		expired := t.PastExpiry(now)
+		if t.Waiting || t.TrashedAt != nil || fi == nil || expired || (t.Sequential && t.NextSequential() != fi) ||
+			fi.Transition(core.FileRunning, fmt.Sprintf("attempt %d/%d", fi.Attempts+1, fi.MaxAttempts), now) != nil {
+			cancelled := fi != nil && fi.State == core.FileCancelled
+			a.unlockTask(t)
+			a.staleJobs.Inc()
+			if cancelled {
+				a.advanceSequential(t) // отменили файл, ждавший своей очереди
+			}
+			if expired {
+				a.wakeExpiry() // срок задачи наступил: её файлы переведёт в Expired expiryLoop
+			}
+			continue
+		}
+		evs := []core.TaskEvent{t.AddEvent(core.TaskEvent{
+			At:      now,
+			Type:    core.EventFileStarted,
+			FileID:  fi.ID,
+			Status:  string(core.FileRunning),
+			Message: fmt.Sprintf("attempt %d/%d", fi.Attempts+1, fi.MaxAttempts),
+		})}
+		if ev, changed := t.RecomputeStatusEvent(now); changed {
+			evs = append(evs, ev)
+		}
+		snap := fi.Clone()
+		ctx, cancel := context.WithTimeout(context.Background(), a.Conf.ClientTimeout*2)
+		key := inflightKey(t.ID, fi.ID)
+		a.imu.Lock()
+		a.inflight[key] = cancel // CancelTasks прерывает загрузку через эту функцию
+		if a.fenced {
+			cancel() // аренда лидера потеряна (fenceLeader) — загрузку не начинаем
+		}
+		a.imu.Unlock()
+		a.unlockTask(t)
 
-		_ = a.wal.AppendTask(t)
+		a.setWorkerJob(idx, t.ID, fi.ID, fi.URL, now)
+		_ = a.wal.AppendFile(t.ID, snap)
+		a.recordEvents(t, evs...)
 
-		destDir := t.DestDir
-		if destDir == "" {
-			destDir = filepath.Join(a.Conf.DownloadDir, t.ID)
-		}
-		destPath := uniquePath(filepath.Join(destDir, fi.Filename))
+		destPath := downloader.UniquePathIn(ctx, a.storage, storage.Join(a.taskDestDir(t), fi.DestSubpath, fi.Filename))
 
-		ctx, cancel := context.WithTimeout(context.Background(), a.Conf.ClientTimeout*2)
-		written, err := a.loader.Fetch(ctx, fi.URL, destPath)
+		var written, last int64
+		var resp *core.FileResponse
+		jar, err := a.taskSession(ctx, t)
+		if err == nil {
+			written, err = a.loader.Do(ctx, downloader.Request{
+				URL:       fi.URL,
+				DestPath:  destPath,
+				Checksum:  fi.Checksum,
+				Headers:   fi.Headers,
+				Host:      fi.HostHeader,
+				ConnectTo: fi.ConnectTo,
+				Jar:       jar,
+				PartDir:   a.partDir(t),
+				Signature: fi.Signature,
+				Range:     fi.Range,
+				OnSignature: func(c core.SignatureCheck) {
+					a.lockTask(t)
+					fi.SignatureCheck = &c
+					a.unlockTask(t)
+				},
+				OnContentType: a.fixExtension(ctx, t, fi, &destPath),
+				OnProgress: func(n int64) {
+					if n < last { // новая попытка внутри Do: отсчёт с нуля
+						last = 0
+					}
+					a.stats.progress(time.Now(), n-last)
+					last = n
+					a.setWorkerBytes(idx, n)
+					a.lockTask(t)
+					t.AddFileProgress(fi, n)
+					a.unlockTask(t)
+				},
+				OnSize: func(size int64) {
+					a.lockTask(t)
+					t.SetFileSize(fi, size)
+					a.unlockTask(t)
+				},
+				OnResponse: func(r core.FileResponse) { resp = &r },
+				OnAttempt: func(at core.FileAttempt) {
+					a.lockTask(t)
+					fi.AddAttempt(at) // в WAL — вместе с итогом загрузки
+					a.unlockTask(t)
+				},
+			})
+		}
 		cancel()
+		a.clearWorkerJob(idx)
+		var blob string
+		if err == nil {
+			blob = a.ingestBlob(destPath)
+		}
 
-		a.mu.Lock()
+		a.imu.Lock()
+		delete(a.inflight, key)
+		a.imu.Unlock()
+		a.lockTask(t)
+		if !a.tasks.current(t) {
+			// задачу удалили (DeleteTask), пока шла загрузка: результат — сирота
+			a.unlockTask(t)
+			if err == nil {
+				a.removeTaskFile(destPath, blob)
+			}
+			continue
+		}
 		now2 := time.Now().UTC()
+		var terr error
+		if err != nil {
+			terr = fi.Transition(core.FileFailed, err.Error(), now2)
+		} else if terr = fi.Transition(core.FileDone, "", now2); terr == nil {
+			a.lastSuccess.Store(now2.UnixNano())
+			a.stats.downloaded(fi.Host, written, now2.Sub(now))
+			fi.Path = destPath
+			fi.Blob = blob
+			fi.Response = resp
+			if t.Sink != "" {
+				fi.Upload = &core.FileUpload{Sink: t.Sink, State: core.UploadPending}
+			}
+			fi.BytesDownloaded = written
+			if fi.SizeHint <= 0 {
+				fi.SizeHint = written
+			}
+		}
+		if terr != nil {
+			// состояние файла сменили снаружи (например, отмена), пока шла загрузка, —
+			// результат попытки не применяем, скачанное — сирота (если файл по
+			// destPath не успела сохранить другая попытка)
+			orphan := err == nil && fi.Path != destPath
+			a.unlockTask(t)
+			switch {
+			case orphan:
+				a.removeTaskFile(destPath, blob)
+			case blob != "":
+				_, _ = a.blobs.Release(blob)
+			}
+			a.advanceSequential(t)
+			continue
+		}
 		fi.Attempts++
+		used := store.UsageCounters{Requests: 1}
 		if err != nil {
-			fi.State = core.FileFailed
-			fi.Error = err.Error()
-			fi.FinishedAt = &now2
+			used.Failures = 1
 		} else {
-			fi.State = core.FileDone
-			fi.Error = ""
-			fi.BytesDownloaded = written
-			fi.FinishedAt = &now2
+			used.Files, used.Bytes = 1, written
 		}
-		t.RecomputeStatus()
-		a.mu.Unlock()
-
-		_ = a.wal.AppendTask(t)
-
-		if err != nil && fi.Attempts < fi.MaxAttempts {
-			a.mu.Lock()
-			fi.State = core.FilePending
-			fi.Error = ""
-			fi.StartedAt = nil
-			fi.FinishedAt = nil
-			t.RecomputeStatus()
-			a.mu.Unlock()
-
-			_ = a.wal.AppendTask(t)
+		tenant, apiKey := t.Tenant, t.APIKey
+		evs = []core.TaskEvent{t.AddEvent(core.TaskEvent{
+			At:      now2,
+			Type:    core.EventFileFinished,
+			FileID:  fi.ID,
+			Status:  string(fi.State),
+			Message: fi.Error,
+		})}
+		retry := err != nil && fi.Attempts < fi.MaxAttempts && !t.PastExpiry(now2)
+		var retryAt time.Time
+		if retry {
+			// промежуточное состояние FAILED в журнал отдельно не пишем: сразу фиксируем
+			// возврат в очередь (ошибка попытки остаётся в истории файла и задачи)
+			delay := a.retryBackoff(fi.Host, fi.Attempts)
+			retryAt = now2.Add(delay)
+			reason := fmt.Sprintf("auto retry %d/%d in %s", fi.Attempts, fi.MaxAttempts, delay.Round(time.Millisecond))
+			_ = fi.Transition(core.FilePending, reason, now2)
+			fi.NextAttemptAt = &retryAt
+			evs = append(evs, t.AddEvent(core.TaskEvent{
+				At:      now2,
+				Type:    core.EventFileRetry,
+				FileID:  fi.ID,
+				Status:  string(core.FilePending),
+				Message: reason,
+			}))
+		}
+		var finished *core.Task // задача дошла до COMPLETE/PARTIAL — пишем опись
+		if ev, changed := t.RecomputeStatusEvent(now2); changed {
+			evs = append(evs, ev)
+			if t.Status == core.TaskComplete || t.Status == core.TaskPartial {
+				finished = t.Clone()
+			}
+		}
+		snap = fi.Clone()
+		next := retryJobFor(t, fi)
+		a.unlockTask(t)
 
-			a.dispatcher.InChan() <- queue.Job{TaskID: job.TaskID, FileIndex: job.FileIndex, Host: fi.Host}
+		a.recordUsage(tenant, apiKey, now2, used)
+		_ = a.wal.AppendFile(t.ID, snap)
+		a.recordEvents(t, evs...)
+		if err != nil && !retry {
+			log.Printf("Worker %d: task %s file %s failed after %d attempt(s): %v%s",
+				idx, t.ID, snap.ID, snap.Attempts, err, requestTag(t.RequestID))
+		}
+		if err == nil && len(snap.Aliases) > 0 {
+			a.linkAliases(t, snap)
+		}
+		if finished != nil {
+			a.logins.drop(t.ID)
+			a.writeManifest(finished)
+			a.releaseDependents(t.ID)
 		}
-	}
-}
 
-// uniquePath возвращает уникальный путь на основе base.
-// Если base не занят — возвращает его. Иначе подставляет суффикс "-N"
-// перед расширением (name-1.ext, name-2.ext, …) и ищет первый свободный
-// до 9999. Если не нашёл — возвращает base + "-dup".
-func uniquePath(base string) string {
-	if _, err := os.Stat(base); errors.Is(err, os.ErrNotExist) {
-		return base
-	}
-	ext := filepath.Ext(base)
-	name := base[:len(base)-len(ext)]
-	for i := 1; i < 10000; i++ {
-		p := name + "-" + itoa(i) + ext
-		if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
-			return p
+		if retry {
+			a.dispatcher.Schedule(next, retryAt)
+		} else {
+			a.advanceSequential(t)
+		}
+		if snap.Upload != nil {
+			a.enqueueUpload(t.ID, snap.ID)
 		}
 	}
-	return base + "-dup"
 }
 
-// itoa преобразует целое n в строку, используя стековый буфер.
-// Поддерживает отрицательные значения и ноль; 20 байт хватает для int до 64 бит
-// (19 цифр + знак). ВАЖНО: для math.MinInt текущее n = -n переполнится.
-func itoa(n int) string {
-	if n == 0 {
-		return "0"
-	}
-	buf := [20]byte{}
-	i := len(buf)
-	neg := n < 0
-	if neg {
-		n = -n
-	}
-	for n > 0 {
-		i--
-		buf[i] = byte('0' + n%10)
-		n /= 10
-	}
-	if neg {
-		i--
-		buf[i] = '-'
+// Задание чужой задачи в общей очереди возвращается в неё не больше
+// maxJobBounces раз, с паузой jobBounceDelay.
+const (
+	maxJobBounces  = 20
+	jobBounceDelay = time.Second
+)
+
+// bounceJob обрабатывает задание задачи, которой нет в памяти. В общей очереди
+// (Queue.Shared) это, скорее всего, задача другого экземпляра: задание
+// возвращается в очередь с паузой, чтобы его забрал владелец. Задание,
+// не нашедшее владельца за maxJobBounces возвратов (задачу удалили), и любое
+// такое задание локальной очереди отбрасывается как устаревшее.
+func (a *App) bounceJob(job queue.Job) {
+	if !a.dispatcher.Shared() || job.Bounces >= maxJobBounces {
+		a.staleJobs.Inc()
+		return
 	}
-	return string(buf[i:])
+	job.Bounces++
+	a.dispatcher.Schedule(job, time.Now().Add(jobBounceDelay))
 }
 
-// Serve запускает HTTP-сервер и блокируется до его остановки.
-//
-// Поведение:
-//   - слушает адрес из a.Conf.Addr();
-//   - параллельно ждёт SIGINT/SIGTERM и при получении делает graceful shutdown:
-//     srv.Shutdown(ctx с таймаутом a.Conf.ShutdownWait, по умолчанию 20s) + a.Close();
-//   - если ListenAndServe завершился не http.ErrServerClosed — возвращает эту ошибку;
-//     при штатном завершении возвращает nil.
-//
-// Ошибки закрытия (Shutdown/Close) логируются, но не пробрасываются.
-func (a *App) Serve(handler http.Handler) error {
-	srv := &http.Server{Addr: a.Conf.Addr(), Handler: handler}
+// inflightKey — ключ App.inflight для файла fileID задачи taskID.
+func inflightKey(taskID, fileID string) string { return taskID + "/" + fileID }
 
-	sigCh := make(chan os.Signal, 2)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+// cancelInflight прерывает идущую загрузку файла fileID задачи taskID, если она есть.
+func (a *App) cancelInflight(taskID, fileID string) {
+	a.imu.Lock()
+	cancel, ok := a.inflight[inflightKey(taskID, fileID)]
+	a.imu.Unlock()
+	if ok {
+		cancel()
+	}
+}
 
-	errCh := make(chan error, 1)
-	go func() {
-		log.Printf("HTTP: listen on %s", a.Conf.Addr())
-		errCh <- srv.ListenAndServe()
-	}()
+// lockTask захватывает задачу t для чтения и изменения, не останавливая
+// работу с другими задачами: a.mu на чтение и блокировку задачи (см. App.mu).
+// Освобождается unlockTask.
+func (a *App) lockTask(t *core.Task) {
+	a.mu.RLock()
+	t.Mutex().Lock()
+}
 
-	select {
-	case err := <-errCh:
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			return err
-		}
-		return nil
-	case sig := <-sigCh:
-		log.Printf("Signal: %v — graceful shutdown", sig)
-		wait := a.Conf.ShutdownWait
-		if wait <= 0 {
-			wait = 20 * time.Second
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), wait)
-		defer cancel()
-		_ = srv.Shutdown(ctx)
-		closeErr := a.Close()
-		if closeErr != nil {
-			log.Printf("close error: %v", closeErr)
-		}
-	}
-	return nil
+// unlockTask освобождает задачу, захваченную lockTask.
+func (a *App) unlockTask(t *core.Task) {
+	t.Mutex().Unlock()
+	a.mu.RUnlock()
 }
 
 func max(a, b int) int {
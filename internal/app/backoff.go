@@ -0,0 +1,25 @@
+package app
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// retryDelay — пауза перед автоповтором файла после failures неудачных попыток:
+// base·2^(failures-1) (base, 2·base, 4·base, …), не больше limit, с джиттером
+// «половина плюс случайная половина» — файлы, упавшие вместе (сбой хоста),
+// не возвращаются к источнику одной волной. base <= 0 — повтор без паузы.
+func retryDelay(base, limit time.Duration, failures int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := base
+	for i := 1; i < failures && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
@@ -0,0 +1,177 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// DeleteResult — итог удаления задачи (DELETE /tasks/{id}).
+type DeleteResult struct {
+	TaskID       string `json:"task_id"`
+	FilesRemoved int    `json:"files_removed"` // файлов задачи удалено из каталога загрузок
+	BytesFreed   int64  `json:"bytes_freed"`   // освобождено места (общие blob'ы не считаются)
+	BlobsKept    int    `json:"blobs_kept"`    // blob'ов не удалено: на них ссылаются другие задачи
+
+	Trashed bool       `json:"trashed,omitempty"`  // задача перенесена в корзину (TrashTask), ничего не удалено
+	PurgeAt *time.Time `json:"purge_at,omitempty"` // когда задача в корзине будет удалена окончательно
+}
+
+// ingestBlob переносит скачанный файл path в хранилище BLOB_DIR (store.BlobStore.Ingest)
+// и возвращает sha256 содержимого. Хранилище выключено или перенос не удался —
+// пустая строка: файл остаётся обычным файлом задачи (ошибка пишется в лог).
+func (a *App) ingestBlob(path string) string {
+	if a.blobs == nil || storage.IsObject(path) {
+		return ""
+	}
+	sum, err := a.blobs.Ingest(path)
+	if err != nil {
+		log.Printf("Blobs: keeping %s outside the blob store: %v", path, err)
+		return ""
+	}
+	return sum
+}
+
+// retainBlobsLocked учитывает ссылки на blob'ы файлов восстановленной задачи t.
+// Вызывать под a.mu.
+func (a *App) retainBlobsLocked(t *core.Task) {
+	if a.blobs == nil {
+		return
+	}
+	for _, f := range t.Files {
+		a.blobs.Retain(f.Blob)
+	}
+}
+
+// DeleteTask удаляет задачу id вместе с её скачанными файлами:
+//   - идущие загрузки задачи прерываются, задача пишется в WAL записью
+//     "delete_task" и пропадает из памяти (и из журнала после компактизации);
+//   - файлы задачи и её опись (TASK_MANIFEST) удаляются из каталога загрузок,
+//     пустые каталоги задачи — тоже;
+//   - при включённом BLOB_DIR с каждого файла снимается ссылка на blob: содержимое
+//     удаляется, только если на него не ссылается ни одна другая задача.
+//
+// ErrNotFound — задачи нет; ошибка записи в WAL — задача не удаляется.
+// Ошибки удаления отдельных файлов пишутся в лог и не прерывают удаление.
+func (a *App) DeleteTask(id string) (DeleteResult, error) {
+	a.mu.Lock()
+	t, ok := a.tasks.get(id)
+	if !ok {
+		a.mu.Unlock()
+		return DeleteResult{}, ErrNotFound
+	}
+	if err := a.wal.AppendDelete(id); err != nil {
+		a.mu.Unlock()
+		return DeleteResult{}, err
+	}
+	a.tasks.remove(id)
+	a.stats.removed(id)
+	delete(a.waiting, id)
+	a.logins.drop(id)
+	files := make([]core.FileItem, 0, len(t.Files))
+	for _, f := range t.Files {
+		a.cancelInflight(t.ID, f.ID)
+		files = append(files, *f)
+	}
+	destDir := a.taskDestDir(t)
+	a.mu.Unlock()
+
+	res := DeleteResult{TaskID: id}
+	for _, f := range files {
+		if f.Path == "" {
+			continue
+		}
+		freed, kept := a.removeTaskFile(f.Path, f.Blob)
+		res.FilesRemoved++
+		res.BytesFreed += freed
+		if kept {
+			res.BlobsKept++
+		}
+		for _, p := range aliasPaths(destDir, &f) {
+			if err := os.Remove(p); err == nil {
+				res.FilesRemoved++
+				removeEmptyDirs(filepath.Dir(p), destDir)
+			}
+		}
+		if !storage.IsObject(f.Path) {
+			removeEmptyDirs(filepath.Dir(f.Path), destDir)
+		}
+	}
+	if a.Conf.TaskManifest != "" {
+		a.removeManifest(destDir)
+	}
+	if !storage.IsObject(destDir) {
+		removeEmptyDirs(destDir, destDir)
+	}
+	a.publishEvents(t, core.TaskEvent{
+		At:      time.Now().UTC(),
+		Type:    core.EventDeleted,
+		Message: fmt.Sprintf("%d file(s) removed, %d byte(s) freed", res.FilesRemoved, res.BytesFreed),
+	})
+	log.Printf("Tasks: deleted %s: %d file(s), %d byte(s) freed, %d shared blob(s) kept%s",
+		id, res.FilesRemoved, res.BytesFreed, res.BlobsKept, requestTag(t.RequestID))
+	a.releaseDependents(id)
+	return res, nil
+}
+
+// removeTaskFile удаляет файл задачи path (с диска или из S3) и снимает ссылку
+// на его blob (если есть). Возвращает освобождённые байты и kept=true, если blob
+// остался нужен другим задачам.
+func (a *App) removeTaskFile(path, blob string) (freed int64, kept bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.Conf.ClientTimeout)
+	defer cancel()
+	var size int64
+	if fi, err := a.storage.Stat(ctx, path); err == nil {
+		size = fi.Size
+	}
+	if err := a.storage.Remove(ctx, path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Tasks: remove %s: %v", path, err)
+		return 0, false
+	}
+	if blob == "" || a.blobs == nil {
+		return size, false
+	}
+	removed, err := a.blobs.Release(blob)
+	if err != nil {
+		log.Printf("Blobs: remove %s: %v", blob, err)
+	}
+	if !removed {
+		return 0, true
+	}
+	return size, false
+}
+
+// taskDestDir возвращает каталог загрузок задачи t (DestDir или DOWNLOAD_DIR/<id>).
+func (a *App) taskDestDir(t *core.Task) string { return destDirOf(a.Conf.DownloadDir, t) }
+
+// destDirOf — каталог задачи t при каталоге загрузок downloadDir.
+func destDirOf(downloadDir string, t *core.Task) string {
+	if t.DestDir != "" {
+		return t.DestDir
+	}
+	return filepath.Join(downloadDir, t.ID)
+}
+
+// removeEmptyDirs удаляет пустые каталоги от dir вверх до stop включительно
+// (останавливается на первом непустом). dir вне stop не трогается.
+func removeEmptyDirs(dir, stop string) {
+	dir, stop = filepath.Clean(dir), filepath.Clean(stop)
+	for {
+		if rel, err := filepath.Rel(stop, dir); err != nil || rel == ".." || filepath.IsAbs(rel) ||
+			len(rel) > 2 && rel[:3] == ".."+string(filepath.Separator) {
+			return
+		}
+		if os.Remove(dir) != nil || dir == stop {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// TaskFilter — отбор задач для массовых операций (POST /tasks/retry-failed,
+// POST /tasks/cancel). Условия объединяются по «И»; пустое поле не ограничивает.
+type TaskFilter struct {
+	IDs    []string `json:"ids,omitempty"`    // конкретные задачи
+	Status string   `json:"status,omitempty"` // агрегированный статус задачи (core.TaskStatus)
+	Tag    string   `json:"tag,omitempty"`    // задача содержит тег
+	Host   string   `json:"host,omitempty"`   // затрагиваются только файлы с этого хоста
+	Tenant string   `json:"-"`                // только задачи арендатора клиента (из токена, не из тела)
+}
+
+// ErrEmptyFilter — массовая операция без единого условия (защита от случайной
+// операции над всеми задачами).
+var ErrEmptyFilter = errors.New("фильтр должен содержать хотя бы одно из: ids, status, tag, host")
+
+// BulkResult — итог массовой операции.
+type BulkResult struct {
+	Tasks   int      `json:"tasks"`    // затронуто задач
+	Files   int      `json:"files"`    // затронуто файлов
+	TaskIDs []string `json:"task_ids"` // ID затронутых задач (по возрастанию)
+}
+
+// Validate проверяет, что задано хотя бы одно условие, а статус — известный.
+func (f TaskFilter) Validate() error {
+	if len(f.IDs) == 0 && f.Status == "" && f.Tag == "" && f.Host == "" {
+		return ErrEmptyFilter
+	}
+	if _, err := core.ParseTaskStatus(f.Status); err != nil {
+		return fmt.Errorf("status: %w", err)
+	}
+	return nil
+}
+
+// matches проверяет задачу по условиям уровня задачи (ids, status, tag).
+// Host проверяется на уровне файлов — в самих операциях. Задачи в корзине
+// массовые операции не затрагивают (даже перечисленные в ids).
+func (f TaskFilter) matches(t *core.Task) bool {
+	if t.TrashedAt != nil || f.Tenant != "" && t.Tenant != f.Tenant {
+		return false
+	}
+	if len(f.IDs) > 0 {
+		found := false
+		for _, id := range f.IDs {
+			if id == t.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Status != "" && !strings.EqualFold(string(t.Status), f.Status) {
+		return false
+	}
+	if f.Tag != "" {
+		found := false
+		for _, tag := range t.Tags {
+			if tag == f.Tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// RetryTasks — массовый RetryFailed: в отобранных задачах упавшие файлы
+// (с хоста filter.Host, если задан) возвращаются в очередь с новым бюджетом попыток.
+// Удобно после сбоя одного зеркала: {"host": "mirror.example.com"}.
+func (a *App) RetryTasks(filter TaskFilter) (BulkResult, error) {
+	return a.bulk(filter, func(t *core.Task, now time.Time) *taskChange {
+		return retryFailedLocked(t, filter.Host, now)
+	})
+}
+
+// CancelTasks отменяет в отобранных задачах ещё не завершённые файлы
+// (с хоста filter.Host, если задан): Pending и Running переходят в Cancelled,
+// идущие загрузки прерываются (их .part-файлы удаляет загрузчик).
+// Задача без оставшихся Pending/Running файлов получает статус CANCELLED.
+func (a *App) CancelTasks(filter TaskFilter) (BulkResult, error) {
+	return a.bulk(filter, func(t *core.Task, now time.Time) *taskChange {
+		return a.cancelLocked(t, filter.Host, now)
+	})
+}
+
+// bulk применяет op ко всем задачам, прошедшим filter, в одной критической
+// секции, затем фиксирует изменения (App.commit) уже без блокировки.
+func (a *App) bulk(filter TaskFilter, op func(t *core.Task, now time.Time) *taskChange) (BulkResult, error) {
+	if err := filter.Validate(); err != nil {
+		return BulkResult{}, err
+	}
+	now := time.Now().UTC()
+	var changes []*taskChange
+	a.mu.Lock()
+	for _, t := range a.tasks.all() {
+		if !filter.matches(t) {
+			continue
+		}
+		if ch := op(t, now); ch.files > 0 {
+			changes = append(changes, ch)
+		}
+	}
+	a.mu.Unlock()
+
+	res := BulkResult{TaskIDs: make([]string, 0, len(changes))}
+	for _, ch := range changes {
+		a.commit(ch)
+		res.Tasks++
+		res.Files += ch.files
+		res.TaskIDs = append(res.TaskIDs, ch.snap.ID)
+	}
+	sort.Strings(res.TaskIDs)
+	return res, nil
+}
+
+// cancelLocked переводит незавершённые файлы задачи t (с хоста host, если задан)
+// в Cancelled и прерывает их загрузки. Вызывать под a.mu.
+func (a *App) cancelLocked(t *core.Task, host string, now time.Time) *taskChange {
+	ch := &taskChange{}
+	for _, f := range t.Files {
+		if f.State != core.FilePending && f.State != core.FileRunning {
+			continue
+		}
+		if host != "" && !strings.EqualFold(f.Host, host) {
+			continue
+		}
+		if f.Transition(core.FileCancelled, "cancelled by request", now) != nil {
+			continue
+		}
+		a.cancelInflight(t.ID, f.ID)
+		ch.files++
+	}
+	if ch.files == 0 {
+		return ch
+	}
+	ch.events = append(ch.events, t.AddEvent(core.TaskEvent{
+		At:      now,
+		Type:    core.EventCancelled,
+		Message: fmt.Sprintf("%d file(s) cancelled", ch.files),
+	}))
+	if ev, changed := t.RecomputeStatusEvent(now); changed {
+		ch.events = append(ch.events, ev)
+	}
+	ch.snap = t.Clone()
+	return ch
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/bundle"
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/storage"
+	"github.com/Extrarius/29.09.2025/internal/store"
+)
+
+// ImportResult — итог импорта архива задачи (POST /admin/tasks/import).
+type ImportResult struct {
+	TaskID  string `json:"task_id"`
+	Files   int    `json:"files"`   // распаковано файлов
+	Bytes   int64  `json:"bytes"`   // их суммарный размер
+	Pending int    `json:"pending"` // файлов поставлено в очередь (ещё не скачаны)
+}
+
+// ExportTask пишет в w архив задачи id (пакет bundle): задачу, историю и
+// скачанные файлы. ErrNotFound — задачи нет; ErrRemoteFile — файлы задачи в S3
+// (dest_dir "s3://…"), в архив они не попадают; ошибка чтения файлов возвращается
+// до записи первого байта (bundle.Write), так что HTTP-ответ можно заменить ошибкой.
+func (a *App) ExportTask(id string, w io.Writer) error {
+	a.mu.RLock()
+	t, ok := a.tasks.get(id)
+	if !ok {
+		a.mu.RUnlock()
+		return ErrNotFound
+	}
+	t.Mutex().Lock()
+	snap, h := t.Clone(), t.History()
+	t.Mutex().Unlock()
+	a.mu.RUnlock()
+	if storage.IsObject(snap.DestDir) {
+		return ErrRemoteFile
+	}
+	return bundle.Write(w, snap, h, a.Conf.DownloadDir)
+}
+
+// ImportTask распаковывает архив задачи из r под DOWNLOAD_DIR и регистрирует
+// задачу с прежними ID, метаданными и историей (плюс событие EventImported):
+//   - задача с таким ID уже есть — ErrTaskExists, задача больше записи журнала —
+//     store.ErrRecordTooLarge; файлы в обоих случаях не распаковываются;
+//   - повреждённый архив — ошибка bundle.ErrFormat, распакованное удаляется;
+//   - при включённом BLOB_DIR файлы переносятся в хранилище по содержимому;
+//   - недокачанные файлы ставятся в очередь, лимиты арендатора не применяются
+//     (импорт — операция администратора).
+func (a *App) ImportTask(r io.Reader) (ImportResult, error) {
+	b, err := bundle.Read(r, a.Conf.DownloadDir, func(t *core.Task) error {
+		a.mu.RLock()
+		_, dup := a.tasks.get(t.ID)
+		a.mu.RUnlock()
+		if dup {
+			return ErrTaskExists
+		}
+		return store.CheckTaskRecord(t)
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+	t := b.Task
+	t.RestoreHistory(b.History)
+	t.SeedVersion(uint64(time.Now().UnixMicro()))
+	res := ImportResult{TaskID: t.ID, Bytes: b.Bytes}
+	for _, f := range t.Files {
+		if f.Path != "" {
+			f.Blob = a.ingestBlob(f.Path)
+			res.Files++
+		}
+		if f.State == core.FilePending {
+			res.Pending++
+		}
+	}
+	ev := core.TaskEvent{
+		Type:    core.EventImported,
+		Status:  string(t.Status),
+		Message: fmt.Sprintf("%d file(s) from bundle exported at %s", res.Files, b.Header.ExportedAt.Format(time.RFC3339)),
+	}
+	if err := a.registerTask(t, false, ev); err != nil {
+		// задачу успели создать параллельно — распакованное не нужно
+		for _, f := range t.Files {
+			if f.Path != "" {
+				a.removeTaskFile(f.Path, f.Blob)
+			}
+		}
+		return ImportResult{}, err
+	}
+	log.Printf("Tasks: imported %s: %d file(s), %d byte(s), %d pending", t.ID, res.Files, res.Bytes, res.Pending)
+	return res, nil
+}
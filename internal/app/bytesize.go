@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ByteSize — размер в байтах. В конфигурации пишется как "256KB", "1MB",
+// "1.5GiB" или числом байт (единицы двоичные: 1KB = 1024 байт).
+type ByteSize int64
+
+// ParseByteSize разбирает размер вида "1MB", "64KiB", "512K" или "65536".
+func ParseByteSize(s string) (ByteSize, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	num := strings.TrimRight(v, "kmgtib")
+	mult := float64(1)
+	switch strings.TrimSuffix(strings.TrimSuffix(v[len(num):], "b"), "i") {
+	case "":
+	case "k":
+		mult = 1 << 10
+	case "m":
+		mult = 1 << 20
+	case "g":
+		mult = 1 << 30
+	case "t":
+		mult = 1 << 40
+	default:
+		return 0, fmt.Errorf("некорректный размер %q", s)
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || f < 0 || math.IsInf(f, 0) || f*mult > math.MaxInt64 {
+		return 0, fmt.Errorf("некорректный размер %q", s)
+	}
+	return ByteSize(f * mult), nil
+}
+
+// String — размер в виде, который принимает ParseByteSize.
+func (b ByteSize) String() string {
+	for _, u := range []struct {
+		name string
+		size ByteSize
+	}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if b >= u.size && b%u.size == 0 {
+			return strconv.FormatInt(int64(b/u.size), 10) + u.name
+		}
+	}
+	return strconv.FormatInt(int64(b), 10) + "B"
+}
+
+// Set и UnmarshalText позволяют читать ByteSize из флагов и файла конфигурации.
+func (b *ByteSize) Set(s string) error {
+	v, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+func (b *ByteSize) UnmarshalText(text []byte) error { return b.Set(string(text)) }
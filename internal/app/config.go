@@ -0,0 +1,400 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/auth"
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/downloader"
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+	"github.com/Extrarius/29.09.2025/internal/notify"
+	"github.com/Extrarius/29.09.2025/internal/redis"
+	"github.com/Extrarius/29.09.2025/internal/sink"
+)
+
+// Validate проверяет конфигурацию целиком и возвращает все найденные
+// проблемы одной ошибкой (errors.Join), а не только первую.
+//
+// Проверяется:
+//   - числовые параметры: Workers >= 1, BandwidthLimit >= 0, WriteRateLimit >= 0,
+//     WorkerNice — 0..MaxWorkerNice, CopyBufferSize — 0 или
+//     от 4KB до 64MB, Retries >= 1, HostConcurrency >= 0, лимиты HostLimits >= 0,
+//     профили хостов HostProfiles (validateHostProfiles),
+//     ClientTimeout > 0, ShutdownWait >= 0, RetryBackoff >= 0 и
+//     RetryBackoffMax >= RetryBackoff, WALAsyncQueue >= 0;
+//   - адреса: Port (если не задан Listen), элементы Listen/AdminListen,
+//     пересечение публичных и админских адресов;
+//   - TLS: TLSCert и TLSKey задаются только парой, файлы существуют,
+//     TLSClientCA требует включённого TLS;
+//   - админ: при заданном пароле нужен логин, пароль не совпадает ни с одним
+//     из API_KEYS (учётные данные админки и API задач раздельны), параметры
+//     блокировки перебора неотрицательны;
+//   - API_KEYS: элементы вида "key" или "key:role" с известной ролью;
+//   - JWT: JWTIssuer и JWTJWKSURL — абсолютные http(s)-URL, задан JWTRoleClaim,
+//     остальные JWT_* без JWT_ISSUER не задаются;
+//   - лимиты MAX_LINKS_PER_TASK, MAX_PENDING_FILES_PER_TENANT, MAX_TASKS_PER_HOUR >= 0;
+//   - подписанные ссылки: SIGNED_URL_KEY не короче 32 символов, SIGNED_URL_MAX_TTL > 0,
+//     SIGNED_URL_BASE — пусто или http(s)-URL без параметров;
+//   - TaskManifest — пусто, "json", "sha256" или "both";
+//   - PartLocation — пусто, same, task или global (global — только с PartDir,
+//     PartDir — только при global); FinalizeSync — пусто, none, file или full;
+//   - WorkerIOPriority — пусто, normal, low или idle;
+//   - ErrorLang — язык из каталога сообщений (i18n.Languages);
+//   - DuplicatePolicy — allow, dedupe или reject, DuplicateWindow >= 0;
+//   - TaskIDFormat — пусто, timestamp, uuidv7 или ulid; TrashRetention >= 0;
+//   - расписание Schedule: окна в пределах суток, непустые и не пересекаются,
+//     workers и bandwidth окон >= 0; окна обслуживания Maintenance — в пределах
+//     суток, непустые, с известными днями недели;
+//   - хранилища Sinks: имена без пробелов и "/", адреса разбираются (sink.New)
+//     и содержат ключи доступа;
+//   - S3 для dest_dir "s3://…": S3Endpoint — http(s)-URL, при заданных S3_*
+//     в окружении есть ключи доступа (openObjectStore);
+//   - QueueURL (если задан) — адрес redis://; IngestURL и EventsURL — nats:// с параметром subject;
+//   - LeaderLease — 0 или не меньше 3s, и только вместе с QueueURL;
+//   - SMTPURL (если задан) — smtp:// или smtps:// с адресом сервера, вместе с
+//     ним — SMTPFrom, разбираемый как почтовый адрес;
+//   - уведомители Notifiers: имена без пробелов и "/", не "email", адреса
+//     разбираются (notify.New); NotifyFailures — только из них;
+//   - правила оповещений Alerts: имена уникальны, одно условие (failure_rate
+//     в (0, 1) или queue_depth > 0), window не короче минуты, получатели —
+//     уведомители из notifiers и почта при SMTPURL (validateAlerts);
+//   - защита свободного места: DiskHighWatermark не меньше DiskLowWatermark,
+//     получатели — из notifiers и почта при SMTPURL (validateDiskGuard);
+//   - SignatureKeyrings (если заданы) читаются как связки ключей OpenPGP и
+//     содержат хотя бы один ключ;
+//   - каталоги DataDir и DownloadDir (и WatchDir, BlobDir, QuarantineDir, PartDir, если заданы) создаются
+//     и доступны на запись; WatchInterval > 0 при заданном WatchDir.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Workers < 1 {
+		add("WORKERS: должно быть >= 1, получено %d", c.Workers)
+	}
+	if c.BandwidthLimit < 0 {
+		add("BANDWIDTH_LIMIT: должно быть >= 0 (0 — без ограничения), получено %d", c.BandwidthLimit)
+	}
+	if c.WriteRateLimit < 0 {
+		add("WRITE_RATE_LIMIT: должно быть >= 0 (0 — без ограничения), получено %d", c.WriteRateLimit)
+	}
+	if c.WorkerNice < 0 || c.WorkerNice > MaxWorkerNice {
+		add("WORKER_NICE: должно быть от 0 до %d, получено %d", MaxWorkerNice, c.WorkerNice)
+	}
+	switch c.WorkerIOPriority {
+	case "", IOPriorityNormal, IOPriorityLow, IOPriorityIdle:
+	default:
+		add("WORKER_IO_PRIORITY: ожидается %s, %s или %s, получено %q", IOPriorityNormal, IOPriorityLow, IOPriorityIdle, c.WorkerIOPriority)
+	}
+	if c.CopyBufferSize != 0 && (c.CopyBufferSize < 4<<10 || c.CopyBufferSize > 64<<20) {
+		add("COPY_BUFFER_SIZE: должно быть от 4KB до 64MB (0 — 32KB), получено %s", c.CopyBufferSize)
+	}
+	validateSchedule(c.Schedule, add)
+	validateMaintenance(c.Maintenance, add)
+	if c.Retries < 1 {
+		add("RETRIES: должно быть >= 1, получено %d", c.Retries)
+	}
+	if c.HostConcurrency < 0 {
+		add("HOST_CONCURRENCY: должно быть >= 0 (0 — без ограничения), получено %d", c.HostConcurrency)
+	}
+	for host, n := range c.HostLimits {
+		if n < 0 {
+			add("hosts.%s.concurrency: должно быть >= 0, получено %d", host, n)
+		}
+	}
+	validateHostProfiles(c, add)
+	sinkNames := make([]string, 0, len(c.Sinks))
+	for name := range c.Sinks {
+		sinkNames = append(sinkNames, name)
+	}
+	sort.Strings(sinkNames)
+	for _, name := range sinkNames {
+		if name == "" || strings.ContainsAny(name, " \t/") {
+			add("sinks: недопустимое имя хранилища %q", name)
+		} else if _, err := sink.New(c.Sinks[name].URL, nil); err != nil {
+			add("sinks.%s.url: %v", name, err)
+		}
+	}
+	if _, err := openObjectStore(c); err != nil {
+		add("S3: %v", err)
+	}
+	if c.ClientTimeout <= 0 {
+		add("CLIENT_TIMEOUT: должно быть > 0, получено %s", c.ClientTimeout)
+	}
+	if c.RetryBackoff < 0 {
+		add("RETRY_BACKOFF: должно быть >= 0 (0 — без паузы), получено %s", c.RetryBackoff)
+	}
+	if c.RetryBackoff > 0 && c.RetryBackoffMax < c.RetryBackoff {
+		add("RETRY_BACKOFF_MAX: должно быть не меньше RETRY_BACKOFF (%s), получено %s", c.RetryBackoff, c.RetryBackoffMax)
+	}
+	if c.WALAsyncQueue < 0 {
+		add("WAL_ASYNC_QUEUE: должно быть >= 0 (0 — синхронная запись), получено %d", c.WALAsyncQueue)
+	}
+	if c.ShutdownWait < 0 {
+		add("SHUTDOWN_WAIT: должно быть >= 0, получено %s", c.ShutdownWait)
+	}
+
+	if len(c.Listen) == 0 {
+		if n, err := strconv.Atoi(strings.TrimPrefix(c.Port, ":")); err != nil || n < 1 || n > 65535 {
+			add("PORT: ожидается число 1..65535, получено %q", c.Port)
+		}
+	}
+	// Публичные адреса берём через ListenAddrs, чтобы поймать конфликт
+	// ADMIN_LISTEN и с адресом по умолчанию из PORT (его синтаксис проверен выше).
+	seen := make(map[string]string)
+	for _, group := range []struct {
+		name  string
+		addrs []string
+	}{{"LISTEN", c.ListenAddrs()}, {"ADMIN_LISTEN", c.AdminListen}} {
+		for _, addr := range group.addrs {
+			if err := validateListenAddr(addr); err != nil {
+				if group.name == "ADMIN_LISTEN" || len(c.Listen) > 0 {
+					add("%s: %q: %v", group.name, addr, err)
+				}
+				continue
+			}
+			if prev, dup := seen[addr]; dup {
+				add("%s: адрес %q уже указан в %s", group.name, addr, prev)
+				continue
+			}
+			seen[addr] = group.name
+		}
+	}
+
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		add("TLS_CERT и TLS_KEY задаются только вместе")
+	}
+	for _, f := range []struct{ name, path string }{
+		{"TLS_CERT", c.TLSCert}, {"TLS_KEY", c.TLSKey}, {"TLS_CLIENT_CA", c.TLSClientCA},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			add("%s: %v", f.name, err)
+		}
+	}
+	if c.TLSClientCA != "" && !c.TLSEnabled() {
+		add("TLS_CLIENT_CA требует включённого TLS (TLS_CERT/TLS_KEY или TLS_SELF_SIGNED)")
+	}
+
+	if c.AdminPassword != "" && c.AdminUser == "" {
+		add("ADMIN_USER: пуст при заданном ADMIN_PASSWORD")
+	}
+	for i, entry := range c.APIKeys {
+		key, _, err := auth.ParseAPIKey(entry)
+		if err != nil {
+			add("API_KEYS[%d]: %v", i, err)
+			continue
+		}
+		if c.AdminPassword != "" && key == c.AdminPassword {
+			add("ADMIN_PASSWORD: совпадает с одним из API_KEYS — у админки должны быть отдельные учётные данные")
+		}
+	}
+	if c.AdminLockoutAttempts < 0 {
+		add("ADMIN_LOCKOUT_ATTEMPTS: должно быть >= 0 (0 — без блокировки), получено %d", c.AdminLockoutAttempts)
+	}
+	if c.AdminLockoutAttempts > 0 && c.AdminLockoutDuration <= 0 {
+		add("ADMIN_LOCKOUT_DURATION: должно быть > 0, получено %s", c.AdminLockoutDuration)
+	}
+	for _, h := range c.AllowedHosts {
+		if strings.TrimSpace(h) == "" || h == "*" || h == "*." {
+			add("ALLOWED_HOSTS: некорректный шаблон %q", h)
+		}
+	}
+
+	if c.JWTIssuer != "" {
+		for _, f := range []struct{ name, value string }{{"JWT_ISSUER", c.JWTIssuer}, {"JWT_JWKS_URL", c.JWTJWKSURL}} {
+			if f.value == "" {
+				continue
+			}
+			if u, err := url.Parse(f.value); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				add("%s: ожидается http(s)-URL, получено %q", f.name, f.value)
+			}
+		}
+		if c.JWTRoleClaim == "" {
+			add("JWT_ROLE_CLAIM: пуст при заданном JWT_ISSUER")
+		}
+	} else if c.JWTJWKSURL != "" || c.JWTAudience != "" {
+		add("JWT_JWKS_URL/JWT_AUDIENCE заданы без JWT_ISSUER")
+	}
+	if err := c.Limits().Validate(); err != nil {
+		add("MAX_*: %v", err)
+	}
+	if c.SignedURLKey != "" && len(c.SignedURLKey) < 32 {
+		add("SIGNED_URL_KEY: слишком короткий ключ (%d символов, нужно не меньше 32)", len(c.SignedURLKey))
+	}
+	if c.SignedURLMaxTTL <= 0 {
+		add("SIGNED_URL_MAX_TTL: должно быть > 0, получено %s", c.SignedURLMaxTTL)
+	}
+	if c.SignedURLBase != "" {
+		if u, err := url.Parse(c.SignedURLBase); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			add("SIGNED_URL_BASE: ожидается http(s)-URL без параметров, получено %q", c.SignedURLBase)
+		}
+	}
+
+	if c.QueueURL != "" {
+		if _, err := redis.ParseURL(c.QueueURL); err != nil {
+			add("QUEUE_URL: %v", err)
+		}
+	}
+	switch {
+	case c.LeaderLease < 0:
+		add("LEADER_LEASE: должно быть >= 0, получено %s", c.LeaderLease)
+	case c.LeaderLease > 0 && c.QueueURL == "":
+		add("LEADER_LEASE: аренда хранится в Redis — задайте QUEUE_URL")
+	case c.LeaderLease > 0 && c.LeaderLease < 3*time.Second:
+		add("LEADER_LEASE: должно быть не меньше 3s, получено %s", c.LeaderLease)
+	}
+	if c.IngestURL != "" {
+		if _, err := parseIngestURL(c.IngestURL); err != nil {
+			add("INGEST_URL: %v", err)
+		}
+	}
+	if c.EventsURL != "" {
+		if _, err := parseEventsURL(c.EventsURL); err != nil {
+			add("EVENTS_URL: %v", err)
+		}
+	}
+	if c.SMTPURL != "" {
+		if _, err := notify.NewSMTP(c.SMTPURL, c.SMTPFrom); err != nil {
+			add("SMTP_URL: %v", err)
+		}
+	}
+	notifierNames := make([]string, 0, len(c.Notifiers))
+	for name := range c.Notifiers {
+		notifierNames = append(notifierNames, name)
+	}
+	sort.Strings(notifierNames)
+	for _, name := range notifierNames {
+		if name == "" || name == core.NotifyChannelEmail || strings.ContainsAny(name, " \t/") {
+			add("notifiers: недопустимое имя уведомителя %q", name)
+		} else if _, err := notify.New(c.Notifiers[name]); err != nil {
+			add("notifiers.%s: %v", name, err)
+		}
+	}
+	for _, name := range c.NotifyFailures {
+		if _, ok := c.Notifiers[name]; !ok {
+			add("NOTIFY_FAILURES: уведомитель %q не задан в секции notifiers", name)
+		}
+	}
+	validateAlerts(c, add)
+	validateDiskGuard(c, add)
+	switch c.TaskManifest {
+	case "", ManifestJSON, ManifestSHA256, ManifestBoth:
+	default:
+		add("TASK_MANIFEST: ожидается %s, %s или %s, получено %q", ManifestJSON, ManifestSHA256, ManifestBoth, c.TaskManifest)
+	}
+	switch c.PartLocation {
+	case "", PartsSame, PartsTask:
+		if c.PartLocation != "" && c.PartDir != "" {
+			add("PART_DIR: задан, но PART_LOCATION=%s (каталог используется только при %s)", c.PartLocation, PartsGlobal)
+		}
+	case PartsGlobal:
+		if c.PartDir == "" {
+			add("PART_LOCATION=%s: не задан PART_DIR", PartsGlobal)
+		}
+	default:
+		add("PART_LOCATION: ожидается %s, %s или %s, получено %q", PartsSame, PartsTask, PartsGlobal, c.PartLocation)
+	}
+	switch c.FinalizeSync {
+	case "", downloader.SyncNone, downloader.SyncFile, downloader.SyncFull:
+	default:
+		add("FINALIZE_SYNC: ожидается %s, %s или %s, получено %q", downloader.SyncNone, downloader.SyncFile, downloader.SyncFull, c.FinalizeSync)
+	}
+	if !i18n.Supported(c.ErrorLang) {
+		add("ERROR_LANG: ожидается %s, получено %q", strings.Join(i18n.Languages, " или "), c.ErrorLang)
+	}
+	switch c.DuplicatePolicy {
+	case "", core.DuplicatesAllow, core.DuplicatesDedupe, core.DuplicatesReject:
+	default:
+		add("DUPLICATE_POLICY: ожидается %s, %s или %s, получено %q", core.DuplicatesAllow, core.DuplicatesDedupe, core.DuplicatesReject, c.DuplicatePolicy)
+	}
+	if c.DuplicateWindow < 0 {
+		add("DUPLICATE_WINDOW: должно быть >= 0 (0 — только внутри задачи), получено %s", c.DuplicateWindow)
+	}
+	if !core.ValidIDFormat(c.TaskIDFormat) {
+		add("TASK_ID_FORMAT: ожидается %s, %s или %s, получено %q", core.IDTimestamp, core.IDUUIDv7, core.IDULID, c.TaskIDFormat)
+	}
+	if c.TrashRetention < 0 {
+		add("TRASH_RETENTION: должно быть >= 0 (0 — удалять сразу), получено %s", c.TrashRetention)
+	}
+
+	if len(c.SignatureKeyrings) > 0 {
+		if keys, err := downloader.LoadKeyring(c.SignatureKeyrings); err != nil {
+			add("SIGNATURE_KEYRINGS: %v", err)
+		} else if len(keys) == 0 {
+			add("SIGNATURE_KEYRINGS: в файлах нет ни одного ключа")
+		}
+	}
+
+	dirs := []struct{ name, path string }{
+		{"DATA_DIR", c.DataDir}, {"DOWNLOAD_DIR", c.DownloadDir},
+	}
+	if c.WatchDir != "" {
+		dirs = append(dirs, struct{ name, path string }{"WATCH_DIR", c.WatchDir})
+		if c.WatchInterval <= 0 {
+			add("WATCH_INTERVAL: должно быть > 0, получено %s", c.WatchInterval)
+		}
+	}
+	if c.BlobDir != "" {
+		dirs = append(dirs, struct{ name, path string }{"BLOB_DIR", c.BlobDir})
+	}
+	if c.QuarantineDir != "" {
+		dirs = append(dirs, struct{ name, path string }{"QUARANTINE_DIR", c.QuarantineDir})
+	}
+	if c.PartDir != "" {
+		dirs = append(dirs, struct{ name, path string }{"PART_DIR", c.PartDir})
+	}
+	for _, d := range dirs {
+		if err := checkWritableDir(d.path); err != nil {
+			add("%s: %v", d.name, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateListenAddr проверяет синтаксис адреса слушателя ("host:port" или "unix:///path").
+func validateListenAddr(addr string) error {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		if path == "" {
+			return errors.New("пустой путь unix-сокета")
+		}
+		return nil
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("некорректный порт %q", port)
+	}
+	return nil
+}
+
+// checkWritableDir создаёт каталог (если нужно) и проверяет, что в него можно писать,
+// создавая и удаляя временный файл.
+func checkWritableDir(dir string) error {
+	if dir == "" {
+		return errors.New("путь не задан")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("каталог недоступен на запись: %w", err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// Зависимости задач (depends_on) — простые многоэтапные конвейеры: задача B
+// с depends_on: [A] регистрируется сразу, но её файлы не ставятся в очередь,
+// пока A не дойдёт до COMPLETE (или PARTIAL при DEPENDS_ALLOW_PARTIAL).
+//
+// Ждущая задача остаётся PENDING с флагом Waiting и лежит в a.waiting.
+// Зависимости проверяются, когда задача-зависимость завершается (workerLoop)
+// или удаляется (DeleteTask): удалённая зависимость считается выполненной,
+// иначе задача ждала бы вечно. Упавшая или отменённая зависимость держит
+// задачу, пока её не повторят (или пока ждущую задачу не отменят).
+
+// checkDependsLocked проверяет, что все задачи из t.DependsOn существуют.
+// Вызывать под a.mu (чтение).
+func (a *App) checkDependsLocked(t *core.Task) error {
+	for _, id := range t.DependsOn {
+		if _, ok := a.tasks.get(id); !ok {
+			return i18n.New(i18n.CodeDependsUnknown, id)
+		}
+	}
+	return nil
+}
+
+// unmetDependsLocked — ID зависимостей задачи t, которые ещё не выполнены.
+// Вызывать под a.mu.
+func (a *App) unmetDependsLocked(t *core.Task) []string {
+	var unmet []string
+	for _, id := range t.DependsOn {
+		dep, ok := a.tasks.get(id)
+		if !ok {
+			continue // зависимость удалили — ждать нечего
+		}
+		if dep.Status == core.TaskComplete || (dep.Status == core.TaskPartial && a.Conf.DependsAllowPartial) {
+			continue
+		}
+		unmet = append(unmet, id)
+	}
+	return unmet
+}
+
+// holdLocked откладывает файлы задачи t, если её зависимости не выполнены:
+// ставит Waiting и кладёт задачу в a.waiting. Возвращает невыполненные
+// зависимости (nil — задача не ждёт). Вызывать под a.mu.
+func (a *App) holdLocked(t *core.Task) []string {
+	unmet := a.unmetDependsLocked(t)
+	t.Waiting = len(unmet) > 0
+	if t.Waiting {
+		a.waiting[t.ID] = struct{}{}
+	} else {
+		delete(a.waiting, t.ID)
+	}
+	return unmet
+}
+
+// releaseLocked снимает задачу t с ожидания: пишет событие EventDependsMet
+// и собирает задания для её Pending-файлов. Вызывать под a.mu.
+func (a *App) releaseLocked(t *core.Task, now time.Time) *taskChange {
+	delete(a.waiting, t.ID)
+	t.Waiting = false
+	ch := &taskChange{jobs: queueJobsLocked(t)}
+	ch.events = append(ch.events, t.AddEvent(core.TaskEvent{
+		At:      now,
+		Type:    core.EventDependsMet,
+		Status:  string(t.Status),
+		Message: fmt.Sprintf("dependencies met (%s), %d file(s) queued", strings.Join(t.DependsOn, ", "), len(ch.jobs)),
+	}))
+	if ev, changed := t.RecomputeStatusEvent(now); changed {
+		ch.events = append(ch.events, ev)
+	}
+	ch.files = max(len(ch.jobs), 1) // событие пишется, даже если ставить в очередь нечего
+	ch.snap = t.Clone()
+	return ch
+}
+
+// releaseDependents ставит в очередь файлы задач, ждавших задачу id, если
+// все их зависимости теперь выполнены. Вызывается, когда задача id
+// завершилась или удалена.
+func (a *App) releaseDependents(id string) {
+	now := time.Now().UTC()
+	var changes []*taskChange
+	a.mu.Lock()
+	for wid := range a.waiting {
+		t, ok := a.tasks.get(wid)
+		if !ok {
+			delete(a.waiting, wid)
+			continue
+		}
+		if !dependsOn(t, id) || len(a.unmetDependsLocked(t)) > 0 {
+			continue
+		}
+		changes = append(changes, a.releaseLocked(t, now))
+	}
+	a.mu.Unlock()
+
+	for _, ch := range changes {
+		a.commit(ch)
+	}
+}
+
+// dependsOn сообщает, что задача t зависит от задачи id.
+func dependsOn(t *core.Task, id string) bool {
+	for _, d := range t.DependsOn {
+		if d == id {
+			return true
+		}
+	}
+	return false
+}
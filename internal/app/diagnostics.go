@@ -0,0 +1,79 @@
+package app
+
+import (
+	"log"
+	"runtime"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/downloader"
+	"github.com/Extrarius/29.09.2025/internal/queue"
+	"github.com/Extrarius/29.09.2025/internal/store"
+)
+
+// Diagnostics — снимок рантайма и внутреннего состояния сервиса
+// для отладки зависаний и утечек.
+type Diagnostics struct {
+	Uptime     string       `json:"uptime"`
+	Goroutines int          `json:"goroutines"`
+	HeapAlloc  uint64       `json:"heap_alloc_bytes"`
+	HeapInuse  uint64       `json:"heap_inuse_bytes"`
+	HeapSys    uint64       `json:"heap_sys_bytes"`
+	NumGC      uint32       `json:"num_gc"`
+	Tasks      int          `json:"tasks"`
+	Queue      queue.Stats  `json:"queue"`
+	Workers    []WorkerInfo `json:"workers"`
+}
+
+// Diagnostics собирает снимок рантайма: число горутин, статистику кучи,
+// заполненность очереди и текущие задания воркеров.
+// runtime.ReadMemStats кратковременно останавливает мир — не вызывайте его в горячем пути.
+func (a *App) Diagnostics() Diagnostics {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	tasks := a.tasks.len()
+
+	return Diagnostics{
+		Uptime:     time.Since(a.startedAt).Round(time.Second).String(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  ms.HeapAlloc,
+		HeapInuse:  ms.HeapInuse,
+		HeapSys:    ms.HeapSys,
+		NumGC:      ms.NumGC,
+		Tasks:      tasks,
+		Queue:      a.dispatcher.Stats(),
+		Workers:    a.Workers(),
+	}
+}
+
+// HostStats возвращает пер-хостовую статистику загрузчика.
+func (a *App) HostStats() []downloader.HostStats { return a.loader.HostStats() }
+
+// StoreStats возвращает состояние журнала (размер, число записей, возраст снимка)
+// и, если включено, хранилища BLOB_DIR (blob'ов и ссылок на них) и исходящей
+// очереди событий EVENTS_URL (неподтверждённых брокером событий).
+func (a *App) StoreStats() (store.Stats, error) {
+	st, err := a.wal.Stats()
+	if err == nil && a.blobs != nil {
+		bs := a.blobs.Stats()
+		st.Blobs = &bs
+	}
+	if err == nil && a.outbox != nil {
+		ob := a.outbox.Stats()
+		st.Outbox = &ob
+	}
+	return st, err
+}
+
+// CompactStore компактизирует журнал на работающем сервисе (store.WAL.Compact):
+// записи задач на время компактизации ждут, чтение задач не блокируется.
+func (a *App) CompactStore() (store.CompactStats, error) {
+	cs, err := a.wal.Compact()
+	if err != nil {
+		log.Printf("Store: compaction failed: %v", err)
+		return cs, err
+	}
+	log.Printf("Store: compacted %d → %d records, %d → %d bytes in %s",
+		cs.RecordsBefore, cs.RecordsAfter, cs.BytesBefore, cs.BytesAfter, cs.Duration)
+	return cs, nil
+}
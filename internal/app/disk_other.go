@@ -0,0 +1,10 @@
+//go:build !unix
+
+package app
+
+import "errors"
+
+// diskUsage на платформах без statfs не поддерживается.
+func diskUsage(path string) (free, total uint64, err error) {
+	return 0, 0, errors.New("disk usage is not supported on this platform")
+}
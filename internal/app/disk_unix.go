@@ -0,0 +1,15 @@
+//go:build unix
+
+package app
+
+import "syscall"
+
+// diskUsage возвращает свободное (доступное непривилегированному процессу)
+// и общее место файловой системы, на которой лежит path.
+func diskUsage(path string) (free, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), st.Blocks * uint64(st.Bsize), nil
+}
@@ -0,0 +1,266 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/notify"
+)
+
+// Защита свободного места (DISK_LOW_WATERMARK, DISK_HIGH_WATERMARK): сервис
+// раз в diskGuardInterval смотрит свободное место на разделе DownloadDir.
+// Меньше нижней границы — очередь уходит в drain (новые задания не выдаются,
+// идущие загрузки докачиваются) и уходит оповещение (DISK_GUARD_NOTIFY,
+// DISK_GUARD_EMAIL); больше верхней — выдача возобновляется сама и уходит
+// оповещение об отмене. Между границами состояние не меняется, так что
+// очередь не «дребезжит» у порога.
+//
+// Drain защиты независим от drain оператора (SetDrain) и окон обслуживания:
+// очередь стоит, пока действует хотя бы один из них. POST /admin/resume его
+// не снимает — место от этого не появится. Состояние в WAL не пишется:
+// после перезапуска место проверяется заново.
+
+// diskGuardInterval — период проверки свободного места; diskGuardRule — имя
+// правила в оповещениях.
+const (
+	diskGuardInterval = 10 * time.Second
+	diskGuardRule     = "disk_space"
+)
+
+// DiskGuardStatus — состояние защиты свободного места (HealthDetails.DiskGuard).
+type DiskGuardStatus struct {
+	HealthCheck
+	Path          string     `json:"path"`
+	LowWatermark  ByteSize   `json:"low_watermark"`
+	HighWatermark ByteSize   `json:"high_watermark"`
+	FreeBytes     uint64     `json:"free_bytes"`
+	Draining      bool       `json:"draining"`        // места меньше нижней границы: очередь в drain
+	Since         *time.Time `json:"since,omitempty"` // когда начался drain
+	CheckedAt     time.Time  `json:"checked_at"`
+}
+
+// diskGuard — проверка свободного места (diskGuardLoop).
+type diskGuard struct {
+	low, high ByteSize
+
+	mu    sync.Mutex
+	state DiskGuardStatus // под mu
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newDiskGuard создаёт защиту свободного места по границам из conf; nil —
+// DISK_LOW_WATERMARK не задан.
+func newDiskGuard(conf *Config) *diskGuard {
+	if conf.DiskLowWatermark <= 0 {
+		return nil
+	}
+	g := &diskGuard{
+		low:  conf.DiskLowWatermark,
+		high: conf.diskHighWatermark(),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	g.state = DiskGuardStatus{
+		HealthCheck:   HealthCheck{Status: HealthOK},
+		Path:          conf.DownloadDir,
+		LowWatermark:  g.low,
+		HighWatermark: g.high,
+	}
+	return g
+}
+
+// diskHighWatermark — действующая верхняя граница: DiskHighWatermark, а без
+// неё — нижняя (возобновление сразу, как место снова есть).
+func (c *Config) diskHighWatermark() ByteSize {
+	if c.DiskHighWatermark > 0 {
+		return c.DiskHighWatermark
+	}
+	return c.DiskLowWatermark
+}
+
+// validateDiskGuard проверяет границы защиты свободного места и получателей
+// её оповещений.
+func validateDiskGuard(c *Config, add func(format string, args ...any)) {
+	if c.DiskLowWatermark < 0 {
+		add("DISK_LOW_WATERMARK: должно быть >= 0 (0 — без защиты), получено %s", c.DiskLowWatermark)
+	}
+	if c.DiskHighWatermark < 0 {
+		add("DISK_HIGH_WATERMARK: должно быть >= 0 (0 — как DISK_LOW_WATERMARK), получено %s", c.DiskHighWatermark)
+	}
+	if c.DiskLowWatermark == 0 {
+		if c.DiskHighWatermark != 0 || len(c.DiskGuardNotify) > 0 || c.DiskGuardEmail != "" {
+			add("DISK_HIGH_WATERMARK/DISK_GUARD_NOTIFY/DISK_GUARD_EMAIL заданы без DISK_LOW_WATERMARK")
+		}
+		return
+	}
+	if c.DiskHighWatermark > 0 && c.DiskHighWatermark < c.DiskLowWatermark {
+		add("DISK_HIGH_WATERMARK: должно быть не меньше DISK_LOW_WATERMARK (%s), получено %s", c.DiskLowWatermark, c.DiskHighWatermark)
+	}
+	r := c.diskGuardAlertRule()
+	for _, n := range r.Notify {
+		if _, ok := c.Notifiers[n]; !ok {
+			add("DISK_GUARD_NOTIFY: уведомитель %q не задан в секции notifiers", n)
+		}
+	}
+	if r.Email != "" {
+		if _, err := core.NotifyRecipients(r.Email); err != nil {
+			add("DISK_GUARD_EMAIL: %v", err)
+		} else if c.SMTPURL == "" {
+			add("DISK_GUARD_EMAIL: почта не настроена (SMTP_URL)")
+		}
+	}
+}
+
+// diskGuardAlertRule — правило, от имени которого уходят оповещения защиты
+// (получатели — DiskGuardNotify и DiskGuardEmail).
+func (c *Config) diskGuardAlertRule() AlertRule {
+	return AlertRule{Name: diskGuardRule, Notify: c.DiskGuardNotify, Email: c.DiskGuardEmail}
+}
+
+// startDiskGuard запускает проверку свободного места (если она включена).
+func (a *App) startDiskGuard() {
+	if a.diskGuard == nil {
+		return
+	}
+	go a.diskGuardLoop()
+}
+
+// stopDiskGuard останавливает diskGuardLoop и ждёт его выхода.
+func (a *App) stopDiskGuard() {
+	g := a.diskGuard
+	if g == nil {
+		return
+	}
+	select {
+	case <-g.stop: // Close уже вызывали
+	default:
+		close(g.stop)
+	}
+	<-g.done
+}
+
+// diskGuardLoop каждые diskGuardInterval проверяет свободное место.
+func (a *App) diskGuardLoop() {
+	g := a.diskGuard
+	defer close(g.done)
+	tick := time.NewTicker(diskGuardInterval)
+	defer tick.Stop()
+	a.checkDiskGuard(time.Now())
+	for {
+		select {
+		case <-g.stop:
+			return
+		case now := <-tick.C:
+			a.checkDiskGuard(now)
+		}
+	}
+}
+
+// checkDiskGuard сверяет свободное место с границами в момент now: ниже
+// нижней — включает drain защиты, выше верхней — снимает; при смене
+// состояния пишет в лог и отправляет оповещение. Ошибка statfs состояния
+// не меняет.
+func (a *App) checkDiskGuard(now time.Time) {
+	g := a.diskGuard
+	now = now.UTC()
+	free, _, err := diskUsage(g.state.Path)
+	g.mu.Lock()
+	st := &g.state
+	st.CheckedAt = now
+	if err != nil {
+		st.Status, st.Message = HealthDegraded, fmt.Sprintf("statfs: %v", err)
+		g.mu.Unlock()
+		return
+	}
+	st.FreeBytes = free
+	draining := st.Draining
+	switch {
+	case !draining && free < uint64(g.low):
+		draining = true
+	case draining && free >= uint64(g.high):
+		draining = false
+	}
+	changed := draining != st.Draining
+	var since time.Time
+	if st.Since != nil {
+		since = *st.Since
+	}
+	st.Draining = draining
+	if draining {
+		st.Status, st.Message = HealthDegraded, fmt.Sprintf("low disk space: %d bytes free (below %s): new jobs are not dispatched", free, g.low)
+		if changed {
+			st.Since = &now
+		}
+	} else {
+		st.Status, st.Message, st.Since = HealthOK, "", nil
+	}
+	g.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	a.schedMu.Lock()
+	a.diskLow = draining
+	if a.modeRestored {
+		a.applyDrainLocked()
+	}
+	a.schedMu.Unlock()
+
+	value := fmt.Sprintf("%d bytes free on %s", free, g.state.Path)
+	if draining {
+		log.Printf("DiskGuard: %s, below %s: no new jobs are dispatched until %s is free", value, g.low, g.high)
+	} else {
+		log.Printf("DiskGuard: %s, above %s after %s: dispatching resumed", value, g.high, now.Sub(since).Round(time.Second))
+	}
+	if a.notifier != nil {
+		r := a.Conf.diskGuardAlertRule()
+		a.notifier.enqueueAlert(r, diskGuardMessage(g, draining, value, since, now))
+	}
+}
+
+// diskGuardMessage — текст оповещения о начале (draining) или конце drain
+// защиты свободного места. since — когда drain начался (для конца).
+func diskGuardMessage(g *diskGuard, draining bool, value string, since, now time.Time) notify.Message {
+	state := "RESOLVED"
+	if draining {
+		state = "FIRING"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Alert:     %s\n", diskGuardRule)
+	fmt.Fprintf(&b, "Condition: free space < %s (resume above %s)\n", g.low, g.high)
+	fmt.Fprintf(&b, "State:     %s\n", state)
+	fmt.Fprintf(&b, "Value:     %s\n", value)
+	if draining {
+		fmt.Fprintf(&b, "Since:     %s\n", now.Format(time.RFC3339))
+		b.WriteString("Action:    queue drained: no new jobs are dispatched, running downloads finish\n")
+	} else {
+		fmt.Fprintf(&b, "Fired:     %s (lasted %s)\n", since.Format(time.RFC3339), now.Sub(since).Round(time.Second))
+		b.WriteString("Action:    dispatching resumed\n")
+	}
+	return notify.Message{Subject: fmt.Sprintf("[downloader] %s: %s", state, diskGuardRule), Text: b.String()}
+}
+
+// diskLowDrain сообщает, держит ли очередь в drain защита свободного места.
+func (a *App) diskLowDrain() bool {
+	a.schedMu.Lock()
+	defer a.schedMu.Unlock()
+	return a.diskLow
+}
+
+// DiskGuard возвращает состояние защиты свободного места; nil — выключена.
+func (a *App) DiskGuard() *DiskGuardStatus {
+	g := a.diskGuard
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st := g.state
+	return &st
+}
@@ -0,0 +1,249 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/downloader"
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// Вердикты ссылок пробного прогона (LinkVerdict.Verdict).
+const (
+	VerdictOK          = "ok"          // будет скачана как есть
+	VerdictRenamed     = "renamed"     // путь занят (на диске или другой ссылкой задачи) — имя получит суффикс "-N"
+	VerdictDuplicate   = "duplicate"   // тот же URL, что у ссылки DuplicateOf: скачан ещё раз, слит с ней (dedupe) или задача отклонена (reject)
+	VerdictUnreachable = "unreachable" // пробный запрос (probe) не получил ответ 2xx
+	VerdictInvalid     = "invalid"     // ссылка не прошла валидацию: задача не будет создана
+)
+
+const (
+	// maxProbeLinks — наибольшее число ссылок пробного прогона с probe.
+	maxProbeLinks = 1000
+	// probeWorkers — сколько пробных запросов идёт одновременно.
+	probeWorkers = 8
+)
+
+// ErrTooManyProbes — пробный прогон с probe для задачи больше maxProbeLinks ссылок.
+var ErrTooManyProbes = errors.New("too many links to probe (max " + strconv.Itoa(maxProbeLinks) + ")")
+
+// LinkVerdict — что стало бы со ссылкой задачи (DryRunTask).
+type LinkVerdict struct {
+	Index       int                     `json:"index"`
+	URL         string                  `json:"url"`
+	Verdict     string                  `json:"verdict"`
+	Code        string                  `json:"code,omitempty"`         // причина VerdictInvalid (i18n.Code*)
+	Error       string                  `json:"error,omitempty"`        // сообщение о причине или ошибка probe
+	DuplicateOf *int                    `json:"duplicate_of,omitempty"` // индекс первой ссылки с тем же URL
+	DestPath    string                  `json:"dest_path,omitempty"`    // куда файл был бы сохранён сейчас
+	File        *core.FileItem          `json:"file,omitempty"`         // файл будущей задачи
+	Probe       *downloader.ProbeResult `json:"probe,omitempty"`
+
+	fe core.FieldError // ошибка валидации — для сообщения на другом языке (Localize)
+}
+
+// DryRun — результат пробного прогона задачи: ничего не создаётся и не ставится
+// в очередь. Valid — задача с этим телом была бы создана прямо сейчас.
+type DryRun struct {
+	Valid   bool              `json:"valid"`
+	DestDir string            `json:"dest_dir,omitempty"` // каталог задачи (или "s3://…")
+	Errors  []core.FieldError `json:"errors,omitempty"`   // ошибки уровня задачи: поля, sink, dest_dir, лимиты
+	Limit   *LimitError       `json:"limit,omitempty"`    // превышенный лимит (есть и в Errors)
+	Links   []LinkVerdict     `json:"links"`
+	Summary map[string]int    `json:"summary"` // вердикт → число ссылок
+}
+
+// Localize переводит сообщения ошибок результата на язык lang.
+func (d *DryRun) Localize(lang string) {
+	for i := range d.Errors {
+		d.Errors[i] = d.Errors[i].Localize(lang)
+	}
+	for i := range d.Links {
+		if l := &d.Links[i]; l.Verdict == VerdictInvalid {
+			l.Error = l.fe.Localize(lang).Error
+		}
+	}
+}
+
+// DryRunTask проверяет spec так же, как CreateTask, но ничего не создаёт:
+//   - валидация полей и ссылок (TaskSpec.FieldErrors с allowlist хостов) —
+//     невалидные ссылки получают VerdictInvalid с кодом и причиной;
+//   - правила сервиса (buildTask: sink, dest_dir), повторы URL при политике
+//     reject (applyDuplicates) и лимиты арендатора
+//     spec.Tenant (без учёта задачи в счётчике задач за час);
+//   - для каждой валидной ссылки — будущий файл (имя после очистки,
+//     dest_subpath по layout) и путь, под которым он был бы сохранён сейчас:
+//     занятые на диске или другой ссылкой задачи пути получают суффикс
+//     "-N", как у downloader.UniquePathIn (VerdictRenamed); повторы URL —
+//     VerdictDuplicate;
+//   - probe=true — пробный запрос к каждой валидной ссылке
+//     (downloader.Probe, не более probeWorkers одновременно); без ответа 2xx —
+//     VerdictUnreachable. Задача с недоступными ссылками всё равно Valid:
+//     при создании их обработают повторы.
+//
+// ErrTooManyProbes — probe для задачи больше maxProbeLinks ссылок.
+func (a *App) DryRunTask(ctx context.Context, spec core.TaskSpec, probe bool) (*DryRun, error) {
+	if probe && len(spec.Links) > maxProbeLinks {
+		return nil, ErrTooManyProbes
+	}
+	res := &DryRun{Links: make([]LinkVerdict, len(spec.Links)), Summary: make(map[string]int)}
+	for i, l := range spec.Links {
+		res.Links[i] = LinkVerdict{Index: i, URL: l.URL}
+	}
+	valid := spec
+	valid.Links = nil
+	var files []int // индекс ссылки spec.Links для каждого файла будущей задачи
+	for _, fe := range spec.FieldErrors(a.Conf.HostAllowed) {
+		if fe.Index == nil {
+			res.Errors = append(res.Errors, fe)
+			continue
+		}
+		l := &res.Links[*fe.Index]
+		l.Verdict, l.Code, l.Error, l.fe = VerdictInvalid, fe.Code, fe.Error, fe
+	}
+	for i, l := range spec.Links {
+		if res.Links[i].Verdict == "" {
+			valid.Links = append(valid.Links, l)
+			files = append(files, i)
+		}
+	}
+
+	if len(res.Errors) == 0 && len(valid.Links) > 0 {
+		task, err := a.buildTask(valid)
+		if err != nil {
+			res.Errors = append(res.Errors, core.NewFieldError(dryRunField(err), err))
+		} else {
+			res.DestDir = a.taskDestDir(task)
+			if a.duplicatePolicy(spec.Duplicates) == core.DuplicatesReject {
+				if err := a.applyDuplicates(task, core.DuplicatesReject, time.Now().UTC()); err != nil {
+					res.Errors = append(res.Errors, core.NewFieldError(dryRunField(err), err))
+				}
+			}
+			a.mu.Lock()
+			_, err = a.checkLimitsLocked(task, time.Now())
+			a.mu.Unlock()
+			var le *LimitError
+			if errors.As(err, &le) {
+				res.Limit = le
+				res.Errors = append(res.Errors, core.NewFieldError("limits", le))
+			}
+			a.planFiles(ctx, task, files, res.Links)
+		}
+	}
+	if probe {
+		a.probeLinks(ctx, spec.Links, res.Links)
+	}
+
+	for i := range res.Links {
+		if res.Links[i].Verdict == "" {
+			res.Links[i].Verdict = VerdictOK
+		}
+		res.Summary[res.Links[i].Verdict]++
+	}
+	res.Valid = len(res.Errors) == 0 && res.Summary[VerdictInvalid] == 0
+	return res, nil
+}
+
+// dryRunField — поле спецификации, к которому относится ошибка buildTask.
+func dryRunField(err error) string {
+	switch i18n.Code(err) {
+	case i18n.CodeSinkUnknown, i18n.CodeSinkObjectDest:
+		return "sink"
+	case i18n.CodeObjectStoreOff, i18n.CodeObjectDestDir:
+		return "dest_dir"
+	case i18n.CodeDependsUnknown:
+		return "depends_on"
+	case i18n.CodeNotifyNoSMTP:
+		return "notify_email"
+	case i18n.CodeNotifierUnknown:
+		return "notify"
+	case i18n.CodeDuplicateURL, i18n.CodeDuplicateRecent:
+		return "links"
+	}
+	return "task"
+}
+
+// planFiles заполняет у ссылок links (files[j] — индекс ссылки j-го файла
+// задачи task) будущий файл, путь сохранения и вердикты VerdictDuplicate /
+// VerdictRenamed.
+func (a *App) planFiles(ctx context.Context, task *core.Task, files []int, links []LinkVerdict) {
+	firstURL := make(map[string]int)
+	taken := make(map[string]bool)
+	for j, fi := range task.Files {
+		l := &links[files[j]]
+		l.File = fi
+		base := storage.Join(a.taskDestDir(task), fi.DestSubpath, fi.Filename)
+		l.DestPath = a.plannedPath(ctx, base, taken)
+		taken[l.DestPath] = true
+		if first, ok := firstURL[fi.URL]; ok {
+			l.Verdict, l.DuplicateOf = VerdictDuplicate, &first
+		} else {
+			firstURL[fi.URL] = files[j]
+			if l.DestPath != base {
+				l.Verdict = VerdictRenamed
+			}
+		}
+	}
+}
+
+// plannedPath — путь, который downloader.UniquePathIn выбрал бы для base,
+// если пути taken (другие файлы той же задачи) тоже заняты.
+func (a *App) plannedPath(ctx context.Context, base string, taken map[string]bool) string {
+	free := func(p string) bool {
+		if taken[p] {
+			return false
+		}
+		_, err := a.storage.Stat(ctx, p)
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	if free(base) {
+		return base
+	}
+	ext := filepath.Ext(base)
+	name := base[:len(base)-len(ext)]
+	for i := 1; i < 10000; i++ {
+		if p := name + "-" + strconv.Itoa(i) + ext; free(p) {
+			return p
+		}
+	}
+	return base + "-dup"
+}
+
+// probeLinks делает пробный запрос к каждой валидной ссылке (downloader.Probe)
+// и помечает недоступные VerdictUnreachable.
+func (a *App) probeLinks(ctx context.Context, specs []core.LinkSpec, links []LinkVerdict) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < probeWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				p := a.loader.Probe(ctx, downloader.Request{
+					URL:       specs[i].URL,
+					Headers:   specs[i].Headers,
+					Host:      specs[i].HostHeader,
+					ConnectTo: specs[i].ConnectTo,
+				})
+				links[i].Probe = &p
+				if !p.OK() {
+					links[i].Verdict, links[i].Error = VerdictUnreachable, p.Error
+				}
+			}
+		}()
+	}
+	for i := range links {
+		if links[i].Verdict != VerdictInvalid {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// Повторы URL при создании задачи (DUPLICATE_POLICY, поле duplicates):
+//   - allow — как раньше: повтор качается ещё раз и ложится рядом с суффиксом
+//     "-1" (трафик удваивается);
+//   - dedupe — повторы внутри задачи сливаются в один файл с несколькими
+//     именами (core.Task.MergeDuplicates, linkAliases), а ссылки, которые уже
+//     есть в задачах за DUPLICATE_WINDOW, не качаются: файл сразу SKIPPED
+//     с duplicate_of;
+//   - reject — задача с повтором (внутри или в недавней задаче) не создаётся:
+//     CodeDuplicateURL / CodeDuplicateRecent.
+//
+// Недавней задачей считается созданная за DUPLICATE_WINDOW; её упавшие,
+// отменённые и пропущенные файлы повторами не считаются.
+
+// duplicatePolicy — политика повторов задачи: policy из запроса, а без неё —
+// DUPLICATE_POLICY.
+func (a *App) duplicatePolicy(policy string) string {
+	if policy != "" {
+		return policy
+	}
+	if a.Conf.DuplicatePolicy != "" {
+		return a.Conf.DuplicatePolicy
+	}
+	return core.DuplicatesAllow
+}
+
+// applyDuplicates применяет к собранной задаче t (buildTask) политику
+// повторов policy. reject задачу не меняет; ошибка — задача не создаётся.
+func (a *App) applyDuplicates(t *core.Task, policy string, now time.Time) error {
+	if policy == core.DuplicatesAllow {
+		return nil
+	}
+	a.mu.RLock()
+	recent := a.recentURLsLocked(now)
+	a.mu.RUnlock()
+
+	if policy == core.DuplicatesReject {
+		first := make(map[string]int, len(t.Files))
+		for i, f := range t.Files {
+			if j, ok := first[f.SourceKey()]; ok {
+				return i18n.New(i18n.CodeDuplicateURL, i, j, f.URL)
+			}
+			first[f.SourceKey()] = i
+			if ref, ok := recent[f.SourceKey()]; ok {
+				return i18n.New(i18n.CodeDuplicateRecent, f.URL, ref.task)
+			}
+		}
+		return nil
+	}
+
+	t.MergeDuplicates()
+	skipped := 0
+	for _, f := range t.Files {
+		ref, ok := recent[f.SourceKey()]
+		if !ok || f.Transition(core.FileSkipped, "duplicate of task "+ref.task, now) != nil {
+			continue
+		}
+		f.DuplicateOf = ref.task + "/" + ref.file
+		skipped++
+	}
+	if skipped > 0 {
+		t.RecomputeStatus()
+	}
+	return nil
+}
+
+// fileRef — файл другой задачи.
+type fileRef struct{ task, file string }
+
+// recentURLsLocked — URL файлов задач (с диапазоном, FileItem.SourceKey),
+// созданных за DUPLICATE_WINDOW (кроме упавших, отменённых и пропущенных),
+// с первым найденным файлом. nil — окно не задано. Вызывать под a.mu на
+// чтение: задачи блокируются по одной.
+func (a *App) recentURLsLocked(now time.Time) map[string]fileRef {
+	if a.Conf.DuplicateWindow <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-a.Conf.DuplicateWindow)
+	urls := make(map[string]fileRef)
+	for _, t := range a.tasks.all() {
+		if t.CreatedAt.Before(cutoff) {
+			continue
+		}
+		t.Mutex().Lock()
+		for _, f := range t.Files {
+			switch f.State {
+			case core.FileFailed, core.FileCancelled, core.FileSkipped, core.FileExpired:
+				continue
+			}
+			if _, ok := urls[f.SourceKey()]; !ok {
+				urls[f.SourceKey()] = fileRef{t.ID, f.ID}
+			}
+		}
+		t.Mutex().Unlock()
+	}
+	return urls
+}
+
+// aliasPaths — пути псевдонимов (FileItem.Aliases) скачанного файла f в
+// каталоге задачи dir. У нескачанных файлов и задач в объектном хранилище — nil.
+func aliasPaths(dir string, f *core.FileItem) []string {
+	if f.State != core.FileDone || storage.IsObject(dir) {
+		return nil
+	}
+	paths := make([]string, len(f.Aliases))
+	for i, alias := range f.Aliases {
+		paths[i] = filepath.Join(dir, filepath.FromSlash(alias))
+	}
+	return paths
+}
+
+// linkAliases делает скачанный файл f задачи t доступным и под другими
+// запрошенными именами (FileItem.Aliases): жёсткой ссылкой, а если она
+// невозможна (другая файловая система) — копией. Занятые пути не
+// перезаписываются. Ошибки пишутся в лог и на состояние файла не влияют.
+func (a *App) linkAliases(t *core.Task, f *core.FileItem) {
+	if storage.IsObject(f.Path) {
+		return
+	}
+	for _, p := range aliasPaths(a.taskDestDir(t), f) {
+		if err := linkOrCopy(f.Path, p); err != nil {
+			log.Printf("Tasks: task %s file %s: alias %s: %v", t.ID, f.ID, p, err)
+		}
+	}
+}
+
+// linkOrCopy создаёт dst жёсткой ссылкой на src или его копией. Существующий
+// dst — ошибка fs.ErrExist.
+func linkOrCopy(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	err := os.Link(src, dst)
+	if err == nil || errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// Сроки задач (TaskSpec.ExpiresAt, см. core/expiry.go): к сроку не начатые
+// файлы задачи переходят в EXPIRED и убираются из очереди (expireTask).
+// Срабатывание ведёт expiryLoop — таймер до ближайшего срока; воркер, взявший
+// задание задачи с наступившим сроком, его не запускает и будит цикл, а
+// файл, упавший после срока, не повторяется. Сроки, наступившие, пока сервис
+// был остановлен, применяются при восстановлении (recoverFromWAL).
+
+// expiryMaxWait — предел ожидания expiryLoop между проверками (на случай
+// пропущенного пробуждения).
+const expiryMaxWait = time.Hour
+
+// startExpiry запускает expiryLoop.
+func (a *App) startExpiry() {
+	a.expiryWake = make(chan struct{}, 1)
+	a.expiryStop = make(chan struct{})
+	a.expiryDone = make(chan struct{})
+	go a.expiryLoop()
+}
+
+// stopExpiry останавливает expiryLoop и ждёт его выхода.
+func (a *App) stopExpiry() {
+	if a.expiryStop == nil {
+		return
+	}
+	close(a.expiryStop)
+	<-a.expiryDone
+	a.expiryStop = nil
+}
+
+// wakeExpiry будит expiryLoop: появилась задача со сроком или срок наступил.
+func (a *App) wakeExpiry() {
+	select {
+	case a.expiryWake <- struct{}{}:
+	default:
+	}
+}
+
+// expiryLoop применяет наступившие сроки задач и спит до ближайшего
+// следующего (не дольше expiryMaxWait) или до wakeExpiry.
+func (a *App) expiryLoop() {
+	defer close(a.expiryDone)
+	for {
+		wait := expiryMaxWait
+		if next := a.expireDue(time.Now().UTC()); !next.IsZero() && time.Until(next) < wait {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-a.expiryStop:
+			timer.Stop()
+			return
+		case <-a.expiryWake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// expireDue применяет сроки, наступившие к моменту now (expireTask), и
+// возвращает ближайший ещё не наступивший срок (нулевой — таких нет).
+// ExpiresAt не меняется после создания задачи — читается без её блокировки.
+func (a *App) expireDue(now time.Time) time.Time {
+	var (
+		due  []string
+		next time.Time
+	)
+	a.mu.RLock()
+	for _, t := range a.tasks.all() {
+		switch {
+		case t.ExpiresAt == nil:
+		case t.PastExpiry(now):
+			t.Mutex().Lock()
+			if t.Pending > 0 {
+				due = append(due, t.ID)
+			}
+			t.Mutex().Unlock()
+		case next.IsZero() || t.ExpiresAt.Before(next):
+			next = *t.ExpiresAt
+		}
+	}
+	a.mu.RUnlock()
+	for _, id := range due {
+		a.expireTask(id, now)
+	}
+	return next
+}
+
+// expireTask применяет наступивший срок задачи id (expireLocked), убирает её
+// задания из очереди (queue.Queue.Remove) и фиксирует изменение. Задача,
+// дошедшая так до PARTIAL, завершается как в workerLoop: опись и снятие
+// ожидания с зависимых задач.
+func (a *App) expireTask(id string, now time.Time) {
+	a.mu.Lock()
+	t, ok := a.tasks.get(id)
+	if !ok {
+		a.mu.Unlock()
+		return
+	}
+	ch := a.expireLocked(t, now)
+	a.mu.Unlock()
+	if ch.files == 0 {
+		return
+	}
+
+	removed := a.dispatcher.Remove(id)
+	a.commit(ch)
+	log.Printf("Expiry: task %s expired at %s: %d file(s) not started, %d job(s) removed from the queue%s",
+		id, ch.snap.ExpiresAt.Format(time.RFC3339), ch.files, removed, requestTag(ch.snap.RequestID))
+	if ch.snap.Status.Terminal() {
+		a.logins.drop(id)
+	}
+	if ch.snap.Status == core.TaskPartial {
+		a.writeManifest(ch.snap)
+		a.releaseDependents(id)
+	}
+}
+
+// expireLocked переводит не начатые файлы задачи t с наступившим к моменту
+// now сроком в Expired (core.Task.ExpirePending), снимает задачу с ожидания
+// зависимостей (ставить в очередь больше нечего) и собирает изменение с
+// событием EventExpired. Вызывать под a.mu (запись).
+func (a *App) expireLocked(t *core.Task, now time.Time) *taskChange {
+	ch := &taskChange{files: t.ExpirePending(now)}
+	if ch.files == 0 {
+		return ch
+	}
+	delete(a.waiting, t.ID)
+	t.Waiting = false
+	ch.events = append(ch.events, t.AddEvent(core.TaskEvent{
+		At:      now,
+		Type:    core.EventExpired,
+		Message: fmt.Sprintf("%d file(s) expired", ch.files),
+	}))
+	if ev, changed := t.RecomputeStatusEvent(now); changed {
+		ch.events = append(ch.events, ev)
+	}
+	ch.snap = t.Clone()
+	return ch
+}
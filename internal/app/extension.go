@@ -0,0 +1,44 @@
+package app
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/downloader"
+)
+
+// fixExtension возвращает downloader.Request.OnContentType для загрузки файла
+// fi задачи t в *destPath — или nil, если FIX_EXTENSIONS выключен.
+//
+// Обработчик запоминает тип содержимого в fi.ContentType и, если расширение
+// имени из *destPath ему не подходит (core.FixExtension), сохраняет файл под
+// свободным (downloader.UniquePathIn) исправленным именем в том же каталоге и
+// исправляет fi.Filename (имя при скачивании через API, ключ в sink, опись).
+// Итоговый путь записывается в *destPath, чтобы воркер отметил в FileItem.Path
+// именно его. Имя считается от исходного пути и при повторных вызовах в
+// следующих попытках.
+func (a *App) fixExtension(ctx context.Context, t *core.Task, fi *core.FileItem, destPath *string) func(string) string {
+	if !a.Conf.FixExtensions {
+		return nil
+	}
+	orig := *destPath
+	i := strings.LastIndexByte(orig, '/') + 1
+	dir, name := orig[:i], orig[i:]
+	return func(contentType string) string {
+		fixed := core.FixExtension(name, contentType)
+		a.lockTask(t)
+		fi.ContentType = contentType
+		if fixed != name {
+			fi.Filename = core.FixExtension(fi.Filename, contentType)
+		}
+		a.unlockTask(t)
+		*destPath = orig
+		if fixed != name {
+			*destPath = downloader.UniquePathIn(ctx, a.storage, dir+fixed)
+			log.Printf("Task %s: file %s is %s, saving as %s%s", t.ID, fi.ID, contentType, *destPath, requestTag(t.RequestID))
+		}
+		return *destPath
+	}
+}
@@ -0,0 +1,309 @@
+package app
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/storage"
+	"github.com/Extrarius/29.09.2025/internal/store"
+)
+
+// DefaultGCMinAge — файлы моложе этого уборка не трогает (GCOptions.MinAge).
+const DefaultGCMinAge = time.Hour
+
+// maxGCList — сколько удалённых путей перечисляется в GCReport.Removed.
+const maxGCList = 1000
+
+// GCOptions — параметры уборки каталога загрузок (GC, App.CollectGarbage).
+type GCOptions struct {
+	MinAge time.Duration // файлы с временем изменения новее now-MinAge не трогаются; 0 — DefaultGCMinAge
+	DryRun bool          // только отчёт: ничего не удаляется
+}
+
+// GCReport — итог уборки каталога загрузок.
+type GCReport struct {
+	DryRun         bool     `json:"dry_run"`
+	OrphanFiles    int      `json:"orphan_files"`    // файлов, не принадлежащих ни одной задаче
+	PartFiles      int      `json:"part_files"`      // брошенных недокачанных .part
+	EmptyDirs      int      `json:"empty_dirs"`      // пустых каталогов
+	Blobs          int      `json:"blobs"`           // blob'ов BLOB_DIR без ссылок
+	BytesReclaimed int64    `json:"bytes_reclaimed"` // освобождено (при dry_run — освободилось бы)
+	Removed        []string `json:"removed"`         // удалённые пути (не больше maxGCList)
+	Truncated      bool     `json:"truncated,omitempty"`
+	Errors         []string `json:"errors,omitempty"` // пути, которые не удалось обойти или удалить
+}
+
+// add учитывает удалённый путь.
+func (r *GCReport) add(path string, size int64) {
+	r.BytesReclaimed += size
+	if len(r.Removed) < maxGCList {
+		r.Removed = append(r.Removed, path)
+	} else {
+		r.Truncated = true
+	}
+}
+
+// gcIndex — что в каталоге загрузок принадлежит задачам.
+type gcIndex struct {
+	files    map[string]bool // скачанные файлы задач
+	taskDirs map[string]bool // каталоги задач: их опись (TASK_MANIFEST) не трогается
+	busy     map[string]bool // каталоги файлов PENDING/RUNNING и все их предки
+}
+
+// newGCIndex строит индекс по задачам tasks. Пути — абсолютные.
+func newGCIndex(downloadDir string, tasks []*core.Task) *gcIndex {
+	ix := &gcIndex{files: make(map[string]bool), taskDirs: make(map[string]bool), busy: make(map[string]bool)}
+	for _, t := range tasks {
+		dir := destDirOf(downloadDir, t)
+		if storage.IsObject(dir) {
+			continue
+		}
+		dir = absPath(dir)
+		ix.taskDirs[dir] = true
+		for _, f := range t.Files {
+			if f.Path != "" && !storage.IsObject(f.Path) {
+				ix.files[absPath(f.Path)] = true
+			}
+			for _, p := range aliasPaths(dir, f) {
+				ix.files[p] = true
+			}
+			if f.State == core.FilePending || f.State == core.FileRunning {
+				// файл ещё может появиться здесь — под любым именем
+				for d := filepath.Dir(filepath.Join(dir, filepath.FromSlash(f.DestSubpath), "x")); !ix.busy[d]; d = filepath.Dir(d) {
+					ix.busy[d] = true
+					if filepath.Dir(d) == d {
+						break
+					}
+				}
+			}
+		}
+	}
+	return ix
+}
+
+// absPath — абсолютный очищенный путь p (p, если его не удалось получить).
+func absPath(p string) string {
+	if a, err := filepath.Abs(p); err == nil {
+		return a
+	}
+	return filepath.Clean(p)
+}
+
+// GC убирает каталог загрузок сервиса, который не запущен (downloader gc):
+// tasks — задачи, восстановленные из WAL. См. App.CollectGarbage.
+func GC(conf *Config, tasks map[string]*core.Task, opts GCOptions) (GCReport, error) {
+	var blobs *store.BlobStore
+	if conf.BlobDir != "" {
+		var err error
+		if blobs, err = store.OpenBlobStore(conf.BlobDir); err != nil {
+			return GCReport{}, err
+		}
+		for _, t := range tasks {
+			for _, f := range t.Files {
+				blobs.Retain(f.Blob)
+			}
+		}
+	}
+	index := func() *gcIndex { return newGCIndex(conf.DownloadDir, slices.Collect(maps.Values(tasks))) }
+	return collectGarbage(conf, index, nil, blobs, opts)
+}
+
+// CollectGarbage убирает из DOWNLOAD_DIR то, что не принадлежит ни одной
+// задаче, — чтобы каталог не рос бесконечно:
+//   - файлы удалённых задач и задач, удалённых в обход сервиса (каталог
+//     задачи, которой нет в WAL, удаляется целиком);
+//   - брошенные недокачанные файлы .part (сбой, удаление задачи посреди загрузки),
+//     в том числе в PART_DIR;
+//   - опустевшие каталоги задач и dest_subpath;
+//   - при включённом BLOB_DIR — blob'ы, на которые не ссылается ни один файл
+//     (store.BlobStore.Sweep).
+//
+// Не трогаются: файлы новее opts.MinAge (загрузка могла только
+// начаться), скачанные файлы задач, опись задачи (manifest.json,
+// checksums.sha256), чужие файлы в каталогах, где у задачи ещё есть
+// незавершённые файлы, сам DOWNLOAD_DIR и вложенные в него DATA_DIR,
+// BLOB_DIR, WATCH_DIR, QUARANTINE_DIR и PART_DIR (в нём — только .part). Каталоги задач вне DOWNLOAD_DIR (dest_dir) не
+// обходятся. Кандидаты ищутся без блокировки, а удаляются под a.mu после
+// повторной сверки с задачами: файл, который за это время стал файлом
+// задачи, останется.
+func (a *App) CollectGarbage(opts GCOptions) (GCReport, error) {
+	index := func() *gcIndex { return newGCIndex(a.Conf.DownloadDir, a.tasks.all()) }
+	rep, err := collectGarbage(&a.Conf, index, &a.mu, a.blobs, opts)
+	if err == nil {
+		verb := "reclaimed"
+		if rep.DryRun {
+			verb = "would reclaim"
+		}
+		log.Printf("GC: %d orphan file(s), %d .part file(s), %d empty dir(s), %d blob(s), %s %d bytes",
+			rep.OrphanFiles, rep.PartFiles, rep.EmptyDirs, rep.Blobs, verb, rep.BytesReclaimed)
+	}
+	return rep, err
+}
+
+// gcCandidate — файл, который уборка может удалить.
+type gcCandidate struct {
+	path string
+	size int64
+}
+
+// collectGarbage — общая часть GC и App.CollectGarbage. index строит
+// индекс задач; вызывается под lock (nil — без блокировки).
+func collectGarbage(conf *Config, index func() *gcIndex, lock sync.Locker, blobs *store.BlobStore, opts GCOptions) (GCReport, error) {
+	if opts.MinAge <= 0 {
+		opts.MinAge = DefaultGCMinAge
+	}
+	rep := GCReport{DryRun: opts.DryRun, Removed: []string{}}
+	cutoff := time.Now().Add(-opts.MinAge)
+	root := absPath(conf.DownloadDir)
+	skip := make(map[string]bool)
+	for _, d := range []string{conf.DataDir, conf.BlobDir, conf.WatchDir, conf.QuarantineDir, conf.PartDir} {
+		if d != "" {
+			skip[absPath(d)] = true
+		}
+	}
+
+	var (
+		cands   []gcCandidate
+		dirs    []string
+		entries = make(map[string]int) // каталог → сколько в нём записей
+	)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			rep.Errors = append(rep.Errors, err.Error())
+			entries[filepath.Dir(p)]++ // необойдённое не считается пустым
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if p == root {
+			return nil
+		}
+		entries[filepath.Dir(p)]++
+		if d.IsDir() {
+			if skip[p] {
+				return fs.SkipDir
+			}
+			dirs = append(dirs, p)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !d.Type().IsRegular() || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		cands = append(cands, gcCandidate{path: p, size: info.Size()})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil // каталог загрузок ещё не создан — убирать нечего
+	}
+	if err != nil {
+		return GCReport{}, err
+	}
+	if conf.PartDir != "" {
+		cands = append(cands, staleParts(absPath(conf.PartDir), cutoff, &rep)...)
+	}
+
+	if lock != nil {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	ix := index()
+	remove := func(p string) bool {
+		if opts.DryRun {
+			return true
+		}
+		if err := os.Remove(p); err != nil {
+			rep.Errors = append(rep.Errors, err.Error())
+			return false
+		}
+		return true
+	}
+	for _, c := range cands {
+		dir := filepath.Dir(c.path)
+		if ix.files[c.path] {
+			continue
+		}
+		part := strings.HasSuffix(c.path, ".part")
+		if !part {
+			if ix.busy[dir] {
+				continue
+			}
+			if name := filepath.Base(c.path); ix.taskDirs[dir] && (name == ManifestFile || name == ChecksumsFile) {
+				continue
+			}
+		}
+		if !remove(c.path) {
+			continue
+		}
+		if part {
+			rep.PartFiles++
+		} else {
+			rep.OrphanFiles++
+		}
+		rep.add(c.path, c.size)
+		entries[dir]--
+	}
+
+	// вложенные каталоги — раньше родителей: опустевший родитель тоже уходит
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, d := range dirs {
+		if entries[d] > 0 || ix.busy[d] {
+			continue
+		}
+		if !remove(d) {
+			continue
+		}
+		rep.EmptyDirs++
+		rep.add(d, 0)
+		entries[filepath.Dir(d)]--
+	}
+
+	if blobs != nil {
+		removed, n, err := blobs.Sweep(cutoff, opts.DryRun)
+		if err != nil {
+			rep.Errors = append(rep.Errors, err.Error())
+		}
+		rep.Blobs = len(removed)
+		rep.BytesReclaimed += n
+		for _, p := range removed {
+			rep.add(p, 0)
+		}
+	}
+	return rep, nil
+}
+
+// staleParts — файлы .part в каталоге dir (PART_DIR), не менявшиеся с cutoff:
+// идущая загрузка пишет в свой .part постоянно.
+func staleParts(dir string, cutoff time.Time, rep *GCReport) []gcCandidate {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			rep.Errors = append(rep.Errors, err.Error())
+		}
+		return nil
+	}
+	var out []gcCandidate
+	for _, d := range des {
+		if !d.Type().IsRegular() || !strings.HasSuffix(d.Name(), ".part") {
+			continue
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().Before(cutoff) {
+			out = append(out, gcCandidate{path: filepath.Join(dir, d.Name()), size: info.Size()})
+		}
+	}
+	return out
+}
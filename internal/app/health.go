@@ -0,0 +1,206 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/queue"
+)
+
+// Статусы проверок HealthDetails.
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+)
+
+// Пороги, при которых проверка переходит в HealthDegraded.
+const (
+	healthMinFreeBytes   = 1 << 30          // свободного места меньше 1 ГиБ…
+	healthMinFreePercent = 5.0              // …или меньше 5% раздела
+	healthQueueSaturated = 0.9              // входной канал очереди заполнен на 90%
+	healthStallAfter     = 15 * time.Minute // есть работа, но ни одной успешной загрузки столько времени
+)
+
+// HealthCheck — результат одной проверки.
+type HealthCheck struct {
+	Status  string `json:"status"`            // HealthOK | HealthDegraded
+	Message string `json:"message,omitempty"` // причина деградации или ошибка проверки
+}
+
+// degrade переводит проверку в HealthDegraded; первая причина сохраняется.
+func (c *HealthCheck) degrade(format string, args ...any) {
+	if c.Status == HealthDegraded {
+		return
+	}
+	c.Status = HealthDegraded
+	c.Message = fmt.Sprintf(format, args...)
+}
+
+// DiskCheck — свободное место на разделе каталога.
+type DiskCheck struct {
+	HealthCheck
+	Path        string  `json:"path"`
+	FreeBytes   uint64  `json:"free_bytes"`
+	TotalBytes  uint64  `json:"total_bytes"`
+	FreePercent float64 `json:"free_percent"`
+}
+
+// QueueCheck — заполненность очереди и загрузка воркеров.
+// Saturation — доля заполнения входного канала (0..1): при 1 постановка
+// новых заданий блокируется.
+type QueueCheck struct {
+	HealthCheck
+	queue.Stats
+	Saturation   float64 `json:"saturation"`
+	WorkersBusy  int     `json:"workers_busy"`
+	WorkersTotal int     `json:"workers_total"`
+	Maintenance  string  `json:"maintenance,omitempty"` // окно обслуживания, держащее drain
+	DiskLow      bool    `json:"disk_low,omitempty"`    // drain держит защита свободного места (diskguard.go)
+}
+
+// LastDownloadCheck — время последней успешной загрузки файла
+// (с момента запуска процесса; nil — ещё не было).
+type LastDownloadCheck struct {
+	HealthCheck
+	At  *time.Time `json:"at,omitempty"`
+	Age string     `json:"age,omitempty"`
+}
+
+// HealthDetails — ответ GET /healthz/details. Status = HealthDegraded,
+// если деградировала хотя бы одна проверка.
+type HealthDetails struct {
+	Status       string            `json:"status"`
+	Ready        bool              `json:"ready"`
+	CheckedAt    time.Time         `json:"checked_at"`
+	WAL          HealthCheck       `json:"wal"`
+	DataDisk     DiskCheck         `json:"data_disk"`
+	DownloadDisk DiskCheck         `json:"download_disk"`
+	Queue        QueueCheck        `json:"queue"`
+	LastDownload LastDownloadCheck `json:"last_download"`
+	Recovery     RecoveryStatus    `json:"recovery"`
+	Leader       *LeaderStatus     `json:"leader,omitempty"`     // выбор лидера (LEADER_LEASE); нет — выключен
+	DiskGuard    *DiskGuardStatus  `json:"disk_guard,omitempty"` // защита свободного места (DISK_LOW_WATERMARK); нет — выключена
+}
+
+// HealthDetails выполняет «глубокие» проверки состояния сервиса:
+//   - WAL — журнал пишется (store.WAL.Check) и в DataDir можно создать файл;
+//   - свободное место на разделах DataDir и DownloadDir (не меньше
+//     healthMinFreeBytes и healthMinFreePercent);
+//   - очередь — входной канал заполнен меньше чем на healthQueueSaturated,
+//     не включён drain и внешняя очередь (QUEUE_URL) отвечает;
+//   - защита свободного места (DISK_LOW_WATERMARK, если включена) — места
+//     не меньше нижней границы, очередь не в её drain (diskguard.go);
+//   - последняя успешная загрузка — не старше healthStallAfter, если в очереди
+//     или у воркеров есть работа (простаивающий сервис не считается деградировавшим).
+//
+// Проверки дешёвые (без обхода задач и файлов) — подходит для частого опроса.
+func (a *App) HealthDetails() HealthDetails {
+	now := time.Now().UTC()
+	h := HealthDetails{
+		Ready:        a.Ready(),
+		CheckedAt:    now,
+		WAL:          a.checkWAL(),
+		DataDisk:     checkDisk(a.Conf.DataDir),
+		DownloadDisk: checkDisk(a.Conf.DownloadDir),
+		Queue:        a.checkQueue(),
+		Recovery:     a.Recovery(),
+		Leader:       a.LeaderStatus(),
+		DiskGuard:    a.DiskGuard(),
+	}
+	h.LastDownload = a.checkLastDownload(now, h.Queue)
+
+	h.Status = HealthOK
+	for _, c := range []HealthCheck{h.WAL, h.DataDisk.HealthCheck, h.DownloadDisk.HealthCheck,
+		h.Queue.HealthCheck, h.LastDownload.HealthCheck} {
+		if c.Status != HealthOK {
+			h.Status = HealthDegraded
+		}
+	}
+	if h.DiskGuard != nil && h.DiskGuard.Status != HealthOK {
+		h.Status = HealthDegraded
+	}
+	return h
+}
+
+// checkWAL проверяет журнал и пробной записью — каталог DataDir
+// (например, раздел перемонтирован только на чтение).
+func (a *App) checkWAL() HealthCheck {
+	c := HealthCheck{Status: HealthOK}
+	if err := a.wal.Check(); err != nil {
+		c.degrade("wal: %v", err)
+		return c
+	}
+	f, err := os.CreateTemp(a.Conf.DataDir, ".healthz-*")
+	if err != nil {
+		c.degrade("data dir is not writable: %v", err)
+		return c
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return c
+}
+
+// checkDisk сообщает свободное место на разделе каталога dir.
+func checkDisk(dir string) DiskCheck {
+	c := DiskCheck{HealthCheck: HealthCheck{Status: HealthOK}, Path: dir}
+	free, total, err := diskUsage(dir)
+	if err != nil {
+		c.degrade("statfs: %v", err)
+		return c
+	}
+	c.FreeBytes, c.TotalBytes = free, total
+	if total > 0 {
+		c.FreePercent = float64(free) * 100 / float64(total)
+	}
+	if free < healthMinFreeBytes || c.FreePercent < healthMinFreePercent {
+		c.degrade("low disk space: %d bytes free (%.1f%%)", free, c.FreePercent)
+	}
+	return c
+}
+
+// checkQueue оценивает заполненность очереди и занятость воркеров.
+func (a *App) checkQueue() QueueCheck {
+	c := QueueCheck{HealthCheck: HealthCheck{Status: HealthOK}, Stats: a.dispatcher.Stats(), Maintenance: a.Maintenance(), DiskLow: a.diskLowDrain()}
+	if c.InboundCap > 0 {
+		c.Saturation = float64(c.Inbound) / float64(c.InboundCap)
+	}
+	for _, w := range a.Workers() {
+		c.WorkersTotal++
+		if w.Busy {
+			c.WorkersBusy++
+		}
+	}
+	switch {
+	case c.Error != "":
+		c.degrade("queue backend: %s", c.Error)
+	case c.Saturation >= healthQueueSaturated:
+		c.degrade("queue is saturated: %d/%d inbound jobs", c.Inbound, c.InboundCap)
+	case c.Drain && c.DiskLow:
+		c.degrade("low disk space in %s: new jobs are not dispatched", a.Conf.DownloadDir)
+	case c.Drain && c.Maintenance != "":
+		c.degrade("maintenance window %s: new jobs are not dispatched", c.Maintenance)
+	case c.Drain:
+		c.degrade("drain is enabled: new jobs are not dispatched")
+	}
+	return c
+}
+
+// checkLastDownload — «нет прогресса»: работа есть, а успешных загрузок
+// не было дольше healthStallAfter (отсчёт — от последней успешной загрузки
+// или от запуска процесса).
+func (a *App) checkLastDownload(now time.Time, q QueueCheck) LastDownloadCheck {
+	c := LastDownloadCheck{HealthCheck: HealthCheck{Status: HealthOK}}
+	since := a.startedAt
+	if ns := a.lastSuccess.Load(); ns != 0 {
+		at := time.Unix(0, ns).UTC()
+		c.At = &at
+		c.Age = now.Sub(at).Round(time.Second).String()
+		since = at
+	}
+	busy := q.WorkersBusy > 0 || q.Inbound+q.Backlog+q.Outbound > 0
+	if busy && !q.Drain && now.Sub(since) > healthStallAfter {
+		c.degrade("no successful downloads for %s while work is pending", now.Sub(since).Round(time.Second))
+	}
+	return c
+}
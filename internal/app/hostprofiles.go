@@ -0,0 +1,138 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/downloader"
+)
+
+// HostProfile — настройки загрузок с хостов, подходящих под шаблон (секция
+// hosts файла конфигурации, Config.HostProfiles): особенности источника
+// задаются один раз и применяются ко всем его файлам без указания в задачах.
+// Шаблон — точное имя или "*.example.com"; из подходящих берётся самый
+// конкретный (core.LookupHost). Лимит параллельности профиля — в
+// Config.HostLimits (hosts.<шаблон>.concurrency).
+type HostProfile struct {
+	Headers      map[string]string // заголовки запросов к хосту; заголовки ссылки важнее
+	Bandwidth    Bandwidth         // ограничение скорости загрузок с одного хоста; 0 — только общее
+	Proxy        string            // прокси (http://, https://, socks5://); пусто — по окружению (HTTP_PROXY)
+	MaxAttempts  int               // попыток на файл вместо RETRIES (если не задано у ссылки); 0 — RETRIES
+	RetryBackoff time.Duration     // пауза перед первым автоповтором вместо RETRY_BACKOFF; 0 — RETRY_BACKOFF
+}
+
+// validateHostProfiles проверяет профили хостов: шаблон — имя хоста или
+// "*.домен", заголовки — как у ссылок (core.ValidateHeader), прокси —
+// http(s):// или socks5:// с хостом, Bandwidth и RetryBackoff >= 0,
+// MaxAttempts — 0..core.MaxLinkAttempts.
+func validateHostProfiles(c *Config, add func(format string, args ...any)) {
+	patterns := make([]string, 0, len(c.HostProfiles))
+	for p := range c.HostProfiles {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		p := c.HostProfiles[pattern]
+		if name := strings.TrimPrefix(pattern, "*."); name == "" || strings.ContainsAny(name, "*/ ") {
+			add("hosts: шаблон %q: ожидается имя хоста или \"*.домен\"", pattern)
+		}
+		for name, value := range p.Headers {
+			if err := core.ValidateHeader(name, value); err != nil {
+				add("hosts.%s.headers: %v", pattern, err)
+			}
+		}
+		if p.Bandwidth < 0 {
+			add("hosts.%s.bandwidth: должно быть >= 0, получено %v", pattern, p.Bandwidth)
+		}
+		if p.Proxy != "" {
+			if _, err := parseProxy(p.Proxy); err != nil {
+				add("hosts.%s.proxy: %v", pattern, err)
+			}
+		}
+		if p.MaxAttempts < 0 || p.MaxAttempts > core.MaxLinkAttempts {
+			add("hosts.%s.max_attempts: ожидается 0..%d, получено %d", pattern, core.MaxLinkAttempts, p.MaxAttempts)
+		}
+		if p.RetryBackoff < 0 {
+			add("hosts.%s.retry_backoff: должно быть >= 0, получено %s", pattern, p.RetryBackoff)
+		}
+	}
+}
+
+// parseProxy разбирает адрес прокси профиля: http://, https:// или socks5://
+// с хостом (логин и пароль — в адресе).
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("ожидается http://, https:// или socks5://, получено %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("не задан адрес прокси")
+	}
+	return u, nil
+}
+
+// DownloaderProfiles переводит профили хостов в настройки загрузчика
+// (downloader.Options.HostProfiles: заголовки, скорость, прокси); Validate
+// уже проверил адреса прокси.
+func (c *Config) DownloaderProfiles() map[string]downloader.HostProfile {
+	if len(c.HostProfiles) == 0 {
+		return nil
+	}
+	out := make(map[string]downloader.HostProfile, len(c.HostProfiles))
+	for pattern, p := range c.HostProfiles {
+		dp := downloader.HostProfile{Headers: p.Headers, Bandwidth: int64(p.Bandwidth)}
+		if p.Proxy != "" {
+			dp.Proxy, _ = parseProxy(p.Proxy)
+		}
+		out[strings.ToLower(pattern)] = dp
+	}
+	return out
+}
+
+// hostProfile возвращает профиль хоста host (пустой, если ни один не подошёл).
+func (a *App) hostProfile(host string) HostProfile {
+	p, _ := core.LookupHost(a.Conf.HostProfiles, host)
+	return p
+}
+
+// profileAttempts возвращает ссылки links, у которых не задан max_attempts,
+// с числом попыток из профиля их хоста (HostProfile.MaxAttempts). Исходный
+// срез не меняется.
+func (a *App) profileAttempts(links []core.LinkSpec) []core.LinkSpec {
+	if len(a.Conf.HostProfiles) == 0 {
+		return links
+	}
+	out := make([]core.LinkSpec, len(links))
+	for i, l := range links {
+		if l.MaxAttempts == 0 {
+			if u, err := url.Parse(l.URL); err == nil {
+				l.MaxAttempts = a.hostProfile(u.Host).MaxAttempts
+			}
+		}
+		out[i] = l
+	}
+	return out
+}
+
+// retryBackoff — пауза перед автоповтором файла с хоста host после failures
+// неудач: retryDelay с RETRY_BACKOFF или паузой из профиля хоста.
+func (a *App) retryBackoff(host string, failures int) time.Duration {
+	base, limit := a.Conf.RetryBackoff, a.Conf.RetryBackoffMax
+	if p := a.hostProfile(host); p.RetryBackoff > 0 {
+		base = p.RetryBackoff
+		if limit < base {
+			limit = base
+		}
+	}
+	return retryDelay(base, limit, failures)
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/metrics"
+	"github.com/Extrarius/29.09.2025/internal/nats"
+)
+
+// Тела подтверждений JetStream (публикуются в Msg.Reply вида "$JS.ACK.…").
+const (
+	jsAck  = "+ACK"  // сообщение обработано
+	jsNak  = "-NAK"  // повторить доставку позже
+	jsTerm = "+TERM" // не доставлять больше: сообщение не станет корректным
+)
+
+// Паузы между переподключениями к брокеру: от ingestRetryMin, вдвое больше
+// после каждой неудачи, не больше ingestRetryMax.
+const (
+	ingestRetryMin = time.Second
+	ingestRetryMax = 30 * time.Second
+)
+
+// ingestMetrics — счётчики приёма задач из брокера (GET /metrics).
+type ingestMetrics struct {
+	accepted metrics.Counter // задача создана и журнал сброшен на диск
+	rejected metrics.Counter // сообщение некорректно (+TERM / ответ с ошибкой)
+	deferred metrics.Counter // временный отказ — лимит или ошибка журнала (-NAK)
+}
+
+// ingestTarget — разобранный INGEST_URL.
+type ingestTarget struct {
+	url     string // адрес сервера без параметров
+	subject string
+	queue   string
+}
+
+// parseIngestURL разбирает INGEST_URL: nats://[user:password@|token@]host[:port]?subject=...&queue=...
+// subject обязателен, queue — группа очереди (экземпляры с одной группой делят сообщения).
+func parseIngestURL(raw string) (ingestTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ingestTarget{}, err
+	}
+	switch u.Scheme {
+	case "nats":
+	case "kafka":
+		return ingestTarget{}, errors.New("Kafka не поддерживается: используйте NATS (например, через мост Kafka → NATS)")
+	default:
+		return ingestTarget{}, fmt.Errorf("ожидается nats://, получено %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return ingestTarget{}, errors.New("не задан адрес сервера")
+	}
+	q := u.Query()
+	t := ingestTarget{subject: q.Get("subject"), queue: q.Get("queue")}
+	if t.subject == "" || strings.ContainsAny(t.subject, " \t\r\n") {
+		return ingestTarget{}, fmt.Errorf("ожидается параметр subject без пробелов, получено %q", t.subject)
+	}
+	if strings.ContainsAny(t.queue, " \t\r\n") {
+		return ingestTarget{}, fmt.Errorf("параметр queue не должен содержать пробелов: %q", t.queue)
+	}
+	u.RawQuery = ""
+	t.url = u.String()
+	return t, nil
+}
+
+// startIngest запускает приём задач из брокера (Conf.IngestURL, если задан) в фоне.
+// Вызывается после восстановления — как и опрос WatchDir. Остановка — stopIngest.
+func (a *App) startIngest() {
+	if a.Conf.IngestURL == "" {
+		return
+	}
+	target, err := parseIngestURL(a.Conf.IngestURL)
+	if err != nil {
+		log.Printf("Ingest: %v", err) // Validate уже проверил адрес
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.ingestStop = cancel
+	a.ingestDone = make(chan struct{})
+	go a.ingestLoop(ctx, target)
+}
+
+// stopIngest останавливает приём и ждёт выхода ingestLoop. Сообщение, чьё
+// подтверждение не успело уйти, брокер доставит повторно.
+func (a *App) stopIngest() {
+	if a.ingestStop == nil {
+		return
+	}
+	a.ingestStop()
+	<-a.ingestDone
+	a.ingestStop = nil
+}
+
+// ingestLoop держит подписку на subject брокера и создаёт задачи из сообщений.
+// Соединение рвётся — переподключается с нарастающей паузой.
+func (a *App) ingestLoop(ctx context.Context, t ingestTarget) {
+	defer close(a.ingestDone)
+	pause := ingestRetryMin
+	for {
+		err := a.ingestSession(ctx, t)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Ingest: %s: %v; reconnecting in %s", t.subject, err, pause)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pause):
+		}
+		pause = min(pause*2, ingestRetryMax)
+	}
+}
+
+// ingestSession — одно подключение: подписка и обработка сообщений до ошибки
+// соединения или отмены ctx.
+func (a *App) ingestSession(ctx context.Context, t ingestTarget) error {
+	dctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	conn, err := nats.Dial(dctx, t.url, "downloader")
+	cancel()
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+	if err := conn.Subscribe(t.subject, t.queue); err != nil {
+		return err
+	}
+	log.Printf("Ingest: subscribed to %s (queue %q)", t.subject, t.queue)
+	for {
+		msg, err := conn.Next()
+		if err != nil {
+			return err
+		}
+		if reply, body := a.ingestMessage(msg); reply != "" {
+			if err := conn.Publish(reply, body); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ingestMessage создаёт задачу из сообщения и возвращает, что ответить и куда:
+//   - тело — core.TaskSpec, как у POST /tasks (строгий разбор, те же проверки);
+//   - задача подтверждается только после того, как она записана в журнал
+//     и журнал сброшен на диск (store.WAL.Sync);
+//   - JetStream (Reply "$JS.ACK.…"): +ACK — задача создана, +TERM — сообщение
+//     некорректно, -NAK — временная причина (лимит, ошибка журнала), будет повтор;
+//   - запрос NATS (обычный Reply): ответ {"task_id": ...} или {"error": ...};
+//   - без Reply подтверждать нечего — исход только в логе и метриках.
+//
+// Доставка — «хотя бы один раз»: потерянное подтверждение приведёт к повторной
+// доставке и второй задаче.
+func (a *App) ingestMessage(msg nats.Msg) (reply string, body []byte) {
+	jetStream := strings.HasPrefix(msg.Reply, "$JS.ACK.")
+	respond := func(ack string, res map[string]any) (string, []byte) {
+		if msg.Reply == "" {
+			return "", nil
+		}
+		if jetStream {
+			return msg.Reply, []byte(ack)
+		}
+		b, _ := json.Marshal(res)
+		return msg.Reply, b
+	}
+
+	var spec core.TaskSpec
+	var fieldErrs []core.FieldError
+	dec := json.NewDecoder(bytes.NewReader(msg.Data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&spec)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after JSON object")
+	}
+	if err == nil {
+		if fieldErrs = spec.FieldErrors(a.Conf.HostAllowed); len(fieldErrs) > 0 {
+			err = fmt.Errorf("validation failed: %s: %s", fieldErrs[0].Field, fieldErrs[0].Error)
+		}
+	}
+	var task *core.Task
+	if err == nil {
+		task, err = a.CreateTask(spec)
+	}
+	var le *LimitError
+	switch {
+	case errors.As(err, &le) && le.Temporary():
+		a.ingest.deferred.Inc()
+		log.Printf("Ingest: %s: deferred: %v", msg.Subject, err)
+		return respond(jsNak, map[string]any{"error": err.Error(), "temporary": true})
+	case err != nil:
+		a.ingest.rejected.Inc()
+		log.Printf("Ingest: %s: rejected: %v", msg.Subject, err)
+		res := map[string]any{"error": err.Error()}
+		if len(fieldErrs) > 0 {
+			res["errors"] = fieldErrs
+		}
+		return respond(jsTerm, res)
+	}
+	if err := a.wal.Sync(); err != nil {
+		a.ingest.deferred.Inc()
+		log.Printf("Ingest: %s: task %s not confirmed: %v", msg.Subject, task.ID, err)
+		return respond(jsNak, map[string]any{"error": "wal: " + err.Error(), "temporary": true})
+	}
+	a.ingest.accepted.Inc()
+	log.Printf("Ingest: %s → task %s (%d files)", msg.Subject, task.ID, len(task.Files))
+	return respond(jsAck, map[string]any{"task_id": task.ID})
+}
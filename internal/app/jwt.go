@@ -0,0 +1,18 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Extrarius/29.09.2025/internal/auth"
+)
+
+// VerifyJWT проверяет bearer-токен издателя JWT_ISSUER (подпись по JWKS,
+// iss/aud/exp) и возвращает клиента с ролью и арендатором из claims.
+// Если JWT не настроены — ошибка.
+func (a *App) VerifyJWT(ctx context.Context, token string) (auth.Principal, error) {
+	if a.jwt == nil {
+		return auth.Principal{}, errors.New("jwt authentication is not configured")
+	}
+	return a.jwt.Verify(ctx, token)
+}
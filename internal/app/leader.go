@@ -0,0 +1,247 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/queue"
+	"github.com/Extrarius/29.09.2025/internal/redis"
+)
+
+// LeaderStatus — состояние выбора лидера (GET /healthz/details, поле leader).
+type LeaderStatus struct {
+	Instance string     `json:"instance"`         // идентификатор этого экземпляра
+	Leader   bool       `json:"leader"`           // экземпляр активен: держит аренду
+	Holder   string     `json:"holder,omitempty"` // кто держит аренду (у резервного)
+	Since    *time.Time `json:"since,omitempty"`  // с какого момента экземпляр — лидер
+}
+
+// leaderElection — выбор единственного активного экземпляра (LEADER_LEASE)
+// среди экземпляров с общим хранилищем: аренда ключа <prefix>:leader в Redis
+// очереди QUEUE_URL (redis.Lease).
+type leaderElection struct {
+	lease *redis.Lease
+	cli   *redis.Client
+	ttl   time.Duration
+
+	startMu sync.Mutex // упорядочивает запуск восстановления лидером и Close
+
+	mu     sync.Mutex
+	status LeaderStatus // под mu
+
+	stop chan struct{} // закрывается для остановки leaderLoop
+	done chan struct{} // закрывается leaderLoop при выходе
+}
+
+// newLeaderElection готовит выбор лидера по адресу очереди rawURL
+// с длительностью аренды ttl. Подключение к Redis — лениво, в leaderLoop.
+func newLeaderElection(rawURL string, ttl time.Duration) (*leaderElection, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := queue.KeyPrefix(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	id := instanceID()
+	cli := redis.New(opts)
+	return &leaderElection{
+		lease:  redis.NewLease(cli, prefix+":leader", id, ttl),
+		cli:    cli,
+		ttl:    ttl,
+		status: LeaderStatus{Instance: id},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// instanceID — идентификатор экземпляра: хост, PID и случайный суффикс
+// (перезапущенный процесс — новый претендент).
+func instanceID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// LeaderStatus возвращает состояние выбора лидера; nil — выбор выключен
+// (LEADER_LEASE не задан).
+func (a *App) LeaderStatus() *LeaderStatus {
+	if a.leader == nil {
+		return nil
+	}
+	a.leader.mu.Lock()
+	defer a.leader.mu.Unlock()
+	st := a.leader.status
+	return &st
+}
+
+// startLeader запускает выбор лидера вместо немедленного восстановления
+// (вызывается из New при заданном LeaderLease). До получения аренды экземпляр —
+// резервный: HTTP-сервер работает, но состояние из WAL не читается (/readyz — 503,
+// API задач недоступен), а очередь в режиме drain — задания не забираются.
+func (a *App) startLeader() {
+	a.dispatcher.Drain(true) // режим выдачи восстановит restoreScheduler лидера
+	a.recovery.standby(time.Now())
+	go a.leaderLoop()
+}
+
+// stopLeader останавливает leaderLoop и снимает аренду — вызывается из Close
+// последним, когда WAL уже закрыт: до этого момента аренда продлевается,
+// и другой экземпляр не начнёт работу с тем же хранилищем раньше времени.
+func (a *App) stopLeader() {
+	if a.leader == nil || a.leader.stop == nil {
+		return
+	}
+	close(a.leader.stop)
+	<-a.leader.done
+	a.leader.stop = nil
+}
+
+// haltLeader не даёт leaderLoop запустить восстановление после начала
+// Close: после возврата восстановление либо уже запущено (и его остановит
+// stopRecovery), либо не будет запущено вовсе.
+func (a *App) haltLeader() {
+	if a.leader == nil {
+		return
+	}
+	a.leader.startMu.Lock()
+	a.leader.startMu.Unlock()
+}
+
+// fenceLeader останавливает работу экземпляра, потерявшего аренду, раньше
+// всего остального: запрещает запись в WAL (store.WAL.Fence) и отменяет все
+// идущие загрузки (и те, что воркеры начнут после этого), чтобы ни журнал,
+// ни общее хранилище не получили ничего после того, как их начал вести новый
+// лидер.
+func (a *App) fenceLeader() {
+	a.wal.Fence()
+	a.imu.Lock()
+	a.fenced = true
+	cancels := make([]context.CancelFunc, 0, len(a.inflight))
+	for _, cancel := range a.inflight {
+		cancels = append(cancels, cancel)
+	}
+	a.imu.Unlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+}
+
+// leaderLoop ждёт аренду и, получив её, запускает восстановление (startRecovery)
+// и продлевает аренду каждую треть ttl:
+//   - аренда у другого — ждёт; Redis недоступен — пробует снова;
+//   - продлить не удалось (аренду забрали или Redis не отвечал две трети
+//     ttl) — экземпляр останавливает работу (fenceLeader) ещё до того, как
+//     ключ аренды истечёт в Redis, перестаёт забирать задания (drain) и
+//     завершает Serve с ошибкой: работу продолжит новый лидер, а
+//     перезапущенный процесс станет резервным;
+//   - при остановке (stopLeader) лидер снимает аренду.
+func (a *App) leaderLoop() {
+	l := a.leader
+	defer close(l.done)
+	defer l.cli.Close()
+	tick := time.NewTicker(l.ttl / 3)
+	defer tick.Stop()
+	lastHolder, lastErr := "", ""
+	// renewedAt — начало последнего удачного получения или продления (ключ
+	// истекает не раньше renewedAt+ttl); нулевое — не лидер. Работа
+	// останавливается к renewedAt+ttl-margin — с запасом до истечения ключа.
+	var renewedAt time.Time
+	margin := l.ttl / 3
+	for {
+		start := time.Now()
+		deadline := start.Add(l.ttl / 3)
+		if d := renewedAt.Add(l.ttl - margin); !renewedAt.IsZero() && d.Before(deadline) {
+			deadline = d // продление, не успевшее к этому сроку, уже не поможет
+		}
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		if renewedAt.IsZero() {
+			ok, holder, err := l.lease.Acquire(ctx)
+			cancel()
+			switch {
+			case err != nil:
+				if err.Error() != lastErr {
+					log.Printf("Leader: acquire lease: %v", err)
+					lastErr = err.Error()
+				}
+			case !ok:
+				lastErr = ""
+				l.setStatus(false, holder)
+				if holder != lastHolder {
+					log.Printf("Leader: standby, lease held by %s", holder)
+					lastHolder = holder
+				}
+			default:
+				renewedAt = start
+				l.setStatus(true, holder)
+				log.Printf("Leader: %s acquired the lease — starting", l.lease.ID())
+				l.startMu.Lock()
+				if !a.stopping.Load() {
+					a.startRecovery()
+				}
+				l.startMu.Unlock()
+			}
+		} else {
+			ok, err := l.lease.Renew(ctx)
+			cancel()
+			switch {
+			case ok:
+				renewedAt = start
+			case err != nil && time.Since(renewedAt) < l.ttl-margin:
+				log.Printf("Leader: renew lease: %v", err)
+			default:
+				a.fenceLeader()
+				if err == nil {
+					err = errors.New("lease taken over by another instance")
+				}
+				a.schedMu.Lock()
+				a.modeRestored = false // окна обслуживания больше не снимут drain
+				a.schedMu.Unlock()
+				a.dispatcher.Drain(true)
+				l.setStatus(false, "")
+				log.Printf("Leader: lost leadership: %v", err)
+				select {
+				case a.fatal <- fmt.Errorf("leader lease lost: %w", err):
+				default:
+				}
+				<-l.stop // повторно не претендуем: состояние уже загружено, процесс завершается
+				return
+			}
+		}
+		select {
+		case <-l.stop:
+			if !renewedAt.IsZero() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				if err := l.lease.Release(ctx); err != nil {
+					log.Printf("Leader: release lease: %v", err)
+				}
+				cancel()
+			}
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// setStatus обновляет LeaderStatus.
+func (l *leaderElection) setStatus(leader bool, holder string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.status.Holder = holder
+	switch {
+	case !leader:
+		l.status.Leader, l.status.Since = false, nil
+	case !l.status.Leader:
+		now := time.Now().UTC()
+		l.status.Leader, l.status.Since = true, &now
+	}
+}
@@ -0,0 +1,190 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// Limits — структурные лимиты приёма задач. 0 — без ограничения.
+// Начальные значения — из конфигурации (MAX_*), на ходу меняются через
+// PATCH /admin/limits (App.SetLimits) до перезапуска сервиса.
+type Limits struct {
+	LinksPerTask          int `json:"links_per_task"`           // ссылок в одной задаче
+	PendingFilesPerTenant int `json:"pending_files_per_tenant"` // незавершённых (PENDING/RUNNING) файлов у арендатора
+	TasksPerHour          int `json:"tasks_per_hour"`           // новых задач арендатора за скользящий час
+}
+
+// Validate проверяет, что лимиты неотрицательны.
+func (l Limits) Validate() error {
+	for _, f := range []struct {
+		name string
+		v    int
+	}{{"links_per_task", l.LinksPerTask}, {"pending_files_per_tenant", l.PendingFilesPerTenant}, {"tasks_per_hour", l.TasksPerHour}} {
+		if f.v < 0 {
+			return fmt.Errorf("%s: должно быть >= 0 (0 — без ограничения), получено %d", f.name, f.v)
+		}
+	}
+	return nil
+}
+
+// Имена лимитов (LimitError.Limit) — совпадают с JSON-полями Limits.
+const (
+	LimitLinksPerTask          = "links_per_task"
+	LimitPendingFilesPerTenant = "pending_files_per_tenant"
+	LimitTasksPerHour          = "tasks_per_hour"
+)
+
+// LimitError — задача отклонена из-за лимита. Превышение links_per_task —
+// свойство самой задачи (повтор не поможет); остальные лимиты временные
+// (RetryAfter — когда освободится место, если это можно предсказать).
+type LimitError struct {
+	Limit      string        `json:"limit"`
+	Tenant     string        `json:"tenant,omitempty"`
+	Max        int           `json:"max"`
+	Current    int           `json:"current"`   // уже занято (для links_per_task — ссылок в задаче)
+	Requested  int           `json:"requested"` // сколько просила задача
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *LimitError) Error() string { return e.Localize(i18n.RU) }
+
+// ErrorCode — код ошибки (i18n.CodeLimit*).
+func (e *LimitError) ErrorCode() string { return e.coded().Code }
+
+// Localize — сообщение на языке lang (i18n.Localizer).
+func (e *LimitError) Localize(lang string) string { return e.coded().Localize(lang) }
+
+func (e *LimitError) coded() *i18n.Error {
+	switch e.Limit {
+	case LimitLinksPerTask:
+		return i18n.New(i18n.CodeLimitLinks, e.Requested, e.Max)
+	case LimitPendingFilesPerTenant:
+		return i18n.New(i18n.CodeLimitPending, e.Current, e.Requested, e.Max)
+	case LimitTasksPerHour:
+		return i18n.New(i18n.CodeLimitTasksHour, e.Current, e.Max)
+	}
+	return i18n.New(i18n.CodeLimitExceeded, e.Limit)
+}
+
+// Temporary сообщает, что лимит освободится со временем (HTTP 429, а не 422).
+func (e *LimitError) Temporary() bool { return e.Limit != LimitLinksPerTask }
+
+// TenantUsage — текущее потребление лимитов арендатором (GET /admin/limits).
+type TenantUsage struct {
+	PendingFiles int `json:"pending_files"`
+	TasksPerHour int `json:"tasks_last_hour"`
+}
+
+// Limits возвращает действующие лимиты.
+func (a *App) Limits() Limits {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.limits
+}
+
+// SetLimits заменяет действующие лимиты (уже принятые задачи не затрагиваются).
+func (a *App) SetLimits(l Limits) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	old := a.limits
+	a.limits = l
+	a.mu.Unlock()
+	log.Printf("Limits: changed from %+v to %+v", old, l)
+	return nil
+}
+
+// LimitUsage возвращает потребление лимитов по арендаторам
+// (пустой ключ — клиенты без арендатора: ключи API, открытый API, WATCH_DIR).
+func (a *App) LimitUsage() map[string]TenantUsage {
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	usage := make(map[string]TenantUsage)
+	for _, t := range a.tasks.all() {
+		u := usage[t.Tenant]
+		u.PendingFiles += unfinishedFiles(t)
+		usage[t.Tenant] = u
+	}
+	for tenant := range a.tenantCreates {
+		u := usage[tenant]
+		u.TasksPerHour = len(a.recentCreatesLocked(tenant, now))
+		usage[tenant] = u
+	}
+	return usage
+}
+
+// admitLocked проверяет лимиты для новой задачи t и при успехе учитывает её
+// в счётчике задач за час. Вызывать под a.mu (вместе с регистрацией задачи —
+// чтобы параллельные запросы не проскочили лимит вдвоём).
+func (a *App) admitLocked(t *core.Task, now time.Time) error {
+	recent, err := a.checkLimitsLocked(t, now)
+	if err != nil {
+		return err
+	}
+	a.tenantCreates[t.Tenant] = append(recent, now)
+	return nil
+}
+
+// checkLimitsLocked — проверка лимитов из admitLocked без учёта задачи t
+// (её же использует пробный прогон, DryRunTask). Возвращает моменты создания
+// задач арендатора за последний час. Вызывать под a.mu (на запись).
+func (a *App) checkLimitsLocked(t *core.Task, now time.Time) ([]time.Time, error) {
+	l := a.limits
+	if l.LinksPerTask > 0 && len(t.Files) > l.LinksPerTask {
+		return nil, &LimitError{Limit: LimitLinksPerTask, Tenant: t.Tenant, Max: l.LinksPerTask, Requested: len(t.Files)}
+	}
+	if l.PendingFilesPerTenant > 0 {
+		pending := 0
+		for _, other := range a.tasks.all() {
+			if other.Tenant == t.Tenant {
+				pending += unfinishedFiles(other)
+			}
+		}
+		if add := unfinishedFiles(t); pending+add > l.PendingFilesPerTenant {
+			return nil, &LimitError{Limit: LimitPendingFilesPerTenant, Tenant: t.Tenant, Max: l.PendingFilesPerTenant, Current: pending, Requested: add}
+		}
+	}
+	recent := a.recentCreatesLocked(t.Tenant, now)
+	if l.TasksPerHour > 0 && len(recent) >= l.TasksPerHour {
+		// место освободится, когда самая старая из учтённых задач выйдет из окна
+		return nil, &LimitError{
+			Limit: LimitTasksPerHour, Tenant: t.Tenant, Max: l.TasksPerHour,
+			Current: len(recent), Requested: 1, RetryAfter: recent[len(recent)-l.TasksPerHour].Add(time.Hour).Sub(now),
+		}
+	}
+	return recent, nil
+}
+
+// recentCreatesLocked возвращает моменты создания задач арендатора за последний
+// час, заодно выбрасывая более старые. Вызывать под a.mu (на запись).
+func (a *App) recentCreatesLocked(tenant string, now time.Time) []time.Time {
+	times := a.tenantCreates[tenant]
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= time.Hour {
+		i++
+	}
+	times = times[i:]
+	if len(times) == 0 {
+		delete(a.tenantCreates, tenant)
+		return nil
+	}
+	a.tenantCreates[tenant] = times
+	return times
+}
+
+// unfinishedFiles — число файлов задачи в PENDING или RUNNING.
+func unfinishedFiles(t *core.Task) int {
+	n := 0
+	for _, f := range t.Files {
+		if f.State == core.FilePending || f.State == core.FileRunning {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// lockoutSweepSize — при таком числе отслеживаемых клиентов устаревшие записи
+// вычищаются (защита от роста карты при переборе с множества адресов).
+const lockoutSweepSize = 4096
+
+// authLockout считает неудачные попытки входа в админку по клиенту (IP)
+// и временно блокирует клиента после max неудач подряд в пределах окна d.
+// max <= 0 — блокировка выключена (попытки только логируются).
+type authLockout struct {
+	mu      sync.Mutex
+	max     int
+	d       time.Duration
+	clients map[string]*lockoutEntry
+}
+
+type lockoutEntry struct {
+	failures int
+	first    time.Time // первая неудача в текущем окне
+	until    time.Time // заблокирован до (нулевое — не заблокирован)
+}
+
+func newAuthLockout(max int, d time.Duration) *authLockout {
+	return &authLockout{max: max, d: d, clients: make(map[string]*lockoutEntry)}
+}
+
+// AdminLocked сообщает, заблокирован ли client после неудачных попыток
+// входа, и сколько ещё продлится блокировка.
+func (a *App) AdminLocked(client string) (time.Duration, bool) {
+	l := a.adminLockout
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := l.clients[client]
+	if e == nil || e.until.IsZero() {
+		return 0, false
+	}
+	left := time.Until(e.until)
+	if left <= 0 {
+		delete(l.clients, client)
+		return 0, false
+	}
+	return left, true
+}
+
+// AdminAuthFailed учитывает неудачную попытку входа client (user — присланный
+// логин, для лога) и блокирует клиента на AdminLockoutDuration после
+// AdminLockoutAttempts неудач. Каждая неудача и каждая блокировка логируются.
+func (a *App) AdminAuthFailed(client, user string) {
+	l := a.adminLockout
+	now := time.Now()
+	l.mu.Lock()
+	if len(l.clients) >= lockoutSweepSize {
+		l.sweep(now)
+	}
+	e := l.clients[client]
+	if e == nil || now.Sub(e.first) > l.d {
+		e = &lockoutEntry{first: now}
+		l.clients[client] = e
+	}
+	e.failures++
+	n := e.failures
+	locked := l.max > 0 && n >= l.max
+	if locked {
+		e.until = now.Add(l.d)
+	}
+	l.mu.Unlock()
+
+	if l.max <= 0 {
+		log.Printf("Admin auth: failed attempt from %s (user %q)", client, user)
+		return
+	}
+	log.Printf("Admin auth: failed attempt %d/%d from %s (user %q)", n, l.max, client, user)
+	if locked {
+		log.Printf("Admin auth: %s locked out for %s after %d failed attempts", client, l.d, n)
+	}
+}
+
+// AdminAuthSucceeded сбрасывает счётчик неудач client.
+func (a *App) AdminAuthSucceeded(client string) {
+	l := a.adminLockout
+	l.mu.Lock()
+	delete(l.clients, client)
+	l.mu.Unlock()
+}
+
+// sweep удаляет записи с истёкшими окном и блокировкой. Вызывать под l.mu.
+func (l *authLockout) sweep(now time.Time) {
+	for c, e := range l.clients {
+		if now.Sub(e.first) > l.d && now.After(e.until) {
+			delete(l.clients, c)
+		}
+	}
+}
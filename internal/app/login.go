@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// Вход на сайт-источник (TaskSpec.Login): перед первой загрузкой задачи
+// воркер выполняет запрос входа (downloader.Login), и полученная сессия
+// (cookie jar) уходит со всеми загрузками задачи. Вход — один на задачу:
+// воркеры, одновременно взявшие её файлы, ждут одного запроса. Сессии
+// живут только в памяти: после перезапуска сервиса, а также после
+// завершения задачи (повтор файлов, verify) вход выполняется заново.
+//
+// Неудачный вход запоминается на loginRetryDelay: файлы, взятые в это
+// время, завершаются той же ошибкой (и уходят на обычный автоповтор), а не
+// штурмуют форму входа неверным паролем.
+
+// loginRetryDelay — сколько помнится неудачный вход задачи.
+const loginRetryDelay = 30 * time.Second
+
+// loginSessions — сессии входа задач по ID. Нулевое значение готово к работе.
+type loginSessions struct {
+	mu sync.Mutex
+	m  map[string]*loginSession
+}
+
+// loginSession — сессия входа одной задачи.
+type loginSession struct {
+	mu       sync.Mutex // удерживается на время запроса входа
+	jar      http.CookieJar
+	err      error // последняя неудачная попытка входа
+	failedAt time.Time
+}
+
+// get возвращает сессию задачи id, создавая пустую при первом обращении.
+func (s *loginSessions) get(id string) *loginSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ls, ok := s.m[id]
+	if !ok {
+		if s.m == nil {
+			s.m = make(map[string]*loginSession)
+		}
+		ls = &loginSession{}
+		s.m[id] = ls
+	}
+	return ls
+}
+
+// drop забывает сессию задачи id.
+func (s *loginSessions) drop(id string) {
+	s.mu.Lock()
+	delete(s.m, id)
+	s.mu.Unlock()
+}
+
+// taskSession возвращает сессию входа задачи t для загрузки её файла,
+// выполняя вход при необходимости; nil без ошибки — у задачи нет Login.
+// t.Login не меняется после создания задачи, блокировка задачи не нужна.
+func (a *App) taskSession(ctx context.Context, t *core.Task) (http.CookieJar, error) {
+	if t.Login == nil {
+		return nil, nil
+	}
+	ls := a.logins.get(t.ID)
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.jar != nil {
+		return ls.jar, nil
+	}
+	if ls.err != nil && time.Since(ls.failedAt) < loginRetryDelay {
+		return nil, ls.err
+	}
+	jar, err := a.loader.Login(ctx, *t.Login)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err // загрузку отменили — вход не виноват
+		}
+		ls.err, ls.failedAt = err, time.Now()
+		log.Printf("Task %s: %v%s", t.ID, err, requestTag(t.RequestID))
+		return nil, err
+	}
+	ls.jar, ls.err = jar, nil
+	log.Printf("Task %s: logged in at %s%s", t.ID, t.Login.Host(), requestTag(t.RequestID))
+	return jar, nil
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Окна обслуживания (секция maintenance файла конфигурации) — повторяющиеся
+// периоды, на которые очередь сама уходит в drain: новые задания не выдаются,
+// идущие загрузки докачиваются; по окончании окна выдача возобновляется.
+// Внешний cron с POST /admin/drain и /admin/resume для этого не нужен.
+//
+// Drain оператора (SetDrain) и окно обслуживания независимы: очередь стоит,
+// пока действует хотя бы одно из них. POST /admin/resume посреди окна снимает
+// drain до конца этого окна; окно, начавшееся после — снова в силе. Режим
+// оператора пишется в WAL, окна — нет: после перезапуска посреди окна очередь
+// снова стоит до его конца.
+
+// MaintenanceWindow — окно обслуживания. From и To — минуты от полуночи
+// (To <= From — окно переходит через полночь); Days — дни недели начала окна,
+// пусто — каждый день.
+type MaintenanceWindow struct {
+	From, To int
+	Days     []time.Weekday
+}
+
+// weekdayNames — имена дней недели в конфигурации (индекс — time.Weekday).
+var weekdayNames = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseWeekday разбирает день недели: "mon", "tue", … "sun" (регистр не важен,
+// допускаются полные английские имена).
+func ParseWeekday(s string) (time.Weekday, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	for d, name := range weekdayNames {
+		if v == name || v == strings.ToLower(time.Weekday(d).String()) {
+			return time.Weekday(d), nil
+		}
+	}
+	return 0, fmt.Errorf("ожидается день недели mon..sun, получено %q", s)
+}
+
+// String — окно в виде "sat,sun 02:00-04:00" (без дней — каждый день).
+func (w MaintenanceWindow) String() string {
+	clock := formatClock(w.From) + "-" + formatClock(w.To)
+	if len(w.Days) == 0 {
+		return clock
+	}
+	days := make([]string, len(w.Days))
+	for i, d := range w.Days {
+		days[i] = weekdayNames[d]
+	}
+	return strings.Join(days, ",") + " " + clock
+}
+
+// contains сообщает, идёт ли окно в момент now (местное время). Часть окна
+// после полуночи относится ко дню его начала.
+func (w MaintenanceWindow) contains(now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+	day := now.Weekday()
+	switch {
+	case w.From < w.To:
+		if minute < w.From || minute >= w.To {
+			return false
+		}
+	case minute >= w.From:
+	case minute < w.To:
+		day = (day + 6) % 7 // окно началось накануне
+	default:
+		return false
+	}
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// validateMaintenance проверяет окна обслуживания: время в пределах суток,
+// непустое окно, известные дни недели.
+func validateMaintenance(windows []MaintenanceWindow, add func(format string, args ...any)) {
+	for i, w := range windows {
+		if w.From < 0 || w.From >= 24*60 || w.To < 0 || w.To >= 24*60 {
+			add("maintenance[%d]: время вне суток (%d, %d минут)", i, w.From, w.To)
+			continue
+		}
+		if w.From == w.To {
+			add("maintenance[%d]: пустое окно %s", i, formatClock(w.From)+"-"+formatClock(w.To))
+		}
+		for _, d := range w.Days {
+			if d < time.Sunday || d > time.Saturday {
+				add("maintenance[%d].days: некорректный день недели %d", i, d)
+			}
+		}
+	}
+}
+
+// maintenanceAt возвращает окно обслуживания, идущее в момент now
+// (первое подходящее); пусто — окна нет.
+func (c *Config) maintenanceAt(now time.Time) string {
+	for _, w := range c.Maintenance {
+		if w.contains(now) {
+			return w.String()
+		}
+	}
+	return ""
+}
+
+// maintenanceState — текущее окно обслуживания; под schedMu.
+type maintenanceState struct {
+	window string // идущее окно; пусто — вне окон
+	lifted bool   // оператор снял drain посреди окна (SetDrain(false))
+}
+
+// Maintenance возвращает идущее окно обслуживания, если оно держит очередь
+// в drain; пусто — не держит.
+func (a *App) Maintenance() string {
+	a.schedMu.Lock()
+	defer a.schedMu.Unlock()
+	if a.maint.lifted {
+		return ""
+	}
+	return a.maint.window
+}
+
+// applyMaintenance сверяет окно обслуживания с моментом now: в начале окна
+// включает drain, в конце — снимает (если drain не включал оператор).
+// До restoreScheduler только запоминает окно — режим выдачи применит он.
+func (a *App) applyMaintenance(now time.Time) {
+	win := a.Conf.maintenanceAt(now)
+	a.schedMu.Lock()
+	defer a.schedMu.Unlock()
+	if win == a.maint.window {
+		return
+	}
+	prev := a.maint
+	a.maint = maintenanceState{window: win}
+	if !a.modeRestored {
+		return
+	}
+	switch {
+	case win != "":
+		log.Printf("Maintenance: window %s started: no new jobs are dispatched, running downloads finish", win)
+	case a.drainManual:
+		log.Printf("Maintenance: window %s ended; drain stays on until POST /admin/resume", prev.window)
+	case !prev.lifted:
+		log.Printf("Maintenance: window %s ended: dispatching resumed", prev.window)
+	}
+	a.applyDrainLocked()
+}
+
+// applyDrainLocked включает drain диспетчера, если его держит оператор,
+// окно обслуживания или защита свободного места. Вызывать под a.schedMu.
+func (a *App) applyDrainLocked() {
+	a.dispatcher.Drain(a.drainManual || (a.maint.window != "" && !a.maint.lifted) || a.diskLow)
+}
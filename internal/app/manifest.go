@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/storage"
+	"github.com/Extrarius/29.09.2025/internal/store"
+)
+
+// Значения TASK_MANIFEST — какие файлы описи писать в каталог завершённой задачи.
+const (
+	ManifestJSON   = "json"   // manifest.json
+	ManifestSHA256 = "sha256" // checksums.sha256 (формат sha256sum -c)
+	ManifestBoth   = "both"
+)
+
+// Имена файлов описи в каталоге задачи.
+const (
+	ManifestFile  = "manifest.json"
+	ChecksumsFile = "checksums.sha256"
+)
+
+// TaskManifest — содержимое manifest.json: опись поставки, по которой потребитель
+// проверяет каталог задачи без обращения к API.
+type TaskManifest struct {
+	Format      int                `json:"format"` // версия формата описи
+	TaskID      string             `json:"task_id"`
+	Label       string             `json:"label,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	Status      core.TaskStatus    `json:"status"` // COMPLETE или PARTIAL
+	CreatedAt   time.Time          `json:"created_at"`
+	CompletedAt time.Time          `json:"completed_at"`
+	Files       []TaskManifestFile `json:"files"`
+}
+
+// TaskManifestFile — файл задачи в описи. У нескачанных файлов (PARTIAL) нет
+// Path, SHA256 и Response, зато есть State и Error. У файлов в S3 (dest_dir "s3://…")
+// нет SHA256: содержимое сервис не перечитывает.
+type TaskManifestFile struct {
+	ID         string             `json:"id"`
+	URL        string             `json:"url"`
+	Path       string             `json:"path,omitempty"` // относительно каталога задачи, через '/'
+	State      core.FileState     `json:"state"`
+	Size       int64              `json:"size"`
+	SHA256     string             `json:"sha256,omitempty"`
+	StartedAt  *time.Time         `json:"started_at,omitempty"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Response   *core.FileResponse `json:"response,omitempty"` // ответ источника: итоговый URL, код, ETag и т.п.
+}
+
+// manifestFormat — версия формата manifest.json (TaskManifest.Format).
+const manifestFormat = 1
+
+// writeManifest пишет опись задачи t (копия, статус COMPLETE или PARTIAL) в её
+// каталог — manifest.json и/или checksums.sha256 по TASK_MANIFEST:
+//   - sha256 файла берётся из Blob (BLOB_DIR) или считается по содержимому;
+//   - у задачи с dest_dir "s3://…" пишется только manifest.json (объектом
+//     рядом с файлами) и без sha256;
+//   - файлы пишутся через временное имя и rename: читатель не увидит половину описи;
+//   - задача, дошедшая до конца повторно (после ретрая), получает опись заново;
+//   - если файл задачи сам называется как файл описи, опись не пишется.
+//
+// Вызывается воркером вне a.mu. Ошибки пишутся в лог: опись — best-effort,
+// на статус задачи она не влияет.
+func (a *App) writeManifest(t *core.Task) {
+	mode := a.Conf.TaskManifest
+	if mode == "" {
+		return
+	}
+	dir := a.taskDestDir(t)
+	var names []string
+	if mode == ManifestJSON || mode == ManifestBoth {
+		names = append(names, ManifestFile)
+	}
+	if (mode == ManifestSHA256 || mode == ManifestBoth) && !storage.IsObject(dir) {
+		names = append(names, ChecksumsFile)
+	}
+
+	m := TaskManifest{
+		Format:      manifestFormat,
+		TaskID:      t.ID,
+		Label:       t.Label,
+		Tags:        t.Tags,
+		Status:      t.Status,
+		CreatedAt:   t.CreatedAt,
+		CompletedAt: time.Now().UTC(),
+		Files:       make([]TaskManifestFile, 0, len(t.Files)),
+	}
+	var sums strings.Builder
+	for _, f := range t.Files {
+		mf := TaskManifestFile{
+			ID:         f.ID,
+			URL:        f.URL,
+			State:      f.State,
+			Size:       f.BytesDownloaded,
+			StartedAt:  f.StartedAt,
+			FinishedAt: f.FinishedAt,
+			Error:      f.Error,
+		}
+		if f.State == core.FileDone && f.Path != "" {
+			rel, ok := storage.Rel(dir, f.Path)
+			if !ok {
+				rel = f.Path // файл вне каталога задачи: оставляем абсолютный путь
+			}
+			for _, n := range names {
+				if rel == n {
+					log.Printf("Manifest: task %s: file %s is named %s, manifest not written%s", t.ID, f.ID, n, requestTag(t.RequestID))
+					return
+				}
+			}
+			mf.Path = filepath.ToSlash(rel)
+			mf.Response = f.Response
+			mf.SHA256 = f.Blob
+			if mf.SHA256 == "" && !storage.IsObject(f.Path) {
+				sum, err := store.HashFile(f.Path)
+				if err != nil {
+					log.Printf("Manifest: task %s: hash %s: %v%s", t.ID, f.Path, err, requestTag(t.RequestID))
+					return
+				}
+				mf.SHA256 = sum
+			}
+			if mf.SHA256 != "" {
+				fmt.Fprintf(&sums, "%s  %s\n", mf.SHA256, mf.Path)
+			}
+		}
+		m.Files = append(m.Files, mf)
+	}
+
+	for _, n := range names {
+		var data []byte
+		if n == ManifestFile {
+			b, err := json.MarshalIndent(m, "", "  ")
+			if err != nil {
+				log.Printf("Manifest: task %s: %v%s", t.ID, err, requestTag(t.RequestID))
+				return
+			}
+			data = append(b, '\n')
+		} else {
+			data = []byte(sums.String())
+		}
+		if err := a.writeFileAtomic(storage.Join(dir, n), data); err != nil {
+			log.Printf("Manifest: task %s: %v%s", t.ID, err, requestTag(t.RequestID))
+			return
+		}
+	}
+}
+
+// removeManifest удаляет файлы описи из каталога задачи dir (при удалении задачи).
+func (a *App) removeManifest(dir string) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.Conf.ClientTimeout)
+	defer cancel()
+	for _, n := range []string{ManifestFile, ChecksumsFile} {
+		a.storage.Remove(ctx, storage.Join(dir, n))
+	}
+}
+
+// writeFileAtomic записывает data в path (на диске или в S3) через временный
+// файл рядом и rename.
+func (a *App) writeFileAtomic(path string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.Conf.ClientTimeout)
+	defer cancel()
+	tmp := path + ".tmp"
+	w, err := a.storage.Create(ctx, tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Abort()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		a.storage.Remove(ctx, tmp)
+		return err
+	}
+	if err := a.storage.Rename(ctx, tmp, path); err != nil {
+		a.storage.Remove(ctx, tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"io"
+
+	"github.com/Extrarius/29.09.2025/internal/metrics"
+)
+
+// registerMetrics наполняет реестр метрик сервиса (GET /metrics): метрики
+// диспетчера очереди (queue.Dispatcher.RegisterMetrics), задания, которые
+// воркер отбросил как устаревшие — повтор уже запущенного или завершённого
+// файла либо файл удалённой задачи, — автоповторы PARTIAL-задач, приём задач из брокера (ingest.go),
+// публикация событий (outbox.go), выгрузка в хранилища (sinks.go),
+// занятость воркеров, лимиты расписания (timetable.go), окна обслуживания
+// (maintenance.go) и фоновая запись WAL.
+func (a *App) registerMetrics() {
+	a.metrics = metrics.NewRegistry()
+	a.dispatcher.RegisterMetrics(a.metrics)
+	a.metrics.Counter("downloader_queue_jobs_stale_total",
+		"Jobs skipped by workers as duplicates or stale (file no longer pending or task gone).", &a.staleJobs)
+	a.metrics.Counter("downloader_partial_retries_total", "PARTIAL tasks whose failed files were requeued by their partial_retry policy.", &a.partialRetries)
+	a.metrics.Counter("downloader_ingest_accepted_total", "Task messages from the broker turned into tasks and acknowledged.", &a.ingest.accepted)
+	a.metrics.Counter("downloader_ingest_rejected_total", "Task messages from the broker rejected as invalid.", &a.ingest.rejected)
+	a.metrics.Counter("downloader_ingest_deferred_total", "Task messages from the broker left for redelivery (limits, WAL errors).", &a.ingest.deferred)
+	a.metrics.Counter("downloader_events_published_total", "Task events confirmed by the broker (EVENTS_URL).", &a.events.published)
+	a.metrics.Counter("downloader_events_publish_failures_total", "Failed broker sessions while publishing task events; events stay in the outbox.", &a.events.failed)
+	a.metrics.Gauge("downloader_events_pending", "Task events in the outbox not yet confirmed by the broker.", func() float64 {
+		if a.outbox == nil {
+			return 0
+		}
+		return float64(a.outbox.Stats().Pending)
+	})
+	if a.uploads != nil {
+		a.metrics.Counter("downloader_sink_uploads_total", "Files uploaded to sinks.", &a.uploads.uploaded)
+		a.metrics.Counter("downloader_sink_upload_failures_total", "Failed sink upload attempts (including retried ones).", &a.uploads.failed)
+		a.metrics.Gauge("downloader_sink_uploads_queued", "Files waiting for a sink upload worker.", func() float64 { return float64(a.uploadsPending()) })
+	}
+	if a.notifier != nil {
+		a.metrics.Counter("downloader_notify_sent_total", "Task summaries delivered (e-mail, Slack, Telegram).", &a.notifier.sent)
+		a.metrics.Counter("downloader_notify_alerts_sent_total", "Alert notifications delivered (e-mail, Slack, Telegram).", &a.notifier.alertsSent)
+		a.metrics.Counter("downloader_notify_failures_total", "Failed attempts to deliver a task summary or an alert (including retried ones).", &a.notifier.failed)
+		a.metrics.Gauge("downloader_notify_pending", "Task summaries and alerts waiting to be delivered.", func() float64 { return float64(a.notificationsPending()) })
+	}
+	if a.alerts != nil {
+		a.metrics.Counter("downloader_alerts_fired_total", "Alert rules that started firing.", &a.alerts.fired)
+		a.metrics.Gauge("downloader_alerts_firing", "Alert rules currently firing (per host for failure-rate rules).", func() float64 { return float64(a.alertsFiring()) })
+	}
+	a.metrics.Gauge("downloader_workers_busy", "Workers currently downloading a file.", func() float64 {
+		busy := 0
+		for _, w := range a.Workers() {
+			if w.Busy {
+				busy++
+			}
+		}
+		return float64(busy)
+	})
+	a.metrics.Gauge("downloader_workers", "Started workers (the largest schedule window or WORKERS).", func() float64 { return float64(len(a.Workers())) })
+	a.metrics.Gauge("downloader_workers_active", "Workers allowed to take jobs right now (WORKERS or the current schedule window).", func() float64 {
+		return float64(a.WorkersReport().Active)
+	})
+	a.metrics.Gauge("downloader_bandwidth_limit_bytes", "Current download bandwidth limit in bytes per second; 0 means unlimited.", func() float64 {
+		return float64(a.loader.Bandwidth())
+	})
+	a.metrics.Gauge("downloader_inflight_buffer_bytes", "Memory reserved for buffers of running downloads (MAX_INFLIGHT_BYTES budget).", func() float64 {
+		used, _ := a.loader.InflightBytes()
+		return float64(used)
+	})
+	a.metrics.Gauge("downloader_maintenance", "1 while a maintenance window keeps the queue drained.", func() float64 {
+		if a.Maintenance() != "" {
+			return 1
+		}
+		return 0
+	})
+	if a.diskGuard != nil {
+		a.metrics.Gauge("downloader_disk_guard_draining", "1 while free space in DOWNLOAD_DIR is below DISK_LOW_WATERMARK and the queue is drained.", func() float64 {
+			if a.diskLowDrain() {
+				return 1
+			}
+			return 0
+		})
+	}
+	a.metrics.Gauge("downloader_wal_async_queue", "WAL operations waiting for the background writer.", func() float64 {
+		st, _ := a.wal.AsyncStats()
+		return float64(st.Queued)
+	})
+	a.metrics.CounterFunc("downloader_wal_sync_fallbacks_total", "WAL operations written synchronously because the async queue was full.", func() uint64 {
+		st, _ := a.wal.AsyncStats()
+		return st.SyncFallbacks
+	})
+}
+
+// WriteMetrics выводит метрики в текстовом формате Prometheus (metrics.ContentType).
+func (a *App) WriteMetrics(w io.Writer) error { return a.metrics.WriteText(w) }
@@ -0,0 +1,39 @@
+package app
+
+import "runtime"
+
+// Приоритет воркеров (WORKER_NICE, WORKER_IO_PRIORITY) — чтобы загрузчик на
+// одной машине с чувствительными к задержкам сервисами не отнимал у них
+// процессор и диск. Приоритет ставится потоку ОС воркера (Linux: setpriority
+// и ioprio_set с ID потока): воркер навсегда закрепляет за собой поток
+// (runtime.LockOSThread), и пониженный приоритет не достаётся горутинам
+// API и журнала. Поток с закреплённой горутиной завершается вместе с ней и
+// в общий пул не возвращается.
+//
+// Приоритет ввода-вывода действует на запись, которую поток делает сам:
+// отложенную запись из кеша страниц ядро выполняет от своего имени — вместе с
+// WORKER_IO_PRIORITY имеет смысл BYPASS_PAGE_CACHE или FINALIZE_SYNC. Для
+// жёстких пределов процесс запускают в cgroup с io.weight / io.max (systemd:
+// IOWeight=, IOWriteBandwidthMax=) — это настройка окружения, не сервиса.
+
+// Значения WORKER_IO_PRIORITY.
+const (
+	IOPriorityNormal = "normal" // не менять (как у процесса)
+	IOPriorityLow    = "low"    // best-effort, низший уровень (ionice -c2 -n7)
+	IOPriorityIdle   = "idle"   // только когда диск простаивает (ionice -c3)
+)
+
+// MaxWorkerNice — наибольшее значение WORKER_NICE (как у nice(1)).
+const MaxWorkerNice = 19
+
+// lowerWorkerPriority закрепляет текущую горутину воркера за потоком ОС и
+// понижает приоритет потока по WORKER_NICE и WORKER_IO_PRIORITY. Без них
+// ничего не делает.
+func (a *App) lowerWorkerPriority() error {
+	nice, io := a.Conf.WorkerNice, a.Conf.WorkerIOPriority
+	if nice == 0 && (io == "" || io == IOPriorityNormal) {
+		return nil
+	}
+	runtime.LockOSThread() // не отпускаем: поток с пониженным приоритетом уйдёт вместе с воркером
+	return setThreadPriority(nice, io)
+}
@@ -0,0 +1,39 @@
+//go:build linux
+
+package app
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const (
+	ioprioWhoProcess = 1 // IOPRIO_WHO_PROCESS: с ID потока — только этот поток
+	ioprioClassBE    = 2 // IOPRIO_CLASS_BE
+	ioprioClassIdle  = 3 // IOPRIO_CLASS_IDLE
+	ioprioClassShift = 13
+)
+
+// setThreadPriority понижает приоритет текущего потока ОС: nice (0 — не
+// менять) и класс ввода-вывода io (IOPriority*).
+func setThreadPriority(nice int, io string) error {
+	tid := syscall.Gettid()
+	if nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice); err != nil {
+			return fmt.Errorf("setpriority %d: %w", nice, err)
+		}
+	}
+	var prio uintptr
+	switch io {
+	case IOPriorityLow:
+		prio = ioprioClassBE<<ioprioClassShift | 7
+	case IOPriorityIdle:
+		prio = ioprioClassIdle << ioprioClassShift
+	default:
+		return nil
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), prio); errno != 0 {
+		return fmt.Errorf("ioprio_set %s: %w", io, errno)
+	}
+	return nil
+}
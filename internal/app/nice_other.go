@@ -0,0 +1,10 @@
+//go:build !linux
+
+package app
+
+import "errors"
+
+// setThreadPriority — см. nice_linux.go; приоритет потока здесь не меняется.
+func setThreadPriority(int, string) error {
+	return errors.New("приоритет воркеров (WORKER_NICE, WORKER_IO_PRIORITY) поддерживается только в Linux")
+}
@@ -0,0 +1,529 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/metrics"
+	"github.com/Extrarius/29.09.2025/internal/notify"
+)
+
+// Отправка сводки по задаче в канал: до notifyAttempts попыток с паузой
+// notifyRetryDelay·2^(N-1); в сводке — не больше notifyMaxFailed упавших ссылок.
+const (
+	notifyAttempts   = 5
+	notifyRetryDelay = time.Minute
+	notifyMaxFailed  = 50
+)
+
+// finishedTask — задача, дошедшая до конечного статуса: её уведомления ещё
+// не записаны в задачу (queueNotifications).
+type finishedTask struct {
+	taskID string
+	status core.TaskStatus
+}
+
+// notifyJob — уведомление задачи в канал, ждущее отправки.
+type notifyJob struct {
+	taskID  string
+	channel string    // core.NotifyChannelEmail или имя уведомителя
+	at      time.Time // не раньше
+	alert   *alertJob // оповещение по правилу alerts вместо сводки задачи (taskID пуст)
+}
+
+// alertJob — оповещение по правилу (alerts.go). Хранится только в очереди:
+// после перезапуска правила оцениваются заново.
+type alertJob struct {
+	rule     string
+	email    []string // адреса для канала core.NotifyChannelEmail
+	msg      notify.Message
+	attempts int
+}
+
+// notifier — каналы и очередь сводок по завершении задач (notifyLoop):
+//   - почта на notify_email задачи (SMTP_URL);
+//   - уведомители из секции notifiers (Slack, Telegram) — из notify задачи и
+//     NOTIFY_FAILURES для задач, завершившихся с ошибками;
+//   - оповещения по правилам alerts (alerts.go) — в ту же очередь.
+//
+// Доставка хранится в задаче (core.Notification, WAL), поэтому переживает
+// перезапуск: недоставленные уведомления отправляются после восстановления
+// (resumeNotifications).
+type notifier struct {
+	mail     *notify.SMTP              // nil — почта не настроена
+	channels map[string]notify.Channel // Conf.Notifiers
+	failures []string                  // Conf.NotifyFailures
+
+	mu       sync.Mutex
+	finished []finishedTask // под mu
+	queue    []notifyJob    // под mu
+	wake     chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	sent       metrics.Counter
+	alertsSent metrics.Counter // оповещений по правилам alerts
+	failed     metrics.Counter // неудачных попыток (в том числе с повтором)
+}
+
+// openNotifier создаёт каналы из SMTP_URL/SMTP_FROM и секции notifiers; nil —
+// ни один не настроен.
+func openNotifier(c *Config) (*notifier, error) {
+	if c.SMTPURL == "" && len(c.Notifiers) == 0 {
+		return nil, nil
+	}
+	n := &notifier{
+		channels: make(map[string]notify.Channel, len(c.Notifiers)),
+		failures: c.NotifyFailures,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	if c.SMTPURL != "" {
+		s, err := notify.NewSMTP(c.SMTPURL, c.SMTPFrom)
+		if err != nil {
+			return nil, err
+		}
+		n.mail = s
+	}
+	for name, raw := range c.Notifiers {
+		ch, err := notify.New(raw)
+		if err != nil {
+			return nil, fmt.Errorf("notifiers.%s: %w", name, err)
+		}
+		n.channels[name] = ch
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	return n, nil
+}
+
+// HasNotifier сообщает, настроен ли уведомитель name (для проверки TaskSpec.Notify).
+func (a *App) HasNotifier(name string) bool {
+	if a.notifier == nil {
+		return false
+	}
+	_, ok := a.notifier.channels[name]
+	return ok
+}
+
+// mailConfigured сообщает, настроена ли почта (для проверки TaskSpec.NotifyEmail).
+func (a *App) mailConfigured() bool {
+	return a.notifier != nil && a.notifier.mail != nil
+}
+
+// startNotify запускает отправку сводок (если каналы настроены).
+func (a *App) startNotify() {
+	if a.notifier == nil {
+		return
+	}
+	go a.notifyLoop()
+}
+
+// stopNotify прерывает отправку и ждёт notifyLoop. Завершения, ещё не
+// записанные в задачи, записываются (без отправки): неотправленные
+// уведомления уйдут после перезапуска.
+func (a *App) stopNotify() {
+	n := a.notifier
+	if n == nil {
+		return
+	}
+	n.cancel()
+	<-n.done
+	a.queueFinished()
+}
+
+// channelsFor возвращает каналы, куда уведомить о завершении задачи t со
+// статусом status: почта (notify_email), уведомители задачи (notify) и, если
+// задача завершилась с ошибками (FAILED, PARTIAL), — NOTIFY_FAILURES.
+// Читает только неизменяемые поля t.
+func (n *notifier) channelsFor(t *core.Task, status core.TaskStatus) []string {
+	var out []string
+	if t.NotifyEmail != "" && n.mail != nil {
+		out = append(out, core.NotifyChannelEmail)
+	}
+	for _, name := range t.Notify {
+		if _, ok := n.channels[name]; ok && !slices.Contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	if status == core.TaskFailed || status == core.TaskPartial {
+		for _, name := range n.failures {
+			if !slices.Contains(out, name) {
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
+
+// notifyFinished отмечает завершение задачи t, если среди events есть переход
+// в конечный статус (core.TaskStatus.Terminal) и есть кого уведомлять.
+// Уведомления записываются в задачу уже в notifyLoop: здесь блокировки
+// брать нельзя (recordEvents вызывают и под a.mu). Читает только
+// неизменяемые поля t.
+func (a *App) notifyFinished(t *core.Task, events []core.TaskEvent) {
+	n := a.notifier
+	if n == nil || n.ctx.Err() != nil {
+		return
+	}
+	for _, ev := range events {
+		status := core.TaskStatus(ev.Status)
+		if ev.Type != core.EventStatusChanged || !status.Terminal() || len(n.channelsFor(t, status)) == 0 {
+			continue
+		}
+		n.mu.Lock()
+		n.finished = append(n.finished, finishedTask{taskID: t.ID, status: status})
+		n.mu.Unlock()
+		n.signal()
+	}
+}
+
+// signal будит notifyLoop.
+func (n *notifier) signal() {
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// enqueue ставит отправку в очередь.
+func (n *notifier) enqueue(jobs ...notifyJob) {
+	if len(jobs) == 0 {
+		return
+	}
+	n.mu.Lock()
+	n.queue = append(n.queue, jobs...)
+	n.mu.Unlock()
+	n.signal()
+}
+
+// queueFinished записывает уведомления всех отмеченных завершений
+// (queueNotifications).
+func (a *App) queueFinished() {
+	n := a.notifier
+	n.mu.Lock()
+	finished := n.finished
+	n.finished = nil
+	n.mu.Unlock()
+	for _, f := range finished {
+		a.queueNotifications(f)
+	}
+}
+
+// queueNotifications записывает в задачу уведомления о её завершении
+// (core.Task.QueueNotification) по каналу на каждого получателя, фиксирует
+// задачу в WAL и ставит отправку в очередь. Задача удалена или уже снова в
+// работе — ничего не делает.
+func (a *App) queueNotifications(f finishedTask) {
+	n := a.notifier
+	now := time.Now().UTC()
+	a.mu.Lock()
+	t, ok := a.tasks.get(f.taskID)
+	if !ok || t.Status != f.status {
+		a.mu.Unlock()
+		return
+	}
+	var jobs []notifyJob
+	for _, ch := range n.channelsFor(t, f.status) {
+		t.QueueNotification(ch, f.status, now)
+		jobs = append(jobs, notifyJob{taskID: t.ID, channel: ch, at: now})
+	}
+	snap := t.Clone()
+	a.mu.Unlock()
+
+	_ = a.wal.AppendTask(snap)
+	if n.ctx.Err() == nil {
+		n.enqueue(jobs...)
+	}
+}
+
+// resumeNotifications ставит в очередь уведомления, не отправленные до
+// остановки сервиса (вызывается после восстановления). Уведомления о
+// прошлом завершении задачи, снова запущенной с тех пор, пропускаются.
+func (a *App) resumeNotifications() {
+	n := a.notifier
+	if n == nil {
+		return
+	}
+	var jobs []notifyJob
+	now := time.Now()
+	a.mu.RLock()
+	for _, t := range a.tasks.all() {
+		t.Mutex().Lock()
+		for _, nt := range t.Notifications {
+			if nt.State == core.NotifyPending && nt.Status == t.Status {
+				jobs = append(jobs, notifyJob{taskID: t.ID, channel: nt.Channel, at: now})
+			}
+		}
+		t.Mutex().Unlock()
+	}
+	a.mu.RUnlock()
+	n.enqueue(jobs...)
+	if len(jobs) > 0 {
+		log.Printf("Notify: %d notification(s) queued after restart", len(jobs))
+	}
+}
+
+// enqueueAlert ставит в очередь оповещение msg по правилу r — в каждый его
+// канал (почта, уведомители).
+func (n *notifier) enqueueAlert(r AlertRule, msg notify.Message) {
+	if n.ctx.Err() != nil {
+		return
+	}
+	email, _ := core.NotifyRecipients(r.Email)
+	now := time.Now()
+	var jobs []notifyJob
+	for _, ch := range alertRecipients(r) {
+		jobs = append(jobs, notifyJob{channel: ch, at: now, alert: &alertJob{rule: r.Name, email: email, msg: msg}})
+	}
+	n.enqueue(jobs...)
+}
+
+// notificationsPending — длина очереди уведомлений (метрика).
+func (a *App) notificationsPending() int {
+	n := a.notifier
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.queue) + len(n.finished)
+}
+
+// notifyLoop записывает уведомления завершившихся задач и отправляет их по
+// одному до stopNotify; неудачные возвращаются в очередь с паузой.
+func (a *App) notifyLoop() {
+	n := a.notifier
+	defer close(n.done)
+	for {
+		a.queueFinished()
+		now := time.Now()
+		var (
+			job  notifyJob
+			ok   bool
+			wait = time.Hour
+		)
+		n.mu.Lock()
+		for i, j := range n.queue {
+			if !j.at.After(now) {
+				job, ok = j, true
+				n.queue = append(n.queue[:i], n.queue[i+1:]...)
+				break
+			}
+			wait = min(wait, j.at.Sub(now))
+		}
+		n.mu.Unlock()
+		if !ok {
+			timer := time.NewTimer(wait)
+			select {
+			case <-n.ctx.Done():
+				timer.Stop()
+				return
+			case <-n.wake:
+			case <-timer.C:
+			}
+			timer.Stop()
+			continue
+		}
+		if job.alert != nil {
+			n.deliverAlert(job)
+		} else {
+			a.deliver(job)
+		}
+		if n.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// deliver отправляет уведомление задачи job.taskID в канал job.channel:
+//   - задача удалена, уведомление уже отправлено или задача снова в работе
+//     (ретрай) — пропускается: следующее завершение поставит новое;
+//   - успех — Notification.State = NotifySent;
+//   - ошибка — повтор через notifyRetryDelay·2^(N-1), всего до
+//     notifyAttempts попыток, затем NotifyFailed;
+//   - итог пишется в WAL и историю задачи (EventNotified).
+func (a *App) deliver(job notifyJob) {
+	n := a.notifier
+	a.mu.RLock()
+	t, ok := a.tasks.get(job.taskID)
+	if !ok {
+		a.mu.RUnlock()
+		return
+	}
+	t.Mutex().Lock()
+	nt := t.Notification(job.channel)
+	if nt == nil || nt.State != core.NotifyPending || nt.Status != t.Status {
+		a.unlockTask(t)
+		return
+	}
+	snap := t.Clone()
+	a.unlockTask(t)
+
+	target, err := n.send(snap, job.channel)
+	if n.ctx.Err() != nil {
+		return // остановка сервиса: уведомление остаётся pending
+	}
+
+	now := time.Now().UTC()
+	a.mu.Lock()
+	if !a.tasks.current(t) || t.Notification(job.channel) != nt || nt.State != core.NotifyPending {
+		a.mu.Unlock()
+		return
+	}
+	nt.Attempts++
+	var retryIn time.Duration
+	msg := target
+	if err == nil {
+		n.sent.Inc()
+		nt.State, nt.Error, nt.SentAt = core.NotifySent, "", &now
+	} else {
+		n.failed.Inc()
+		nt.Error = err.Error()
+		msg = fmt.Sprintf("%s: attempt %d: %v", target, nt.Attempts, err)
+		if nt.Attempts < notifyAttempts {
+			retryIn = notifyRetryDelay << (nt.Attempts - 1)
+		} else {
+			nt.State = core.NotifyFailed
+		}
+	}
+	ev := t.AddEvent(core.TaskEvent{At: now, Type: core.EventNotified, Status: nt.State, Message: msg})
+	attempts := nt.Attempts
+	snap = t.Clone()
+	a.mu.Unlock()
+
+	_ = a.wal.AppendTask(snap)
+	a.recordEvents(snap, ev)
+	switch {
+	case err == nil:
+		log.Printf("Notify: task %s %s summary sent to %s%s", snap.ID, snap.Status, target, requestTag(snap.RequestID))
+	case retryIn > 0:
+		log.Printf("Notify: task %s summary to %s failed (attempt %d), retry in %s: %v%s",
+			snap.ID, target, attempts, retryIn, err, requestTag(snap.RequestID))
+		job.at = now.Add(retryIn)
+		n.enqueue(job)
+	default:
+		log.Printf("Notify: task %s summary to %s failed after %d attempt(s): %v%s",
+			snap.ID, target, attempts, err, requestTag(snap.RequestID))
+	}
+}
+
+// deliverAlert отправляет оповещение по правилу; ошибка — повтор через
+// notifyRetryDelay·2^(N-1), всего до notifyAttempts попыток.
+func (n *notifier) deliverAlert(job notifyJob) {
+	al := job.alert
+	target, err := n.sendMessage(job.channel, al.email, al.msg)
+	if n.ctx.Err() != nil {
+		return
+	}
+	al.attempts++
+	switch {
+	case err == nil:
+		n.alertsSent.Inc()
+		log.Printf("Notify: alert %s sent to %s", al.rule, target)
+	case al.attempts < notifyAttempts:
+		n.failed.Inc()
+		retryIn := notifyRetryDelay << (al.attempts - 1)
+		log.Printf("Notify: alert %s to %s failed (attempt %d), retry in %s: %v", al.rule, target, al.attempts, retryIn, err)
+		job.at = time.Now().Add(retryIn)
+		n.enqueue(job)
+	default:
+		n.failed.Inc()
+		log.Printf("Notify: alert %s to %s failed after %d attempt(s): %v", al.rule, target, al.attempts, err)
+	}
+}
+
+// send отправляет сводку по задаче t в канал channel и возвращает описание
+// получателя для логов и истории: "email a@example.com, b@example.com" или
+// имя уведомителя.
+func (n *notifier) send(t *core.Task, channel string) (string, error) {
+	var to []string
+	if channel == core.NotifyChannelEmail {
+		var err error
+		if to, err = core.NotifyRecipients(t.NotifyEmail); err != nil {
+			return "email", err
+		}
+	}
+	return n.sendMessage(channel, to, taskSummary(t))
+}
+
+// sendMessage отправляет msg в канал channel: на адреса to (канал почты) или
+// уведомителю с этим именем; возвращает описание получателя, как send.
+func (n *notifier) sendMessage(channel string, to []string, msg notify.Message) (string, error) {
+	if channel == core.NotifyChannelEmail {
+		target := "email " + strings.Join(to, ", ")
+		if n.mail == nil {
+			return target, fmt.Errorf("e-mail delivery is not configured")
+		}
+		return target, n.mail.Send(n.ctx, to, msg)
+	}
+	ch, ok := n.channels[channel]
+	if !ok {
+		return channel, fmt.Errorf("notifier %q is not configured", channel)
+	}
+	return channel, ch.Send(n.ctx, msg)
+}
+
+// taskSummary — сводка по завершённой задаче t: статус, счётчики файлов,
+// скачанный объём, упавшие ссылки с ошибками (до notifyMaxFailed).
+func taskSummary(t *core.Task) notify.Message {
+	name := t.ID
+	if t.Label != "" {
+		name = fmt.Sprintf("%s (%s)", t.Label, t.ID)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Task:       %s\n", name)
+	fmt.Fprintf(&b, "Status:     %s\n", t.Status)
+	fmt.Fprintf(&b, "Created:    %s\n", t.CreatedAt.Format(time.RFC3339))
+	if end := lastFinished(t); !end.IsZero() {
+		fmt.Fprintf(&b, "Finished:   %s (%s)\n", end.Format(time.RFC3339), end.Sub(t.CreatedAt).Round(time.Second))
+	}
+	fmt.Fprintf(&b, "Files:      %d total, %d done, %d failed", t.Total, t.Done, t.Failed)
+	if t.Cancelled > 0 {
+		fmt.Fprintf(&b, ", %d cancelled", t.Cancelled)
+	}
+	if t.Skipped > 0 {
+		fmt.Fprintf(&b, ", %d skipped", t.Skipped)
+	}
+	if t.Expired > 0 {
+		fmt.Fprintf(&b, ", %d expired", t.Expired)
+	}
+	fmt.Fprintf(&b, "\nDownloaded: %s (%d bytes)\n", ByteSize(t.TotalBytesDownloaded), t.TotalBytesDownloaded)
+	if len(t.Tags) > 0 {
+		fmt.Fprintf(&b, "Tags:       %s\n", strings.Join(t.Tags, ", "))
+	}
+	failed := 0
+	for _, f := range t.Files {
+		if f.State != core.FileFailed {
+			continue
+		}
+		if failed == 0 {
+			b.WriteString("\nFailed files:\n")
+		}
+		failed++
+		if failed > notifyMaxFailed {
+			fmt.Fprintf(&b, "  … and %d more\n", t.Failed-notifyMaxFailed)
+			break
+		}
+		fmt.Fprintf(&b, "  %s\n    %s\n", f.URL, f.Error)
+	}
+	return notify.Message{
+		Subject: fmt.Sprintf("[downloader] %s: task %s, %d/%d files", t.Status, name, t.Done, t.Total),
+		Text:    b.String(),
+	}
+}
+
+// lastFinished — самое позднее FinishedAt файлов задачи; нулевое — нет.
+func lastFinished(t *core.Task) time.Time {
+	var end time.Time
+	for _, f := range t.Files {
+		if f.FinishedAt != nil && f.FinishedAt.After(end) {
+			end = *f.FinishedAt
+		}
+	}
+	return end
+}
@@ -0,0 +1,258 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/metrics"
+	"github.com/Extrarius/29.09.2025/internal/nats"
+	"github.com/Extrarius/29.09.2025/internal/store"
+)
+
+// Параметры публикации событий задач (EVENTS_URL).
+const (
+	eventsBatch    = 256              // событий за одну отправку
+	eventsTimeout  = 10 * time.Second // ожидание подтверждения брокера
+	eventsKeepIdle = 30 * time.Second // простой без событий — PING, чтобы ответить серверу на его PING
+)
+
+// eventsMetrics — счётчики публикации событий (GET /metrics).
+type eventsMetrics struct {
+	published metrics.Counter // событий подтверждено брокером
+	failed    metrics.Counter // неудачных отправок (события остались в очереди)
+}
+
+// eventsTarget — разобранный EVENTS_URL.
+type eventsTarget struct {
+	url       string // адрес сервера без параметров
+	subject   string // префикс: событие уходит в "<subject>.<тип события>"
+	jetStream bool   // ждать PubAck потока JetStream, а не только приёма сервером
+}
+
+// parseEventsURL разбирает EVENTS_URL: nats://[user:password@|token@]host[:port]?subject=...&jetstream=true.
+// subject обязателен; jetstream — публиковать с подтверждением записи в поток.
+func parseEventsURL(raw string) (eventsTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return eventsTarget{}, err
+	}
+	switch u.Scheme {
+	case "nats":
+	case "kafka", "amqp", "amqps":
+		return eventsTarget{}, fmt.Errorf("%s не поддерживается: используйте NATS (например, через мост NATS → %s)", u.Scheme, u.Scheme)
+	default:
+		return eventsTarget{}, fmt.Errorf("ожидается nats://, получено %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return eventsTarget{}, errors.New("не задан адрес сервера")
+	}
+	q := u.Query()
+	t := eventsTarget{subject: q.Get("subject")}
+	if t.subject == "" || strings.ContainsAny(t.subject, " \t\r\n*>") {
+		return eventsTarget{}, fmt.Errorf("ожидается параметр subject без пробелов и масок, получено %q", t.subject)
+	}
+	if v := q.Get("jetstream"); v != "" {
+		if t.jetStream, err = strconv.ParseBool(v); err != nil {
+			return eventsTarget{}, fmt.Errorf("параметр jetstream: ожидается true или false, получено %q", v)
+		}
+	}
+	u.RawQuery = ""
+	t.url = u.String()
+	return t, nil
+}
+
+// recordEvents пишет события задачи t в WAL (историю задачи) и, если включена
+// публикация (EVENTS_URL), — в исходящую очередь store.Outbox; завершение задачи
+// с notify_email ставит в очередь сводку на почту (notifyFinished), а переход в
+// PARTIAL задачи с автоповтором будит partialRetryLoop (notePartial), смены статуса
+// попадают в счётчики GET /stats (taskStats.events). Читает только неизменяемые
+// поля t (ID, RequestID, NotifyEmail, PartialRetry) — блокировка a.mu не нужна.
+func (a *App) recordEvents(t *core.Task, events ...core.TaskEvent) {
+	_ = a.wal.AppendEvents(t.ID, events...)
+	a.stats.events(t.ID, events)
+	a.publishEvents(t, events...)
+	a.notifyFinished(t, events)
+	a.notePartial(t, events)
+}
+
+// publishEvents ставит события в исходящую очередь (без записи в историю —
+// так публикуется EventDeleted). Публикация выключена — ничего не делает.
+func (a *App) publishEvents(t *core.Task, events ...core.TaskEvent) {
+	if a.outbox == nil {
+		return
+	}
+	if err := a.outbox.Append(t.ID, t.RequestID, events...); err != nil {
+		log.Printf("Events: outbox append for task %s%s: %v", t.ID, requestTag(t.RequestID), err)
+	}
+}
+
+// startEvents запускает публикацию событий из исходящей очереди в брокер
+// (Conf.EventsURL, если задан) в фоне. Вызывается после восстановления — как
+// и приём задач из брокера. Остановка — stopEvents.
+func (a *App) startEvents() {
+	if a.outbox == nil {
+		return
+	}
+	target, err := parseEventsURL(a.Conf.EventsURL)
+	if err != nil {
+		log.Printf("Events: %v", err) // Validate уже проверил адрес
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.eventsStop = cancel
+	a.eventsDone = make(chan struct{})
+	go a.eventsLoop(ctx, target)
+}
+
+// stopEvents останавливает публикацию и ждёт выхода eventsLoop. Неподтверждённые
+// события остаются в очереди и уйдут после перезапуска.
+func (a *App) stopEvents() {
+	if a.eventsStop == nil {
+		return
+	}
+	a.eventsStop()
+	<-a.eventsDone
+	a.eventsStop = nil
+}
+
+// eventsLoop публикует события, пока не отменён ctx. Брокер недоступен —
+// переподключается с нарастающей паузой (как ingestLoop), события копятся на диске.
+func (a *App) eventsLoop(ctx context.Context, t eventsTarget) {
+	defer close(a.eventsDone)
+	pause := ingestRetryMin
+	for {
+		err := a.eventsSession(ctx, t)
+		if ctx.Err() != nil {
+			return
+		}
+		a.events.failed.Inc()
+		log.Printf("Events: %s: %v; %d event(s) pending, reconnecting in %s",
+			t.subject, err, a.outbox.Stats().Pending, pause)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pause):
+		}
+		pause = min(pause*2, ingestRetryMax)
+	}
+}
+
+// eventsSession — одно подключение: отправляет события пачками по eventsBatch
+// и подтверждает их в очереди (store.Outbox.Ack) только после подтверждения
+// брокера:
+//   - обычный NATS — PING/PONG после пачки (nats.Conn.Flush): сервер принял сообщения;
+//   - JetStream — PubAck потока на каждое сообщение (ответ в собственный inbox).
+//
+// Ошибка публикации или подтверждения завершает сессию: пачка уйдёт снова
+// после переподключения (доставка «хотя бы один раз», дубликаты отсекаются по seq).
+func (a *App) eventsSession(ctx context.Context, t eventsTarget) error {
+	dctx, cancel := context.WithTimeout(ctx, eventsTimeout)
+	conn, err := nats.Dial(dctx, t.url, "downloader-events")
+	cancel()
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+	inbox := ""
+	if t.jetStream {
+		inbox = "_INBOX." + randomToken()
+		if err := conn.Subscribe(inbox+".*", ""); err != nil {
+			return err
+		}
+	}
+	log.Printf("Events: publishing to %s.* (jetstream=%v)", t.subject, t.jetStream)
+	for {
+		entries, next, err := a.outbox.Pending(eventsBatch)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-a.outbox.Notify():
+			case <-time.After(eventsKeepIdle):
+				if err := conn.Flush(eventsTimeout); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := publishBatch(conn, t, inbox, entries); err != nil {
+			return err
+		}
+		if err := a.outbox.Ack(entries[len(entries)-1].Seq, next); err != nil {
+			return err
+		}
+		a.events.published.Add(uint64(len(entries)))
+	}
+}
+
+// publishBatch публикует пачку событий и ждёт подтверждения брокера.
+func publishBatch(conn *nats.Conn, t eventsTarget, inbox string, entries []store.OutboxEntry) error {
+	for _, e := range entries {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		reply := ""
+		if inbox != "" {
+			reply = inbox + "." + strconv.FormatUint(e.Seq, 10)
+		}
+		if err := conn.PublishReply(t.subject+"."+e.Type, reply, body); err != nil {
+			return err
+		}
+	}
+	if inbox == "" {
+		return conn.Flush(eventsTimeout)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(eventsTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	for acked := 0; acked < len(entries); {
+		msg, err := conn.Next()
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(msg.Subject, inbox+".") {
+			continue
+		}
+		var ack struct {
+			Stream string `json:"stream"`
+			Error  *struct {
+				Code        int    `json:"code"`
+				Description string `json:"description"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(msg.Data, &ack); err != nil {
+			return fmt.Errorf("bad PubAck %q: %w", msg.Data, err)
+		}
+		if ack.Error != nil {
+			return fmt.Errorf("jetstream: %s (code %d)", ack.Error.Description, ack.Error.Code)
+		}
+		if ack.Stream == "" {
+			// ответ без имени потока — не PubAck (в subject ответил не JetStream)
+			return fmt.Errorf("jetstream: no stream for %s", msg.Subject)
+		}
+		acked++
+	}
+	return nil
+}
+
+// randomToken — случайный идентификатор для subject'а inbox.
+func randomToken() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
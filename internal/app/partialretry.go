@@ -0,0 +1,137 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// Автоповтор PARTIAL-задач (TaskSpec.PartialRetry, см. core/partialretry.go)
+// ведёт partialRetryLoop: спит до ближайшего момента повтора
+// (core.Task.PartialRetryAt) и возвращает в очередь упавшие файлы задач, чей
+// момент наступил. Будят цикл переход задачи с политикой в PARTIAL
+// (notePartial из recordEvents) и восстановление после перезапуска.
+
+// partialRetryMaxWait — предел ожидания partialRetryLoop между проверками.
+const partialRetryMaxWait = time.Hour
+
+// startPartialRetry запускает partialRetryLoop.
+func (a *App) startPartialRetry() {
+	a.partialWake = make(chan struct{}, 1)
+	a.partialStop = make(chan struct{})
+	a.partialDone = make(chan struct{})
+	go a.partialRetryLoop()
+}
+
+// stopPartialRetry останавливает partialRetryLoop и ждёт его выхода.
+func (a *App) stopPartialRetry() {
+	if a.partialStop == nil {
+		return
+	}
+	close(a.partialStop)
+	<-a.partialDone
+	a.partialStop = nil
+}
+
+// wakePartialRetry будит partialRetryLoop.
+func (a *App) wakePartialRetry() {
+	select {
+	case a.partialWake <- struct{}{}:
+	default:
+	}
+}
+
+// notePartial будит partialRetryLoop, если среди events есть переход задачи
+// с политикой автоповтора в PARTIAL. Вызывается из recordEvents (в том числе
+// под a.mu) — блокировок не берёт, PartialRetry задаётся при создании задачи.
+func (a *App) notePartial(t *core.Task, events []core.TaskEvent) {
+	if t.PartialRetry == nil {
+		return
+	}
+	for _, ev := range events {
+		if ev.Type == core.EventStatusChanged && ev.Status == string(core.TaskPartial) {
+			a.wakePartialRetry()
+			return
+		}
+	}
+}
+
+// partialRetryLoop повторяет PARTIAL-задачи, чей момент наступил, и спит до
+// ближайшего следующего (не дольше partialRetryMaxWait) или до wakePartialRetry.
+func (a *App) partialRetryLoop() {
+	defer close(a.partialDone)
+	for {
+		wait := partialRetryMaxWait
+		if next := a.retryPartialDue(time.Now().UTC()); !next.IsZero() && time.Until(next) < wait {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-a.partialStop:
+			timer.Stop()
+			return
+		case <-a.partialWake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// retryPartialDue повторяет задачи, чей момент автоповтора к now наступил
+// (retryPartial), и возвращает ближайший ещё не наступивший (нулевой — таких нет).
+func (a *App) retryPartialDue(now time.Time) time.Time {
+	var (
+		due  []string
+		next time.Time
+	)
+	a.mu.RLock()
+	for _, t := range a.tasks.all() {
+		if t.PartialRetry == nil {
+			continue
+		}
+		t.Mutex().Lock()
+		at, ok := t.PartialRetryAt(now)
+		t.Mutex().Unlock()
+		switch {
+		case !ok:
+		case !at.After(now):
+			due = append(due, t.ID)
+		case next.IsZero() || at.Before(next):
+			next = at
+		}
+	}
+	a.mu.RUnlock()
+	for _, id := range due {
+		a.retryPartial(id, now)
+	}
+	return next
+}
+
+// retryPartial возвращает в очередь упавшие файлы PARTIAL-задачи id, если её
+// момент автоповтора всё ещё наступил (задачу могли повторить вручную или
+// удалить), и увеличивает счётчик повторов политики.
+func (a *App) retryPartial(id string, now time.Time) {
+	a.mu.Lock()
+	t, ok := a.tasks.get(id)
+	if !ok {
+		a.mu.Unlock()
+		return
+	}
+	ch := &taskChange{}
+	if at, ok := t.PartialRetryAt(now); ok && !at.After(now) {
+		p := t.PartialRetry
+		p.Count++
+		ch = requeueFailedLocked(t, "", fmt.Sprintf("partial retry %d/%d", p.Count, p.Max), now)
+	}
+	a.mu.Unlock()
+	if ch.files == 0 {
+		return
+	}
+	a.commit(ch)
+	a.partialRetries.Inc()
+	p := ch.snap.PartialRetry
+	log.Printf("PartialRetry: task %s: retry %d/%d, %d failed file(s) requeued%s",
+		id, p.Count, p.Max, ch.files, requestTag(ch.snap.RequestID))
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// Значения PART_LOCATION — где лежат временные .part-файлы загрузок.
+const (
+	PartsSame   = "same"   // рядом с файлом назначения
+	PartsTask   = "task"   // в подкаталоге TaskPartsDir каталога задачи
+	PartsGlobal = "global" // в PART_DIR
+)
+
+// TaskPartsDir — подкаталог каталога задачи для .part-файлов при
+// PART_LOCATION=task.
+const TaskPartsDir = ".parts"
+
+// PartPlacement — действующее значение PART_LOCATION: пусто — global, если
+// задан PART_DIR, иначе same.
+func (c *Config) PartPlacement() string {
+	switch {
+	case c.PartLocation != "":
+		return c.PartLocation
+	case c.PartDir != "":
+		return PartsGlobal
+	}
+	return PartsSame
+}
+
+// partDir — каталог .part-файлов загрузок задачи t для
+// downloader.Request.PartDir: при PART_LOCATION=task — TaskPartsDir в
+// каталоге задачи (кроме dest_dir в S3), иначе пусто (решает
+// Options.PartDir загрузчика).
+func (a *App) partDir(t *core.Task) string {
+	dir := a.taskDestDir(t)
+	if a.Conf.PartPlacement() != PartsTask || storage.IsObject(dir) {
+		return ""
+	}
+	return storage.Join(dir, TaskPartsDir)
+}
+
+// globalPartDir — downloader.Options.PartDir: PART_DIR при
+// PART_LOCATION=global, иначе пусто.
+func globalPartDir(c *Config) string {
+	if c.PartPlacement() != PartsGlobal {
+		return ""
+	}
+	return c.PartDir
+}
@@ -0,0 +1,359 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/store"
+)
+
+// recoveredFile — ссылка на восстановленный Pending-файл, ждущий постановки в очередь.
+type recoveredFile struct {
+	taskID, fileID string
+	resume         bool // загрузку прервал перезапуск (был Running) — ставится первой (Job.Resume)
+}
+
+// interleaveRecovered раскладывает Pending-файлы восстановленных задач
+// (кроме отложенных автоповторов) в порядок постановки в очередь, при котором
+// ни одна задача и ни один хост не занимают воркеров надолго:
+//   - сначала файлы, чью загрузку прервал перезапуск (interrupted), — их
+//     .part-файлы уже частично скачаны, дальше — остальные;
+//   - внутри задачи файлы чередуются по хостам (по одному с каждого хоста по кругу);
+//   - задачи чередуются между собой (по одному файлу от каждой, в порядке создания).
+//
+// Очередь дальше упорядочивает задания по приоритету задачи, сохраняя этот
+// порядок среди заданий с равным приоритетом; прерванные загрузки (Job.Resume)
+// встают в голову своего приоритета — и впереди заданий, принятых за время
+// постановки восстановленных.
+func interleaveRecovered(tasks map[string]*core.Task, interrupted map[*core.FileItem]bool) []recoveredFile {
+	sorted := make([]*core.Task, 0, len(tasks))
+	for _, t := range tasks {
+		sorted = append(sorted, t)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].CreatedAt.Equal(sorted[j].CreatedAt) {
+			return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	var out []recoveredFile
+	for _, resume := range []bool{true, false} {
+		var lanes [][]recoveredFile
+		total := 0
+		for _, t := range sorted {
+			var hosts []string
+			byHost := make(map[string][]recoveredFile)
+			for _, f := range t.Files {
+				if f.State != core.FilePending || f.NextAttemptAt != nil || interrupted[f] != resume {
+					continue
+				}
+				if _, ok := byHost[f.Host]; !ok {
+					hosts = append(hosts, f.Host)
+				}
+				byHost[f.Host] = append(byHost[f.Host], recoveredFile{t.ID, f.ID, resume})
+			}
+			lane := roundRobin(hosts, byHost)
+			if len(lane) > 0 {
+				lanes = append(lanes, lane)
+				total += len(lane)
+			}
+		}
+		for i, n := 0, len(out)+total; len(out) < n; i++ {
+			for _, lane := range lanes {
+				if i < len(lane) {
+					out = append(out, lane[i])
+				}
+			}
+		}
+	}
+	return out
+}
+
+// roundRobin сливает списки groups[keys[i]] по одному элементу с каждого по кругу.
+func roundRobin(keys []string, groups map[string][]recoveredFile) []recoveredFile {
+	var out []recoveredFile
+	for i := 0; ; i++ {
+		added := false
+		for _, k := range keys {
+			if g := groups[k]; i < len(g) {
+				out = append(out, g[i])
+				added = true
+			}
+		}
+		if !added {
+			return out
+		}
+	}
+}
+
+// startRecovery запускает восстановление в фоне, чтобы New() не ждал чтения
+// большого журнала: HTTP-сервер поднимается сразу (/readyz — 503 до конца
+// загрузки состояния), ход виден в логе и в GET /admin/recovery (App.Recovery).
+// Остановка — stopRecovery (из Close).
+func (a *App) startRecovery() {
+	a.recoverStop = make(chan struct{})
+	a.recoverDone = make(chan struct{})
+	a.recovery.begin(time.Now())
+	go a.runRecovery()
+}
+
+// stopRecovery прерывает фоновое восстановление (чтение журнала или постановку
+// файлов в очередь) и ждёт его завершения. Не поставленные файлы остаются
+// Pending в WAL и будут поставлены при следующем запуске.
+func (a *App) stopRecovery() {
+	if a.recoverStop == nil {
+		return
+	}
+	close(a.recoverStop)
+	<-a.recoverDone
+	a.recoverStop = nil
+}
+
+// runRecovery — тело фонового восстановления:
+//  1. читает журнал и раскладывает задачи (recoverFromWAL) — фаза RecoveryLoading;
+//  2. восстанавливает режим выдачи (restoreScheduler), запускает опрос WatchDir,
+//     читает учёт потребления (startUsage) и объявляет готовность (Ready, /readyz = 200);
+//  3. ставит восстановленные файлы в очередь (enqueueRecovered) — фаза
+//     RecoveryEnqueueing; API задач к этому моменту уже доступен.
+//
+// Ошибка чтения журнала фатальна: она пишется в лог и передаётся в Serve,
+// который завершает сервис с этой ошибкой (как раньше — New).
+func (a *App) runRecovery() {
+	defer close(a.recoverDone)
+	files, err := a.recoverFromWAL()
+	if errors.Is(err, errRecoveryStopped) {
+		a.recovery.finish(RecoveryStopped, nil)
+		log.Printf("Recovery: stopped while reading the WAL")
+		return
+	}
+	if err != nil {
+		a.recovery.finish(RecoveryFailed, err)
+		log.Printf("Recovery: failed: %v", err)
+		a.fatal <- fmt.Errorf("recover from WAL: %w", err)
+		return
+	}
+	a.restoreScheduler()
+	a.startWatcher()
+	a.startIngest()
+	a.startEvents()
+	a.resumeUploads()
+	a.resumeNotifications()
+	a.wakeExpiry() // ближайший срок среди восстановленных задач
+	a.wakePartialRetry()
+	a.startUsage()
+	a.loaded.Store(true)
+	st := a.recovery.enterEnqueue(len(files))
+	log.Printf("Recovery: state loaded: %d task(s), %d record(s) in %s — ready",
+		st.Tasks, st.Records, st.Elapsed)
+	if a.enqueueRecovered(files) {
+		a.recovery.finish(RecoveryDone, nil)
+	} else {
+		a.recovery.finish(RecoveryStopped, nil)
+	}
+}
+
+// errRecoveryStopped — чтение журнала прервано остановкой сервиса.
+var errRecoveryStopped = errors.New("recovery stopped")
+
+// recoveryProgress — обратный вызов чтения журнала (store.WAL.RecoverTasksProgress):
+// обновляет App.Recovery, не чаще раза в recoveryLogEvery пишет ход в лог
+// и прерывает чтение, если сервис останавливается.
+func (a *App) recoveryProgress(p store.ReadProgress) error {
+	select {
+	case <-a.recoverStop:
+		return errRecoveryStopped
+	default:
+	}
+	if st, log := a.recovery.progress(p, time.Now()); log {
+		logRecoveryProgress(st)
+	}
+	return nil
+}
+
+func logRecoveryProgress(st RecoveryStatus) {
+	log.Printf("Recovery: reading WAL %.1f%% (%d of %d bytes), %d record(s), %.0f records/s",
+		st.Percent, st.BytesRead, st.BytesTotal, st.Records, st.RecordsPerSec)
+}
+
+// enqueueRecovered ставит восстановленные файлы в очередь. Задание собирается
+// в момент постановки: файл, который за это время отменили или пропустили,
+// не ставится, а приоритет берётся текущий (его мог изменить PATCH /tasks/{id}).
+// Возвращает false, если постановку прервал stopRecovery.
+func (a *App) enqueueRecovered(files []recoveredFile) bool {
+	if len(files) == 0 {
+		return true
+	}
+	start := time.Now()
+	queued, resumed := 0, 0
+	for _, rf := range files {
+		a.mu.RLock()
+		t, ok := a.tasks.get(rf.taskID)
+		if !ok {
+			a.mu.RUnlock()
+			continue
+		}
+		t.Mutex().Lock()
+		f, _ := t.FileByID(rf.fileID)
+		if f == nil || f.State != core.FilePending || f.NextAttemptAt != nil {
+			a.unlockTask(t)
+			continue
+		}
+		job := jobFor(t, f)
+		job.Resume = rf.resume
+		a.unlockTask(t)
+
+		select {
+		case a.dispatcher.InChan() <- job:
+			queued++
+			if job.Resume {
+				resumed++
+			}
+			a.recovery.queued(queued)
+		case <-a.recoverStop:
+			log.Printf("Recovery: stopped after %d of %d file(s)", queued, len(files))
+			return false
+		}
+	}
+	log.Printf("Recovery: %d file(s) requeued in %s (%d interrupted download(s) first)",
+		queued, time.Since(start).Round(time.Millisecond), resumed)
+	return true
+}
+
+// Фазы восстановления (RecoveryStatus.Phase).
+const (
+	RecoveryStandby    = "standby"    // резервный экземпляр ждёт аренду лидера (LEADER_LEASE); API задач отвечает 503
+	RecoveryLoading    = "loading"    // чтение журнала; API задач отвечает 503
+	RecoveryEnqueueing = "enqueueing" // состояние загружено, файлы ставятся в очередь
+	RecoveryDone       = "done"
+	RecoveryFailed     = "failed"
+	RecoveryStopped    = "stopped" // прервано остановкой сервиса
+)
+
+// recoveryLogEvery — как часто ход чтения журнала пишется в лог.
+const recoveryLogEvery = 2 * time.Second
+
+// RecoveryStatus — ход восстановления после старта (GET /admin/recovery).
+type RecoveryStatus struct {
+	Phase         string     `json:"phase"`
+	Percent       float64    `json:"percent"` // прочитано журнала, %
+	BytesRead     int64      `json:"bytes_read"`
+	BytesTotal    int64      `json:"bytes_total"`
+	Records       int        `json:"records"`
+	RecordsPerSec float64    `json:"records_per_sec"`
+	Tasks         int        `json:"tasks"`
+	FilesQueued   int        `json:"files_queued"`
+	FilesTotal    int        `json:"files_total"` // Pending-файлов к постановке в очередь
+	StartedAt     time.Time  `json:"started_at"`
+	LoadedAt      *time.Time `json:"loaded_at,omitempty"` // состояние загружено, сервис готов
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	Elapsed       string     `json:"elapsed"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// recoveryTracker хранит RecoveryStatus; обновляется горутиной восстановления,
+// читается обработчиками HTTP.
+type recoveryTracker struct {
+	mu      sync.Mutex
+	st      RecoveryStatus
+	lastLog time.Time
+}
+
+// standby отмечает, что восстановление отложено до получения аренды лидера.
+func (r *recoveryTracker) standby(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.st = RecoveryStatus{Phase: RecoveryStandby, StartedAt: now.UTC()}
+}
+
+func (r *recoveryTracker) begin(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.st = RecoveryStatus{Phase: RecoveryLoading, StartedAt: now.UTC()}
+	r.lastLog = now
+}
+
+// progress учитывает ход чтения журнала; log=true — пора написать его в лог.
+func (r *recoveryTracker) progress(p store.ReadProgress, now time.Time) (st RecoveryStatus, log bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.st.BytesRead, r.st.BytesTotal, r.st.Records = p.BytesRead, p.BytesTotal, p.Records
+	if !p.Done && now.Sub(r.lastLog) >= recoveryLogEvery {
+		r.lastLog = now
+		log = true
+	}
+	return r.snapshotLocked(now), log
+}
+
+// enterEnqueue отмечает, что состояние загружено и files файлов ставятся в очередь.
+func (r *recoveryTracker) enterEnqueue(files int) RecoveryStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	at := now.UTC()
+	r.st.Phase, r.st.FilesTotal, r.st.LoadedAt = RecoveryEnqueueing, files, &at
+	return r.snapshotLocked(now)
+}
+
+func (r *recoveryTracker) setTasks(n int) {
+	r.mu.Lock()
+	r.st.Tasks = n
+	r.mu.Unlock()
+}
+
+func (r *recoveryTracker) queued(n int) {
+	r.mu.Lock()
+	r.st.FilesQueued = n
+	r.mu.Unlock()
+}
+
+func (r *recoveryTracker) finish(phase string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	at := time.Now().UTC()
+	r.st.Phase, r.st.FinishedAt = phase, &at
+	if err != nil {
+		r.st.Error = err.Error()
+	}
+}
+
+// snapshotLocked дополняет статус вычисляемыми полями на момент now.
+func (r *recoveryTracker) snapshotLocked(now time.Time) RecoveryStatus {
+	st := r.st
+	if st.BytesTotal > 0 {
+		st.Percent = math.Round(float64(st.BytesRead)/float64(st.BytesTotal)*1000) / 10
+	}
+	readEnd := now // скорость чтения журнала — до конца загрузки состояния
+	switch {
+	case st.LoadedAt != nil:
+		readEnd = *st.LoadedAt
+	case st.FinishedAt != nil:
+		readEnd = *st.FinishedAt
+	}
+	if d := readEnd.Sub(st.StartedAt); d > 0 {
+		st.RecordsPerSec = math.Round(float64(st.Records) / d.Seconds())
+	}
+	finished := now
+	if st.FinishedAt != nil {
+		finished = *st.FinishedAt
+	}
+	st.Elapsed = finished.Sub(st.StartedAt).Round(time.Millisecond).String()
+	return st
+}
+
+// Recovery возвращает ход восстановления после старта.
+func (a *App) Recovery() RecoveryStatus {
+	a.recovery.mu.Lock()
+	defer a.recovery.mu.Unlock()
+	return a.recovery.snapshotLocked(time.Now())
+}
+
+// Loaded сообщает, что состояние задач восстановлено из журнала и API задач
+// можно обслуживать (файлы при этом могут ещё ставиться в очередь).
+func (a *App) Loaded() bool { return a.loaded.Load() }
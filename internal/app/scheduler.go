@@ -0,0 +1,131 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/store"
+)
+
+// ErrBadHost — пустое или некорректное имя хоста для паузы.
+var ErrBadHost = errors.New("некорректное имя хоста")
+
+// Режим выдачи заданий (drain и приостановленные хосты) хранится в WAL
+// записью "scheduler" и восстанавливается при старте (restoreScheduler):
+// сервис, поставленный оператором на паузу, после перезапуска остаётся на паузе.
+
+// SetDrain включает/выключает «дренаж» очереди (пауза выдачи заданий воркерам)
+// и сохраняет режим в WAL. Выключение посреди окна обслуживания снимает
+// и его drain до конца окна (maintenance.go). Ошибка — режим переключён,
+// но не сохранён (после перезапуска он не восстановится).
+func (a *App) SetDrain(on bool) error {
+	a.schedMu.Lock()
+	defer a.schedMu.Unlock()
+	a.drainManual = on
+	if !on && a.maint.window != "" && !a.maint.lifted {
+		a.maint.lifted = true
+		log.Printf("Maintenance: window %s lifted by operator: dispatching resumed", a.maint.window)
+	}
+	a.applyDrainLocked()
+	return a.persistSchedulerLocked()
+}
+
+// IsDrain сообщает, включён ли «дренаж» очереди (оператором, окном
+// обслуживания или защитой свободного места).
+func (a *App) IsDrain() bool { return a.dispatcher.IsDrain() }
+
+// PauseHost приостанавливает выдачу заданий с хоста (queue.Dispatcher.PauseHost)
+// и сохраняет режим в WAL. changed=false — хост уже был на паузе.
+func (a *App) PauseHost(host string) (changed bool, err error) {
+	host, err = normalizeHost(host)
+	if err != nil {
+		return false, err
+	}
+	a.schedMu.Lock()
+	defer a.schedMu.Unlock()
+	if !a.dispatcher.PauseHost(host) {
+		return false, nil
+	}
+	log.Printf("Scheduler: host %s paused", host)
+	return true, a.persistSchedulerLocked()
+}
+
+// ResumeHost снимает паузу с хоста и сохраняет режим в WAL.
+// changed=false — хост не был на паузе.
+func (a *App) ResumeHost(host string) (changed bool, err error) {
+	host, err = normalizeHost(host)
+	if err != nil {
+		return false, err
+	}
+	a.schedMu.Lock()
+	defer a.schedMu.Unlock()
+	if !a.dispatcher.ResumeHost(host) {
+		return false, nil
+	}
+	log.Printf("Scheduler: host %s resumed", host)
+	return true, a.persistSchedulerLocked()
+}
+
+// PausedHosts возвращает приостановленные хосты по алфавиту.
+func (a *App) PausedHosts() []string { return a.dispatcher.PausedHosts() }
+
+// persistSchedulerLocked пишет текущий режим выдачи в WAL. Вызывать под a.schedMu,
+// чтобы записи параллельных переключений не легли в журнал в обратном порядке.
+func (a *App) persistSchedulerLocked() error {
+	s := store.SchedulerState{Drain: a.drainManual, PausedHosts: a.dispatcher.PausedHosts()}
+	if err := a.wal.AppendScheduler(s); err != nil {
+		log.Printf("Scheduler: failed to persist mode %s: %v", describeScheduler(s), err)
+		return err
+	}
+	return nil
+}
+
+// restoreScheduler применяет режим выдачи, сохранённый в WAL до перезапуска,
+// вместе с идущим окном обслуживания (maintenance.go) и пишет в лог, в каком
+// режиме стартует сервис. Вызывать после RecoverTasks и до постановки
+// восстановленных заданий в очередь.
+func (a *App) restoreScheduler() {
+	s := a.wal.Scheduler()
+	a.schedMu.Lock()
+	a.drainManual = s.Drain
+	a.maint = maintenanceState{window: a.Conf.maintenanceAt(time.Now())}
+	a.modeRestored = true
+	a.applyDrainLocked()
+	window := a.maint.window
+	a.schedMu.Unlock()
+	for _, h := range s.PausedHosts {
+		a.dispatcher.PauseHost(h)
+	}
+	if window != "" {
+		log.Printf("Maintenance: window %s in progress: no new jobs are dispatched until it ends", window)
+	}
+	if s.IsZero() {
+		log.Printf("Scheduler: starting in normal mode")
+		return
+	}
+	log.Printf("Scheduler: restored mode from WAL: %s", describeScheduler(s))
+}
+
+// describeScheduler — режим выдачи для лога.
+func describeScheduler(s store.SchedulerState) string {
+	mode := "running"
+	if s.Drain {
+		mode = "drain (no new jobs are dispatched until POST /admin/resume)"
+	}
+	if len(s.PausedHosts) > 0 {
+		mode += ", paused hosts: " + strings.Join(s.PausedHosts, ", ")
+	}
+	return mode
+}
+
+// normalizeHost приводит имя хоста к нижнему регистру и отбрасывает пустые
+// имена и имена с пробелами или '/'. Порт — часть имени, как в core.FileItem.Host.
+func normalizeHost(host string) (string, error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if host == "" || strings.ContainsAny(host, " /\t") {
+		return "", ErrBadHost
+	}
+	return host, nil
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/queue"
+)
+
+// Последовательные задачи (sequential: true) качают файлы строго в порядке
+// списка, по одному: нужно, когда следующие файлы ссылаются на предыдущие
+// (индекс раньше своих частей) или источник наказывает за параллельность.
+//
+// В очереди у такой задачи — задание только для следующего файла
+// (core.Task.NextSequential). Следующее ставится, когда файл завершился
+// (успехом или окончательной ошибкой) или отменён (advanceSequential).
+// Задания вне очереди — из retry, verify, восстановления после
+// перезапуска — воркер отбрасывает как устаревшие: их файлы дождутся своей
+// очереди.
+
+// queueJobsLocked — задания для Pending-файлов задачи t при постановке её в
+// очередь (регистрация, снятие с ожидания зависимостей); у последовательной
+// задачи — только задание для следующего по порядку файла. Вызывать под a.mu
+// на запись или под lockTask.
+func queueJobsLocked(t *core.Task) []queue.Job {
+	if t.Sequential {
+		if j, ok := sequentialJobLocked(t); ok {
+			return []queue.Job{j}
+		}
+		return nil
+	}
+	var jobs []queue.Job
+	for _, f := range t.Files {
+		if f.State == core.FilePending {
+			jobs = append(jobs, jobFor(t, f))
+		}
+	}
+	return jobs
+}
+
+// sequentialJobLocked — задание для следующего файла последовательной задачи
+// t; false — ставить нечего: файл ещё качается, ждёт автоповтора или задача
+// ждёт зависимостей. Вызывать под a.mu на запись или под lockTask.
+func sequentialJobLocked(t *core.Task) (queue.Job, bool) {
+	if t.Waiting {
+		return queue.Job{}, false
+	}
+	f := t.NextSequential()
+	if f == nil || f.NextAttemptAt != nil {
+		return queue.Job{}, false
+	}
+	return jobFor(t, f), true
+}
+
+// advanceSequential ставит в очередь следующий файл последовательной задачи
+// t, когда её текущий файл завершился или отменён. У обычных и удалённых
+// задач ничего не делает.
+func (a *App) advanceSequential(t *core.Task) {
+	if !t.Sequential {
+		return
+	}
+	a.lockTask(t)
+	j, ok := sequentialJobLocked(t)
+	if !a.tasks.current(t) {
+		ok = false
+	}
+	a.unlockTask(t)
+	if ok {
+		a.dispatcher.InChan() <- j
+	}
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ListenAddrs возвращает адреса публичного API.
+// Если Listen пуст — единственный адрес из Addr() (т.е. PORT).
+func (c *Config) ListenAddrs() []string {
+	if len(c.Listen) == 0 {
+		return []string{c.Addr()}
+	}
+	return c.Listen
+}
+
+// SeparateAdmin сообщает, вынесены ли админские эндпоинты на отдельные слушатели.
+func (c *Config) SeparateAdmin() bool { return len(c.AdminListen) > 0 }
+
+// listen открывает слушатель по адресу addr.
+//
+// Форматы:
+//   - "unix:///run/downloader.sock" — unix-сокет; «мёртвый» файл сокета
+//     от предыдущего запуска удаляется перед bind;
+//   - всё остальное ("host:port", ":port") — TCP.
+//
+// TLS (если настроен) накладывается только на TCP-слушатели:
+// unix-сокет доступен лишь локально и защищается правами на файл.
+func listen(addr string, tlsConf *tls.Config) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(path)
+		}
+		return net.Listen("unix", path)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConf != nil {
+		ln = tls.NewListener(ln, tlsConf)
+	}
+	return ln, nil
+}
+
+// Serve запускает HTTP-серверы и блокируется до их остановки.
+//
+// Поведение:
+//   - для каждого адреса из a.Conf.ListenAddrs() поднимает отдельный http.Server
+//     с обработчиком handler; адреса вида unix:// слушаются как unix-сокеты;
+//   - если задан a.Conf.AdminListen — так же поднимает серверы с обработчиком admin
+//     (админка/метрики/pprof на отдельном, например локальном, адресе);
+//   - при настроенном TLS TCP-слушатели работают по HTTPS;
+//   - параллельно ждёт SIGINT/SIGTERM и при получении делает graceful shutdown:
+//     Shutdown всех серверов (общий ctx с таймаутом a.Conf.ShutdownWait,
+//     по умолчанию 20s) + a.Close();
+//   - если любой сервер упал с ошибкой (не http.ErrServerClosed) или фоновое
+//     восстановление не смогло прочитать журнал — останавливает серверы
+//     и возвращает эту ошибку; при штатном завершении возвращает nil.
+//
+// Ошибки закрытия (Shutdown/Close) логируются, но не пробрасываются.
+func (a *App) Serve(handler, admin http.Handler) error {
+	tlsConf, err := a.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	type binding struct {
+		addr    string
+		handler http.Handler
+	}
+	var bindings []binding
+	for _, addr := range a.Conf.ListenAddrs() {
+		bindings = append(bindings, binding{addr, handler})
+	}
+	if admin != nil {
+		for _, addr := range a.Conf.AdminListen {
+			bindings = append(bindings, binding{addr, admin})
+		}
+	}
+
+	var (
+		servers   []*http.Server
+		listeners []net.Listener
+	)
+	for _, b := range bindings {
+		ln, err := listen(b.addr, tlsConf)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("listen %s: %w", b.addr, err)
+		}
+		listeners = append(listeners, ln)
+		servers = append(servers, &http.Server{Handler: b.handler, TLSConfig: tlsConf})
+	}
+
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	errCh := make(chan error, len(servers))
+	for i, srv := range servers {
+		ln, addr := listeners[i], bindings[i].addr
+		scheme := "HTTP"
+		if tlsConf != nil && !strings.HasPrefix(addr, "unix://") {
+			scheme = "HTTPS"
+		}
+		go func() {
+			log.Printf("%s: listen on %s", scheme, addr)
+			errCh <- srv.Serve(ln)
+		}()
+	}
+
+	var serveErr error
+	select {
+	case err := <-errCh:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr = err
+		}
+	case sig := <-sigCh:
+		log.Printf("Signal: %v — graceful shutdown", sig)
+	case err := <-a.fatal:
+		serveErr = err
+	}
+	a.stopping.Store(true)
+
+	wait := a.Conf.ShutdownWait
+	if wait <= 0 {
+		wait = 20 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Printf("shutdown error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if closeErr := a.Close(); closeErr != nil {
+		log.Printf("close error: %v", closeErr)
+	}
+	return serveErr
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// DefaultSignedURLTTL — срок жизни подписанной ссылки, если клиент его не указал.
+const DefaultSignedURLTTL = time.Hour
+
+var (
+	// ErrSigningDisabled — SIGNED_URL_KEY не задан, подписанные ссылки выключены.
+	ErrSigningDisabled = errors.New("signed urls are not configured")
+	// ErrBadSignature — подпись ссылки не сходится (подделка или другой ключ).
+	ErrBadSignature = errors.New("invalid signature")
+	// ErrLinkExpired — срок действия подписанной ссылки истёк.
+	ErrLinkExpired = errors.New("link expired")
+	// ErrFileNotReady — файл ещё не скачан (не DONE), содержимого нет.
+	ErrFileNotReady = errors.New("file is not downloaded")
+)
+
+// SignFileURL подписывает доступ к содержимому файла fileID задачи taskID
+// на срок ttl (0 — DefaultSignedURLTTL; не больше SignedURLMaxTTL).
+// Возвращает момент истечения и подпись — параметры expires и sig ссылки
+// GET /tasks/{id}/files/{fid}/content. Клиенту с арендатором tenant ссылка на
+// задачу другого арендатора не выдаётся — ErrNotFound, как на несуществующую.
+//
+// Подпись — HMAC-SHA256 ключом SIGNED_URL_KEY над ID задачи, ID файла и
+// временем истечения, поэтому ссылка открывает ровно один файл и только
+// до expires; смена ключа отзывает все выданные ссылки разом.
+func (a *App) SignFileURL(taskID, fileID, tenant string, ttl time.Duration) (time.Time, string, error) {
+	if a.Conf.SignedURLKey == "" {
+		return time.Time{}, "", ErrSigningDisabled
+	}
+	if ttl <= 0 {
+		ttl = DefaultSignedURLTTL
+	}
+	if ttl > a.Conf.SignedURLMaxTTL {
+		ttl = a.Conf.SignedURLMaxTTL
+	}
+	if owner, ok := a.TaskTenant(taskID); !ok || tenant != "" && owner != tenant {
+		return time.Time{}, "", ErrNotFound
+	}
+	if _, _, ok := a.FileSnapshot(taskID, fileID); !ok {
+		return time.Time{}, "", ErrNotFound
+	}
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	return expires, a.fileSignature(taskID, fileID, expires.Unix()), nil
+}
+
+// VerifyFileURL проверяет параметры expires (Unix-время) и sig подписанной ссылки.
+func (a *App) VerifyFileURL(taskID, fileID, expires, sig string) error {
+	if a.Conf.SignedURLKey == "" {
+		return ErrSigningDisabled
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	want := a.fileSignature(taskID, fileID, exp)
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return ErrBadSignature
+	}
+	if time.Now().Unix() > exp {
+		return ErrLinkExpired
+	}
+	return nil
+}
+
+// fileSignature — base64url(HMAC-SHA256(key, "taskID\nfileID\nexpires")).
+func (a *App) fileSignature(taskID, fileID string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(a.Conf.SignedURLKey))
+	mac.Write([]byte(taskID + "\n" + fileID + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// FileContent возвращает снимок скачанного файла (путь на диске — FileItem.Path).
+// ErrNotFound — нет задачи или файла; ErrFileNotReady — файл не в DONE;
+// ErrRemoteFile — файл записан в S3 (вместе со снимком: адрес — в Path).
+func (a *App) FileContent(taskID, fileID string) (*core.FileItem, error) {
+	f, _, ok := a.FileSnapshot(taskID, fileID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if f.State != core.FileDone || f.Path == "" {
+		return nil, ErrFileNotReady
+	}
+	if storage.IsObject(f.Path) {
+		return f, ErrRemoteFile
+	}
+	return f, nil
+}
@@ -0,0 +1,273 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/metrics"
+	"github.com/Extrarius/29.09.2025/internal/sink"
+)
+
+// uploadWorkers — сколько файлов выгружается в хранилища одновременно.
+const uploadWorkers = 2
+
+// SinkConfig — хранилище для выгрузки скачанных файлов (секция sinks файла
+// конфигурации): адрес (см. sink.New) и удаление локальной копии после выгрузки.
+type SinkConfig struct {
+	URL         string
+	DeleteLocal bool
+}
+
+// sinkTarget — открытое хранилище из Config.Sinks.
+type sinkTarget struct {
+	sink        sink.Sink
+	deleteLocal bool
+}
+
+// uploadJob — файл, ждущий выгрузки.
+type uploadJob struct{ taskID, fileID string }
+
+// uploader — очередь и исполнители выгрузки (uploadLoop).
+type uploader struct {
+	targets map[string]sinkTarget
+
+	mu    sync.Mutex
+	queue []uploadJob // под mu
+	wake  chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	uploaded metrics.Counter
+	failed   metrics.Counter // неудачных попыток (в том числе с повтором)
+}
+
+// openSinks создаёт хранилища из конфигурации; nil — выгрузка не настроена.
+func openSinks(conf map[string]SinkConfig) (*uploader, error) {
+	if len(conf) == 0 {
+		return nil, nil
+	}
+	u := &uploader{targets: make(map[string]sinkTarget, len(conf)), wake: make(chan struct{}, 1)}
+	for name, sc := range conf {
+		s, err := sink.New(sc.URL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("sinks.%s: %w", name, err)
+		}
+		u.targets[name] = sinkTarget{sink: s, deleteLocal: sc.DeleteLocal}
+	}
+	u.ctx, u.cancel = context.WithCancel(context.Background())
+	return u, nil
+}
+
+// HasSink сообщает, настроено ли хранилище name (для проверки TaskSpec.Sink).
+func (a *App) HasSink(name string) bool {
+	if a.uploads == nil {
+		return false
+	}
+	_, ok := a.uploads.targets[name]
+	return ok
+}
+
+// startUploads запускает исполнителей выгрузки (если хранилища настроены).
+func (a *App) startUploads() {
+	if a.uploads == nil {
+		return
+	}
+	for range uploadWorkers {
+		a.uploads.wg.Add(1)
+		go a.uploadLoop()
+	}
+}
+
+// stopUploads прерывает идущие выгрузки и ждёт исполнителей. Невыгруженные
+// файлы остаются в состоянии UploadPending и выгружаются после перезапуска
+// (resumeUploads).
+func (a *App) stopUploads() {
+	if a.uploads == nil {
+		return
+	}
+	a.uploads.cancel()
+	a.uploads.wg.Wait()
+}
+
+// enqueueUpload ставит файл в очередь выгрузки.
+func (a *App) enqueueUpload(taskID, fileID string) {
+	u := a.uploads
+	if u == nil || u.ctx.Err() != nil {
+		return
+	}
+	u.mu.Lock()
+	u.queue = append(u.queue, uploadJob{taskID, fileID})
+	u.mu.Unlock()
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// resumeUploads ставит в очередь файлы, не выгруженные до остановки сервиса
+// (вызывается после восстановления).
+func (a *App) resumeUploads() {
+	if a.uploads == nil {
+		return
+	}
+	var jobs []uploadJob
+	a.mu.RLock()
+	for _, t := range a.tasks.all() {
+		t.Mutex().Lock()
+		for _, f := range t.Files {
+			if f.State == core.FileDone && f.Upload != nil && f.Upload.State == core.UploadPending {
+				jobs = append(jobs, uploadJob{t.ID, f.ID})
+			}
+		}
+		t.Mutex().Unlock()
+	}
+	a.mu.RUnlock()
+	for _, j := range jobs {
+		a.enqueueUpload(j.taskID, j.fileID)
+	}
+	if len(jobs) > 0 {
+		log.Printf("Sinks: %d file(s) queued for upload after restart", len(jobs))
+	}
+}
+
+// uploadsPending — длина очереди выгрузки (метрика).
+func (a *App) uploadsPending() int {
+	if a.uploads == nil {
+		return 0
+	}
+	a.uploads.mu.Lock()
+	defer a.uploads.mu.Unlock()
+	return len(a.uploads.queue)
+}
+
+// uploadLoop — исполнитель выгрузки: берёт файлы из очереди до stopUploads.
+func (a *App) uploadLoop() {
+	u := a.uploads
+	defer u.wg.Done()
+	for {
+		u.mu.Lock()
+		var job uploadJob
+		ok := len(u.queue) > 0
+		if ok {
+			job = u.queue[0]
+			u.queue = u.queue[1:]
+		}
+		u.mu.Unlock()
+		if !ok {
+			select {
+			case <-u.ctx.Done():
+				return
+			case <-u.wake:
+			}
+			continue
+		}
+		a.uploadFile(job)
+		if u.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// uploadFile выгружает один файл в хранилище его задачи:
+//   - ключ объекта — "<ID задачи>/<путь файла внутри каталога задачи>";
+//   - успех — FileUpload.State = UploadDone, адрес объекта в Location; при
+//     delete_local локальная копия удаляется (ссылка на blob BLOB_DIR снимается);
+//   - ошибка — повтор после паузы RETRY_BACKOFF·2^(N-1) (не больше
+//     RETRY_BACKOFF_MAX), всего до RETRIES попыток, затем UploadFailed;
+//   - итог пишется в WAL и историю задачи (EventFileUploaded).
+//
+// Файлы удалённой задачи и уже выгруженные пропускаются.
+func (a *App) uploadFile(job uploadJob) {
+	u := a.uploads
+	a.mu.RLock()
+	t, ok := a.tasks.get(job.taskID)
+	if !ok {
+		a.mu.RUnlock()
+		return
+	}
+	t.Mutex().Lock()
+	fi, _ := t.FileByID(job.fileID)
+	if fi == nil || fi.State != core.FileDone || fi.Upload == nil || fi.Upload.State != core.UploadPending {
+		a.unlockTask(t)
+		return
+	}
+	sinkName, path, blob := fi.Upload.Sink, fi.Path, fi.Blob
+	key := t.ID + "/" + filepath.ToSlash(fi.Filename)
+	if rel, err := filepath.Rel(a.taskDestDir(t), path); err == nil && !strings.HasPrefix(rel, "..") {
+		key = t.ID + "/" + filepath.ToSlash(rel)
+	}
+	a.unlockTask(t)
+
+	target, configured := u.targets[sinkName]
+	var loc string
+	err := fmt.Errorf("sink %q is not configured", sinkName)
+	if configured {
+		loc, err = target.sink.Upload(u.ctx, key, path)
+	}
+	if u.ctx.Err() != nil {
+		return // остановка сервиса: файл остаётся UploadPending
+	}
+
+	now := time.Now().UTC()
+	a.mu.Lock()
+	if !a.tasks.current(t) || fi.Upload == nil || fi.Upload.State != core.UploadPending {
+		a.mu.Unlock()
+		return
+	}
+	up := fi.Upload
+	up.Attempts++
+	var retryIn time.Duration
+	removeLocal := false
+	if err == nil {
+		u.uploaded.Inc()
+		up.State, up.Error, up.Location, up.UploadedAt = core.UploadDone, "", loc, &now
+		if target.deleteLocal {
+			removeLocal, up.LocalDeleted, fi.Blob = true, true, ""
+		}
+	} else {
+		u.failed.Inc()
+		up.Error = err.Error()
+		if configured && up.Attempts < a.Conf.Retries {
+			retryIn = retryDelay(a.Conf.RetryBackoff, a.Conf.RetryBackoffMax, up.Attempts)
+		} else {
+			up.State = core.UploadFailed
+		}
+	}
+	msg := loc
+	if err != nil {
+		msg = fmt.Sprintf("attempt %d: %v", up.Attempts, err)
+	}
+	evs := []core.TaskEvent{t.AddEvent(core.TaskEvent{
+		At:      now,
+		Type:    core.EventFileUploaded,
+		FileID:  fi.ID,
+		Status:  up.State,
+		Message: msg,
+	})}
+	if ev, changed := t.RecomputeStatusEvent(now); changed {
+		evs = append(evs, ev)
+	}
+	snap := fi.Clone()
+	a.mu.Unlock()
+
+	_ = a.wal.AppendFile(t.ID, snap)
+	a.recordEvents(t, evs...)
+	switch {
+	case removeLocal:
+		a.removeTaskFile(path, blob)
+	case retryIn > 0:
+		log.Printf("Sinks: upload %s/%s to %s failed (attempt %d), retry in %s: %v%s",
+			t.ID, snap.ID, sinkName, snap.Upload.Attempts, retryIn, err, requestTag(t.RequestID))
+		time.AfterFunc(retryIn, func() { a.enqueueUpload(job.taskID, job.fileID) })
+	case err != nil:
+		log.Printf("Sinks: upload %s/%s to %s failed: %v%s", t.ID, snap.ID, sinkName, err, requestTag(t.RequestID))
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// ErrNotSkippable — файл нельзя пропустить: он качается или уже завершён
+// (SkipFile пропускает только Pending и Failed).
+var ErrNotSkippable = errors.New("пропустить можно только ждущий или упавший файл")
+
+// SkipFile переводит файл fileID задачи taskID из Pending или Failed в Skipped
+// (POST /tasks/{id}/files/{fid}/skip), чтобы задача завершилась без него:
+// пропущенный файл не считается ни скачанным, ни упавшим (core.Task.RecomputeStatus).
+// reason — причина для истории файла (пусто — "skipped by request").
+// Изменение пишется в WAL и историю задачи (EventFileSkipped); задача,
+// дошедшая так до COMPLETE/PARTIAL, завершается как в workerLoop: опись и
+// снятие ожидания с зависимых задач. Задание файла, оставшееся в очереди,
+// воркер пропустит. Возвращает копию файла.
+//
+// ErrNotFound — нет задачи или файла; ErrNotSkippable — файл не Pending и не Failed.
+func (a *App) SkipFile(taskID, fileID, reason string) (*core.FileItem, error) {
+	if reason == "" {
+		reason = "skipped by request"
+	}
+	now := time.Now().UTC()
+	a.mu.Lock()
+	t, ok := a.tasks.get(taskID)
+	if !ok {
+		a.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	f, _ := t.FileByID(fileID)
+	if f == nil {
+		a.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if f.State != core.FilePending && f.State != core.FileFailed {
+		state := f.State
+		a.mu.Unlock()
+		return nil, fmt.Errorf("%w: файл %s в состоянии %s", ErrNotSkippable, fileID, state)
+	}
+	_ = f.Transition(core.FileSkipped, reason, now) // Pending и Failed → Skipped допустимы
+	evs := []core.TaskEvent{t.AddEvent(core.TaskEvent{
+		At:      now,
+		Type:    core.EventFileSkipped,
+		FileID:  f.ID,
+		Status:  string(core.FileSkipped),
+		Message: reason,
+	})}
+	if ev, changed := t.RecomputeStatusEvent(now); changed {
+		evs = append(evs, ev)
+	}
+	fsnap := f.Clone()
+	snap := t.Clone()
+	a.mu.Unlock()
+
+	_ = a.wal.AppendFile(taskID, fsnap)
+	a.recordEvents(snap, evs...)
+	if snap.Status.Terminal() {
+		a.logins.drop(taskID)
+	}
+	if snap.Status == core.TaskComplete || snap.Status == core.TaskPartial {
+		a.writeManifest(snap)
+		a.releaseDependents(taskID)
+	}
+	a.advanceSequential(t) // пропущенный файл мог быть текущим в последовательной задаче
+	return fsnap, nil
+}
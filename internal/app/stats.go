@@ -0,0 +1,211 @@
+package app
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// Сводная статистика (GET /stats). Счётчики ведутся по ходу работы, а не
+// обходом всех задач на каждый запрос:
+//   - статусы задач — по регистрации (registerTask, recoverFromWAL), удалению
+//     (DeleteTask) и событиям EventStatusChanged (recordEvents);
+//   - байты за сегодня и за неделю — из учёта потребления (usage.go), он же
+//     переживает перезапуск;
+//   - объём по хостам, длительность загрузок и текущая скорость — от воркеров,
+//     с момента старта сервиса.
+
+const (
+	statsTopHosts   = 10  // хостов в Stats.TopHosts по умолчанию
+	statsMaxHosts   = 100 // верхняя граница ?top=
+	statsRateWindow = 10  // секунд в окне текущей скорости
+)
+
+// Stats — ответ GET /stats.
+type Stats struct {
+	Tasks          int                     `json:"tasks"`
+	ByStatus       map[core.TaskStatus]int `json:"by_status"`
+	BytesToday     int64                   `json:"bytes_today"` // успешные загрузки с полуночи UTC
+	BytesWeek      int64                   `json:"bytes_week"`  // с понедельника текущей недели (UTC)
+	WeekStart      string                  `json:"week_start"`  // этот понедельник, "2006-01-02"
+	TopHosts       []HostVolume            `json:"top_hosts"`   // по убыванию объёма, с момента старта
+	Downloads      int64                   `json:"downloads"`   // успешных загрузок с момента старта
+	AvgDuration    float64                 `json:"avg_duration_seconds"`
+	ThroughputRate float64                 `json:"throughput_bytes_per_sec"` // за последние statsRateWindow секунд
+	Since          time.Time               `json:"since"`                    // старт сервиса
+}
+
+// HostVolume — скачанный с хоста объём (Stats.TopHosts).
+type HostVolume struct {
+	Host  string `json:"host"`
+	Bytes int64  `json:"bytes"`
+	Files int64  `json:"files"`
+}
+
+// taskStats — счётчики Stats. Нулевое значение готово к работе.
+type taskStats struct {
+	mu       sync.Mutex
+	status   map[string]statusMark // ID задачи → последний учтённый статус
+	byStatus map[core.TaskStatus]int
+	hosts    map[string]*HostVolume
+	files    int64
+	took     time.Duration // суммарная длительность успешных загрузок
+
+	// байты за секунду: rate[i] относится к секунде rateSec[i] (Unix)
+	rate    [statsRateWindow + 1]int64
+	rateSec [statsRateWindow + 1]int64
+}
+
+// statusMark — статус задачи и момент его смены: события recordEvents могут
+// прийти не по порядку, устаревшее не должно затереть свежее.
+type statusMark struct {
+	status core.TaskStatus
+	at     time.Time
+}
+
+// setLocked заменяет учтённый статус задачи id на st. Вызывать под s.mu.
+func (s *taskStats) setLocked(id string, st core.TaskStatus, at time.Time) {
+	if s.status == nil {
+		s.status = make(map[string]statusMark)
+		s.byStatus = make(map[core.TaskStatus]int)
+	}
+	if old, ok := s.status[id]; ok {
+		s.byStatus[old.status]--
+		if s.byStatus[old.status] == 0 {
+			delete(s.byStatus, old.status)
+		}
+	}
+	s.status[id] = statusMark{status: st, at: at}
+	s.byStatus[st]++
+}
+
+// added учитывает зарегистрированную задачу t. Вызывать под a.mu на запись,
+// вместе с a.tasks.put: воркеры ещё не могут сменить её статус.
+func (s *taskStats) added(t *core.Task) {
+	s.mu.Lock()
+	s.setLocked(t.ID, t.Status, time.Time{})
+	s.mu.Unlock()
+}
+
+// removed забывает удалённую задачу id.
+func (s *taskStats) removed(id string) {
+	s.mu.Lock()
+	if old, ok := s.status[id]; ok {
+		delete(s.status, id)
+		s.byStatus[old.status]--
+		if s.byStatus[old.status] == 0 {
+			delete(s.byStatus, old.status)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// events учитывает смены статуса задачи id из events. Задачи, которой нет
+// среди учтённых (удалена), и события старше учтённого не меняют счётчиков.
+func (s *taskStats) events(id string, events []core.TaskEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ev := range events {
+		if ev.Type != core.EventStatusChanged {
+			continue
+		}
+		if old, ok := s.status[id]; ok && !ev.At.Before(old.at) {
+			s.setLocked(id, core.TaskStatus(ev.Status), ev.At)
+		}
+	}
+}
+
+// progress учитывает n байт, записанных в момент now (текущая скорость).
+func (s *taskStats) progress(now time.Time, n int64) {
+	if n <= 0 {
+		return
+	}
+	sec := now.Unix()
+	i := sec % int64(len(s.rate))
+	s.mu.Lock()
+	if s.rateSec[i] != sec {
+		s.rateSec[i], s.rate[i] = sec, 0
+	}
+	s.rate[i] += n
+	s.mu.Unlock()
+}
+
+// downloaded учитывает успешную загрузку n байт с хоста host за took.
+func (s *taskStats) downloaded(host string, n int64, took time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hosts == nil {
+		s.hosts = make(map[string]*HostVolume)
+	}
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &HostVolume{Host: host}
+		s.hosts[host] = h
+	}
+	h.Bytes += n
+	h.Files++
+	s.files++
+	s.took += took
+}
+
+// throughputLocked — байт в секунду за statsRateWindow полных секунд до now.
+// Вызывать под s.mu.
+func (s *taskStats) throughputLocked(now time.Time) float64 {
+	sec := now.Unix()
+	var sum int64
+	for i, at := range s.rateSec {
+		if at < sec && at >= sec-statsRateWindow {
+			sum += s.rate[i]
+		}
+	}
+	return float64(sum) / statsRateWindow
+}
+
+// Stats собирает сводную статистику; top — сколько хостов вернуть в TopHosts
+// (0 — statsTopHosts, не больше statsMaxHosts).
+func (a *App) Stats(top int) Stats {
+	if top <= 0 {
+		top = statsTopHosts
+	}
+	top = min(top, statsMaxHosts)
+	now := time.Now().UTC()
+	today := usageDay(now)
+	week := now.AddDate(0, 0, -(int(now.Weekday())+6)%7)
+	st := Stats{WeekStart: usageDay(week), Since: a.startedAt, TopHosts: []HostVolume{}}
+	for _, r := range a.usage.Records(st.WeekStart, today) {
+		st.BytesWeek += r.Bytes
+		if r.Day == today {
+			st.BytesToday += r.Bytes
+		}
+	}
+
+	s := &a.stats
+	s.mu.Lock()
+	st.Tasks = len(s.status)
+	st.ByStatus = make(map[core.TaskStatus]int, len(s.byStatus))
+	for k, n := range s.byStatus {
+		st.ByStatus[k] = n
+	}
+	for _, h := range s.hosts {
+		st.TopHosts = append(st.TopHosts, *h)
+	}
+	st.Downloads = s.files
+	if s.files > 0 {
+		st.AvgDuration = (s.took / time.Duration(s.files)).Seconds()
+	}
+	st.ThroughputRate = s.throughputLocked(now)
+	s.mu.Unlock()
+
+	sort.Slice(st.TopHosts, func(i, j int) bool {
+		if st.TopHosts[i].Bytes != st.TopHosts[j].Bytes {
+			return st.TopHosts[i].Bytes > st.TopHosts[j].Bytes
+		}
+		return st.TopHosts[i].Host < st.TopHosts[j].Host
+	})
+	if len(st.TopHosts) > top {
+		st.TopHosts = st.TopHosts[:top]
+	}
+	return st
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// ErrRemoteFile — файл лежит в объектном хранилище (dest_dir "s3://…"):
+// сервис не отдаёт его содержимое и не упаковывает в архив.
+var ErrRemoteFile = errors.New("file is stored in object storage")
+
+// openObjectStore создаёт хранилище для задач с dest_dir "s3://bucket/prefix":
+// параметры — S3_ENDPOINT, S3_REGION, S3_PATH_STYLE, ключи — AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY (и AWS_SESSION_TOKEN) из окружения. Ключи не заданы —
+// nil: такие dest_dir отвергаются (если не заданы и S3_* — это не ошибка).
+func openObjectStore(c *Config) (*storage.S3, error) {
+	access, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if access == "" || secret == "" {
+		if c.S3Endpoint != "" || c.S3Region != "" {
+			return nil, errors.New("не заданы ключи доступа AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY")
+		}
+		return nil, nil
+	}
+	return storage.NewS3(storage.S3Options{
+		Endpoint:  c.S3Endpoint,
+		Region:    c.S3Region,
+		PathStyle: c.S3PathStyle,
+		AccessKey: access,
+		SecretKey: secret,
+		Token:     os.Getenv("AWS_SESSION_TOKEN"),
+	})
+}
+
+// objectDestDir проверяет dest_dir вида "s3://bucket[/prefix]" и возвращает
+// его без завершающего "/".
+func (a *App) objectDestDir(dir string) (string, error) {
+	if !a.objects {
+		return "", i18n.New(i18n.CodeObjectStoreOff)
+	}
+	rest := strings.TrimRight(strings.TrimPrefix(dir, storage.ObjectScheme), "/")
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" || strings.Contains(prefix, "//") || strings.Contains("/"+prefix+"/", "/../") {
+		return "", i18n.New(i18n.CodeObjectDestDir, dir)
+	}
+	return storage.ObjectScheme + rest, nil
+}
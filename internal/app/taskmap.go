@@ -0,0 +1,143 @@
+package app
+
+import (
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// taskShards — число шардов taskMap. Степень двойки: шард выбирается маской.
+const taskShards = 32
+
+// taskMap — карта задач по ID, разбитая на шарды со своими блокировками.
+//
+// Изменяется карта по-прежнему только под a.mu на запись (см. App.mu), так что
+// все инварианты сервиса, завязанные на a.mu, сохраняются. Шарды нужны
+// читателям: GetTask, ListTasks и Diagnostics обходятся без a.mu и не
+// встают в очередь за писателем (создание, удаление, массовые операции) —
+// а значит, не задерживают воркеров, которым a.mu на чтение нужна сразу
+// после писателя. Поиск блокирует один шард, обход — шарды по одному.
+//
+// Нулевое значение готово к работе.
+type taskMap struct {
+	shards [taskShards]taskShard
+}
+
+type taskShard struct {
+	mu sync.RWMutex
+	m  map[string]*core.Task
+}
+
+// shard — шард задачи id (FNV-1a по ID).
+func (m *taskMap) shard(id string) *taskShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+	return &m.shards[h&(taskShards-1)]
+}
+
+// get возвращает задачу id.
+func (m *taskMap) get(id string) (*core.Task, bool) {
+	s := m.shard(id)
+	s.mu.RLock()
+	t, ok := s.m[id]
+	s.mu.RUnlock()
+	return t, ok
+}
+
+// current сообщает, что задача t всё ещё в карте: её не удалили и не
+// заменили другой с тем же ID.
+func (m *taskMap) current(t *core.Task) bool {
+	cur, ok := m.get(t.ID)
+	return ok && cur == t
+}
+
+// put кладёт задачу t (заменяя задачу с тем же ID). Вызывать под a.mu на запись.
+func (m *taskMap) put(t *core.Task) {
+	s := m.shard(t.ID)
+	s.mu.Lock()
+	if s.m == nil {
+		s.m = make(map[string]*core.Task)
+	}
+	s.m[t.ID] = t
+	s.mu.Unlock()
+}
+
+// remove убирает задачу id. Вызывать под a.mu на запись.
+func (m *taskMap) remove(id string) {
+	s := m.shard(id)
+	s.mu.Lock()
+	delete(s.m, id)
+	s.mu.Unlock()
+}
+
+// len — число задач.
+func (m *taskMap) len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// all возвращает все задачи («живые» объекты) в произвольном порядке. Под
+// a.mu это точный снимок карты; без неё — задачи, созданные или удалённые
+// во время обхода, могут попасть или не попасть в результат.
+func (m *taskMap) all() []*core.Task {
+	out := make([]*core.Task, 0, m.len())
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		for _, t := range s.m {
+			out = append(out, t)
+		}
+		s.mu.RUnlock()
+	}
+	return out
+}
+
+// taskIDAttempts — сколько раз выдать задаче новый ID, если выданный уже
+// занят (newTaskID, addNewTask).
+const taskIDAttempts = 5
+
+// newTaskID генерирует ID новой задачи в формате Conf.TaskIDFormat, которого
+// ещё нет в карте. Коллизия маловероятна (у формата timestamp — 1 из 16,7
+// млн в одну секунду), но задача с тем же ID не должна тихо заменить
+// существующую; свободу ID при регистрации всё равно проверяет registerTask.
+func (a *App) newTaskID() string {
+	id := core.NewIDFormat(a.Conf.TaskIDFormat)
+	for i := 1; i < taskIDAttempts; i++ {
+		if _, dup := a.tasks.get(id); !dup {
+			break
+		}
+		log.Printf("Tasks: generated ID %s is already taken, generating another", id)
+		id = core.NewIDFormat(a.Conf.TaskIDFormat)
+	}
+	return id
+}
+
+// addNewTask — addTask с проверкой лимитов для задачи, собранной buildTask.
+// Если её ID заняли между сборкой и регистрацией (параллельное создание,
+// импорт архива), задача получает новый ID (newTaskID; каталог по умолчанию
+// DownloadDir/<ID> — тоже) и регистрируется снова, до taskIDAttempts раз.
+func (a *App) addNewTask(t *core.Task) error {
+	for i := 1; ; i++ {
+		err := a.addTask(t, true)
+		if err != ErrTaskExists || i == taskIDAttempts {
+			return err
+		}
+		old := t.ID
+		t.ID = a.newTaskID()
+		if t.DestDir == filepath.Join(a.Conf.DownloadDir, old) {
+			t.DestDir = filepath.Join(a.Conf.DownloadDir, t.ID)
+		}
+		log.Printf("Tasks: ID %s was taken while the task was being created, using %s", old, t.ID)
+	}
+}
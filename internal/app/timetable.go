@@ -0,0 +1,225 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Расписание (секция schedule файла конфигурации) меняет число работающих
+// воркеров и ограничение скорости загрузок по времени суток — например,
+// ночью 16 воркеров без ограничения, днём 4 воркера и 10MB/s, чтобы
+// не забивать канал, общий с дневным трафиком. Время — местное (TZ).
+
+// Bandwidth — скорость в байтах в секунду; 0 — без ограничения.
+// В конфигурации пишется как "10MB", "512KB/s", "1.5GB" (единицы двоичные:
+// 1KB = 1024 байт) или "unlimited".
+type Bandwidth int64
+
+// ParseBandwidth разбирает скорость вида "10MB", "10MB/s", "800K", "0" или "unlimited".
+func ParseBandwidth(s string) (Bandwidth, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	if v == "unlimited" || v == "none" {
+		return 0, nil
+	}
+	n, err := ParseByteSize(strings.TrimSuffix(v, "/s"))
+	if err != nil {
+		return 0, fmt.Errorf("некорректная скорость %q", s)
+	}
+	return Bandwidth(n), nil
+}
+
+// String — скорость в виде, который принимает ParseBandwidth.
+func (b Bandwidth) String() string {
+	if b <= 0 {
+		return "unlimited"
+	}
+	for _, u := range []struct {
+		name string
+		size Bandwidth
+	}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if b >= u.size && b%u.size == 0 {
+			return strconv.FormatInt(int64(b/u.size), 10) + u.name + "/s"
+		}
+	}
+	return strconv.FormatInt(int64(b), 10) + "B/s"
+}
+
+// Set и UnmarshalText позволяют читать Bandwidth из флагов и файла конфигурации.
+func (b *Bandwidth) Set(s string) error {
+	v, err := ParseBandwidth(s)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+func (b *Bandwidth) UnmarshalText(text []byte) error { return b.Set(string(text)) }
+
+// ScheduleWindow — период суток со своими лимитами. From и To — минуты
+// от полуночи; To <= From — окно переходит через полночь ("22:00"–"06:00").
+type ScheduleWindow struct {
+	From, To  int
+	Workers   int        // воркеров в окне; 0 — Workers
+	Bandwidth *Bandwidth // ограничение скорости в окне; nil — BandwidthLimit
+}
+
+// ParseClock разбирает время суток "HH:MM" в минуты от полуночи.
+func ParseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hh, err1 := strconv.Atoi(h)
+	mm, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hh < 0 || hh > 23 || mm < 0 || mm > 59 || len(m) != 2 {
+		return 0, fmt.Errorf("ожидается время HH:MM, получено %q", s)
+	}
+	return hh*60 + mm, nil
+}
+
+// formatClock — минуты от полуночи в виде "HH:MM".
+func formatClock(minute int) string { return fmt.Sprintf("%02d:%02d", minute/60, minute%60) }
+
+// String — окно в виде "02:00-06:00".
+func (w ScheduleWindow) String() string { return formatClock(w.From) + "-" + formatClock(w.To) }
+
+// contains сообщает, попадает ли минута суток minute в окно [From, To).
+func (w ScheduleWindow) contains(minute int) bool {
+	if w.From < w.To {
+		return minute >= w.From && minute < w.To
+	}
+	return minute >= w.From || minute < w.To
+}
+
+// validateSchedule проверяет окна расписания: время в пределах суток,
+// From != To, неотрицательные лимиты, окна не пересекаются.
+func validateSchedule(windows []ScheduleWindow, add func(format string, args ...any)) {
+	var owner [24 * 60]int // минута суток → номер окна + 1
+	for i, w := range windows {
+		if w.From < 0 || w.From >= 24*60 || w.To < 0 || w.To >= 24*60 {
+			add("schedule[%d]: время вне суток (%d, %d минут)", i, w.From, w.To)
+			continue
+		}
+		if w.From == w.To {
+			add("schedule[%d]: пустое окно %s", i, w)
+			continue
+		}
+		if w.Workers < 0 {
+			add("schedule[%d].workers: должно быть >= 0 (0 — WORKERS), получено %d", i, w.Workers)
+		}
+		if w.Bandwidth != nil && *w.Bandwidth < 0 {
+			add("schedule[%d].bandwidth: должно быть >= 0, получено %d", i, *w.Bandwidth)
+		}
+		for m := range owner {
+			if !w.contains(m) {
+				continue
+			}
+			if owner[m] != 0 {
+				add("schedule[%d]: окно %s пересекается с schedule[%d] (%s)", i, w, owner[m]-1, windows[owner[m]-1])
+				break
+			}
+			owner[m] = i + 1
+		}
+	}
+}
+
+// MaxWorkers — сколько воркеров нужно запустить, чтобы хватило на любое окно
+// расписания: наибольшее из Workers и workers окон.
+func (c *Config) MaxWorkers() int {
+	n := max(1, c.Workers)
+	for _, w := range c.Schedule {
+		n = max(n, w.Workers)
+	}
+	return n
+}
+
+// workPlan — лимиты, действующие в данный момент (planAt).
+type workPlan struct {
+	workers   int
+	bandwidth Bandwidth
+	window    string // окно расписания; пусто — вне окон (WORKERS, BANDWIDTH_LIMIT)
+}
+
+// planAt возвращает лимиты, действующие в момент now (по местному времени).
+func (c *Config) planAt(now time.Time) workPlan {
+	p := workPlan{workers: max(1, c.Workers), bandwidth: c.BandwidthLimit}
+	minute := now.Hour()*60 + now.Minute()
+	for _, w := range c.Schedule {
+		if !w.contains(minute) {
+			continue
+		}
+		p.window = w.String()
+		if w.Workers > 0 {
+			p.workers = w.Workers
+		}
+		if w.Bandwidth != nil {
+			p.bandwidth = *w.Bandwidth
+		}
+		break
+	}
+	return p
+}
+
+// applyPlan включает лимиты p: число воркеров, берущих задания
+// (остальные ждут, см. nextJob), и ограничение скорости загрузчика.
+// Смену лимитов при заданном расписании пишет в лог.
+func (a *App) applyPlan(p workPlan) {
+	a.wmu.Lock()
+	prev := a.plan
+	a.plan = p
+	if p.workers != prev.workers {
+		close(a.workersWake) // будим ждущих воркеров: они перепроверят свой номер
+		a.workersWake = make(chan struct{})
+	}
+	a.wmu.Unlock()
+	a.loader.SetBandwidth(int64(p.bandwidth))
+	if p != prev && len(a.Conf.Schedule) > 0 {
+		window := p.window
+		if window == "" {
+			window = "default"
+		}
+		log.Printf("Schedule: %s: %d worker(s), bandwidth %s", window, p.workers, p.bandwidth)
+	}
+}
+
+// startSchedule запускает scheduleLoop, если задано расписание или окна
+// обслуживания (maintenance.go).
+func (a *App) startSchedule() {
+	if len(a.Conf.Schedule) == 0 && len(a.Conf.Maintenance) == 0 {
+		return
+	}
+	a.scheduleStop = make(chan struct{})
+	a.scheduleDone = make(chan struct{})
+	go a.scheduleLoop()
+}
+
+// stopSchedule останавливает scheduleLoop и ждёт его выхода.
+func (a *App) stopSchedule() {
+	if a.scheduleStop == nil {
+		return
+	}
+	close(a.scheduleStop)
+	<-a.scheduleDone
+	a.scheduleStop = nil
+}
+
+// scheduleLoop в начале каждой минуты пересчитывает лимиты по расписанию
+// и окно обслуживания (границы окон — с точностью до минуты, так переводы
+// часов тоже учитываются).
+func (a *App) scheduleLoop() {
+	defer close(a.scheduleDone)
+	for {
+		now := time.Now()
+		t := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		select {
+		case <-a.scheduleStop:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		now = time.Now()
+		a.applyPlan(a.Conf.planAt(now))
+		a.applyMaintenance(now)
+	}
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TLSEnabled сообщает, будет ли сервер слушать HTTPS.
+func (c *Config) TLSEnabled() bool {
+	return (c.TLSCert != "" && c.TLSKey != "") || c.TLSSelfSigned
+}
+
+// tlsConfig собирает *tls.Config для HTTP-сервера по настройкам конфигурации.
+//
+// Правила:
+//   - TLS выключен (nil, nil), если не заданы TLSCert/TLSKey и не включён TLSSelfSigned;
+//   - явные TLSCert/TLSKey имеют приоритет над самоподписанным сертификатом;
+//   - при TLSSelfSigned сертификат генерируется один раз и кладётся в DataDir
+//     (tls-selfsigned.crt / tls-selfsigned.key), при следующих стартах переиспользуется;
+//   - если задан TLSClientCA, сервер запрашивает клиентский сертификат
+//     (VerifyClientCertIfGiven) — обязательность проверяется уже на уровне
+//     админских эндпоинтов, публичное API остаётся доступным без него.
+func (a *App) tlsConfig() (*tls.Config, error) {
+	if !a.Conf.TLSEnabled() {
+		return nil, nil
+	}
+	certFile, keyFile := a.Conf.TLSCert, a.Conf.TLSKey
+	if certFile == "" || keyFile == "" {
+		var err error
+		certFile, keyFile, err = ensureSelfSigned(a.Conf.DataDir)
+		if err != nil {
+			return nil, fmt.Errorf("self-signed cert: %w", err)
+		}
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load tls keypair: %w", err)
+	}
+	conf := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if a.Conf.TLSClientCA != "" {
+		pemData, err := os.ReadFile(a.Conf.TLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("read client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, errors.New("client ca: no certificates found")
+		}
+		conf.ClientCAs = pool
+		conf.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return conf, nil
+}
+
+// ensureSelfSigned возвращает пути к самоподписанному сертификату и ключу в dir,
+// генерируя их (ECDSA P-256, срок 1 год, SAN: localhost/127.0.0.1/::1),
+// если файлов ещё нет. Предназначено только для разработки.
+func ensureSelfSigned(dir string) (certFile, keyFile string, err error) {
+	certFile = filepath.Join(dir, "tls-selfsigned.crt")
+	keyFile = filepath.Join(dir, "tls-selfsigned.key")
+	if _, err := os.Stat(certFile); err == nil {
+		if _, err := os.Stat(keyFile); err == nil {
+			return certFile, keyFile, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "downloader (self-signed)"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
@@ -0,0 +1,216 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/queue"
+)
+
+// Корзина (TRASH_RETENTION, см. core/trash.go): при заданном сроке DELETE
+// /tasks/{id} не удаляет задачу, а переносит её в корзину (TrashTask) —
+// защита от случайного удаления вместе со скачанными файлами. Задача в
+// корзине скрыта из списков (store.TaskQuery.Trash), её задания воркеры
+// пропускают, а идущие загрузки прерываются. RestoreTask возвращает её в
+// работу; по истечении срока задачу удаляет trashLoop (DeleteTask).
+// Пометка хранится в задаче и переживает перезапуск.
+
+// trashMaxWait — предел ожидания trashLoop между проверками.
+const trashMaxWait = time.Hour
+
+// ErrNotTrashed — задача не в корзине (RestoreTask).
+var ErrNotTrashed = errors.New("задача не в корзине")
+
+// startTrash запускает trashLoop (если корзина включена).
+func (a *App) startTrash() {
+	if a.Conf.TrashRetention <= 0 {
+		return
+	}
+	a.trashWake = make(chan struct{}, 1)
+	a.trashStop = make(chan struct{})
+	a.trashDone = make(chan struct{})
+	go a.trashLoop()
+}
+
+// stopTrash останавливает trashLoop и ждёт его выхода.
+func (a *App) stopTrash() {
+	if a.trashStop == nil {
+		return
+	}
+	close(a.trashStop)
+	<-a.trashDone
+	a.trashStop = nil
+}
+
+// wakeTrash будит trashLoop: в корзине появилась задача.
+func (a *App) wakeTrash() {
+	if a.trashWake == nil {
+		return
+	}
+	select {
+	case a.trashWake <- struct{}{}:
+	default:
+	}
+}
+
+// trashLoop удаляет задачи, чей срок в корзине истёк, и спит до ближайшего
+// следующего (не дольше trashMaxWait) или до wakeTrash.
+func (a *App) trashLoop() {
+	defer close(a.trashDone)
+	for {
+		wait := trashMaxWait
+		if next := a.purgeDue(time.Now().UTC()); !next.IsZero() && time.Until(next) < wait {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-a.trashStop:
+			timer.Stop()
+			return
+		case <-a.trashWake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// purgeDue удаляет задачи, пробывшие в корзине TrashRetention к моменту now,
+// и возвращает ближайший ещё не наступивший момент удаления (нулевой — таких нет).
+func (a *App) purgeDue(now time.Time) time.Time {
+	var (
+		due  []string
+		next time.Time
+	)
+	a.mu.RLock()
+	for _, t := range a.tasks.all() {
+		t.Mutex().Lock()
+		var at time.Time
+		if t.TrashedAt != nil {
+			at = t.PurgeAt(a.Conf.TrashRetention)
+		}
+		t.Mutex().Unlock()
+		switch {
+		case at.IsZero():
+		case !at.After(now):
+			due = append(due, t.ID)
+		case next.IsZero() || at.Before(next):
+			next = at
+		}
+	}
+	a.mu.RUnlock()
+	for _, id := range due {
+		// задачу могли восстановить между обходом и удалением
+		if t, ok := a.tasks.get(id); !ok || !a.trashedSince(t, now) {
+			continue
+		}
+		res, err := a.DeleteTask(id)
+		if err != nil {
+			log.Printf("Trash: failed to purge task %s: %v", id, err)
+			continue
+		}
+		log.Printf("Trash: purged task %s after %s in trash: %d file(s), %d byte(s) freed",
+			id, a.Conf.TrashRetention, res.FilesRemoved, res.BytesFreed)
+	}
+	return next
+}
+
+// trashedSince сообщает, что задача t в корзине и срок её хранения к now истёк.
+func (a *App) trashedSince(t *core.Task, now time.Time) bool {
+	a.lockTask(t)
+	defer a.unlockTask(t)
+	return t.TrashedAt != nil && !t.PurgeAt(a.Conf.TrashRetention).After(now)
+}
+
+// TrashTask переносит задачу id в корзину: помечает её (core.Task.Trash),
+// прерывает идущие загрузки (их файлы возвращаются в Pending), фиксирует
+// задачу в WAL и пишет в историю EventTrashed. Файлы на диске не трогаются.
+// Задача уже в корзине — возвращается её прежний срок, ничего не меняется.
+//
+// ErrNotFound — задачи нет.
+func (a *App) TrashTask(id string) (DeleteResult, error) {
+	now := time.Now().UTC()
+	a.mu.Lock()
+	t, ok := a.tasks.get(id)
+	if !ok {
+		a.mu.Unlock()
+		return DeleteResult{}, ErrNotFound
+	}
+	if t.TrashedAt != nil {
+		at := t.PurgeAt(a.Conf.TrashRetention)
+		a.mu.Unlock()
+		return DeleteResult{TaskID: id, Trashed: true, PurgeAt: &at}, nil
+	}
+	var running []string
+	for _, f := range t.Files {
+		if f.State == core.FileRunning {
+			running = append(running, f.ID)
+		}
+	}
+	n := t.Trash(now)
+	evs := []core.TaskEvent{t.AddEvent(core.TaskEvent{
+		At:      now,
+		Type:    core.EventTrashed,
+		Message: fmt.Sprintf("%d running download(s) stopped", n),
+	})}
+	if ev, changed := t.RecomputeStatusEvent(now); changed {
+		evs = append(evs, ev)
+	}
+	snap := t.Clone()
+	a.mu.Unlock()
+
+	for _, fid := range running {
+		a.cancelInflight(id, fid)
+	}
+	_ = a.wal.AppendTask(snap)
+	a.recordEvents(snap, evs...)
+	a.wakeTrash()
+	at := snap.PurgeAt(a.Conf.TrashRetention)
+	log.Printf("Tasks: moved %s to trash, purge at %s%s", id, at.Format(time.RFC3339), requestTag(snap.RequestID))
+	return DeleteResult{TaskID: id, Trashed: true, PurgeAt: &at}, nil
+}
+
+// RestoreTask возвращает задачу id из корзины: снимает пометку, ставит её
+// Pending-файлы в очередь (если задача не ждёт зависимостей), фиксирует
+// задачу в WAL и пишет в историю EventRestored. Возвращает копию задачи.
+//
+// ErrNotFound — задачи нет; ErrNotTrashed — задача не в корзине.
+func (a *App) RestoreTask(id string) (*core.Task, error) {
+	now := time.Now().UTC()
+	a.mu.Lock()
+	t, ok := a.tasks.get(id)
+	if !ok {
+		a.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if t.TrashedAt == nil {
+		a.mu.Unlock()
+		return nil, ErrNotTrashed
+	}
+	t.TrashedAt = nil
+	var jobs []queue.Job
+	if !t.Waiting {
+		jobs = queueJobsLocked(t)
+	}
+	evs := []core.TaskEvent{t.AddEvent(core.TaskEvent{
+		At:      now,
+		Type:    core.EventRestored,
+		Message: fmt.Sprintf("%d file(s) requeued", len(jobs)),
+	})}
+	if ev, changed := t.RecomputeStatusEvent(now); changed {
+		evs = append(evs, ev)
+	}
+	snap := t.Clone()
+	a.mu.Unlock()
+
+	_ = a.wal.AppendTask(snap)
+	a.recordEvents(snap, evs...)
+	for _, j := range jobs {
+		a.dispatcher.InChan() <- j
+	}
+	a.wakePartialRetry()
+	log.Printf("Tasks: restored %s from trash, %d file(s) requeued%s", id, len(jobs), requestTag(snap.RequestID))
+	return snap, nil
+}
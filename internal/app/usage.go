@@ -0,0 +1,213 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/store"
+)
+
+// usageFlushEvery — как часто учёт потребления сбрасывается на диск.
+const usageFlushEvery = 30 * time.Second
+
+// usageDays — отчёт по умолчанию: последние 30 дней.
+const usageDays = 30
+
+// Группировки отчёта о потреблении (UsageQuery.Group).
+const (
+	UsageByDay    = "day"    // по дням, арендаторам и ключам API (как хранится)
+	UsageByTenant = "tenant" // итог за период по арендаторам и ключам API
+	UsageByTask   = "task"   // по задачам, созданным за период
+)
+
+// ErrBadUsageQuery — некорректные параметры отчёта о потреблении.
+var ErrBadUsageQuery = errors.New("некорректный запрос отчёта")
+
+// UsageQuery — параметры отчёта о потреблении (GET /admin/usage).
+// From, To — дни (UTC, "2006-01-02") включительно; пусто — последние 30 дней
+// по сегодняшний. Tenant и APIKey (отпечаток auth.KeyID) — фильтры.
+type UsageQuery struct {
+	From, To string
+	Tenant   string
+	APIKey   string
+	Group    string // UsageByDay (по умолчанию), UsageByTenant или UsageByTask
+}
+
+// UsageRow — строка отчёта. Day заполнен при группировке по дням, TaskID
+// (с Status и CreatedAt) — по задачам.
+type UsageRow struct {
+	Day       string          `json:"day,omitempty"`
+	TaskID    string          `json:"task_id,omitempty"`
+	Tenant    string          `json:"tenant"`
+	APIKey    string          `json:"api_key"`
+	Status    core.TaskStatus `json:"status,omitempty"`
+	CreatedAt *time.Time      `json:"created_at,omitempty"`
+	store.UsageCounters
+}
+
+// UsageReport — отчёт о потреблении: строки и их сумма.
+type UsageReport struct {
+	From  string              `json:"from"`
+	To    string              `json:"to"`
+	Group string              `json:"group"`
+	Rows  []UsageRow          `json:"rows"`
+	Total store.UsageCounters `json:"total"`
+}
+
+// usageDay — день учёта для момента t.
+func usageDay(t time.Time) string { return t.UTC().Format(time.DateOnly) }
+
+// recordUsage прибавляет d к учёту арендатора tenant и ключа API apiKey за день момента at.
+func (a *App) recordUsage(tenant, apiKey string, at time.Time, d store.UsageCounters) {
+	a.usage.Add(store.UsageKey{Day: usageDay(at), Tenant: tenant, APIKey: apiKey}, d)
+}
+
+// Usage собирает отчёт о потреблении:
+//   - по дням и по арендаторам — из накопительного учёта (DATA_DIR/usage.json):
+//     созданные задачи, попытки скачивания, скачанные файлы, неудачные попытки
+//     и байты успешных попыток; учёт переживает удаление задач;
+//   - по задачам — из задач, созданных за период и ещё не удалённых
+//     (попытки и неудачи — по счётчикам попыток файлов).
+//
+// ErrBadUsageQuery — неразбираемые даты, From позже To или неизвестная группировка.
+func (a *App) Usage(q UsageQuery) (UsageReport, error) {
+	if q.Group == "" {
+		q.Group = UsageByDay
+	}
+	if q.To == "" {
+		q.To = usageDay(time.Now())
+	}
+	to, err := time.Parse(time.DateOnly, q.To)
+	if err != nil {
+		return UsageReport{}, fmt.Errorf("%w: to: ожидается дата YYYY-MM-DD, получено %q", ErrBadUsageQuery, q.To)
+	}
+	if q.From == "" {
+		q.From = to.AddDate(0, 0, -(usageDays - 1)).Format(time.DateOnly)
+	}
+	from, err := time.Parse(time.DateOnly, q.From)
+	if err != nil {
+		return UsageReport{}, fmt.Errorf("%w: from: ожидается дата YYYY-MM-DD, получено %q", ErrBadUsageQuery, q.From)
+	}
+	if from.After(to) {
+		return UsageReport{}, fmt.Errorf("%w: from (%s) позже to (%s)", ErrBadUsageQuery, q.From, q.To)
+	}
+	rep := UsageReport{From: q.From, To: q.To, Group: q.Group, Rows: []UsageRow{}}
+	match := func(tenant, apiKey string) bool {
+		return (q.Tenant == "" || tenant == q.Tenant) && (q.APIKey == "" || apiKey == q.APIKey)
+	}
+
+	switch q.Group {
+	case UsageByDay, UsageByTenant:
+		byAccount := make(map[store.UsageKey]int) // арендатор и ключ → строка (UsageByTenant)
+		for _, r := range a.usage.Records(q.From, q.To) {
+			if !match(r.Tenant, r.APIKey) {
+				continue
+			}
+			rep.Total.Add(r.UsageCounters)
+			if q.Group == UsageByDay {
+				rep.Rows = append(rep.Rows, UsageRow{Day: r.Day, Tenant: r.Tenant, APIKey: r.APIKey, UsageCounters: r.UsageCounters})
+				continue
+			}
+			k := store.UsageKey{Tenant: r.Tenant, APIKey: r.APIKey}
+			i, ok := byAccount[k]
+			if !ok {
+				i = len(rep.Rows)
+				byAccount[k] = i
+				rep.Rows = append(rep.Rows, UsageRow{Tenant: r.Tenant, APIKey: r.APIKey})
+			}
+			rep.Rows[i].UsageCounters.Add(r.UsageCounters)
+		}
+		if q.Group == UsageByTenant {
+			sort.Slice(rep.Rows, func(i, j int) bool {
+				if rep.Rows[i].Tenant != rep.Rows[j].Tenant {
+					return rep.Rows[i].Tenant < rep.Rows[j].Tenant
+				}
+				return rep.Rows[i].APIKey < rep.Rows[j].APIKey
+			})
+		}
+	case UsageByTask:
+		end := to.AddDate(0, 0, 1)
+		a.mu.RLock()
+		for _, t := range a.tasks.all() {
+			if t.CreatedAt.Before(from) || !t.CreatedAt.Before(end) || !match(t.Tenant, t.APIKey) {
+				continue
+			}
+			t.Mutex().Lock()
+			created := t.CreatedAt
+			row := UsageRow{TaskID: t.ID, Tenant: t.Tenant, APIKey: t.APIKey, Status: t.Status, CreatedAt: &created}
+			row.Tasks = 1
+			for _, f := range t.Files {
+				row.Requests += int64(f.Attempts)
+				row.Failures += int64(f.Attempts)
+				if f.State == core.FileDone {
+					row.Files++
+					row.Failures--
+					row.Bytes += f.BytesDownloaded
+				}
+			}
+			t.Mutex().Unlock()
+			rep.Total.Add(row.UsageCounters)
+			rep.Rows = append(rep.Rows, row)
+		}
+		a.mu.RUnlock()
+		sort.Slice(rep.Rows, func(i, j int) bool {
+			if !rep.Rows[i].CreatedAt.Equal(*rep.Rows[j].CreatedAt) {
+				return rep.Rows[i].CreatedAt.Before(*rep.Rows[j].CreatedAt)
+			}
+			return rep.Rows[i].TaskID < rep.Rows[j].TaskID
+		})
+	default:
+		return UsageReport{}, fmt.Errorf("%w: group: ожидается %s, %s или %s, получено %q",
+			ErrBadUsageQuery, UsageByDay, UsageByTenant, UsageByTask, q.Group)
+	}
+	return rep, nil
+}
+
+// startUsage читает сохранённый учёт и запускает его периодический сброс
+// на диск (usageLoop). Вызывается из runRecovery: резервный экземпляр
+// (LEADER_LEASE) не читает и не перезаписывает учёт лидера.
+func (a *App) startUsage() {
+	if err := a.usage.Load(); err != nil {
+		log.Printf("Usage: failed to load usage.json, counting from zero: %v", err)
+	}
+	a.usageStop = make(chan struct{})
+	a.usageDone = make(chan struct{})
+	go a.usageLoop()
+}
+
+// stopUsage останавливает usageLoop и сбрасывает учёт в последний раз.
+func (a *App) stopUsage() {
+	if a.usageStop == nil {
+		return
+	}
+	close(a.usageStop)
+	<-a.usageDone
+	a.usageStop = nil
+	a.flushUsage()
+}
+
+// usageLoop сбрасывает учёт на диск каждые usageFlushEvery.
+func (a *App) usageLoop() {
+	defer close(a.usageDone)
+	tick := time.NewTicker(usageFlushEvery)
+	defer tick.Stop()
+	for {
+		select {
+		case <-a.usageStop:
+			return
+		case <-tick.C:
+			a.flushUsage()
+		}
+	}
+}
+
+// flushUsage сбрасывает учёт на диск; ошибку только пишет в лог.
+func (a *App) flushUsage() {
+	if err := a.usage.Flush(); err != nil {
+		log.Printf("Usage: failed to save usage.json: %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// Итоги проверки файла (VerifyFile.Result).
+const (
+	VerifyOK               = "ok"
+	VerifyMissing          = "missing"           // файла нет на диске (или в S3)
+	VerifySizeMismatch     = "size_mismatch"     // размер не совпал с BytesDownloaded
+	VerifyChecksumMismatch = "checksum_mismatch" // содержимое не совпало с ожидаемой или сохранённой суммой
+	VerifyUnreadable       = "unreadable"        // файл не удалось прочитать (права, ошибка ввода-вывода)
+	VerifySkipped          = "skipped"           // локальная копия удалена после выгрузки в sink
+)
+
+// VerifyFile — итог проверки одного DONE-файла задачи.
+type VerifyFile struct {
+	FileID   string `json:"file_id"`
+	Path     string `json:"path"`
+	Result   string `json:"result"`
+	Detail   string `json:"detail,omitempty"`   // что именно не совпало
+	Requeued bool   `json:"requeued,omitempty"` // файл возвращён в очередь на повторное скачивание
+}
+
+// VerifyResult — итог проверки задачи (VerifyTask).
+type VerifyResult struct {
+	TaskID   string       `json:"task_id"`
+	Checked  int          `json:"checked"`  // проверено DONE-файлов
+	OK       int          `json:"ok"`       // из них целых
+	Bad      int          `json:"bad"`      // пропавших и повреждённых
+	Requeued int          `json:"requeued"` // возвращено в очередь
+	Files    []VerifyFile `json:"files"`
+}
+
+// verifyItem — снимок DONE-файла для проверки вне a.mu.
+type verifyItem struct {
+	id, path, blob, checksum string
+	size                     int64
+	manifest                 string // sha256 из manifest.json каталога задачи
+	uploaded                 bool   // локальная копия удалена после выгрузки
+}
+
+// VerifyTask перепроверяет скачанные (DONE) файлы задачи id:
+//   - файл есть и его размер совпадает с BytesDownloaded;
+//   - содержимое совпадает с ожидаемой суммой ссылки (checksum), с sha256
+//     хранилища BLOB_DIR (Blob) и с sha256 из manifest.json задачи (TASK_MANIFEST),
+//     какие из них есть; у файлов в S3 проверяются только наличие и размер;
+//   - файлы, выгруженные в sink с удалением локальной копии, пропускаются.
+//
+// Пропавшие и повреждённые файлы (кроме нечитаемых — их причину надо
+// устранить руками) при requeue=true возвращаются в Pending с полным бюджетом
+// попыток: испорченная копия удаляется (испорченный blob вынимается из
+// хранилища, store.BlobStore.Evict), изменение пишется в WAL событием
+// "verified", файлы встают в очередь. requeue=false — только отчёт.
+//
+// Файлы читаются вне a.mu; файл, изменившийся за время проверки (задачу
+// удалили, файл перекачали), не трогается. ErrNotFound — задачи нет.
+func (a *App) VerifyTask(ctx context.Context, id string, requeue bool) (VerifyResult, error) {
+	a.mu.RLock()
+	t, ok := a.tasks.get(id)
+	if !ok {
+		a.mu.RUnlock()
+		return VerifyResult{}, ErrNotFound
+	}
+	t.Mutex().Lock()
+	dir := a.taskDestDir(t)
+	var items []verifyItem
+	for _, f := range t.Files {
+		if f.State == core.FileDone && f.Path != "" {
+			items = append(items, verifyItem{
+				id: f.ID, path: f.Path, blob: f.Blob, checksum: f.Checksum, size: f.BytesDownloaded,
+				uploaded: f.Upload != nil && f.Upload.LocalDeleted,
+			})
+		}
+	}
+	a.unlockTask(t)
+
+	sums := manifestSums(dir)
+	res := VerifyResult{TaskID: id, Files: make([]VerifyFile, 0, len(items))}
+	var bad []int // индексы res.Files к возврату в очередь
+	for i := range items {
+		if err := ctx.Err(); err != nil {
+			return VerifyResult{}, err
+		}
+		it := &items[i]
+		it.manifest = sums[it.id]
+		vf := VerifyFile{FileID: it.id, Path: it.path}
+		vf.Result, vf.Detail = a.verifyFile(ctx, it)
+		res.Checked++
+		switch vf.Result {
+		case VerifyOK:
+			res.OK++
+		case VerifyMissing, VerifySizeMismatch, VerifyChecksumMismatch:
+			res.Bad++
+			bad = append(bad, len(res.Files))
+		case VerifyUnreadable:
+			res.Bad++
+		}
+		res.Files = append(res.Files, vf)
+	}
+	if !requeue || len(bad) == 0 {
+		return res, nil
+	}
+
+	now := time.Now().UTC()
+	ch := &taskChange{}
+	var remove []verifyItem
+	a.mu.Lock()
+	if !a.tasks.current(t) {
+		a.mu.Unlock()
+		return res, nil // задачу удалили во время проверки
+	}
+	for _, i := range bad {
+		vf := &res.Files[i]
+		it := items[i]
+		f, _ := t.FileByID(vf.FileID)
+		if f == nil || f.State != core.FileDone || f.Path != it.path {
+			continue
+		}
+		if err := f.Transition(core.FilePending, "verify: "+vf.Result, now); err != nil {
+			continue
+		}
+		f.Attempts = 0
+		f.Path, f.Blob, f.Upload = "", "", nil
+		f.BytesDownloaded, f.ProgressPercent = 0, 0
+		ch.jobs = append(ch.jobs, retryJobFor(t, f))
+		remove = append(remove, it)
+		vf.Requeued = true
+	}
+	ch.files = len(ch.jobs)
+	if ch.files > 0 {
+		ch.events = append(ch.events, t.AddEvent(core.TaskEvent{
+			At:      now,
+			Type:    core.EventVerified,
+			Message: fmt.Sprintf("%d file(s) checked, %d missing or corrupted file(s) requeued", res.Checked, ch.files),
+		}))
+		if ev, changed := t.RecomputeStatusEvent(now); changed {
+			ch.events = append(ch.events, ev)
+		}
+		ch.snap = t.Clone()
+	}
+	a.mu.Unlock()
+
+	// испорченные копии убираются до постановки в очередь: иначе новая копия
+	// легла бы рядом с суффиксом "-1" (downloader.UniquePathIn)
+	for _, it := range remove {
+		if it.blob != "" && a.blobs != nil {
+			if err := a.blobs.Evict(it.blob); err != nil {
+				log.Printf("Blobs: evict %s: %v", it.blob, err)
+			}
+		}
+		a.removeTaskFile(it.path, it.blob)
+	}
+	a.commit(ch)
+	res.Requeued = ch.files
+	log.Printf("Verify: task %s: %d file(s) checked, %d ok, %d bad, %d requeued%s",
+		id, res.Checked, res.OK, res.Bad, res.Requeued, requestTag(t.RequestID))
+	return res, nil
+}
+
+// verifyFile проверяет один файл и возвращает итог (Verify*) и подробности.
+func (a *App) verifyFile(ctx context.Context, it *verifyItem) (result, detail string) {
+	if it.uploaded {
+		return VerifySkipped, "local copy removed after upload"
+	}
+	if storage.IsObject(it.path) {
+		info, err := a.storage.Stat(ctx, it.path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return VerifyMissing, ""
+		case err != nil:
+			return VerifyUnreadable, err.Error()
+		case info.Size != it.size:
+			return VerifySizeMismatch, fmt.Sprintf("size %d, expected %d", info.Size, it.size)
+		}
+		return VerifyOK, ""
+	}
+
+	f, err := os.Open(it.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return VerifyMissing, ""
+	case err != nil:
+		return VerifyUnreadable, err.Error()
+	}
+	defer f.Close()
+
+	var (
+		algo string
+		want []byte
+	)
+	if it.checksum != "" {
+		algo, want, _ = core.ParseChecksum(it.checksum) // проверена при создании задачи
+	}
+	sha := sha256.New()
+	var (
+		w        io.Writer = sha
+		expected hash.Hash = sha
+	)
+	if algo != "" && algo != "sha256" {
+		expected = core.NewHash(algo)
+		w = io.MultiWriter(sha, expected)
+	}
+	n, err := io.Copy(w, &ctxReader{ctx: ctx, r: f})
+	if err != nil {
+		return VerifyUnreadable, err.Error()
+	}
+	if n != it.size {
+		return VerifySizeMismatch, fmt.Sprintf("size %d, expected %d", n, it.size)
+	}
+	if algo != "" && !bytes.Equal(expected.Sum(nil), want) {
+		return VerifyChecksumMismatch, fmt.Sprintf("%s %x, expected %x", algo, expected.Sum(nil), want)
+	}
+	got := hex.EncodeToString(sha.Sum(nil))
+	for _, stored := range []struct{ name, sum string }{{"blob", it.blob}, {"manifest", it.manifest}} {
+		if stored.sum != "" && stored.sum != got {
+			return VerifyChecksumMismatch, fmt.Sprintf("sha256 %s, %s has %s", got, stored.name, stored.sum)
+		}
+	}
+	return VerifyOK, ""
+}
+
+// manifestSums читает sha256 файлов из manifest.json каталога задачи dir
+// (ID файла → sha256). Нет описи или она не читается — пустая карта.
+func manifestSums(dir string) map[string]string {
+	sums := make(map[string]string)
+	if storage.IsObject(dir) {
+		return sums
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return sums
+	}
+	var m TaskManifest
+	if json.Unmarshal(data, &m) != nil {
+		return sums
+	}
+	for _, f := range m.Files {
+		if f.SHA256 != "" {
+			sums[f.ID] = f.SHA256
+		}
+	}
+	return sums
+}
+
+// ctxReader прерывает чтение по отмене ctx (проверка больших файлов).
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// Расширения файлов-манифестов в WatchDir и суффиксы обработанных файлов.
+const (
+	watchExtURLs  = ".urls"
+	watchExtJSON  = ".json"
+	watchDoneExt  = ".done"
+	watchErrorExt = ".error"
+)
+
+// watchSeen — размер и время изменения файла на прошлом проходе.
+type watchSeen struct {
+	size    int64
+	modTime time.Time
+}
+
+// startWatcher запускает опрос Conf.WatchDir (если задан) в фоне.
+// Остановка — через stopWatcher (вызывается из Close).
+func (a *App) startWatcher() {
+	if a.Conf.WatchDir == "" {
+		return
+	}
+	a.watchStop = make(chan struct{})
+	a.watchDone = make(chan struct{})
+	go a.watchLoop()
+	log.Printf("Watch: polling %s every %s", a.Conf.WatchDir, a.Conf.WatchInterval)
+}
+
+// stopWatcher останавливает опрос и ждёт завершения текущего прохода.
+func (a *App) stopWatcher() {
+	if a.watchStop == nil {
+		return
+	}
+	close(a.watchStop)
+	<-a.watchDone
+	a.watchStop = nil
+}
+
+// watchLoop — опрос каталога манифестов.
+//
+// Используется поллинг (без inotify): переносимо и не требует зависимостей.
+// Файл обрабатывается, только когда его размер и mtime не менялись между
+// двумя проходами — так не подхватываются недописанные манифесты. Надёжнее всего
+// класть файл атомарно: записать под другим именем (например, *.tmp) и переименовать.
+func (a *App) watchLoop() {
+	defer close(a.watchDone)
+	seen := make(map[string]watchSeen)
+	ticker := time.NewTicker(a.Conf.WatchInterval)
+	defer ticker.Stop()
+	for {
+		a.scanWatchDir(seen)
+		select {
+		case <-a.watchStop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scanWatchDir — один проход по каталогу: стабильные *.urls/*.json превращаются в задачи.
+func (a *App) scanWatchDir(seen map[string]watchSeen) {
+	entries, err := os.ReadDir(a.Conf.WatchDir)
+	if err != nil {
+		log.Printf("Watch: read %s: %v", a.Conf.WatchDir, err)
+		return
+	}
+	present := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		if e.IsDir() || strings.HasPrefix(name, ".") || (ext != watchExtURLs && ext != watchExtJSON) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		present[name] = true
+		cur := watchSeen{size: info.Size(), modTime: info.ModTime()}
+		if prev, ok := seen[name]; !ok || prev != cur {
+			seen[name] = cur
+			continue
+		}
+		delete(seen, name)
+		a.ingestManifest(filepath.Join(a.Conf.WatchDir, name))
+	}
+	for name := range seen {
+		if !present[name] {
+			delete(seen, name)
+		}
+	}
+}
+
+// ingestManifest создаёт задачу из манифеста path и переименовывает его
+// в path.done (успех) или path.error (ошибка разбора/валидации, причина — в логе).
+// Упёршийся во временный лимит (LimitError.Temporary) манифест остаётся на месте
+// и подбирается следующими проходами, когда лимит освободится.
+func (a *App) ingestManifest(path string) {
+	task, err := a.taskFromManifest(path)
+	var le *LimitError
+	if errors.As(err, &le) && le.Temporary() {
+		log.Printf("Watch: %s: postponed: %v", path, err)
+		return
+	}
+	suffix := watchDoneExt
+	if err != nil {
+		suffix = watchErrorExt
+		log.Printf("Watch: %s: %v", path, err)
+	} else {
+		log.Printf("Watch: %s → task %s (%d files)", path, task.ID, len(task.Files))
+	}
+	if err := os.Rename(path, path+suffix); err != nil {
+		log.Printf("Watch: rename %s: %v", path, err)
+	}
+}
+
+// taskFromManifest разбирает манифест и создаёт задачу через CreateTask.
+//
+// Форматы:
+//   - *.urls — по ссылке на строку, пустые строки и "#…" пропускаются;
+//     метка задачи — имя файла без расширения;
+//   - *.json — core.TaskSpec, как тело POST /tasks: {"links": [...], "label": "...",
+//     "dest_dir": "...", "layout": "..."}; ссылки — строки или объекты core.LinkSpec.
+func (a *App) taskFromManifest(path string) (*core.Task, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m core.TaskSpec
+	if strings.EqualFold(filepath.Ext(path), watchExtJSON) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("некорректный JSON: %w", err)
+		}
+	} else {
+		m.Label = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				m.Links = append(m.Links, core.LinkSpec{URL: line})
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return a.CreateTask(m)
+}
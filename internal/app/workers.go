@@ -0,0 +1,121 @@
+package app
+
+import (
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/queue"
+)
+
+// WorkerInfo — состояние одного воркера: чем он занят прямо сейчас.
+// Для простаивающего воркера Busy=false, TaskID пуст, а Since == nil.
+// Parked — воркер не берёт задания: по расписанию сейчас работает меньше воркеров.
+type WorkerInfo struct {
+	Index   int        `json:"index"`
+	Busy    bool       `json:"busy"`
+	Parked  bool       `json:"parked,omitempty"`
+	TaskID  string     `json:"task_id,omitempty"`
+	FileID  string     `json:"file_id,omitempty"`
+	URL     string     `json:"url,omitempty"`
+	Bytes   int64      `json:"bytes"`
+	Since   *time.Time `json:"since,omitempty"`
+	Elapsed string     `json:"elapsed,omitempty"`
+}
+
+// WorkersReport — ответ /admin/workers: состояния воркеров и итоги.
+// Active и Bandwidth — лимиты, действующие сейчас (с расписанием — лимиты
+// окна Window); Parked — воркеры, ждущие окна с большим числом воркеров.
+type WorkersReport struct {
+	Total     int          `json:"total"`
+	Active    int          `json:"active"`
+	Busy      int          `json:"busy"`
+	Idle      int          `json:"idle"`
+	Parked    int          `json:"parked"`
+	Bandwidth int64        `json:"bandwidth_limit"` // байт/с; 0 — без ограничения
+	Window    string       `json:"schedule_window,omitempty"`
+	Workers   []WorkerInfo `json:"workers"`
+}
+
+// setWorkerJob отмечает, что воркер idx взял в работу файл fileID задачи taskID.
+func (a *App) setWorkerJob(idx int, taskID, fileID, url string, since time.Time) {
+	a.wmu.Lock()
+	defer a.wmu.Unlock()
+	a.workers[idx] = WorkerInfo{Index: idx, Busy: true, TaskID: taskID, FileID: fileID, URL: url, Since: &since}
+}
+
+// setWorkerBytes обновляет число байт, записанных воркером idx в текущей попытке.
+// Вызывается из колбэка прогресса загрузчика.
+func (a *App) setWorkerBytes(idx int, n int64) {
+	a.wmu.Lock()
+	a.workers[idx].Bytes = n
+	a.wmu.Unlock()
+}
+
+// clearWorkerJob переводит воркер idx в состояние простоя.
+func (a *App) clearWorkerJob(idx int) {
+	a.wmu.Lock()
+	defer a.wmu.Unlock()
+	a.workers[idx] = WorkerInfo{Index: idx}
+}
+
+// Workers возвращает копию состояний всех воркеров;
+// Elapsed вычисляется на момент вызова.
+func (a *App) Workers() []WorkerInfo {
+	a.wmu.Lock()
+	defer a.wmu.Unlock()
+	now := time.Now().UTC()
+	out := make([]WorkerInfo, len(a.workers))
+	copy(out, a.workers)
+	for i := range out {
+		if out[i].Since != nil {
+			out[i].Elapsed = now.Sub(*out[i].Since).Round(time.Millisecond).String()
+		}
+		out[i].Parked = !out[i].Busy && i >= a.plan.workers
+	}
+	return out
+}
+
+// WorkersReport возвращает состояния воркеров вместе с числом занятых/свободных.
+// Помогает разобраться в ситуации «всё в очереди, но ничего не качается».
+func (a *App) WorkersReport() WorkersReport {
+	ws := a.Workers()
+	a.wmu.Lock()
+	plan := a.plan
+	a.wmu.Unlock()
+	rep := WorkersReport{Total: len(ws), Active: plan.workers, Bandwidth: int64(plan.bandwidth), Window: plan.window, Workers: ws}
+	for _, w := range ws {
+		switch {
+		case w.Busy:
+			rep.Busy++
+		case w.Parked:
+			rep.Parked++
+		default:
+			rep.Idle++
+		}
+	}
+	return rep
+}
+
+// nextJob ждёт задание для воркера idx. Воркер с номером не меньше числа
+// воркеров по расписанию (timetable.go) заданий не берёт и ждёт смены окна;
+// смена будит и воркеров, ждущих OutChan, — лишние уходят ждать.
+// ok=false — очередь закрыта или приложение завершается (Close).
+func (a *App) nextJob(idx int) (job queue.Job, ok bool) {
+	for {
+		a.wmu.Lock()
+		active, wake := idx < a.plan.workers, a.workersWake
+		a.wmu.Unlock()
+		if !active {
+			select {
+			case <-wake:
+			case <-a.workersStop:
+				return queue.Job{}, false
+			}
+			continue
+		}
+		select {
+		case job, ok = <-a.dispatcher.OutChan():
+			return job, ok
+		case <-wake:
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// jwk — открытый ключ в формате JWK (RFC 7517); используются только
+// поля ключей подписи RSA, EC и OKP (Ed25519).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keysFor возвращает ключи-кандидаты для kid (без kid — все ключи),
+// при необходимости (пр)загружая JWKS.
+func (v *Verifier) keysFor(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	stale := v.keys == nil || now.Sub(v.fetchedAt) > jwksTTL
+	_, known := v.keys[kid]
+	if (stale || (kid != "" && !known)) && now.Sub(v.lastAttempt) >= jwksMinRefresh {
+		v.lastAttempt = now
+		if err := v.refreshLocked(ctx); err != nil && v.keys == nil {
+			return nil, fmt.Errorf("jwks: %w", err)
+		}
+	}
+	if kid != "" {
+		if k, ok := v.keys[kid]; ok {
+			return []crypto.PublicKey{k}, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	keys := make([]crypto.PublicKey, 0, len(v.keys))
+	for _, k := range v.keys {
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks: no usable keys")
+	}
+	return keys, nil
+}
+
+// refreshLocked загружает JWKS (при пустом jwksURL — сначала OIDC discovery).
+// Ключи, которые не удалось разобрать, пропускаются. Вызывать под v.mu.
+func (v *Verifier) refreshLocked(ctx context.Context) error {
+	if v.jwksURL == "" {
+		var doc struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		discovery := strings.TrimRight(v.conf.Issuer, "/") + "/.well-known/openid-configuration"
+		if err := v.getJSON(ctx, discovery, &doc); err != nil {
+			return fmt.Errorf("discovery: %w", err)
+		}
+		if doc.JWKSURI == "" {
+			return errors.New("discovery: jwks_uri is empty")
+		}
+		v.jwksURL = doc.JWKSURI
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := v.getJSON(ctx, v.jwksURL, &set); err != nil {
+		return err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	return nil
+}
+
+func (v *Verifier) getJSON(ctx context.Context, url string, dst any) error {
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dst)
+}
+
+// publicKey превращает JWK в открытый ключ crypto.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err1 := b64Int(k.N)
+		e, err2 := b64Int(k.E)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 || n.BitLen() < 2048 {
+			return nil, errors.New("unsupported RSA key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err1 := b64Int(k.X)
+		y, err2 := b64Int(k.Y)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("unsupported OKP key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func b64Int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("bad base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256" // crypto.SHA256 для Hash.New
+	_ "crypto/sha512" // crypto.SHA384/SHA512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jwtLeeway        = 60 * time.Second // допуск расхождения часов для exp/nbf
+	jwksTTL          = time.Hour        // как долго ключи JWKS считаются свежими
+	jwksMinRefresh   = 30 * time.Second // не чаще — повторная загрузка при незнакомом kid
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// JWTConfig — параметры проверки JWT (JWT_* в конфигурации сервиса).
+type JWTConfig struct {
+	Issuer      string // обязательный: claim "iss" должен совпадать
+	JWKSURL     string // пусто — берётся jwks_uri из OIDC discovery издателя
+	Audience    string // если задан — должен быть в claim "aud"
+	RoleClaim   string // путь к claim роли через точку ("role", "realm_access.roles")
+	TenantClaim string // путь к claim арендатора; пусто — не извлекается
+}
+
+// Enabled сообщает, что проверка JWT настроена.
+func (c JWTConfig) Enabled() bool { return c.Issuer != "" }
+
+// Verifier проверяет JWT, подписанные ключами издателя (JWKS), и превращает
+// их claims в Principal. Ключи кэшируются на jwksTTL; токен с незнакомым kid
+// вызывает внеочередную загрузку JWKS (не чаще jwksMinRefresh) — так
+// подхватывается ротация ключей без перезапуска сервиса.
+//
+// Поддерживаемые алгоритмы: RS256/384/512, PS256/384/512, ES256/384/512, EdDSA.
+// "none" и HMAC (HS*) отвергаются: общий секрет с провайдером не используется.
+type Verifier struct {
+	conf   JWTConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	jwksURL     string // conf.JWKSURL или найденный через discovery
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// NewVerifier создаёт Verifier; сеть при создании не используется —
+// ключи загружаются при первой проверке.
+func NewVerifier(conf JWTConfig) *Verifier {
+	return &Verifier{
+		conf:    conf,
+		client:  &http.Client{Timeout: jwksFetchTimeout},
+		jwksURL: conf.JWKSURL,
+	}
+}
+
+// LooksLikeJWT — быстрая проверка формы "xxx.yyy.zzz", чтобы отличить
+// JWT от статического ключа API без попытки разбора.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify проверяет подпись и claims токена:
+//   - подпись — ключом из JWKS с kid из заголовка (без kid — любым подходящим);
+//   - iss совпадает с Issuer, aud содержит Audience (если задан);
+//   - exp обязателен и не истёк, nbf (если есть) наступил — с допуском jwtLeeway;
+//   - claim роли содержит известную роль (ParseRole); из нескольких берётся старшая.
+func (v *Verifier) Verify(ctx context.Context, token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, errors.New("malformed token")
+	}
+	var hdr jwtHeader
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return Principal{}, fmt.Errorf("bad header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, fmt.Errorf("bad signature encoding: %w", err)
+	}
+	keys, err := v.keysFor(ctx, hdr.Kid)
+	if err != nil {
+		return Principal{}, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if err = verifySignature(hdr.Alg, key, signed, sig); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return Principal{}, fmt.Errorf("signature: %w", err)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, fmt.Errorf("bad claims: %w", err)
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return Principal{}, err
+	}
+	p := Principal{Method: MethodJWT}
+	p.Subject, _ = claims["sub"].(string)
+	if v.conf.TenantClaim != "" {
+		p.Tenant, _ = claimPath(claims, v.conf.TenantClaim).(string)
+	}
+	role, ok := roleFromClaim(claimPath(claims, v.conf.RoleClaim))
+	if !ok {
+		return Principal{}, fmt.Errorf("no known role in claim %q", v.conf.RoleClaim)
+	}
+	p.Role = role
+	return p, nil
+}
+
+// checkClaims проверяет iss, aud, exp и nbf.
+func (v *Verifier) checkClaims(claims map[string]any, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != v.conf.Issuer {
+		return fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if v.conf.Audience != "" && !hasAudience(claims["aud"], v.conf.Audience) {
+		return errors.New("token is not issued for this audience")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("missing exp claim")
+	}
+	if now.Add(-jwtLeeway).After(time.Unix(int64(exp), 0)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token is not valid yet")
+	}
+	return nil
+}
+
+func hasAudience(aud any, want string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == want
+	case []any:
+		for _, x := range a {
+			if s, _ := x.(string); s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimPath достаёт вложенный claim по пути через точку ("realm_access.roles").
+func claimPath(claims map[string]any, path string) any {
+	var cur any = claims
+	for _, name := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[name]
+	}
+	return cur
+}
+
+// roleFromClaim выбирает старшую известную роль из строки или массива строк.
+func roleFromClaim(v any) (Role, bool) {
+	var values []string
+	switch x := v.(type) {
+	case string:
+		values = strings.Fields(strings.ReplaceAll(x, ",", " "))
+	case []any:
+		for _, e := range x {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	var best Role
+	for _, s := range values {
+		if r, ok := ParseRole(s); ok && (best == "" || !best.AtLeast(r)) {
+			best = r
+		}
+	}
+	return best, best != ""
+}
+
+func decodeSegment(seg string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// verifySignature проверяет подпись sig над signed ключом key по алгоритму alg.
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	hashFor := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+	if alg == "EdDSA" {
+		k, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(k, signed, sig) {
+			return errors.New("invalid EdDSA signature")
+		}
+		return nil
+	}
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	h, ok := hashFor[alg[2:]]
+	if !ok {
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	hasher := h.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch alg[:2] {
+	case "RS":
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match alg")
+		}
+		return rsa.VerifyPKCS1v15(k, h, digest, sig)
+	case "PS":
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match alg")
+		}
+		return rsa.VerifyPSS(k, h, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match alg")
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid ECDSA signature length")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported alg %q", alg)
+}
@@ -0,0 +1,97 @@
+// Package auth — аутентификация клиентов API задач: кто обращается
+// (Principal) и с какой ролью. Статические ключи API_KEYS и JWT от
+// внешнего провайдера (OIDC) приводятся к одному и тому же Principal.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Role — роль клиента API. Роли упорядочены: каждая следующая включает
+// права предыдущей (см. Role.AtLeast).
+type Role string
+
+const (
+	RoleViewer    Role = "viewer"    // список задач и их просмотр
+	RoleSubmitter Role = "submitter" // + создание задач (POST /tasks, импорт, клон) и PATCH
+	RoleOperator  Role = "operator"  // + retry и отмена
+	RoleAdmin     Role = "admin"     // + /admin/* и /debug/* (drain, компактизация, диагностика)
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleSubmitter: 2, RoleOperator: 3, RoleAdmin: 4}
+
+// roleAliases — прежние имена ролей.
+var roleAliases = map[string]Role{"reader": RoleViewer}
+
+// ParseRole разбирает имя роли (без учёта регистра). ok=false — роль неизвестна.
+func ParseRole(s string) (Role, bool) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if r, ok := roleAliases[name]; ok {
+		return r, true
+	}
+	r := Role(name)
+	_, ok := roleRank[r]
+	return r, ok
+}
+
+// DefaultKeyRole — роль ключа API_KEYS без явного ":role": всё API задач,
+// как до появления ролей, но не админка.
+const DefaultKeyRole = RoleOperator
+
+// ParseAPIKey разбирает элемент API_KEYS вида "key" или "key:role".
+func ParseAPIKey(entry string) (key string, role Role, err error) {
+	key, name, found := strings.Cut(entry, ":")
+	if !found {
+		return entry, DefaultKeyRole, nil
+	}
+	role, ok := ParseRole(name)
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("ожидается \"ключ\" или \"ключ:роль\" (viewer, submitter, operator, admin)")
+	}
+	return key, role, nil
+}
+
+// KeyID — отпечаток ключа API для учёта и отчётов: "k_" и первые 12 hex-символов
+// его sha256. Сам ключ нигде не сохраняется; сопоставить отпечаток с ключом —
+// printf %s KEY | sha256sum | cut -c1-12.
+func KeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "k_" + hex.EncodeToString(sum[:6])
+}
+
+// AtLeast сообщает, что роль r не ниже min.
+func (r Role) AtLeast(min Role) bool { return roleRank[r] >= roleRank[min] }
+
+// Способы аутентификации (Principal.Method).
+const (
+	MethodNone      = "none"       // API открыт: ни ключей, ни JWT не настроено
+	MethodAPIKey    = "api_key"    // статический ключ из API_KEYS
+	MethodJWT       = "jwt"        // JWT, подписанный ключом из JWKS издателя
+	MethodSignedURL = "signed_url" // подписанная ссылка на содержимое файла (без учётных данных)
+)
+
+// Principal — аутентифицированный клиент.
+type Principal struct {
+	Subject string `json:"subject,omitempty"` // claim "sub" (для ключа API — пусто)
+	Tenant  string `json:"tenant,omitempty"`  // из claim JWT_TENANT_CLAIM
+	KeyID   string `json:"key_id,omitempty"`  // отпечаток ключа API (KeyID); для JWT — пусто
+	Role    Role   `json:"role"`
+	Method  string `json:"method"`
+}
+
+type principalKey struct{}
+
+// WithPrincipal кладёт p в контекст запроса.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext достаёт Principal, положенный WithPrincipal.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,295 @@
+// Package bundle — переносимый архив задачи: метаданные (задача, история событий)
+// и скачанные файлы в одном tar. Архив позволяет перенести задачу в другое
+// окружение (downloader export / import, GET /admin/tasks/{id}/export,
+// POST /admin/tasks/import) или сохранить завершённую партию как есть.
+//
+// Состав архива (в этом порядке):
+//
+//	bundle.json               — Header: версия формата, ID задачи, список файлов с sha256;
+//	task.json                 — core.Task (как в WAL);
+//	history.json              — core.TaskHistory;
+//	files/<file-id>/<имя>     — содержимое DONE-файлов задачи.
+package bundle
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// FormatVersion — версия формата архива (Header.Format).
+const FormatVersion = 1
+
+// Header — оглавление архива (bundle.json).
+type Header struct {
+	Format     int       `json:"format"`
+	ExportedAt time.Time `json:"exported_at"`
+	TaskID     string    `json:"task_id"`
+	// DestRel — каталог задачи относительно DOWNLOAD_DIR исходного окружения
+	// (пусто — каталог по умолчанию <DOWNLOAD_DIR>/<task_id>).
+	DestRel string  `json:"dest_rel,omitempty"`
+	Files   []Entry `json:"files"`
+}
+
+// Entry — файл задачи в архиве.
+type Entry struct {
+	FileID string `json:"file_id"`
+	Name   string `json:"name"` // путь внутри архива: files/<file-id>/<имя>
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Bundle — прочитанный архив: задача с путями файлов в новом окружении.
+type Bundle struct {
+	Header  Header
+	Task    *core.Task
+	History core.TaskHistory
+	Bytes   int64 // распаковано байт файлов
+}
+
+// ErrFormat — архив не читается: не tar, нарушен порядок записей, не та версия,
+// не совпал размер или sha256 файла.
+var ErrFormat = errors.New("bad bundle")
+
+// Write пишет архив задачи t с историей h в w. Файлы берутся с диска по FileItem.Path
+// (только DONE-файлы); downloadDir — DOWNLOAD_DIR, относительно которого
+// запоминается каталог задачи.
+//
+// Сначала проверяет и хеширует все файлы и лишь затем пишет первый байт: ошибка
+// (файл пропал с диска) возвращается до начала вывода — HTTP-ответ ещё можно
+// заменить ошибкой. Передавайте копию задачи (core.Task.Clone).
+func Write(w io.Writer, t *core.Task, h core.TaskHistory, downloadDir string) error {
+	hdr := Header{Format: FormatVersion, ExportedAt: time.Now().UTC(), TaskID: t.ID}
+	if rel, err := filepath.Rel(downloadDir, t.DestDir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		hdr.DestRel = filepath.ToSlash(rel)
+	}
+	var paths []string
+	for _, f := range t.Files {
+		if f.State != core.FileDone || f.Path == "" {
+			continue
+		}
+		size, sum, err := hashFile(f.Path)
+		if err != nil {
+			return fmt.Errorf("file %s: %w", f.ID, err)
+		}
+		hdr.Files = append(hdr.Files, Entry{
+			FileID: f.ID,
+			Name:   path.Join("files", f.ID, filepath.Base(f.Path)),
+			Size:   size,
+			SHA256: sum,
+		})
+		paths = append(paths, f.Path)
+	}
+
+	tw := tar.NewWriter(w)
+	for _, doc := range []struct {
+		name string
+		v    any
+	}{{"bundle.json", hdr}, {"task.json", t}, {"history.json", h}} {
+		if err := writeJSON(tw, doc.name, doc.v, hdr.ExportedAt); err != nil {
+			return err
+		}
+	}
+	for i, e := range hdr.Files {
+		if err := writeFile(tw, e, paths[i], hdr.ExportedAt); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeJSON(tw *tar.Writer, name string, v any, mod time.Time) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(b)), ModTime: mod}); err != nil {
+		return err
+	}
+	_, err = tw.Write(b)
+	return err
+}
+
+func writeFile(tw *tar.Writer, e Entry, src string, mod time.Time) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := tw.WriteHeader(&tar.Header{Name: e.Name, Mode: 0o644, Size: e.Size, ModTime: mod}); err != nil {
+		return err
+	}
+	// размер зафиксирован в заголовке tar: файл, изменившийся после хеширования, — ошибка
+	if _, err := io.CopyN(tw, f, e.Size); err != nil {
+		return fmt.Errorf("file %s: %w", e.FileID, err)
+	}
+	return nil
+}
+
+// Read читает архив из r и распаковывает файлы задачи под downloadDir:
+//   - ID задачи должен быть одним элементом пути (core.ValidTaskID), иначе
+//     ErrFormat;
+//   - каталог задачи — <downloadDir>/<DestRel> (или <downloadDir>/<task_id>),
+//     файл — <каталог>/<dest_subpath>/<имя из архива>;
+//   - accept (может быть nil) вызывается до распаковки файлов и может отклонить
+//     задачу (например, задача с таким ID уже есть) — его ошибка возвращается как есть;
+//   - существующие файлы не перезаписываются (ошибка), размер и sha256 каждого
+//     файла сверяются с оглавлением;
+//   - при любой ошибке уже распакованные файлы удаляются.
+//
+// У возвращённой задачи DestDir и Path файлов указывают на новые места,
+// Blob очищен, прерванные (RUNNING) файлы возвращены в PENDING. DONE-файл
+// без содержимого в архиве — ErrFormat.
+func Read(r io.Reader, downloadDir string, accept func(t *core.Task) error) (b *Bundle, err error) {
+	tr := tar.NewReader(r)
+	b = &Bundle{}
+	if err := readJSON(tr, "bundle.json", &b.Header); err != nil {
+		return nil, err
+	}
+	if b.Header.Format != FormatVersion {
+		return nil, fmt.Errorf("%w: format %d, supported %d", ErrFormat, b.Header.Format, FormatVersion)
+	}
+	if err := readJSON(tr, "task.json", &b.Task); err != nil {
+		return nil, err
+	}
+	if err := readJSON(tr, "history.json", &b.History); err != nil {
+		return nil, err
+	}
+	t := b.Task
+	if t == nil || t.ID == "" || t.ID != b.Header.TaskID {
+		return nil, fmt.Errorf("%w: task.json does not match bundle.json", ErrFormat)
+	}
+	if !core.ValidTaskID(t.ID) || !filepath.IsLocal(t.ID) {
+		return nil, fmt.Errorf("%w: bad task id %q", ErrFormat, t.ID)
+	}
+	t.EnsureFileIDs()
+	if accept != nil {
+		if err := accept(t); err != nil {
+			return nil, err
+		}
+	}
+
+	destDir := filepath.Join(downloadDir, t.ID)
+	if rel := filepath.FromSlash(b.Header.DestRel); rel != "" {
+		if !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("%w: dest_rel %q escapes the download dir", ErrFormat, b.Header.DestRel)
+		}
+		destDir = filepath.Join(downloadDir, rel)
+	}
+	t.DestDir = destDir
+
+	var written []string
+	defer func() {
+		if err != nil {
+			for _, p := range written {
+				os.Remove(p)
+			}
+		}
+	}()
+	extracted := make(map[string]string, len(b.Header.Files)) // file ID → путь
+	for _, e := range b.Header.Files {
+		f, _ := t.FileByID(e.FileID)
+		if f == nil {
+			return nil, fmt.Errorf("%w: unknown file %q", ErrFormat, e.FileID)
+		}
+		name := path.Base(e.Name)
+		sub := filepath.FromSlash(f.DestSubpath)
+		if name == "." || !filepath.IsLocal(name) || (sub != "" && !filepath.IsLocal(sub)) {
+			return nil, fmt.Errorf("%w: bad path for file %q", ErrFormat, e.FileID)
+		}
+		dst := filepath.Join(destDir, sub, name)
+		if err := extract(tr, e, dst); err != nil {
+			return nil, err
+		}
+		written = append(written, dst)
+		extracted[e.FileID] = dst
+		b.Bytes += e.Size
+	}
+
+	for _, f := range t.Files {
+		f.Blob = ""
+		f.Path = extracted[f.ID]
+		if f.State == core.FileDone && f.Path == "" {
+			return nil, fmt.Errorf("%w: no content for done file %q", ErrFormat, f.ID)
+		}
+	}
+	t.ResetInterrupted()
+	return b, nil
+}
+
+func readJSON(tr *tar.Reader, name string, v any) error {
+	h, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrFormat, name, err)
+	}
+	if h.Name != name {
+		return fmt.Errorf("%w: expected %s, got %s", ErrFormat, name, h.Name)
+	}
+	if err := json.NewDecoder(tr).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrFormat, name, err)
+	}
+	return nil
+}
+
+// extract распаковывает очередную запись tar (ожидается e.Name) в dst:
+// через временный файл, со сверкой размера и sha256, без перезаписи dst
+// (жёсткая ссылка вместо rename: существующий dst — ошибка).
+func extract(tr *tar.Reader, e Entry, dst string) error {
+	h, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrFormat, e.Name, err)
+	}
+	if h.Name != e.Name || h.Size != e.Size {
+		return fmt.Errorf("%w: expected %s (%d bytes), got %s (%d bytes)", ErrFormat, e.Name, e.Size, h.Name, h.Size)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp := dst + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, hasher), tr)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(hasher.Sum(nil)); sum != e.SHA256 {
+		return fmt.Errorf("%w: %s: sha256 %s, expected %s", ErrFormat, e.Name, sum, e.SHA256)
+	}
+	if err := os.Link(tmp, dst); err != nil {
+		return fmt.Errorf("file %s: %w", e.FileID, err)
+	}
+	return nil
+}
+
+// hashFile возвращает размер и sha256 (hex) файла.
+func hashFile(p string) (int64, string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
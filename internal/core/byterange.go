@@ -0,0 +1,57 @@
+package core
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// ByteRange — часть удалённого объекта, которую качать вместо него целиком
+// (LinkSpec.Range): Length байт начиная с Offset. Length 0 — до конца объекта.
+type ByteRange struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length,omitempty"`
+}
+
+// Validate проверяет диапазон: Offset и Length неотрицательны, диапазон не
+// пустой (0+0 — весь объект: просто не задавайте range) и конец не выходит
+// за int64.
+func (r ByteRange) Validate() error {
+	if r.Offset < 0 || r.Length < 0 || (r.Offset == 0 && r.Length == 0) || r.Offset > math.MaxInt64-r.Length {
+		return i18n.New(i18n.CodeRangeInvalid, r.Offset, r.Length)
+	}
+	return nil
+}
+
+// End — последний байт диапазона (включительно); -1 — до конца объекта.
+func (r ByteRange) End() int64 {
+	if r.Length == 0 {
+		return -1
+	}
+	return r.Offset + r.Length - 1
+}
+
+// Header — значение заголовка Range запроса: "bytes=100-199" или "bytes=100-".
+func (r ByteRange) Header() string {
+	return "bytes=" + r.String()
+}
+
+// String — диапазон в записи Range/Content-Range без единиц: "100-199" или "100-".
+func (r ByteRange) String() string {
+	s := strconv.FormatInt(r.Offset, 10) + "-"
+	if end := r.End(); end >= 0 {
+		s += strconv.FormatInt(end, 10)
+	}
+	return s
+}
+
+// SourceKey — что именно качает файл: URL, а для части объекта — URL и
+// диапазон. По нему ищутся повторы (MergeDuplicates, DUPLICATE_POLICY):
+// разные части одного URL повторами не считаются.
+func (f *FileItem) SourceKey() string {
+	if f.Range == nil {
+		return f.URL
+	}
+	return f.URL + " bytes=" + f.Range.String()
+}
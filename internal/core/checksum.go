@@ -0,0 +1,62 @@
+package core
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"strings"
+
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// ParseChecksum разбирает контрольную сумму вида "алгоритм:hex"
+// (sha256, sha512, sha1, md5) или просто hex — тогда алгоритм
+// определяется по длине (64 символа — sha256, 128 — sha512, 40 — sha1, 32 — md5).
+// Возвращает имя алгоритма в нижнем регистре и ожидаемый дайджест.
+func ParseChecksum(s string) (algo string, sum []byte, err error) {
+	s = strings.TrimSpace(s)
+	algo, hexSum, ok := strings.Cut(s, ":")
+	if !ok {
+		hexSum = s
+		switch len(s) {
+		case 64:
+			algo = "sha256"
+		case 128:
+			algo = "sha512"
+		case 40:
+			algo = "sha1"
+		case 32:
+			algo = "md5"
+		default:
+			return "", nil, i18n.New(i18n.CodeChecksumUndetected, s)
+		}
+	}
+	algo = strings.ToLower(algo)
+	h := NewHash(algo)
+	if h == nil {
+		return "", nil, i18n.New(i18n.CodeChecksumUnknown, s, algo)
+	}
+	sum, err = hex.DecodeString(hexSum)
+	if err != nil || len(sum) != h.Size() {
+		return "", nil, i18n.New(i18n.CodeChecksumInvalidHex, s, algo)
+	}
+	return algo, sum, nil
+}
+
+// NewHash возвращает hash.Hash для алгоритма algo или nil, если алгоритм не поддерживается.
+func NewHash(algo string) hash.Hash {
+	switch algo {
+	case "sha256":
+		return sha256.New()
+	case "sha512":
+		return sha512.New()
+	case "sha1":
+		return sha1.New()
+	case "md5":
+		return md5.New()
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxTaskEvents — сколько последних событий задачи хранится в памяти
+// (и переживает компактизацию WAL). Более старые отбрасываются, их число
+// видно в TaskHistory.Dropped.
+const MaxTaskEvents = 1000
+
+// Типы событий жизненного цикла задачи (TaskEvent.Type).
+const (
+	EventCreated       = "created"          // задача создана (в т.ч. импортом, из WATCH_DIR, клонированием)
+	EventRecovered     = "recovered"        // восстановлена из WAL после перезапуска
+	EventStatusChanged = "status_changed"   // сменился агрегированный статус
+	EventFileStarted   = "file_started"     // воркер начал попытку загрузки файла
+	EventFileFinished  = "file_finished"    // попытка завершилась (Status файла — DONE/FAILED)
+	EventFileRetry     = "file_retry"       // файл автоматически возвращён в очередь после ошибки
+	EventFileSkipped   = "file_skipped"     // файл пропущен по запросу (POST /tasks/{id}/files/{fid}/skip)
+	EventRetry         = "retry"            // ручной перезапуск упавших файлов (POST /tasks/{id}/retry)
+	EventVerified      = "verified"         // проверка файлов на диске вернула пропавшие/повреждённые в очередь (POST /tasks/{id}/verify)
+	EventPatched       = "patched"          // изменены метаданные (PATCH /tasks/{id})
+	EventDependsMet    = "dependencies_met" // зависимости DependsOn выполнены — файлы поставлены в очередь
+	EventCancelled     = "cancelled"        // файлы задачи отменены (POST /tasks/cancel)
+	EventExpired       = "expired"          // наступил срок задачи (ExpiresAt): не начатые файлы — EXPIRED
+	EventImported      = "imported"         // задача перенесена из архива (downloader import, POST /admin/tasks/import)
+	EventFileUploaded  = "file_uploaded"    // попытка выгрузки файла в хранилище задачи (Status — done/pending/failed)
+	EventNotified      = "notified"         // попытка отправить сводку по завершении (Status — sent/pending/failed, Message — получатель)
+	EventDeleted       = "deleted"          // задача удалена (DELETE /tasks/{id}); только в событиях EVENTS_URL — история удалённой задачи не хранится
+	EventTrashed       = "trashed"          // задача перенесена в корзину (DELETE /tasks/{id} при TRASH_RETENTION)
+	EventRestored      = "restored"         // задача возвращена из корзины (POST /tasks/{id}/restore)
+)
+
+// TaskEvent — одно событие в истории задачи.
+type TaskEvent struct {
+	At      time.Time `json:"at"`
+	Type    string    `json:"type"`
+	FileID  string    `json:"file_id,omitempty"`
+	Status  string    `json:"status,omitempty"` // новый статус задачи или файла
+	Message string    `json:"message,omitempty"`
+}
+
+// TaskHistory — ответ GET /tasks/{id}/history.
+type TaskHistory struct {
+	TaskID  string      `json:"task_id"`
+	Events  []TaskEvent `json:"events"`
+	Dropped int         `json:"dropped,omitempty"` // отброшено старых событий сверх MaxTaskEvents
+}
+
+// eventLog — ограниченный журнал событий задачи (не сериализуется вместе с задачей:
+// события пишутся в WAL отдельными записями).
+type eventLog struct {
+	events  []TaskEvent
+	dropped int
+}
+
+// AddEvent дописывает событие в историю задачи (At = сейчас, если не задано)
+// и возвращает его — для записи в WAL. Вызывать под блокировкой задачи.
+func (t *Task) AddEvent(ev TaskEvent) TaskEvent {
+	if ev.At.IsZero() {
+		ev.At = time.Now().UTC()
+	}
+	if len(t.history.events) >= MaxTaskEvents {
+		drop := len(t.history.events) - MaxTaskEvents + 1
+		t.history.events = append(t.history.events[:0:0], t.history.events[drop:]...)
+		t.history.dropped += drop
+	}
+	t.history.events = append(t.history.events, ev)
+	return ev
+}
+
+// History возвращает копию истории задачи.
+func (t *Task) History() TaskHistory {
+	return TaskHistory{
+		TaskID:  t.ID,
+		Events:  append([]TaskEvent{}, t.history.events...),
+		Dropped: t.history.dropped,
+	}
+}
+
+// RestoreHistory заменяет историю задачи сохранённой копией h (перенос задачи
+// в другое окружение, см. пакет bundle). Лишние старые события сверх
+// MaxTaskEvents отбрасываются и учитываются в Dropped.
+func (t *Task) RestoreHistory(h TaskHistory) {
+	events := h.Events
+	dropped := h.Dropped
+	if len(events) > MaxTaskEvents {
+		dropped += len(events) - MaxTaskEvents
+		events = events[len(events)-MaxTaskEvents:]
+	}
+	t.history = eventLog{events: append([]TaskEvent(nil), events...), dropped: dropped}
+}
+
+// AdoptHistory переносит историю из prev — предыдущей версии той же задачи
+// (при чтении WAL запись "upsert_task" заменяет объект задачи целиком).
+func (t *Task) AdoptHistory(prev *Task) {
+	t.history = prev.history
+}
+
+// RecomputeStatusEvent — RecomputeStatus с записью события EventStatusChanged,
+// если статус изменился. ok=false — статус прежний, события нет.
+func (t *Task) RecomputeStatusEvent(at time.Time) (ev TaskEvent, ok bool) {
+	prev := t.Status
+	t.RecomputeStatus()
+	if t.Status == prev {
+		return TaskEvent{}, false
+	}
+	return t.AddEvent(TaskEvent{
+		At:      at,
+		Type:    EventStatusChanged,
+		Status:  string(t.Status),
+		Message: fmt.Sprintf("%s → %s", prev, t.Status),
+	}), true
+}
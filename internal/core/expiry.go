@@ -0,0 +1,43 @@
+package core
+
+import (
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// Срок задачи (TaskSpec.ExpiresAt) — защита от разовых запросов, которые
+// иначе начали бы качаться через дни, когда рассосётся очередь: к сроку все
+// ещё не начатые файлы (Pending, в том числе ждущие автоповтора) переходят
+// в EXPIRED и убираются из очереди, идущие загрузки докачиваются. EXPIRED —
+// конечное состояние, как CANCELLED: retry его не возвращает.
+
+// validateExpiresAt: срок, если задан, ещё не наступил.
+func validateExpiresAt(at *time.Time) error {
+	if at != nil && !at.After(time.Now()) {
+		return i18n.New(i18n.CodeExpiresPast, at.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// PastExpiry сообщает, что срок задачи (ExpiresAt) к моменту now наступил.
+func (t *Task) PastExpiry(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// ExpirePending переводит в Expired все Pending-файлы задачи, если её срок
+// к моменту now наступил, и возвращает их число. Статус задачи не
+// пересчитывается — это делает вызывающий (RecomputeStatusEvent).
+func (t *Task) ExpirePending(now time.Time) int {
+	if !t.PastExpiry(now) {
+		return 0
+	}
+	n := 0
+	reason := "task expired at " + t.ExpiresAt.Format(time.RFC3339)
+	for _, f := range t.Files {
+		if f.State == FilePending && f.Transition(FileExpired, reason, now) == nil {
+			n++
+		}
+	}
+	return n
+}
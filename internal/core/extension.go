@@ -0,0 +1,91 @@
+package core
+
+import (
+	"mime"
+	"path"
+	"slices"
+	"strings"
+)
+
+// sniffedExtensions — расширения, подходящие типу содержимого, который
+// определяет http.DetectContentType: первое — то, что подставляется при
+// исправлении, остальные тоже считаются верными. Форматы-контейнеры
+// (docx, jar, apk — это zip; tgz — gzip) перечислены, чтобы их не
+// «исправляло» в .zip/.gz. Текстовые типы (html, xml, plain) и
+// application/octet-stream не определяются надёжно и не исправляются.
+var sniffedExtensions = map[string][]string{
+	"application/pdf":               {".pdf"},
+	"application/postscript":        {".ps", ".eps", ".ai"},
+	"application/zip":               {".zip", ".jar", ".war", ".ear", ".apk", ".aar", ".ipa", ".xpi", ".whl", ".nupkg", ".epub", ".cbz", ".kmz", ".docx", ".xlsx", ".pptx", ".odt", ".ods", ".odp", ".vsix", ".crx"},
+	"application/x-gzip":            {".gz", ".tgz", ".gzip", ".svgz"},
+	"application/x-rar-compressed":  {".rar", ".cbr"},
+	"application/wasm":              {".wasm"},
+	"application/ogg":               {".ogg", ".oga", ".ogv", ".ogx", ".opus", ".spx"},
+	"application/vnd.ms-fontobject": {".eot"},
+	"image/png":                     {".png", ".apng"},
+	"image/jpeg":                    {".jpg", ".jpeg", ".jpe", ".jfif"},
+	"image/gif":                     {".gif"},
+	"image/webp":                    {".webp"},
+	"image/bmp":                     {".bmp", ".dib"},
+	"image/x-icon":                  {".ico", ".cur"},
+	"image/vnd.microsoft.icon":      {".ico", ".cur"},
+	"audio/mpeg":                    {".mp3"},
+	"audio/wave":                    {".wav"},
+	"audio/aiff":                    {".aiff", ".aif"},
+	"audio/midi":                    {".mid", ".midi"},
+	"video/mp4":                     {".mp4", ".m4v", ".m4a", ".m4b", ".mov", ".3gp", ".3g2", ".heic", ".avif"},
+	"video/webm":                    {".webm", ".mkv", ".mka"},
+	"video/avi":                     {".avi"},
+	"font/ttf":                      {".ttf"},
+	"font/otf":                      {".otf"},
+	"font/collection":               {".ttc"},
+	"font/woff":                     {".woff"},
+	"font/woff2":                    {".woff2"},
+}
+
+// FixExtension возвращает имя файла name с расширением, соответствующим
+// типу содержимого contentType (результат http.DetectContentType):
+//   - тип неизвестен (см. sniffedExtensions) или расширение уже ему
+//     подходит (без учёта регистра) — name без изменений;
+//   - у name нет расширения или «расширение» не похоже на настоящее
+//     (цифры, как в "v1.2.3", длиннее 8 символов, не буквы и цифры) —
+//     расширение дописывается: "file" → "file.zip";
+//   - иначе расширение заменяется: "download.php" → "download.zip",
+//     "photo.png" с JPEG внутри → "photo.jpg".
+func FixExtension(name, contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return name
+	}
+	exts := sniffedExtensions[mt]
+	if len(exts) == 0 {
+		return name
+	}
+	ext := path.Ext(name)
+	if slices.Contains(exts, strings.ToLower(ext)) {
+		return name
+	}
+	if realExtension(ext) {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name + exts[0]
+}
+
+// realExtension — похоже ли ext (с точкой) на расширение файла: 1..8
+// латинских букв и цифр, не только цифры.
+func realExtension(ext string) bool {
+	if len(ext) < 2 || len(ext) > 9 {
+		return false
+	}
+	letter := false
+	for _, c := range ext[1:] {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			letter = true
+		case c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return letter
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+)
+
+// newFileID генерирует идентификатор файла: 4 случайных байта в hex (8 символов).
+// Уникальность требуется только в пределах задачи (см. EnsureFileIDs).
+func newFileID() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// assignNewFileIDs выдаёт случайные уникальные ID всем файлам новой задачи.
+func (t *Task) assignNewFileIDs() {
+	seen := make(map[string]bool, len(t.Files))
+	for _, f := range t.Files {
+		f.ID = newFileID()
+		for seen[f.ID] {
+			f.ID = newFileID()
+		}
+		seen[f.ID] = true
+	}
+}
+
+// EnsureFileIDs выдаёт ID файлам, у которых его нет, и перестраивает индекс
+// ID → позиция, используемый FileByID. Возвращает число выданных ID.
+//
+// Новые задачи получают случайные ID ещё в NewTaskFromSpecs, так что здесь ID
+// выдаются только файлам из журналов до их появления (WAL v1): детерминированный
+// "f<индекс>" (при совпадении с существующим — случайный). Так один и тот же
+// старый журнал при каждом чтении даёт одинаковые ID, и дельта-записи,
+// ссылающиеся на них, применяются корректно.
+//
+// Вызывать, пока задача недоступна другим горутинам (создание, восстановление).
+func (t *Task) EnsureFileIDs() int {
+	n := 0
+	t.fileIdx = make(map[string]int, len(t.Files))
+	for _, f := range t.Files {
+		if f.ID != "" {
+			t.fileIdx[f.ID] = -1
+		}
+	}
+	for i, f := range t.Files {
+		if f.ID == "" {
+			f.ID = "f" + strconv.Itoa(i)
+			for _, dup := t.fileIdx[f.ID]; dup; _, dup = t.fileIdx[f.ID] {
+				f.ID = newFileID()
+			}
+			n++
+		}
+		t.fileIdx[f.ID] = i
+	}
+	return n
+}
+
+// FileByID возвращает файл задачи и его позицию по ID; (nil, -1), если не найден.
+// Использует индекс EnsureFileIDs, при его отсутствии или промахе — линейный поиск.
+func (t *Task) FileByID(id string) (*FileItem, int) {
+	if i, ok := t.fileIdx[id]; ok && i >= 0 && i < len(t.Files) && t.Files[i].ID == id {
+		return t.Files[i], i
+	}
+	for i, f := range t.Files {
+		if f.ID == id {
+			return f, i
+		}
+	}
+	return nil, -1
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxFileHistory — сколько последних переходов хранится в FileItem.History.
+// Старые записи отбрасываются: история попадает в WAL вместе с файлом.
+const MaxFileHistory = 32
+
+// MaxFileAttempts — сколько последних попыток хранится в FileItem.AttemptLog.
+const MaxFileAttempts = 32
+
+// FileAttempt — одна HTTP-попытка загрузки файла (включая повторы внутри
+// загрузчика): по журналу попыток видно, на каком адресе и как сбоит источник.
+type FileAttempt struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Bytes      int64     `json:"bytes"`                 // записано за попытку
+	HTTPStatus int       `json:"http_status,omitempty"` // 0 — ответа не было
+	Error      string    `json:"error,omitempty"`
+	RemoteIP   string    `json:"remote_ip,omitempty"` // адрес соединения (с прокси — адрес прокси)
+}
+
+// FileEvent — один переход файла между состояниями.
+type FileEvent struct {
+	At     time.Time `json:"at"`
+	From   FileState `json:"from"`
+	To     FileState `json:"to"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// fileTransitions — допустимые переходы состояний файла:
+//
+//	Pending → Running (воркер взял файл) | Cancelled | Skipped | Expired (срок задачи)
+//	Running → Done | Failed | Pending (прерван остановкой сервиса) | Cancelled
+//	Failed  → Pending (повтор: автоматический или POST /tasks/{id}/retry) | Skipped
+//	Done    → Pending (файл пропал или повреждён на диске: POST /tasks/{id}/verify)
+//
+// Cancelled, Skipped и Expired — конечные.
+var fileTransitions = map[FileState][]FileState{
+	FilePending: {FileRunning, FileCancelled, FileSkipped, FileExpired},
+	FileRunning: {FileDone, FileFailed, FilePending, FileCancelled},
+	FileFailed:  {FilePending, FileSkipped},
+	FileDone:    {FilePending},
+}
+
+// CanTransition сообщает, допустим ли переход from → to.
+func CanTransition(from, to FileState) bool {
+	for _, s := range fileTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError — попытка недопустимого перехода (см. fileTransitions).
+type TransitionError struct {
+	FileID string
+	From   FileState
+	To     FileState
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("файл %s: недопустимый переход %s → %s", e.FileID, e.From, e.To)
+}
+
+// Transition переводит файл в состояние to, проверяя допустимость перехода,
+// и дописывает событие в History (не более MaxFileHistory последних).
+//
+// Сопутствующие поля меняются здесь же, чтобы правила были в одном месте:
+//   - Running — StartedAt = at, FinishedAt и Error сбрасываются;
+//   - Done — FinishedAt = at, Error сбрасывается;
+//   - Failed — FinishedAt = at, Error = reason;
+//   - Cancelled, Skipped, Expired — FinishedAt = at (Error сохраняется: у
+//     пропущенного упавшего файла видно, почему он упал);
+//   - Pending — Error, StartedAt и FinishedAt сбрасываются.
+//
+// NextAttemptAt сбрасывается при любом переходе: пауза перед автоповтором
+// назначается вызывающим уже после перехода в Pending.
+//
+// Недопустимый переход возвращает *TransitionError и ничего не меняет.
+// Статус задачи не пересчитывается — это делает вызывающий (RecomputeStatus).
+func (f *FileItem) Transition(to FileState, reason string, at time.Time) error {
+	if !CanTransition(f.State, to) {
+		return &TransitionError{FileID: f.ID, From: f.State, To: to}
+	}
+	ev := FileEvent{At: at, From: f.State, To: to, Reason: reason}
+	if len(f.History) >= MaxFileHistory {
+		f.History = append(f.History[:0:0], f.History[len(f.History)-MaxFileHistory+1:]...)
+	}
+	f.History = append(f.History, ev)
+	f.State = to
+	f.NextAttemptAt = nil
+
+	switch to {
+	case FileRunning:
+		f.StartedAt = &at
+		f.FinishedAt = nil
+		f.Error = ""
+	case FileDone:
+		f.FinishedAt = &at
+		f.Error = ""
+	case FileFailed:
+		f.FinishedAt = &at
+		f.Error = reason
+	case FileCancelled, FileSkipped, FileExpired:
+		f.FinishedAt = &at
+	case FilePending:
+		f.Error = ""
+		f.StartedAt = nil
+		f.FinishedAt = nil
+	}
+	return nil
+}
+
+// AddAttempt дописывает попытку в AttemptLog (не более MaxFileAttempts
+// последних). Журнал попадает в WAL вместе с файлом.
+func (f *FileItem) AddAttempt(a FileAttempt) {
+	if len(f.AttemptLog) >= MaxFileAttempts {
+		f.AttemptLog = append(f.AttemptLog[:0:0], f.AttemptLog[len(f.AttemptLog)-MaxFileAttempts+1:]...)
+	}
+	f.AttemptLog = append(f.AttemptLog, a)
+}
+
+// Clone возвращает глубокую копию файла (временные метки, история и попытки копируются):
+// снимок можно сериализовать и писать в WAL без блокировок.
+func (f *FileItem) Clone() *FileItem {
+	c := *f
+	if f.StartedAt != nil {
+		ts := *f.StartedAt
+		c.StartedAt = &ts
+	}
+	if f.FinishedAt != nil {
+		ts := *f.FinishedAt
+		c.FinishedAt = &ts
+	}
+	if f.NextAttemptAt != nil {
+		ts := *f.NextAttemptAt
+		c.NextAttemptAt = &ts
+	}
+	if f.Upload != nil {
+		u := *f.Upload
+		if u.UploadedAt != nil {
+			ts := *u.UploadedAt
+			u.UploadedAt = &ts
+		}
+		c.Upload = &u
+	}
+	if f.Response != nil {
+		r := *f.Response
+		if r.LastModified != nil {
+			ts := *r.LastModified
+			r.LastModified = &ts
+		}
+		c.Response = &r
+	}
+	if f.SignatureCheck != nil {
+		sc := *f.SignatureCheck
+		c.SignatureCheck = &sc
+	}
+	c.History = append([]FileEvent(nil), f.History...)
+	c.AttemptLog = append([]FileAttempt(nil), f.AttemptLog...)
+	c.Aliases = append([]string(nil), f.Aliases...)
+	return &c
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"net"
+	"strings"
+)
+
+// MatchHost сравнивает host (порт игнорируется, регистр не важен) с шаблоном
+// pattern: точное имя или "*.example.com" — поддомены (но не сам example.com).
+func MatchHost(pattern, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host, pattern = strings.ToLower(host), strings.ToLower(pattern)
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return host == pattern
+}
+
+// LookupHost находит в m значение для host по ключам-шаблонам (MatchHost):
+// ключ, совпадающий с host целиком (с портом, если он есть), важнее точного
+// имени без порта, а то — любого "*.домена"; из нескольких "*.доменов"
+// выбирается самый длинный (самый конкретный). ok=false — не подошёл ни один.
+func LookupHost[V any](m map[string]V, host string) (v V, ok bool) {
+	_, v, ok = LookupHostKey(m, host)
+	return v, ok
+}
+
+// LookupHostKey — как LookupHost, но возвращает и подошедший ключ-шаблон:
+// по нему делят состояние все хосты одного шаблона (например, общий предел
+// скорости "*.example.com").
+func LookupHostKey[V any](m map[string]V, host string) (key string, v V, ok bool) {
+	if len(m) == 0 {
+		return "", v, false
+	}
+	host = strings.ToLower(host)
+	if v, ok := m[host]; ok {
+		return host, v, true
+	}
+	for pattern, pv := range m {
+		if !MatchHost(pattern, host) {
+			continue
+		}
+		exact := !strings.HasPrefix(pattern, "*")
+		switch {
+		case exact:
+			return pattern, pv, true
+		case len(pattern) > len(key):
+			key, v, ok = pattern, pv, true
+		}
+	}
+	return key, v, ok
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// MaxLinkAttempts — верхняя граница max_attempts для одной ссылки.
+const MaxLinkAttempts = 100
+
+// AllowedSchemes — схемы URL, которые умеет качать загрузчик.
+var AllowedSchemes = map[string]bool{"http": true, "https": true}
+
+// LinkSpec — описание одной ссылки при создании задачи.
+//
+// В JSON допускается как объект, так и просто строка с URL
+// (["https://…", {"url": "https://…", "filename": "a.bin"}]).
+type LinkSpec struct {
+	URL         string            `json:"url"`
+	Filename    string            `json:"filename,omitempty"`     // пусто — из пути URL
+	DestSubpath string            `json:"dest_subpath,omitempty"` // подкаталог внутри каталога задачи
+	Checksum    string            `json:"checksum,omitempty"`     // "sha256:<hex>" и т.п., см. ParseChecksum
+	Headers     map[string]string `json:"headers,omitempty"`      // доп. заголовки запроса (Authorization, Cookie, …)
+	HostHeader  string            `json:"host_header,omitempty"`  // заголовок Host вместо хоста из URL (виртуальный хост)
+	ConnectTo   string            `json:"connect_to,omitempty"`   // IP[:порт] для соединения вместо DNS (edge CDN, сервер до переключения DNS)
+	Signature   string            `json:"signature,omitempty"`    // ссылка на отделённую подпись OpenPGP (.asc/.sig)
+	Range       *ByteRange        `json:"range,omitempty"`        // качать только часть объекта (offset+length)
+	MaxAttempts int               `json:"max_attempts,omitempty"` // 0 — по умолчанию сервиса (RETRIES)
+}
+
+// UnmarshalJSON принимает строку (только URL) или объект LinkSpec.
+func (s *LinkSpec) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		*s = LinkSpec{}
+		return json.Unmarshal(data, &s.URL)
+	}
+	type plain LinkSpec
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*s = LinkSpec(p)
+	return nil
+}
+
+// Validate проверяет ссылку:
+//   - URL парсится, имеет хост и схему из AllowedSchemes;
+//   - контрольная сумма (если задана) разбирается ParseChecksum;
+//   - DestSubpath — относительный путь без выхода наверх ("..");
+//   - заголовки — корректные имена без управляющих (Host, Range, …) и значения без переводов строк;
+//   - HostHeader — хост[:порт], ConnectTo — IP-адрес[:порт] (см. ValidateConnectTo);
+//   - Signature — http(s)-ссылка с хостом;
+//   - Range (если задан) проходит ByteRange.Validate;
+//   - MaxAttempts в диапазоне 0..MaxLinkAttempts.
+func (s LinkSpec) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return i18n.New(i18n.CodeInvalidURL, s.URL)
+	}
+	if !AllowedSchemes[strings.ToLower(u.Scheme)] {
+		return i18n.New(i18n.CodeUnsupportedScheme, u.Scheme)
+	}
+	if s.Checksum != "" {
+		if _, _, err := ParseChecksum(s.Checksum); err != nil {
+			return err
+		}
+	}
+	if _, err := cleanSubpath(s.DestSubpath); err != nil {
+		return err
+	}
+	for name, value := range s.Headers {
+		if err := ValidateHeader(name, value); err != nil {
+			return err
+		}
+	}
+	if s.HostHeader != "" && !validHostPort(s.HostHeader) {
+		return i18n.New(i18n.CodeHostHeaderInvalid, s.HostHeader)
+	}
+	if err := ValidateConnectTo(s.ConnectTo); err != nil {
+		return err
+	}
+	if s.Signature != "" {
+		su, err := url.Parse(s.Signature)
+		if err != nil || su.Host == "" || !AllowedSchemes[strings.ToLower(su.Scheme)] {
+			return i18n.New(i18n.CodeSignatureURLInvalid, s.Signature)
+		}
+	}
+	if s.Range != nil {
+		if err := s.Range.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.MaxAttempts < 0 || s.MaxAttempts > MaxLinkAttempts {
+		return i18n.New(i18n.CodeOutOfRange, "max_attempts", 0, MaxLinkAttempts, s.MaxAttempts)
+	}
+	return nil
+}
+
+// cleanSubpath нормализует относительный подкаталог ("a//b/./c" → "a/b/c").
+// Абсолютные пути, пути с ".." и обратные слэши отвергаются — файл не должен
+// выйти за пределы каталога задачи.
+func cleanSubpath(p string) (string, error) {
+	if p == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(p, "/") || strings.ContainsRune(p, '\\') {
+		return "", i18n.New(i18n.CodeDestSubpathAbsolute, p)
+	}
+	for _, part := range strings.Split(p, "/") {
+		if part == ".." {
+			return "", i18n.New(i18n.CodeDestSubpathParent, p)
+		}
+	}
+	clean := path.Clean(p)
+	if clean == "." {
+		return "", nil
+	}
+	return clean, nil
+}
+
+// forbiddenHeaders — заголовки, которые управляются самим загрузчиком/транспортом.
+var forbiddenHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Upgrade":           true,
+	"Te":                true,
+	"Trailer":           true,
+	"Range":             true,
+	"Accept-Encoding":   true,
+}
+
+// ValidateHeader проверяет имя (токен RFC 7230) и значение заголовка (ссылки,
+// входа на сайт-источник, профиля хоста).
+func ValidateHeader(name, value string) error {
+	if name == "" {
+		return i18n.New(i18n.CodeHeaderNameEmpty)
+	}
+	if !validToken(name) {
+		return i18n.New(i18n.CodeHeaderNameInvalid, name)
+	}
+	if forbiddenHeaders[http.CanonicalHeaderKey(name)] {
+		return i18n.New(i18n.CodeHeaderForbidden, name)
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return i18n.New(i18n.CodeHeaderValueInvalid, name)
+	}
+	return nil
+}
+
+// validToken сообщает, что s — токен HTTP (имя заголовка или cookie):
+// печатные ASCII без пробелов и разделителей.
+func validToken(s string) bool {
+	for _, r := range s {
+		if r > 0x7e || r <= ' ' || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// validHostPort сообщает, что s — хост[:порт] без пользователя, пути и
+// пробелов (как в URL).
+func validHostPort(s string) bool {
+	if strings.ContainsAny(s, " \t\r\n/\\?#@") {
+		return false
+	}
+	u, err := url.Parse("//" + s)
+	return err == nil && u.Host == s && u.Hostname() != ""
+}
+
+// ValidateConnectTo проверяет адрес соединения ссылки (LinkSpec.ConnectTo):
+// IP-адрес с портом или без ("203.0.113.7", "203.0.113.7:8443", "[2001:db8::1]:443",
+// "2001:db8::1"). Пусто — соединение по DNS, не ошибка.
+func ValidateConnectTo(s string) error {
+	if s == "" {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		host, port = strings.Trim(s, "[]"), ""
+	}
+	if net.ParseIP(host) == nil {
+		return i18n.New(i18n.CodeConnectToInvalid, s)
+	}
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return i18n.New(i18n.CodeConnectToInvalid, s)
+		}
+	}
+	return nil
+}
+
+// ConnectHost — IP-адрес из connect_to без порта и скобок (для проверки по
+// списку разрешённых хостов); пусто — адрес не задан.
+func ConnectHost(connectTo string) string {
+	if host, _, err := net.SplitHostPort(connectTo); err == nil {
+		return host
+	}
+	return strings.Trim(connectTo, "[]")
+}
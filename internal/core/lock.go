@@ -0,0 +1,18 @@
+package core
+
+import "sync"
+
+// InitLock создаёт блокировку задачи (Mutex), если её ещё нет. Сервис
+// вызывает его до того, как задача станет видна другим горутинам.
+func (t *Task) InitLock() {
+	if t.mu == nil {
+		t.mu = new(sync.Mutex)
+	}
+}
+
+// Mutex — блокировка задачи: под ней сервис меняет одну задачу, не
+// останавливая работу с остальными (см. app.App.mu). До InitLock — nil.
+//
+// Методов Lock/Unlock у Task нет намеренно: с ними go vet считал бы копию
+// задачи (Clone) копией блокировки.
+func (t *Task) Mutex() *sync.Mutex { return t.mu }
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// MaxLoginFormFields — наибольшее число полей формы входа (LoginSpec.Form).
+const MaxLoginFormFields = 32
+
+// LoginSpec — вход на сайт-источник перед скачиванием файлов задачи
+// (TaskSpec.Login): один HTTP-запрос, чьи cookie (сессия) затем уходят со
+// всеми загрузками задачи. Подходит для источников за простой формой входа.
+//
+// Учтите: поля формы (обычно логин и пароль) хранятся в WAL как есть; в
+// ответах API, как и Headers ссылок, видны только их имена (Task.Redacted).
+type LoginSpec struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`  // GET | POST; пусто — POST
+	Form    map[string]string `json:"form,omitempty"`    // поля формы: тело x-www-form-urlencoded (POST) или query (GET)
+	Headers map[string]string `json:"headers,omitempty"` // доп. заголовки запроса входа
+	Cookie  string            `json:"cookie,omitempty"`  // cookie, которую выставляет успешный вход; пусто — не проверяется
+}
+
+// Validate проверяет описание входа:
+//   - URL парсится, имеет хост и схему из AllowedSchemes;
+//   - Method — пусто, GET или POST (регистр не важен);
+//   - Form — не больше MaxLoginFormFields полей с непустыми именами;
+//   - заголовки — как у ссылок (ValidateHeader);
+//   - Cookie — корректное имя cookie (токен без пробелов и разделителей).
+func (l LoginSpec) Validate() error {
+	u, err := url.Parse(l.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return i18n.New(i18n.CodeInvalidURL, l.URL)
+	}
+	if !AllowedSchemes[strings.ToLower(u.Scheme)] {
+		return i18n.New(i18n.CodeUnsupportedScheme, u.Scheme)
+	}
+	switch strings.ToUpper(l.Method) {
+	case "", http.MethodGet, http.MethodPost:
+	default:
+		return i18n.New(i18n.CodeLoginMethodInvalid, l.Method)
+	}
+	if len(l.Form) > MaxLoginFormFields {
+		return i18n.New(i18n.CodeLoginFormInvalid, MaxLoginFormFields)
+	}
+	for name := range l.Form {
+		if name == "" {
+			return i18n.New(i18n.CodeLoginFormInvalid, MaxLoginFormFields)
+		}
+	}
+	for name, value := range l.Headers {
+		if err := ValidateHeader(name, value); err != nil {
+			return err
+		}
+	}
+	if l.Cookie != "" && !validToken(l.Cookie) {
+		return i18n.New(i18n.CodeLoginCookieInvalid, l.Cookie)
+	}
+	return nil
+}
+
+// HTTPMethod — метод запроса входа (POST, если не задан).
+func (l LoginSpec) HTTPMethod() string {
+	if l.Method == "" {
+		return http.MethodPost
+	}
+	return strings.ToUpper(l.Method)
+}
+
+// Host — хост URL входа (для проверки по списку разрешённых хостов).
+func (l LoginSpec) Host() string {
+	u, err := url.Parse(l.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
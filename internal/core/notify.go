@@ -0,0 +1,104 @@
+package core
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// MaxNotifyRecipients — сколько адресов можно указать в notify_email задачи.
+const MaxNotifyRecipients = 10
+
+// NotifyRecipients разбирает notify_email — адреса через запятую (RFC 5322,
+// можно с именем: "Ops <ops@example.com>") — и возвращает адреса без имён.
+// Пустая строка, больше MaxNotifyRecipients адресов или нераспознанный
+// адрес — ошибка notify_email_invalid.
+func NotifyRecipients(s string) ([]string, error) {
+	list, err := mail.ParseAddressList(s)
+	if err != nil || len(list) == 0 || len(list) > MaxNotifyRecipients {
+		return nil, i18n.New(i18n.CodeNotifyEmailInvalid, MaxNotifyRecipients, s)
+	}
+	out := make([]string, len(list))
+	for i, a := range list {
+		out[i] = a.Address
+	}
+	return out, nil
+}
+
+// validateNotifyEmail: notify_email — пусто или список адресов (NotifyRecipients).
+func validateNotifyEmail(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	_, err := NotifyRecipients(s)
+	return err
+}
+
+// NotifyChannelEmail — канал сводки на notify_email (Notification.Channel);
+// остальные каналы — имена уведомителей из конфигурации (notifiers).
+const NotifyChannelEmail = "email"
+
+// MaxNotifiers — сколько уведомителей можно указать в notify задачи.
+const MaxNotifiers = 8
+
+// Состояния доставки уведомления (Notification.State).
+const (
+	NotifyPending = "pending" // ждёт отправки (или повтора после ошибки)
+	NotifySent    = "sent"
+	NotifyFailed  = "failed" // попытки исчерпаны
+)
+
+// Notification — доставка уведомления о завершении задачи в один канал.
+// Хранится в задаче (и в WAL): недоставленные уведомления отправляются и
+// после перезапуска сервиса.
+type Notification struct {
+	Channel  string     `json:"channel"` // NotifyChannelEmail или имя уведомителя
+	Status   TaskStatus `json:"status"`  // конечный статус задачи, о котором уведомление
+	State    string     `json:"state"`   // NotifyPending | NotifySent | NotifyFailed
+	Attempts int        `json:"attempts"`
+	Error    string     `json:"error,omitempty"`
+	QueuedAt time.Time  `json:"queued_at"`
+	SentAt   *time.Time `json:"sent_at,omitempty"`
+}
+
+// QueueNotification ставит уведомление в канал channel о завершении задачи
+// со статусом status. Прежнее уведомление в тот же канал (о предыдущем
+// завершении, до повтора задачи) заменяется: в задаче хранится последнее.
+// Вызывать под блокировкой задачи.
+func (t *Task) QueueNotification(channel string, status TaskStatus, now time.Time) *Notification {
+	n := &Notification{Channel: channel, Status: status, State: NotifyPending, QueuedAt: now}
+	for i, old := range t.Notifications {
+		if old.Channel == channel {
+			t.Notifications[i] = n
+			return n
+		}
+	}
+	t.Notifications = append(t.Notifications, n)
+	return n
+}
+
+// Notification возвращает уведомление в канал channel; nil — нет.
+func (t *Task) Notification(channel string) *Notification {
+	for _, n := range t.Notifications {
+		if n.Channel == channel {
+			return n
+		}
+	}
+	return nil
+}
+
+// validateNotify: не больше MaxNotifiers имён, непустые, без пробелов.
+func validateNotify(names []string) error {
+	if len(names) > MaxNotifiers {
+		return i18n.New(i18n.CodeNotifyInvalid, MaxNotifiers)
+	}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || strings.ContainsAny(name, " \t/") {
+			return i18n.New(i18n.CodeNotifyInvalid, MaxNotifiers)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// Автоповтор PARTIAL-задачи (TaskSpec.PartialRetry) — для источников, которые
+// сбоят часами: автоповторы файлов (RETRIES, RETRY_BACKOFF) укладываются в
+// минуты, и задача завершается PARTIAL. С политикой через After после
+// завершения последнего файла упавшие файлы (Failed) сами возвращаются в
+// очередь с обнулённым счётчиком попыток — как POST /tasks/{id}/retry, — и так
+// не больше Max раз. Счётчик сделанных повторов (Count) хранится в задаче и
+// переживает перезапуск; момент следующего повтора вычисляется, а не хранится
+// (PartialRetryAt). EXPIRED- и CANCELLED-файлы не повторяются, задача с
+// наступившим сроком (ExpiresAt) — тоже.
+
+// Пределы политики автоповтора.
+const (
+	MinPartialRetryAfter = time.Minute // пауза не короче минуты (текст — в i18n.CodePartialRetryAfter)
+	MaxPartialRetries    = 100         // не больше 100 повторов
+)
+
+// PartialRetrySpec — политика автоповтора в запросе создания задачи.
+type PartialRetrySpec struct {
+	After string `json:"after"` // пауза после завершения задачи PARTIAL: "30m", "12h" (time.ParseDuration)
+	Max   int    `json:"max"`   // сколько раз повторять, 1..MaxPartialRetries
+}
+
+// PartialRetry — политика автоповтора задачи и число уже сделанных повторов.
+type PartialRetry struct {
+	PartialRetrySpec
+	Count int `json:"count"` // сделано автоповторов
+}
+
+// Validate проверяет политику: After — длительность не короче
+// MinPartialRetryAfter, Max — в пределах 1..MaxPartialRetries.
+func (p PartialRetrySpec) Validate() error {
+	if d, err := time.ParseDuration(p.After); err != nil || d < MinPartialRetryAfter {
+		return i18n.New(i18n.CodePartialRetryAfter, p.After)
+	}
+	if p.Max < 1 || p.Max > MaxPartialRetries {
+		return i18n.New(i18n.CodeOutOfRange, "partial_retry.max", 1, MaxPartialRetries, p.Max)
+	}
+	return nil
+}
+
+// Interval — пауза After (политика уже проверена Validate).
+func (p PartialRetrySpec) Interval() time.Duration {
+	d, _ := time.ParseDuration(p.After)
+	return d
+}
+
+// PartialRetryAt возвращает момент следующего автоповтора: через After после
+// завершения последнего файла задачи. ok=false — повтора не будет: политики
+// нет, задача не PARTIAL, упавших файлов нет, повторы исчерпаны, срок
+// задачи к моменту now наступил или задача в корзине.
+func (t *Task) PartialRetryAt(now time.Time) (at time.Time, ok bool) {
+	p := t.PartialRetry
+	if p == nil || t.Status != TaskPartial || t.Failed == 0 || p.Count >= p.Max || t.PastExpiry(now) || t.TrashedAt != nil {
+		return time.Time{}, false
+	}
+	for _, f := range t.Files {
+		if f.FinishedAt != nil && f.FinishedAt.After(at) {
+			at = *f.FinishedAt
+		}
+	}
+	return at.Add(p.Interval()), true
+}
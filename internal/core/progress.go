@@ -0,0 +1,107 @@
+package core
+
+import "math"
+
+// recomputeProgress пересчитывает байтовые итоги и проценты задачи и её файлов.
+// Вызывается из RecomputeStatus.
+//
+// Правила:
+//   - файл: DONE — 100%; иначе BytesDownloaded/SizeHint, если размер известен
+//     (Content-Length), иначе 0%;
+//   - задача: TotalBytesExpected — сумма известных SizeHint, TotalBytesDownloaded —
+//     сумма BytesDownloaded; завершённые файлы (FileState.Finished: DONE, FAILED,
+//     CANCELLED, SKIPPED) считаются
+//     обработанными целиком. Если размеры известны у всех файлов — процент по байтам,
+//     иначе — среднее по файлам.
+func (t *Task) recomputeProgress() {
+	var expected, downloaded, doneBytes int64
+	var sumPct float64
+	allSized := len(t.Files) > 0
+	for _, f := range t.Files {
+		f.ProgressPercent = f.progress()
+		expected += f.SizeHint
+		downloaded += f.BytesDownloaded
+		if f.SizeHint <= 0 {
+			allSized = false
+		}
+		switch {
+		case f.State.Finished():
+			doneBytes += f.SizeHint
+			sumPct += 100
+		default:
+			doneBytes += min(f.BytesDownloaded, f.SizeHint)
+			sumPct += f.ProgressPercent
+		}
+	}
+	t.TotalBytesExpected = expected
+	t.TotalBytesDownloaded = downloaded
+	t.prog = taskProgress{allSized: allSized, doneBytes: doneBytes, sumPct: sumPct}
+	t.updatePercent()
+}
+
+// taskProgress — промежуточные суммы recomputeProgress, позволяющие
+// AddFileProgress обновлять процент задачи за O(1).
+type taskProgress struct {
+	allSized  bool    // размеры известны у всех файлов
+	doneBytes int64   // обработанные байты (для процента по байтам)
+	sumPct    float64 // сумма процентов файлов (для среднего)
+}
+
+// updatePercent выводит ProgressPercent задачи из t.prog.
+func (t *Task) updatePercent() {
+	switch {
+	case len(t.Files) == 0:
+		t.ProgressPercent = 0
+	case t.prog.allSized && t.TotalBytesExpected > 0:
+		t.ProgressPercent = roundPercent(float64(t.prog.doneBytes) * 100 / float64(t.TotalBytesExpected))
+	default:
+		t.ProgressPercent = roundPercent(t.prog.sumPct / float64(len(t.Files)))
+	}
+}
+
+// AddFileProgress обновляет число скачанных байт файла f (n — записано в текущей
+// попытке) и инкрементально поправляет итоги задачи без полного пересчёта —
+// вызывается часто, из колбэка прогресса загрузчика.
+// Для завершённых файлов вклад в процент задачи не меняется.
+func (t *Task) AddFileProgress(f *FileItem, n int64) {
+	t.touch()
+	t.TotalBytesDownloaded += n - f.BytesDownloaded
+	if !f.State.Finished() {
+		oldPct, oldBytes := f.ProgressPercent, min(f.BytesDownloaded, f.SizeHint)
+		f.BytesDownloaded = n
+		f.ProgressPercent = f.progress()
+		t.prog.sumPct += f.ProgressPercent - oldPct
+		t.prog.doneBytes += min(n, f.SizeHint) - oldBytes
+		t.updatePercent()
+		return
+	}
+	f.BytesDownloaded = n
+	f.ProgressPercent = f.progress()
+}
+
+// SetFileSize запоминает ожидаемый размер файла (Content-Length) и пересчитывает прогресс.
+func (t *Task) SetFileSize(f *FileItem, size int64) {
+	if size <= 0 || f.SizeHint == size {
+		return
+	}
+	f.SizeHint = size
+	t.recomputeProgress()
+	t.touch()
+}
+
+// progress — процент готовности одного файла.
+func (f *FileItem) progress() float64 {
+	switch {
+	case f.State == FileDone:
+		return 100
+	case f.SizeHint > 0:
+		return roundPercent(math.Min(100, float64(f.BytesDownloaded)*100/float64(f.SizeHint)))
+	default:
+		return 0
+	}
+}
+
+// roundPercent округляет процент до десятых.
+func roundPercent(p float64) float64 {
+	return math.Round(p*10) / 10
+}
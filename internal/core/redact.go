@@ -0,0 +1,50 @@
+package core
+
+// Секреты в ответах API. Заголовки ссылок (FileItem.Headers: Authorization,
+// Cookie…) и поля/заголовки входа (Task.Login) нужны воркеру и хранятся в WAL
+// как есть, но клиентам API, SSE и выгрузок отдаются только имена: значения
+// заменяются на RedactedValue.
+
+// RedactedValue — чем в ответах API заменяются значения секретов.
+const RedactedValue = "***"
+
+// Redacted возвращает копию снимка задачи (Clone) для ответа API: значения
+// заголовков файлов и полей входа заменены на RedactedValue. Сам снимок не
+// меняется — его может читать и фоновая запись WAL.
+func (t *Task) Redacted() *Task {
+	c := *t
+	if t.Login != nil {
+		l := *t.Login
+		l.Form = redactValues(l.Form)
+		l.Headers = redactValues(l.Headers)
+		c.Login = &l
+	}
+	c.Files = make([]*FileItem, len(t.Files))
+	for i, f := range t.Files {
+		c.Files[i] = f.Redacted()
+	}
+	return &c
+}
+
+// Redacted возвращает копию файла для ответа API со значениями Headers,
+// заменёнными на RedactedValue (см. Task.Redacted).
+func (f *FileItem) Redacted() *FileItem {
+	if len(f.Headers) == 0 {
+		return f
+	}
+	c := *f
+	c.Headers = redactValues(f.Headers)
+	return &c
+}
+
+// redactValues — новая карта с теми же ключами и значениями RedactedValue.
+func redactValues(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return m
+	}
+	r := make(map[string]string, len(m))
+	for k := range m {
+		r[k] = RedactedValue
+	}
+	return r
+}
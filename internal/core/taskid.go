@@ -0,0 +1,96 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// Форматы ID задач (TASK_ID_FORMAT). Все три растут со временем создания,
+// так что сортировка по ID — это сортировка по времени (с точностью до
+// секунды у IDTimestamp и до миллисекунды у остальных).
+const (
+	IDTimestamp = "timestamp" // "20250929-101000-a1b2c3" (NewID), по умолчанию
+	IDUUIDv7    = "uuidv7"    // "0199a3c4-5e6f-7a8b-9c0d-1e2f3a4b5c6d" (RFC 9562)
+	IDULID      = "ulid"      // "01K6HW8QGZ5R3M9T2X7B4N6D0C" (26 символов Crockford base32)
+)
+
+// ValidIDFormat сообщает, что format — известный формат ID задач (пусто —
+// IDTimestamp).
+func ValidIDFormat(format string) bool {
+	switch format {
+	case "", IDTimestamp, IDUUIDv7, IDULID:
+		return true
+	}
+	return false
+}
+
+// maxTaskIDLen — предел длины ID задачи из внешнего источника (ValidTaskID).
+const maxTaskIDLen = 128
+
+// ValidTaskID сообщает, что id пригоден как ID задачи из внешнего источника
+// (архив задачи): непустой, не длиннее maxTaskIDLen, только латиница, цифры,
+// '-', '_' и '.', не "." и не "..". Такой ID — один элемент пути (каталог
+// DOWNLOAD_DIR/<ID>) и один сегмент URL (/tasks/{id}).
+func ValidTaskID(id string) bool {
+	if id == "" || len(id) > maxTaskIDLen || id == "." || id == ".." {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewIDFormat генерирует ID задачи в формате format (IDTimestamp, IDUUIDv7,
+// IDULID; пусто или неизвестный — IDTimestamp, как NewID).
+func NewIDFormat(format string) string {
+	switch format {
+	case IDUUIDv7:
+		return newUUIDv7(time.Now())
+	case IDULID:
+		return newULID(time.Now())
+	}
+	return NewID()
+}
+
+// newUUIDv7 — UUID версии 7: 48 бит миллисекунд Unix-времени, затем версия,
+// вариант и 74 случайных бита.
+func newUUIDv7(now time.Time) string {
+	var b [16]byte
+	_, _ = rand.Read(b[6:])
+	ms := uint64(now.UnixMilli())
+	b[0], b[1], b[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	b[3], b[4], b[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	b[6] = b[6]&0x0f | 0x70 // версия 7
+	b[8] = b[8]&0x3f | 0x80 // вариант RFC 9562
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// crockford — алфавит Crockford base32 (без I, L, O, U).
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID — ULID: 48 бит миллисекунд Unix-времени и 80 случайных бит,
+// 26 символов Crockford base32.
+func newULID(now time.Time) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(now.UnixMilli())<<16)
+	_, _ = rand.Read(b[6:])
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	// 128 бит по 5, начиная со старших: первый символ несёт только 3 бита.
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
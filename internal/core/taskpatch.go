@@ -0,0 +1,155 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// Ограничения на метаданные задачи.
+const (
+	MaxTags      = 32   // тегов у одной задачи
+	MaxTagLen    = 64   // символов в одном теге
+	MaxLabelLen  = 256  // символов в метке
+	PriorityMin  = -100 // самый низкий приоритет
+	PriorityMax  = 100  // самый высокий приоритет
+	MaxDependsOn = 64   // задач в depends_on
+)
+
+// TaskPatch — частичное изменение задачи (тело PATCH /tasks/{id}).
+// nil-поле означает «не менять»; пустой срез Tags очищает теги.
+type TaskPatch struct {
+	Label       *string   `json:"label,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+	Priority    *int      `json:"priority,omitempty"`
+	MaxAttempts *int      `json:"max_attempts,omitempty"` // применяется только к Pending-файлам
+}
+
+// Empty сообщает, что патч ничего не меняет.
+func (p TaskPatch) Empty() bool {
+	return p.Label == nil && p.Tags == nil && p.Priority == nil && p.MaxAttempts == nil
+}
+
+// Fields возвращает имена заданных полей патча (для истории задачи).
+func (p TaskPatch) Fields() []string {
+	var fields []string
+	if p.Label != nil {
+		fields = append(fields, "label")
+	}
+	if p.Tags != nil {
+		fields = append(fields, "tags")
+	}
+	if p.Priority != nil {
+		fields = append(fields, "priority")
+	}
+	if p.MaxAttempts != nil {
+		fields = append(fields, "max_attempts")
+	}
+	return fields
+}
+
+// Validate проверяет значения патча (см. validateLabel, validateTags,
+// validatePriority; MaxAttempts — 1..MaxLinkAttempts).
+func (p TaskPatch) Validate() error {
+	if p.Label != nil {
+		if err := validateLabel(*p.Label); err != nil {
+			return err
+		}
+	}
+	if p.Tags != nil {
+		if err := validateTags(*p.Tags); err != nil {
+			return err
+		}
+	}
+	if p.Priority != nil {
+		if err := validatePriority(*p.Priority); err != nil {
+			return err
+		}
+	}
+	if p.MaxAttempts != nil && (*p.MaxAttempts < 1 || *p.MaxAttempts > MaxLinkAttempts) {
+		return i18n.New(i18n.CodeOutOfRange, "max_attempts", 1, MaxLinkAttempts, *p.MaxAttempts)
+	}
+	return nil
+}
+
+// Apply применяет проверенный патч к задаче.
+//
+// MaxAttempts меняется только у файлов в состоянии Pending: у запущенных
+// текущая попытка уже идёт, а у завершённых лимит больше не используется
+// (для них есть POST /tasks/{id}/retry). Файл, уже исчерпавший новый лимит,
+// всё равно получит одну попытку — он уже стоит в очереди.
+//
+// Возвращает число файлов, у которых изменён MaxAttempts.
+func (t *Task) Apply(p TaskPatch) int {
+	if p.Label != nil {
+		t.Label = *p.Label
+	}
+	if p.Tags != nil {
+		t.Tags = normalizeTags(*p.Tags)
+	}
+	if p.Priority != nil {
+		t.Priority = *p.Priority
+	}
+	n := 0
+	if p.MaxAttempts != nil {
+		for _, f := range t.Files {
+			if f.State == FilePending && f.MaxAttempts != *p.MaxAttempts {
+				f.MaxAttempts = *p.MaxAttempts
+				n++
+			}
+		}
+	}
+	t.touch()
+	return n
+}
+
+func validateLabel(label string) error {
+	if len([]rune(label)) > MaxLabelLen {
+		return i18n.New(i18n.CodeLabelTooLong, MaxLabelLen)
+	}
+	return nil
+}
+
+// validateTags: не больше MaxTags тегов, каждый непустой (после обрезки
+// пробелов), не длиннее MaxTagLen и без управляющих символов.
+func validateTags(tags []string) error {
+	if len(tags) > MaxTags {
+		return i18n.New(i18n.CodeTooManyTags, MaxTags, len(tags))
+	}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return i18n.New(i18n.CodeTagEmpty)
+		}
+		if len([]rune(tag)) > MaxTagLen {
+			return i18n.New(i18n.CodeTagTooLong, tag, MaxTagLen)
+		}
+		if strings.ContainsFunc(tag, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+			return i18n.New(i18n.CodeTagControlChars, tag)
+		}
+	}
+	return nil
+}
+
+func validatePriority(p int) error {
+	if p < PriorityMin || p > PriorityMax {
+		return i18n.New(i18n.CodeOutOfRange, "priority", PriorityMin, PriorityMax, p)
+	}
+	return nil
+}
+
+// normalizeTags обрезает пробелы и убирает повторы, сохраняя порядок.
+// Пустой результат — nil (в JSON поле опускается).
+func normalizeTags(tags []string) []string {
+	var out []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out
+}
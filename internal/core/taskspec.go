@@ -0,0 +1,260 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// Раскладка файлов задачи в каталоге назначения.
+const (
+	LayoutFlat         = "flat"          // все файлы в один каталог (по умолчанию)
+	LayoutPreservePath = "preserve_path" // <host>/<путь из URL>/<имя файла>
+)
+
+// Политики повторов URL (TaskSpec.Duplicates, DUPLICATE_POLICY).
+const (
+	DuplicatesAllow  = "allow"  // повторы качаются как отдельные файлы (суффиксы "-1", "-2")
+	DuplicatesDedupe = "dedupe" // повтор сливается с первым файлом (Task.MergeDuplicates), недавние — пропускаются
+	DuplicatesReject = "reject" // задача с повтором не создаётся
+)
+
+// TaskSpec — описание создаваемой задачи (тело POST /tasks, .json-манифест и т.п.).
+type TaskSpec struct {
+	Links      []LinkSpec `json:"links"` // строки URL или объекты LinkSpec
+	Label      string     `json:"label"`
+	DestDir    string     `json:"dest_dir"`         // подкаталог под DOWNLOAD_DIR
+	Layout     string     `json:"layout,omitempty"` // LayoutFlat (пусто) | LayoutPreservePath
+	Tags       []string   `json:"tags,omitempty"`
+	Priority   int        `json:"priority,omitempty"`   // PriorityMin..PriorityMax, больше — раньше в очереди
+	Sink       string     `json:"sink,omitempty"`       // имя хранилища для выгрузки файлов; пусто — только локально
+	DependsOn  []string   `json:"depends_on,omitempty"` // ID задач, после завершения которых начнётся эта
+	Sequential bool       `json:"sequential,omitempty"` // качать файлы строго по порядку, по одному
+	Duplicates string     `json:"duplicates,omitempty"` // политика повторов URL (Duplicates*); пусто — DUPLICATE_POLICY
+	Login      *LoginSpec `json:"login,omitempty"`      // вход на сайт-источник перед скачиванием (сессия на всю задачу)
+	// NotifyEmail — адреса через запятую, куда отправить сводку, когда задача
+	// дойдёт до конечного статуса (нужен SMTP_URL); пусто — не отправлять.
+	NotifyEmail string `json:"notify_email,omitempty"`
+	// Notify — имена уведомителей (Slack, Telegram — секция notifiers
+	// конфигурации), куда отправить сводку по завершении задачи.
+	Notify []string `json:"notify,omitempty"`
+	// ExpiresAt — срок задачи (RFC 3339): файлы, не начатые к нему, переходят
+	// в EXPIRED и не качаются; nil — без срока.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// PartialRetry — автоповтор упавших файлов, если задача завершится
+	// PARTIAL (см. partialretry.go); nil — не повторять.
+	PartialRetry *PartialRetrySpec `json:"partial_retry,omitempty"`
+	Tenant       string            `json:"-"` // арендатор создателя (из аутентификации, не из тела запроса)
+	APIKey       string            `json:"-"` // отпечаток ключа API создателя (auth.KeyID), для учёта
+	RequestID    string            `json:"-"` // X-Request-ID запроса, создавшего задачу
+}
+
+// Validate проверяет параметры задачи, не относящиеся к отдельным ссылкам.
+func (s TaskSpec) Validate() error {
+	if err := validateLayout(s.Layout); err != nil {
+		return err
+	}
+	if err := validateLabel(s.Label); err != nil {
+		return err
+	}
+	if err := validateTags(s.Tags); err != nil {
+		return err
+	}
+	if err := validateDependsOn(s.DependsOn); err != nil {
+		return err
+	}
+	if err := ValidateDuplicates(s.Duplicates); err != nil {
+		return err
+	}
+	if err := validateNotifyEmail(s.NotifyEmail); err != nil {
+		return err
+	}
+	if err := validateNotify(s.Notify); err != nil {
+		return err
+	}
+	if err := validateExpiresAt(s.ExpiresAt); err != nil {
+		return err
+	}
+	if s.Login != nil {
+		if err := s.Login.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.PartialRetry != nil {
+		if err := s.PartialRetry.Validate(); err != nil {
+			return err
+		}
+	}
+	return validatePriority(s.Priority)
+}
+
+// validateDependsOn: не больше MaxDependsOn задач, ID непустые.
+func validateDependsOn(ids []string) error {
+	if len(ids) > MaxDependsOn {
+		return i18n.New(i18n.CodeDependsTooMany, MaxDependsOn, len(ids))
+	}
+	for _, id := range ids {
+		if strings.TrimSpace(id) == "" {
+			return i18n.New(i18n.CodeDependsEmpty)
+		}
+	}
+	return nil
+}
+
+// ValidateDuplicates: политика повторов — пусто или одна из Duplicates*.
+func ValidateDuplicates(policy string) error {
+	switch policy {
+	case "", DuplicatesAllow, DuplicatesDedupe, DuplicatesReject:
+		return nil
+	}
+	return i18n.New(i18n.CodeDuplicatesInvalid, DuplicatesAllow, DuplicatesDedupe, DuplicatesReject, policy)
+}
+
+func validateLayout(layout string) error {
+	switch layout {
+	case "", LayoutFlat, LayoutPreservePath:
+		return nil
+	}
+	return i18n.New(i18n.CodeLayoutInvalid, LayoutFlat, LayoutPreservePath, layout)
+}
+
+// FieldError — ошибка валидации одного поля TaskSpec (см. TaskSpec.FieldErrors).
+type FieldError struct {
+	Field string `json:"field"`           // "label", "tags", "links", "links[3]", …
+	Index *int   `json:"index,omitempty"` // номер ссылки для "links[i]"
+	Value string `json:"value,omitempty"` // URL ссылки
+	Code  string `json:"code,omitempty"`  // код ошибки (i18n.Code*)
+	Error string `json:"error"`
+
+	err error // исходная ошибка — для сообщения на другом языке (Localize)
+}
+
+// NewFieldError — ошибка поля field с кодом и сообщением (на русском) из err.
+func NewFieldError(field string, err error) FieldError {
+	return FieldError{Field: field, Code: i18n.Code(err), Error: err.Error(), err: err}
+}
+
+// Localize возвращает копию ошибки с сообщением на языке lang (i18n.Message).
+func (e FieldError) Localize(lang string) FieldError {
+	if e.err != nil {
+		e.Error = i18n.Message(e.err, lang)
+	}
+	return e
+}
+
+// FieldErrors проверяет все поля спецификации и возвращает все найденные
+// ошибки, а не только первую: параметры задачи (layout, label, tags, priority,
+// depends_on, duplicates, notify_email, notify, expires_at, partial_retry, login — с hostAllowed для его URL),
+// непустой список ссылок и каждую ссылку (LinkSpec.Validate плюс hostAllowed,
+// если задан, — для хоста URL и адреса connect_to). Ошибки ссылок идут в порядке их индексов. nil — всё корректно.
+func (s TaskSpec) FieldErrors(hostAllowed func(host string) bool) []FieldError {
+	var errs []FieldError
+	add := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, NewFieldError(field, err))
+		}
+	}
+	add("layout", validateLayout(s.Layout))
+	add("label", validateLabel(s.Label))
+	add("tags", validateTags(s.Tags))
+	add("priority", validatePriority(s.Priority))
+	add("depends_on", validateDependsOn(s.DependsOn))
+	add("duplicates", ValidateDuplicates(s.Duplicates))
+	add("notify_email", validateNotifyEmail(s.NotifyEmail))
+	add("notify", validateNotify(s.Notify))
+	add("expires_at", validateExpiresAt(s.ExpiresAt))
+	if s.PartialRetry != nil {
+		add("partial_retry", s.PartialRetry.Validate())
+	}
+	if s.Login != nil {
+		err := s.Login.Validate()
+		if err == nil && hostAllowed != nil && !hostAllowed(s.Login.Host()) {
+			err = i18n.New(i18n.CodeHostNotAllowed, s.Login.Host())
+		}
+		add("login", err)
+	}
+	if len(s.Links) == 0 {
+		add("links", i18n.New(i18n.CodeEmptyLinks))
+	}
+	for i, link := range s.Links {
+		err := link.Validate()
+		if err == nil && hostAllowed != nil {
+			if u, _ := url.Parse(link.URL); !hostAllowed(u.Host) {
+				err = i18n.New(i18n.CodeHostNotAllowed, u.Host)
+			} else if ip := ConnectHost(link.ConnectTo); ip != "" && !hostAllowed(ip) {
+				err = i18n.New(i18n.CodeHostNotAllowed, ip)
+			}
+		}
+		if err != nil {
+			idx := i
+			fe := NewFieldError(fmt.Sprintf("links[%d]", i), err)
+			fe.Index, fe.Value = &idx, link.URL
+			errs = append(errs, fe)
+		}
+	}
+	return errs
+}
+
+// NewTaskFromSpec конструирует задачу по TaskSpec: NewTaskFromSpecs плюс
+// параметры уровня задачи (раскладка, теги, приоритет, хранилище выгрузки,
+// зависимости, последовательный режим, вход на сайт-источник, адреса сводки,
+// уведомители, срок и автоповтор PARTIAL).
+//
+// При Layout = LayoutPreservePath файлам без явного dest_subpath назначается
+// подкаталог по URL (urlSubpath): одноимённые файлы с разных путей и хостов
+// не сваливаются в один каталог с суффиксами "-1", "-2".
+func NewTaskFromSpec(spec TaskSpec, maxAttempts int) (*Task, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	t, err := NewTaskFromSpecs(spec.Label, spec.DestDir, spec.Links, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	t.Tags = normalizeTags(spec.Tags)
+	t.Priority = spec.Priority
+	t.Sink = spec.Sink
+	t.DependsOn = normalizeTags(spec.DependsOn) // та же обрезка и удаление повторов
+	t.Sequential = spec.Sequential
+	t.Login = spec.Login
+	t.NotifyEmail = strings.TrimSpace(spec.NotifyEmail)
+	t.Notify = normalizeTags(spec.Notify)
+	if spec.ExpiresAt != nil {
+		at := spec.ExpiresAt.UTC()
+		t.ExpiresAt = &at
+	}
+	if spec.PartialRetry != nil {
+		t.PartialRetry = &PartialRetry{PartialRetrySpec: *spec.PartialRetry}
+	}
+	if spec.Layout == LayoutPreservePath {
+		t.Layout = spec.Layout
+		for _, f := range t.Files {
+			if f.DestSubpath == "" {
+				f.DestSubpath = urlSubpath(f.URL)
+			}
+		}
+	}
+	return t, nil
+}
+
+// urlSubpath строит относительный подкаталог из URL: хост и каталоги пути
+// ("https://cdn.example.com:8443/img/2025/a.jpg" → "cdn.example.com-8443/img/2025").
+// Каждый сегмент проходит sanitizeFilename, "." и ".." отбрасываются.
+func urlSubpath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	parts := []string{sanitizeFilename(strings.ToLower(u.Host))}
+	for _, seg := range strings.Split(path.Dir(path.Clean("/"+u.Path)), "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			continue
+		}
+		parts = append(parts, sanitizeFilename(seg))
+	}
+	return strings.Join(parts, "/")
+}
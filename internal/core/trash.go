@@ -0,0 +1,27 @@
+package core
+
+import "time"
+
+// Корзина (TRASH_RETENTION): DELETE не удаляет задачу сразу, а помечает её
+// TrashedAt — задача пропадает из списков, её файлы не качаются, скачанные
+// остаются на месте. POST /tasks/{id}/restore снимает пометку, а по
+// истечении срока задачу удаляет приложение (app/trash.go).
+
+// Trash помечает задачу как перенесённую в корзину в момент at и возвращает
+// идущие загрузки в Pending (прерывает их вызывающий); возвращает их число.
+// Статус не пересчитывается — это делает вызывающий (RecomputeStatusEvent).
+func (t *Task) Trash(at time.Time) int {
+	t.TrashedAt = &at
+	n := 0
+	for _, f := range t.Files {
+		if f.State == FileRunning && f.Transition(FilePending, "task moved to trash", at) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// PurgeAt — когда задачу в корзине пора удалить при сроке хранения retention.
+func (t *Task) PurgeAt(retention time.Duration) time.Time {
+	return t.TrashedAt.Add(retention)
+}
@@ -3,99 +3,251 @@ package core
 import (
 	"crypto/rand"
 	"encoding/hex"
-	"fmt"
 	"net/url"
 	"path"
+	"slices"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/i18n"
 )
 
 // TaskStatus — агрегированный статус задачи
 type TaskStatus string
 
 const (
-	TaskPending  TaskStatus = "PENDING"
-	TaskRunning  TaskStatus = "RUNNING"
-	TaskComplete TaskStatus = "COMPLETE"
-	TaskFailed   TaskStatus = "FAILED"
-	TaskPartial  TaskStatus = "PARTIAL"
+	TaskPending   TaskStatus = "PENDING"
+	TaskRunning   TaskStatus = "RUNNING"
+	TaskComplete  TaskStatus = "COMPLETE"
+	TaskFailed    TaskStatus = "FAILED"
+	TaskPartial   TaskStatus = "PARTIAL"
+	TaskCancelled TaskStatus = "CANCELLED" // работа остановлена отменой, часть файлов CANCELLED
+	TaskPaused    TaskStatus = "PAUSED"    // Task.Paused: новые файлы не запускаются
 )
 
+// Terminal сообщает, что статус конечный (задача больше не изменится сама).
+// PAUSED — не конечный: задачу можно возобновить.
+func (s TaskStatus) Terminal() bool {
+	return s == TaskComplete || s == TaskFailed || s == TaskPartial || s == TaskCancelled
+}
+
+// ParseTaskStatus разбирает статус задачи без учёта регистра ("failed" → TaskFailed).
+// Пустая строка — пустой статус без ошибки (фильтр не задан).
+func ParseTaskStatus(s string) (TaskStatus, error) {
+	st := TaskStatus(strings.ToUpper(strings.TrimSpace(s)))
+	switch st {
+	case "", TaskPending, TaskRunning, TaskPaused, TaskComplete, TaskFailed, TaskPartial, TaskCancelled:
+		return st, nil
+	}
+	return "", i18n.New(i18n.CodeStatusUnknown, s)
+}
+
 // FileState — статус конкретного файла
 type FileState string
 
 const (
-	FilePending FileState = "PENDING"
-	FileRunning FileState = "RUNNING"
-	FileDone    FileState = "DONE"
-	FileFailed  FileState = "FAILED"
+	FilePending   FileState = "PENDING"
+	FileRunning   FileState = "RUNNING"
+	FileDone      FileState = "DONE"
+	FileFailed    FileState = "FAILED"
+	FileCancelled FileState = "CANCELLED" // загрузка отменена, файл не будет скачан
+	FileSkipped   FileState = "SKIPPED"   // файл исключён из задачи (не считается ошибкой)
+	FileExpired   FileState = "EXPIRED"   // не начат до срока задачи (Task.ExpiresAt), не будет скачан
 )
 
+// Finished сообщает, что файл больше не будет обрабатываться без явного
+// действия (retry): DONE, FAILED, CANCELLED, SKIPPED или EXPIRED.
+func (s FileState) Finished() bool {
+	return s == FileDone || s == FileFailed || s == FileCancelled || s == FileSkipped || s == FileExpired
+}
+
+// Переходы между состояниями — только через FileItem.Transition (см. filestate.go).
+
 // FileItem — описание одного файла
 type FileItem struct {
-	URL             string     `json:"url"`
-	Filename        string     `json:"filename"`
-	State           FileState  `json:"state"`
-	Error           string     `json:"error,omitempty"`
-	Attempts        int        `json:"attempts"`
-	MaxAttempts     int        `json:"max_attempts"`
-	BytesDownloaded int64      `json:"bytes_downloaded"`
-	SizeHint        int64      `json:"size_hint,omitempty"`
-	StartedAt       *time.Time `json:"started_at,omitempty"`
-	FinishedAt      *time.Time `json:"finished_at,omitempty"`
-	Host            string     `json:"host"`
+	ID              string            `json:"id"` // стабильный в пределах задачи, см. EnsureFileIDs
+	URL             string            `json:"url"`
+	Filename        string            `json:"filename"`
+	DestSubpath     string            `json:"dest_subpath,omitempty"` // подкаталог внутри DestDir задачи
+	Checksum        string            `json:"checksum,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`         // доп. заголовки запроса
+	HostHeader      string            `json:"host_header,omitempty"`     // заголовок Host вместо хоста из URL
+	ConnectTo       string            `json:"connect_to,omitempty"`      // IP[:порт] для соединения вместо DNS
+	Signature       string            `json:"signature,omitempty"`       // ссылка на отделённую подпись OpenPGP
+	SignatureCheck  *SignatureCheck   `json:"signature_check,omitempty"` // результат проверки подписи последней попытки
+	Range           *ByteRange        `json:"range,omitempty"`           // качается только эта часть объекта
+	State           FileState         `json:"state"`
+	Error           string            `json:"error,omitempty"`
+	Attempts        int               `json:"attempts"`
+	MaxAttempts     int               `json:"max_attempts"`
+	BytesDownloaded int64             `json:"bytes_downloaded"`
+	SizeHint        int64             `json:"size_hint,omitempty"` // ожидаемый размер (Content-Length)
+	ProgressPercent float64           `json:"progress_percent"`
+	StartedAt       *time.Time        `json:"started_at,omitempty"`
+	FinishedAt      *time.Time        `json:"finished_at,omitempty"`
+	NextAttemptAt   *time.Time        `json:"next_attempt_at,omitempty"` // автоповтор отложен до этого момента
+	Host            string            `json:"host"`
+	Path            string            `json:"path,omitempty"`         // куда сохранён файл (после успешной загрузки)
+	Blob            string            `json:"blob,omitempty"`         // sha256 содержимого в хранилище BLOB_DIR (Path — ссылка на него)
+	ContentType     string            `json:"content_type,omitempty"` // тип содержимого по первым байтам (FIX_EXTENSIONS)
+	Upload          *FileUpload       `json:"upload,omitempty"`       // выгрузка в хранилище задачи (Task.Sink)
+	Aliases         []string          `json:"aliases,omitempty"`      // другие запрошенные имена того же URL (dest_subpath/filename), см. MergeDuplicates
+	DuplicateOf     string            `json:"duplicate_of,omitempty"` // "<taskID>/<fileID>" недавней задачи с тем же URL: файл пропущен (SKIPPED)
+	History         []FileEvent       `json:"history,omitempty"`      // последние переходы состояний
+	AttemptLog      []FileAttempt     `json:"attempt_log,omitempty"`  // последние HTTP-попытки загрузки (AddAttempt)
+	Response        *FileResponse     `json:"response,omitempty"`     // ответ источника на успешную загрузку
+}
+
+// Состояния выгрузки файла в хранилище (FileUpload.State).
+const (
+	UploadPending = "pending" // ждёт выгрузки (или повтора после ошибки)
+	UploadDone    = "done"
+	UploadFailed  = "failed" // попытки исчерпаны
+)
+
+// FileUpload — выгрузка скачанного файла во внешнее хранилище (sink).
+type FileUpload struct {
+	Sink         string     `json:"sink"`
+	State        string     `json:"state"` // UploadPending | UploadDone | UploadFailed
+	Attempts     int        `json:"attempts"`
+	Error        string     `json:"error,omitempty"`
+	Location     string     `json:"location,omitempty"` // адрес объекта в хранилище
+	UploadedAt   *time.Time `json:"uploaded_at,omitempty"`
+	LocalDeleted bool       `json:"local_deleted,omitempty"` // локальная копия удалена после выгрузки
+}
+
+// FileResponse — что ответил источник на успешную загрузку файла
+// (FileItem.Response): откуда в итоге взято содержимое и с какими метаданными.
+type FileResponse struct {
+	FinalURL     string     `json:"final_url"` // после редиректов
+	StatusCode   int        `json:"status_code"`
+	ContentType  string     `json:"content_type,omitempty"` // заголовок Content-Type (не путать с FileItem.ContentType)
+	ETag         string     `json:"etag,omitempty"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+	RemoteIP     string     `json:"remote_ip,omitempty"` // с прокси — адрес прокси
+}
+
+// SignatureCheck — результат проверки отделённой подписи файла (FileItem.Signature).
+type SignatureCheck struct {
+	Valid     bool      `json:"valid"`
+	KeyID     string    `json:"key_id,omitempty"` // ID ключа подписи (16 hex)
+	Signer    string    `json:"signer,omitempty"` // user ID ключа из связки, если ключ найден
+	Error     string    `json:"error,omitempty"`  // причина, если подпись не прошла
+	CheckedAt time.Time `json:"checked_at"`
 }
 
 // Task — бизнес-объект задачи
 type Task struct {
-	ID        string      `json:"id"`
-	Label     string      `json:"label,omitempty"`
-	CreatedAt time.Time   `json:"created_at"`
-	DestDir   string      `json:"dest_dir"`
-	Status    TaskStatus  `json:"status"`
-	Files     []*FileItem `json:"files"`
+	ID          string      `json:"id"`
+	Label       string      `json:"label,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	Priority    int         `json:"priority,omitempty"` // PriorityMin..PriorityMax, больше — раньше в очереди
+	CreatedAt   time.Time   `json:"created_at"`
+	DestDir     string      `json:"dest_dir"`
+	Layout      string      `json:"layout,omitempty"`       // LayoutPreservePath или пусто (flat)
+	Sink        string      `json:"sink,omitempty"`         // куда выгружать скачанные файлы (имя из конфигурации sinks)
+	ClonedFrom  string      `json:"cloned_from,omitempty"`  // ID задачи-источника (POST /tasks/{id}/clone)
+	DependsOn   []string    `json:"depends_on,omitempty"`   // ID задач, которые должны завершиться до запуска этой
+	Waiting     bool        `json:"waiting,omitempty"`      // ждёт зависимостей DependsOn: файлы не ставятся в очередь
+	Sequential  bool        `json:"sequential,omitempty"`   // файлы качаются строго по порядку списка, по одному (NextSequential)
+	Login       *LoginSpec  `json:"login,omitempty"`        // вход на сайт-источник перед загрузками (сессия на всю задачу)
+	NotifyEmail string      `json:"notify_email,omitempty"` // адреса сводки по завершении (TaskSpec.NotifyEmail)
+	Notify      []string    `json:"notify,omitempty"`       // уведомители (notifiers) для сводки по завершении (TaskSpec.Notify)
+	ExpiresAt   *time.Time  `json:"expires_at,omitempty"`   // срок: не начатые к нему файлы — EXPIRED (см. expiry.go)
+	Tenant      string      `json:"tenant,omitempty"`       // арендатор создателя (claim JWT_TENANT_CLAIM), для лимитов
+	APIKey      string      `json:"api_key,omitempty"`      // отпечаток ключа API создателя (auth.KeyID), для учёта потребления
+	RequestID   string      `json:"request_id,omitempty"`   // X-Request-ID запроса, создавшего задачу: корреляция логов и событий
+	Status      TaskStatus  `json:"status"`
+	Version     uint64      `json:"version"`              // растёт при каждом изменении, см. version.go
+	Paused      bool        `json:"paused,omitempty"`     // новые файлы не запускаются, статус PAUSED
+	TrashedAt   *time.Time  `json:"trashed_at,omitempty"` // в корзине (DELETE при TRASH_RETENTION): скрыта из списков, не качается
+	Files       []*FileItem `json:"files"`
 
-	Total   int `json:"total"`
-	Done    int `json:"done"`
-	Failed  int `json:"failed"`
-	Pending int `json:"pending"`
-	Running int `json:"running"`
-	Retries int `json:"retries_total"`
+	Notifications []*Notification `json:"notifications,omitempty"` // доставка сводок по завершении, по каналу — последняя
+	PartialRetry  *PartialRetry   `json:"partial_retry,omitempty"` // автоповтор после PARTIAL (см. partialretry.go)
+
+	Total     int `json:"total"`
+	Done      int `json:"done"`
+	Failed    int `json:"failed"`
+	Pending   int `json:"pending"`
+	Running   int `json:"running"`
+	Cancelled int `json:"cancelled,omitempty"`
+	Skipped   int `json:"skipped,omitempty"`
+	Expired   int `json:"expired,omitempty"`
+	Retries   int `json:"retries_total"`
+
+	TotalBytesExpected   int64   `json:"total_bytes_expected"`   // сумма известных SizeHint
+	TotalBytesDownloaded int64   `json:"total_bytes_downloaded"` // сумма BytesDownloaded
+	ProgressPercent      float64 `json:"progress_percent"`       // 0..100, см. recomputeProgress
+
+	prog    taskProgress   // суммы для инкрементального пересчёта процента
+	fileIdx map[string]int // FileItem.ID → индекс в Files, см. EnsureFileIDs
+	history eventLog       // события жизненного цикла, см. events.go
+	mu      *sync.Mutex    // блокировка задачи, см. lock.go
 }
 
 // NewTask конструирует новую задачу скачивания из списка ссылок.
+// Эквивалентен NewTaskFromSpecs со спецификациями, содержащими только URL.
+func NewTask(label string, destDir string, links []string, maxAttempts int) (*Task, error) {
+	specs := make([]LinkSpec, len(links))
+	for i, link := range links {
+		specs[i] = LinkSpec{URL: link}
+	}
+	return NewTaskFromSpecs(label, destDir, specs, maxAttempts)
+}
+
+// NewTaskFromSpecs конструирует новую задачу скачивания из описаний ссылок.
 //
 // Делает:
-//   - валидирует вход: links не пуст, каждая ссылка парсится и имеет схему/хост;
+//   - валидирует вход: specs не пуст, каждая ссылка проходит LinkSpec.Validate;
 //   - для каждой ссылки создаёт FileItem:
-//     – имя файла = path.Base(URL.Path), при пустом — "file";
+//     – ID — случайный, уникальный в пределах задачи;
+//     – имя файла = spec.Filename, при пустом — path.Base(URL.Path), при пустом — "file";
 //     – имя проходит sanitizeFilename;
 //     – начальное состояние FilePending;
 //     – Host берётся из URL.Host;
-//     – MaxAttempts устанавливается из аргумента;
+//     – DestSubpath (нормализованный), Checksum, Headers, HostHeader,
+//     ConnectTo, Signature и Range переносятся из spec;
+//     – MaxAttempts = spec.MaxAttempts, если задан, иначе из аргумента;
 //   - генерирует ID, заполняет Label, DestDir, CreatedAt (UTC),
 //     ставит начальный статус TaskPending и вызывает RecomputeStatus.
 //
 // Возвращает *Task или ошибку при пустом списке/некорректной ссылке.
-func NewTask(label string, destDir string, links []string, maxAttempts int) (*Task, error) {
-	if len(links) == 0 {
-		return nil, fmt.Errorf("пустой список ссылок")
-	}
-	files := make([]*FileItem, 0, len(links))
-	for _, link := range links {
-		u, err := url.Parse(link)
-		if err != nil || u.Scheme == "" || u.Host == "" {
-			return nil, fmt.Errorf("некорректная ссылка: %q", link)
+func NewTaskFromSpecs(label string, destDir string, specs []LinkSpec, maxAttempts int) (*Task, error) {
+	if len(specs) == 0 {
+		return nil, i18n.New(i18n.CodeEmptyLinks)
+	}
+	files := make([]*FileItem, 0, len(specs))
+	for _, spec := range specs {
+		if err := spec.Validate(); err != nil {
+			return nil, err
+		}
+		u, _ := url.Parse(spec.URL)
+		base := spec.Filename
+		if base == "" {
+			base = path.Base(u.Path)
 		}
-		base := path.Base(u.Path)
 		if base == "." || base == "/" || base == "" {
 			base = "file"
 		}
+		attempts := maxAttempts
+		if spec.MaxAttempts > 0 {
+			attempts = spec.MaxAttempts
+		}
+		sub, _ := cleanSubpath(spec.DestSubpath)
 		files = append(files, &FileItem{
-			URL:         link,
+			URL:         spec.URL,
 			Filename:    sanitizeFilename(base),
+			DestSubpath: sub,
+			Checksum:    spec.Checksum,
+			Headers:     spec.Headers,
+			HostHeader:  spec.HostHeader,
+			ConnectTo:   spec.ConnectTo,
+			Signature:   spec.Signature,
+			Range:       spec.Range,
 			State:       FilePending,
-			MaxAttempts: maxAttempts,
+			MaxAttempts: attempts,
 			Host:        u.Host,
 		})
 	}
@@ -108,6 +260,8 @@ func NewTask(label string, destDir string, links []string, maxAttempts int) (*Ta
 		Status:    TaskPending,
 		Files:     files,
 	}
+	t.assignNewFileIDs()
+	t.EnsureFileIDs()
 	t.RecomputeStatus()
 	return t, nil
 }
@@ -116,14 +270,14 @@ func NewTask(label string, destDir string, links []string, maxAttempts int) (*Ta
 //
 // Делает:
 //   - отбрасывает всё после '?' (query из URL);
-//   - если имя пустое, "." или "/" — возвращает "file";
+//   - если имя пустое, ".", ".." или "/" — возвращает "file";
 //   - заменяет/удаляет опасные символы:
 //     ':' '/' '\' → '-' ;  '*' '?' '"' '<' '>' '|' '\n' '\r' → удаляются.
 func sanitizeFilename(s string) string {
 	if i := strings.IndexByte(s, '?'); i >= 0 {
 		s = s[:i]
 	}
-	if s == "" || s == "." || s == "/" {
+	if s == "" || s == "." || s == ".." || s == "/" {
 		return "file"
 	}
 	r := strings.NewReplacer(
@@ -143,16 +297,24 @@ func sanitizeFilename(s string) string {
 }
 
 // RecomputeStatus пересчитывает агрегаты задачи по её файлам:
-// Total/Done/Failed/Pending/Running/Retries.
-// По результатам устанавливает итоговый статус:
-//   - TaskComplete — все файлы Done;
-//   - TaskFailed   — все файлы Failed;
-//   - TaskRunning  — есть хотя бы один Running;
-//   - TaskPartial  — есть Done и Failed, и при этом нет Pending/Running;
-//   - иначе TaskPending.
+// Total/Done/Failed/Pending/Running/Cancelled/Skipped/Expired/Retries, байтовые
+// итоги и проценты (recomputeProgress), увеличивает Version. По результатам
+// устанавливает итоговый статус:
+//   - TaskPaused    — задача на паузе (Paused) и есть Pending/Running файлы;
+//   - TaskRunning   — есть хотя бы один Running;
+//   - TaskPending   — есть Pending (или в задаче нет файлов);
+//
+// иначе (все файлы завершены, FileState.Finished):
+//   - TaskCancelled — есть хотя бы один Cancelled;
+//   - TaskComplete  — нет Failed и Expired (Skipped считаются обработанными);
+//   - TaskFailed    — нет ни одного Done;
+//   - TaskPartial   — есть и Done, и Failed или Expired.
+//
+// Skipped не считаются ни скачанными, ни упавшими: задача из Done и Skipped —
+// COMPLETE, из Failed и Skipped — FAILED, только из Skipped — COMPLETE.
 func (t *Task) RecomputeStatus() {
 	total := len(t.Files)
-	var done, failed, pending, running, retries int
+	var done, failed, pending, running, cancelled, skipped, expired, retries int
 	for _, f := range t.Files {
 		switch f.State {
 		case FileDone:
@@ -163,6 +325,12 @@ func (t *Task) RecomputeStatus() {
 			pending++
 		case FileRunning:
 			running++
+		case FileCancelled:
+			cancelled++
+		case FileSkipped:
+			skipped++
+		case FileExpired:
+			expired++
 		}
 		retries += f.Attempts
 	}
@@ -171,20 +339,132 @@ func (t *Task) RecomputeStatus() {
 	t.Failed = failed
 	t.Pending = pending
 	t.Running = running
+	t.Cancelled = cancelled
+	t.Skipped = skipped
+	t.Expired = expired
 	t.Retries = retries
 
 	switch {
-	case total > 0 && done == total:
-		t.Status = TaskComplete
-	case total > 0 && failed == total:
-		t.Status = TaskFailed
+	case total == 0:
+		t.Status = TaskPending
+	case t.Paused && pending+running > 0:
+		t.Status = TaskPaused
 	case running > 0:
 		t.Status = TaskRunning
-	case done > 0 && failed > 0 && pending == 0 && running == 0:
-		t.Status = TaskPartial
-	default:
+	case pending > 0:
 		t.Status = TaskPending
+	case cancelled > 0:
+		t.Status = TaskCancelled
+	case failed+expired == 0:
+		t.Status = TaskComplete
+	case done == 0:
+		t.Status = TaskFailed
+	default:
+		t.Status = TaskPartial
+	}
+	t.recomputeProgress()
+	t.touch()
+}
+
+// ResetInterrupted возвращает в Pending файлы, загрузка которых была прервана
+// (состояние Running на момент остановки) через Transition — с записью в историю.
+// Статус задачи пересчитывается. Возвращает число сброшенных файлов.
+func (t *Task) ResetInterrupted() int {
+	n := 0
+	now := time.Now().UTC()
+	for _, f := range t.Files {
+		if f.State == FileRunning {
+			_ = f.Transition(FilePending, "interrupted by restart", now)
+			n++
+		}
+	}
+	t.RecomputeStatus()
+	return n
+}
+
+// MergeDuplicates сливает файлы с одинаковым URL и диапазоном (SourceKey) в
+// первый из них (политика DuplicatesDedupe): остальные удаляются из задачи,
+// а их пути в каталоге задачи (dest_subpath/filename), если отличаются от
+// пути первого и друг от друга, добавляются в его Aliases — после загрузки
+// файл появится и под этими именами. Возвращает число удалённых файлов.
+func (t *Task) MergeDuplicates() int {
+	first := make(map[string]*FileItem, len(t.Files))
+	kept := t.Files[:0]
+	for _, f := range t.Files {
+		orig, ok := first[f.SourceKey()]
+		if !ok {
+			first[f.SourceKey()] = f
+			kept = append(kept, f)
+			continue
+		}
+		if alias := f.RelPath(); alias != orig.RelPath() && !slices.Contains(orig.Aliases, alias) {
+			orig.Aliases = append(orig.Aliases, alias)
+		}
+	}
+	n := len(t.Files) - len(kept)
+	clear(t.Files[len(kept):])
+	t.Files = kept
+	if n > 0 {
+		t.RecomputeStatus()
+	}
+	return n
+}
+
+// RelPath — путь файла в каталоге задачи: dest_subpath/filename.
+func (f *FileItem) RelPath() string {
+	return path.Join(f.DestSubpath, f.Filename)
+}
+
+// NextSequential — файл последовательной задачи (Sequential), чья очередь
+// подошла: первый по списку Pending-файл. nil — какой-то файл ещё качается
+// (Running) или Pending-файлов не осталось. Упавший или отменённый файл
+// очередь не держит: за ним идёт следующий.
+func (t *Task) NextSequential() *FileItem {
+	var next *FileItem
+	for _, f := range t.Files {
+		switch f.State {
+		case FileRunning:
+			return nil
+		case FilePending:
+			if next == nil {
+				next = f
+			}
+		}
+	}
+	return next
+}
+
+// Clone возвращает глубокую копию задачи (файлы копируются FileItem.Clone),
+// которую можно сериализовать и читать без блокировок.
+func (t *Task) Clone() *Task {
+	c := *t
+	c.history = eventLog{} // история отдаётся отдельно (Task.History)
+	c.mu = nil             // снимок не разделяет блокировку с оригиналом
+	c.Tags = append([]string(nil), t.Tags...)
+	c.DependsOn = append([]string(nil), t.DependsOn...)
+	c.Notify = append([]string(nil), t.Notify...)
+	if t.ExpiresAt != nil {
+		ts := *t.ExpiresAt
+		c.ExpiresAt = &ts
+	}
+	if t.PartialRetry != nil {
+		pr := *t.PartialRetry
+		c.PartialRetry = &pr
+	}
+	if t.TrashedAt != nil {
+		ts := *t.TrashedAt
+		c.TrashedAt = &ts
+	}
+	c.Notifications = nil
+	for _, n := range t.Notifications {
+		nc := *n
+		c.Notifications = append(c.Notifications, &nc)
+	}
+	c.Files = make([]*FileItem, len(t.Files))
+	for i, f := range t.Files {
+		c.Files[i] = f.Clone()
 	}
+	return &c
 }
 
 // NewID генерирует человекочитаемый идентификатор вида
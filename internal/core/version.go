@@ -0,0 +1,20 @@
+package core
+
+// touch увеличивает Task.Version. Вызывается каждым методом, меняющим видимое
+// состояние задачи: RecomputeStatus, AddFileProgress, SetFileSize, Apply.
+// Поэтому любой код, меняющий файлы напрямую, обязан завершать изменение
+// RecomputeStatus — иначе ожидающие (long-poll, ETag) не увидят изменения.
+func (t *Task) touch() { t.Version++ }
+
+// SeedVersion поднимает Version до floor, если она меньше.
+//
+// Изменения прогресса не попадают в WAL, поэтому после перезапуска сохранённая
+// версия может оказаться меньше уже выданной клиентам. При восстановлении
+// версия поднимается до «часов» (микросекунды Unix-времени старта): за время
+// работы процесса версия задачи растёт заведомо медленнее, так что новая
+// версия всегда больше любой прежней и счётчик остаётся монотонным.
+func (t *Task) SeedVersion(floor uint64) {
+	if t.Version < floor {
+		t.Version = floor
+	}
+}
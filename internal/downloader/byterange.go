@@ -0,0 +1,72 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// ErrRangeMismatch — сервер не отдал запрошенную часть объекта
+// (Request.Range): проигнорировал Range, вернул другой диапазон или
+// прислал не столько байт, сколько обещал в Content-Range.
+var ErrRangeMismatch = errors.New("range mismatch")
+
+// checkRange сверяет ответ на запрос части объекта r с самим запросом и
+// возвращает, сколько байт должно прийти в теле.
+//
+// Ответ должен быть 206 с Content-Range "bytes start-end/total" (total может
+// быть "*"), где start = r.Offset, а end — конец диапазона r; диапазон «до
+// конца объекта» сервер закрывает сам (end = total-1). Диапазон с длиной,
+// выходящий за конец объекта, сервер обрезает — это тоже ошибка: нужных
+// байт на сервере нет. 200 (Range не поддерживается) — ошибка: качать
+// объект целиком ради части мы не будем.
+func checkRange(resp *http.Response, r core.ByteRange) (int64, error) {
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("%w: requested bytes %s, got http %d", ErrRangeMismatch, r, resp.StatusCode)
+	}
+	cr := resp.Header.Get("Content-Range")
+	start, end, total, ok := parseContentRange(cr)
+	if !ok {
+		return 0, fmt.Errorf("%w: requested bytes %s, got Content-Range %q", ErrRangeMismatch, r, cr)
+	}
+	if start != r.Offset || (r.Length > 0 && end != r.End()) || (r.Length == 0 && total >= 0 && end != total-1) {
+		return 0, fmt.Errorf("%w: requested bytes %s, got %d-%d", ErrRangeMismatch, r, start, end)
+	}
+	return end - start + 1, nil
+}
+
+// parseContentRange разбирает "bytes start-end/total" ("bytes start-end/*" —
+// total = -1).
+func parseContentRange(s string) (start, end, total int64, ok bool) {
+	spec, found := strings.CutPrefix(s, "bytes ")
+	if !found {
+		return 0, 0, 0, false
+	}
+	rng, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+	first, last, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+	var err1, err2 error
+	start, err1 = strconv.ParseInt(first, 10, 64)
+	end, err2 = strconv.ParseInt(last, 10, 64)
+	if err1 != nil || err2 != nil || start < 0 || end < start {
+		return 0, 0, 0, false
+	}
+	total = -1
+	if size != "*" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || n <= end {
+			return 0, 0, 0, false
+		}
+		total = n
+	}
+	return start, end, total, true
+}
@@ -0,0 +1,89 @@
+package downloader
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// pinnedClients — HTTP-клиенты для ссылок с явным адресом соединения
+// (Request.ConnectTo), по ключу "хост:порт из URL|адрес", и для прокси
+// профилей хостов (HostProfile.Proxy), по ключу "proxy|адрес прокси". У
+// каждого свой транспорт и пул соединений: соединение с конкретным сервером
+// не должно достаться обычному запросу к тому же хосту.
+type pinnedClients struct {
+	mu sync.Mutex
+	m  map[string]*http.Client
+}
+
+// clientFor возвращает клиента для запроса к target (хост:порт из URL, см. dialTarget): общий d.httpClient,
+// клиента, который соединяется с connectTo вместо адреса из DNS (если он
+// задан), или клиента, который ходит через прокси proxy.
+//
+// Подменяется только соединение с хостом и портом из URL: после редиректа
+// на другой хост запрос идёт по DNS как обычно. Прокси не используется
+// (иначе адрес соединения ничего не значил бы); TLS (SNI и проверка
+// сертификата) — по хосту из URL, так что edge CDN должен предъявить
+// сертификат этого хоста. connectTo без порта берёт порт из URL.
+func (d *Downloader) clientFor(target, connectTo string, proxy *url.URL) *http.Client {
+	if connectTo == "" && proxy == nil {
+		return d.httpClient
+	}
+	key := target + "|" + connectTo
+	if connectTo == "" {
+		key = "proxy|" + proxy.String()
+	}
+	d.pinned.mu.Lock()
+	defer d.pinned.mu.Unlock()
+	if c, ok := d.pinned.m[key]; ok {
+		return c
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if connectTo == "" {
+		tr.Proxy = http.ProxyURL(proxy)
+	} else {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		tr.Proxy = nil
+		tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == target {
+				addr = pinnedAddr(connectTo, addr)
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+	c := &http.Client{Timeout: d.opts.ClientTimeout, Transport: tr, CheckRedirect: d.checkRedirect}
+	if d.pinned.m == nil {
+		d.pinned.m = make(map[string]*http.Client)
+	}
+	d.pinned.m[key] = c
+	return c
+}
+
+// pinnedAddr — адрес соединения connectTo с портом из addr, если свой не задан.
+func pinnedAddr(connectTo, addr string) string {
+	if _, _, err := net.SplitHostPort(connectTo); err == nil {
+		return connectTo
+	}
+	_, port, _ := net.SplitHostPort(addr)
+	ip := connectTo
+	if len(ip) > 1 && ip[0] == '[' {
+		ip = ip[1 : len(ip)-1]
+	}
+	return net.JoinHostPort(ip, port)
+}
+
+// dialTarget — хост:порт, с которым соединяется транспорт для u (порт по
+// умолчанию — по схеме).
+func dialTarget(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -1,16 +1,23 @@
 package downloader
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
-	"os"
-	"path/filepath"
-	"strconv"
+	"sync"
 	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+	"github.com/Extrarius/29.09.2025/internal/storage"
 )
 
 // Скачивание файла по URL с ретраями и атомарным rename.
@@ -18,27 +25,110 @@ type Options struct {
 	ClientTimeout   time.Duration
 	Retries         int
 	HostConcurrency int
+	// HostLimits перекрывает HostConcurrency для отдельных хостов (шаблон
+	// хоста → слотов; "*.example.com" — поддомены, см. core.LookupHost).
+	HostLimits map[string]int
+	// HostProfiles — заголовки, ограничение скорости и прокси по хостам
+	// (шаблон хоста → HostProfile).
+	HostProfiles map[string]HostProfile
+	// Storage — куда пишутся файлы; nil — storage.Local (DestPath — путь на диске).
+	Storage storage.Storage
+	// Bandwidth — общее ограничение скорости всех загрузок, байт/с; 0 — без
+	// ограничения. Меняется на ходу через SetBandwidth.
+	Bandwidth int64
+	// WriteRate — общее ограничение скорости записи загрузок в хранилище,
+	// байт/с (limitedWriter); 0 — без ограничения. В отличие от Bandwidth
+	// сглаживает запись на диск: всплески не выходят за rateBurst.
+	WriteRate int64
+	// BufferSize — блок копирования тела ответа в файл, байт; 0 —
+	// DefaultBufferSize. Больше блок — меньше системных вызовов на больших файлах.
+	BufferSize int
+	// Preallocate — резервировать место под файл по Content-Length до начала
+	// записи (storage.Preallocator).
+	Preallocate bool
+	// BypassCache — не оставлять записанное в кеше страниц
+	// (storage.CacheBypasser): для массовых разовых загрузок.
+	BypassCache bool
+	// MaxInflightBytes — общий предел памяти под буферы идущих загрузок
+	// (см. memBudget); 0 — без ограничения.
+	MaxInflightBytes int64
+	// QuarantineDir — каталог карантина недокачанных файлов (quarantine.go);
+	// пусто — недокачанное удаляется.
+	QuarantineDir string
+	// PartDir — каталог временных файлов .part загрузок на диске (partPath);
+	// пусто — рядом с файлом назначения. Request.PartDir его перекрывает.
+	PartDir string
+	// FinalizeSync — что сбрасывать на диск перед тем, как считать файл
+	// сохранённым: SyncNone (пусто), SyncFile или SyncFull.
+	FinalizeSync string
+	// Keyring — открытые ключи для проверки подписей (Request.Signature,
+	// см. LoadKeyring); пусто — ссылки с подписью не качаются.
+	Keyring openpgp.EntityList
+	// AllowHost (если задан) — разрешён ли хост (с портом, как в URL):
+	// проверяются ссылка, каждый редирект (checkRedirect) и итоговый URL
+	// ответа; запрещённый — немедленная ErrHostNotAllowed. nil — любые хосты.
+	AllowHost func(host string) bool
 }
 
+// Значения Options.FinalizeSync: чем надёжнее, тем медленнее — fsync ждёт,
+// пока данные дойдут до диска.
+const (
+	SyncNone = "none" // ничего: файл в кеше страниц, после сбоя питания может оказаться пустым или обрезанным
+	SyncFile = "file" // fsync файла перед rename: под своим именем файл всегда целиком
+	SyncFull = "full" // SyncFile и fsync каталога после rename: переживает сбой и само имя
+)
+
+// DefaultBufferSize — блок копирования по умолчанию (Options.BufferSize),
+// как у io.Copy.
+const DefaultBufferSize = 32 << 10
+
 type Downloader struct {
 	httpClient *http.Client
 	opts       Options
-	hostSem    map[string]chan struct{}
+	slots      *hostSlots    // семафоры параллелизма по хостам (acquireHost)
+	bufs       sync.Pool     // буферы копирования *[]byte размера Options.BufferSize
+	mem        *memBudget    // предел памяти под буферы загрузок (Options.MaxInflightBytes)
+	pinned     pinnedClients // клиенты для Request.ConnectTo и прокси профилей, см. clientFor
+	stats      *hostStatsRegistry
+	limit      rateLimiter // общее ограничение скорости (Options.Bandwidth, SetBandwidth)
+	wlimit     rateLimiter // ограничение скорости записи (Options.WriteRate)
+	rates      hostRates   // ограничения скорости профилей хостов (HostProfile.Bandwidth)
 }
 
 // NewDownloader создаёт загрузчик с переданными опциями.
 //
 // Инициализирует:
-//   - httpClient с таймаутом opts.ClientTimeout;
-//   - карту семафоров hostSem для ограничения параллелизма по хостам
+//   - httpClient с таймаутом opts.ClientTimeout и проверкой редиректов по
+//     opts.AllowHost (checkRedirect);
+//   - реестр семафоров hostSlots для ограничения параллелизма по хостам
 //     (используется вместе с opts.HostConcurrency);
-//   - сохраняет opts (включая Retries и др.).
+//   - реестр пер-хостовой статистики (HostStats);
+//   - общее ограничение скорости opts.Bandwidth (см. SetBandwidth) и
+//     скорости записи opts.WriteRate;
+//   - пул буферов копирования размера opts.BufferSize (их делят все загрузки)
+//     и общий предел памяти под буферы opts.MaxInflightBytes;
+//   - сохраняет opts (включая Retries и др.; без Storage — storage.Local).
 func NewDownloader(opts Options) *Downloader {
-	return &Downloader{
-		httpClient: &http.Client{Timeout: opts.ClientTimeout},
-		opts:       opts,
-		hostSem:    make(map[string]chan struct{}),
+	if opts.Storage == nil {
+		opts.Storage = storage.Local
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
 	}
+	d := &Downloader{
+		opts:  opts,
+		slots: newHostSlots(),
+		mem:   newMemBudget(opts.MaxInflightBytes),
+		stats: newHostStatsRegistry(),
+	}
+	d.httpClient = &http.Client{Timeout: opts.ClientTimeout, CheckRedirect: d.checkRedirect}
+	d.limit.set(opts.Bandwidth)
+	d.wlimit.set(opts.WriteRate)
+	d.bufs.New = func() any {
+		b := make([]byte, opts.BufferSize)
+		return &b
+	}
+	return d
 }
 
 // acquireHost захватывает слот параллелизма для указанного хоста
@@ -46,70 +136,243 @@ func NewDownloader(opts Options) *Downloader {
 // для освобождения слота.
 //
 // Логика:
-//   - если HostConcurrency <= 0 — ограничение отключено (возвращается no-op release);
-//   - для каждого host лениво создаётся буферизированный канал-семафор
-//     ёмкостью HostConcurrency;
+//   - лимит хоста берётся из HostLimits (точное имя или шаблон, см.
+//     core.LookupHost), иначе — HostConcurrency;
+//   - если лимит <= 0 — ограничение отключено (возвращается no-op release);
+//   - семафор хоста (буферизированный канал ёмкостью лимита) берётся из
+//     реестра d.slots, который создаёт его при первом обращении и вычищает
+//     простаивающие (см. hostSlots);
 //   - запись в канал блокирует при исчерпании слотов, тем самым ограничивая
 //     одновременные загрузки с этого хоста;
 //   - release() читает из канала, освобождая слот.
-//
-// ВАЖНО: d.hostSem — обычная map; её заполнение при конкурентном доступе
-// нужно синхронизировать (мьютексом или sync.Map), иначе возможны гонки / panic
-// «concurrent map writes».
 func (d *Downloader) acquireHost(host string) func() {
-	if d.opts.HostConcurrency <= 0 {
-		return func() {}
+	limit := d.opts.HostConcurrency
+	if l, ok := core.LookupHost(d.opts.HostLimits, host); ok {
+		limit = l
 	}
-	sem, ok := d.hostSem[host]
-	if !ok {
-		sem = make(chan struct{}, d.opts.HostConcurrency)
-		d.hostSem[host] = sem
+	if limit <= 0 {
+		return func() {}
 	}
-	sem <- struct{}{}
-	return func() { <-sem }
+	return d.slots.acquire(host, limit)
+}
+
+// Request — параметры одной загрузки для Do.
+type Request struct {
+	URL      string
+	DestPath string
+	// Checksum (если задан) — ожидаемая контрольная сумма в формате core.ParseChecksum;
+	// при несовпадении файл не сохраняется, а Do возвращает ErrChecksumMismatch.
+	Checksum string
+	// Headers — дополнительные заголовки HTTP-запроса (авторизация, cookie и т.п.).
+	Headers map[string]string
+	// Host (если задан) — заголовок Host вместо хоста из URL (виртуальный хост).
+	Host string
+	// ConnectTo (если задан) — IP[:порт], с которым соединяться вместо адреса
+	// из DNS (см. clientFor).
+	ConnectTo string
+	// Jar (если задан) — сессия входа на сайт-источник (см. Login): её cookie
+	// отправляются с запросом, а новые cookie из ответов сохраняются в неё.
+	Jar http.CookieJar
+	// PartDir (если задан) — каталог временного .part вместо Options.PartDir.
+	PartDir string
+	// Signature (если задан) — ссылка на отделённую подпись OpenPGP файла
+	// (signature.go): при неверной подписи файл не сохраняется, а Do
+	// возвращает ErrSignatureMismatch.
+	Signature string
+	// Range (если задан) — качать только эту часть объекта: запрос с
+	// заголовком Range, ответ сверяется с ним (checkRange), а число
+	// записанных байт — с длиной из Content-Range. Checksum и Signature
+	// проверяются по этой части.
+	Range *core.ByteRange
+	// OnSignature (если задан) вызывается с результатом проверки подписи.
+	OnSignature func(core.SignatureCheck)
+	// OnContentType (если задан) вызывается перед сохранением файла с типом
+	// содержимого, определённым по первым байтам (http.DetectContentType;
+	// не вызывается для пустого файла). Возвращает путь, под которым
+	// сохранить файл вместо DestPath (пусто — DestPath).
+	OnContentType func(contentType string) string
+	// OnProgress (если задан) вызывается после каждой записи в файл
+	// с числом байт, записанных в текущей попытке (при ретрае отсчёт начинается с нуля).
+	OnProgress func(written int64)
+	// OnSize (если задан) вызывается в начале каждой попытки с размером
+	// из Content-Length, если сервер его сообщил.
+	OnSize func(size int64)
+	// OnAttempt (если задан) вызывается по завершении каждой HTTP-попытки
+	// (в том числе повтора внутри Do) с её итогом: время, записанные байты,
+	// код ответа, ошибка и IP, с которым было соединение.
+	OnAttempt func(core.FileAttempt)
+	// OnResponse (если задан) вызывается один раз при успешной загрузке с
+	// метаданными ответа, из которого сохранён файл (responseInfo).
+	OnResponse func(core.FileResponse)
+}
+
+// progressWriter — io.Writer-обёртка, сообщающая накопленный объём записанного.
+type progressWriter struct {
+	w       io.Writer
+	written int64
+	fn      func(int64)
 }
 
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.fn(p.written)
+	return n, err
+}
+
+// ErrChecksumMismatch — скачанные данные не совпали с ожидаемой контрольной суммой.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
 // Fetch скачивает ресурс по rawURL в файл destPath.
+// Эквивалентен Do(ctx, Request{URL: rawURL, DestPath: destPath}).
+func (d *Downloader) Fetch(ctx context.Context, rawURL, destPath string) (int64, error) {
+	return d.Do(ctx, Request{URL: rawURL, DestPath: destPath})
+}
+
+// Do скачивает ресурс req.URL в файл req.DestPath хранилища Options.Storage.
 //
 // Поведение:
 //   - ограничивает параллелизм по хосту (acquireHost/release);
+//   - ведёт пер-хостовую статистику (занятые слоты, успехи/ошибки, скорость);
+//   - читает ответ не быстрее общего ограничения скорости (Options.Bandwidth)
+//     и ограничения хоста из его профиля (HostProfile.Bandwidth), пишет в
+//     хранилище не быстрее Options.WriteRate;
+//   - перед каждой попыткой занимает долю общего предела памяти под буферы
+//     (Options.MaxInflightBytes) и ждёт её, если предел исчерпан;
+//   - подставляет req.Host в заголовок Host и соединяется с req.ConnectTo
+//     вместо адреса из DNS (clientFor), отправляет cookie сессии req.Jar,
+//     заголовки и прокси профиля хоста (HostProfile);
+//   - с req.Range запрашивает только часть объекта и требует в ответ 206 с
+//     тем же диапазоном (checkRange);
 //   - делает до max(1, d.opts.Retries) попыток с экспоненциальным backoff;
-//   - пишет во временный файл destPath+".part" и по успеху атомарно переименовывает;
-//   - создаёт директорию назначения при необходимости;
+//     итог каждой (время, байты, код ответа, ошибка, IP) сообщает req.OnAttempt;
+//   - пишет потоком во временный файл destPath+".part" или в Options.PartDir
+//     (partPath; Storage.Create) блоками Options.BufferSize из общего пула
+//     (с Options.Preallocate/BypassCache/FinalizeSync — см. tune) и по успеху
+//     переименовывает его (Storage.Rename; между файловыми системами —
+//     копированием) — в DestPath или в путь, который вернул req.OnContentType
+//     по типу содержимого (sniff.go); каталог назначения на диске создаётся
+//     при необходимости;
 //   - прерывается по ctx (таймаут/отмена).
 //
 // Возвращает количество записанных байт или ошибку.
-// Примечания: 5xx ⇒ ретрай; 4xx ⇒ немедленная ошибка; временные файлы удаляются на ошибках.
-func (d *Downloader) Fetch(ctx context.Context, rawURL, destPath string) (int64, error) {
-	u, err := url.Parse(rawURL)
+// Примечания: 5xx ⇒ ретрай; 4xx ⇒ немедленная ошибка; несовпадение Checksum ⇒
+// немедленная ErrChecksumMismatch, неверная подпись req.Signature — ErrSignatureMismatch,
+// ответ не на запрошенный диапазон req.Range — немедленная ErrRangeMismatch,
+// ссылка или редирект на хост вне Options.AllowHost — немедленная ErrHostNotAllowed
+// (меньше байт, чем в Content-Range, — ретрай); временные файлы удаляются на ошибках (с
+// Options.QuarantineDir оборванные и не сошедшиеся с суммой — переносятся в
+// карантин, см. quarantine.go).
+func (d *Downloader) Do(ctx context.Context, req Request) (int64, error) {
+	u, err := url.Parse(req.URL)
 	if err != nil {
 		return 0, err
 	}
+	d.stats.waiting(u.Host)
 	release := d.acquireHost(u.Host)
-	defer release()
+	d.stats.acquired(u.Host)
+	defer func() {
+		release()
+		d.stats.released(u.Host)
+	}()
+
+	started := time.Now()
+	written, err := d.do(ctx, req)
+	d.stats.finished(u.Host, written, time.Since(started), err)
+	return written, err
+}
+
+// do — тело Do без учёта слотов и статистики: цикл попыток скачивания.
+func (d *Downloader) do(ctx context.Context, req Request) (n int64, err error) {
+	rawURL, destPath := req.URL, req.DestPath
+	st := d.opts.Storage
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.checkHost(u); err != nil {
+		return 0, err
+	}
+	prof := d.profile(u.Host)
+	client := withJar(d.clientFor(dialTarget(u), req.ConnectTo, prof.Proxy), req.Jar)
+
+	var (
+		algo string
+		want []byte
+	)
+	if req.Checksum != "" {
+		if algo, want, err = core.ParseChecksum(req.Checksum); err != nil {
+			return 0, err
+		}
+	}
+	var sig *detachedSignature
+	if req.Signature != "" {
+		if sig, err = d.fetchSignature(ctx, req); err != nil {
+			return 0, err
+		}
+	}
 
 	var lastErr error
 	backoff := 500 * time.Millisecond
 
+	// att — текущая попытка для req.OnAttempt: о ней сообщает fail (попытка
+	// упала и будет повторена) или, при выходе из do, defer ниже.
+	var (
+		att *core.FileAttempt
+		ip  *remoteIP
+	)
+	fail := func(err error) error {
+		if att != nil && req.OnAttempt != nil {
+			att.FinishedAt = time.Now().UTC()
+			att.RemoteIP = ip.get()
+			if err != nil {
+				att.Error = err.Error()
+			}
+			req.OnAttempt(*att)
+		}
+		att = nil
+		return err
+	}
+	defer func() { fail(err) }()
+
 	for attempt := 0; attempt < max(1, d.opts.Retries); attempt++ {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		att, ip = &core.FileAttempt{StartedAt: time.Now().UTC()}, &remoteIP{}
+		httpReq, err := http.NewRequestWithContext(ip.trace(ctx), http.MethodGet, rawURL, nil)
 		if err != nil {
 			return 0, err
 		}
+		prof.setHeaders(httpReq.Header, req.Headers)
+		if req.Host != "" {
+			httpReq.Host = req.Host
+		}
+		if req.Range != nil {
+			httpReq.Header.Set("Range", req.Range.Header())
+		}
 
-		tmpPath := destPath + ".part"
-		if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		tmpPath := d.partPath(req.PartDir, destPath)
+		raw, err := st.Create(ctx, tmpPath)
+		if err != nil {
 			return 0, err
 		}
-		out, err := os.Create(tmpPath)
+		out, err := d.holdBudget(ctx, raw)
 		if err != nil {
 			return 0, err
 		}
 
-		resp, err := d.httpClient.Do(req)
+		resp, err := client.Do(httpReq)
+		if err == nil {
+			err = d.checkHost(resp.Request.URL)
+			if err != nil {
+				resp.Body.Close()
+			}
+		}
+		if errors.Is(err, ErrHostNotAllowed) {
+			out.Abort()
+			return 0, err
+		}
 		if err != nil {
-			out.Close()
-			lastErr = err
+			out.Abort()
+			lastErr = fail(err)
 			select {
 			case <-time.After(backoff):
 				backoff *= 2
@@ -121,12 +384,13 @@ func (d *Downloader) Fetch(ctx context.Context, rawURL, destPath string) (int64,
 		if resp.Body != nil {
 			defer resp.Body.Close()
 		}
+		att.HTTPStatus = resp.StatusCode
 		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 			io.Copy(io.Discard, resp.Body)
-			out.Close()
-			os.Remove(tmpPath)
+			out.Abort()
 			lastErr = fmt.Errorf("http %d", resp.StatusCode)
 			if resp.StatusCode >= 500 && resp.StatusCode < 600 {
+				fail(lastErr)
 				select {
 				case <-time.After(backoff):
 					backoff *= 2
@@ -137,18 +401,62 @@ func (d *Downloader) Fetch(ctx context.Context, rawURL, destPath string) (int64,
 			}
 			return 0, lastErr
 		}
-
-		if cl := resp.Header.Get("Content-Length"); cl != "" {
-			if _, err := strconv.ParseInt(cl, 10, 64); err == nil {
-				// можно логировать/передавать как SizeHint
+		rangeLen := int64(-1)
+		if req.Range != nil {
+			if rangeLen, err = checkRange(resp, *req.Range); err != nil {
+				out.Abort()
+				return 0, err
 			}
 		}
 
-		written, copyErr := io.Copy(out, resp.Body)
-		closeErr := out.Close()
+		if req.OnSize != nil && resp.ContentLength > 0 {
+			req.OnSize(resp.ContentLength)
+		}
+		if err := d.tune(raw, resp.ContentLength); err != nil {
+			// тело — сам файл: не дочитываем, соединение закроет defer выше
+			out.Abort()
+			return 0, err
+		}
+
+		var dst io.Writer = out
+		if d.opts.WriteRate > 0 {
+			dst = &limitedWriter{ctx: ctx, w: out, l: &d.wlimit}
+		}
+		hasher := core.NewHash(algo)
+		if hasher != nil {
+			dst = io.MultiWriter(dst, hasher)
+		}
+		var verifier *sigVerifier
+		if sig != nil {
+			verifier = d.newSigVerifier(sig)
+			dst = io.MultiWriter(dst, verifier)
+		}
+		var sniff *sniffWriter
+		if req.OnContentType != nil {
+			sniff = &sniffWriter{}
+			dst = io.MultiWriter(dst, sniff)
+		}
+		if req.OnProgress != nil {
+			req.OnProgress(0)
+			dst = &progressWriter{w: dst, fn: req.OnProgress}
+		}
+		var body io.Reader = resp.Body
+		if l := d.hostLimiter(u.Host); l != nil {
+			body = &limitedReader{ctx: ctx, r: body, l: l}
+		}
+		buf := d.bufs.Get().(*[]byte)
+		written, copyErr := io.CopyBuffer(writerOnly{dst}, &limitedReader{ctx: ctx, r: body, l: &d.limit}, *buf)
+		d.bufs.Put(buf)
+		att.Bytes = written
+		if copyErr == nil && rangeLen >= 0 && written != rangeLen {
+			copyErr = fmt.Errorf("%w: got %d of %d bytes", ErrRangeMismatch, written, rangeLen)
+		}
 		if copyErr != nil {
-			lastErr = copyErr
-			os.Remove(tmpPath)
+			lastErr = fail(copyErr)
+			if verifier != nil {
+				verifier.Abort()
+			}
+			d.abort(out, QuarantineInfo{URL: rawURL, DestPath: destPath, Bytes: written, Size: resp.ContentLength, Error: copyErr.Error(), Attempt: attempt + 1})
 			select {
 			case <-time.After(backoff):
 				backoff *= 2
@@ -157,9 +465,28 @@ func (d *Downloader) Fetch(ctx context.Context, rawURL, destPath string) (int64,
 				return 0, ctx.Err()
 			}
 		}
-		if closeErr != nil {
-			lastErr = closeErr
-			os.Remove(tmpPath)
+		if hasher != nil && !bytes.Equal(hasher.Sum(nil), want) {
+			err := fmt.Errorf("%w: %s %x, expected %x", ErrChecksumMismatch, algo, hasher.Sum(nil), want)
+			if verifier != nil {
+				verifier.Abort()
+			}
+			d.abort(out, QuarantineInfo{URL: rawURL, DestPath: destPath, Bytes: written, Size: resp.ContentLength, Error: err.Error(), Attempt: attempt + 1})
+			return 0, err
+		}
+		if verifier != nil {
+			check := verifier.Finish()
+			if req.OnSignature != nil {
+				req.OnSignature(check)
+			}
+			if !check.Valid {
+				err := fmt.Errorf("%w: %s", ErrSignatureMismatch, check.Error)
+				d.abort(out, QuarantineInfo{URL: rawURL, DestPath: destPath, Bytes: written, Size: resp.ContentLength, Error: err.Error(), Attempt: attempt + 1})
+				return 0, err
+			}
+		}
+		if closeErr := out.Close(); closeErr != nil {
+			lastErr = fail(closeErr)
+			st.Remove(ctx, tmpPath)
 			select {
 			case <-time.After(backoff):
 				backoff *= 2
@@ -169,9 +496,19 @@ func (d *Downloader) Fetch(ctx context.Context, rawURL, destPath string) (int64,
 			}
 		}
 
-		if err := os.Rename(tmpPath, destPath); err != nil {
-			lastErr = err
-			os.Remove(tmpPath)
+		finalPath := destPath
+		if ct := sniff.contentType(); ct != "" {
+			if p := req.OnContentType(ct); p != "" {
+				finalPath = p
+			}
+		}
+		err = st.Rename(ctx, tmpPath, finalPath)
+		if err == nil && d.opts.FinalizeSync == SyncFull {
+			err = storage.SyncParent(finalPath)
+		}
+		if err != nil {
+			lastErr = fail(err)
+			st.Remove(ctx, tmpPath)
 			select {
 			case <-time.After(backoff):
 				backoff *= 2
@@ -180,14 +517,95 @@ func (d *Downloader) Fetch(ctx context.Context, rawURL, destPath string) (int64,
 				return 0, ctx.Err()
 			}
 		}
+		if req.OnResponse != nil {
+			req.OnResponse(responseInfo(resp, ip.get()))
+		}
 		return written, nil
 	}
 	if lastErr == nil {
-		lastErr = errors.New("неизвестная ошибка при скачивании")
+		lastErr = i18n.New(i18n.CodeDownloadUnknown)
 	}
 	return 0, lastErr
 }
 
+// responseInfo собирает метаданные ответа resp: итоговый URL (после
+// редиректов), код, Content-Type, ETag, Last-Modified (если разбирается) и
+// IP соединения.
+func responseInfo(resp *http.Response, ip string) core.FileResponse {
+	r := core.FileResponse{
+		FinalURL:    resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		ETag:        resp.Header.Get("ETag"),
+		RemoteIP:    ip,
+	}
+	if lm, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		lm = lm.UTC()
+		r.LastModified = &lm
+	}
+	return r
+}
+
+// remoteIP — IP, с которым соединяется запрос попытки (FileAttempt.RemoteIP).
+// Обратные вызовы трассировки могут прийти из горутины соединения и после
+// возврата client.Do, поэтому доступ — под mu.
+type remoteIP struct {
+	mu sync.Mutex
+	ip string
+}
+
+// trace дополняет ctx трассировкой, запоминающей адрес попытки соединения
+// (виден и при ошибке соединения), затем адрес полученного соединения (в том
+// числе из пула).
+func (r *remoteIP) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		ConnectStart: func(_, addr string) { r.set(addr) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Conn != nil {
+				r.set(info.Conn.RemoteAddr().String())
+			}
+		},
+	})
+}
+
+func (r *remoteIP) set(addr string) {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	r.mu.Lock()
+	r.ip = addr
+	r.mu.Unlock()
+}
+
+func (r *remoteIP) get() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ip
+}
+
+// tune включает для out оптимизации записи из Options, если хранилище их
+// поддерживает: резервирует size байт (size <= 0 — размер неизвестен),
+// отключает кеш страниц и включает fsync при закрытии (FinalizeSync). Ошибка резервирования (например, нет места) —
+// загрузка не начинается.
+func (d *Downloader) tune(out storage.Writer, size int64) error {
+	if p, ok := out.(storage.Preallocator); ok && d.opts.Preallocate && size > 0 {
+		if err := p.Preallocate(size); err != nil {
+			return fmt.Errorf("preallocate %d bytes: %w", size, err)
+		}
+	}
+	if c, ok := out.(storage.CacheBypasser); ok && d.opts.BypassCache {
+		c.BypassCache()
+	}
+	if s, ok := out.(storage.Syncer); ok && (d.opts.FinalizeSync == SyncFile || d.opts.FinalizeSync == SyncFull) {
+		s.SyncOnClose()
+	}
+	return nil
+}
+
+// writerOnly скрывает io.ReaderFrom приёмника (у *os.File он есть), чтобы
+// io.CopyBuffer копировал через буфер из пула, а не через свой.
+type writerOnly struct{ io.Writer }
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -0,0 +1,102 @@
+package downloader
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// HostProfile — настройки запросов к хостам, подходящим под шаблон
+// (Options.HostProfiles, выбор — core.LookupHost): особенности источника
+// задаются один раз, а не в каждой задаче. Действуют на загрузки (Do),
+// пробные запросы (Probe), подписи и вход на сайт-источник того же хоста.
+type HostProfile struct {
+	// Headers — заголовки всех запросов к хосту; одноимённые заголовки
+	// запроса (Request.Headers, LoginSpec.Headers) важнее.
+	Headers map[string]string
+	// Bandwidth — ограничение скорости загрузок с хостов профиля, байт/с
+	// (вдобавок к общему Options.Bandwidth); 0 — только общее. Предел общий
+	// для всех хостов шаблона: у "*.example.com" поддомены делят его.
+	Bandwidth int64
+	// Proxy — прокси (http://, https://, socks5://); nil — по окружению
+	// (HTTP_PROXY, HTTPS_PROXY, NO_PROXY). С Request.ConnectTo не используется.
+	Proxy *url.URL
+}
+
+// profile возвращает профиль хоста host (пустой, если ни один не подошёл).
+func (d *Downloader) profile(host string) HostProfile {
+	p, _ := core.LookupHost(d.opts.HostProfiles, host)
+	return p
+}
+
+// setHeaders выставляет заголовки профиля, а поверх — заголовки запроса own.
+func (p HostProfile) setHeaders(h http.Header, own map[string]string) {
+	for k, v := range p.Headers {
+		h.Set(k, v)
+	}
+	for k, v := range own {
+		h.Set(k, v)
+	}
+}
+
+// hostLimiter возвращает ограничитель скорости профиля хоста host
+// (HostProfile.Bandwidth); nil — профиль не ограничивает скорость.
+func (d *Downloader) hostLimiter(host string) *rateLimiter {
+	pattern, p, ok := core.LookupHostKey(d.opts.HostProfiles, host)
+	if !ok || p.Bandwidth <= 0 {
+		return nil
+	}
+	return d.rates.get(pattern, p.Bandwidth)
+}
+
+// hostRates — ограничения скорости профилей хостов (HostProfile.Bandwidth):
+// у каждого шаблона профиля свой rateLimiter, общий для всех загрузок с
+// подходящих под него хостов. Ограничитель, которым не пользовались дольше
+// hostSlotsIdleTTL, удаляется (sweepLocked) — как семафоры hostSlots.
+type hostRates struct {
+	mu sync.Mutex
+	m  map[string]*hostRate
+}
+
+// hostRate — ограничитель шаблона и время последней выдачи.
+type hostRate struct {
+	l    *rateLimiter
+	used time.Time
+}
+
+// get возвращает ограничитель шаблона pattern со скоростью rate (байт/с).
+func (r *hostRates) get(pattern string, rate int64) *rateLimiter {
+	pattern = strings.ToLower(pattern)
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.m[pattern]
+	if !ok {
+		r.sweepLocked(now)
+		e = &hostRate{l: &rateLimiter{}}
+		e.l.set(rate)
+		if r.m == nil {
+			r.m = make(map[string]*hostRate)
+		}
+		r.m[pattern] = e
+	} else if e.l.get() != rate {
+		e.l.set(rate)
+	}
+	e.used = now
+	return e.l
+}
+
+// sweepLocked удаляет ограничители, которые не выдавались и через которые не
+// читали дольше hostSlotsIdleTTL (долг ограничителя к этому времени погашен).
+// Вызывать под r.mu.
+func (r *hostRates) sweepLocked(now time.Time) {
+	for pattern, e := range r.m {
+		if now.Sub(e.used) > hostSlotsIdleTTL && now.Sub(e.l.lastUse()) > hostSlotsIdleTTL {
+			delete(r.m, pattern)
+		}
+	}
+}
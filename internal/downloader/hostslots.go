@@ -0,0 +1,89 @@
+package downloader
+
+import (
+	"sync"
+	"time"
+)
+
+// Пределы реестра семафоров хостов (hostSlots): семафор, которым никто не
+// пользуется дольше hostSlotsIdleTTL, удаляется; если хостов больше
+// hostSlotsMax, сверх предела удаляются дольше всех простаивающие (LRU).
+// Занятые семафоры не удаляются никогда — лимит хоста соблюдается.
+const (
+	hostSlotsIdleTTL = 10 * time.Minute
+	hostSlotsMax     = 1024
+)
+
+// hostSlots — потокобезопасный реестр семафоров параллелизма по хостам
+// (host → буферизированный канал ёмкостью лимита хоста).
+//
+// Семафор создаётся при первой загрузке с хоста и живёт, пока им кто-то
+// пользуется (держит слот или ждёт его). Простаивающие семафоры вычищаются
+// (sweep) при создании нового — так реестр не растёт с каждым когда-либо
+// встреченным хостом. Удалённый семафор пуст, поэтому следующий acquire
+// просто создаёт новый с тем же лимитом.
+type hostSlots struct {
+	mu sync.Mutex
+	m  map[string]*hostSlot
+}
+
+// hostSlot — семафор одного хоста.
+type hostSlot struct {
+	sem      chan struct{}
+	users    int       // держат слот или ждут его; >0 — семафор не удаляется
+	lastUsed time.Time // когда users последний раз стал 0
+}
+
+func newHostSlots() *hostSlots {
+	return &hostSlots{m: make(map[string]*hostSlot)}
+}
+
+// acquire захватывает слот хоста host с лимитом limit (> 0), при
+// необходимости дожидаясь свободного, и возвращает release-функцию. Лимит
+// применяется при создании семафора: семафор, созданный с другим лимитом,
+// используется как есть, пока не будет вычищен.
+func (r *hostSlots) acquire(host string, limit int) func() {
+	r.mu.Lock()
+	s, ok := r.m[host]
+	if !ok {
+		r.sweepLocked(time.Now())
+		s = &hostSlot{sem: make(chan struct{}, limit)}
+		r.m[host] = s
+	}
+	s.users++
+	r.mu.Unlock()
+
+	s.sem <- struct{}{}
+	return func() {
+		<-s.sem
+		r.mu.Lock()
+		s.users--
+		if s.users == 0 {
+			s.lastUsed = time.Now()
+		}
+		r.mu.Unlock()
+	}
+}
+
+// sweepLocked удаляет семафоры, простаивающие дольше hostSlotsIdleTTL, а
+// если и после этого места для нового хоста нет — самые давние из
+// простаивающих. Вызывать под r.mu.
+func (r *hostSlots) sweepLocked(now time.Time) {
+	for host, s := range r.m {
+		if s.users == 0 && now.Sub(s.lastUsed) > hostSlotsIdleTTL {
+			delete(r.m, host)
+		}
+	}
+	for len(r.m) >= hostSlotsMax {
+		oldest := ""
+		for host, s := range r.m {
+			if s.users == 0 && (oldest == "" || s.lastUsed.Before(r.m[oldest].lastUsed)) {
+				oldest = host
+			}
+		}
+		if oldest == "" {
+			return // все заняты: реестр временно превысит предел
+		}
+		delete(r.m, oldest)
+	}
+}
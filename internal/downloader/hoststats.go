@@ -0,0 +1,111 @@
+package downloader
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HostStats — накопленная статистика загрузок с одного хоста.
+// Одна «загрузка» — один вызов Do (все внутренние ретраи входят в неё).
+type HostStats struct {
+	Host                string     `json:"host"`
+	Active              int        `json:"active"`  // занятые слоты HostConcurrency
+	Waiting             int        `json:"waiting"` // ждут свободного слота
+	Successes           int64      `json:"successes"`
+	Failures            int64      `json:"failures"`
+	SuccessRate         float64    `json:"success_rate"`      // 0..1
+	AvgSpeed            float64    `json:"avg_bytes_per_sec"` // по успешным загрузкам
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+
+	bytes    int64
+	duration time.Duration
+}
+
+// hostStatsRegistry — потокобезопасная карта host → статистика.
+type hostStatsRegistry struct {
+	mu sync.Mutex
+	m  map[string]*HostStats
+}
+
+func newHostStatsRegistry() *hostStatsRegistry {
+	return &hostStatsRegistry{m: make(map[string]*HostStats)}
+}
+
+// get возвращает (создавая при необходимости) запись для host. Вызывать под r.mu.
+func (r *hostStatsRegistry) get(host string) *HostStats {
+	s, ok := r.m[host]
+	if !ok {
+		s = &HostStats{Host: host}
+		r.m[host] = s
+	}
+	return s
+}
+
+// waiting/acquired/released отслеживают очередь и занятость слотов хоста.
+func (r *hostStatsRegistry) waiting(host string) {
+	r.mu.Lock()
+	r.get(host).Waiting++
+	r.mu.Unlock()
+}
+
+func (r *hostStatsRegistry) acquired(host string) {
+	r.mu.Lock()
+	s := r.get(host)
+	s.Waiting--
+	s.Active++
+	r.mu.Unlock()
+}
+
+func (r *hostStatsRegistry) released(host string) {
+	r.mu.Lock()
+	r.get(host).Active--
+	r.mu.Unlock()
+}
+
+// finished фиксирует итог загрузки: при err == nil — успех с объёмом n
+// за время took, иначе — неудачу с текстом ошибки.
+func (r *hostStatsRegistry) finished(host string, n int64, took time.Duration, err error) {
+	now := time.Now().UTC()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.get(host)
+	if err != nil {
+		s.Failures++
+		s.ConsecutiveFailures++
+		s.LastError = err.Error()
+		s.LastErrorAt = &now
+		return
+	}
+	s.Successes++
+	s.ConsecutiveFailures = 0
+	s.LastSuccessAt = &now
+	s.bytes += n
+	s.duration += took
+}
+
+// snapshot возвращает копии всех записей, отсортированные по имени хоста,
+// с вычисленными SuccessRate и AvgSpeed.
+func (r *hostStatsRegistry) snapshot() []HostStats {
+	r.mu.Lock()
+	out := make([]HostStats, 0, len(r.m))
+	for _, s := range r.m {
+		c := *s
+		if total := c.Successes + c.Failures; total > 0 {
+			c.SuccessRate = float64(c.Successes) / float64(total)
+		}
+		if c.duration > 0 {
+			c.AvgSpeed = float64(c.bytes) / c.duration.Seconds()
+		}
+		out = append(out, c)
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+// HostStats возвращает статистику по всем хостам, с которыми работал загрузчик.
+func (d *Downloader) HostStats() []HostStats { return d.stats.snapshot() }
@@ -0,0 +1,120 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// maxLoginBody — сколько байт ответа на вход читается (и отбрасывается),
+// чтобы соединение вернулось в пул.
+const maxLoginBody = 1 << 20
+
+// Login выполняет вход l и возвращает сессию — набор cookie, который
+// передаётся загрузкам задачи в Request.Jar.
+//
+// Запрос: метод l.HTTPMethod(), поля формы — телом
+// application/x-www-form-urlencoded (POST) или в query (GET), заголовки
+// l.Headers поверх заголовков профиля хоста (HostProfile, его прокси —
+// тоже). Редиректы (обычное «302 на главную» после входа) проходятся с той
+// же сессией. Вход удался, если итоговый ответ — 2xx и (при заданном
+// l.Cookie) сервер выставил эту cookie по пути. Слоты хостов, статистика и
+// ограничение скорости не затрагиваются; повторов нет — их делают загрузки,
+// которым нужна сессия.
+func (d *Downloader) Login(ctx context.Context, l core.LoginSpec) (http.CookieJar, error) {
+	u, err := url.Parse(l.URL)
+	if err != nil {
+		return nil, err
+	}
+	form := make(url.Values, len(l.Form))
+	for k, v := range l.Form {
+		form.Set(k, v)
+	}
+	method := l.HTTPMethod()
+	var body io.Reader
+	if method == http.MethodGet {
+		q := u.Query()
+		for k, v := range form {
+			q[k] = v
+		}
+		u.RawQuery = q.Encode()
+	} else {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	prof := d.profile(u.Host)
+	prof.setHeaders(req.Header, l.Headers)
+
+	inner, _ := cookiejar.New(nil)
+	jar := &loginJar{CookieJar: inner, seen: make(map[string]bool)}
+	client := *d.clientFor(dialTarget(u), "", prof.Proxy)
+	client.Jar = jar
+	resp, err := client.Do(req)
+	if err != nil {
+		// *url.Error печатает URL целиком, а у GET-входа в query — поля формы
+		// (пароль): в ошибку — только метод, хост и путь
+		if inner := errors.Unwrap(err); inner != nil {
+			err = inner
+		}
+		return nil, fmt.Errorf("login: %s %s%s: %w", method, u.Host, u.Path, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxLoginBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("login: http %d", resp.StatusCode)
+	}
+	if l.Cookie != "" && !jar.saw(l.Cookie) {
+		return nil, fmt.Errorf("login: cookie %q not set", l.Cookie)
+	}
+	return inner, nil
+}
+
+// loginJar — cookie jar входа, запоминающий имена выставленных cookie:
+// ожидаемая cookie может относиться к пути, отличному от URL входа, и
+// через Cookies(u) её не найти.
+type loginJar struct {
+	http.CookieJar
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (j *loginJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	for _, c := range cookies {
+		if c.MaxAge >= 0 && c.Value != "" {
+			j.seen[c.Name] = true
+		}
+	}
+	j.mu.Unlock()
+	j.CookieJar.SetCookies(u, cookies)
+}
+
+func (j *loginJar) saw(name string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.seen[name]
+}
+
+// withJar — клиент c с сессией jar (копия c с общим транспортом); nil — сам c.
+func withJar(c *http.Client, jar http.CookieJar) *http.Client {
+	if jar == nil {
+		return c
+	}
+	cc := *c
+	cc.Jar = jar
+	return &cc
+}
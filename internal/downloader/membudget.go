@@ -0,0 +1,142 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// streamOverhead — память одной загрузки помимо буфера копирования и
+// буфера хранилища: буферы чтения HTTP-транспорта, окно распаковки gzip,
+// состояние хеша контрольной суммы. Оценка сверху.
+const streamOverhead = 64 << 10
+
+// memBudget — общий предел памяти под буферы идущих загрузок
+// (Options.MaxInflightBytes). Загрузка занимает свою долю (bufferCost)
+// перед запросом к серверу и возвращает её, когда файл закрыт или брошен;
+// если доли нет — ждёт, пока её освободят другие. Так пул воркеров
+// размером с машину не исчерпает память, когда огромные ответы приходят
+// разом: лишние загрузки просто ждут своей очереди.
+//
+// Нулевой limit — предела нет.
+type memBudget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+	wake  chan struct{} // закрывается при каждом освобождении
+}
+
+func newMemBudget(limit int64) *memBudget {
+	return &memBudget{limit: limit, wake: make(chan struct{})}
+}
+
+// acquire занимает n байт, дожидаясь свободной доли. Доля больше предела
+// урезается до него: такая загрузка идёт, только когда других нет. Ошибка —
+// только отмена ctx.
+func (b *memBudget) acquire(ctx context.Context, n int64) error {
+	if b.limit <= 0 {
+		return nil
+	}
+	n = min(n, b.limit)
+	for {
+		b.mu.Lock()
+		if b.used+n <= b.limit {
+			b.used += n
+			b.mu.Unlock()
+			return nil
+		}
+		wake := b.wake
+		b.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release возвращает n байт, занятых acquire.
+func (b *memBudget) release(n int64) {
+	if b.limit <= 0 {
+		return
+	}
+	n = min(n, b.limit)
+	b.mu.Lock()
+	b.used -= n
+	close(b.wake)
+	b.wake = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// inUse — занято байт.
+func (b *memBudget) inUse() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// bufferCost — оценка памяти загрузки в writer out: буфер копирования,
+// streamOverhead и буфер самого хранилища (storage.Buffered, например часть
+// multipart-загрузки S3).
+func (d *Downloader) bufferCost(out storage.Writer) int64 {
+	n := int64(d.opts.BufferSize) + streamOverhead
+	if b, ok := out.(storage.Buffered); ok {
+		n += b.BufferedMax()
+	}
+	return n
+}
+
+// budgetWriter — writer загрузки, занявшей долю memBudget: Close, Abort и
+// Keep возвращают её.
+type budgetWriter struct {
+	storage.Writer
+	b    *memBudget
+	n    int64
+	once sync.Once
+}
+
+// holdBudget занимает долю memBudget под загрузку в out и возвращает
+// writer, который вернёт её при закрытии. Если ctx отменён раньше, out
+// брошен (Abort).
+func (d *Downloader) holdBudget(ctx context.Context, out storage.Writer) (storage.Writer, error) {
+	if d.mem.limit <= 0 {
+		return out, nil
+	}
+	n := d.bufferCost(out)
+	if err := d.mem.acquire(ctx, n); err != nil {
+		out.Abort()
+		return nil, err
+	}
+	return &budgetWriter{Writer: out, b: d.mem, n: n}, nil
+}
+
+func (w *budgetWriter) Close() error {
+	defer w.done()
+	return w.Writer.Close()
+}
+
+func (w *budgetWriter) Abort() error {
+	defer w.done()
+	return w.Writer.Abort()
+}
+
+// Keep — storage.Keeper, если он есть у обёрнутого writer; иначе — Abort
+// и errors.ErrUnsupported.
+func (w *budgetWriter) Keep(name string) error {
+	defer w.done()
+	if k, ok := w.Writer.(storage.Keeper); ok {
+		return k.Keep(name)
+	}
+	w.Writer.Abort()
+	return errors.ErrUnsupported
+}
+
+func (w *budgetWriter) done() { w.once.Do(func() { w.b.release(w.n) }) }
+
+// InflightBytes возвращает занятую долю и предел памяти под буферы
+// загрузок (Options.MaxInflightBytes); предел 0 — без ограничения.
+func (d *Downloader) InflightBytes() (used, limit int64) {
+	return d.mem.inUse(), d.mem.limit
+}
@@ -0,0 +1,54 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// UniquePath возвращает уникальный путь на локальном диске на основе base
+// (UniquePathIn с storage.Local).
+func UniquePath(base string) string {
+	return UniquePathIn(context.Background(), storage.Local, base)
+}
+
+// UniquePathIn возвращает уникальное в хранилище st имя на основе base.
+// Если base не занят — возвращает его. Иначе подставляет суффикс "-N"
+// перед расширением (name-1.ext, name-2.ext, …) и ищет первый свободный
+// до 9999. Если не нашёл — возвращает base + "-dup".
+func UniquePathIn(ctx context.Context, st storage.Storage, base string) string {
+	if _, err := st.Stat(ctx, base); errors.Is(err, fs.ErrNotExist) {
+		return base
+	}
+	ext := filepath.Ext(base)
+	name := base[:len(base)-len(ext)]
+	for i := 1; i < 10000; i++ {
+		p := name + "-" + strconv.Itoa(i) + ext
+		if _, err := st.Stat(ctx, p); errors.Is(err, fs.ErrNotExist) {
+			return p
+		}
+	}
+	return base + "-dup"
+}
+
+// partPath — временный файл загрузки в destPath: destPath+".part" или, если
+// задан каталог dir (Request.PartDir, иначе Options.PartDir) и destPath на
+// диске, dir/<ключ>.part, где ключ — первые 16 hex-символов sha256 от
+// destPath (у каждого файла назначения свой .part). Объекты S3 всегда
+// пишутся рядом: их .part — та же multipart-загрузка в bucket.
+func (d *Downloader) partPath(dir, destPath string) string {
+	if dir == "" {
+		dir = d.opts.PartDir
+	}
+	if dir == "" || storage.IsObject(destPath) {
+		return destPath + ".part"
+	}
+	sum := sha256.Sum256([]byte(destPath))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".part")
+}
@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProbeResult — ответ сервера-источника на пробный запрос (Probe).
+type ProbeResult struct {
+	Status       int    `json:"status,omitempty"`        // HTTP-статус; 0 — ответа нет (см. Error)
+	Size         int64  `json:"size,omitempty"`          // Content-Length; 0 — неизвестен
+	ContentType  string `json:"content_type,omitempty"`  // Content-Type
+	AcceptRanges bool   `json:"accept_ranges,omitempty"` // сервер отдаёт диапазоны (Accept-Ranges: bytes)
+	Error        string `json:"error,omitempty"`         // сетевая ошибка или "http <код>" для не-2xx
+}
+
+// OK сообщает, что ресурс доступен (2xx).
+func (p ProbeResult) OK() bool { return p.Status >= 200 && p.Status < 300 }
+
+// Probe проверяет доступность req.URL без скачивания: HEAD с заголовками
+// req.Headers (и req.Host, req.ConnectTo, req.Jar, профиль хоста — как у Do) тем же HTTP-клиентом
+// (таймаут ClientTimeout), что и загрузки. DestPath и обработчики не используются.
+// Серверам, не принимающим HEAD (405, 501), отправляется GET с Range: bytes=0-0,
+// тело не читается. Слоты хостов (HostConcurrency), статистика и ограничение
+// скорости не затрагиваются; повторов нет.
+func (d *Downloader) Probe(ctx context.Context, req Request) ProbeResult {
+	res, err := d.probe(ctx, http.MethodHead, req)
+	if err == nil && (res.Status == http.StatusMethodNotAllowed || res.Status == http.StatusNotImplemented) {
+		res, err = d.probe(ctx, http.MethodGet, req)
+	}
+	if err != nil {
+		return ProbeResult{Error: err.Error()}
+	}
+	return res
+}
+
+// probe — один пробный запрос методом method (GET — только первый байт).
+func (d *Downloader) probe(ctx context.Context, method string, r Request) (ProbeResult, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.URL, nil)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	prof := d.profile(req.URL.Host)
+	prof.setHeaders(req.Header, r.Headers)
+	if r.Host != "" {
+		req.Host = r.Host
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := withJar(d.clientFor(dialTarget(req.URL), r.ConnectTo, prof.Proxy), r.Jar).Do(req)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<10))
+
+	res := ProbeResult{
+		Status:       resp.StatusCode,
+		ContentType:  resp.Header.Get("Content-Type"),
+		AcceptRanges: strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes"),
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		// "Content-Range: bytes 0-0/12345" — полный размер после "/"
+		res.AcceptRanges = true
+		if _, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/"); ok {
+			res.Size, _ = strconv.ParseInt(total, 10, 64)
+		}
+		res.Status = http.StatusOK
+	case resp.ContentLength > 0:
+		res.Size = resp.ContentLength
+	}
+	if !res.OK() {
+		res.Error = "http " + strconv.Itoa(resp.StatusCode)
+	}
+	return res, nil
+}
@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// Карантин (Options.QuarantineDir): недокачанный файл попытки — оборванный
+// на середине или не сошедшийся с контрольной суммой — не удаляется, а
+// переносится в каталог карантина вместе с описанием (QuarantineInfo), чтобы
+// оператор мог посмотреть, что на самом деле пришло, а докачка — продолжить
+// с того же места. Файл ссылки в карантине один (QuarantinePaths): следующая
+// неудачная попытка заменяет предыдущую. Пустые попытки (ошибка до первого
+// байта) и хранилища, не умеющие сохранять недописанное (storage.Keeper,
+// например S3), — как без карантина: файл удаляется.
+
+// QuarantineInfo — описание файла в карантине (<ключ>.json рядом с <ключ>.part).
+type QuarantineInfo struct {
+	URL      string    `json:"url"`
+	DestPath string    `json:"dest_path"`      // куда файл сохранялся бы
+	Bytes    int64     `json:"bytes"`          // сколько байт получено
+	Size     int64     `json:"size,omitempty"` // ожидаемый размер (Content-Length), если известен
+	Error    string    `json:"error"`
+	Attempt  int       `json:"attempt"` // номер попытки внутри Do (с 1)
+	At       time.Time `json:"quarantined_at"`
+}
+
+// QuarantinePaths — пути файла и описания ссылки rawURL в карантине dir:
+// ключ — первые 16 hex-символов sha256 от URL.
+func QuarantinePaths(dir, rawURL string) (data, meta string) {
+	sum := sha256.Sum256([]byte(rawURL))
+	key := filepath.Join(dir, hex.EncodeToString(sum[:8]))
+	return key + ".part", key + ".json"
+}
+
+// abort бросает недописанный out: с карантином и полученными данными —
+// переносит их в карантин (storage.Keeper) и пишет описание info, иначе
+// удаляет (Abort). Ошибки карантина пишутся в лог: загрузке они не мешают.
+func (d *Downloader) abort(out storage.Writer, info QuarantineInfo) {
+	k, ok := out.(storage.Keeper)
+	if d.opts.QuarantineDir == "" || info.Bytes <= 0 || !ok {
+		out.Abort()
+		return
+	}
+	data, meta := QuarantinePaths(d.opts.QuarantineDir, info.URL)
+	if err := k.Keep(data); err != nil {
+		log.Printf("quarantine %s: %v", info.URL, err)
+		return
+	}
+	info.At = time.Now().UTC()
+	b, _ := json.MarshalIndent(info, "", "  ")
+	if err := os.WriteFile(meta, append(b, '\n'), 0o644); err != nil {
+		log.Printf("quarantine %s: %v", info.URL, err)
+	}
+}
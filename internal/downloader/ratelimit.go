@@ -0,0 +1,133 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// rateChunk — наибольшее чтение за раз при ограниченной скорости:
+	// большие порции дают рывки, когда скорость делят несколько загрузок.
+	rateChunk = 32 << 10
+	// rateBurst — сколько «неиспользованного» времени копит ограничитель:
+	// после простоя загрузка идёт без паузы не дольше этого.
+	rateBurst = 100 * time.Millisecond
+)
+
+// rateLimiter — общее ограничение скорости всех загрузок (байт/с).
+// Каждое прочитанное из ответа сервера увеличивает «долг» next на n/rate;
+// читающий ждёт, пока долг не будет погашен.
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate int64     // байт/с; 0 — без ограничения
+	next time.Time // момент, к которому погашен уже выданный объём
+}
+
+// set меняет скорость; накопленный долг сбрасывается.
+func (l *rateLimiter) set(rate int64) {
+	if rate < 0 {
+		rate = 0
+	}
+	l.mu.Lock()
+	l.rate, l.next = rate, time.Time{}
+	l.mu.Unlock()
+}
+
+func (l *rateLimiter) get() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// lastUse — момент, к которому погашен выданный объём: позже него через
+// ограничитель не читали (нулевое время — не читали вовсе).
+func (l *rateLimiter) lastUse() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.next
+}
+
+// wait учитывает n прочитанных байт и ждёт, пока скорость не вернётся
+// в пределы ограничения. Ошибка — только отмена ctx.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	if l.rate == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if floor := now.Add(-rateBurst); l.next.Before(floor) {
+		l.next = floor
+	}
+	l.next = l.next.Add(time.Duration(float64(n) * float64(time.Second) / float64(l.rate)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limitedReader — тело ответа, читаемое не быстрее ограничения l.
+// Скорость проверяется на каждом чтении, так что её смена (SetBandwidth)
+// действует и на уже идущие загрузки.
+type limitedReader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *rateLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateChunk && r.l.get() > 0 {
+		p = p[:rateChunk]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.l.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// limitedWriter — запись в хранилище не быстрее ограничения l (Options.WriteRate):
+// блоки копирования делятся на порции по rateChunk, и после каждой пишущий
+// ждёт, так что на диск уходит не больше rateBurst накопленного объёма разом.
+type limitedWriter struct {
+	ctx context.Context
+	w   io.Writer
+	l   *rateLimiter
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p[:min(len(p), rateChunk)]
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if err := w.l.wait(w.ctx, n); err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// SetBandwidth меняет общее ограничение скорости загрузок (байт/с);
+// 0 — без ограничения. Действует сразу, в том числе на идущие загрузки.
+func (d *Downloader) SetBandwidth(bytesPerSec int64) { d.limit.set(bytesPerSec) }
+
+// Bandwidth возвращает текущее ограничение скорости (байт/с); 0 — без ограничения.
+func (d *Downloader) Bandwidth() int64 { return d.limit.get() }
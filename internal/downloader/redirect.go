@@ -0,0 +1,35 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ErrHostNotAllowed — ссылка или редирект ведёт на хост, не прошедший
+// Options.AllowHost.
+var ErrHostNotAllowed = errors.New("host not allowed")
+
+// maxRedirects — предел редиректов одного запроса (как у http.Client по
+// умолчанию).
+const maxRedirects = 10
+
+// checkHost проверяет хост u по Options.AllowHost (nil — разрешены любые).
+func (d *Downloader) checkHost(u *url.URL) error {
+	if d.opts.AllowHost == nil || d.opts.AllowHost(u.Host) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Hostname())
+}
+
+// checkRedirect — CheckRedirect всех клиентов загрузчика (d.httpClient,
+// clientFor): каждый переход проверяется по Options.AllowHost, иначе
+// ответ 302 уводил бы загрузку, вход или подпись на любой хост в обход
+// списка разрешённых.
+func (d *Downloader) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return d.checkHost(req.URL)
+}
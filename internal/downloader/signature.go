@@ -0,0 +1,175 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// Проверка отделённых подписей OpenPGP (Request.Signature): подпись
+// скачивается перед первой попыткой, а файл проверяется потоком, по ходу
+// записи, — как контрольная сумма. Неверная подпись (или подпись ключом не
+// из связки Options.Keyring) — файл не сохраняется, Do возвращает
+// ErrSignatureMismatch без повторов внутри Do, как при несовпадении
+// контрольной суммы.
+
+// maxSignatureSize — наибольший размер файла подписи.
+const maxSignatureSize = 64 << 10
+
+// ErrSignatureMismatch — подпись файла не прошла проверку.
+var ErrSignatureMismatch = errors.New("signature mismatch")
+
+// LoadKeyring читает связки открытых ключей OpenPGP из файлов paths
+// (экспорт gpg --export, двоичный или --armor) и объединяет их.
+func LoadKeyring(paths []string) (openpgp.EntityList, error) {
+	var all openpgp.EntityList
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var el openpgp.EntityList
+		if isArmored(data) {
+			el, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		} else {
+			el, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		all = append(all, el...)
+	}
+	return all, nil
+}
+
+func isArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN PGP"))
+}
+
+// detachedSignature — скачанная и разобранная подпись файла.
+type detachedSignature struct {
+	raw   []byte // двоичная (без armor)
+	keyID string // ID ключа подписи, 16 hex
+}
+
+// fetchSignature скачивает подпись по req.Signature тем же клиентом, что и
+// файл (с сессией req.Jar; заголовки req.Headers — только если подпись на
+// том же хосте, чтобы не отдать их чужому серверу; заголовки и прокси профиля —
+// по хосту подписи), и разбирает её.
+func (d *Downloader) fetchSignature(ctx context.Context, req Request) (*detachedSignature, error) {
+	if len(d.opts.Keyring) == 0 {
+		return nil, errors.New("signature: no keyring configured")
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.Signature, nil)
+	if err != nil {
+		return nil, err
+	}
+	var own map[string]string
+	if fu, err := url.Parse(req.URL); err == nil && strings.EqualFold(fu.Host, httpReq.URL.Host) {
+		own = req.Headers
+	}
+	prof := d.profile(httpReq.URL.Host)
+	prof.setHeaders(httpReq.Header, own)
+	resp, err := withJar(d.clientFor(dialTarget(httpReq.URL), "", prof.Proxy), req.Jar).Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("signature: http %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	if len(data) > maxSignatureSize {
+		return nil, fmt.Errorf("signature: larger than %d bytes", maxSignatureSize)
+	}
+	if isArmored(data) {
+		block, err := armor.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("signature: %w", err)
+		}
+		if data, err = io.ReadAll(block.Body); err != nil {
+			return nil, fmt.Errorf("signature: %w", err)
+		}
+	}
+	p, err := packet.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	sig, ok := p.(*packet.Signature)
+	if !ok || sig.IssuerKeyId == nil {
+		return nil, errors.New("signature: not a detached OpenPGP signature")
+	}
+	return &detachedSignature{raw: data, keyID: fmt.Sprintf("%016X", *sig.IssuerKeyId)}, nil
+}
+
+// sigVerifier проверяет подпись потоком: Write передаёт данные проверке,
+// идущей в своей горутине, Finish дожидается её результата.
+type sigVerifier struct {
+	pw     *io.PipeWriter
+	done   chan core.SignatureCheck
+	failed bool // проверка закончилась раньше данных (ключ не найден и т.п.)
+}
+
+func (d *Downloader) newSigVerifier(s *detachedSignature) *sigVerifier {
+	pr, pw := io.Pipe()
+	v := &sigVerifier{pw: pw, done: make(chan core.SignatureCheck, 1)}
+	go func() {
+		res := core.SignatureCheck{KeyID: s.keyID}
+		_, signer, err := openpgp.VerifyDetachedSignature(d.opts.Keyring, pr, bytes.NewReader(s.raw), nil)
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Valid = true
+			if id := signer.PrimaryIdentity(); id != nil {
+				res.Signer = id.Name
+			}
+		}
+		pr.CloseWithError(errSigDone) // данные после результата не нужны
+		v.done <- res
+	}()
+	return v
+}
+
+// errSigDone — проверка закончена, дальнейшие данные отбрасываются.
+var errSigDone = errors.New("signature check finished")
+
+// Write не возвращает ошибок: если проверка закончилась раньше (результат
+// уже известен), остаток данных отбрасывается.
+func (v *sigVerifier) Write(p []byte) (int, error) {
+	if !v.failed {
+		if _, err := v.pw.Write(p); err != nil {
+			v.failed = true
+		}
+	}
+	return len(p), nil
+}
+
+// Finish сообщает проверке конец данных и возвращает её результат.
+func (v *sigVerifier) Finish() core.SignatureCheck {
+	v.pw.Close()
+	res := <-v.done
+	res.CheckedAt = time.Now().UTC()
+	return res
+}
+
+// Abort прерывает проверку (попытка не удалась).
+func (v *sigVerifier) Abort() {
+	v.pw.CloseWithError(errSigDone)
+	<-v.done
+}
@@ -0,0 +1,27 @@
+package downloader
+
+import "net/http"
+
+// sniffLen — сколько первых байт смотрит http.DetectContentType.
+const sniffLen = 512
+
+// sniffWriter запоминает первые sniffLen байт записанного, чтобы определить
+// тип содержимого (Request.OnContentType). Ошибок не возвращает.
+type sniffWriter struct {
+	head []byte
+}
+
+func (w *sniffWriter) Write(p []byte) (int, error) {
+	if n := sniffLen - len(w.head); n > 0 {
+		w.head = append(w.head, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// contentType — тип по первым байтам; пусто — ничего не записано или w == nil.
+func (w *sniffWriter) contentType() string {
+	if w == nil || len(w.head) == 0 {
+		return ""
+	}
+	return http.DetectContentType(w.head)
+}
@@ -1,16 +1,32 @@
 package httpapi
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"mime"
 	"net/http"
-	"path/filepath"
+	"net/http/pprof"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Extrarius/29.09.2025/internal/app"
+	"github.com/Extrarius/29.09.2025/internal/auth"
+	"github.com/Extrarius/29.09.2025/internal/bundle"
 	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+	"github.com/Extrarius/29.09.2025/internal/metrics"
+	"github.com/Extrarius/29.09.2025/internal/store"
+)
+
+const (
+	maxTaskBody  = 8 << 20 // лимит тела POST /tasks
+	maxTaskLinks = 10_000  // лимит ссылок в POST /tasks (импорт — до maxImportLinks)
 )
 
 // NewRouter собирает HTTP-маршрутизатор (http.ServeMux) для API сервиса.
@@ -18,111 +34,925 @@ import (
 // Эндпоинты:
 //
 //	GET  /healthz        — проверка живости, отвечает "ok".
-//	POST /admin/drain    — поставить диспетчер на «паузу» (drain=true).
-//	POST /admin/resume   — снять «паузу» (drain=false).
-//	POST /tasks          — создать задачу: {links, label, dest_dir}; возвращает {task_id}.
-//	GET  /tasks          — список всех задач (в памяти).
-//	GET  /tasks/{id}     — данные одной задачи.
+//	GET  /readyz         — готовность (после восстановления, до shutdown), иначе 503;
+//	                       пока читается журнал, /tasks* тоже отвечают 503 (withLoaded).
+//	GET  /admin/tasks/{id}/export — архив задачи (tar: метаданные, история, файлы; пакет bundle).
+//	POST /admin/tasks/import — создать задачу из такого архива (тело — tar); 409, если ID занят.
+//	GET  /admin/recovery — ход восстановления: фаза, % журнала, записей/с (только админ).
+//	GET  /healthz/details — WAL, место на дисках, очередь, последняя успешная загрузка
+//	                       (ok/degraded по каждой проверке; 503 при деградации).
+//	POST /admin/drain    — поставить диспетчер на «паузу» (drain=true) (только админ).
+//	POST /admin/resume   — снять «паузу» (drain=false) (только админ).
+//	                       Режим drain сохраняется в WAL и переживает перезапуск.
+//	POST /admin/hosts/{host}/pause|resume — приостановить/возобновить выдачу
+//	                       заданий одного хоста (тоже сохраняется в WAL) (только админ).
+//	GET  /admin/workers  — состояние воркеров: текущий файл, байты, время (только админ).
+//	GET  /admin/hosts    — статистика по хостам: успехи/ошибки, скорость, слоты (только админ).
+//	GET  /admin/alerts   — правила оповещений (секция alerts): условие, последнее значение,
+//	                       сработало ли и с какого момента (app.AlertStatus) (только админ).
+//	GET  /admin/diagnostics — снимок рантайма, очереди и воркеров (только админ).
+//	GET  /admin/store    — журнал: размер, записи, возраст снимка, последняя компактизация (только админ).
+//	POST /admin/store/compact — компактизировать журнал на ходу (только админ).
+//	POST /admin/gc       — убрать из DOWNLOAD_DIR файлы удалённых задач, брошенные .part
+//	                       и пустые каталоги (app.CollectGarbage); ?dry_run=true — только
+//	                       отчёт, ?min_age=1h — не трогать файлы моложе (только админ).
+//	GET  /admin/limits   — лимиты приёма задач и их потребление по арендаторам (только админ).
+//	PATCH /admin/limits  — изменить лимиты до перезапуска (app.Limits; только админ).
+//	GET  /admin/usage    — потребление по арендаторам и ключам API за период,
+//	                       JSON или CSV (см. registerUsage) (только админ).
+//	GET  /debug/pprof/...   — профилировщик net/http/pprof (только админ).
+//	GET  /metrics        — метрики в текстовом формате Prometheus: очередь (ожидание,
+//	                       пребывание в backlog, выдача пачками), воркеры (только админ).
+//	POST /tasks          — создать задачу: core.TaskSpec {links, label, dest_dir, layout, depends_on};
+//	                       201 с Location: /tasks/{id} и задачей целиком (createdTask: поля
+//	                       core.Task и task_id). ?wait=true (или длительность, не больше
+//	                       createWaitMax) — ответить, когда задача дойдёт до конечного статуса.
+//	                       links — строки URL или объекты {url, filename, dest_subpath,
+//	                       checksum, headers, max_attempts}. Разбор строгий (decodeTaskSpec):
+//	                       ошибки всех полей и ссылок возвращаются одним ответом 400;
+//	                       превышение лимитов (app.Limits) — 422/429, см. writeCreateError.
+//	                       ?dry_run=true — пробный прогон без создания задачи (как /tasks/validate).
+//	POST /tasks/validate — пробный прогон (app.DryRunTask): то же тело, что у POST /tasks;
+//	                       ?probe=true — ещё и HEAD к каждой ссылке. Возвращает app.DryRun
+//	                       (будущие файлы, пути и вердикты по ссылкам); ничего не создаётся.
+//	GET  /tasks          — список задач (по времени создания, ?limit=&offset=); фильтры
+//	                       ?status=FAILED&tag=nightly&created_from=…&created_to=… (store.TaskQuery);
+//	                       задачи в корзине — только с ?trash=only (или all — вместе с остальными).
+//	                       Accept: application/x-ndjson — потоком, задача на строку
+//	                       (writeTasksNDJSON); limit по умолчанию не ограничен.
+//	GET  /stats          — сводка по всем задачам (app.Stats): число по статусам, байты за
+//	                       сегодня и за неделю, хосты по объёму (?top=10), средняя длительность
+//	                       загрузки и текущая скорость; счётчики ведутся по ходу работы.
+//	GET  /tasks/export.csv — задачи в CSV с колонками ?columns=…, те же фильтры;
+//	                       GET /tasks/{id}/files.csv — файлы задачи (см. registerCSVExport).
+//	GET  /tasks/{id}     — данные одной задачи; ?wait=30s&since_version=N — long-polling
+//	                       (ждать, пока версия задачи отличается от N, не дольше wait).
+//	                       ETag — версия задачи; If-None-Match с ней → 304 (так же для files/{fid}).
+//	PATCH /tasks/{id}    — изменить метку, теги, приоритет и max_attempts ждущих файлов
+//	                       (core.TaskPatch); возвращает обновлённую задачу.
+//	DELETE /tasks/{id}   — удалить задачу и её файлы; blob'ы BLOB_DIR, на которые ссылаются
+//	                       другие задачи, остаются; возвращает app.DeleteResult. При
+//	                       TRASH_RETENTION задача переносится в корзину (app.TrashTask:
+//	                       файлы на месте, trashed и purge_at в ответе); ?purge=true — сразу.
+//	POST /tasks/{id}/restore — вернуть задачу из корзины (409 — не в корзине); возвращает задачу.
+//	POST /tasks/retry-failed — массовый retry по фильтру app.TaskFilter {ids, status, tag, host};
+//	                       возвращает app.BulkResult {tasks, files, task_ids}.
+//	POST /tasks/cancel   — массовая отмена незавершённых файлов по тому же фильтру.
+//	POST /tasks/import   — создать задачу из списка ссылок (строки/CSV, в т.ч. файлом).
+//	GET  /tasks/{id}/files/{fid} — один файл задачи по его ID.
+//	GET  /tasks/{id}/files/{fid}/history — переходы состояний файла (core.FileEvent).
+//	POST /tasks/{id}/files/{fid}/skip — пропустить ждущий или упавший файл (SKIPPED, ?reason=…),
+//	                       чтобы задача завершилась без него; 409 — файл качается или завершён.
+//	GET  /tasks/{id}/files/{fid}/content — содержимое скачанного файла; вместо
+//	                       учётных данных — подписанная ссылка (?expires=…&sig=…).
+//	POST /tasks/{id}/files/{fid}/link — выдать подписанную ссылку на содержимое
+//	                       (?ttl=1h, не больше SIGNED_URL_MAX_TTL), см. registerContent.
+//	GET  /tasks/{id}/events — поток изменений задачи (Server-Sent Events).
+//	GET  /tasks/{id}/history — события жизненного цикла задачи (core.TaskHistory).
+//	POST /tasks/{id}/retry — перезапустить упавшие файлы задачи; возвращает {retried}.
+//	POST /tasks/{id}/verify — перепроверить DONE-файлы на диске (размер, суммы); пропавшие
+//	                       и повреждённые вернуть в очередь (?dry_run=true — только отчёт);
+//	                       возвращает app.VerifyResult.
+//	POST /tasks/{id}/clone — новая задача из ссылок существующей (?only_failed=true —
+//	                       только упавшие); возвращает {task_id, files, cloned_from}.
 //
 // Примечания:
+//   - при заданных API_KEYS /tasks* требуют ключ (Authorization: Bearer или X-API-Key);
+//   - при заданном ADMIN_LISTEN /admin/* и /debug/* сюда не монтируются —
+//     их обслуживает NewAdminRouter на отдельном адресе;
 //   - dest_dir (если задан) присоединяется под a.Conf.DownloadDir.
 //   - ошибки сериализуются в HTTP-коды/сообщения.
-//   - обработчик обёрнут в withRecover(mux) для защиты от паник.
+//   - обработчик обёрнут в withRecover(mux) для защиты от паник
+//     и в withLoaded — API задач недоступен, пока восстанавливается состояние;
+//   - ответы JSON/текст от 1 КБ сжимаются gzip, если клиент это допускает (withGzip);
+//   - у каждого запроса есть ID (X-Request-ID: из запроса или сгенерированный),
+//     он возвращается в ответе и сохраняется в созданных задачах (withRequestID).
 func NewRouter(a *app.App) http.Handler {
 	mux := http.NewServeMux()
 
 	// health
+	registerHealth(mux, a)
+
+	// admin (если не вынесена на отдельный слушатель ADMIN_LISTEN)
+	if !a.Conf.SeparateAdmin() {
+		registerAdmin(mux, a)
+	}
+
+	// tasks
+	mux.Handle("POST /tasks", withRole(a, auth.RoleSubmitter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dry, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dry {
+			handleDryRun(a, w, r)
+			return
+		}
+		wait, err := parseCreateWait(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := decodeTaskSpec(a, w, r)
+		if !ok {
+			return
+		}
+		req.Tenant = requestTenant(r)
+		req.APIKey = requestKeyID(r)
+		req.RequestID = requestID(r)
+		task, err := a.CreateTask(req)
+		if err != nil {
+			writeCreateError(w, errorLang(a, r), err)
+			return
+		}
+		snap, ok := waitTaskDone(a, r, task.ID, wait)
+		if !ok { // задачу успели удалить
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Location", "/tasks/"+url.PathEscape(snap.ID))
+		w.Header().Set("X-Task-Version", strconv.FormatUint(snap.Version, 10))
+		writeJSONStatus(w, http.StatusCreated, createdTask{TaskID: snap.ID, Task: snap.Redacted()})
+	})))
+	mux.Handle("GET /tasks", withRole(a, auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ndjson := acceptsNDJSON(r)
+		defLimit := 100
+		if ndjson {
+			defLimit = 0 // поток: по умолчанию все задачи
+		}
+		limit, _ := positiveInt(r, "limit", defLimit)
+		offset, _ := positiveInt(r, "offset", 0)
+		q, err := parseTaskQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tasks := a.QueryTasks(q)
+		if offset > len(tasks) {
+			offset = len(tasks)
+		}
+		end := offset + limit
+		if end > len(tasks) || ndjson && limit == 0 {
+			end = len(tasks)
+		}
+
+		if ndjson {
+			writeTasksNDJSON(w, r, a, tasks[offset:end])
+			return
+		}
+		snaps := a.TaskSnapshots(tasks[offset:end])
+		for i, t := range snaps {
+			snaps[i] = t.Redacted()
+		}
+		writeJSON(w, snaps)
+	})))
+	mux.Handle("GET /stats", withRole(a, auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		top, err := positiveInt(r, "top", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, a.Stats(top))
+	})))
+	// остальные методы: без этого обработчика ServeMux перенаправил бы /tasks на /tasks/
+	mux.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	// task by id (с long-polling: ?wait=30s&since_version=N)
+	mux.Handle("/tasks/", withRole(a, auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/tasks/")
+		if id == "" || strings.ContainsRune(id, '/') {
+			http.Error(w, "bad id", http.StatusBadRequest)
+			return
+		}
+		if !taskVisible(a, r, id) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		wait, since, err := parseLongPoll(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if wait > 0 && !waitTaskChange(a, r, id, since, wait) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		t, ok := a.TaskSnapshot(id)
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Task-Version", strconv.FormatUint(t.Version, 10))
+		if notModified(w, r, taskETag(t.Version)) {
+			return
+		}
+		writeJSON(w, t.Redacted())
+	})))
+
+	// изменение метаданных задачи
+	mux.Handle("PATCH /tasks/{id}", withRole(a, auth.RoleSubmitter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p core.TaskPatch
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			http.Error(w, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if p.Empty() {
+			http.Error(w, "nothing to update", http.StatusBadRequest)
+			return
+		}
+		t, err := a.PatchTask(r.PathValue("id"), p)
+		switch {
+		case errors.Is(err, app.ErrNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case err != nil:
+			writeCodedError(w, http.StatusBadRequest, errorLang(a, r), "invalid patch", err)
+		default:
+			writeJSON(w, t.Redacted())
+		}
+	})))
+
+	// удаление задачи вместе с файлами (общие blob'ы BLOB_DIR остаются другим задачам);
+	// при TRASH_RETENTION — перенос в корзину, ?purge=true — удалить сразу
+	mux.Handle("DELETE /tasks/{id}", withRole(a, auth.RoleOperator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		purge, _ := strconv.ParseBool(r.URL.Query().Get("purge"))
+		var (
+			res app.DeleteResult
+			err error
+		)
+		if purge || a.Conf.TrashRetention <= 0 {
+			res, err = a.DeleteTask(r.PathValue("id"))
+		} else {
+			res, err = a.TrashTask(r.PathValue("id"))
+		}
+		switch {
+		case errors.Is(err, app.ErrNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case err != nil:
+			http.Error(w, "delete failed: "+err.Error(), http.StatusInternalServerError)
+		default:
+			writeJSON(w, res)
+		}
+	})))
+
+	mux.Handle("POST /tasks/{id}/restore", withRole(a, auth.RoleOperator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, err := a.RestoreTask(r.PathValue("id"))
+		switch {
+		case errors.Is(err, app.ErrNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case errors.Is(err, app.ErrNotTrashed):
+			http.Error(w, "task is not in trash", http.StatusConflict)
+		default:
+			writeJSON(w, t.Redacted())
+		}
+	})))
+
+	// массовые операции по фильтру
+	mux.Handle("POST /tasks/retry-failed", withRole(a, auth.RoleOperator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleBulk(w, r, a.RetryTasks)
+	})))
+	mux.Handle("POST /tasks/cancel", withRole(a, auth.RoleOperator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleBulk(w, r, a.CancelTasks)
+	})))
+
+	// массовый импорт ссылок (text/plain, text/csv, multipart/form-data)
+	mux.Handle("POST /tasks/import", withRole(a, auth.RoleSubmitter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleImport(a, w, r)
+	})))
+
+	// пробный прогон задачи: валидация, будущие файлы и вердикты по ссылкам
+	mux.Handle("POST /tasks/validate", withRole(a, auth.RoleSubmitter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDryRun(a, w, r)
+	})))
+
+	// один файл задачи по стабильному ID
+	mux.Handle("GET /tasks/{id}/files/{fid}", withRole(a, auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, version, ok := a.FileSnapshot(r.PathValue("id"), r.PathValue("fid"))
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if notModified(w, r, taskETag(version)) {
+			return
+		}
+		writeJSON(w, f.Redacted())
+	})))
+
+	// пропуск файла: задача завершится без него
+	mux.Handle("POST /tasks/{id}/files/{fid}/skip", withRole(a, auth.RoleOperator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := a.SkipFile(r.PathValue("id"), r.PathValue("fid"), r.URL.Query().Get("reason"))
+		switch {
+		case errors.Is(err, app.ErrNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case errors.Is(err, app.ErrNotSkippable):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			writeJSON(w, f.Redacted())
+		}
+	})))
+
+	// содержимое файлов и подписанные ссылки на него
+	registerContent(mux, a)
+
+	// выгрузка задач и файлов в CSV
+	registerCSVExport(mux, a)
+
+	// история переходов состояний файла
+	mux.Handle("GET /tasks/{id}/files/{fid}/history", withRole(a, auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, ok := a.FileSnapshot(r.PathValue("id"), r.PathValue("fid"))
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		history := f.History
+		if history == nil {
+			history = []core.FileEvent{}
+		}
+		writeJSON(w, history)
+	})))
+
+	// история событий задачи (для разбора, почему задача стала PARTIAL/FAILED)
+	mux.Handle("GET /tasks/{id}/history", withRole(a, auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, ok := a.TaskHistory(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, h)
+	})))
+
+	// поток изменений задачи (Server-Sent Events)
+	mux.Handle("GET /tasks/{id}/events", withRole(a, auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleTaskEvents(a, w, r)
+	})))
+
+	// действия над задачей (шаблоны ServeMux Go 1.22+ точнее, чем "/tasks/")
+	mux.Handle("POST /tasks/{id}/retry", withRole(a, auth.RoleOperator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, ok := a.RetryFailed(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]int{"retried": n})
+	})))
+
+	mux.Handle("POST /tasks/{id}/verify", withRole(a, auth.RoleOperator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dry, err := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		if err != nil && r.URL.Query().Get("dry_run") != "" {
+			http.Error(w, "bad dry_run", http.StatusBadRequest)
+			return
+		}
+		res, err := a.VerifyTask(r.Context(), r.PathValue("id"), !dry)
+		switch {
+		case errors.Is(err, app.ErrNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case err != nil:
+			http.Error(w, "verify: "+err.Error(), http.StatusInternalServerError)
+		default:
+			writeJSON(w, res)
+		}
+	})))
+
+	mux.Handle("POST /tasks/{id}/clone", withRole(a, auth.RoleSubmitter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		onlyFailed, err := strconv.ParseBool(r.URL.Query().Get("only_failed"))
+		if err != nil && r.URL.Query().Get("only_failed") != "" {
+			http.Error(w, "bad only_failed", http.StatusBadRequest)
+			return
+		}
+		id := r.PathValue("id")
+		t, err := a.CloneTask(id, onlyFailed, requestID(r))
+		switch {
+		case errors.Is(err, app.ErrNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case errors.Is(err, app.ErrNoFailedFiles):
+			http.Error(w, "task has no failed files", http.StatusConflict)
+		case err != nil:
+			writeCreateError(w, errorLang(a, r), err)
+		default:
+			writeJSON(w, map[string]any{"task_id": t.ID, "files": len(t.Files), "cloned_from": id})
+		}
+	})))
+
+	return withRequestID(withGzip(withRecover(withLoaded(a, mux))))
+}
+
+// handleDryRun — POST /tasks/validate и POST /tasks?dry_run=true: тело
+// разбирается как у POST /tasks (decodeTaskBody), но ошибки валидации не дают
+// 400, а попадают в ответ app.DryRun (200) вместе с вердиктами по ссылкам.
+// ?probe=true — пробные запросы к ссылкам (не больше 1000 ссылок, иначе 400).
+func handleDryRun(a *app.App, w http.ResponseWriter, r *http.Request) {
+	probe, err := strconv.ParseBool(r.URL.Query().Get("probe"))
+	if err != nil && r.URL.Query().Get("probe") != "" {
+		http.Error(w, "bad probe", http.StatusBadRequest)
+		return
+	}
+	spec, ok := decodeTaskBody(a, w, r)
+	if !ok {
+		return
+	}
+	spec.Tenant = requestTenant(r)
+	res, err := a.DryRunTask(r.Context(), spec, probe)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lang := errorLang(a, r)
+	res.Localize(lang)
+	w.Header().Set("Content-Language", lang)
+	writeJSON(w, res)
+}
+
+// decodeTaskBody разбирает тело POST /tasks без проверки полей. Формат — по
+// Content-Type:
+//   - application/json (и любой другой тип) — core.TaskSpec, строго: неизвестные
+//     поля и мусор после JSON-объекта — 400 "bad json";
+//   - text/plain — по ссылке на строку, остальное — query-параметрами
+//     (decodeTaskText);
+//   - application/yaml (text/yaml, …) — манифест с теми же полями, что JSON
+//     (decodeTaskYAML);
+//   - application/x-www-form-urlencoded — поля формы links, label, … (decodeTaskForm);
+//     тело, которое начинается с "{", разбирается как JSON: так его отправляет
+//     curl -d без -H "Content-Type: application/json".
+//
+// Размер тела не больше maxTaskBody (иначе 413), ссылок не больше maxTaskLinks
+// (иначе 400 с ошибкой поля links). ok=false — ответ уже записан.
+func decodeTaskBody(a *app.App, w http.ResponseWriter, r *http.Request) (spec core.TaskSpec, ok bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTaskBody))
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return spec, false
+		}
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return spec, false
+	}
+	lang := errorLang(a, r)
+	var errs []core.FieldError
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "text/plain":
+		spec, errs = decodeTaskText(body, r.URL.Query())
+	case mediaType == "application/x-www-form-urlencoded" && !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")):
+		spec, errs, err = decodeTaskForm(body, r.URL.Query())
+		if err != nil {
+			http.Error(w, "bad form: "+err.Error(), http.StatusBadRequest)
+			return spec, false
+		}
+	case isYAML(mediaType):
+		if spec, _, err = decodeTaskYAML(body); err != nil {
+			http.Error(w, "bad yaml: "+err.Error(), http.StatusBadRequest)
+			return spec, false
+		}
+	default:
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&spec)
+		if err == nil && dec.More() {
+			err = errors.New("unexpected data after JSON object")
+		}
+		if err != nil {
+			http.Error(w, "bad json: "+err.Error(), http.StatusBadRequest)
+			return spec, false
+		}
+	}
+	if len(spec.Links) > maxTaskLinks {
+		errs = append(errs, core.NewFieldError("links", i18n.New(i18n.CodeTooManyLinks, len(spec.Links), maxTaskLinks)))
+	}
+	if len(errs) > 0 {
+		for i := range errs {
+			errs[i] = errs[i].Localize(lang)
+		}
+		w.Header().Set("Content-Language", lang)
+		writeJSONStatus(w, http.StatusBadRequest, map[string]any{
+			"error":  "validation failed",
+			"errors": errs,
+		})
+		return spec, false
+	}
+	return spec, true
+}
+
+// decodeTaskSpec строго разбирает тело POST /tasks (decodeTaskBody):
+//   - размер тела не больше maxTaskBody (иначе 413);
+//   - JSON, текст по ссылке на строку или форма — по Content-Type;
+//   - неизвестные поля и мусор после JSON-объекта — 400 "bad json";
+//   - не больше maxTaskLinks ссылок (крупные списки — через /tasks/import);
+//   - все поля проверяются core.TaskSpec.FieldErrors с allowlist хостов;
+//     при ошибках — 400 {"error", "errors": [core.FieldError…]} со всеми
+//     невалидными полями и ссылками (индекс, URL, код и причина), а не только
+//     первой; причины — на языке клиента (errorLang).
+//
+// ok=false — ответ уже записан.
+func decodeTaskSpec(a *app.App, w http.ResponseWriter, r *http.Request) (spec core.TaskSpec, ok bool) {
+	spec, ok = decodeTaskBody(a, w, r)
+	if !ok {
+		return spec, false
+	}
+	lang := errorLang(a, r)
+	if errs := spec.FieldErrors(a.Conf.HostAllowed); len(errs) > 0 {
+		for i := range errs {
+			errs[i] = errs[i].Localize(lang)
+		}
+		w.Header().Set("Content-Language", lang)
+		writeJSONStatus(w, http.StatusBadRequest, map[string]any{
+			"error":  "validation failed",
+			"errors": errs,
+		})
+		return spec, false
+	}
+	return spec, true
+}
+
+// requestTenant — арендатор аутентифицированного клиента (пусто для ключей API
+// и открытого API): новые задачи учитываются в его лимитах.
+func requestTenant(r *http.Request) string {
+	p, _ := auth.FromContext(r.Context())
+	return p.Tenant
+}
+
+// taskVisible сообщает, что задача id видна клиенту запроса: у клиента нет
+// арендатора либо задача его арендатора. Чужая (и несуществующая) задача для
+// клиента с арендатором — 404, как будто её нет.
+func taskVisible(a *app.App, r *http.Request, id string) bool {
+	tenant := requestTenant(r)
+	if tenant == "" {
+		return true
+	}
+	owner, ok := a.TaskTenant(id)
+	return ok && owner == tenant
+}
+
+// requestKeyID — отпечаток ключа API клиента (auth.KeyID) для учёта потребления;
+// пусто — клиент пришёл не с ключом API.
+func requestKeyID(r *http.Request) string {
+	p, _ := auth.FromContext(r.Context())
+	return p.KeyID
+}
+
+// errorLang — язык сообщений об ошибках для клиента: по Accept-Language,
+// без него (или без поддерживаемого языка в нём) — ERROR_LANG.
+func errorLang(a *app.App, r *http.Request) string {
+	return i18n.Negotiate(r.Header.Get("Accept-Language"), a.Conf.ErrorLang)
+}
+
+// writeCodedError отвечает status с телом {"error": kind, "code", "message"}:
+// code — стабильный код ошибки (i18n.Code, пусто — поля нет), message —
+// сообщение на языке lang.
+func writeCodedError(w http.ResponseWriter, status int, lang, kind string, err error) {
+	body := map[string]any{"error": kind, "message": i18n.Message(err, lang)}
+	if code := i18n.Code(err); code != "" {
+		body["code"] = code
+	}
+	w.Header().Set("Content-Language", lang)
+	writeJSONStatus(w, status, body)
+}
+
+// createdTask — ответ POST /tasks: задача целиком, как в GET /tasks/{id}
+// (имена файлов и ID файлов уже назначены), и task_id — для клиентов,
+// которые читают только его.
+type createdTask struct {
+	TaskID string `json:"task_id"`
+	*core.Task
+}
+
+// writeCreateError отвечает на ошибку создания задачи: превышение лимита
+// (*app.LimitError) — 429 с Retry-After (временные лимиты) или 422 (ссылок
+// в задаче больше links_per_task) и телом {"error", "code", "message", limit,
+// max, current, requested, tenant}; задача больше записи журнала
+// (store.ErrRecordTooLarge) — 413; прочие ошибки — 400 {"error": "invalid task",
+// "code", "message"}. Сообщения — на языке lang.
+func writeCreateError(w http.ResponseWriter, lang string, err error) {
+	if errors.Is(err, store.ErrRecordTooLarge) {
+		writeCodedError(w, http.StatusRequestEntityTooLarge, lang, "task too large", err)
+		return
+	}
+	var le *app.LimitError
+	if !errors.As(err, &le) {
+		writeCodedError(w, http.StatusBadRequest, lang, "invalid task", err)
+		return
+	}
+	code := http.StatusUnprocessableEntity
+	if le.Temporary() {
+		code = http.StatusTooManyRequests
+		if le.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(le.RetryAfter.Seconds())+1))
+		}
+	}
+	w.Header().Set("Content-Language", lang)
+	writeJSONStatus(w, code, map[string]any{
+		"error":     "limit exceeded",
+		"code":      le.ErrorCode(),
+		"message":   le.Localize(lang),
+		"limit":     le.Limit,
+		"max":       le.Max,
+		"current":   le.Current,
+		"requested": le.Requested,
+		"tenant":    le.Tenant,
+	})
+}
+
+// handleBulk декодирует app.TaskFilter из тела и выполняет массовую операцию op.
+func handleBulk(w http.ResponseWriter, r *http.Request, op func(app.TaskFilter) (app.BulkResult, error)) {
+	var filter app.TaskFilter
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&filter); err != nil {
+		http.Error(w, "bad json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.Tenant = requestTenant(r)
+	res, err := op(filter)
+	if err != nil {
+		http.Error(w, "invalid filter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, res)
+}
+
+// NewAdminRouter собирает маршрутизатор для отдельного админского слушателя
+// (ADMIN_LISTEN): /healthz, /readyz, /admin/* и /debug/pprof/*.
+func NewAdminRouter(a *app.App) http.Handler {
+	mux := http.NewServeMux()
+	registerHealth(mux, a)
+	registerAdmin(mux, a)
+	return withRequestID(withGzip(withRecover(mux)))
+}
+
+// registerHealth монтирует пробы: /healthz (процесс жив),
+// /readyz (состояние восстановлено, сервис не завершается; иначе 503) и
+// /healthz/details (app.HealthDetails; 503, если хоть одна проверка degraded).
+func registerHealth(mux *http.ServeMux, a *app.App) {
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
 	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !a.Ready() {
+			msg := "not ready"
+			switch rs := a.Recovery(); rs.Phase {
+			case app.RecoveryLoading:
+				msg = fmt.Sprintf("not ready: recovering, %.1f%% of WAL read", rs.Percent)
+			case app.RecoveryStandby:
+				msg = "not ready: standby, waiting for the leader lease"
+			}
+			http.Error(w, msg, http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ready"))
+	})
+	mux.HandleFunc("/healthz/details", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		h := a.HealthDetails()
+		status := http.StatusOK
+		if h.Status != app.HealthOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSONStatus(w, status, h)
+	})
+}
+
+// setHostPaused — общий обработчик POST /admin/hosts/{host}/pause|resume:
+// отвечает {host, paused, changed, paused_hosts}.
+func setHostPaused(a *app.App, w http.ResponseWriter, host string, pause bool) {
+	set := a.ResumeHost
+	if pause {
+		set = a.PauseHost
+	}
+	changed, err := set(host)
+	switch {
+	case errors.Is(err, app.ErrBadHost):
+		http.Error(w, "bad host", http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, "host mode changed but not persisted: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"host":         strings.ToLower(host),
+		"paused":       pause,
+		"changed":      changed,
+		"paused_hosts": a.PausedHosts(),
+	})
+}
 
-	// admin
-	mux.HandleFunc("/admin/drain", func(w http.ResponseWriter, r *http.Request) {
+// registerAdmin монтирует админские и отладочные эндпоинты в mux.
+func registerAdmin(mux *http.ServeMux, a *app.App) {
+	mux.Handle("/admin/drain", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "POST only", http.StatusMethodNotAllowed)
 			return
 		}
-		a.SetDrain(true)
+		if err := a.SetDrain(true); err != nil {
+			http.Error(w, "drain enabled but not persisted: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 		writeJSON(w, map[string]any{"drain": true})
-	})
-	mux.HandleFunc("/admin/resume", func(w http.ResponseWriter, r *http.Request) {
+	})))
+	mux.Handle("/admin/resume", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "POST only", http.StatusMethodNotAllowed)
 			return
 		}
-		a.SetDrain(false)
+		if err := a.SetDrain(false); err != nil {
+			http.Error(w, "drain disabled but not persisted: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 		writeJSON(w, map[string]any{"drain": false})
-	})
-
-	// tasks
-	mux.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPost:
-			var req struct {
-				Links   []string `json:"links"`
-				Label   string   `json:"label"`
-				DestDir string   `json:"dest_dir"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				http.Error(w, "bad json: "+err.Error(), http.StatusBadRequest)
-				return
-			}
-			if len(req.Links) == 0 {
-				http.Error(w, "links must be non-empty", http.StatusBadRequest)
-				return
-			}
-			task, err := core.NewTask(req.Label, req.DestDir, req.Links, a.Conf.Retries)
+	})))
+	mux.Handle("/admin/workers", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, a.WorkersReport())
+	})))
+	mux.Handle("/admin/hosts", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, a.HostStats())
+	})))
+	mux.Handle("POST /admin/hosts/{host}/pause", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setHostPaused(a, w, r.PathValue("host"), true)
+	})))
+	mux.Handle("POST /admin/hosts/{host}/resume", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setHostPaused(a, w, r.PathValue("host"), false)
+	})))
+	mux.Handle("GET /admin/tasks/{id}/export", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+".tar"))
+		sw := &startedWriter{w: w}
+		err := a.ExportTask(id, sw)
+		switch {
+		case err == nil:
+		case sw.started:
+			// заголовки и часть архива уже ушли: обрываем ответ, клиент получит битый tar
+			log.Printf("HTTP: export %s aborted: %v [request_id=%s]", id, err, requestID(r))
+			panic(http.ErrAbortHandler)
+		case errors.Is(err, app.ErrNotFound):
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "not found", http.StatusNotFound)
+		case errors.Is(err, app.ErrRemoteFile):
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "task files are stored in object storage, export is not supported", http.StatusConflict)
+		default:
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "export failed: "+err.Error(), http.StatusInternalServerError)
+		}
+	})))
+	mux.Handle("POST /admin/tasks/import", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := a.ImportTask(r.Body)
+		switch {
+		case errors.Is(err, app.ErrTaskExists):
+			http.Error(w, "task already exists", http.StatusConflict)
+		case errors.Is(err, store.ErrRecordTooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, bundle.ErrFormat):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err != nil:
+			http.Error(w, "import failed: "+err.Error(), http.StatusInternalServerError)
+		default:
+			writeJSON(w, res)
+		}
+	})))
+	mux.Handle("GET /admin/recovery", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, a.Recovery())
+	})))
+	mux.Handle("GET /admin/alerts", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"alerts": a.Alerts()})
+	})))
+	mux.Handle("GET /metrics", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", metrics.ContentType)
+		_ = a.WriteMetrics(w)
+	})))
+	mux.Handle("GET /admin/limits", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"limits": a.Limits(), "usage": a.LimitUsage()})
+	})))
+	mux.Handle("PATCH /admin/limits", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// поля, которых нет в теле, сохраняют текущие значения
+		l := a.Limits()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&l); err != nil {
+			http.Error(w, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := a.SetLimits(l); err != nil {
+			http.Error(w, "invalid limits: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, l)
+	})))
+	registerUsage(mux, a)
+	mux.Handle("GET /admin/store", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st, err := a.StoreStats()
+		if err != nil {
+			http.Error(w, "store stats: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, st)
+	})))
+	mux.Handle("POST /admin/store/compact", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cs, err := a.CompactStore()
+		if err != nil {
+			http.Error(w, "compaction failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, cs)
+	})))
+	mux.Handle("POST /admin/gc", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opts app.GCOptions
+		q := r.URL.Query()
+		if v := q.Get("dry_run"); v != "" {
+			dry, err := strconv.ParseBool(v)
 			if err != nil {
-				http.Error(w, "invalid task: "+err.Error(), http.StatusBadRequest)
+				http.Error(w, "bad dry_run", http.StatusBadRequest)
 				return
 			}
-			if task.DestDir == "" {
-				task.DestDir = filepath.Join(a.Conf.DownloadDir, task.ID)
-			} else {
-				task.DestDir = filepath.Join(a.Conf.DownloadDir, task.DestDir)
-			}
-			a.AddTask(task)
-			writeJSON(w, map[string]string{"task_id": task.ID})
-		case http.MethodGet:
-			limit, _ := positiveInt(r, "limit", 100)
-			offset, _ := positiveInt(r, "offset", 0)
-
-			tasks := a.ListTasks()
-			if offset > len(tasks) {
-				offset = len(tasks)
-			}
-			end := offset + limit
-			if end > len(tasks) {
-				end = len(tasks)
+			opts.DryRun = dry
+		}
+		if v := q.Get("min_age"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				http.Error(w, "bad min_age", http.StatusBadRequest)
+				return
 			}
-
-			writeJSON(w, tasks[offset:end])
-		default:
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			opts.MinAge = d
 		}
-	})
-
-	// task by id
-	mux.HandleFunc("/tasks/", func(w http.ResponseWriter, r *http.Request) {
+		rep, err := a.CollectGarbage(opts)
+		if err != nil {
+			http.Error(w, "gc failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, rep)
+	})))
+	mux.Handle("/admin/diagnostics", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
 			return
 		}
-		id := strings.TrimPrefix(r.URL.Path, "/tasks/")
-		if id == "" || strings.ContainsRune(id, '/') {
-			http.Error(w, "bad id", http.StatusBadRequest)
+		writeJSON(w, a.Diagnostics())
+	})))
+
+	// debug: pprof монтируем явно, а не через DefaultServeMux
+	mux.Handle("/debug/pprof/", withAdminAuth(a, http.HandlerFunc(pprof.Index)))
+	mux.Handle("/debug/pprof/cmdline", withAdminAuth(a, http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("/debug/pprof/profile", withAdminAuth(a, http.HandlerFunc(pprof.Profile)))
+	mux.Handle("/debug/pprof/symbol", withAdminAuth(a, http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("/debug/pprof/trace", withAdminAuth(a, http.HandlerFunc(pprof.Trace)))
+}
+
+// streamFlushEvery — через сколько строк потоковый ответ (NDJSON, CSV) сбрасывается клиенту.
+const streamFlushEvery = 64
+
+// acceptsNDJSON сообщает, что клиент просит список потоком NDJSON
+// (Accept: application/x-ndjson).
+func acceptsNDJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(mt), "application/x-ndjson") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTasksNDJSON пишет задачи потоком NDJSON — по одной на строку, без
+// отступов. Каждая задача сериализуется из своего снимка (App.TaskSnapshot),
+// так что в памяти не собирается ни массив, ни копии всех задач; удалённые
+// за время ответа задачи пропускаются. Ответ сбрасывается каждые
+// streamFlushEvery строк; отключение клиента прерывает поток.
+func writeTasksNDJSON(w http.ResponseWriter, r *http.Request, a *app.App, tasks []*core.Task) {
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for i, t := range tasks {
+		if r.Context().Err() != nil {
 			return
 		}
-		t, ok := a.GetTask(id)
+		snap, ok := a.TaskSnapshot(t.ID)
 		if !ok {
-			http.Error(w, "not found", http.StatusNotFound)
-			return
+			continue
 		}
-		writeJSON(w, t)
-	})
-
-	return withRecover(mux)
+		if err := enc.Encode(snap.Redacted()); err != nil {
+			return // клиент отключился
+		}
+		if (i+1)%streamFlushEvery == 0 {
+			_ = rc.Flush()
+		}
+	}
 }
 
 // writeJSON сериализует v в JSON с отступами и пишет в ответ,
@@ -135,12 +965,70 @@ func writeJSON(w http.ResponseWriter, v any) {
 	enc.Encode(v)
 }
 
+// writeJSONStatus — как writeJSON, но с явным HTTP-статусом.
+func writeJSONStatus(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// hostOf возвращает host (с портом) из rawURL или пустую строку.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// startedWriter отмечает, что в ответ начали писать тело (после этого
+// статус и заголовки уже не заменить ошибкой).
+type startedWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (s *startedWriter) Write(p []byte) (int, error) {
+	s.started = true
+	return s.w.Write(p)
+}
+
+// withLoaded — middleware для API задач: пока состояние не восстановлено
+// из WAL (app.App.Loaded), /tasks* отвечают 503 с Retry-After и ходом
+// восстановления, а не пустым или неполным списком задач. Пробы, админка
+// и метрики обслуживаются сразу.
+func withLoaded(a *app.App, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Loaded() || (r.URL.Path != "/tasks" && !strings.HasPrefix(r.URL.Path, "/tasks/")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", "5")
+		rs := a.Recovery()
+		msg := "recovering"
+		if rs.Phase == app.RecoveryStandby {
+			msg = "standby"
+		}
+		writeJSONStatus(w, http.StatusServiceUnavailable, map[string]any{
+			"error":    msg,
+			"recovery": rs,
+		})
+	})
+}
+
 // withRecover — middleware, которое перехватывает panic в обработчиках,
-// не даёт упасть всему серверу и возвращает 500 Internal Server Error.
+// не даёт упасть всему серверу и возвращает 500 Internal Server Error
+// (паника пишется в лог с ID запроса).
 func withRecover(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p) // намеренный обрыв ответа — пусть net/http закроет соединение
+				}
+				log.Printf("HTTP: panic in %s %s: %v [request_id=%s]", r.Method, r.URL.Path, p, requestID(r))
 				http.Error(w, fmt.Sprintf("internal error: %v", p), http.StatusInternalServerError)
 			}
 		}()
@@ -148,6 +1036,37 @@ func withRecover(next http.Handler) http.Handler {
 	})
 }
 
+// parseTaskQuery читает фильтры GET /tasks: status (без учёта регистра),
+// tag, created_from и created_to (RFC 3339 или дата YYYY-MM-DD, полуинтервал
+// [from, to)) и trash (only или all — задачи в корзине). Неизвестный статус,
+// неразборчивая дата или trash — ошибка.
+func parseTaskQuery(r *http.Request) (store.TaskQuery, error) {
+	v := r.URL.Query()
+	var q store.TaskQuery
+	status, err := core.ParseTaskStatus(v.Get("status"))
+	if err != nil {
+		return q, errors.New("bad status")
+	}
+	q.Status, q.Tag, q.Trash, q.Tenant = status, v.Get("tag"), v.Get("trash"), requestTenant(r)
+	if !store.ValidTrash(q.Trash) {
+		return q, errors.New("bad trash: want only or all")
+	}
+	for key, dst := range map[string]*time.Time{"created_from": &q.CreatedFrom, "created_to": &q.CreatedTo} {
+		s := v.Get(key)
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, s); err != nil {
+				return q, errors.New("bad " + key + ": want RFC 3339 or YYYY-MM-DD")
+			}
+		}
+		*dst = t
+	}
+	return q, nil
+}
+
 // positiveInt читает из query-параметров r значение по ключу key,
 // парсит его как неотрицательное целое и возвращает.
 // Если параметр отсутствует — возвращает def без ошибки.
@@ -0,0 +1,186 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Extrarius/29.09.2025/internal/app"
+	"github.com/Extrarius/29.09.2025/internal/auth"
+)
+
+// withAdminAuth пропускает запрос к next только при корректных
+// админских учётных данных.
+//
+// Проверки (каждая — только если настроена):
+//   - mTLS: при заданном a.Conf.TLSClientCA нужен клиентский сертификат,
+//     прошедший проверку этим CA;
+//   - HTTP Basic: a.Conf.AdminUser / a.Conf.AdminPassword;
+//   - вместо Basic — bearer-учётные данные API (ключ "key:admin" или JWT)
+//     с ролью admin; с меньшей ролью — 403.
+//
+// Если не настроено ни то, ни другое — эндпоинт закрыт полностью (403),
+// чтобы отладочные ручки не оказались открытыми «по умолчанию».
+// Сравнение паролей выполняется за постоянное время (subtle.ConstantTimeCompare).
+//
+// Неверные логин/пароль учитываются по IP клиента (app.App.AdminAuthFailed,
+// с записью в лог); после ADMIN_LOCKOUT_ATTEMPTS неудач клиент получает 429
+// с Retry-After до конца блокировки — даже с верным паролем.
+func withAdminAuth(a *app.App, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := clientIP(r)
+		if left, locked := a.AdminLocked(client); locked {
+			w.Header().Set("Retry-After", strconv.Itoa(int(left.Seconds())+1))
+			http.Error(w, "too many failed login attempts", http.StatusTooManyRequests)
+			return
+		}
+		if a.Conf.TLSClientCA != "" && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			http.Error(w, "client certificate required", http.StatusForbidden)
+			return
+		}
+		if a.Conf.APIAuthEnabled() && requestAPIKey(r) != "" {
+			p, ok := authenticate(a, w, r)
+			if !ok {
+				return
+			}
+			if !p.Role.AtLeast(auth.RoleAdmin) {
+				http.Error(w, "forbidden: admin role required", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+			return
+		}
+		if a.Conf.AdminPassword == "" && a.Conf.TLSClientCA == "" {
+			http.Error(w, "admin credentials not configured", http.StatusForbidden)
+			return
+		}
+		if a.Conf.AdminPassword == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, pass, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(a.Conf.AdminUser)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(a.Conf.AdminPassword)) == 1
+		if !ok || !userOK || !passOK {
+			if ok {
+				// запрос без заголовка Authorization — обычный первый шаг браузера, не перебор
+				a.AdminAuthFailed(client, user)
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		a.AdminAuthSucceeded(client)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRole аутентифицирует клиента API задач и пропускает запрос к next,
+// только если его роль не ниже need (иначе 403). Результат — auth.Principal
+// в контексте запроса (auth.FromContext).
+//
+// Роли по эндпоинтам (см. NewRouter):
+//   - viewer — GET: список, задача, файлы, история, события;
+//   - submitter — POST /tasks, /tasks/import, /tasks/{id}/clone, PATCH /tasks/{id};
+//   - operator — retry (/tasks/{id}/retry, /tasks/retry-failed) и /tasks/cancel;
+//   - admin — /admin/* и /debug/* (withAdminAuth).
+//
+// Без API_KEYS и JWT_ISSUER API открыт: клиент получает DefaultKeyRole
+// (всё API задач, как раньше), но не admin.
+//
+// Клиент с арендатором (claim JWT_TENANT_CLAIM) работает только со своими
+// задачами: маршрут с задачей {id} другого арендатора отвечает 404
+// (taskVisible), списки, выгрузки и массовые операции отбираются по
+// арендатору (parseTaskQuery, handleBulk).
+func withRole(a *app.App, need auth.Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := auth.Principal{Role: auth.DefaultKeyRole, Method: auth.MethodNone}
+		if a.Conf.APIAuthEnabled() {
+			var ok bool
+			if p, ok = authenticate(a, w, r); !ok {
+				return
+			}
+		}
+		if !p.Role.AtLeast(need) {
+			http.Error(w, "forbidden: "+string(need)+" role required", http.StatusForbidden)
+			return
+		}
+		r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+		if id := r.PathValue("id"); id != "" && !taskVisible(a, r, id) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate проверяет учётные данные из "Authorization: Bearer <…>" или
+// "X-API-Key: <key>":
+//   - статический ключ из a.Conf.APIKeys ("key" или "key:role"; сравнение
+//     со всеми ключами за постоянное время);
+//   - JWT издателя JWT_ISSUER (app.App.VerifyJWT) — роль и арендатор из claims;
+//     неверный токен логируется и отклоняется с 401 (error="invalid_token").
+//
+// ok=false — ответ 401 уже записан.
+func authenticate(a *app.App, w http.ResponseWriter, r *http.Request) (auth.Principal, bool) {
+	token := requestAPIKey(r)
+	if role, ok := apiKeyRole(a.Conf.APIKeys, token); ok {
+		return auth.Principal{Role: role, Method: auth.MethodAPIKey, KeyID: auth.KeyID(token)}, true
+	}
+	if a.Conf.JWTIssuer != "" && auth.LooksLikeJWT(token) {
+		p, err := a.VerifyJWT(r.Context(), token)
+		if err == nil {
+			return p, true
+		}
+		log.Printf("API auth: invalid token from %s: %v [request_id=%s]", clientIP(r), err, requestID(r))
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return auth.Principal{}, false
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+	return auth.Principal{}, false
+}
+
+// requestAPIKey извлекает ключ из Authorization: Bearer или X-API-Key.
+func requestAPIKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if key, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(key)
+		}
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// apiKeyRole сравнивает key со всеми ключами без раннего выхода,
+// чтобы время ответа не зависело от позиции совпавшего ключа,
+// и возвращает роль совпавшего ключа (auth.ParseAPIKey).
+func apiKeyRole(entries []string, key string) (auth.Role, bool) {
+	if key == "" {
+		return "", false
+	}
+	var role auth.Role
+	match := 0
+	for _, e := range entries {
+		k, r, err := auth.ParseAPIKey(e)
+		if err != nil {
+			continue // отвергнуто Config.Validate
+		}
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			match, role = 1, r
+		}
+	}
+	return role, match == 1
+}
+
+// clientIP — адрес клиента без порта (ключ учёта неудачных входов).
+// Для unix-сокетов RemoteAddr пуст или "@" — все такие клиенты учитываются вместе.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
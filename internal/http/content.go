@@ -0,0 +1,125 @@
+package httpapi
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/app"
+	"github.com/Extrarius/29.09.2025/internal/auth"
+)
+
+// registerContent монтирует отдачу скачанных файлов:
+//
+//	GET  /tasks/{id}/files/{fid}/content — содержимое файла (Range, If-Modified-Since);
+//	                       роль viewer либо подписанная ссылка (?expires=…&sig=…).
+//	POST /tasks/{id}/files/{fid}/link?ttl=1h — выдать подписанную ссылку на
+//	                       содержимое: {url, path, expires_at}. Ссылку можно
+//	                       передать третьей стороне — учётные данные API ей не нужны.
+//	                       url — от SIGNED_URL_BASE (не от заголовка Host запроса:
+//	                       его задаёт клиент); без него в ответе только path.
+func registerContent(mux *http.ServeMux, a *app.App) {
+	mux.Handle("GET /tasks/{id}/files/{fid}/content", withSignedURL(a, auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveFileContent(a, w, r)
+	})))
+
+	mux.Handle("POST /tasks/{id}/files/{fid}/link", withRole(a, auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ttl time.Duration
+		if s := r.URL.Query().Get("ttl"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				http.Error(w, "bad ttl", http.StatusBadRequest)
+				return
+			}
+			ttl = d
+		}
+		id, fid := r.PathValue("id"), r.PathValue("fid")
+		expires, sig, err := a.SignFileURL(id, fid, requestTenant(r), ttl)
+		switch {
+		case errors.Is(err, app.ErrSigningDisabled):
+			http.Error(w, "signed urls are not configured (SIGNED_URL_KEY)", http.StatusNotImplemented)
+			return
+		case errors.Is(err, app.ErrNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		q := url.Values{}
+		q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+		q.Set("sig", sig)
+		path := "/tasks/" + url.PathEscape(id) + "/files/" + url.PathEscape(fid) + "/content?" + q.Encode()
+		res := map[string]any{"path": path, "expires_at": expires.UTC()}
+		if base := a.Conf.SignedURLBase; base != "" {
+			res["url"] = strings.TrimRight(base, "/") + path
+		}
+		writeJSON(w, res)
+	})))
+}
+
+// withSignedURL пропускает запрос с параметром sig, если подписанная ссылка
+// действительна (app.App.VerifyFileURL): неверная подпись — 403, истёкшая — 410.
+// Запрос без sig проходит обычную проверку роли (withRole с need).
+func withSignedURL(a *app.App, need auth.Role, next http.Handler) http.Handler {
+	byRole := withRole(a, need, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if !q.Has("sig") {
+			byRole.ServeHTTP(w, r)
+			return
+		}
+		err := a.VerifyFileURL(r.PathValue("id"), r.PathValue("fid"), q.Get("expires"), q.Get("sig"))
+		switch {
+		case errors.Is(err, app.ErrLinkExpired):
+			http.Error(w, "link expired", http.StatusGone)
+		case err != nil:
+			http.Error(w, "invalid signature", http.StatusForbidden)
+		default:
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{Role: auth.RoleViewer, Method: auth.MethodSignedURL})))
+		}
+	})
+}
+
+// serveFileContent отдаёт файл через http.ServeContent (диапазоны, условные
+// запросы) с Content-Disposition: attachment и исходным именем файла.
+// Файл не скачан или записан в S3 (dest_dir "s3://…") — 409; удалён с диска
+// после загрузки — 410.
+func serveFileContent(a *app.App, w http.ResponseWriter, r *http.Request) {
+	f, err := a.FileContent(r.PathValue("id"), r.PathValue("fid"))
+	switch {
+	case errors.Is(err, app.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	case errors.Is(err, app.ErrFileNotReady):
+		http.Error(w, "file is not downloaded yet", http.StatusConflict)
+		return
+	case errors.Is(err, app.ErrRemoteFile):
+		http.Error(w, "file is stored in object storage: "+f.Path, http.StatusConflict)
+		return
+	}
+	file, err := os.Open(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "file is no longer on disk", http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, "open file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	st, err := file.Stat()
+	if err != nil || !st.Mode().IsRegular() {
+		http.Error(w, "file is not readable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Filename}))
+	w.Header().Set("Cache-Control", "private")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, f.Filename, st.ModTime(), file)
+}
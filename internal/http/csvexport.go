@@ -0,0 +1,227 @@
+package httpapi
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/app"
+	"github.com/Extrarius/29.09.2025/internal/auth"
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// registerCSVExport монтирует выгрузку задач и файлов в CSV (роль viewer):
+//
+//	GET /tasks/export.csv?columns=id,status,…&status=…&tag=…&created_from=…&created_to=…
+//	                       — задача на строку, те же фильтры, что у GET /tasks
+//	                       (?limit=&offset= — по умолчанию все задачи).
+//	GET /tasks/{id}/files.csv?columns=file_id,url,…
+//	                       — файлы задачи, файл на строку.
+//
+// Первая строка — заголовок с именами колонок в порядке ?columns=; без
+// параметра — колонки по умолчанию (defaultTaskColumns, defaultFileColumns).
+// Неизвестная колонка — 400 со списком доступных. Время — RFC 3339 в UTC,
+// теги — через ";".
+func registerCSVExport(mux *http.ServeMux, a *app.App) {
+	mux.Handle("GET /tasks/export.csv", withRole(a, auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cols, err := pickColumns(r, taskColumns, defaultTaskColumns)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q, err := parseTaskQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit, _ := positiveInt(r, "limit", 0)
+		offset, _ := positiveInt(r, "offset", 0)
+		tasks := a.QueryTasks(q)
+		offset = min(offset, len(tasks))
+		end := len(tasks)
+		if limit > 0 {
+			end = min(offset+limit, end)
+		}
+
+		cw := startCSV(w, "tasks.csv", cols)
+		for i, t := range tasks[offset:end] {
+			if r.Context().Err() != nil {
+				return
+			}
+			snap, ok := a.TaskSnapshot(t.ID) // задачу могли удалить за время выгрузки
+			if !ok {
+				continue
+			}
+			rec := make([]string, len(cols))
+			for j, c := range cols {
+				rec[j] = c.task(snap)
+			}
+			if cw.Write(rec) != nil {
+				return // клиент отключился
+			}
+			if (i+1)%streamFlushEvery == 0 {
+				cw.Flush()
+				_ = http.NewResponseController(w).Flush()
+			}
+		}
+		cw.Flush()
+	})))
+
+	mux.Handle("GET /tasks/{id}/files.csv", withRole(a, auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cols, err := pickColumns(r, fileColumns, defaultFileColumns)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		t, ok := a.TaskSnapshot(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		cw := startCSV(w, t.ID+"-files.csv", cols)
+		for _, f := range t.Files {
+			rec := make([]string, len(cols))
+			for j, c := range cols {
+				rec[j] = c.file(t, f)
+			}
+			_ = cw.Write(rec)
+		}
+		cw.Flush()
+	})))
+}
+
+// csvColumn — колонка выгрузки: у колонок задач задан task, у колонок файлов — file.
+type csvColumn struct {
+	name string
+	task func(t *core.Task) string
+	file func(t *core.Task, f *core.FileItem) string
+}
+
+// Колонки по умолчанию (?columns= не задан).
+const (
+	defaultTaskColumns = "id,label,status,created_at,total,done,failed,total_bytes_downloaded"
+	defaultFileColumns = "file_id,url,filename,state,attempts,bytes_downloaded,finished_at,path,error"
+)
+
+// taskColumns — колонки GET /tasks/export.csv в порядке списка доступных.
+var taskColumns = []csvColumn{
+	{name: "id", task: func(t *core.Task) string { return t.ID }},
+	{name: "label", task: func(t *core.Task) string { return t.Label }},
+	{name: "tags", task: func(t *core.Task) string { return strings.Join(t.Tags, ";") }},
+	{name: "status", task: func(t *core.Task) string { return string(t.Status) }},
+	{name: "priority", task: func(t *core.Task) string { return strconv.Itoa(t.Priority) }},
+	{name: "created_at", task: func(t *core.Task) string { return csvTime(&t.CreatedAt) }},
+	{name: "dest_dir", task: func(t *core.Task) string { return t.DestDir }},
+	{name: "sink", task: func(t *core.Task) string { return t.Sink }},
+	{name: "tenant", task: func(t *core.Task) string { return t.Tenant }},
+	{name: "api_key", task: func(t *core.Task) string { return t.APIKey }},
+	{name: "request_id", task: func(t *core.Task) string { return t.RequestID }},
+	{name: "cloned_from", task: func(t *core.Task) string { return t.ClonedFrom }},
+	{name: "total", task: func(t *core.Task) string { return strconv.Itoa(t.Total) }},
+	{name: "done", task: func(t *core.Task) string { return strconv.Itoa(t.Done) }},
+	{name: "failed", task: func(t *core.Task) string { return strconv.Itoa(t.Failed) }},
+	{name: "pending", task: func(t *core.Task) string { return strconv.Itoa(t.Pending) }},
+	{name: "running", task: func(t *core.Task) string { return strconv.Itoa(t.Running) }},
+	{name: "cancelled", task: func(t *core.Task) string { return strconv.Itoa(t.Cancelled) }},
+	{name: "skipped", task: func(t *core.Task) string { return strconv.Itoa(t.Skipped) }},
+	{name: "expired", task: func(t *core.Task) string { return strconv.Itoa(t.Expired) }},
+	{name: "retries_total", task: func(t *core.Task) string { return strconv.Itoa(t.Retries) }},
+	{name: "total_bytes_expected", task: func(t *core.Task) string { return strconv.FormatInt(t.TotalBytesExpected, 10) }},
+	{name: "total_bytes_downloaded", task: func(t *core.Task) string { return strconv.FormatInt(t.TotalBytesDownloaded, 10) }},
+	{name: "progress_percent", task: func(t *core.Task) string { return strconv.FormatFloat(t.ProgressPercent, 'f', 1, 64) }},
+	{name: "finished_at", task: func(t *core.Task) string { return csvTime(lastFinished(t)) }},
+}
+
+// fileColumns — колонки GET /tasks/{id}/files.csv.
+var fileColumns = []csvColumn{
+	{name: "task_id", file: func(t *core.Task, _ *core.FileItem) string { return t.ID }},
+	{name: "file_id", file: func(_ *core.Task, f *core.FileItem) string { return f.ID }},
+	{name: "url", file: func(_ *core.Task, f *core.FileItem) string { return f.URL }},
+	{name: "host", file: func(_ *core.Task, f *core.FileItem) string { return f.Host }},
+	{name: "filename", file: func(_ *core.Task, f *core.FileItem) string { return f.Filename }},
+	{name: "dest_subpath", file: func(_ *core.Task, f *core.FileItem) string { return f.DestSubpath }},
+	{name: "state", file: func(_ *core.Task, f *core.FileItem) string { return string(f.State) }},
+	{name: "attempts", file: func(_ *core.Task, f *core.FileItem) string { return strconv.Itoa(f.Attempts) }},
+	{name: "max_attempts", file: func(_ *core.Task, f *core.FileItem) string { return strconv.Itoa(f.MaxAttempts) }},
+	{name: "bytes_downloaded", file: func(_ *core.Task, f *core.FileItem) string { return strconv.FormatInt(f.BytesDownloaded, 10) }},
+	{name: "size_hint", file: func(_ *core.Task, f *core.FileItem) string { return strconv.FormatInt(f.SizeHint, 10) }},
+	{name: "started_at", file: func(_ *core.Task, f *core.FileItem) string { return csvTime(f.StartedAt) }},
+	{name: "finished_at", file: func(_ *core.Task, f *core.FileItem) string { return csvTime(f.FinishedAt) }},
+	{name: "path", file: func(_ *core.Task, f *core.FileItem) string { return f.Path }},
+	{name: "checksum", file: func(_ *core.Task, f *core.FileItem) string { return f.Checksum }},
+	{name: "blob", file: func(_ *core.Task, f *core.FileItem) string { return f.Blob }}, // sha256 содержимого при BLOB_DIR
+	{name: "upload_state", file: func(_ *core.Task, f *core.FileItem) string {
+		if f.Upload == nil {
+			return ""
+		}
+		return f.Upload.State
+	}},
+	{name: "upload_location", file: func(_ *core.Task, f *core.FileItem) string {
+		if f.Upload == nil {
+			return ""
+		}
+		return f.Upload.Location
+	}},
+	{name: "error", file: func(_ *core.Task, f *core.FileItem) string { return f.Error }},
+}
+
+// pickColumns разбирает ?columns= (через запятую; пусто — def) по таблице all.
+func pickColumns(r *http.Request, all []csvColumn, def string) ([]csvColumn, error) {
+	spec := r.URL.Query().Get("columns")
+	if spec == "" {
+		spec = def
+	}
+	var cols []csvColumn
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		i := 0
+		for i < len(all) && all[i].name != name {
+			i++
+		}
+		if i == len(all) {
+			names := make([]string, len(all))
+			for j, c := range all {
+				names[j] = c.name
+			}
+			return nil, fmt.Errorf("unknown column %q (available: %s)", name, strings.Join(names, ","))
+		}
+		cols = append(cols, all[i])
+	}
+	return cols, nil
+}
+
+// startCSV выставляет заголовки ответа-вложения filename и пишет строку
+// с именами колонок.
+func startCSV(w http.ResponseWriter, filename string, cols []csvColumn) *csv.Writer {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	cw := csv.NewWriter(w)
+	head := make([]string, len(cols))
+	for i, c := range cols {
+		head[i] = c.name
+	}
+	_ = cw.Write(head)
+	return cw
+}
+
+// csvTime — время в RFC 3339 (UTC); nil — пустая строка.
+func csvTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// lastFinished — когда завершился последний файл задачи (nil — ни один).
+func lastFinished(t *core.Task) *time.Time {
+	var last *time.Time
+	for _, f := range t.Files {
+		if f.FinishedAt != nil && (last == nil || f.FinishedAt.After(*last)) {
+			last = f.FinishedAt
+		}
+	}
+	return last
+}
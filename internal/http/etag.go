@@ -0,0 +1,41 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// taskETag строит ETag ресурса задачи по её версии (core.Task.Version).
+// Версия растёт при любом изменении задачи и её файлов, поэтому тот же ETag
+// годится и для вложенных ресурсов (GET /tasks/{id}/files/{fid}).
+func taskETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// notModified выставляет ETag и, если он совпал с If-None-Match запроса,
+// отвечает 304 Not Modified без тела и возвращает true.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache") // кэш обязан перепроверять ресурс
+	if !etagMatch(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatch разбирает If-None-Match: список через запятую или "*".
+// Сравнение слабое (RFC 9110, 13.1.2): префикс W/ игнорируется.
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/app"
+)
+
+const (
+	eventsPollInterval = 500 * time.Millisecond // как часто проверять изменения задачи
+	eventsHeartbeat    = 15 * time.Second       // комментарий-пинг, чтобы прокси не рвали соединение
+)
+
+// handleTaskEvents — GET /tasks/{id}/events: поток Server-Sent Events с состоянием задачи.
+//
+// Протокол:
+//   - "event: task" + JSON задачи — сразу после подключения и при каждом изменении;
+//   - "event: done" — задача перешла в конечный статус, сервер закрывает поток;
+//   - строки ": ping" раз в eventsHeartbeat — поддержание соединения.
+//
+// Изменения обнаруживаются опросом снимка задачи (App.TaskSnapshot) раз в
+// eventsPollInterval. Поток завершается также при отключении клиента и при
+// остановке сервиса (Ready() == false), чтобы не задерживать graceful shutdown.
+func handleTaskEvents(a *app.App, w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := a.TaskSnapshot(id); !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	poll := time.NewTicker(eventsPollInterval)
+	defer poll.Stop()
+	lastWrite := time.Now()
+	var last []byte
+	for {
+		t, ok := a.TaskSnapshot(id)
+		if !ok {
+			return
+		}
+		data, err := json.Marshal(t.Redacted())
+		if err != nil {
+			return
+		}
+		switch {
+		case !bytes.Equal(data, last):
+			last = data
+			fmt.Fprintf(w, "event: task\ndata: %s\n\n", data)
+			lastWrite = time.Now()
+		case time.Since(lastWrite) >= eventsHeartbeat:
+			fmt.Fprint(w, ": ping\n\n")
+			lastWrite = time.Now()
+		}
+		if t.Status.Terminal() {
+			fmt.Fprint(w, "event: done\ndata: {}\n\n")
+			_ = rc.Flush()
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-poll.C:
+		}
+		if !a.Ready() {
+			return
+		}
+	}
+}
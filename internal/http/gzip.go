@@ -0,0 +1,176 @@
+package httpapi
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// gzipMinSize — ответы короче не сжимаются: выигрыш меньше накладных расходов.
+	gzipMinSize = 1024
+	// gzipLevel — BestSpeed: списки задач сжимаются и так в разы, а CPU сервиса
+	// нужнее загрузкам, чем лишним процентам степени сжатия.
+	gzipLevel = gzip.BestSpeed
+)
+
+// gzipTypes — типы содержимого, которые имеет смысл сжимать. Потоки
+// (text/event-stream) и бинарные данные (профили pprof) не сжимаются.
+var gzipTypes = map[string]bool{
+	"application/json":     true,
+	"application/x-ndjson": true,
+	"text/plain":           true,
+	"text/csv":             true,
+}
+
+var gzipPool = sync.Pool{New: func() any {
+	w, _ := gzip.NewWriterLevel(nil, gzipLevel)
+	return w
+}}
+
+// withGzip — middleware сжатия ответов.
+//
+// Ответ сжимается, только если одновременно:
+//   - клиент прислал Accept-Encoding с gzip (и не с q=0);
+//   - Content-Type ответа — из gzipTypes;
+//   - тело не короче gzipMinSize (первые байты буферизуются до решения);
+//   - статус допускает тело и ответ ещё не закодирован обработчиком;
+//   - ответ не поддерживает диапазоны (Accept-Ranges — файлы из
+//     http.ServeContent отдаются как есть, иначе сломались бы Range-запросы).
+//
+// Flush до набора порога (SSE, long-polling) принимает решение сразу.
+// Заголовок Vary: Accept-Encoding ставится всегда — для корректной работы кэшей.
+func withGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip разбирает Accept-Encoding: "gzip" или "*" без q=0.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter копит начало ответа до gzipMinSize, затем решает,
+// сжимать ли его, и дальше пишет либо через gzip.Writer, либо напрямую.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.decided {
+		return // как и net/http: повторный WriteHeader игнорируется
+	}
+	g.status = code
+	if !bodyAllowed(code) {
+		g.decide(false)
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !g.decided {
+		g.buf = append(g.buf, p...)
+		if len(g.buf) < gzipMinSize {
+			return len(p), nil
+		}
+		if err := g.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	return g.ResponseWriter.Write(p)
+}
+
+// Flush нужен потоковым обработчикам (SSE): принимает решение о сжатии,
+// не дожидаясь порога, и проталкивает данные клиенту.
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		g.decide(true)
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+// Unwrap даёт http.ResponseController доступ к исходному ResponseWriter.
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter { return g.ResponseWriter }
+
+// decide отправляет заголовки и накопленный буфер. big — тело достаточно
+// велико (или размер неизвестен) для сжатия; остальные условия проверяются здесь.
+func (g *gzipResponseWriter) decide(big bool) error {
+	g.decided = true
+	h := g.Header()
+	if h.Get("Content-Type") == "" && len(g.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(g.buf))
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if big && bodyAllowed(g.status) && gzipTypes[mediaType] && h.Get("Content-Encoding") == "" && h.Get("Accept-Ranges") == "" {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.gz = gzipPool.Get().(*gzip.Writer)
+		g.gz.Reset(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+	if len(g.buf) == 0 {
+		return nil
+	}
+	var err error
+	if g.gz != nil {
+		_, err = g.gz.Write(g.buf)
+	} else {
+		_, err = g.ResponseWriter.Write(g.buf)
+	}
+	g.buf = nil
+	return err
+}
+
+// close дописывает короткий (несжатый) ответ или закрывает gzip-поток.
+func (g *gzipResponseWriter) close() {
+	if !g.decided {
+		if len(g.buf) == 0 && g.status == http.StatusOK {
+			return // обработчик ничего не написал — net/http ответит 200 сам
+		}
+		g.decide(false)
+	}
+	if g.gz != nil {
+		g.gz.Close()
+		gzipPool.Put(g.gz)
+		g.gz = nil
+	}
+}
+
+// bodyAllowed сообщает, может ли ответ с кодом status иметь тело.
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,341 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/app"
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+const (
+	maxImportBody   = 64 << 20 // лимит тела запроса импорта
+	maxImportLinks  = 100_000  // лимит ссылок в одной задаче импорта
+	maxImportErrors = 1000     // сколько ошибок строк возвращать максимум
+)
+
+// lineError — ошибка валидации одной строки импорта.
+type lineError struct {
+	Line  int    `json:"line"`
+	Value string `json:"value,omitempty"`
+	Code  string `json:"code,omitempty"` // код ошибки (i18n.Code*)
+	Error string `json:"error"`
+}
+
+// importResult — накопленный результат разбора: валидные ссылки и ошибки строк.
+type importResult struct {
+	lang      string // язык сообщений об ошибках строк (errorLang)
+	specs     []core.LinkSpec
+	errors    []lineError
+	invalid   int
+	truncated bool
+}
+
+func (res *importResult) fail(line int, value string, err error) {
+	res.invalid++
+	if len(res.errors) >= maxImportErrors {
+		res.truncated = true
+		return
+	}
+	res.errors = append(res.errors, lineError{Line: line, Value: value, Code: i18n.Code(err), Error: i18n.Message(err, res.lang)})
+}
+
+// handleImport — POST /tasks/import: создание задачи из потенциально очень
+// большого списка ссылок.
+//
+// Форматы тела:
+//   - text/plain — по ссылке на строку (пустые строки и "#…" пропускаются);
+//   - text/csv — колонки url,filename,checksum; первая строка с "url" в первой
+//     колонке считается заголовком и задаёт порядок колонок (в заголовке
+//     допустимы также dest_subpath и max_attempts);
+//   - application/yaml — манифест задачи, как у POST /tasks (decodeTaskYAML):
+//     поля задачи из манифеста важнее query-параметров, ошибки — по строкам
+//     документа;
+//   - multipart/form-data — поле-файл (CSV, если имя *.csv или тип text/csv,
+//     иначе строки) плюс необязательные поля label, dest_dir и layout.
+//
+// label, dest_dir, layout, duplicates (политика повторов URL), notify_email,
+// notify (уведомители через запятую) и expires_at (срок задачи, RFC 3339) также
+// принимаются query-параметрами. Тело разбирается
+// потоково; каждая строка валидируется (URL, контрольная сумма, allowlist хостов).
+// Если есть хоть одна ошибка — задача не создаётся, ответ 400 со списком
+// ошибок по строкам (не более maxImportErrors).
+func handleImport(a *app.App, w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBody)
+	q := r.URL.Query()
+	spec := core.TaskSpec{Label: q.Get("label"), DestDir: q.Get("dest_dir"), Layout: q.Get("layout"), Duplicates: q.Get("duplicates"), NotifyEmail: q.Get("notify_email")}
+	spec.Notify = splitValues(q["notify"])
+	if s := q.Get("expires_at"); s != "" {
+		at, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "bad import: expires_at: expected an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		spec.ExpiresAt = &at
+	}
+
+	res := &importResult{lang: errorLang(a, r)}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var err error
+	switch mediaType {
+	case "multipart/form-data":
+		err = parseImportMultipart(a, r, res, &spec)
+	case "text/csv":
+		err = parseImportCSV(a, r.Body, res)
+	case "text/plain", "":
+		err = parseImportLines(a, r.Body, res)
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		err = parseImportYAML(a, r.Body, res, &spec)
+	default:
+		http.Error(w, "unsupported content type: "+mediaType, http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "bad import: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if res.invalid > 0 {
+		w.Header().Set("Content-Language", res.lang)
+		writeJSONStatus(w, http.StatusBadRequest, map[string]any{
+			"error":     "validation failed",
+			"valid":     len(res.specs),
+			"invalid":   res.invalid,
+			"errors":    res.errors,
+			"truncated": res.truncated,
+		})
+		return
+	}
+	if len(res.specs) == 0 {
+		http.Error(w, "links must be non-empty", http.StatusBadRequest)
+		return
+	}
+
+	spec.Links = res.specs
+	spec.Tenant = requestTenant(r)
+	spec.APIKey = requestKeyID(r)
+	spec.RequestID = requestID(r)
+	task, err := a.CreateTask(spec)
+	if err != nil {
+		writeCreateError(w, res.lang, err)
+		return
+	}
+	writeJSON(w, map[string]any{"task_id": task.ID, "files": len(task.Files)})
+}
+
+// parseImportMultipart потоково обходит части multipart-формы:
+// текстовые поля label/dest_dir/layout (в spec) и файл со ссылками (ровно один).
+func parseImportMultipart(a *app.App, r *http.Request, res *importResult, spec *core.TaskSpec) error {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	files := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if part.FileName() == "" {
+			val, err := io.ReadAll(io.LimitReader(part, 4096))
+			if err != nil {
+				return err
+			}
+			switch part.FormName() {
+			case "label":
+				spec.Label = string(val)
+			case "dest_dir":
+				spec.DestDir = string(val)
+			case "layout":
+				spec.Layout = string(val)
+			}
+			continue
+		}
+		files++
+		if files > 1 {
+			return errors.New("ожидается один файл со ссылками")
+		}
+		ct, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if ct == "text/csv" || strings.EqualFold(filepath.Ext(part.FileName()), ".csv") {
+			err = parseImportCSV(a, part, res)
+		} else {
+			err = parseImportLines(a, part, res)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if files == 0 {
+		return errors.New("в форме нет файла со ссылками")
+	}
+	return nil
+}
+
+// parseImportLines читает по ссылке на строку.
+func parseImportLines(a *app.App, body io.Reader, res *importResult) error {
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if err := addImportSpec(a, res, line, core.LinkSpec{URL: text}); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// parseImportYAML читает манифест задачи YAML: поля задачи, заданные в нём,
+// заменяют query-параметры в spec, ссылки проверяются по одной с номером строки.
+func parseImportYAML(a *app.App, body io.Reader, res *importResult, spec *core.TaskSpec) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m, lines, err := decodeTaskYAML(data)
+	if err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&spec.Label, m.Label}, {&spec.DestDir, m.DestDir}, {&spec.Layout, m.Layout}, {&spec.Sink, m.Sink},
+		{&spec.Duplicates, m.Duplicates}, {&spec.NotifyEmail, m.NotifyEmail},
+	} {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+	spec.Tags, spec.Priority, spec.Sequential = m.Tags, m.Priority, m.Sequential
+	if m.Notify != nil {
+		spec.Notify = m.Notify
+	}
+	if m.Login != nil {
+		spec.Login = m.Login
+	}
+	if m.ExpiresAt != nil {
+		spec.ExpiresAt = m.ExpiresAt
+	}
+	if m.PartialRetry != nil {
+		spec.PartialRetry = m.PartialRetry
+	}
+	for i, l := range m.Links {
+		if err := addImportSpec(a, res, lines[i], l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseImportCSV читает CSV с колонками url,filename,checksum (по умолчанию в этом порядке).
+// Заголовок задаёт порядок и может добавить dest_subpath и max_attempts;
+// неизвестная колонка в заголовке — ошибка.
+func parseImportCSV(a *app.App, body io.Reader, res *importResult) error {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+	cols := map[string]int{"url": 0, "filename": 1, "checksum": 2}
+	first := true
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				res.fail(perr.Line, "", perr.Err)
+				continue
+			}
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+		if first {
+			first = false
+			if strings.EqualFold(strings.TrimSpace(rec[0]), "url") {
+				cols = map[string]int{}
+				for i, name := range rec {
+					name = strings.ToLower(strings.TrimSpace(name))
+					switch name {
+					case "url", "filename", "checksum", "dest_subpath", "max_attempts":
+						cols[name] = i
+					default:
+						return fmt.Errorf("строка %d: неизвестная колонка %q", line, name)
+					}
+				}
+				continue
+			}
+		}
+		field := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		spec := core.LinkSpec{
+			URL:         field("url"),
+			Filename:    field("filename"),
+			Checksum:    field("checksum"),
+			DestSubpath: field("dest_subpath"),
+		}
+		if spec.URL == "" {
+			continue
+		}
+		if v := field("max_attempts"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				res.fail(line, spec.URL, i18n.New(i18n.CodeNotANumber, "max_attempts", v))
+				continue
+			}
+			spec.MaxAttempts = n
+		}
+		if err := addImportSpec(a, res, line, spec); err != nil {
+			return err
+		}
+	}
+}
+
+// addImportSpec валидирует ссылку и добавляет её в результат либо записывает ошибку строки.
+// Возвращает ошибку только при превышении maxImportLinks.
+func addImportSpec(a *app.App, res *importResult, line int, spec core.LinkSpec) error {
+	if len(res.specs)+res.invalid >= maxImportLinks {
+		return fmt.Errorf("слишком много ссылок (максимум %d)", maxImportLinks)
+	}
+	if err := spec.Validate(); err != nil {
+		res.fail(line, spec.URL, err)
+		return nil
+	}
+	if host := hostOf(spec.URL); !a.Conf.HostAllowed(host) {
+		res.fail(line, spec.URL, i18n.New(i18n.CodeHostNotAllowed, host))
+		return nil
+	}
+	if ip := core.ConnectHost(spec.ConnectTo); ip != "" && !a.Conf.HostAllowed(ip) {
+		res.fail(line, spec.URL, i18n.New(i18n.CodeHostNotAllowed, ip))
+		return nil
+	}
+	res.specs = append(res.specs, spec)
+	return nil
+}
@@ -0,0 +1,122 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/app"
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+const (
+	longPollMaxWait  = 60 * time.Second       // верхняя граница ?wait=
+	longPollInterval = 250 * time.Millisecond // как часто сверять версию задачи
+)
+
+// parseLongPoll читает параметры long-polling из query:
+//   - wait — сколько ждать изменения ("30s", "1m" или число секунд), не больше longPollMaxWait;
+//   - since_version — версия задачи, которую клиент уже видел (core.Task.Version).
+//
+// Без since_version ожидание не включается (wait=0): сравнивать не с чем.
+func parseLongPoll(r *http.Request) (wait time.Duration, since uint64, err error) {
+	q := r.URL.Query()
+	sv := q.Get("since_version")
+	if sv == "" {
+		return 0, 0, nil
+	}
+	if since, err = strconv.ParseUint(sv, 10, 64); err != nil {
+		return 0, 0, errors.New("bad since_version")
+	}
+	ws := q.Get("wait")
+	if ws == "" {
+		return 0, since, nil
+	}
+	if n, err := strconv.Atoi(ws); err == nil {
+		wait = time.Duration(n) * time.Second
+	} else if wait, err = time.ParseDuration(ws); err != nil {
+		return 0, 0, errors.New("bad wait")
+	}
+	if wait < 0 {
+		return 0, 0, errors.New("bad wait")
+	}
+	return min(wait, longPollMaxWait), since, nil
+}
+
+// waitTaskChange блокирует, пока версия задачи id равна since, не дольше wait.
+// Выходит раньше при отключении клиента и при остановке сервиса (Ready() == false).
+// Версия сверяется опросом App.TaskVersion — дёшево, без снятия снимка задачи.
+// Сравнение «не равно», а не «больше»: клиент с чужой или устаревшей версией
+// получает ответ сразу. Возвращает false, если задачи нет.
+func waitTaskChange(a *app.App, r *http.Request, id string, since uint64, wait time.Duration) bool {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	poll := time.NewTicker(longPollInterval)
+	defer poll.Stop()
+	for {
+		v, ok := a.TaskVersion(id)
+		if !ok {
+			return false
+		}
+		if v != since || !a.Ready() {
+			return true
+		}
+		select {
+		case <-r.Context().Done():
+			return true
+		case <-deadline.C:
+			return true
+		case <-poll.C:
+		}
+	}
+}
+
+// createWaitMax — верхняя граница ожидания POST /tasks?wait=: синхронный
+// режим задуман для небольших задач, долгие ждут через GET /tasks/{id}.
+const createWaitMax = 5 * time.Minute
+
+// parseCreateWait читает ?wait= у POST /tasks: "true" — ждать до
+// createWaitMax, "30s", "1m" или число секунд — не дольше указанного (и не
+// дольше createWaitMax); пусто или "false" — не ждать.
+func parseCreateWait(r *http.Request) (time.Duration, error) {
+	ws := r.URL.Query().Get("wait")
+	switch ws {
+	case "", "false", "0":
+		return 0, nil
+	case "true", "1":
+		return createWaitMax, nil
+	}
+	var wait time.Duration
+	if n, err := strconv.Atoi(ws); err == nil {
+		wait = time.Duration(n) * time.Second
+	} else if wait, err = time.ParseDuration(ws); err != nil {
+		return 0, errors.New("bad wait")
+	}
+	if wait < 0 {
+		return 0, errors.New("bad wait")
+	}
+	return min(wait, createWaitMax), nil
+}
+
+// waitTaskDone блокирует, пока задача id не дойдёт до конечного статуса, не
+// дольше wait (wait=0 — не ждать), и возвращает её снимок: конечный или
+// последний на момент выхода. Каждое изменение задачи ждёт waitTaskChange,
+// так что выходит раньше при отключении клиента и остановке сервиса.
+// Возвращает false, если задачи нет.
+func waitTaskDone(a *app.App, r *http.Request, id string, wait time.Duration) (*core.Task, bool) {
+	deadline := time.Now().Add(wait)
+	for {
+		t, ok := a.TaskSnapshot(id)
+		if !ok {
+			return nil, false
+		}
+		left := time.Until(deadline)
+		if t.Status.Terminal() || left <= 0 || r.Context().Err() != nil || !a.Ready() {
+			return t, true
+		}
+		if !waitTaskChange(a, r, id, t.Version, left) {
+			return nil, false
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	// requestIDHeader — заголовок с ID запроса: принимается от клиента
+	// (или прокси) и возвращается в каждом ответе.
+	requestIDHeader = "X-Request-ID"
+	// maxRequestID — наибольшая длина ID из запроса; длиннее — генерируется свой.
+	maxRequestID = 128
+)
+
+// requestIDKey — ключ контекста запроса с его ID (withRequestID).
+type requestIDKey struct{}
+
+// withRequestID — внешнее middleware: берёт ID запроса из X-Request-ID
+// (если он есть и годится — см. validRequestID) или генерирует новый,
+// возвращает его в заголовке ответа X-Request-ID и кладёт в контекст запроса
+// (requestID). Созданные запросом задачи хранят его в Task.RequestID — так он
+// попадает в логи воркеров и в события (EVENTS_URL).
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID возвращает ID запроса (withRequestID); вне middleware — пусто.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID принимает непустой ID не длиннее maxRequestID из видимых
+// ASCII-символов: он пишется в логи и JSON как есть.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID — случайный ID запроса: 16 hex-символов.
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,122 @@
+package httpapi
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+	"github.com/Extrarius/29.09.2025/internal/i18n"
+)
+
+// Создание задачи без JSON — из браузера, закладки или одной строкой в shell:
+//
+//	curl --data-binary @urls.txt -H 'Content-Type: text/plain' '…/tasks?label=nightly&tags=a,b'
+//	curl -d links=https://example.com/a.iso -d label=iso …/tasks
+//
+// Поля задачи (поля формы или query-параметры): label, dest_dir, layout, sink,
+// duplicates, notify_email, priority, sequential (true/false), expires_at
+// (RFC 3339), tags, depends_on и notify (через запятую, можно повторять). Ссылки — только URL: имя
+// файла, контрольная сумма и заголовки задаются в JSON.
+
+// decodeTaskText разбирает тело text/plain: по ссылке на строку, пустые
+// строки и "#…" пропускаются; поля задачи — из query.
+func decodeTaskText(body []byte, query url.Values) (core.TaskSpec, []core.FieldError) {
+	var links []string
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 0, 64*1024), maxTaskBody)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			links = append(links, line)
+		}
+	}
+	return taskSpecFromValues(links, query)
+}
+
+// decodeTaskForm разбирает тело application/x-www-form-urlencoded: ссылки —
+// поле links (можно повторять; в одном поле — по ссылке на строку, как из
+// <textarea>), поля задачи — из формы, а чего в форме нет — из query.
+func decodeTaskForm(body []byte, query url.Values) (core.TaskSpec, []core.FieldError, error) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return core.TaskSpec{}, nil, err
+	}
+	var links []string
+	for _, v := range form["links"] {
+		for _, line := range strings.Split(v, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				links = append(links, line)
+			}
+		}
+	}
+	for k, v := range query {
+		if _, ok := form[k]; !ok {
+			form[k] = v
+		}
+	}
+	spec, errs := taskSpecFromValues(links, form)
+	return spec, errs, nil
+}
+
+// taskSpecFromValues собирает core.TaskSpec из ссылок links и полей задачи v.
+// Нечисловой priority, sequential не true/false и expires_at не в RFC 3339 —
+// ошибки полей (остальное проверит TaskSpec.FieldErrors).
+func taskSpecFromValues(links []string, v url.Values) (core.TaskSpec, []core.FieldError) {
+	spec := core.TaskSpec{
+		Label:   v.Get("label"),
+		DestDir: v.Get("dest_dir"),
+		Layout:  v.Get("layout"),
+		Sink:    v.Get("sink"),
+
+		Duplicates:  v.Get("duplicates"),
+		NotifyEmail: v.Get("notify_email"),
+		Links:       make([]core.LinkSpec, len(links)),
+	}
+	for i, l := range links {
+		spec.Links[i] = core.LinkSpec{URL: l}
+	}
+	spec.Tags = splitValues(v["tags"])
+	spec.DependsOn = splitValues(v["depends_on"])
+	spec.Notify = splitValues(v["notify"])
+	var errs []core.FieldError
+	if p := v.Get("priority"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			errs = append(errs, core.NewFieldError("priority", i18n.New(i18n.CodeNotANumber, "priority", p)))
+		}
+		spec.Priority = n
+	}
+	if s := v.Get("sequential"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			errs = append(errs, core.NewFieldError("sequential", i18n.New(i18n.CodeNotABool, "sequential", s)))
+		}
+		spec.Sequential = b
+	}
+	if s := v.Get("expires_at"); s != "" {
+		at, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			errs = append(errs, core.NewFieldError("expires_at", i18n.New(i18n.CodeNotATime, "expires_at", s)))
+		} else {
+			spec.ExpiresAt = &at
+		}
+	}
+	return spec, errs
+}
+
+// splitValues разбирает повторяемое поле со значениями через запятую;
+// пустые значения пропускаются.
+func splitValues(vals []string) []string {
+	var out []string
+	for _, v := range vals {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package httpapi
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/app"
+)
+
+// registerUsage монтирует отчёт о потреблении (только админ):
+//
+//	GET /admin/usage?from=2025-09-01&to=2025-09-30&group=day|tenant|task&tenant=…&api_key=…
+//	                       — задачи, попытки, файлы, неудачи и байты по арендаторам
+//	                       и ключам API (app.UsageReport). ?format=csv (или
+//	                       Accept: text/csv) — те же строки в CSV для биллинга.
+func registerUsage(mux *http.ServeMux, a *app.App) {
+	mux.Handle("GET /admin/usage", withAdminAuth(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		rep, err := a.Usage(app.UsageQuery{
+			From:   q.Get("from"),
+			To:     q.Get("to"),
+			Tenant: q.Get("tenant"),
+			APIKey: q.Get("api_key"),
+			Group:  q.Get("group"),
+		})
+		if errors.Is(err, app.ErrBadUsageQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "usage report: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		switch format := q.Get("format"); {
+		case format == "csv", format == "" && strings.Contains(r.Header.Get("Accept"), "text/csv"):
+			writeUsageCSV(w, rep)
+		case format == "", format == "json":
+			writeJSON(w, rep)
+		default:
+			http.Error(w, "bad format: expected json or csv", http.StatusBadRequest)
+		}
+	})))
+}
+
+// writeUsageCSV пишет строки отчёта в CSV: первая строка — заголовок, колонки
+// зависят от группировки (day — день, task — задача, статус и время создания).
+func writeUsageCSV(w http.ResponseWriter, rep app.UsageReport) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=%q", "usage-"+rep.Group+"-"+rep.From+"-"+rep.To+".csv"))
+	cw := csv.NewWriter(w)
+	var head []string
+	switch rep.Group {
+	case app.UsageByDay:
+		head = append(head, "day")
+	case app.UsageByTask:
+		head = append(head, "task_id", "status", "created_at")
+	}
+	_ = cw.Write(append(head, "tenant", "api_key", "tasks", "requests", "files", "failures", "bytes"))
+	for _, row := range rep.Rows {
+		var rec []string
+		switch rep.Group {
+		case app.UsageByDay:
+			rec = append(rec, row.Day)
+		case app.UsageByTask:
+			rec = append(rec, row.TaskID, string(row.Status), row.CreatedAt.UTC().Format(time.RFC3339))
+		}
+		_ = cw.Write(append(rec, row.Tenant, row.APIKey,
+			strconv.FormatInt(row.Tasks, 10),
+			strconv.FormatInt(row.Requests, 10),
+			strconv.FormatInt(row.Files, 10),
+			strconv.FormatInt(row.Failures, 10),
+			strconv.FormatInt(row.Bytes, 10)))
+	}
+	cw.Flush()
+}
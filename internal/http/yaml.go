@@ -0,0 +1,78 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// Манифест задачи в YAML — то же, что JSON-тело POST /tasks, но удобнее
+// писать руками: с комментариями и без кавычек.
+//
+//	label: nightly            # метка
+//	tags: [mirror, iso]
+//	links:
+//	  - https://example.com/a.iso
+//	  - url: https://example.com/b.iso
+//	    checksum: sha256:9f86d0…
+//
+// Документ переводится в JSON и разбирается тем же строгим декодером
+// (core.TaskSpec, core.LinkSpec): неизвестные поля — ошибка, ссылки — строки
+// или объекты.
+
+// isYAML сообщает, что mediaType — YAML.
+func isYAML(mediaType string) bool {
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	}
+	return false
+}
+
+// decodeTaskYAML разбирает манифест задачи YAML. lines — номер строки
+// документа для каждой ссылки spec.Links (для ошибок импорта). Пустой
+// документ — пустая спецификация; больше одного документа — ошибка.
+func decodeTaskYAML(data []byte) (spec core.TaskSpec, lines []int, err error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); err == io.EOF {
+		return spec, nil, nil
+	} else if err != nil {
+		return spec, nil, err
+	}
+	var extra yaml.Node
+	if err := dec.Decode(&extra); err != io.EOF {
+		return spec, nil, errors.New("expected a single YAML document")
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return spec, nil, errors.New("expected a mapping with task fields")
+	}
+	var v any
+	if err := root.Decode(&v); err != nil {
+		return spec, nil, err
+	}
+	js, err := json.Marshal(v)
+	if err != nil {
+		return spec, nil, err
+	}
+	jd := json.NewDecoder(bytes.NewReader(js))
+	jd.DisallowUnknownFields()
+	if err := jd.Decode(&spec); err != nil {
+		return spec, nil, errors.New(strings.TrimPrefix(err.Error(), "json: "))
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if key, val := root.Content[i], root.Content[i+1]; key.Value == "links" && val.Kind == yaml.SequenceNode {
+			for _, item := range val.Content {
+				lines = append(lines, item.Line)
+			}
+		}
+	}
+	return spec, lines, nil
+}
@@ -0,0 +1,180 @@
+package i18n
+
+// Коды ошибок. Код — часть API: не переименовывается, новые только добавляются.
+const (
+	// задача и ссылки (core)
+	CodeEmptyLinks          = "empty_links"
+	CodeTooManyLinks        = "too_many_links"
+	CodeInvalidURL          = "invalid_url"
+	CodeUnsupportedScheme   = "unsupported_scheme"
+	CodeHostNotAllowed      = "host_not_allowed"
+	CodeOutOfRange          = "out_of_range"
+	CodeDestSubpathAbsolute = "dest_subpath_absolute"
+	CodeDestSubpathParent   = "dest_subpath_parent"
+	CodeHeaderNameEmpty     = "header_name_empty"
+	CodeHeaderNameInvalid   = "header_name_invalid"
+	CodeHeaderForbidden     = "header_forbidden"
+	CodeHeaderValueInvalid  = "header_value_invalid"
+	CodeHostHeaderInvalid   = "host_header_invalid"
+	CodeConnectToInvalid    = "connect_to_invalid"
+	CodeChecksumUndetected  = "checksum_algorithm_undetected"
+	CodeChecksumUnknown     = "checksum_algorithm_unknown"
+	CodeChecksumInvalidHex  = "checksum_invalid_hex"
+	CodeLayoutInvalid       = "layout_invalid"
+	CodeLabelTooLong        = "label_too_long"
+	CodeTooManyTags         = "too_many_tags"
+	CodeTagEmpty            = "tag_empty"
+	CodeTagTooLong          = "tag_too_long"
+	CodeTagControlChars     = "tag_control_chars"
+	CodeStatusUnknown       = "status_unknown"
+	CodeNotANumber          = "not_a_number"
+	CodeNotABool            = "not_a_bool"
+	CodeNotATime            = "not_a_time"
+	CodeDependsTooMany      = "depends_on_too_many"
+	CodeDependsEmpty        = "depends_on_empty"
+	CodeDuplicatesInvalid   = "duplicates_invalid"
+	CodeLoginMethodInvalid  = "login_method_invalid"
+	CodeLoginFormInvalid    = "login_form_invalid"
+	CodeLoginCookieInvalid  = "login_cookie_invalid"
+	CodeSignatureURLInvalid = "signature_url_invalid"
+	CodeNotifyEmailInvalid  = "notify_email_invalid"
+	CodeNotifyInvalid       = "notify_invalid"
+	CodeExpiresPast         = "expires_at_past"
+	CodePartialRetryAfter   = "partial_retry_after_invalid"
+	CodeRangeInvalid        = "range_invalid"
+
+	// создание задачи (app)
+	CodeSinkUnknown     = "sink_unknown"
+	CodeSinkObjectDest  = "sink_object_dest"
+	CodeObjectStoreOff  = "object_storage_disabled"
+	CodeObjectDestDir   = "object_dest_dir_invalid"
+	CodeLimitLinks      = "limit_links_per_task"
+	CodeLimitPending    = "limit_pending_files_per_tenant"
+	CodeLimitTasksHour  = "limit_tasks_per_hour"
+	CodeLimitExceeded   = "limit_exceeded"
+	CodeDownloadUnknown = "download_unknown"
+	CodeDependsUnknown  = "depends_on_unknown"
+	CodeDuplicateURL    = "duplicate_url"
+	CodeDuplicateRecent = "duplicate_recent"
+	CodeSignatureNoKeys = "signature_no_keyring"
+	CodeNotifyNoSMTP    = "notify_email_no_smtp"
+	CodeNotifierUnknown = "notify_unknown"
+)
+
+// catalog — шаблоны сообщений: язык → код → шаблон fmt.
+var catalog = map[string]map[string]string{
+	RU: {
+		CodeEmptyLinks:          "пустой список ссылок",
+		CodeTooManyLinks:        "слишком много ссылок: %d (максимум %d, большие списки — через /tasks/import)",
+		CodeInvalidURL:          "некорректная ссылка: %q",
+		CodeUnsupportedScheme:   "схема %q не поддерживается (ожидается http или https)",
+		CodeHostNotAllowed:      "хост не разрешён: %s",
+		CodeOutOfRange:          "%s: ожидается %d..%d, получено %d",
+		CodeDestSubpathAbsolute: "dest_subpath: ожидается относительный путь, получено %q",
+		CodeDestSubpathParent:   "dest_subpath: \"..\" не допускается: %q",
+		CodeHeaderNameEmpty:     "headers: пустое имя заголовка",
+		CodeHeaderNameInvalid:   "headers: некорректное имя заголовка %q",
+		CodeHeaderForbidden:     "headers: заголовок %q задавать нельзя",
+		CodeHeaderValueInvalid:  "headers: недопустимые символы в значении %q",
+		CodeHostHeaderInvalid:   "host_header: ожидается хост[:порт], получено %q",
+		CodeConnectToInvalid:    "connect_to: ожидается IP-адрес[:порт], получено %q",
+		CodeChecksumUndetected:  "контрольная сумма %q: не удалось определить алгоритм",
+		CodeChecksumUnknown:     "контрольная сумма %q: неизвестный алгоритм %q",
+		CodeChecksumInvalidHex:  "контрольная сумма %q: некорректный hex для %s",
+		CodeLayoutInvalid:       "layout: ожидается %q или %q, получено %q",
+		CodeLabelTooLong:        "label: не длиннее %d символов",
+		CodeTooManyTags:         "tags: не больше %d тегов, получено %d",
+		CodeTagEmpty:            "tags: пустой тег",
+		CodeTagTooLong:          "tags: тег %q длиннее %d символов",
+		CodeTagControlChars:     "tags: тег %q содержит управляющие символы",
+		CodeStatusUnknown:       "неизвестный статус %q",
+		CodeNotANumber:          "%s: ожидается число, получено %q",
+		CodeNotABool:            "%s: ожидается true или false, получено %q",
+		CodeNotATime:            "%s: ожидается время в формате RFC 3339 (2006-01-02T15:04:05Z), получено %q",
+		CodeDependsTooMany:      "depends_on: не больше %d задач, получено %d",
+		CodeDependsEmpty:        "depends_on: пустой ID задачи",
+		CodeDuplicatesInvalid:   "duplicates: ожидается %s, %s или %s, получено %q",
+		CodeLoginMethodInvalid:  "login.method: ожидается GET или POST, получено %q",
+		CodeLoginFormInvalid:    "login.form: не больше %d полей, имена непустые",
+		CodeLoginCookieInvalid:  "login.cookie: недопустимое имя cookie %q",
+		CodeSignatureURLInvalid: "signature: ожидается http(s)-ссылка на подпись, получено %q",
+		CodeNotifyEmailInvalid:  "notify_email: ожидается от 1 до %d адресов через запятую, получено %q",
+		CodeNotifyInvalid:       "notify: не больше %d уведомителей, имена непустые и без пробелов",
+		CodeExpiresPast:         "expires_at: срок %s уже прошёл",
+		CodePartialRetryAfter:   "partial_retry.after: ожидается длительность не меньше 1m (например 12h), получено %q",
+		CodeRangeInvalid:        "range: ожидается offset >= 0 и length >= 0, не оба нулевые, получено %d+%d",
+
+		CodeSinkUnknown:     "sink: неизвестное хранилище %q",
+		CodeSinkObjectDest:  "sink: выгрузка доступна только задачам с локальным dest_dir",
+		CodeObjectStoreOff:  "dest_dir: объектное хранилище не настроено (AWS_ACCESS_KEY_ID, S3_*)",
+		CodeObjectDestDir:   "dest_dir: ожидается s3://bucket[/prefix], получено %q",
+		CodeLimitLinks:      "слишком много ссылок в задаче: %d (максимум %d)",
+		CodeLimitPending:    "превышен лимит незавершённых файлов арендатора: в работе %d, задача добавляет %d (максимум %d)",
+		CodeLimitTasksHour:  "превышен лимит задач в час: создано %d (максимум %d)",
+		CodeLimitExceeded:   "превышен лимит %s",
+		CodeDownloadUnknown: "неизвестная ошибка при скачивании",
+		CodeDependsUnknown:  "depends_on: задача %s не найдена",
+		CodeSignatureNoKeys: "signature: ключи для проверки подписей не настроены (SIGNATURE_KEYRINGS)",
+		CodeNotifyNoSMTP:    "notify_email: отправка почты не настроена (SMTP_URL)",
+		CodeNotifierUnknown: "notify: неизвестный уведомитель %q",
+		CodeDuplicateURL:    "links[%d]: повторяет ссылку links[%d] (%s)",
+		CodeDuplicateRecent: "ссылка %s уже есть в задаче %s",
+	},
+	EN: {
+		CodeEmptyLinks:          "empty list of links",
+		CodeTooManyLinks:        "too many links: %d (maximum %d, use /tasks/import for large lists)",
+		CodeInvalidURL:          "invalid URL: %q",
+		CodeUnsupportedScheme:   "scheme %q is not supported (expected http or https)",
+		CodeHostNotAllowed:      "host is not allowed: %s",
+		CodeOutOfRange:          "%s: expected %d..%d, got %d",
+		CodeDestSubpathAbsolute: "dest_subpath: expected a relative path, got %q",
+		CodeDestSubpathParent:   "dest_subpath: \"..\" is not allowed: %q",
+		CodeHeaderNameEmpty:     "headers: empty header name",
+		CodeHeaderNameInvalid:   "headers: invalid header name %q",
+		CodeHeaderForbidden:     "headers: header %q cannot be set",
+		CodeHeaderValueInvalid:  "headers: invalid characters in the value of %q",
+		CodeHostHeaderInvalid:   "host_header: expected host[:port], got %q",
+		CodeConnectToInvalid:    "connect_to: expected an IP address[:port], got %q",
+		CodeChecksumUndetected:  "checksum %q: cannot detect the algorithm",
+		CodeChecksumUnknown:     "checksum %q: unknown algorithm %q",
+		CodeChecksumInvalidHex:  "checksum %q: invalid hex for %s",
+		CodeLayoutInvalid:       "layout: expected %q or %q, got %q",
+		CodeLabelTooLong:        "label: at most %d characters",
+		CodeTooManyTags:         "tags: at most %d tags, got %d",
+		CodeTagEmpty:            "tags: empty tag",
+		CodeTagTooLong:          "tags: tag %q is longer than %d characters",
+		CodeTagControlChars:     "tags: tag %q contains control characters",
+		CodeStatusUnknown:       "unknown status %q",
+		CodeNotANumber:          "%s: expected a number, got %q",
+		CodeNotABool:            "%s: expected true or false, got %q",
+		CodeNotATime:            "%s: expected an RFC 3339 time (2006-01-02T15:04:05Z), got %q",
+		CodeDependsTooMany:      "depends_on: at most %d tasks, got %d",
+		CodeDependsEmpty:        "depends_on: empty task ID",
+		CodeDuplicatesInvalid:   "duplicates: expected %s, %s or %s, got %q",
+		CodeLoginMethodInvalid:  "login.method: expected GET or POST, got %q",
+		CodeLoginFormInvalid:    "login.form: at most %d fields, names must not be empty",
+		CodeLoginCookieInvalid:  "login.cookie: invalid cookie name %q",
+		CodeSignatureURLInvalid: "signature: expected an http(s) link to the signature, got %q",
+		CodeNotifyEmailInvalid:  "notify_email: expected 1 to %d comma-separated addresses, got %q",
+		CodeNotifyInvalid:       "notify: at most %d notifiers, names must be non-empty and contain no spaces",
+		CodeExpiresPast:         "expires_at: %s is already in the past",
+		CodePartialRetryAfter:   "partial_retry.after: expected a duration of at least 1m (e.g. 12h), got %q",
+		CodeRangeInvalid:        "range: expected offset >= 0 and length >= 0, not both zero, got %d+%d",
+
+		CodeSinkUnknown:     "sink: unknown storage %q",
+		CodeSinkObjectDest:  "sink: uploads are only available for tasks with a local dest_dir",
+		CodeObjectStoreOff:  "dest_dir: object storage is not configured (AWS_ACCESS_KEY_ID, S3_*)",
+		CodeObjectDestDir:   "dest_dir: expected s3://bucket[/prefix], got %q",
+		CodeLimitLinks:      "too many links in the task: %d (maximum %d)",
+		CodeLimitPending:    "tenant's unfinished files limit exceeded: %d in progress, the task adds %d (maximum %d)",
+		CodeLimitTasksHour:  "tasks per hour limit exceeded: %d created (maximum %d)",
+		CodeLimitExceeded:   "limit %s exceeded",
+		CodeDownloadUnknown: "unknown download error",
+		CodeDependsUnknown:  "depends_on: task %s not found",
+		CodeSignatureNoKeys: "signature: no keys for signature verification are configured (SIGNATURE_KEYRINGS)",
+		CodeNotifyNoSMTP:    "notify_email: e-mail delivery is not configured (SMTP_URL)",
+		CodeNotifierUnknown: "notify: unknown notifier %q",
+		CodeDuplicateURL:    "links[%d]: duplicates links[%d] (%s)",
+		CodeDuplicateRecent: "link %s is already in task %s",
+	},
+}
@@ -0,0 +1,121 @@
+// Package i18n — коды ошибок, которые сервис отдаёт клиентам API, и каталог
+// их сообщений на нескольких языках.
+//
+// Ошибка с кодом (*Error) хранит код и аргументы сообщения, а текст
+// собирается по каталогу в момент ответа — на языке клиента (Accept-Language)
+// или сервиса (ERROR_LANG). Error() — текст на русском, как исторически
+// писал сервис (логи, WAL, ошибки вне HTTP).
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Поддерживаемые языки сообщений.
+const (
+	RU = "ru" // по умолчанию
+	EN = "en"
+)
+
+// Languages — поддерживаемые языки в порядке предпочтения по умолчанию.
+var Languages = []string{RU, EN}
+
+// Supported сообщает, есть ли каталог сообщений на языке lang.
+func Supported(lang string) bool {
+	_, ok := catalog[lang]
+	return ok
+}
+
+// Error — ошибка с кодом из каталога: Code стабилен и предназначен для
+// программ, текст — для людей. Args подставляются в шаблон сообщения
+// (шаблоны всех языков принимают одни и те же аргументы в одном порядке).
+type Error struct {
+	Code string
+	Args []any
+}
+
+// New возвращает ошибку с кодом code и аргументами сообщения args.
+func New(code string, args ...any) *Error { return &Error{Code: code, Args: args} }
+
+// Error — сообщение на русском (RU).
+func (e *Error) Error() string { return e.Localize(RU) }
+
+// ErrorCode — код ошибки (Coder).
+func (e *Error) ErrorCode() string { return e.Code }
+
+// Localize — сообщение на языке lang; нет шаблона на этом языке — на русском,
+// нет и его — сам код.
+func (e *Error) Localize(lang string) string {
+	format, ok := catalog[lang][e.Code]
+	if !ok {
+		if format, ok = catalog[RU][e.Code]; !ok {
+			return e.Code
+		}
+	}
+	return fmt.Sprintf(format, e.Args...)
+}
+
+// Coder — ошибка со стабильным кодом.
+type Coder interface {
+	error
+	ErrorCode() string
+}
+
+// Localizer — ошибка, сообщение которой можно получить на нужном языке.
+type Localizer interface {
+	error
+	Localize(lang string) string
+}
+
+// Message — сообщение ошибки err на языке lang: если err (сама, не обёрнутая —
+// у обёртки свой текст) умеет Localize, иначе err.Error().
+func Message(err error, lang string) string {
+	if l, ok := err.(Localizer); ok {
+		return l.Localize(lang)
+	}
+	return err.Error()
+}
+
+// Code — код ошибки err (в том числе обёрнутой); пусто — ошибка без кода.
+func Code(err error) string {
+	var c Coder
+	if errors.As(err, &c) {
+		return c.ErrorCode()
+	}
+	return ""
+}
+
+// Negotiate выбирает язык сообщений по заголовку Accept-Language
+// ("en-US,en;q=0.9,ru;q=0.8"): поддерживаемый язык с наибольшим весом
+// (по основному подтегу: "en-US" → "en"). Подходящего нет — fallback.
+func Negotiate(acceptLanguage, fallback string) string {
+	type choice struct {
+		lang string
+		q    float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if q > 0 && Supported(primary) {
+			choices = append(choices, choice{primary, q})
+		}
+	}
+	if len(choices) == 0 {
+		return fallback
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	return choices[0].lang
+}
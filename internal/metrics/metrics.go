@@ -0,0 +1,174 @@
+// Package metrics — минимальная подсистема метрик: счётчики, гистограммы
+// и вычисляемые датчики, отдаваемые в текстовом формате Prometheus
+// (exposition format 0.0.4) без внешних зависимостей.
+//
+// Метрики создаются владельцем (диспетчер очереди, приложение) и регистрируются
+// в Registry под уникальным именем; Registry.WriteText выводит их в порядке
+// регистрации. Меток (labels) нет: каждая метрика — один ряд.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ContentType — заголовок Content-Type ответа с метриками (Registry.WriteText).
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Готовые наборы верхних границ корзин гистограмм.
+var (
+	// WaitBuckets — ожидание в очереди: от миллисекунд до часа, секунды.
+	WaitBuckets = []float64{0.001, 0.01, 0.1, 0.5, 1, 5, 15, 60, 300, 900, 3600}
+	// LatencyBuckets — короткие операции под блокировкой, секунды.
+	LatencyBuckets = []float64{0.00001, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1}
+	// SizeBuckets — размеры пачек (штук).
+	SizeBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+)
+
+// Counter — монотонный счётчик. Нулевое значение готово к работе.
+type Counter struct{ v atomic.Uint64 }
+
+// Inc увеличивает счётчик на 1.
+func (c *Counter) Inc() { c.v.Add(1) }
+
+// Add увеличивает счётчик на n.
+func (c *Counter) Add(n uint64) { c.v.Add(n) }
+
+// Value возвращает текущее значение.
+func (c *Counter) Value() uint64 { return c.v.Load() }
+
+// Histogram — гистограмма с фиксированными верхними границами корзин
+// (как histogram в Prometheus: корзины кумулятивные, плюс сумма и число наблюдений).
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64 // по корзинам, не кумулятивно; последняя — +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram создаёт гистограмму с верхними границами bounds
+// (по возрастанию; +Inf добавляется сама).
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// Observe учитывает одно наблюдение v.
+func (h *Histogram) Observe(v float64) {
+	i := 0
+	for i < len(h.bounds) && v > h.bounds[i] {
+		i++
+	}
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+// ObserveDuration учитывает длительность d в секундах.
+func (h *Histogram) ObserveDuration(d time.Duration) { h.Observe(d.Seconds()) }
+
+// Count возвращает число наблюдений.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// entry — зарегистрированная метрика.
+type entry struct {
+	name, help, kind string
+	write            func(w *bufio.Writer, name string)
+}
+
+// Registry — набор метрик для отдачи на GET /metrics.
+type Registry struct {
+	mu      sync.Mutex
+	entries []entry
+	names   map[string]bool
+}
+
+// NewRegistry создаёт пустой реестр.
+func NewRegistry() *Registry { return &Registry{names: make(map[string]bool)} }
+
+// add регистрирует метрику; повтор имени — ошибка программы (panic, как в expvar).
+func (r *Registry) add(e entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[e.name] {
+		panic("metrics: duplicate metric " + e.name)
+	}
+	r.names[e.name] = true
+	r.entries = append(r.entries, e)
+}
+
+// Counter регистрирует счётчик c под именем name (принято окончание _total).
+func (r *Registry) Counter(name, help string, c *Counter) {
+	r.add(entry{name: name, help: help, kind: "counter", write: func(w *bufio.Writer, name string) {
+		fmt.Fprintf(w, "%s %d\n", name, c.Value())
+	}})
+}
+
+// CounterFunc регистрирует счётчик, значение которого хранит владелец:
+// fn вызывается при каждой выдаче метрик и должна быть потокобезопасной.
+func (r *Registry) CounterFunc(name, help string, fn func() uint64) {
+	r.add(entry{name: name, help: help, kind: "counter", write: func(w *bufio.Writer, name string) {
+		fmt.Fprintf(w, "%s %d\n", name, fn())
+	}})
+}
+
+// Gauge регистрирует датчик: fn вызывается при каждой выдаче метрик
+// и должна быть дешёвой и потокобезопасной.
+func (r *Registry) Gauge(name, help string, fn func() float64) {
+	r.add(entry{name: name, help: help, kind: "gauge", write: func(w *bufio.Writer, name string) {
+		fmt.Fprintf(w, "%s %s\n", name, formatFloat(fn()))
+	}})
+}
+
+// Histogram регистрирует гистограмму h.
+func (r *Registry) Histogram(name, help string, h *Histogram) {
+	r.add(entry{name: name, help: help, kind: "histogram", write: func(w *bufio.Writer, name string) {
+		h.mu.Lock()
+		counts := append([]uint64(nil), h.counts...)
+		sum, count := h.sum, h.count
+		h.mu.Unlock()
+		var cum uint64
+		for i, b := range h.bounds {
+			cum += counts[i]
+			fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", name, formatFloat(b), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, count)
+		fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(sum))
+		fmt.Fprintf(w, "%s_count %d\n", name, count)
+	}})
+}
+
+// WriteText выводит все метрики в текстовом формате Prometheus (с # HELP и # TYPE).
+func (r *Registry) WriteText(out io.Writer) error {
+	r.mu.Lock()
+	entries := append([]entry(nil), r.entries...)
+	r.mu.Unlock()
+	w := bufio.NewWriter(out)
+	for _, e := range entries {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", e.name, e.help, e.name, e.kind)
+		e.write(w, e.name)
+	}
+	return w.Flush()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,257 @@
+// Package nats — минимальный клиент NATS (текстовый протокол) на стандартной
+// библиотеке: подписка (в том числе в группе очереди), чтение сообщений
+// и публикация — ровно то, что нужно приёму задач из брокера (app.ingestLoop)
+// и публикации событий задач (app.eventsLoop).
+//
+// Подтверждения JetStream — обычная публикация в subject ответа сообщения
+// (Msg.Reply, "$JS.ACK...") с телом "+ACK", "-NAK" или "+TERM": отдельного
+// API для них не нужно.
+package nats
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Msg — полученное сообщение.
+type Msg struct {
+	Subject string
+	Reply   string // subject для ответа/подтверждения; пусто — ответ не ждут
+	Data    []byte
+}
+
+// Conn — соединение с сервером NATS. Читать сообщения (Next) должна одна
+// горутина; Publish можно вызывать откуда угодно.
+type Conn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+
+	wmu sync.Mutex // запись в conn
+	sid int
+}
+
+// ServerError — ошибка от сервера ("-ERR '...'").
+type ServerError string
+
+func (e ServerError) Error() string { return "nats: " + string(e) }
+
+// maxPayload — предел тела сообщения, которое клиент согласен прочитать
+// (сервер по умолчанию ограничивает 1 MiB).
+const maxPayload = 64 << 20
+
+// Dial подключается к nats://[user:password@|token@]host[:port] (порт по
+// умолчанию 4222) и выполняет рукопожатие (INFO → CONNECT → PING/PONG).
+// name — имя клиента, видимое в мониторинге сервера.
+func Dial(ctx context.Context, rawURL, name string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nats" {
+		return nil, fmt.Errorf("nats: unsupported scheme %q (expected nats://)", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{conn: nc, rd: bufio.NewReader(nc)}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = nc.SetDeadline(dl)
+	}
+	if err := c.handshake(u, name); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	_ = nc.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func (c *Conn) handshake(u *url.URL, name string) error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("nats: expected INFO, got %q", line)
+	}
+	opts := map[string]any{
+		"verbose": false, "pedantic": false, "name": name,
+		"lang": "go", "version": "0", "protocol": 1, "headers": false,
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			opts["user"], opts["pass"] = u.User.Username(), pass
+		} else {
+			opts["auth_token"] = u.User.Username()
+		}
+	}
+	b, _ := json.Marshal(opts)
+	if err := c.write("CONNECT " + string(b) + "\r\nPING\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return serverError(line)
+		}
+	}
+}
+
+// Subscribe подписывается на subject; queue (если не пуст) — группа очереди:
+// каждое сообщение получает один подписчик группы.
+func (c *Conn) Subscribe(subject, queue string) error {
+	c.wmu.Lock()
+	c.sid++
+	sid := c.sid
+	c.wmu.Unlock()
+	if queue != "" {
+		return c.write(fmt.Sprintf("SUB %s %s %d\r\n", subject, queue, sid))
+	}
+	return c.write(fmt.Sprintf("SUB %s %d\r\n", subject, sid))
+}
+
+// Publish публикует data в subject.
+func (c *Conn) Publish(subject string, data []byte) error {
+	return c.PublishReply(subject, "", data)
+}
+
+// PublishReply публикует data в subject с subject'ом для ответа reply
+// (запрос: ответ придёт сообщением в reply — на него нужно подписаться заранее).
+// Так публикуют в поток JetStream с подтверждением (PubAck).
+func (c *Conn) PublishReply(subject, reply string, data []byte) error {
+	if reply == "" {
+		return c.write(fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(data), data))
+	}
+	return c.write(fmt.Sprintf("PUB %s %s %d\r\n%s\r\n", subject, reply, len(data), data))
+}
+
+// Flush отправляет PING и ждёт PONG не дольше timeout: сервер отвечает
+// по порядку, значит, всё опубликованное до Flush им принято. Читает
+// соединение — нельзя вызывать одновременно с Next; пришедшие в это время
+// сообщения (MSG) отбрасываются.
+func (c *Conn) Flush(timeout time.Duration) error {
+	if err := c.write("PING\r\n"); err != nil {
+		return err
+	}
+	_ = c.conn.SetReadDeadline(time.Now().Add(timeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return serverError(line)
+		case strings.HasPrefix(line, "MSG "):
+			if _, err := c.readMsg(line); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// SetReadDeadline ограничивает ожидание в Next моментом t (нулевое время —
+// без ограничения). По истечении Next возвращает ошибку с Timeout() == true;
+// строка протокола могла быть прочитана не целиком — соединение после этого
+// следует закрыть и установить заново.
+func (c *Conn) SetReadDeadline(t time.Time) error { return c.conn.SetReadDeadline(t) }
+
+// Next ждёт следующее сообщение. На PING сервера отвечает сам; ошибка
+// соединения или "-ERR" сервера возвращаются — соединение после них
+// следует закрыть и установить заново.
+func (c *Conn) Next() (Msg, error) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return Msg{}, err
+		}
+		switch {
+		case strings.HasPrefix(line, "MSG "):
+			return c.readMsg(line)
+		case line == "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				return Msg{}, err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return Msg{}, serverError(line)
+		}
+		// +OK, PONG, INFO (обновление кластера) — пропускаем
+	}
+}
+
+// readMsg дочитывает сообщение по строке "MSG <subject> <sid> [reply] <size>".
+func (c *Conn) readMsg(line string) (Msg, error) {
+	f := strings.Fields(line)
+	if len(f) != 4 && len(f) != 5 {
+		return Msg{}, fmt.Errorf("nats: bad MSG line %q", line)
+	}
+	size, err := strconv.Atoi(f[len(f)-1])
+	if err != nil || size < 0 || size > maxPayload {
+		return Msg{}, fmt.Errorf("nats: bad MSG size in %q", line)
+	}
+	m := Msg{Subject: f[1]}
+	if len(f) == 5 {
+		m.Reply = f[3]
+	}
+	buf := make([]byte, size+2)
+	if _, err := io.ReadFull(c.rd, buf); err != nil {
+		return Msg{}, err
+	}
+	m.Data = buf[:size]
+	return m, nil
+}
+
+// Close закрывает соединение; ожидающий Next возвращает ошибку.
+func (c *Conn) Close() error { return c.conn.Close() }
+
+func (c *Conn) write(s string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := io.WriteString(c.conn, s)
+	return err
+}
+
+func (c *Conn) readLine() (string, error) {
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func serverError(line string) error {
+	msg := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'")
+	if msg == "" {
+		return errors.New("nats: server error")
+	}
+	return ServerError(msg)
+}
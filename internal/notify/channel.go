@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Ограничения длины сообщения: Telegram принимает до 4096 символов, Slack
+// обрезает длинные сообщения сам, но читать их в канале всё равно неудобно.
+const (
+	telegramMaxText = 4096
+	slackMaxText    = 8000
+)
+
+// Channel — канал уведомлений с фиксированным получателем (уведомитель из
+// секции notifiers): вебхук Slack или чат Telegram.
+type Channel interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// httpClient — клиент запросов к API каналов: ответ ждём не дольше 30 секунд.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// New разбирает адрес уведомителя:
+//   - slack://hooks.slack.com/services/T…/B…/… — входящий вебхук Slack
+//     (запрос уходит на https:// с тем же хостом и путём);
+//   - telegram://<токен бота>@api.telegram.org?chat_id=<ID чата> — сообщение
+//     от бота в чат, группу или канал; хост — сервер Bot API (свой или
+//     api.telegram.org).
+func New(rawURL string) (Channel, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, errors.New("не задан адрес сервера")
+	}
+	switch u.Scheme {
+	case "slack":
+		if strings.Trim(u.Path, "/") == "" {
+			return nil, errors.New("slack: не задан путь вебхука (/services/…)")
+		}
+		hook := *u
+		hook.Scheme = "https"
+		return &slack{url: hook.String()}, nil
+	case "telegram":
+		token := ""
+		if u.User != nil {
+			token = u.User.String()
+			if p, ok := u.User.Password(); ok {
+				token = u.User.Username() + ":" + p
+			}
+		}
+		if token == "" {
+			return nil, errors.New("telegram: не задан токен бота (telegram://<токен>@api.telegram.org?chat_id=…)")
+		}
+		chat := u.Query().Get("chat_id")
+		if chat == "" {
+			return nil, errors.New("telegram: не задан параметр chat_id")
+		}
+		api := url.URL{Scheme: "https", Host: u.Host, Path: "/bot" + token + "/sendMessage"}
+		return &telegram{url: api.String(), chatID: chat}, nil
+	}
+	return nil, fmt.Errorf("ожидается slack:// или telegram://, получено %q", u.Scheme)
+}
+
+// slack — входящий вебхук Slack.
+type slack struct{ url string }
+
+func (s *slack) Send(ctx context.Context, msg Message) error {
+	text := "*" + msg.Subject + "*\n```\n" + truncate(msg.Text, slackMaxText) + "```"
+	return postJSON(ctx, "slack", s.url, map[string]any{"text": text})
+}
+
+// telegram — сообщение бота Telegram (метод sendMessage Bot API).
+type telegram struct {
+	url    string // https://<сервер>/bot<токен>/sendMessage
+	chatID string
+}
+
+func (t *telegram) Send(ctx context.Context, msg Message) error {
+	text := truncate(msg.Subject+"\n\n"+msg.Text, telegramMaxText)
+	return postJSON(ctx, "telegram", t.url, map[string]any{
+		"chat_id":                  t.chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	})
+}
+
+// postJSON отправляет body в формате JSON; ответ не 2xx — ошибка с началом
+// тела ответа (там объяснение Slack или Telegram). Адрес в ошибку не
+// попадает: в нём секрет (путь вебхука, токен бота).
+func postJSON(ctx context.Context, name, endpoint string, body any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("%s: bad request", name)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err // без адреса с секретом
+		}
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: http %d: %s", name, resp.StatusCode, strings.TrimSpace(string(text)))
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return nil
+}
+
+// truncate обрезает s до max символов (рун), отмечая обрезку многоточием.
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
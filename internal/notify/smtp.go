@@ -0,0 +1,181 @@
+// Package notify — уведомления о задачах вне API: сводка по завершении
+// задачи на почту (SMTP), в Slack и Telegram (Channel).
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Message — письмо: тема и текст (text/plain, UTF-8).
+type Message struct {
+	Subject string
+	Text    string
+}
+
+// SMTP — отправка писем через SMTP-сервер (SMTP_URL).
+type SMTP struct {
+	addr     string // host:port
+	host     string // имя для проверки сертификата
+	implicit bool   // smtps://: TLS с первого байта; иначе STARTTLS, если сервер умеет
+	user     string
+	password string
+	from     *mail.Address
+	hostname string // имя в Message-ID
+}
+
+// NewSMTP разбирает адрес сервера и отправителя:
+//   - smtp://[user:password@]host[:port] — порт по умолчанию 587, соединение
+//     переводится в TLS (STARTTLS), если сервер это умеет;
+//   - smtps://[user:password@]host[:port] — TLS сразу, порт по умолчанию 465.
+//
+// Логин и пароль (PLAIN) отправляются только по TLS или на localhost.
+// from — адрес отправителя (можно с именем: "Downloader <dl@example.com>").
+func NewSMTP(rawURL, from string) (*SMTP, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	s := &SMTP{}
+	port := "587"
+	switch u.Scheme {
+	case "smtp":
+	case "smtps":
+		s.implicit, port = true, "465"
+	default:
+		return nil, fmt.Errorf("ожидается smtp:// или smtps://, получено %q", u.Scheme)
+	}
+	if s.host = u.Hostname(); s.host == "" {
+		return nil, errors.New("не задан адрес сервера")
+	}
+	if p := u.Port(); p != "" {
+		port = p
+	}
+	s.addr = net.JoinHostPort(s.host, port)
+	if u.User != nil {
+		s.user = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if from == "" {
+		return nil, errors.New("не задан адрес отправителя")
+	}
+	if s.from, err = mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("адрес отправителя %q: %v", from, err)
+	}
+	_, s.hostname, _ = strings.Cut(s.from.Address, "@")
+	return s, nil
+}
+
+// Redact возвращает адрес (SMTP_URL, уведомителя из notifiers) без секретов:
+// пароля, токена бота Telegram, пути вебхука Slack.
+func Redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if u.Scheme == "slack" && u.Path != "" {
+		u.Path, u.RawPath = "/***", "/***"
+	}
+	if u.User == nil {
+		return u.String()
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "***")
+	} else if u.Scheme == "telegram" {
+		u.User = url.User("***")
+	}
+	return u.String()
+}
+
+// Send отправляет msg получателям to. Соединение — на одно письмо: писем
+// немного (по одному на завершённую задачу), держать его открытым незачем.
+func (s *SMTP) Send(ctx context.Context, to []string, msg Message) error {
+	if len(to) == 0 {
+		return errors.New("smtp: no recipients")
+	}
+	d := net.Dialer{Timeout: 30 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if s.implicit {
+		conn = tls.Client(conn, &tls.Config{ServerName: s.host})
+	}
+	deadline := time.Now().Add(2 * time.Minute)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	conn.SetDeadline(deadline)
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if !s.implicit {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+				return err
+			}
+		}
+	}
+	if s.user != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.user, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.compose(to, msg)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// compose собирает письмо RFC 5322: тема в кодировке RFC 2047, текст —
+// quoted-printable (строки ответа могут быть длиннее 998 байт, а в URL —
+// что угодно).
+func (s *SMTP) compose(to []string, msg Message) []byte {
+	var b bytes.Buffer
+	id := make([]byte, 12)
+	rand.Read(id)
+	fmt.Fprintf(&b, "From: %s\r\n", s.from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), s.hostname)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(msg.Text, "\n", "\r\n")))
+	qp.Close()
+	return b.Bytes()
+}
@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"container/heap"
+	"time"
+)
+
+// delayed — задание, отложенное до момента at (Dispatcher.Schedule).
+type delayed struct {
+	job Job
+	at  time.Time
+	seq uint64 // порядок постановки: при равном at — FIFO
+}
+
+// delayHeap — min-куча отложенных заданий по времени готовности.
+type delayHeap []delayed
+
+func (h delayHeap) Len() int { return len(h) }
+func (h delayHeap) Less(i, j int) bool {
+	if !h[i].at.Equal(h[j].at) {
+		return h[i].at.Before(h[j].at)
+	}
+	return h[i].seq < h[j].seq
+}
+func (h delayHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *delayHeap) Push(x any)   { *h = append(*h, x.(delayed)) }
+func (h *delayHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Schedule откладывает задание j до момента at (очередь отложенных заданий).
+//
+// Готовые задания переносятся в backlog планировщиком на каждом тике
+// flushTicker (~250ms) — точнее откладывать не нужно: это паузы между
+// попытками загрузки в секунды и минуты. В backlog задание встаёт по своему
+// приоритету и подчиняется Drain, как и любое другое. at в прошлом — задание
+// готово сразу. После Close задания не принимаются (отложенные теряются:
+// при перезапуске они восстанавливаются из WAL).
+func (d *Dispatcher) Schedule(j Job, at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed.Load() {
+		d.metrics.dropped.Inc()
+		return
+	}
+	d.metrics.scheduled.Inc()
+	d.delaySeq++
+	heap.Push(&d.delayed, delayed{job: j, at: at, seq: d.delaySeq})
+}
+
+// promoteDueLocked переносит в backlog отложенные задания, чей момент наступил.
+// Ожидание в очереди (метрика downloader_queue_wait_seconds) отсчитывается
+// от этого момента, а не от Schedule. Вызывать под d.mu.
+func (d *Dispatcher) promoteDueLocked(now time.Time) {
+	for len(d.delayed) > 0 && !d.delayed[0].at.After(now) {
+		j := heap.Pop(&d.delayed).(delayed).job
+		j.enqueuedAt = now
+		if j.Retry || j.Resume {
+			d.pushRetryLocked(j, now)
+			continue
+		}
+		d.pushBacklog(j, now)
+	}
+}
@@ -1,17 +1,32 @@
 package queue
 
 import (
+	"container/heap"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
 // Диспетчер: принимает Job в InChan, отдаёт воркерам из OutChan.
-// Поддерживает drain (пауза выдачи новых работ) и backlog.
+// Поддерживает drain (пауза выдачи новых работ), паузу отдельных хостов
+// (PauseHost), backlog и отложенные задания (Schedule — например, повтор
+// загрузки через паузу).
 type Job struct {
-	TaskID    string
-	FileIndex int
-	Host      string
+	TaskID   string
+	FileID   string // core.FileItem.ID — стабилен, в отличие от индекса в Task.Files
+	Host     string
+	Priority int  // core.Task.Priority на момент постановки; больше — раньше
+	Retry    bool // повтор упавшей загрузки: встаёт в голову своего приоритета, см. pushBacklog
+	// Resume — загрузка, прерванная перезапуском сервиса: встаёт в голову своего
+	// приоритета впереди повторов — её .part-файл, скорее всего, почти докачан.
+	Resume bool
+	// Bounces — сколько раз задание возвращалось в общую очередь (Queue.Shared)
+	// экземплярами, которым задача не принадлежит.
+	Bounces int
+
+	enqueuedAt time.Time // приём планировщиком (или срок отложенного) — для метрик ожидания
+	backlogAt  time.Time // попадание в backlog — для метрик пребывания в нём
 }
 
 type Dispatcher struct {
@@ -22,6 +37,13 @@ type Dispatcher struct {
 	drain   atomic.Bool
 	closed  atomic.Bool
 
+	delayed  delayHeap // отложенные до своего момента задания; под mu
+	delaySeq uint64
+
+	pausedHosts map[string]bool // хосты, чьи задания не выдаются (PauseHost); под mu
+
+	metrics *dispatcherMetrics // см. RegisterMetrics
+
 	flushTicker *time.Ticker
 	stopCh      chan struct{}
 }
@@ -45,6 +67,7 @@ func NewDispatcher(inBuffer, workerBuffer int) *Dispatcher {
 		backlog:     make([]Job, 0, 1024),
 		flushTicker: time.NewTicker(250 * time.Millisecond),
 		stopCh:      make(chan struct{}),
+		metrics:     newDispatcherMetrics(),
 	}
 	go d.schedulerLoop()
 	return d
@@ -102,54 +125,291 @@ func (d *Dispatcher) OutChan() <-chan Job { return d.taskCh }
 //
 // Логика при поступлении job:
 //
-//	– если включён Drain — кладёт job в backlog;
+//	– повтор (Job.Retry) и прерванная загрузка (Job.Resume) — в голову своего
+//	  приоритета (pushRetryLocked);
+//	– если включён Drain, хост job приостановлен или backlog не пуст — кладёт
+//	  job в backlog (по приоритету, см. pushBacklog), чтобы не обогнать ждущие задания;
 //	– иначе пытается неблокирующе отправить в taskCh;
 //	  если taskCh полон — перемещает job в backlog.
 //
-// Порядок задач не строго гарантируется (из-за неблокирующей отправки и бэклога).
-// Частота сброса регулируется flushTicker.
+// Приоритет соблюдается среди заданий backlog; уже попавшие в taskCh
+// выдаются в порядке поступления. Частота сброса регулируется flushTicker.
 func (d *Dispatcher) schedulerLoop() {
 	for {
 		select {
 		case <-d.stopCh:
+			d.mu.Lock() // Reprioritize не должен писать в закрытый канал
 			close(d.taskCh)
+			d.metrics.dropped.Add(uint64(len(d.backlog) + len(d.delayed) + len(d.jobInCh)))
+			d.mu.Unlock()
 			return
 		case <-d.flushTicker.C:
 			d.tryFlushBacklog()
 		case j := <-d.jobInCh:
-			if d.IsDrain() {
-				d.mu.Lock()
-				d.backlog = append(d.backlog, j)
+			now := time.Now()
+			j.enqueuedAt = now
+			d.metrics.enqueued.Inc()
+			d.mu.Lock()
+			if j.Retry || j.Resume {
+				// выдаст ближайший тик: подряд идущие повторы (массовый retry)
+				// не гоняют буфер воркеров туда-обратно на каждом задании
+				d.pushRetryLocked(j, now)
+				d.mu.Unlock()
+				continue
+			}
+			if d.IsDrain() || len(d.backlog) > 0 || d.hostPausedLocked(j.Host) {
+				d.pushBacklog(j, now)
 				d.mu.Unlock()
 				continue
 			}
 			select {
 			case d.taskCh <- j:
+				d.metrics.observeDispatch(j, now)
 			default:
-				d.mu.Lock()
-				d.backlog = append(d.backlog, j)
-				d.mu.Unlock()
+				d.pushBacklog(j, now)
 			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// pushBacklog вставляет j в backlog, упорядоченный по убыванию Priority;
+// при равном приоритете — после уже ждущих (FIFO), но повторы (Job.Retry)
+// встают перед первыми заданиями своего приоритета (после ранее вставших
+// повторов): упавший на временной ошибке файл не ждёт весь backlog заново
+// и завершается рядом с соседями по задаче. Прерванные перезапуском загрузки
+// (Job.Resume) встают ещё раньше — перед повторами. now — момент попадания
+// в backlog (для метрик). Вызывать под d.mu.
+func (d *Dispatcher) pushBacklog(j Job, now time.Time) {
+	j.backlogAt = now
+	i := sort.Search(len(d.backlog), func(i int) bool { return jobBefore(j, d.backlog[i]) })
+	d.backlog = append(d.backlog, Job{})
+	copy(d.backlog[i+1:], d.backlog[i:])
+	d.backlog[i] = j
+}
+
+// pushRetryLocked ставит повтор (или прерванную загрузку) j в голову его приоритета — в том числе впереди
+// заданий, уже выданных в буфер воркеров: если буфер не пуст, они забираются
+// обратно в backlog (pullOutboundLocked) и упорядочиваются вместе с ним.
+// Вызывать под d.mu.
+func (d *Dispatcher) pushRetryLocked(j Job, now time.Time) {
+	if len(d.taskCh) > 0 {
+		d.pullOutboundLocked()
+		sort.SliceStable(d.backlog, func(i, j int) bool { return jobBefore(d.backlog[i], d.backlog[j]) })
+	}
+	d.pushBacklog(j, now)
+}
+
+// jobBefore сообщает, что задание a выдаётся раньше b: по убыванию приоритета,
+// при равном — прерванные загрузки (Resume), затем повторы (Retry), затем
+// остальные; иначе порядок не определён (сохраняется порядок поступления).
+func jobBefore(a, b Job) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.rank() > b.rank()
+}
+
+// rank — очерёдность задания внутри приоритета (см. jobBefore).
+func (j Job) rank() int {
+	switch {
+	case j.Resume:
+		return 2
+	case j.Retry:
+		return 1
+	}
+	return 0
+}
+
+// Reprioritize меняет приоритет ждущих заданий задачи taskID и пересортировывает очередь.
+// Отложенным заданиям (Schedule) приоритет меняется на месте: он понадобится,
+// когда они перейдут в backlog.
+//
+// Задания, уже лежащие в буфере воркеров (taskCh), забираются обратно в backlog,
+// чтобы новый порядок касался и их; задания во входном канале (ещё не принятые
+// планировщиком) сохраняют старый приоритет. Возвращает число изменённых заданий.
+func (d *Dispatcher) Reprioritize(taskID string, priority int) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed.Load() {
+		return 0
+	}
+	d.pullOutboundLocked()
+	n := 0
+	for i := range d.delayed {
+		if d.delayed[i].job.TaskID == taskID && d.delayed[i].job.Priority != priority {
+			d.delayed[i].job.Priority = priority
+			n++
+		}
+	}
+	for i := range d.backlog {
+		if d.backlog[i].TaskID == taskID && d.backlog[i].Priority != priority {
+			d.backlog[i].Priority = priority
+			n++
+		}
+	}
+	sort.SliceStable(d.backlog, func(i, j int) bool { return jobBefore(d.backlog[i], d.backlog[j]) })
+	d.flushLocked()
+	return n
+}
+
+// Remove убирает из очереди ждущие задания задачи taskID: отложенные
+// (Schedule), из backlog и буфера воркеров (taskCh). Задания во входном
+// канале (ещё не принятые планировщиком) остаются — воркер отбросит их как
+// устаревшие. Возвращает число убранных заданий.
+func (d *Dispatcher) Remove(taskID string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed.Load() {
+		return 0
+	}
+	d.pullOutboundLocked()
+	n := 0
+	delayed := d.delayed[:0]
+	for _, dj := range d.delayed {
+		if dj.job.TaskID == taskID {
+			n++
+			continue
+		}
+		delayed = append(delayed, dj)
+	}
+	clear(d.delayed[len(delayed):])
+	d.delayed = delayed
+	heap.Init(&d.delayed)
+	backlog := d.backlog[:0]
+	for _, j := range d.backlog {
+		if j.TaskID == taskID {
+			n++
+			continue
+		}
+		backlog = append(backlog, j)
+	}
+	clear(d.backlog[len(backlog):])
+	d.backlog = backlog
+	d.flushLocked()
+	return n
+}
+
+// pullOutboundLocked забирает задания из буфера воркеров (taskCh) обратно
+// в голову backlog: они стояли раньше всех ждущих. Вызывать под d.mu.
+func (d *Dispatcher) pullOutboundLocked() {
+	var pulled []Job
+	now := time.Now()
+pull:
+	for range len(d.taskCh) {
+		select {
+		case j := <-d.taskCh:
+			j.backlogAt = now
+			pulled = append(pulled, j)
+		default:
+			break pull
 		}
 	}
+	d.metrics.requeued.Add(uint64(len(pulled)))
+	d.backlog = append(pulled, d.backlog...)
 }
 
-// tryFlushBacklog пытается выдать накопленные задания из backlog в taskCh.
-// Ничего не делает, если включён Drain. Работает под мьютексом,
+// tryFlushBacklog переносит в backlog отложенные задания, чей момент наступил,
+// и пытается выдать накопленные задания из backlog в taskCh.
+// Выдачи нет, если включён Drain; задания приостановленных хостов (PauseHost)
+// пропускаются и остаются в backlog на своих местах. Работает под мьютексом,
 // отправляет неблокирующе (select default) и прекращает, как только taskCh полон.
-// Порядок в backlog — FIFO (всегда берём первый элемент).
+// Выдаёт с головы backlog — по убыванию приоритета, при равном — FIFO.
+//
+// Длительность выдачи (когда было что выдавать) и размер пачки попадают
+// в метрики downloader_queue_flush_*.
 func (d *Dispatcher) tryFlushBacklog() {
-	if d.IsDrain() {
-		return
-	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	for len(d.backlog) > 0 {
+	start := time.Now()
+	d.promoteDueLocked(start)
+	if len(d.backlog) == 0 || d.IsDrain() {
+		return
+	}
+	d.flushLocked()
+	d.metrics.flush.ObserveDuration(time.Since(start))
+}
+
+// flushLocked — тело tryFlushBacklog; вызывать под d.mu.
+func (d *Dispatcher) flushLocked() {
+	if d.IsDrain() || len(d.backlog) == 0 {
+		return
+	}
+	now := time.Now()
+	sent := 0
+	defer func() {
+		if sent > 0 {
+			d.metrics.batch.Observe(float64(sent))
+		}
+	}()
+	if len(d.pausedHosts) == 0 {
+		for len(d.backlog) > 0 {
+			select {
+			case d.taskCh <- d.backlog[0]:
+				d.metrics.observeDispatch(d.backlog[0], now)
+				sent++
+				d.backlog = d.backlog[1:]
+			default:
+				return
+			}
+		}
+		return
+	}
+	// есть приостановленные хосты: их задания остаются, остальные выдаются;
+	// фильтруем на месте, сохраняя порядок оставшихся
+	kept := d.backlog[:0]
+	full := false
+	for _, j := range d.backlog {
+		if full || d.hostPausedLocked(j.Host) {
+			kept = append(kept, j)
+			continue
+		}
 		select {
-		case d.taskCh <- d.backlog[0]:
-			d.backlog = d.backlog[1:]
+		case d.taskCh <- j:
+			d.metrics.observeDispatch(j, now)
+			sent++
 		default:
-			return
+			full = true
+			kept = append(kept, j)
 		}
 	}
+	clear(d.backlog[len(kept):])
+	d.backlog = kept
+}
+
+// Stats — моментальный снимок заполненности очереди.
+type Stats struct {
+	Inbound     int  `json:"inbound"`          // заданий во входном канале
+	InboundCap  int  `json:"inbound_capacity"` // ёмкость входного канала
+	Backlog     int  `json:"backlog"`          // заданий в backlog
+	Delayed     int  `json:"delayed"`          // отложенных заданий (ждут паузы перед повтором)
+	Outbound    int  `json:"outbound"`         // заданий в канале воркеров
+	OutboundCap int  `json:"outbound_capacity"`
+	Drain       bool `json:"drain"`
+
+	PausedHosts []string `json:"paused_hosts,omitempty"`
+
+	Backend string `json:"backend"`         // "memory" или "redis"
+	Error   string `json:"error,omitempty"` // последняя ошибка обращения к внешней очереди
+}
+
+// Stats возвращает текущую заполненность каналов и backlog.
+// Значения читаются без общей блокировки и могут быть слегка рассогласованы —
+// для диагностики этого достаточно.
+func (d *Dispatcher) Stats() Stats {
+	d.mu.Lock()
+	backlog, delayed := len(d.backlog), len(d.delayed)
+	d.mu.Unlock()
+	paused := d.PausedHosts()
+	return Stats{
+		Inbound:     len(d.jobInCh),
+		InboundCap:  cap(d.jobInCh),
+		Backlog:     backlog,
+		Delayed:     delayed,
+		Outbound:    len(d.taskCh),
+		OutboundCap: cap(d.taskCh),
+		Drain:       d.IsDrain(),
+		PausedHosts: paused,
+		Backend:     "memory",
+	}
 }
@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"sort"
+	"strings"
+)
+
+// PauseHost приостанавливает выдачу заданий с хоста host (без учёта регистра):
+// такие задания копятся в backlog, остальные выдаются как обычно. Задания хоста,
+// уже лежащие в буфере воркеров (taskCh), забираются обратно в backlog.
+// Загрузки, уже начатые воркерами, не прерываются. Возвращает false, если хост
+// уже был приостановлен.
+func (d *Dispatcher) PauseHost(host string) bool {
+	host = strings.ToLower(host)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pausedHosts[host] {
+		return false
+	}
+	if d.pausedHosts == nil {
+		d.pausedHosts = make(map[string]bool)
+	}
+	d.pausedHosts[host] = true
+	if d.closed.Load() {
+		return true
+	}
+	d.pullOutboundLocked()
+	d.flushLocked()
+	return true
+}
+
+// ResumeHost снимает паузу с хоста host; его задания из backlog выдаются
+// на ближайшем тике в порядке приоритета. Возвращает false, если хост
+// не был приостановлен.
+func (d *Dispatcher) ResumeHost(host string) bool {
+	host = strings.ToLower(host)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.pausedHosts[host] {
+		return false
+	}
+	delete(d.pausedHosts, host)
+	d.flushLocked()
+	return true
+}
+
+// PausedHosts возвращает приостановленные хосты по алфавиту.
+func (d *Dispatcher) PausedHosts() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	hosts := make([]string, 0, len(d.pausedHosts))
+	for h := range d.pausedHosts {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// hostPausedLocked сообщает, что выдача заданий с хоста приостановлена. Вызывать под d.mu.
+func (d *Dispatcher) hostPausedLocked(host string) bool {
+	return len(d.pausedHosts) > 0 && d.pausedHosts[strings.ToLower(host)]
+}
@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/metrics"
+)
+
+// dispatcherMetrics — счётчики и гистограммы диспетчера (RegisterMetrics).
+// Обновляются всегда, даже если реестр метрик не подключён: это атомарные
+// счётчики и короткие критические секции.
+type dispatcherMetrics struct {
+	enqueued   metrics.Counter // принято планировщиком из InChan
+	scheduled  metrics.Counter // отложено через Schedule
+	dispatched metrics.Counter // выдано в канал воркеров (включая повторную выдачу после requeued)
+	requeued   metrics.Counter // забрано из канала воркеров обратно в backlog (Reprioritize, PauseHost)
+	dropped    metrics.Counter // не выдано из-за остановки (восстанавливаются из WAL при старте)
+
+	wait      *metrics.Histogram // от приёма (или наступления срока отложенного) до взятия воркером
+	residency *metrics.Histogram // время в backlog — только у заданий, не выданных сразу
+	batch     *metrics.Histogram // заданий, выданных за одну выдачу из backlog
+	flush     *metrics.Histogram // длительность выдачи из backlog под блокировкой
+}
+
+func newDispatcherMetrics() *dispatcherMetrics {
+	return &dispatcherMetrics{
+		wait:      metrics.NewHistogram(metrics.WaitBuckets),
+		residency: metrics.NewHistogram(metrics.WaitBuckets),
+		batch:     metrics.NewHistogram(metrics.SizeBuckets),
+		flush:     metrics.NewHistogram(metrics.LatencyBuckets),
+	}
+}
+
+// observeDispatch учитывает выдачу задания j в канал воркеров в момент now.
+func (m *dispatcherMetrics) observeDispatch(j Job, now time.Time) {
+	m.dispatched.Inc()
+	if !j.backlogAt.IsZero() {
+		m.residency.ObserveDuration(now.Sub(j.backlogAt))
+	}
+}
+
+// Picked отмечает, что воркер взял задание j из OutChan: время от приёма
+// задания до этого момента (включая ожидание в буфере воркеров) попадает
+// в downloader_queue_wait_seconds. Вызывайте сразу после чтения из OutChan.
+func (d *Dispatcher) Picked(j Job) {
+	if !j.enqueuedAt.IsZero() {
+		d.metrics.wait.ObserveDuration(time.Since(j.enqueuedAt))
+	}
+}
+
+// RegisterMetrics регистрирует метрики диспетчера в реестре r (префикс
+// downloader_queue_): счётчики заданий, время ожидания и пребывания в backlog,
+// размеры и длительность выдачи пачками, а также текущую заполненность (Stats).
+func (d *Dispatcher) RegisterMetrics(r *metrics.Registry) {
+	registerMetrics(r, d.metrics, d.Stats)
+}
+
+// registerMetrics — общая часть RegisterMetrics реализаций Queue.
+func registerMetrics(r *metrics.Registry, m *dispatcherMetrics, stats func() Stats) {
+	r.Counter("downloader_queue_jobs_enqueued_total", "Jobs accepted by the dispatcher.", &m.enqueued)
+	r.Counter("downloader_queue_jobs_scheduled_total", "Jobs delayed until a later time (retry backoff).", &m.scheduled)
+	r.Counter("downloader_queue_jobs_dispatched_total", "Jobs handed to workers, including re-dispatch after requeue.", &m.dispatched)
+	r.Counter("downloader_queue_jobs_requeued_total", "Jobs pulled back from the worker buffer into the backlog.", &m.requeued)
+	r.Counter("downloader_queue_jobs_dropped_total", "Jobs discarded on shutdown before dispatch (restored from the WAL on start).", &m.dropped)
+	r.Histogram("downloader_queue_wait_seconds", "Time from enqueue (or retry due time) until a worker picks the job.", m.wait)
+	r.Histogram("downloader_queue_backlog_residency_seconds", "Time jobs spent in the backlog before dispatch.", m.residency)
+	r.Histogram("downloader_queue_flush_batch_size", "Jobs dispatched from the backlog per flush.", m.batch)
+	r.Histogram("downloader_queue_flush_duration_seconds", "Duration of a backlog flush under the dispatcher lock.", m.flush)
+
+	gauge := func(name, help string, fn func(Stats) int) {
+		r.Gauge(name, help, func() float64 { return float64(fn(stats())) })
+	}
+	gauge("downloader_queue_inbound_jobs", "Jobs waiting in the inbound channel.", func(s Stats) int { return s.Inbound })
+	gauge("downloader_queue_backlog_jobs", "Jobs waiting in the backlog.", func(s Stats) int { return s.Backlog })
+	gauge("downloader_queue_delayed_jobs", "Jobs delayed until their retry time.", func(s Stats) int { return s.Delayed })
+	gauge("downloader_queue_outbound_jobs", "Jobs in the worker buffer.", func(s Stats) int { return s.Outbound })
+	gauge("downloader_queue_paused_hosts", "Hosts with dispatch paused.", func(s Stats) int { return len(s.PausedHosts) })
+	gauge("downloader_queue_drain", "1 if drain mode is on.", func(s Stats) int {
+		if s.Drain {
+			return 1
+		}
+		return 0
+	})
+}
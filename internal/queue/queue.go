@@ -0,0 +1,45 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/metrics"
+)
+
+// Queue — очередь заданий, из которой читают воркеры. Реализации:
+//   - Dispatcher — в памяти процесса (по умолчанию);
+//   - RedisQueue — общая для нескольких экземпляров сервиса (QUEUE_URL=redis://...).
+//
+// Семантика одна: InChan принимает задания, OutChan выдаёт их воркерам
+// по убыванию приоритета (повторы — в голову своего приоритета), Schedule
+// откладывает задание, Remove убирает ждущие задания задачи, Drain и PauseHost останавливают выдачу (всей очереди
+// или заданий одного хоста) этому экземпляру.
+type Queue interface {
+	InChan() chan<- Job
+	OutChan() <-chan Job
+	Picked(j Job)
+	Schedule(j Job, at time.Time)
+	Reprioritize(taskID string, priority int) int
+	Remove(taskID string) int
+
+	Drain(on bool)
+	IsDrain() bool
+	PauseHost(host string) bool
+	ResumeHost(host string) bool
+	PausedHosts() []string
+
+	// Shared сообщает, что очередь общая для нескольких экземпляров: воркер
+	// может получить задание чужой задачи (см. Job.Bounces).
+	Shared() bool
+	Stats() Stats
+	RegisterMetrics(r *metrics.Registry)
+	Close()
+}
+
+var (
+	_ Queue = (*Dispatcher)(nil)
+	_ Queue = (*RedisQueue)(nil)
+)
+
+// Shared — очередь Dispatcher принадлежит одному процессу.
+func (d *Dispatcher) Shared() bool { return false }
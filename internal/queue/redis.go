@@ -0,0 +1,609 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/metrics"
+	"github.com/Extrarius/29.09.2025/internal/redis"
+)
+
+// RedisQueue — очередь заданий в Redis, общая для нескольких экземпляров
+// сервиса (QUEUE_URL=redis://...): все читают один поток заданий.
+//
+// Устройство (ключи с префиксом, по умолчанию "downloader"):
+//   - <prefix>:ready — sorted set готовых заданий; оценка кодирует порядок
+//     выдачи (readyScore): по убыванию приоритета, повторы — в голову своего
+//     приоритета, иначе FIFO. Экземпляры забирают задания BZPOPMIN —
+//     каждое получает ровно один;
+//   - <prefix>:delayed — sorted set отложенных заданий (Schedule), оценка —
+//     момент готовности в миллисекундах; готовые переносит в ready тот
+//     экземпляр, чей ZREM удался первым.
+//
+// Drain и паузы хостов — свойство экземпляра: он перестаёт забирать задания
+// (Drain) или придерживает у себя задания приостановленного хоста и возвращает
+// их в Redis при ResumeHost/Close. Задания, которые воркеры не успели взять
+// до Close, возвращаются в Redis; потерянные при аварии восстанавливаются
+// из WAL экземпляра-владельца при его перезапуске.
+//
+// Ошибки Redis не роняют процесс: они пишутся в лог и видны в Stats.Error,
+// операции повторяются.
+type RedisQueue struct {
+	cli    *redis.Client // обычные команды
+	popCli *redis.Client // блокирующий BZPOPMIN — отдельное соединение
+	ready  string
+	delay  string
+
+	jobInCh chan Job
+	taskCh  chan Job
+	drain   atomic.Bool
+	closed  atomic.Bool
+
+	mu          sync.Mutex
+	pausedHosts map[string]bool // см. PauseHost; под mu
+	held        []Job           // задания приостановленных хостов, забранные из Redis; под mu
+
+	lastErr atomic.Pointer[string]
+	metrics *dispatcherMetrics
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup // pushLoop и promoteLoop
+	popped chan struct{}  // закрывается popLoop при выходе
+}
+
+// redisJob — задание в Redis (член sorted set'а). Одинаковые задания
+// схлопываются в один член — повторная постановка того же файла безвредна.
+type redisJob struct {
+	TaskID   string `json:"task"`
+	FileID   string `json:"file"`
+	Host     string `json:"host,omitempty"`
+	Priority int    `json:"prio,omitempty"`
+	Retry    bool   `json:"retry,omitempty"`
+	Resume   bool   `json:"resume,omitempty"`
+	Bounces  int    `json:"bounces,omitempty"`
+}
+
+const (
+	redisPopTimeout    = time.Second            // ожидание BZPOPMIN: как часто popLoop замечает Close/Drain
+	redisRetryPause    = time.Second            // пауза после ошибки Redis
+	redisPromoteBatch  = 256                    // отложенных заданий за тик
+	redisPriorityStep  = 1e13                   // шаг оценки между приоритетами (мс с запасом)
+	redisRetryAdvance  = 4e12                   // сдвиг повторов в голову своего приоритета
+	redisResumeAdvance = 6e12                   // сдвиг прерванных загрузок — впереди повторов
+	redisPromoteEvery  = 250 * time.Millisecond // как и flushTicker Dispatcher
+)
+
+// NewRedisQueue подключается к Redis по rawURL (redis://[user:password@]host:port[/db],
+// необязательный параметр ?prefix= — префикс ключей) и запускает фоновые циклы:
+// отправку принятых заданий, перенос готовых отложенных и выдачу воркерам.
+// workerBuffer — ёмкость канала воркеров (заданий, забранных из Redis наперёд).
+// Недоступный Redis — ошибка (проверяется PING).
+func NewRedisQueue(rawURL string, inBuffer, workerBuffer int) (*RedisQueue, error) {
+	prefix, err := KeyPrefix(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	q := &RedisQueue{
+		cli:     redis.New(opts),
+		popCli:  redis.New(opts),
+		ready:   prefix + ":ready",
+		delay:   prefix + ":delayed",
+		jobInCh: make(chan Job, inBuffer),
+		taskCh:  make(chan Job, workerBuffer),
+		metrics: newDispatcherMetrics(),
+		stopCh:  make(chan struct{}),
+		popped:  make(chan struct{}),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	if _, err := q.cli.Do(ctx, 0, "PING"); err != nil {
+		return nil, fmt.Errorf("queue: %w", err)
+	}
+	q.wg.Add(2)
+	go q.pushLoop()
+	go q.promoteLoop()
+	go q.popLoop()
+	return q, nil
+}
+
+// KeyPrefix извлекает префикс ключей из параметра prefix адреса очереди.
+func KeyPrefix(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	prefix := u.Query().Get("prefix")
+	if prefix == "" {
+		prefix = "downloader"
+	}
+	return prefix, nil
+}
+
+// Shared — очередь в Redis общая для всех экземпляров с тем же адресом и префиксом.
+func (q *RedisQueue) Shared() bool { return true }
+
+// InChan — как Dispatcher.InChan: задания уходят в Redis фоновым циклом.
+func (q *RedisQueue) InChan() chan<- Job { return q.jobInCh }
+
+// OutChan — канал выдачи воркерам; закрывается после Close.
+func (q *RedisQueue) OutChan() <-chan Job { return q.taskCh }
+
+// Drain включает/выключает паузу выдачи: при on=true экземпляр перестаёт
+// забирать задания из Redis (их получают другие экземпляры).
+func (q *RedisQueue) Drain(on bool) { q.drain.Store(on) }
+
+// IsDrain сообщает, включён ли Drain.
+func (q *RedisQueue) IsDrain() bool { return q.drain.Load() }
+
+// Picked — как Dispatcher.Picked (метрика ожидания).
+func (q *RedisQueue) Picked(j Job) {
+	if !j.enqueuedAt.IsZero() {
+		q.metrics.wait.ObserveDuration(time.Since(j.enqueuedAt))
+	}
+}
+
+// Close останавливает циклы, возвращает в Redis задания, которые воркеры
+// ещё не взяли, и придержанные задания приостановленных хостов, затем
+// закрывает OutChan. Идемпотентна.
+func (q *RedisQueue) Close() {
+	if q.closed.Swap(true) {
+		return
+	}
+	close(q.stopCh)
+	q.wg.Wait()
+	<-q.popped
+
+	var back []Job
+	q.mu.Lock()
+	back = append(back, q.held...)
+	q.held = nil
+	q.mu.Unlock()
+	// воркеры читают taskCh параллельно: забираем без блокировки
+	for done := false; !done; {
+		select {
+		case j := <-q.taskCh:
+			back = append(back, j)
+		case j := <-q.jobInCh:
+			back = append(back, j)
+		default:
+			done = true
+		}
+	}
+	if len(back) > 0 {
+		if err := q.push(back...); err != nil {
+			q.metrics.dropped.Add(uint64(len(back)))
+			log.Printf("Queue: %d job(s) not returned to redis on shutdown: %v", len(back), err)
+		}
+	}
+	close(q.taskCh)
+	q.cli.Close()
+	q.popCli.Close()
+}
+
+// pushLoop переносит задания из InChan в <prefix>:ready. При ошибке Redis
+// задание не теряется: повтор через redisRetryPause.
+func (q *RedisQueue) pushLoop() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.stopCh:
+			return
+		case j := <-q.jobInCh:
+			q.metrics.enqueued.Inc()
+			for q.push(j) != nil {
+				select {
+				case <-q.stopCh:
+					q.hold(j) // Close попробует ещё раз
+					return
+				case <-time.After(redisRetryPause):
+				}
+			}
+		}
+	}
+}
+
+// push добавляет задания в <prefix>:ready одной командой ZADD.
+func (q *RedisQueue) push(jobs ...Job) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	args := make([]string, 0, 2+2*len(jobs))
+	args = append(args, "ZADD", q.ready)
+	for _, j := range jobs {
+		args = append(args, readyScore(j, now), encodeJob(j))
+	}
+	_, err := q.do(0, args...)
+	return err
+}
+
+// Schedule откладывает задание до момента at (<prefix>:delayed). Ошибка Redis —
+// задание теряется для очереди (пишется в лог; при перезапуске его восстановит WAL).
+func (q *RedisQueue) Schedule(j Job, at time.Time) {
+	if q.closed.Load() {
+		q.metrics.dropped.Inc()
+		return
+	}
+	q.metrics.scheduled.Inc()
+	if _, err := q.do(0, "ZADD", q.delay, strconv.FormatInt(at.UnixMilli(), 10), encodeJob(j)); err != nil {
+		q.metrics.dropped.Inc()
+		log.Printf("Queue: schedule %s/%s: %v", j.TaskID, j.FileID, err)
+	}
+}
+
+// promoteLoop раз в redisPromoteEvery переносит готовые отложенные задания в ready.
+func (q *RedisQueue) promoteLoop() {
+	defer q.wg.Done()
+	t := time.NewTicker(redisPromoteEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-q.stopCh:
+			return
+		case <-t.C:
+			q.promoteDue()
+		}
+	}
+}
+
+// promoteDue переносит до redisPromoteBatch готовых заданий из delayed в ready.
+// Задание переносит тот экземпляр, чей ZREM его удалил, — гонки между
+// экземплярами не дублируют задания.
+func (q *RedisQueue) promoteDue() {
+	now := time.Now()
+	members, err := redis.Strings(q.do(0, "ZRANGEBYSCORE", q.delay, "-inf", strconv.FormatInt(now.UnixMilli(), 10),
+		"LIMIT", "0", strconv.Itoa(redisPromoteBatch)))
+	if err != nil {
+		return
+	}
+	for _, m := range members {
+		n, err := redis.Int(q.do(0, "ZREM", q.delay, m))
+		if err != nil || n == 0 {
+			continue
+		}
+		j, err := decodeJob(m)
+		if err != nil {
+			log.Printf("Queue: dropping malformed delayed job %q: %v", m, err)
+			continue
+		}
+		if err := q.push(j); err != nil {
+			q.metrics.dropped.Inc()
+		}
+	}
+}
+
+// popLoop забирает задания из ready и отдаёт воркерам. Пока включён Drain,
+// заданий не берёт; задания приостановленных хостов придерживает (held).
+func (q *RedisQueue) popLoop() {
+	defer close(q.popped)
+	timeout := strconv.Itoa(int(redisPopTimeout / time.Second))
+	for {
+		select {
+		case <-q.stopCh:
+			return
+		default:
+		}
+		if q.IsDrain() {
+			select {
+			case <-q.stopCh:
+				return
+			case <-time.After(redisPromoteEvery):
+			}
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), redisPopTimeout+5*time.Second)
+		res, err := redis.Strings(q.popCli.Do(ctx, redisPopTimeout, "BZPOPMIN", q.ready, timeout))
+		cancel()
+		if err != nil {
+			q.setErr(err)
+			select {
+			case <-q.stopCh:
+				return
+			case <-time.After(redisRetryPause):
+			}
+			continue
+		}
+		if len(res) < 3 {
+			continue // таймаут ожидания
+		}
+		j, err := decodeJob(res[1])
+		if err != nil {
+			log.Printf("Queue: dropping malformed job %q: %v", res[1], err)
+			continue
+		}
+		now := time.Now()
+		j.enqueuedAt = now
+		q.mu.Lock()
+		if q.hostPausedLocked(j.Host) {
+			q.held = append(q.held, j)
+			q.mu.Unlock()
+			continue
+		}
+		q.mu.Unlock()
+		select {
+		case q.taskCh <- j:
+			q.metrics.observeDispatch(j, now)
+		case <-q.stopCh:
+			q.hold(j) // вернётся в Redis в Close
+			return
+		}
+	}
+}
+
+// hold придерживает задание локально (held): оно вернётся в Redis при
+// ResumeHost или Close.
+func (q *RedisQueue) hold(j Job) {
+	q.mu.Lock()
+	q.held = append(q.held, j)
+	q.mu.Unlock()
+}
+
+// Reprioritize меняет приоритет ждущих заданий задачи taskID в Redis
+// (ready и delayed) и среди придержанных. Просматривает очередь целиком —
+// операция редкая. Задания, уже забранные экземплярами в буфер воркеров,
+// сохраняют старый приоритет. Возвращает число изменённых заданий.
+func (q *RedisQueue) Reprioritize(taskID string, priority int) int {
+	n := 0
+	q.mu.Lock()
+	for i := range q.held {
+		if q.held[i].TaskID == taskID && q.held[i].Priority != priority {
+			q.held[i].Priority = priority
+			n++
+		}
+	}
+	q.mu.Unlock()
+	for _, key := range []string{q.ready, q.delay} {
+		members, err := redis.Strings(q.do(0, "ZRANGE", key, "0", "-1", "WITHSCORES"))
+		if err != nil {
+			continue
+		}
+		for i := 0; i+1 < len(members); i += 2 {
+			j, err := decodeJob(members[i])
+			if err != nil || j.TaskID != taskID || j.Priority == priority {
+				continue
+			}
+			if rm, err := redis.Int(q.do(0, "ZREM", key, members[i])); err != nil || rm == 0 {
+				continue // задание уже забрали
+			}
+			j.Priority = priority
+			score := members[i+1]
+			if key == q.ready {
+				score = readyScore(j, time.Now())
+			}
+			if _, err := q.do(0, "ZADD", key, score, encodeJob(j)); err == nil {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Remove убирает ждущие задания задачи taskID из Redis (ready и delayed)
+// и среди придержанных. Как Reprioritize, просматривает очередь целиком;
+// задания, уже забранные экземплярами в буфер воркеров, остаются — воркер
+// отбросит их как устаревшие. Возвращает число убранных заданий.
+func (q *RedisQueue) Remove(taskID string) int {
+	n := 0
+	q.mu.Lock()
+	kept := q.held[:0]
+	for _, j := range q.held {
+		if j.TaskID == taskID {
+			n++
+			continue
+		}
+		kept = append(kept, j)
+	}
+	clear(q.held[len(kept):])
+	q.held = kept
+	q.mu.Unlock()
+	for _, key := range []string{q.ready, q.delay} {
+		members, err := redis.Strings(q.do(0, "ZRANGE", key, "0", "-1"))
+		if err != nil {
+			continue
+		}
+		for _, m := range members {
+			j, err := decodeJob(m)
+			if err != nil || j.TaskID != taskID {
+				continue
+			}
+			if rm, err := redis.Int(q.do(0, "ZREM", key, m)); err == nil && rm > 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// PauseHost приостанавливает выдачу этому экземпляру заданий хоста host:
+// такие задания из буфера воркеров и забираемые дальше придерживаются локально.
+// Другие экземпляры их по-прежнему выдают, если у них хост не на паузе.
+// Возвращает false, если хост уже был приостановлен.
+func (q *RedisQueue) PauseHost(host string) bool {
+	host = strings.ToLower(host)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pausedHosts[host] {
+		return false
+	}
+	if q.pausedHosts == nil {
+		q.pausedHosts = make(map[string]bool)
+	}
+	q.pausedHosts[host] = true
+	if q.closed.Load() {
+		return true
+	}
+	// задания хоста из буфера воркеров — в held, остальные — обратно в буфер
+	var keep []Job
+pull:
+	for range len(q.taskCh) {
+		select {
+		case j := <-q.taskCh:
+			if q.hostPausedLocked(j.Host) {
+				q.held = append(q.held, j)
+				q.metrics.requeued.Inc()
+			} else {
+				keep = append(keep, j)
+			}
+		default:
+			break pull
+		}
+	}
+	for _, j := range keep {
+		select {
+		case q.taskCh <- j:
+		default:
+			q.held = append(q.held, j) // буфер успел заполниться — вернём в Redis ниже
+		}
+	}
+	return true
+}
+
+// ResumeHost снимает паузу с хоста и возвращает его придержанные задания в Redis
+// (и туда же — случайно придержанные при PauseHost задания других хостов).
+// Возвращает false, если хост не был приостановлен.
+func (q *RedisQueue) ResumeHost(host string) bool {
+	host = strings.ToLower(host)
+	q.mu.Lock()
+	if !q.pausedHosts[host] {
+		q.mu.Unlock()
+		return false
+	}
+	delete(q.pausedHosts, host)
+	var back []Job
+	kept := q.held[:0]
+	for _, j := range q.held {
+		if q.hostPausedLocked(j.Host) {
+			kept = append(kept, j)
+		} else {
+			back = append(back, j)
+		}
+	}
+	clear(q.held[len(kept):])
+	q.held = kept
+	q.mu.Unlock()
+	if err := q.push(back...); err != nil {
+		for _, j := range back {
+			q.hold(j) // вернём при следующем ResumeHost/Close
+		}
+	}
+	return true
+}
+
+// PausedHosts возвращает приостановленные на этом экземпляре хосты по алфавиту.
+func (q *RedisQueue) PausedHosts() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	hosts := make([]string, 0, len(q.pausedHosts))
+	for h := range q.pausedHosts {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+func (q *RedisQueue) hostPausedLocked(host string) bool {
+	return len(q.pausedHosts) > 0 && q.pausedHosts[strings.ToLower(host)]
+}
+
+// Stats — заполненность очереди: Backlog и Delayed — размеры общих sorted set'ов
+// в Redis (плюс придержанные этим экземпляром задания), Inbound и Outbound —
+// локальные каналы. Error — последняя ошибка Redis, если последнее обращение неудачно.
+func (q *RedisQueue) Stats() Stats {
+	q.mu.Lock()
+	held := len(q.held)
+	q.mu.Unlock()
+	st := Stats{
+		Inbound:     len(q.jobInCh),
+		InboundCap:  cap(q.jobInCh),
+		Backlog:     held,
+		Outbound:    len(q.taskCh),
+		OutboundCap: cap(q.taskCh),
+		Drain:       q.IsDrain(),
+		PausedHosts: q.PausedHosts(),
+		Backend:     "redis",
+	}
+	if !q.closed.Load() {
+		if n, err := redis.Int(q.do(0, "ZCARD", q.ready)); err == nil {
+			st.Backlog += int(n)
+		}
+		if n, err := redis.Int(q.do(0, "ZCARD", q.delay)); err == nil {
+			st.Delayed = int(n)
+		}
+	}
+	if e := q.lastErr.Load(); e != nil {
+		st.Error = *e
+	}
+	return st
+}
+
+// RegisterMetrics — метрики downloader_queue_* (как у Dispatcher).
+func (q *RedisQueue) RegisterMetrics(r *metrics.Registry) {
+	registerMetrics(r, q.metrics, q.Stats)
+}
+
+// do выполняет команду на основном соединении и запоминает исход для Stats.Error
+// (ответ-ошибка сервера считается ошибкой, а не сбоем связи, но тоже видна).
+func (q *RedisQueue) do(block time.Duration, args ...string) (any, error) {
+	v, err := q.cli.Do(context.Background(), block, args...)
+	q.setErr(err)
+	return v, err
+}
+
+func (q *RedisQueue) setErr(err error) {
+	if err == nil {
+		if q.lastErr.Load() != nil {
+			log.Printf("Queue: redis is reachable again")
+			q.lastErr.Store(nil)
+		}
+		return
+	}
+	msg := err.Error()
+	if prev := q.lastErr.Swap(&msg); prev == nil || *prev != msg {
+		log.Printf("Queue: redis: %v", err)
+	}
+}
+
+// readyScore — оценка задания в <prefix>:ready: меньше — раньше. Приоритеты
+// разнесены на redisPriorityStep, внутри приоритета — время постановки в мс
+// (FIFO), повторы сдвинуты на redisRetryAdvance вперёд всех ждущих,
+// прерванные перезапуском загрузки — на redisResumeAdvance (впереди повторов).
+func readyScore(j Job, now time.Time) string {
+	score := -float64(j.Priority)*redisPriorityStep + float64(now.UnixMilli())
+	switch {
+	case j.Resume:
+		score -= redisResumeAdvance
+	case j.Retry:
+		score -= redisRetryAdvance
+	}
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+func encodeJob(j Job) string {
+	b, _ := json.Marshal(redisJob{TaskID: j.TaskID, FileID: j.FileID, Host: j.Host, Priority: j.Priority, Retry: j.Retry, Resume: j.Resume, Bounces: j.Bounces})
+	return string(b)
+}
+
+func decodeJob(s string) (Job, error) {
+	var rj redisJob
+	if err := json.Unmarshal([]byte(s), &rj); err != nil {
+		return Job{}, err
+	}
+	if rj.TaskID == "" || rj.FileID == "" {
+		return Job{}, errors.New("missing task or file id")
+	}
+	return Job{TaskID: rj.TaskID, FileID: rj.FileID, Host: rj.Host, Priority: rj.Priority, Retry: rj.Retry, Resume: rj.Resume, Bounces: rj.Bounces}, nil
+}
@@ -0,0 +1,263 @@
+// Package redis — минимальный клиент Redis (протокол RESP2) на стандартной
+// библиотеке: ровно то, что нужно очереди заданий (queue.RedisQueue) и выбору
+// лидера (Lease) — команды отправляются как массив строк, ответы разбираются
+// в Go-значения.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options — параметры подключения (ParseURL).
+type Options struct {
+	Addr     string // host:port
+	Username string // ACL (Redis 6+); пусто — AUTH только паролем
+	Password string
+	DB       int
+	Timeout  time.Duration // подключение и обычные команды; блокирующим добавляется их ожидание
+}
+
+// ParseURL разбирает адрес вида redis://[user:password@]host[:port][/db].
+// Порт по умолчанию — 6379, таймаут — 5s.
+func ParseURL(raw string) (Options, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Options{}, err
+	}
+	if u.Scheme != "redis" {
+		return Options{}, fmt.Errorf("redis: unsupported scheme %q (expected redis://)", u.Scheme)
+	}
+	if u.Host == "" {
+		return Options{}, errors.New("redis: empty host")
+	}
+	o := Options{Addr: u.Host, Timeout: 5 * time.Second}
+	if u.Port() == "" {
+		o.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		o.Password, _ = u.User.Password()
+		if o.Password == "" {
+			o.Password = u.User.Username() // redis://secret@host — пароль без имени
+		} else {
+			o.Username = u.User.Username()
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if o.DB, err = strconv.Atoi(db); err != nil || o.DB < 0 {
+			return Options{}, fmt.Errorf("redis: bad database %q", db)
+		}
+	}
+	return o, nil
+}
+
+// Error — ответ-ошибка сервера ("-ERR ..."). Соединение после неё остаётся рабочим.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// Client — одно соединение с сервером, команды выполняются по очереди.
+// Соединение устанавливается при первой команде и заново — после сетевой
+// ошибки (сама команда при этом не повторяется: это решает вызывающий).
+// Блокирующие команды (BZPOPMIN) держат соединение на всё ожидание —
+// для них заводите отдельный Client.
+type Client struct {
+	opts Options
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// New возвращает клиента; подключения ещё нет.
+func New(opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	return &Client{opts: opts}
+}
+
+// Close закрывает соединение (если есть). Клиент можно использовать дальше —
+// следующая команда подключится заново.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn, c.rd = nil, nil
+	return err
+}
+
+// Do выполняет команду args и возвращает ответ:
+//   - простая строка и bulk-строка — string, пустой bulk/массив (nil) — nil;
+//   - целое — int64, массив — []any;
+//   - ответ-ошибка — Error.
+//
+// Срок — дедлайн ctx, а без него Options.Timeout; block добавляется к сроку
+// для блокирующих команд (их собственный таймаут ожидания).
+func (c *Client) Do(ctx context.Context, block time.Duration, args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.dialLocked(ctx); err != nil {
+			return nil, err
+		}
+	}
+	deadline := time.Now().Add(c.opts.Timeout + block)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = c.conn.SetDeadline(deadline)
+	v, err := c.roundTripLocked(args)
+	var rerr Error
+	if err != nil && !errors.As(err, &rerr) {
+		// сетевая ошибка или рассинхронизация протокола: соединение не переиспользуем
+		c.conn.Close()
+		c.conn, c.rd = nil, nil
+	}
+	return v, err
+}
+
+func (c *Client) dialLocked(ctx context.Context) error {
+	d := net.Dialer{Timeout: c.opts.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return err
+	}
+	c.conn, c.rd = conn, bufio.NewReader(conn)
+	_ = conn.SetDeadline(time.Now().Add(c.opts.Timeout))
+	var setup [][]string
+	switch {
+	case c.opts.Username != "":
+		setup = append(setup, []string{"AUTH", c.opts.Username, c.opts.Password})
+	case c.opts.Password != "":
+		setup = append(setup, []string{"AUTH", c.opts.Password})
+	}
+	if c.opts.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.opts.DB)})
+	}
+	for _, cmd := range setup {
+		if _, err := c.roundTripLocked(cmd); err != nil {
+			conn.Close()
+			c.conn, c.rd = nil, nil
+			return fmt.Errorf("redis %s: %w", strings.ToLower(cmd[0]), err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) roundTripLocked(args []string) (any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readReply(c.rd)
+}
+
+// readReply читает один ответ RESP2.
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: bad reply line %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = readReply(rd); err != nil {
+				var rerr Error
+				if !errors.As(err, &rerr) {
+					return nil, err
+				}
+				arr[i] = rerr
+			}
+		}
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+	}
+}
+
+// Int приводит ответ Do к int64 (для ZADD, ZREM, ZCARD и т.п.).
+func Int(v any, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: expected integer, got %T", v)
+	}
+	return n, nil
+}
+
+// Strings приводит ответ-массив Do к []string (nil-элементы — пустые строки).
+func Strings(v any, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("redis: expected array, got %T", v)
+	}
+	out := make([]string, len(arr))
+	for i, x := range arr {
+		switch x := x.(type) {
+		case string:
+			out[i] = x
+		case nil:
+		case int64:
+			out[i] = strconv.FormatInt(x, 10)
+		default:
+			return nil, fmt.Errorf("redis: unexpected array element %T", x)
+		}
+	}
+	return out, nil
+}
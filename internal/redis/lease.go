@@ -0,0 +1,70 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Скрипты продления и снятия аренды: ключ меняет только её владелец
+// (проверка и действие атомарны на стороне Redis).
+const (
+	leaseRenewScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`
+	leaseFreeScript  = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+)
+
+// Lease — аренда ключа в Redis (выбор лидера): ключ key со значением id
+// и сроком жизни ttl. Владелец продлевает аренду раньше её истечения
+// (Renew); не успел — её может взять другой (Acquire).
+type Lease struct {
+	cli *Client
+	key string
+	id  string
+	ttl time.Duration
+}
+
+// NewLease описывает аренду key для претендента id.
+func NewLease(cli *Client, key, id string, ttl time.Duration) *Lease {
+	return &Lease{cli: cli, key: key, id: id, ttl: ttl}
+}
+
+// ID — идентификатор претендента.
+func (l *Lease) ID() string { return l.id }
+
+// Acquire пытается взять аренду (SET NX PX). ok=false — аренда у другого,
+// holder — его идентификатор (пусто, если аренда истекла между командами).
+// Повторный Acquire владельцем продлевает аренду.
+func (l *Lease) Acquire(ctx context.Context) (ok bool, holder string, err error) {
+	ttl := strconv.FormatInt(l.ttl.Milliseconds(), 10)
+	v, err := l.cli.Do(ctx, 0, "SET", l.key, l.id, "NX", "PX", ttl)
+	if err != nil {
+		return false, "", err
+	}
+	if v != nil {
+		return true, l.id, nil
+	}
+	v, err = l.cli.Do(ctx, 0, "GET", l.key)
+	if err != nil {
+		return false, "", err
+	}
+	holder, _ = v.(string)
+	if holder == l.id {
+		ok, err = l.Renew(ctx)
+		return ok, holder, err
+	}
+	return false, holder, nil
+}
+
+// Renew продлевает аренду на ttl, если она всё ещё у этого претендента.
+// ok=false — аренда истекла и/или перешла к другому.
+func (l *Lease) Renew(ctx context.Context) (bool, error) {
+	n, err := Int(l.cli.Do(ctx, 0, "EVAL", leaseRenewScript, "1", l.key, l.id, strconv.FormatInt(l.ttl.Milliseconds(), 10)))
+	return n == 1, err
+}
+
+// Release снимает аренду, если она у этого претендента: следующий
+// претендент возьмёт её, не дожидаясь истечения ttl.
+func (l *Lease) Release(ctx context.Context) error {
+	_, err := l.cli.Do(ctx, 0, "EVAL", leaseFreeScript, "1", l.key, l.id)
+	return err
+}
@@ -0,0 +1,39 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// s3Sink — выгрузка в S3 (и совместимые хранилища) через storage.S3:
+// файл читается один раз, потоково; больше одной части — multipart-загрузка.
+type s3Sink struct {
+	scheme string // "s3" или "gs" — для адреса объекта в ответе Upload
+	store  *storage.S3
+	bucket string
+	prefix string
+}
+
+func (s *s3Sink) Upload(ctx context.Context, key, localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	name := joinKey(s.prefix, key)
+	w, err := s.store.Create(ctx, storage.ObjectScheme+s.bucket+"/"+name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		_ = w.Abort()
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return s.scheme + "://" + s.bucket + "/" + name, nil
+}
@@ -0,0 +1,124 @@
+// Package sink — выгрузка скачанных файлов во внешние хранилища после загрузки
+// (app.uploadLoop): S3 и совместимые (MinIO, Ceph), Google Cloud Storage
+// (через XML API с HMAC-ключами — тот же протокол S3) и WebDAV. S3 и GCS —
+// через storage.S3 (AWS Signature V4, multipart-загрузка больших файлов).
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Extrarius/29.09.2025/internal/storage"
+)
+
+// Sink — хранилище, куда выгружаются файлы.
+type Sink interface {
+	// Upload выгружает локальный файл localPath под ключом key (относительный
+	// путь через "/") и возвращает адрес объекта (без секретов).
+	Upload(ctx context.Context, key, localPath string) (location string, err error)
+}
+
+// New создаёт Sink по адресу:
+//   - s3://bucket[/prefix]?region=...&endpoint=https://...&path_style=true —
+//     ключи доступа из userinfo (access:secret@) или AWS_ACCESS_KEY_ID,
+//     AWS_SECRET_ACCESS_KEY (и AWS_SESSION_TOKEN); регион по умолчанию us-east-1;
+//   - gs://bucket[/prefix] — Google Cloud Storage, HMAC-ключи из userinfo или
+//     GCS_HMAC_ACCESS_ID, GCS_HMAC_SECRET;
+//   - webdav://host/path, webdavs://host/path — WebDAV по HTTP/HTTPS,
+//     Basic-аутентификация из userinfo.
+func New(rawURL string, client *http.Client) (Sink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "s3", "gs":
+		if u.Host == "" {
+			return nil, fmt.Errorf("не задан bucket")
+		}
+		q := u.Query()
+		opts := storage.S3Options{
+			Endpoint:  q.Get("endpoint"),
+			Region:    q.Get("region"),
+			PathStyle: q.Get("path_style") == "true",
+			Client:    client,
+		}
+		idEnv, secretEnv := "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"
+		if u.Scheme == "gs" {
+			idEnv, secretEnv = "GCS_HMAC_ACCESS_ID", "GCS_HMAC_SECRET"
+			if opts.Endpoint == "" {
+				opts.Endpoint = "https://storage.googleapis.com"
+			}
+			if opts.Region == "" {
+				opts.Region = "auto"
+			}
+			opts.PathStyle = true
+		} else {
+			opts.Token = os.Getenv("AWS_SESSION_TOKEN")
+		}
+		if u.User != nil {
+			opts.AccessKey = u.User.Username()
+			opts.SecretKey, _ = u.User.Password()
+		} else {
+			opts.AccessKey, opts.SecretKey = os.Getenv(idEnv), os.Getenv(secretEnv)
+		}
+		if opts.AccessKey == "" || opts.SecretKey == "" {
+			return nil, fmt.Errorf("не заданы ключи доступа (userinfo или %s/%s)", idEnv, secretEnv)
+		}
+		store, err := storage.NewS3(opts)
+		if err != nil {
+			return nil, err
+		}
+		return &s3Sink{scheme: u.Scheme, store: store, bucket: u.Host, prefix: prefix}, nil
+	case "webdav", "webdavs":
+		if u.Host == "" {
+			return nil, fmt.Errorf("не задан адрес сервера")
+		}
+		base := *u
+		base.Scheme = "http"
+		if u.Scheme == "webdavs" {
+			base.Scheme = "https"
+		}
+		base.User, base.RawQuery = nil, ""
+		base.Path = "/" + prefix
+		d := &davSink{client: client, base: &base}
+		if u.User != nil {
+			d.user = u.User.Username()
+			d.pass, _ = u.User.Password()
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("ожидается s3://, gs://, webdav:// или webdavs://, получено %q", u.Scheme)
+}
+
+// Redact убирает секрет из адреса хранилища (для вывода конфигурации).
+func Redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "***")
+	}
+	return u.String()
+}
+
+// joinKey склеивает префикс хранилища и ключ объекта.
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return path.Join(prefix, key)
+}
+
+// StatusError — ответ хранилища с кодом ошибки.
+type StatusError = storage.StatusError
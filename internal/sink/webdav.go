@@ -0,0 +1,87 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// davSink — выгрузка на WebDAV-сервер: недостающие каталоги создаются MKCOL,
+// файл пишется PUT (существующий перезаписывается).
+type davSink struct {
+	client *http.Client
+	base   *url.URL // адрес корня выгрузки, без userinfo
+	user   string
+	pass   string
+}
+
+func (d *davSink) Upload(ctx context.Context, key, localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	// каталоги по пути к файлу: base/a, base/a/b, …
+	dir := path.Dir(key)
+	if dir != "." {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			if err := d.mkcol(ctx, strings.Join(parts[:i+1], "/")); err != nil {
+				return "", err
+			}
+		}
+	}
+	target := d.url(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, f)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = fi.Size()
+	if err := d.do(req, http.StatusOK, http.StatusCreated, http.StatusNoContent); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// mkcol создаёт коллекцию rel; уже существующая (405) — не ошибка.
+func (d *davSink) mkcol(ctx context.Context, rel string) error {
+	req, err := http.NewRequestWithContext(ctx, "MKCOL", d.url(rel), nil)
+	if err != nil {
+		return err
+	}
+	return d.do(req, http.StatusCreated, http.StatusMethodNotAllowed)
+}
+
+func (d *davSink) url(rel string) string {
+	u := *d.base
+	u.Path = path.Join(d.base.Path, rel)
+	return u.String()
+}
+
+// do выполняет запрос и проверяет, что код ответа — один из ok.
+func (d *davSink) do(req *http.Request, ok ...int) error {
+	if d.user != "" {
+		req.SetBasicAuth(d.user, d.pass)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			return nil
+		}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &StatusError{Method: req.Method, URL: req.URL.String(), Code: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+)
+
+// moveFile переносит файл oldName в newName (существующий newName
+// заменяется). Обычно это os.Rename; если файлы на разных файловых системах
+// (EXDEV: .part во временном каталоге, каталог назначения — смонтированный
+// том), rename невозможен, и файл копируется (copyAcross).
+func moveFile(oldName, newName string) error {
+	err := os.Rename(oldName, newName)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	return copyAcross(oldName, newName)
+}
+
+// copyAcross переносит oldName в newName на другую файловую систему так же
+// атомарно, как rename: содержимое копируется во временный файл рядом с
+// newName, сбрасывается на диск (fsync), временный файл переименовывается в
+// newName, и только потом удаляется oldName. Под именем newName никогда не
+// виден недописанный файл; сбой посреди копирования оставляет oldName на
+// месте (временный ".<имя>.*.tmp" удаляется, а после аварии его уберёт GC).
+func copyAcross(oldName, newName string) error {
+	src, err := os.Open(oldName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	st, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(newName)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(newName)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, src)
+	if err == nil {
+		err = tmp.Chmod(st.Mode().Perm())
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), newName)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	SyncParent(newName)
+	src.Close()
+	return os.Remove(oldName)
+}
+
+// SyncParent сбрасывает на диск каталог файла name — запись о его имени
+// после rename: без этого при сбое питания переименование может пропасть.
+// Для объектов S3 ничего не делает. Платформы, где каталог нельзя открыть
+// для fsync (Windows), — не ошибка.
+func SyncParent(name string) error {
+	if IsObject(name) {
+		return nil
+	}
+	d, err := os.Open(filepath.Dir(name))
+	if err != nil {
+		if runtime.GOOS == "windows" {
+			return nil
+		}
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && runtime.GOOS != "windows" {
+		return err
+	}
+	return nil
+}
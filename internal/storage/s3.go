@@ -0,0 +1,474 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPartSize — начальный размер части multipart-загрузки. Часть
+	// держится в памяти до отправки; после каждой тысячи частей размер
+	// удваивается, чтобы уложиться в предел S3 в 10 000 частей.
+	defaultPartSize = 8 << 20
+	// maxCopySize — предел CopyObject; больше копируется по частям (UploadPartCopy).
+	maxCopySize = 5 << 30
+	// copyPartSize — размер части при копировании по частям.
+	copyPartSize = 1 << 30
+)
+
+// S3Options — параметры доступа к S3 (NewS3).
+type S3Options struct {
+	Endpoint  string // пусто — https://s3.<Region>.amazonaws.com
+	Region    string // пусто — us-east-1
+	PathStyle bool   // bucket в пути, а не в имени хоста (MinIO и большинство совместимых)
+	AccessKey string
+	SecretKey string
+	Token     string       // сессионный токен временных ключей (AWS_SESSION_TOKEN)
+	PartSize  int64        // начальный размер части multipart-загрузки; 0 — 8 MiB
+	Client    *http.Client // nil — http.DefaultClient
+}
+
+// S3 — объектное хранилище S3: имена — "s3://bucket/key" (bucket любой,
+// доступный ключам). Запросы подписываются AWS Signature V4 без хеша тела
+// (UNSIGNED-PAYLOAD), поэтому файл пишется потоково, без второго прохода:
+//   - Create копит данные частями и, если файл больше одной части, ведёт
+//     multipart-загрузку; объект появляется только при Close (CompleteMultipartUpload);
+//   - Rename — копирование на стороне сервера (CopyObject, больше 5 GiB —
+//     UploadPartCopy) и удаление исходного объекта.
+type S3 struct {
+	opts     S3Options
+	endpoint *url.URL
+}
+
+// NewS3 проверяет параметры и создаёт хранилище.
+func NewS3(opts S3Options) (*S3, error) {
+	if opts.AccessKey == "" || opts.SecretKey == "" {
+		return nil, errors.New("не заданы ключи доступа")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.PartSize <= 0 {
+		opts.PartSize = defaultPartSize
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	ep := &url.URL{Scheme: "https", Host: "s3." + opts.Region + ".amazonaws.com"}
+	if opts.Endpoint != "" {
+		e, err := url.Parse(opts.Endpoint)
+		if err != nil || (e.Scheme != "http" && e.Scheme != "https") || e.Host == "" {
+			return nil, fmt.Errorf("endpoint: ожидается http(s)-URL, получено %q", opts.Endpoint)
+		}
+		ep = &url.URL{Scheme: e.Scheme, Host: e.Host}
+	}
+	return &S3{opts: opts, endpoint: ep}, nil
+}
+
+// splitName разбирает "s3://bucket/key".
+func splitName(name string) (bucket, key string, err error) {
+	rest, ok := strings.CutPrefix(name, ObjectScheme)
+	if ok {
+		bucket, key, ok = strings.Cut(rest, "/")
+	}
+	if !ok || bucket == "" || key == "" {
+		return "", "", fmt.Errorf("bad object address %q: want s3://bucket/key", name)
+	}
+	return bucket, key, nil
+}
+
+func (s *S3) Create(ctx context.Context, name string) (Writer, error) {
+	bucket, key, err := splitName(name)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Writer{s: s, ctx: ctx, bucket: bucket, key: key, partSize: s.opts.PartSize}, nil
+}
+
+func (s *S3) Rename(ctx context.Context, oldName, newName string) error {
+	srcBucket, srcKey, err := splitName(oldName)
+	if err != nil {
+		return err
+	}
+	bucket, key, err := splitName(newName)
+	if err != nil {
+		return err
+	}
+	info, err := s.Stat(ctx, oldName)
+	if err != nil {
+		return err
+	}
+	source := escape(srcBucket+"/"+srcKey, true)
+	if info.Size <= maxCopySize {
+		hdr := http.Header{"X-Amz-Copy-Source": {source}}
+		if _, err := s.call(ctx, http.MethodPut, bucket, key, nil, nil, 0, hdr); err != nil {
+			return err
+		}
+	} else if err := s.copyParts(ctx, bucket, key, source, info.Size); err != nil {
+		return err
+	}
+	return s.Remove(ctx, oldName)
+}
+
+// copyParts копирует объект source размером size по частям (UploadPartCopy).
+func (s *S3) copyParts(ctx context.Context, bucket, key, source string, size int64) error {
+	uploadID, err := s.createUpload(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	var parts []completedPart
+	for off := int64(0); off < size; off += copyPartSize {
+		end := min(off+copyPartSize, size) - 1
+		q := url.Values{"partNumber": {strconv.Itoa(len(parts) + 1)}, "uploadId": {uploadID}}
+		hdr := http.Header{
+			"X-Amz-Copy-Source":       {source},
+			"X-Amz-Copy-Source-Range": {fmt.Sprintf("bytes=%d-%d", off, end)},
+		}
+		var res struct {
+			ETag string `xml:"ETag"`
+		}
+		body, err := s.call(ctx, http.MethodPut, bucket, key, q, nil, 0, hdr)
+		if err == nil {
+			err = xml.Unmarshal(body, &res)
+		}
+		if err != nil {
+			s.abortUpload(bucket, key, uploadID)
+			return err
+		}
+		parts = append(parts, completedPart{Number: len(parts) + 1, ETag: res.ETag})
+	}
+	if err := s.completeUpload(ctx, bucket, key, uploadID, parts); err != nil {
+		s.abortUpload(bucket, key, uploadID)
+		return err
+	}
+	return nil
+}
+
+func (s *S3) Stat(ctx context.Context, name string) (Info, error) {
+	bucket, key, err := splitName(name)
+	if err != nil {
+		return Info{}, err
+	}
+	resp, err := s.send(ctx, http.MethodHead, bucket, key, nil, nil, 0, nil)
+	if err != nil {
+		var se *StatusError
+		if errors.As(err, &se) && se.Code == http.StatusNotFound {
+			return Info{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+		}
+		return Info{}, err
+	}
+	resp.Body.Close()
+	info := Info{Size: resp.ContentLength}
+	info.ModTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info, nil
+}
+
+// Remove удаляет объект; отсутствующий объект S3 ошибкой не считает.
+func (s *S3) Remove(ctx context.Context, name string) error {
+	bucket, key, err := splitName(name)
+	if err != nil {
+		return err
+	}
+	_, err = s.call(ctx, http.MethodDelete, bucket, key, nil, nil, 0, nil)
+	return err
+}
+
+// completedPart — загруженная часть (тело CompleteMultipartUpload).
+type completedPart struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+}
+
+func (s *S3) createUpload(ctx context.Context, bucket, key string) (string, error) {
+	body, err := s.call(ctx, http.MethodPost, bucket, key, url.Values{"uploads": {""}}, nil, 0,
+		http.Header{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &res); err != nil || res.UploadID == "" {
+		return "", fmt.Errorf("create multipart upload %s/%s: bad response: %.200s", bucket, key, body)
+	}
+	return res.UploadID, nil
+}
+
+func (s *S3) completeUpload(ctx context.Context, bucket, key, uploadID string, parts []completedPart) error {
+	doc, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	_, err = s.call(ctx, http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}},
+		bytes.NewReader(doc), int64(len(doc)), nil)
+	return err
+}
+
+// abortUpload отменяет multipart-загрузку, чтобы S3 не хранил её части.
+// Выполняется и после отмены контекста загрузки.
+func (s *S3) abortUpload(bucket, key, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, _ = s.call(ctx, http.MethodDelete, bucket, key, url.Values{"uploadId": {uploadID}}, nil, 0, nil)
+}
+
+// call выполняет запрос (send) и возвращает тело ответа. Ответ 200 с <Error>
+// в теле (так S3 сообщает об ошибке копирования и CompleteMultipartUpload) — ошибка.
+func (s *S3) call(ctx context.Context, method, bucket, key string, q url.Values, body io.Reader, size int64, hdr http.Header) ([]byte, error) {
+	resp, err := s.send(ctx, method, bucket, key, q, body, size, hdr)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Contains(data, []byte("<Error>")) {
+		return nil, &StatusError{Method: method, URL: resp.Request.URL.String(), Code: resp.StatusCode, Body: strings.TrimSpace(string(data[:min(len(data), 512)]))}
+	}
+	return data, nil
+}
+
+// send подписывает и отправляет запрос к объекту; ответ не 2xx — *StatusError.
+// Тело успешного ответа закрывает вызывающий.
+func (s *S3) send(ctx context.Context, method, bucket, key string, q url.Values, body io.Reader, size int64, hdr http.Header) (*http.Response, error) {
+	u := *s.endpoint
+	objPath := "/" + key
+	if s.opts.PathStyle {
+		objPath = "/" + bucket + objPath
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+	u.Path, u.RawPath = objPath, escape(objPath, true)
+	u.RawQuery = canonicalQuery(q)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for k, v := range hdr {
+		req.Header[k] = v
+	}
+	s.sign(req, time.Now().UTC())
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &StatusError{Method: method, URL: u.String(), Code: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+// sign подписывает запрос AWS Signature V4 (заголовок Authorization).
+func (s *S3) sign(req *http.Request, now time.Time) {
+	const payload = "UNSIGNED-PAYLOAD"
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if s.opts.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", s.opts.Token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		signed,
+		payload,
+	}, "\n")
+	scope := day + "/" + s.opts.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonical)
+
+	k := hmacSHA256([]byte("AWS4"+s.opts.SecretKey), day)
+	k = hmacSHA256(k, s.opts.Region)
+	k = hmacSHA256(k, "s3")
+	k = hmacSHA256(k, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(k, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKey, scope, signed, sig))
+}
+
+// canonicalQuery кодирует параметры запроса в каноническом для SigV4 виде
+// (ключи по порядку, %XX-кодирование); так же они уходят в URL.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, escape(k, false)+"="+escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escape кодирует строку по правилам SigV4: всё, кроме незарезервированных
+// символов (A-Z a-z 0-9 - _ . ~) и, при keepSlash, "/", — в %XX.
+func escape(p string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c == '/' && keepSlash || c == '-' || c == '_' || c == '.' || c == '~' ||
+			('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func hexSHA256(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+// s3Writer — запись объекта: данные копятся в buf до размера части; первая
+// полная часть начинает multipart-загрузку, файл меньше части уходит одним PUT.
+type s3Writer struct {
+	s           *S3
+	ctx         context.Context
+	bucket, key string
+
+	buf      bytes.Buffer
+	partSize int64
+	uploadID string
+	parts    []completedPart
+	err      error // первая ошибка; после неё запись невозможна
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		room := min(int(w.partSize)-w.buf.Len(), len(p))
+		w.buf.Write(p[:room])
+		p = p[room:]
+		if int64(w.buf.Len()) >= w.partSize {
+			if err := w.flushPart(); err != nil {
+				w.err = err
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+// BufferedMax — размер части: столько s3Writer держит в памяти. Часть
+// растёт после тысячи частей (8 ГиБ), это не учитывается.
+func (w *s3Writer) BufferedMax() int64 { return w.partSize }
+
+// flushPart отправляет накопленное в buf очередной частью.
+func (w *s3Writer) flushPart() error {
+	if w.uploadID == "" {
+		id, err := w.s.createUpload(w.ctx, w.bucket, w.key)
+		if err != nil {
+			return err
+		}
+		w.uploadID = id
+	}
+	num := len(w.parts) + 1
+	q := url.Values{"partNumber": {strconv.Itoa(num)}, "uploadId": {w.uploadID}}
+	resp, err := w.s.send(w.ctx, http.MethodPut, w.bucket, w.key, q, bytes.NewReader(w.buf.Bytes()), int64(w.buf.Len()), nil)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	w.parts = append(w.parts, completedPart{Number: num, ETag: resp.Header.Get("ETag")})
+	w.buf.Reset()
+	if len(w.parts)%1000 == 0 {
+		w.partSize *= 2
+	}
+	return nil
+}
+
+func (w *s3Writer) Close() error {
+	if w.err != nil {
+		_ = w.Abort()
+		return w.err
+	}
+	if w.uploadID == "" {
+		_, err := w.s.call(w.ctx, http.MethodPut, w.bucket, w.key, nil, bytes.NewReader(w.buf.Bytes()), int64(w.buf.Len()),
+			http.Header{"Content-Type": {"application/octet-stream"}})
+		w.err = errors.New("storage: writer is closed")
+		return err
+	}
+	if w.buf.Len() > 0 {
+		if err := w.flushPart(); err != nil {
+			w.err = err
+			_ = w.Abort()
+			return err
+		}
+	}
+	if err := w.s.completeUpload(w.ctx, w.bucket, w.key, w.uploadID, w.parts); err != nil {
+		w.err = err
+		_ = w.Abort()
+		return err
+	}
+	w.uploadID, w.err = "", errors.New("storage: writer is closed")
+	return nil
+}
+
+func (w *s3Writer) Abort() error {
+	if w.uploadID != "" {
+		w.s.abortUpload(w.bucket, w.key, w.uploadID)
+		w.uploadID = ""
+	}
+	w.buf = bytes.Buffer{}
+	if w.err == nil {
+		w.err = errors.New("storage: writer is aborted")
+	}
+	return nil
+}
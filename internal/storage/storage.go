@@ -0,0 +1,245 @@
+// Package storage — куда пишутся скачанные файлы: локальная файловая система
+// или объектное хранилище S3 (и совместимые: MinIO, Ceph). Имя файла —
+// путь на диске либо адрес объекта "s3://bucket/key"; хранилище выбирается
+// по имени (New), так что загрузчик и приложение работают с ними одинаково.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage — место хранения файлов.
+type Storage interface {
+	// Create открывает name на запись. Содержимое становится видимым под
+	// этим именем только после Writer.Close; Writer.Abort отбрасывает записанное.
+	Create(ctx context.Context, name string) (Writer, error)
+	// Rename переименовывает oldName в newName (существующий newName заменяется).
+	Rename(ctx context.Context, oldName, newName string) error
+	// Stat возвращает размер и время изменения name; нет такого файла —
+	// ошибка, для которой errors.Is(err, fs.ErrNotExist).
+	Stat(ctx context.Context, name string) (Info, error)
+	// Remove удаляет name.
+	Remove(ctx context.Context, name string) error
+}
+
+// Writer — открытый на запись файл (Storage.Create).
+type Writer interface {
+	io.Writer
+	// Close дописывает и публикует файл.
+	Close() error
+	// Abort прерывает запись и удаляет недописанное.
+	Abort() error
+}
+
+// Keeper — Writer, умеющий вместо удаления (Abort) сохранить недописанное
+// под другим именем.
+type Keeper interface {
+	// Keep закрывает файл и переносит записанное в name (существующий
+	// name заменяется); если перенести не удалось — удаляет, как Abort.
+	Keep(name string) error
+}
+
+// Syncer — Writer, умеющий при Close сбросить записанное на диск (fsync),
+// чтобы после rename на место файл пережил сбой питания целиком.
+type Syncer interface {
+	// SyncOnClose включает fsync файла в Close.
+	SyncOnClose()
+}
+
+// Buffered — Writer, который держит записанное в памяти до отправки
+// (часть multipart-загрузки S3). BufferedMax — сколько байт он может
+// держать; загрузчик учитывает это в пределе памяти под буферы.
+type Buffered interface {
+	BufferedMax() int64
+}
+
+// Info — сведения о файле (Storage.Stat).
+type Info struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// ObjectScheme — схема адресов объектного хранилища.
+const ObjectScheme = "s3://"
+
+// ErrNoObjectStore — адрес s3://, но объектное хранилище не настроено.
+var ErrNoObjectStore = errors.New("object storage is not configured")
+
+// IsObject сообщает, что name — адрес объекта ("s3://bucket/key"), а не путь на диске.
+func IsObject(name string) bool { return strings.HasPrefix(name, ObjectScheme) }
+
+// Join склеивает каталог dir и элементы пути (через "/", как dest_subpath):
+// для адреса объекта — path.Join, иначе — filepath.Join.
+func Join(dir string, elem ...string) string {
+	parts := []string{dir}
+	if IsObject(dir) {
+		parts[0] = strings.TrimPrefix(dir, ObjectScheme)
+		return ObjectScheme + path.Join(append(parts, elem...)...)
+	}
+	for _, e := range elem {
+		parts = append(parts, filepath.FromSlash(e))
+	}
+	return filepath.Join(parts...)
+}
+
+// Rel возвращает путь name относительно каталога dir через "/";
+// ok=false — name вне dir.
+func Rel(dir, name string) (rel string, ok bool) {
+	if IsObject(dir) != IsObject(name) {
+		return "", false
+	}
+	if IsObject(dir) {
+		rel, ok = strings.CutPrefix(name, strings.TrimSuffix(dir, "/")+"/")
+		return rel, ok && rel != ""
+	}
+	r, err := filepath.Rel(dir, name)
+	if err != nil || !filepath.IsLocal(r) {
+		return "", false
+	}
+	return filepath.ToSlash(r), true
+}
+
+// Local — локальная файловая система: имена — пути на диске, недостающие
+// каталоги создаются при Create и Rename.
+var Local Storage = localFS{}
+
+type localFS struct{}
+
+func (localFS) Create(_ context.Context, name string) (Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &localFile{File: f}, nil
+}
+
+// Rename переименовывает файл; между файловыми системами — копирует
+// (moveFile).
+func (localFS) Rename(_ context.Context, oldName, newName string) error {
+	if err := os.MkdirAll(filepath.Dir(newName), 0o755); err != nil {
+		return err
+	}
+	return moveFile(oldName, newName)
+}
+
+func (localFS) Stat(_ context.Context, name string) (Info, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+func (localFS) Remove(_ context.Context, name string) error { return os.Remove(name) }
+
+// localFile — файл на диске: Close закрывает его, Abort закрывает и удаляет,
+// Keep (Keeper) закрывает и переносит.
+// Поддерживает Syncer, Preallocator и CacheBypasser (tuning.go).
+type localFile struct {
+	*os.File
+	sync             bool  // SyncOnClose: fsync в Close
+	bypass           bool  // BypassCache: записанное вытесняется из кеша страниц
+	written, dropped int64 // записано байт; из них уже вытеснено из кеша
+}
+
+func (f *localFile) SyncOnClose() { f.sync = true }
+
+func (f *localFile) Abort() error {
+	f.File.Close()
+	return os.Remove(f.Name())
+}
+
+// Keep переносит недописанный файл в name (moveFile: на другой файловой
+// системе — копированием).
+func (f *localFile) Keep(name string) error {
+	f.Close()
+	err := os.MkdirAll(filepath.Dir(name), 0o755)
+	if err == nil {
+		if err = moveFile(f.Name(), name); err == nil {
+			return nil
+		}
+	}
+	os.Remove(f.Name())
+	return err
+}
+
+// New возвращает хранилище, выбирающее бэкенд по имени файла: адреса s3://
+// уходят в objects, остальное — в Local. objects = nil — адреса s3://
+// отвергаются с ErrNoObjectStore.
+func New(objects *S3) Storage {
+	return router{objects: objects}
+}
+
+type router struct{ objects *S3 }
+
+func (r router) pick(name string) (Storage, error) {
+	if !IsObject(name) {
+		return Local, nil
+	}
+	if r.objects == nil {
+		return nil, fmt.Errorf("%s: %w", name, ErrNoObjectStore)
+	}
+	return r.objects, nil
+}
+
+func (r router) Create(ctx context.Context, name string) (Writer, error) {
+	s, err := r.pick(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.Create(ctx, name)
+}
+
+func (r router) Rename(ctx context.Context, oldName, newName string) error {
+	if IsObject(oldName) != IsObject(newName) {
+		return fmt.Errorf("rename %s → %s: different storages", oldName, newName)
+	}
+	s, err := r.pick(oldName)
+	if err != nil {
+		return err
+	}
+	return s.Rename(ctx, oldName, newName)
+}
+
+func (r router) Stat(ctx context.Context, name string) (Info, error) {
+	s, err := r.pick(name)
+	if err != nil {
+		return Info{}, err
+	}
+	return s.Stat(ctx, name)
+}
+
+func (r router) Remove(ctx context.Context, name string) error {
+	s, err := r.pick(name)
+	if err != nil {
+		return err
+	}
+	return s.Remove(ctx, name)
+}
+
+// StatusError — ответ хранилища с кодом ошибки.
+type StatusError struct {
+	Method string
+	URL    string
+	Code   int
+	Body   string // начало тела ответа (сообщение хранилища)
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s %s: HTTP %d", e.Method, e.URL, e.Code)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
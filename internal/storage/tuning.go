@@ -0,0 +1,70 @@
+package storage
+
+// Необязательные оптимизации записи на диск. Writer, который их
+// поддерживает, реализует соответствующий интерфейс; загрузчик проверяет
+// его приведением типа, так что объектное хранилище о них не знает.
+//
+//   - Preallocator — место под файл резервируется заранее одним куском
+//     (fallocate): меньше фрагментации, а нехватка места видна сразу;
+//   - CacheBypasser — записанное периодически сбрасывается на диск и
+//     вытесняется из кеша страниц (fdatasync + posix_fadvise DONTNEED):
+//     огромные разовые загрузки не вытесняют из памяти то, что ещё нужно.
+//     Это замена O_DIRECT, которому нужны выровненные буферы и блоки записи.
+//
+// Обе оптимизации работают на Linux (amd64, arm64); на остальных
+// платформах вызовы ничего не делают.
+
+// Preallocator — Writer, умеющий заранее зарезервировать место под файл.
+type Preallocator interface {
+	// Preallocate резервирует size байт, не меняя размер файла: если данных
+	// окажется меньше, в файле не будет хвоста из нулей.
+	Preallocate(size int64) error
+}
+
+// CacheBypasser — Writer, умеющий не оставлять записанное в кеше страниц.
+type CacheBypasser interface {
+	// BypassCache включает вытеснение записанного из кеша до конца записи.
+	BypassCache()
+}
+
+// bypassChunk — через сколько записанных байт данные сбрасываются на диск и
+// вытесняются из кеша страниц (CacheBypasser).
+const bypassChunk = 8 << 20
+
+func (f *localFile) Preallocate(size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	return preallocate(f.File, size)
+}
+
+func (f *localFile) BypassCache() { f.bypass = true }
+
+func (f *localFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.written += int64(n)
+	if f.bypass && f.written-f.dropped >= bypassChunk {
+		f.dropCache()
+	}
+	return n, err
+}
+
+func (f *localFile) Close() error {
+	if f.bypass && f.written > f.dropped {
+		f.dropCache()
+	}
+	if f.sync {
+		if err := f.File.Sync(); err != nil {
+			f.File.Close()
+			return err
+		}
+	}
+	return f.File.Close()
+}
+
+// dropCache сбрасывает на диск и вытесняет из кеша записанное после
+// f.dropped. Ошибки не важны: это только подсказка ядру.
+func (f *localFile) dropCache() {
+	_ = dropCache(f.File, f.dropped, f.written-f.dropped)
+	f.dropped = f.written
+}
@@ -0,0 +1,37 @@
+//go:build linux && (amd64 || arm64)
+
+package storage
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+const (
+	fallocKeepSize  = 0x1 // FALLOC_FL_KEEP_SIZE
+	fadviseDontNeed = 4   // POSIX_FADV_DONTNEED
+)
+
+// preallocate резервирует size байт под f, не меняя его размер. Файловые
+// системы без fallocate (EOPNOTSUPP) — не ошибка.
+func preallocate(f *os.File, size int64) error {
+	err := syscall.Fallocate(int(f.Fd()), fallocKeepSize, 0, size)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		return nil
+	}
+	return err
+}
+
+// dropCache сбрасывает на диск n байт f с позиции off и просит ядро убрать
+// их из кеша страниц (грязные страницы DONTNEED не вытесняет, отсюда fdatasync).
+func dropCache(f *os.File, off, n int64) error {
+	fd := int(f.Fd())
+	if err := syscall.Fdatasync(fd); err != nil {
+		return err
+	}
+	if _, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, uintptr(fd), uintptr(off), uintptr(n), fadviseDontNeed, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
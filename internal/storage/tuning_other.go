@@ -0,0 +1,10 @@
+//go:build !(linux && (amd64 || arm64))
+
+package storage
+
+import "os"
+
+// preallocate и dropCache — см. tuning_linux.go; здесь ничего не делают.
+func preallocate(*os.File, int64) error { return nil }
+
+func dropCache(*os.File, int64, int64) error { return nil }
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"sync/atomic"
+)
+
+// walBatch — закодированные строки JSONL одной операции Append* (records штук).
+type walBatch struct {
+	data    []byte
+	records int
+}
+
+// asyncWriter — фоновая запись журнала (StartAsync): Append* кладут
+// закодированные записи в ограниченную очередь и возвращаются, не дожидаясь
+// диска; отдельная горутина дописывает всё накопившееся и сбрасывает буфер
+// один раз на пачку.
+//
+// Порядок записей сохраняется: очередь разбирается только под w.mu
+// (drainLocked), и любая синхронная запись сначала дописывает очередь.
+type asyncWriter struct {
+	ch     chan walBatch
+	notify chan struct{} // ёмкость 1: «в очереди что-то есть»
+	stop   chan struct{}
+	done   chan struct{}
+
+	fallbacks atomic.Uint64             // записей, ушедших синхронно из-за полной очереди
+	batches   atomic.Uint64             // сбросов буфера фоновой горутиной
+	failed    atomic.Pointer[asyncFail] // первая ошибка фоновой записи («липкая»)
+}
+
+type asyncFail struct{ err error }
+
+// StartAsync включает фоновую запись с очередью на queue операций (queue <= 0 —
+// ничего не делает, запись остаётся синхронной). Вызывать один раз, до того
+// как журнал стал доступен другим горутинам (обычно сразу после RecoverTasks).
+//
+// Гарантии:
+//   - Append* возвращаются, как только записи поставлены в очередь; на диск
+//     (в файл, без fsync) они попадают пачкой в ближайшие миллисекунды;
+//   - очередь переполнена — запись выполняется синхронно в вызывающей горутине
+//     (сначала дописывается очередь, чтобы не нарушить порядок), ничего не теряется;
+//   - ошибка фоновой записи запоминается: её возвращают последующие Append*
+//     и Check (а значит, и /healthz);
+//   - AppendScheduler, Compact, Stats, Check и Close сначала дописывают очередь;
+//   - Close дожидается записи всей очереди. При аварийном завершении процесса
+//     (kill -9) теряются записи, ещё стоявшие в очереди, — до queue операций.
+func (w *WAL) StartAsync(queue int) {
+	if queue <= 0 || w.async != nil {
+		return
+	}
+	a := &asyncWriter{
+		ch:     make(chan walBatch, queue),
+		notify: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	w.async = a
+	go w.asyncLoop(a)
+}
+
+// asyncLoop — горутина фоновой записи: по сигналу notify дописывает всю
+// очередь и сбрасывает буфер; по stop выходит (остаток дописывает Close).
+func (w *WAL) asyncLoop(a *asyncWriter) {
+	defer close(a.done)
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-a.notify:
+		}
+		w.mu.Lock()
+		err := w.flushLocked()
+		w.mu.Unlock()
+		a.batches.Add(1)
+		if err != nil {
+			a.failed.CompareAndSwap(nil, &asyncFail{err: err})
+		}
+	}
+}
+
+// append записывает закодированную пачку b: в фоновую очередь, если она
+// включена и в ней есть место, иначе — синхронно со сбросом буфера.
+func (w *WAL) append(b walBatch) error {
+	if a := w.async; a != nil {
+		if f := a.failed.Load(); f != nil {
+			return f.err
+		}
+		select {
+		case a.ch <- b:
+			select {
+			case a.notify <- struct{}{}:
+			default:
+			}
+			return nil
+		default:
+			a.fallbacks.Add(1)
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.drainLocked(); err != nil {
+		return err
+	}
+	if err := w.writeLine(b); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+// drainLocked дописывает в буфер всё, что стоит в фоновой очереди.
+// Без фоновой записи ничего не делает. Вызывать под w.mu.
+func (w *WAL) drainLocked() error {
+	if w.async == nil {
+		return nil
+	}
+	for {
+		select {
+		case b := <-w.async.ch:
+			if err := w.writeLine(b); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// flushLocked дописывает фоновую очередь и сбрасывает буфер в файл. Вызывать под w.mu.
+func (w *WAL) flushLocked() error {
+	if w.fenced {
+		return ErrFenced
+	}
+	if err := w.drainLocked(); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+// stopAsync останавливает фоновую горутину (без записи остатка — его
+// дописывает вызывающий под w.mu). Вызывать без w.mu.
+func (w *WAL) stopAsync() {
+	if a := w.async; a != nil {
+		select {
+		case <-a.stop:
+		default:
+			close(a.stop)
+		}
+		<-a.done
+	}
+}
+
+// AsyncStats — состояние фоновой записи журнала (Stats.Async).
+type AsyncStats struct {
+	Queued        int    `json:"queued"`         // операций в очереди сейчас
+	Capacity      int    `json:"capacity"`       // ёмкость очереди (WAL_ASYNC_QUEUE)
+	Batches       uint64 `json:"batches"`        // сбросов буфера фоновой горутиной
+	SyncFallbacks uint64 `json:"sync_fallbacks"` // записей, выполненных синхронно из-за полной очереди
+	Error         string `json:"error,omitempty"`
+}
+
+// AsyncStats возвращает состояние фоновой записи; ok=false — она выключена.
+func (w *WAL) AsyncStats() (AsyncStats, bool) {
+	a := w.async
+	if a == nil {
+		return AsyncStats{}, false
+	}
+	st := AsyncStats{
+		Queued:        len(a.ch),
+		Capacity:      cap(a.ch),
+		Batches:       a.batches.Load(),
+		SyncFallbacks: a.fallbacks.Load(),
+	}
+	if f := a.failed.Load(); f != nil {
+		st.Error = f.err.Error()
+	}
+	return st, true
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// BlobStore — контентно-адресуемое хранилище скачанных файлов (BLOB_DIR):
+// содержимое лежит один раз в <dir>/<sha[:2]>/<sha>, а файлы в каталогах задач —
+// жёсткие ссылки на него. Одинаковые файлы разных задач занимают место на диске
+// один раз.
+//
+// Учёт ссылок:
+//   - источник истины — журнал: у готового файла задачи core.FileItem.Blob
+//     хранит sha256 его содержимого, удаление задачи пишется записью "delete_task";
+//   - счётчики ссылок держатся в памяти и восстанавливаются на старте по задачам
+//     из WAL (Retain для каждого DONE-файла с Blob);
+//   - Release на последней ссылке удаляет сам blob — только тогда место освобождается.
+//
+// Каталог blob'ов должен быть на той же файловой системе, что и DOWNLOAD_DIR
+// (жёсткие ссылки между ФС невозможны): иначе Ingest возвращает ошибку, а файл
+// остаётся обычным файлом задачи вне хранилища.
+type BlobStore struct {
+	dir string
+
+	mu   sync.Mutex
+	refs map[string]int // sha256 (hex) → число ссылок из задач
+}
+
+// BlobStats — состояние хранилища для GET /admin/store.
+type BlobStats struct {
+	Dir   string `json:"dir"`
+	Blobs int    `json:"blobs"` // blob'ов с хотя бы одной ссылкой
+	Refs  int    `json:"refs"`  // ссылок из файлов задач всего
+}
+
+// OpenBlobStore создаёт (при необходимости) каталог dir и возвращает пустое
+// хранилище; ссылки заполняются вызовами Retain при восстановлении.
+func OpenBlobStore(dir string) (*BlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &BlobStore{dir: dir, refs: make(map[string]int)}, nil
+}
+
+// Dir возвращает каталог хранилища.
+func (b *BlobStore) Dir() string { return b.dir }
+
+// Path возвращает путь к blob'у с хешем sum.
+func (b *BlobStore) Path(sum string) string {
+	return filepath.Join(b.dir, sum[:2], sum)
+}
+
+// Ingest переносит только что скачанный файл path в хранилище и заменяет его
+// жёсткой ссылкой на blob, добавляя одну ссылку. Возвращает sha256 содержимого.
+//
+// Если blob с таким содержимым уже есть, новая копия удаляется, а path
+// становится ссылкой на существующий — так и экономится место. При ошибке
+// path остаётся на месте обычным файлом, ссылка не добавляется.
+func (b *BlobStore) Ingest(path string) (string, error) {
+	sum, err := HashFile(path)
+	if err != nil {
+		return "", err
+	}
+	blob := b.Path(sum)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(blob), 0o755); err != nil {
+		return "", err
+	}
+	switch err := os.Link(path, blob); {
+	case err == nil:
+		// первая копия: path и blob — уже один и тот же inode
+	case errors.Is(err, fs.ErrExist):
+		if err := replaceWithLink(blob, path); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("blob store: %w", err)
+	}
+	b.refs[sum]++
+	return sum, nil
+}
+
+// replaceWithLink атомарно заменяет path жёсткой ссылкой на blob
+// (через временное имя и rename, чтобы path не пропадал ни на миг).
+func replaceWithLink(blob, path string) error {
+	tmp := path + ".blob"
+	os.Remove(tmp)
+	if err := os.Link(blob, tmp); err != nil {
+		return fmt.Errorf("blob store: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Retain добавляет ссылку на blob sum — при восстановлении ссылок из журнала.
+func (b *BlobStore) Retain(sum string) {
+	if sum == "" {
+		return
+	}
+	b.mu.Lock()
+	b.refs[sum]++
+	b.mu.Unlock()
+}
+
+// Release снимает одну ссылку на blob sum; на последней ссылке удаляет файл
+// blob'а (removed=true). Ссылки из каталогов задач удаляет вызывающий.
+// Неизвестный sum — no-op.
+func (b *BlobStore) Release(sum string) (removed bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n, ok := b.refs[sum]
+	if !ok {
+		return false, nil
+	}
+	if n > 1 {
+		b.refs[sum] = n - 1
+		return false, nil
+	}
+	delete(b.refs, sum)
+	if err := os.Remove(b.Path(sum)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	os.Remove(filepath.Dir(b.Path(sum))) // каталог-префикс, если опустел
+	return true, nil
+}
+
+// Evict удаляет файл blob'а sum с повреждённым содержимым, не трогая счётчик
+// ссылок: следующий Ingest того же содержимого положит свежую копию, а не
+// сошлётся на испорченную. Ссылки из каталогов задач (тот же inode) остаются
+// на старом содержимом, пока их не перекачают.
+func (b *BlobStore) Evict(sum string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := os.Remove(b.Path(sum)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Sweep удаляет blob'ы, на которые не ссылается ни одна задача (остались после
+// сбоя между удалением файлов задачи и Release или от задач, удалённых в обход
+// сервиса), с временем изменения до olderThan: свежий blob может ждать Retain
+// из только что восстановленной задачи. dryRun=true — только перечисляет.
+// Возвращает пути удалённых blob'ов и их суммарный размер.
+func (b *BlobStore) Sweep(olderThan time.Time, dryRun bool) (removed []string, bytes int64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err = filepath.WalkDir(b.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() || len(d.Name()) != sha256.Size*2 {
+			return nil
+		}
+		if _, ok := b.refs[d.Name()]; ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().Before(olderThan) {
+			return nil
+		}
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			os.Remove(filepath.Dir(path)) // каталог-префикс, если опустел
+		}
+		removed = append(removed, path)
+		bytes += info.Size()
+		return nil
+	})
+	return removed, bytes, err
+}
+
+// Stats возвращает число blob'ов и ссылок на них.
+func (b *BlobStore) Stats() BlobStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BlobStats{Dir: b.dir, Blobs: len(b.refs)}
+	for _, n := range b.refs {
+		st.Refs += n
+	}
+	return st
+}
+
+// HashFile возвращает sha256 (hex) содержимого файла path.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// CompactStats — итог компактизации журнала.
+type CompactStats struct {
+	RecordsBefore int   `json:"records_before"`
+	RecordsAfter  int   `json:"records_after"`
+	BytesBefore   int64 `json:"bytes_before"`
+	BytesAfter    int64 `json:"bytes_after"`
+	Tasks         int   `json:"tasks"`
+	FromVersion   int   `json:"from_version"`
+	ToVersion     int   `json:"to_version"`
+
+	CompactedAt time.Time `json:"compacted_at"`
+	Duration    string    `json:"duration"`
+}
+
+// Compact переписывает журнал так, чтобы в нём осталась одна запись на задачу
+// (последнее состояние, дельты "upsert_file" свёрнуты), за ней — сохранённые
+// события её истории ("task_event", не более core.MaxTaskEvents), плюс заголовок
+// "meta" с текущим FormatVersion и временем компактизации (см. Stats.SnapshotAge)
+// и — если он отличается от умолчания — режим выдачи ("scheduler").
+//
+// Шаги:
+//   - под мьютексом сбрасывает буфер и перечитывает файл (readWAL);
+//   - пишет новое содержимое во временный файл tasks.wal.compact
+//     (задачи упорядочены по CreatedAt, затем ID), делает fsync;
+//   - атомарно подменяет журнал через rename и переоткрывает его на дозапись.
+//
+// Параллельные AppendTask блокируются на время компактизации, поэтому
+// вызов безопасен и на работающем сервисе.
+func (w *WAL) Compact() (CompactStats, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	start := time.Now()
+	var cs CompactStats
+	if err := w.flushLocked(); err != nil {
+		return cs, err
+	}
+	if fi, err := w.f.Stat(); err == nil {
+		cs.BytesBefore = fi.Size()
+	}
+	st, err := readWAL(w.path, nil)
+	if err != nil {
+		return cs, err
+	}
+	cs.RecordsBefore = st.records
+	cs.FromVersion = st.version
+	cs.ToVersion = FormatVersion
+	cs.Tasks = len(st.tasks)
+
+	tasks := make([]*core.Task, 0, len(st.tasks))
+	for _, t := range st.tasks {
+		tasks = append(tasks, t)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].CreatedAt.Equal(tasks[j].CreatedAt) {
+			return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+
+	tmpPath := w.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return cs, err
+	}
+	tw := &WAL{f: tmp, path: tmpPath, w: bufio.NewWriterSize(tmp, 64*1024)}
+	cs.CompactedAt = start.UTC()
+	writeErr := tw.writeRecord(walRecord{Type: "meta", Version: FormatVersion, At: &cs.CompactedAt})
+	records := 1
+	if writeErr == nil && !st.scheduler.IsZero() {
+		writeErr = tw.writeRecord(walRecord{Type: "scheduler", Scheduler: &st.scheduler})
+		records++
+	}
+	for _, t := range tasks {
+		if writeErr != nil {
+			break
+		}
+		writeErr = tw.writeRecord(walRecord{Type: "upsert_task", Task: t})
+		records++
+		events := t.History().Events
+		for i := 0; i < len(events) && writeErr == nil; i++ {
+			writeErr = tw.writeRecord(walRecord{Type: "task_event", TaskID: t.ID, Event: &events[i]})
+			records++
+		}
+	}
+	if writeErr == nil {
+		writeErr = tw.w.Flush()
+	}
+	if writeErr == nil {
+		writeErr = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		os.Remove(tmpPath)
+		return cs, writeErr
+	}
+	if err := os.Rename(tmpPath, w.path); err != nil {
+		os.Remove(tmpPath)
+		return cs, err
+	}
+
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return cs, err
+	}
+	w.f.Close()
+	w.f = f
+	w.w = bufio.NewWriterSize(f, 64*1024)
+	if fi, err := f.Stat(); err == nil {
+		cs.BytesAfter = fi.Size()
+	}
+	cs.RecordsAfter = records
+	cs.Duration = time.Since(start).Round(time.Millisecond).String()
+	w.records, w.version, w.compactedAt = records, FormatVersion, cs.CompactedAt
+	last := cs
+	w.lastCompact = &last
+	return cs, nil
+}
+
+// Version возвращает версию формата журнала на диске
+// (0 — файл создан до введения версионирования).
+func (w *WAL) Version() (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.flushLocked(); err != nil {
+		return 0, err
+	}
+	st, err := readWAL(w.path, nil)
+	return st.version, err
+}
+
+// Migrate приводит журнал к текущему FormatVersion.
+// Если версия уже актуальна — ничего не делает и возвращает (false, stats с From=To).
+// Иначе выполняет Compact, который переписывает файл в текущем формате.
+func (w *WAL) Migrate() (bool, CompactStats, error) {
+	v, err := w.Version()
+	if err != nil {
+		return false, CompactStats{}, err
+	}
+	if v >= FormatVersion {
+		return false, CompactStats{FromVersion: v, ToVersion: v}, nil
+	}
+	cs, err := w.Compact()
+	return err == nil, cs, err
+}
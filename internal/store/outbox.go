@@ -0,0 +1,281 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// Имена файлов исходящей очереди событий в DATA_DIR.
+const (
+	outboxFile    = "outbox.jsonl"
+	outboxAckFile = "outbox.ack"
+)
+
+// OutboxEntry — событие задачи в исходящей очереди (и тело сообщения брокеру).
+type OutboxEntry struct {
+	Seq    uint64 `json:"seq"` // сквозной номер, растёт на 1
+	TaskID string `json:"task_id"`
+	// RequestID — X-Request-ID запроса, создавшего задачу (core.Task.RequestID).
+	RequestID string `json:"request_id,omitempty"`
+	core.TaskEvent
+}
+
+// OutboxStats — состояние исходящей очереди для GET /admin/store.
+type OutboxStats struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	Pending   uint64 `json:"pending"` // записано, но не подтверждено брокером
+	Acked     uint64 `json:"acked"`   // последний подтверждённый Seq
+	LastSeq   uint64 `json:"last_seq"`
+}
+
+// Outbox — исходящая очередь событий задач (outbox pattern): события пишутся
+// на диск рядом с журналом и хранятся, пока публикатор не подтвердит их
+// доставку брокеру (Ack). Так события не теряются при недоступном брокере
+// и перезапусках сервиса.
+//
+// Устройство (DATA_DIR):
+//   - outbox.jsonl — строки OutboxEntry в порядке Seq (только дозапись);
+//   - outbox.ack — "<seq> <offset>": последний подтверждённый Seq и смещение
+//     следующей неподтверждённой строки (пишется атомарно через rename).
+//
+// Когда подтверждено всё, файл очереди обрезается до нуля — он не растёт
+// бесконечно, пока брокер доступен. Доставка «хотя бы один раз»: события,
+// отправленные, но не подтверждённые до аварии, после перезапуска уйдут снова
+// (с теми же Seq — по ним потребитель отсекает дубликаты).
+type Outbox struct {
+	path    string
+	ackPath string
+
+	mu      sync.Mutex
+	f       *os.File
+	w       *bufio.Writer
+	size    int64  // размер файла (с учётом буфера)
+	readOff int64  // смещение первой неподтверждённой строки
+	acked   uint64 // последний подтверждённый Seq
+	lastSeq uint64 // последний выданный Seq
+
+	notify chan struct{} // ёмкость 1: «появились новые события»
+}
+
+// OpenOutbox открывает (создаёт) исходящую очередь в dir и находит
+// первую неподтверждённую запись. Битая последняя строка (обрыв записи
+// при аварии) отбрасывается.
+func OpenOutbox(dir string) (*Outbox, error) {
+	o := &Outbox{
+		path:    filepath.Join(dir, outboxFile),
+		ackPath: filepath.Join(dir, outboxAckFile),
+		notify:  make(chan struct{}, 1),
+	}
+	if err := o.readAck(); err != nil {
+		return nil, err
+	}
+	if err := o.scan(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(o.path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(o.size); err != nil { // хвост битой строки
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(o.size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	o.f, o.w = f, bufio.NewWriter(f)
+	return o, nil
+}
+
+// readAck читает outbox.ack; файла нет — ничего не подтверждено.
+func (o *Outbox) readAck() error {
+	data, err := os.ReadFile(o.ackPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f := strings.Fields(string(data))
+	if len(f) != 2 {
+		return fmt.Errorf("outbox: bad ack file %s", o.ackPath)
+	}
+	if o.acked, err = strconv.ParseUint(f[0], 10, 64); err != nil {
+		return fmt.Errorf("outbox: bad ack file %s: %w", o.ackPath, err)
+	}
+	if o.readOff, err = strconv.ParseInt(f[1], 10, 64); err != nil {
+		return fmt.Errorf("outbox: bad ack file %s: %w", o.ackPath, err)
+	}
+	o.lastSeq = o.acked
+	return nil
+}
+
+// scan проходит файл очереди: находит размер без битого хвоста, последний Seq
+// и проверяет смещение из outbox.ack (файл обрезали после подтверждения —
+// смещение за концом сбрасывается в начало).
+func (o *Outbox) scan() error {
+	f, err := os.Open(o.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		o.readOff = 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rd := bufio.NewReader(f)
+	var off int64
+	for {
+		line, err := rd.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var e OutboxEntry
+			if json.Unmarshal(line, &e) == nil && e.Seq > o.lastSeq {
+				o.lastSeq = e.Seq
+			}
+			off += int64(len(line))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	o.size = off
+	if o.readOff > o.size {
+		o.readOff = 0
+	}
+	return nil
+}
+
+// Append записывает события задачи taskID (созданной запросом requestID,
+// может быть пуст) в очередь (с очередными Seq)
+// и будит публикатора (Notify).
+func (o *Outbox) Append(taskID, requestID string, events ...core.TaskEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, ev := range events {
+		o.lastSeq++
+		b, err := json.Marshal(OutboxEntry{Seq: o.lastSeq, TaskID: taskID, RequestID: requestID, TaskEvent: ev})
+		if err != nil {
+			return err
+		}
+		n, err := o.w.Write(append(b, '\n'))
+		o.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	if err := o.w.Flush(); err != nil {
+		return err
+	}
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Notify — сигнал о новых событиях (ёмкость 1, сигналы схлопываются).
+func (o *Outbox) Notify() <-chan struct{} { return o.notify }
+
+// Pending возвращает до max первых неподтверждённых событий и смещение
+// за последним из них (для Ack). Пусто — всё подтверждено.
+func (o *Outbox) Pending(max int) ([]OutboxEntry, int64, error) {
+	o.mu.Lock()
+	off, size := o.readOff, o.size
+	o.mu.Unlock()
+	if off >= size {
+		return nil, off, nil
+	}
+	f, err := os.Open(o.path)
+	if err != nil {
+		return nil, off, err
+	}
+	defer f.Close()
+	rd := bufio.NewReader(io.NewSectionReader(f, off, size-off))
+	var out []OutboxEntry
+	for len(out) < max {
+		line, err := rd.ReadBytes('\n')
+		if len(line) == 0 || line[len(line)-1] != '\n' {
+			break
+		}
+		off += int64(len(line))
+		var e OutboxEntry
+		if json.Unmarshal(bytes.TrimSpace(line), &e) != nil {
+			continue // битую строку пропускаем: повторная попытка её не исправит
+		}
+		out = append(out, e)
+		if err != nil {
+			break
+		}
+	}
+	return out, off, nil
+}
+
+// Ack подтверждает события до seq включительно; next — смещение из Pending.
+// Подтверждено всё — файл очереди обрезается. Положение записывается
+// в outbox.ack атомарно.
+func (o *Outbox) Ack(seq uint64, next int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.acked, o.readOff = seq, next
+	if o.readOff >= o.size && o.acked == o.lastSeq {
+		if err := o.w.Flush(); err != nil {
+			return err
+		}
+		if err := o.f.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := o.f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		o.size, o.readOff = 0, 0
+	}
+	tmp := o.ackPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", o.acked, o.readOff)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.ackPath)
+}
+
+// Stats возвращает размер и число неподтверждённых событий.
+func (o *Outbox) Stats() OutboxStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return OutboxStats{
+		Path:      o.path,
+		SizeBytes: o.size,
+		Pending:   o.lastSeq - o.acked,
+		Acked:     o.acked,
+		LastSeq:   o.lastSeq,
+	}
+}
+
+// Close сбрасывает буфер и закрывает файл очереди.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	err := o.w.Flush()
+	if cerr := o.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Extrarius/29.09.2025/internal/core"
+)
+
+// TaskQuery — условия отбора задач (QueryTasks, а в приложении — фильтры
+// GET /tasks). Условия объединяются по «И»; пустое поле не ограничивает.
+type TaskQuery struct {
+	Status      core.TaskStatus // агрегированный статус (сравнивается без учёта регистра)
+	Tag         string          // задача содержит тег
+	CreatedFrom time.Time       // CreatedAt >= CreatedFrom
+	CreatedTo   time.Time       // CreatedAt < CreatedTo
+	Trash       string          // задачи в корзине: "" — не отбираются, TrashOnly, TrashAll
+	Tenant      string          // задачи арендатора (core.Task.Tenant): клиент с арендатором видит только свои
+}
+
+// Отбор задач в корзине (TaskQuery.Trash). По умолчанию их нет в выборке.
+const (
+	TrashOnly = "only" // только задачи в корзине
+	TrashAll  = "all"  // задачи в корзине вместе с остальными
+)
+
+// ValidTrash сообщает, что s — допустимое значение TaskQuery.Trash.
+func ValidTrash(s string) bool { return s == "" || s == TrashOnly || s == TrashAll }
+
+// IsZero сообщает, что запрос не содержит ни одного условия.
+func (q TaskQuery) IsZero() bool {
+	return q.Status == "" && q.Tag == "" && q.CreatedFrom.IsZero() && q.CreatedTo.IsZero() && q.Trash == "" && q.Tenant == ""
+}
+
+// Match проверяет задачу по условиям запроса.
+func (q TaskQuery) Match(t *core.Task) bool {
+	if trashed := t.TrashedAt != nil; q.Trash != TrashAll && trashed != (q.Trash == TrashOnly) {
+		return false
+	}
+	if q.Tenant != "" && t.Tenant != q.Tenant {
+		return false
+	}
+	if q.Status != "" && !strings.EqualFold(string(t.Status), string(q.Status)) {
+		return false
+	}
+	if !q.CreatedFrom.IsZero() && t.CreatedAt.Before(q.CreatedFrom) {
+		return false
+	}
+	if !q.CreatedTo.IsZero() && !t.CreatedAt.Before(q.CreatedTo) {
+		return false
+	}
+	if q.Tag != "" {
+		for _, tag := range t.Tags {
+			if tag == q.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// SortTasks упорядочивает задачи по времени создания, при равенстве — по ID:
+// порядок выдачи QueryTasks и GET /tasks, стабильный между запросами.
+func SortTasks(tasks []*core.Task) {
+	sort.Slice(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// QueryTasks отбирает задачи прямо из файла журнала, не требуя, чтобы они были
+// загружены в память приложения (например, из CLI при остановленном сервисе):
+//   - сначала дописывает фоновую очередь, чтобы в файл попали все принятые записи;
+//   - затем читает журнал так же, как RecoverTasks (last-write-wins), но не меняет
+//     счётчики WAL — это только чтение;
+//   - возвращает задачи, прошедшие q, в порядке SortTasks.
+//
+// Чтение — полный проход по файлу: для частых запросов к работающему сервису
+// используйте отбор по задачам в памяти (app.App.QueryTasks).
+func (w *WAL) QueryTasks(q TaskQuery) ([]*core.Task, error) {
+	w.mu.Lock()
+	err := w.flushLocked()
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	st, err := readWAL(w.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*core.Task, 0, len(st.tasks))
+	for _, t := range st.tasks {
+		if q.Match(t) {
+			out = append(out, t)
+		}
+	}
+	SortTasks(out)
+	return out, nil
+}
+
+// ByStatus возвращает задачи журнала с агрегированным статусом status.
+func (w *WAL) ByStatus(status core.TaskStatus) ([]*core.Task, error) {
+	return w.QueryTasks(TaskQuery{Status: status})
+}
+
+// ByCreatedRange возвращает задачи журнала, созданные в [from, to);
+// нулевая граница не ограничивает.
+func (w *WAL) ByCreatedRange(from, to time.Time) ([]*core.Task, error) {
+	return w.QueryTasks(TaskQuery{CreatedFrom: from, CreatedTo: to})
+}
+
+// ByTag возвращает задачи журнала с тегом tag.
+func (w *WAL) ByTag(tag string) ([]*core.Task, error) {
+	return w.QueryTasks(TaskQuery{Tag: tag})
+}
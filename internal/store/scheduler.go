@@ -0,0 +1,35 @@
+package store
+
+// SchedulerState — режим выдачи заданий, переживающий перезапуск: drain
+// и приостановленные хосты. Пишется записью "scheduler" при каждом изменении;
+// при восстановлении действует последняя запись (last-write-wins).
+type SchedulerState struct {
+	Drain       bool     `json:"drain"`
+	PausedHosts []string `json:"paused_hosts,omitempty"`
+}
+
+// IsZero сообщает, что состояние совпадает с умолчанием (работа без пауз).
+func (s SchedulerState) IsZero() bool { return !s.Drain && len(s.PausedHosts) == 0 }
+
+// AppendScheduler дописывает запись "scheduler" с текущим режимом выдачи
+// и сбрасывает буфер: режим должен оказаться на диске до ответа оператору.
+func (w *WAL) AppendScheduler(s SchedulerState) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.drainLocked(); err != nil {
+		return err
+	}
+	if err := w.writeRecord(walRecord{Type: "scheduler", Scheduler: &s}); err != nil {
+		return err
+	}
+	w.scheduler = s
+	return w.w.Flush()
+}
+
+// Scheduler возвращает последний записанный режим выдачи: после RecoverTasks —
+// прочитанный из журнала, далее — последний AppendScheduler.
+func (w *WAL) Scheduler() SchedulerState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.scheduler
+}
@@ -0,0 +1,60 @@
+package store
+
+import "time"
+
+// Stats — состояние журнала для GET /admin/store.
+type Stats struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	Records   int    `json:"records"` // строк-записей в файле (включая битые и "meta")
+	Version   int    `json:"version"` // версия формата на диске
+
+	// CompactedAt — время последней компактизации (из записи "meta"); журнал
+	// после неё — «снимок» плюс дописанные дельты. Пусто — не компактизировался.
+	CompactedAt *time.Time `json:"compacted_at,omitempty"`
+	SnapshotAge string     `json:"snapshot_age,omitempty"`
+
+	// LastCompaction — итог последней компактизации в этом процессе.
+	LastCompaction *CompactStats `json:"last_compaction,omitempty"`
+
+	// Async — фоновая запись (WAL_ASYNC_QUEUE); нет — запись синхронная.
+	Async *AsyncStats `json:"async,omitempty"`
+
+	// Blobs — хранилище файлов по содержимому (BLOB_DIR); нет — выключено.
+	Blobs *BlobStats `json:"blobs,omitempty"`
+	// Outbox — исходящая очередь событий (EVENTS_URL); нет — публикация выключена.
+	Outbox *OutboxStats `json:"outbox,omitempty"`
+}
+
+// Stats возвращает размер файла и счётчики журнала. Records, Version и
+// CompactedAt известны после RecoverTasks (до него — только с момента открытия).
+func (w *WAL) Stats() (Stats, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.flushLocked(); err != nil {
+		return Stats{}, err
+	}
+	fi, err := w.f.Stat()
+	if err != nil {
+		return Stats{}, err
+	}
+	st := Stats{
+		Path:      w.path,
+		SizeBytes: fi.Size(),
+		Records:   w.records,
+		Version:   w.version,
+	}
+	if !w.compactedAt.IsZero() {
+		at := w.compactedAt
+		st.CompactedAt = &at
+		st.SnapshotAge = time.Since(at).Round(time.Second).String()
+	}
+	if w.lastCompact != nil {
+		last := *w.lastCompact
+		st.LastCompaction = &last
+	}
+	if as, ok := w.AsyncStats(); ok {
+		st.Async = &as
+	}
+	return st, nil
+}
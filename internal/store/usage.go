@@ -0,0 +1,151 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// usageFile — учёт потребления в DATA_DIR.
+const usageFile = "usage.json"
+
+// UsageKey — строка учёта: день (UTC, "2006-01-02"), арендатор и отпечаток
+// ключа API (auth.KeyID), от чьего имени создана задача.
+type UsageKey struct {
+	Day    string `json:"day"`
+	Tenant string `json:"tenant,omitempty"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// UsageCounters — счётчики потребления.
+type UsageCounters struct {
+	Tasks    int64 `json:"tasks"`    // создано задач
+	Requests int64 `json:"requests"` // попыток скачивания файлов
+	Files    int64 `json:"files"`    // скачанных файлов
+	Failures int64 `json:"failures"` // неудачных попыток
+	Bytes    int64 `json:"bytes"`    // скачано байт (успешные попытки)
+}
+
+// Add прибавляет к c счётчики d.
+func (c *UsageCounters) Add(d UsageCounters) {
+	c.Tasks += d.Tasks
+	c.Requests += d.Requests
+	c.Files += d.Files
+	c.Failures += d.Failures
+	c.Bytes += d.Bytes
+}
+
+// UsageRecord — строка учёта со счётчиками.
+type UsageRecord struct {
+	UsageKey
+	UsageCounters
+}
+
+// UsageLedger — накопительный учёт потребления по дням, арендаторам и ключам
+// API для отчётов (GET /admin/usage). Счётчики живут в памяти и сбрасываются
+// в DATA_DIR/usage.json целиком (атомарно, через rename) по Flush — аварийная
+// остановка теряет только накопленное после последнего сброса.
+type UsageLedger struct {
+	path string
+
+	mu    sync.Mutex
+	rows  map[UsageKey]*UsageCounters
+	dirty bool // есть несброшенные изменения
+}
+
+// OpenUsageLedger готовит учёт в каталоге dir; прочитать сохранённое — Load.
+func OpenUsageLedger(dir string) *UsageLedger {
+	return &UsageLedger{path: filepath.Join(dir, usageFile), rows: make(map[UsageKey]*UsageCounters)}
+}
+
+// Load читает сохранённый учёт и прибавляет его к накопленному в памяти
+// (то, что учтено до Load, не теряется). Нет файла — ничего не меняет.
+func (l *UsageLedger) Load() error {
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var recs []UsageRecord
+	if err := json.Unmarshal(data, &recs); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	for _, r := range recs {
+		l.addLocked(r.UsageKey, r.UsageCounters)
+	}
+	l.mu.Unlock()
+	return nil
+}
+
+// Add прибавляет d к строке k.
+func (l *UsageLedger) Add(k UsageKey, d UsageCounters) {
+	l.mu.Lock()
+	l.addLocked(k, d)
+	l.dirty = true
+	l.mu.Unlock()
+}
+
+func (l *UsageLedger) addLocked(k UsageKey, d UsageCounters) {
+	c, ok := l.rows[k]
+	if !ok {
+		c = &UsageCounters{}
+		l.rows[k] = c
+	}
+	c.Add(d)
+}
+
+// Records возвращает строки за дни from..to включительно ("2006-01-02";
+// пустая граница — без ограничения), по дням, арендаторам и ключам.
+func (l *UsageLedger) Records(from, to string) []UsageRecord {
+	l.mu.Lock()
+	out := make([]UsageRecord, 0, len(l.rows))
+	for k, c := range l.rows {
+		if (from != "" && k.Day < from) || (to != "" && k.Day > to) {
+			continue
+		}
+		out = append(out, UsageRecord{UsageKey: k, UsageCounters: *c})
+	}
+	l.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].UsageKey, out[j].UsageKey
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Tenant != b.Tenant {
+			return a.Tenant < b.Tenant
+		}
+		return a.APIKey < b.APIKey
+	})
+	return out
+}
+
+// Flush записывает учёт на диск, если с прошлого сброса он менялся.
+func (l *UsageLedger) Flush() error {
+	l.mu.Lock()
+	if !l.dirty {
+		l.mu.Unlock()
+		return nil
+	}
+	l.dirty = false
+	l.mu.Unlock()
+	data, err := json.Marshal(l.Records("", ""))
+	if err == nil {
+		tmp := l.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o644); err == nil {
+			err = os.Rename(tmp, l.path)
+		}
+	}
+	if err != nil {
+		l.mu.Lock()
+		l.dirty = true // повторим при следующем сбросе
+		l.mu.Unlock()
+	}
+	return err
+}
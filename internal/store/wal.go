@@ -3,17 +3,38 @@ package store
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/Extrarius/29.09.2025/internal/core"
 )
 
+// FormatVersion — текущая версия формата WAL. Записывается в служебную
+// запись "meta" в начале файла при создании и при компактизации.
+// Файлы без такой записи считаются версией 0 (до введения версионирования).
+//
+// История:
+//   - 1 — записи "meta" и "upsert_task";
+//   - 2 — у файлов есть ID, добавлены дельта-записи "upsert_file";
+//   - 3 — записи событий задачи "task_event" (GET /tasks/{id}/history);
+//   - 4 — запись режима выдачи "scheduler" (drain, приостановленные хосты);
+//   - 5 — удаление задачи "delete_task", у готовых файлов — ссылка на blob (BLOB_DIR).
+const FormatVersion = 5
+
 type walRecord struct {
-	Type string     `json:"type"` // "upsert_task"
-	Task *core.Task `json:"task,omitempty"`
+	Type      string          `json:"type"` // "upsert_task" | "upsert_file" | "task_event" | "delete_task" | "scheduler" | "meta"
+	Task      *core.Task      `json:"task,omitempty"`
+	TaskID    string          `json:"task_id,omitempty"`   // для "upsert_file", "task_event" и "delete_task"
+	File      *core.FileItem  `json:"file,omitempty"`      // только для "upsert_file"
+	Event     *core.TaskEvent `json:"event,omitempty"`     // только для "task_event"
+	Scheduler *SchedulerState `json:"scheduler,omitempty"` // только для "scheduler"
+	Version   int             `json:"version,omitempty"`   // только для "meta"
+	At        *time.Time      `json:"at,omitempty"`        // для "meta": время компактизации
 }
 
 type WAL struct {
@@ -21,6 +42,34 @@ type WAL struct {
 	f    *os.File
 	path string
 	w    *bufio.Writer
+
+	// учёт для Stats (под mu): заполняется RecoverTasks, обновляется при записи
+	records     int
+	version     int
+	compactedAt time.Time
+	lastCompact *CompactStats
+
+	scheduler SchedulerState // последний режим выдачи (под mu), см. Scheduler
+
+	async *asyncWriter // фоновая запись (StartAsync); nil — запись синхронная
+
+	fenced bool // Fence: запись запрещена (под mu)
+}
+
+// ErrFenced — запись в журнал запрещена (Fence): экземпляр потерял аренду
+// лидера, журнал может уже вести другой экземпляр.
+var ErrFenced = errors.New("wal fenced: leader lease lost")
+
+// Fence запрещает дальнейшую запись в журнал: ещё не сброшенный буфер
+// отбрасывается, а Append*, Sync, Check и Compact возвращают ErrFenced
+// (фоновая запись запоминает её как свою ошибку). Вызывается при потере
+// аренды лидера (LEADER_LEASE): новый лидер мог уже открыть тот же файл, и
+// любая строка отсюда смешалась бы с его записями. Необратимо.
+func (w *WAL) Fence() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fenced = true
+	w.w.Reset(io.Discard)
 }
 
 // OpenWAL открывает (или создаёт) файл журнала tasks.wal в dataDir.
@@ -28,7 +77,8 @@ type WAL struct {
 // Делает:
 //   - гарантирует наличие каталога dataDir (0755);
 //   - открывает файл в режимах O_CREATE|O_RDWR|O_APPEND (без truncate), права 0644;
-//   - оборачивает файл буфером записи 64 KiB.
+//   - оборачивает файл буфером записи 64 KiB;
+//   - в новый (пустой) файл первой строкой пишет запись "meta" с FormatVersion.
 //
 // Возвращает *WAL, готовый к записи. Данные буферизуются — они гарантированно
 // записываются на диск при Flush/Close (вызовите Close() по завершении работы).
@@ -41,24 +91,78 @@ func OpenWAL(dataDir string) (*WAL, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &WAL{
+	w := &WAL{
 		f:    f,
 		path: path,
 		w:    bufio.NewWriterSize(f, 64*1024),
-	}, nil
+	}
+	if fi, err := f.Stat(); err == nil && fi.Size() == 0 {
+		w.version = FormatVersion
+		if err := w.writeRecord(walRecord{Type: "meta", Version: FormatVersion}); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := w.w.Flush(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Path возвращает путь к файлу журнала.
+func (w *WAL) Path() string { return w.path }
+
+// writeRecord сериализует rec и дописывает строку JSONL в буфер. Вызывать под w.mu
+// (или до того, как WAL стал доступен другим горутинам).
+func (w *WAL) writeRecord(rec walRecord) error {
+	b, err := encodeRecords(rec)
+	if err != nil {
+		return err
+	}
+	return w.writeLine(b)
+}
+
+// encodeRecords сериализует записи в строки JSONL — без блокировки журнала,
+// чтобы маршалинг не занимал w.mu.
+func encodeRecords(recs ...walRecord) (walBatch, error) {
+	var b walBatch
+	for _, rec := range recs {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return walBatch{}, fmt.Errorf("marshal wal record: %w", err)
+		}
+		b.data = append(append(b.data, data...), '\n')
+		b.records++
+	}
+	return b, nil
+}
+
+// writeLine дописывает закодированные строки в буфер. Вызывать под w.mu.
+func (w *WAL) writeLine(b walBatch) error {
+	if w.fenced {
+		return ErrFenced
+	}
+	if _, err := w.w.Write(b.data); err != nil {
+		return err
+	}
+	w.records += b.records
+	return nil
 }
 
 // Close завершает работу с WAL:
 //
-//	– под мьютексом пытается сбросить буфер (Flush);
+//	– останавливает фоновую запись (StartAsync), если она включена;
+//	– под мьютексом дописывает её очередь и пытается сбросить буфер (Flush);
 //	– затем закрывает файловый дескриптор.
 //
 // Возвращает ошибку только от Close(); ошибка Flush в текущей реализации игнорируется.
 func (w *WAL) Close() error {
+	w.stopAsync()
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	if w.w != nil {
-		w.w.Flush()
+		w.flushLocked()
 	}
 	if w.f != nil {
 		return w.f.Close()
@@ -66,59 +170,267 @@ func (w *WAL) Close() error {
 	return nil
 }
 
+// Check проверяет, что журнал пригоден для записи: сбрасывает буфер
+// (ошибка записи у bufio.Writer «липкая» — после неё все последующие записи
+// тоже падают) и убеждается, что файловый дескриптор жив (Stat).
+// Ошибка фоновой записи (StartAsync) тоже возвращается.
+// Возвращает первую найденную проблему или nil.
+func (w *WAL) Check() error {
+	if w.async != nil {
+		if f := w.async.failed.Load(); f != nil {
+			return f.err
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.flushLocked(); err != nil {
+		return err
+	}
+	_, err := w.f.Stat()
+	return err
+}
+
+// Sync делает принятые записи надёжными: дописывает фоновую очередь, сбрасывает
+// буфер и выполняет fsync файла журнала. Нужен там, где внешней стороне
+// подтверждается сохранение (приём задач из брокера сообщений), — обычные
+// Append* fsync не делают.
+func (w *WAL) Sync() error {
+	if w.async != nil {
+		if f := w.async.failed.Load(); f != nil {
+			return f.err
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.flushLocked(); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+// MaxTaskRecord — предел размера записи "upsert_task" (задача целиком в
+// одной строке JSONL): задачу больше него AppendTask не пишет.
+const MaxTaskRecord = 64 << 20
+
+// ErrRecordTooLarge — запись задачи больше MaxTaskRecord.
+var ErrRecordTooLarge = errors.New("task record too large")
+
+// CheckTaskRecord проверяет, что задача task уместится в одну запись
+// журнала (MaxTaskRecord); иначе — ErrRecordTooLarge. Вызывается до
+// регистрации задачи, пока она не видна другим горутинам.
+func CheckTaskRecord(task *core.Task) error {
+	_, err := encodeTask(task)
+	return err
+}
+
+// encodeTask сериализует запись "upsert_task" с проверкой MaxTaskRecord.
+func encodeTask(task *core.Task) (walBatch, error) {
+	b, err := encodeRecords(walRecord{Type: "upsert_task", Task: task})
+	if err != nil {
+		return walBatch{}, err
+	}
+	if len(b.data) > MaxTaskRecord {
+		return walBatch{}, fmt.Errorf("%w: %d bytes, max %d", ErrRecordTooLarge, len(b.data), MaxTaskRecord)
+	}
+	return b, nil
+}
+
 // AppendTask добавляет в WAL одну запись типа "upsert_task" в формате JSONL.
 // Потокобезопасно пишет в конец файла и выполняет Flush буфера,
-// чтобы данные оказались в файле. Возвращает ошибку маршалинга/записи/Flush.
+// чтобы данные оказались в файле (при фоновой записи — ставит запись в очередь,
+// см. StartAsync). Возвращает ошибку маршалинга/записи/Flush; задача больше
+// MaxTaskRecord не пишется — ErrRecordTooLarge.
 func (w *WAL) AppendTask(task *core.Task) error {
-	rec := walRecord{Type: "upsert_task", Task: task}
-	data, err := json.Marshal(rec)
+	b, err := encodeTask(task)
 	if err != nil {
-		return fmt.Errorf("marshal wal record: %w", err)
+		return err
 	}
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	if _, err := w.w.Write(append(data, '\n')); err != nil {
+	return w.append(b)
+}
+
+// AppendFile добавляет дельта-запись "upsert_file": новое состояние одного файла
+// задачи taskID (файл идентифицируется по f.ID). Агрегаты задачи не пишутся —
+// они пересчитываются при восстановлении. Для больших задач это на порядки
+// дешевле, чем AppendTask на каждую смену состояния файла.
+// Передавайте копию FileItem, снятую под блокировкой задачи.
+func (w *WAL) AppendFile(taskID string, f *core.FileItem) error {
+	b, err := encodeRecords(walRecord{Type: "upsert_file", TaskID: taskID, File: f})
+	if err != nil {
+		return err
+	}
+	return w.append(b)
+}
+
+// AppendEvents дописывает записи "task_event" — события истории задачи taskID —
+// одним сбросом буфера. Пустой список ничего не пишет.
+func (w *WAL) AppendEvents(taskID string, events ...core.TaskEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	recs := make([]walRecord, len(events))
+	for i := range events {
+		recs[i] = walRecord{Type: "task_event", TaskID: taskID, Event: &events[i]}
+	}
+	b, err := encodeRecords(recs...)
+	if err != nil {
 		return err
 	}
-	return w.w.Flush()
+	return w.append(b)
+}
+
+// AppendDelete дописывает запись "delete_task": при восстановлении задача
+// taskID (вместе с историей) исчезает, а компактизация её больше не переносит.
+func (w *WAL) AppendDelete(taskID string) error {
+	b, err := encodeRecords(walRecord{Type: "delete_task", TaskID: taskID})
+	if err != nil {
+		return err
+	}
+	return w.append(b)
 }
 
 // RecoverTasks перечитывает файл WAL (w.path) и восстанавливает последнее
 // известное состояние задач.
 //
-// Формат WAL — JSONL: по одной JSON-записи на строку. Учитываются только
-// записи с Type="upsert_task"; применяется политика last-write-wins — для
-// каждого Task.ID в результате остаётся самое позднее встретившееся состояние.
-//
-// Реализация:
-//   - открывает файл и сканирует построчно через bufio.Scanner;
-//   - увеличивает лимит токена до 10 МБ (sc.Buffer(..., 10*1024*1024));
-//   - некорректные/битые строки пропускает (continue), не прерывая восстановление;
-//   - на выходе возвращает map[Task.ID]*Task или ошибку сканера.
+// Формат WAL — JSONL: по одной JSON-записи на строку. Применяется политика
+// last-write-wins: "upsert_task" заменяет задачу целиком (история событий
+// сохраняется), "upsert_file" — один файл уже известной задачи (по ID файла),
+// "task_event" дописывает событие в историю задачи, "delete_task" убирает
+// задачу (повторный "upsert_task" с тем же ID создаёт её заново), "scheduler" заменяет
+// режим выдачи (его затем возвращает Scheduler). Файлам без ID (журналы v1)
+// выдаются детерминированные ID (core.Task.EnsureFileIDs), агрегаты задач
+// пересчитываются по итогам чтения. Служебные записи ("meta"), дельты для
+// неизвестных задач/файлов и некорректные/битые строки пропускаются,
+// не прерывая восстановление.
 //
 // Предназначено для вызова на старте приложения, до запуска воркеров.
 func (w *WAL) RecoverTasks() (map[string]*core.Task, error) {
-	f, err := os.Open(w.path)
+	return w.RecoverTasksProgress(nil)
+}
+
+// ReadProgress — ход чтения журнала при восстановлении (RecoverTasksProgress).
+type ReadProgress struct {
+	BytesRead  int64 // прочитано байт файла
+	BytesTotal int64 // размер файла на момент начала чтения
+	Records    int   // прочитано записей
+	Done       bool  // последний вызов: файл прочитан целиком
+}
+
+// recoverProgressEvery — как часто (в записях) вызывается progress при чтении журнала.
+const recoverProgressEvery = 10_000
+
+// RecoverTasksProgress — RecoverTasks с отчётом о ходе чтения: progress
+// вызывается каждые recoverProgressEvery записей и в конце (Done=true).
+// Ошибка, возвращённая progress, прерывает чтение и возвращается как есть —
+// так вызывающий останавливает восстановление при завершении сервиса.
+func (w *WAL) RecoverTasksProgress(progress func(ReadProgress) error) (map[string]*core.Task, error) {
+	st, err := readWAL(w.path, progress)
 	if err != nil {
 		return nil, err
 	}
+	w.mu.Lock()
+	w.records, w.version, w.compactedAt = st.records, st.version, st.compactedAt
+	w.scheduler = st.scheduler
+	w.mu.Unlock()
+	return st.tasks, nil
+}
+
+// walState — результат полного чтения файла журнала.
+type walState struct {
+	tasks   map[string]*core.Task
+	records int // всего строк-записей (включая битые)
+	version int // версия формата из записи "meta" (0 — записи нет)
+
+	compactedAt time.Time      // из записи "meta", если журнал компактизировался
+	scheduler   SchedulerState // из последней записи "scheduler"
+}
+
+// readWAL читает файл журнала построчно (bufio.Reader.ReadBytes, без
+// предела длины строки — задача после дельт "upsert_file" может оказаться
+// больше MaxTaskRecord при компактизации) и применяет записи по политике
+// last-write-wins.
+// progress (может быть nil) получает ход чтения, см. RecoverTasksProgress.
+func readWAL(path string, progress func(ReadProgress) error) (walState, error) {
+	st := walState{tasks: make(map[string]*core.Task, 128)}
+	f, err := os.Open(path)
+	if err != nil {
+		return st, err
+	}
 	defer f.Close()
 
-	tasks := make(map[string]*core.Task, 128)
-	sc := bufio.NewScanner(f)
-	sc.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
-	for sc.Scan() {
-		line := sc.Bytes()
+	var total int64
+	if fi, err := f.Stat(); err == nil {
+		total = fi.Size()
+	}
+	var read int64 // байт до конца последней прочитанной строки
+	dirty := make(map[*core.Task]bool)
+	rd := bufio.NewReaderSize(f, 64*1024)
+	for {
+		line, err := rd.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) == 0 {
+				break
+			}
+		} else if err != nil {
+			return st, err
+		}
+		st.records++
+		read += int64(len(line))
+		if progress != nil && st.records%recoverProgressEvery == 0 {
+			if err := progress(ReadProgress{BytesRead: read, BytesTotal: total, Records: st.records}); err != nil {
+				return st, err
+			}
+		}
 		var rec walRecord
 		if err := json.Unmarshal(line, &rec); err != nil {
 			continue
 		}
-		if rec.Type == "upsert_task" && rec.Task != nil {
-			tasks[rec.Task.ID] = rec.Task
+		switch rec.Type {
+		case "meta":
+			st.version = rec.Version
+			if rec.At != nil {
+				st.compactedAt = *rec.At
+			}
+		case "upsert_task":
+			if rec.Task != nil {
+				if prev := st.tasks[rec.Task.ID]; prev != nil {
+					rec.Task.AdoptHistory(prev)
+				}
+				rec.Task.EnsureFileIDs()
+				st.tasks[rec.Task.ID] = rec.Task
+				dirty[rec.Task] = true
+			}
+		case "upsert_file":
+			t := st.tasks[rec.TaskID]
+			if t == nil || rec.File == nil {
+				continue
+			}
+			if _, i := t.FileByID(rec.File.ID); i >= 0 {
+				t.Files[i] = rec.File
+				dirty[t] = true
+			}
+		case "task_event":
+			if t := st.tasks[rec.TaskID]; t != nil && rec.Event != nil {
+				t.AddEvent(*rec.Event)
+			}
+		case "delete_task":
+			if t := st.tasks[rec.TaskID]; t != nil {
+				delete(dirty, t)
+				delete(st.tasks, rec.TaskID)
+			}
+		case "scheduler":
+			if rec.Scheduler != nil {
+				st.scheduler = *rec.Scheduler
+			}
 		}
 	}
-	if err := sc.Err(); err != nil {
-		return nil, err
+	// пересчёт агрегатов один раз на задачу, а не на каждую дельту
+	for t := range dirty {
+		t.RecomputeStatus()
+	}
+	if progress != nil {
+		if err := progress(ReadProgress{BytesRead: read, BytesTotal: max(total, read), Records: st.records, Done: true}); err != nil {
+			return st, err
+		}
 	}
-	return tasks, nil
+	return st, nil
 }
@@ -0,0 +1,515 @@
+// Package client — Go-клиент HTTP API сервиса загрузок.
+//
+// Пример:
+//
+//	c := client.New("http://localhost:8080", client.WithAPIKey(os.Getenv("DOWNLOADER_API_KEY")))
+//	id, err := c.CreateTask(ctx, client.CreateTaskRequest{Links: client.URLs(links...), Label: "photos"})
+//	...
+//	err = c.WatchTask(ctx, id, func(t *client.Task) error {
+//		log.Printf("%s: %d/%d", t.Status, t.Done, t.Total)
+//		return nil
+//	})
+//
+// Все методы принимают context.Context (отмена и дедлайны) и повторяют
+// запрос при временных сбоях (см. WithRetries).
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client — клиент API. Безопасен для конкурентного использования.
+type Client struct {
+	base       string
+	apiKey     string
+	lang       string
+	http       *http.Client
+	maxRetries int
+	retryWait  time.Duration
+}
+
+// Option настраивает Client в New.
+type Option func(*Client)
+
+// WithAPIKey задаёт ключ API (передаётся как "Authorization: Bearer <key>").
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithLanguage задаёт язык сообщений об ошибках сервиса ("ru", "en"):
+// передаётся как Accept-Language. Без него — язык сервиса (ERROR_LANG).
+func WithLanguage(lang string) Option {
+	return func(c *Client) { c.lang = lang }
+}
+
+// WithHTTPClient задаёт собственный *http.Client (TLS, прокси, таймауты).
+// Для WatchTask у клиента не должно быть общего Timeout — поток длится долго;
+// ограничивайте вызовы через context.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithRetries задаёт число повторов при временных сбоях и начальную паузу
+// между ними (удваивается с каждой попыткой). n=0 отключает повторы.
+func WithRetries(n int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = n
+		c.retryWait = wait
+	}
+}
+
+// New создаёт клиент для сервиса по адресу baseURL (например, "http://localhost:8080").
+// По умолчанию: http.DefaultClient, 3 повтора с начальной паузой 500ms.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		base:       strings.TrimRight(baseURL, "/"),
+		http:       http.DefaultClient,
+		maxRetries: 3,
+		retryWait:  500 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// requestIDKey — ключ контекста для WithRequestID.
+type requestIDKey struct{}
+
+// WithRequestID возвращает контекст, запросы с которым передают сервису
+// заголовок X-Request-ID: id (для сквозной корреляции логов). Сервис пишет
+// его в создаваемые задачи (Task.RequestID), логи и события. Без него сервис
+// сгенерирует ID сам.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// APIError — ответ сервиса с кодом не 2xx.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Code       string // код ошибки из JSON-ответа ("invalid_url", "limit_tasks_per_hour", …); пусто — без кода
+	RequestID  string // X-Request-ID ответа — для поиска запроса в логах сервиса
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("http %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound сообщает, что err — ответ 404 (задача не найдена).
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// CreateTask создаёт задачу и возвращает её ID.
+func (c *Client) CreateTask(ctx context.Context, req CreateTaskRequest) (string, error) {
+	var resp struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/tasks", req, &resp); err != nil {
+		return "", err
+	}
+	return resp.TaskID, nil
+}
+
+// SubmitTask создаёт задачу и возвращает её целиком (с назначенными именами
+// и ID файлов). wait > 0 — сервер отвечает, когда задача дойдёт до конечного
+// статуса, но не дольше wait (сервер ограничивает 5m); по истечении
+// возвращается текущее состояние — проверьте t.Status. Как и для WatchTask,
+// у http.Client не должно быть Timeout короче wait.
+func (c *Client) SubmitTask(ctx context.Context, req CreateTaskRequest, wait time.Duration) (*Task, error) {
+	path := "/tasks"
+	if wait > 0 {
+		path += "?wait=" + url.QueryEscape(wait.String())
+	}
+	var t Task
+	if err := c.do(ctx, http.MethodPost, path, req, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTask возвращает задачу по ID; для отсутствующей — *APIError с кодом 404 (IsNotFound).
+func (c *Client) GetTask(ctx context.Context, id string) (*Task, error) {
+	var t Task
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+url.PathEscape(id), nil, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// PollTask — long-polling: ждёт (не дольше wait, сервер ограничивает 60s), пока
+// версия задачи не станет отличной от sinceVersion, и возвращает задачу.
+// По истечении wait возвращается текущее состояние — сравните t.Version с sinceVersion.
+func (c *Client) PollTask(ctx context.Context, id string, sinceVersion uint64, wait time.Duration) (*Task, error) {
+	q := url.Values{}
+	q.Set("since_version", strconv.FormatUint(sinceVersion, 10))
+	q.Set("wait", wait.String())
+	var t Task
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+url.PathEscape(id)+"?"+q.Encode(), nil, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetFile возвращает файл задачи по его ID (File.ID стабилен, его можно сохранять).
+func (c *Client) GetFile(ctx context.Context, taskID, fileID string) (*File, error) {
+	var f File
+	path := "/tasks/" + url.PathEscape(taskID) + "/files/" + url.PathEscape(fileID)
+	if err := c.do(ctx, http.MethodGet, path, nil, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// SkipFile пропускает ждущий или упавший файл задачи (SKIPPED), чтобы задача
+// завершилась без него; reason — причина для истории файла (пусто — умолчание
+// сервиса). Файл качается или уже завершён — *APIError с кодом 409.
+func (c *Client) SkipFile(ctx context.Context, taskID, fileID, reason string) (*File, error) {
+	path := "/tasks/" + url.PathEscape(taskID) + "/files/" + url.PathEscape(fileID) + "/skip"
+	if reason != "" {
+		path += "?reason=" + url.QueryEscape(reason)
+	}
+	var f File
+	if err := c.do(ctx, http.MethodPost, path, nil, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// SignFileURL выдаёт подписанную ссылку на содержимое скачанного файла
+// на срок ttl (0 — умолчание сервиса; сервис ограничивает его SIGNED_URL_MAX_TTL).
+// Если на сервисе не задан SIGNED_URL_KEY — *APIError с кодом 501. Если не задан
+// SIGNED_URL_BASE, URL строится от адреса, с которым создан клиент.
+func (c *Client) SignFileURL(ctx context.Context, taskID, fileID string, ttl time.Duration) (*SignedURL, error) {
+	var s SignedURL
+	path := "/tasks/" + url.PathEscape(taskID) + "/files/" + url.PathEscape(fileID) + "/link"
+	if ttl > 0 {
+		path += "?ttl=" + url.QueryEscape(ttl.String())
+	}
+	if err := c.do(ctx, http.MethodPost, path, nil, &s); err != nil {
+		return nil, err
+	}
+	if s.URL == "" {
+		s.URL = c.base + s.Path
+	}
+	return &s, nil
+}
+
+// GetHistory возвращает историю событий задачи.
+func (c *Client) GetHistory(ctx context.Context, id string) (*TaskHistory, error) {
+	var h TaskHistory
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+url.PathEscape(id)+"/history", nil, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// ListTasks возвращает страницу задач.
+func (c *Client) ListTasks(ctx context.Context, opts ListOptions) ([]Task, error) {
+	q := url.Values{}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		q.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	path := "/tasks"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var tasks []Task
+	if err := c.do(ctx, http.MethodGet, path, nil, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// EachTask читает список задач потоком (GET /tasks, Accept: application/x-ndjson)
+// и вызывает fn с каждой задачей по мере получения — без загрузки всего
+// списка в память. opts.Limit=0 — все задачи. Ошибка fn прекращает чтение и
+// возвращается как есть. Повторов нет: часть задач уже могла попасть в fn.
+func (c *Client) EachTask(ctx context.Context, opts ListOptions, fn func(*Task) error) error {
+	q := url.Values{}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		q.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	path := "/tasks"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var t Task
+		if err := dec.Decode(&t); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(&t); err != nil {
+			return err
+		}
+	}
+}
+
+// ValidateTask — пробный прогон задачи (POST /tasks/validate): ошибки валидации,
+// будущие файлы и вердикты по ссылкам; задача не создаётся. probe=true —
+// сервис ещё и проверяет доступность каждой ссылки (до 1000 ссылок).
+func (c *Client) ValidateTask(ctx context.Context, req CreateTaskRequest, probe bool) (*DryRun, error) {
+	path := "/tasks/validate"
+	if probe {
+		path += "?probe=true"
+	}
+	var res DryRun
+	if err := c.do(ctx, http.MethodPost, path, req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CloneTask создаёт новую задачу из ссылок задачи id и возвращает её ID.
+// onlyFailed=true — только упавшие файлы (если их нет — *APIError с кодом 409).
+func (c *Client) CloneTask(ctx context.Context, id string, onlyFailed bool) (string, error) {
+	var resp struct {
+		TaskID string `json:"task_id"`
+	}
+	path := "/tasks/" + url.PathEscape(id) + "/clone"
+	if onlyFailed {
+		path += "?only_failed=true"
+	}
+	if err := c.do(ctx, http.MethodPost, path, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.TaskID, nil
+}
+
+// PatchTask меняет метаданные задачи и возвращает её обновлённое состояние.
+func (c *Client) PatchTask(ctx context.Context, id string, patch TaskPatch) (*Task, error) {
+	var t Task
+	if err := c.do(ctx, http.MethodPatch, "/tasks/"+url.PathEscape(id), patch, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Stats возвращает сводку по всем задачам; top — сколько хостов в TopHosts
+// (0 — по умолчанию сервера, 10).
+func (c *Client) Stats(ctx context.Context, top int) (*Stats, error) {
+	path := "/stats"
+	if top > 0 {
+		path += "?top=" + strconv.Itoa(top)
+	}
+	var st Stats
+	if err := c.do(ctx, http.MethodGet, path, nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// DeleteTask удаляет задачу вместе с её скачанными файлами; при включённой
+// на сервере корзине (TRASH_RETENTION) — переносит в неё (res.Trashed).
+func (c *Client) DeleteTask(ctx context.Context, id string) (*DeleteResult, error) {
+	return c.deleteTask(ctx, "/tasks/"+url.PathEscape(id))
+}
+
+// PurgeTask удаляет задачу сразу, минуя корзину (и из корзины).
+func (c *Client) PurgeTask(ctx context.Context, id string) (*DeleteResult, error) {
+	return c.deleteTask(ctx, "/tasks/"+url.PathEscape(id)+"?purge=true")
+}
+
+func (c *Client) deleteTask(ctx context.Context, path string) (*DeleteResult, error) {
+	var res DeleteResult
+	if err := c.do(ctx, http.MethodDelete, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RestoreTask возвращает задачу из корзины; не в корзине — *APIError с кодом 409.
+func (c *Client) RestoreTask(ctx context.Context, id string) (*Task, error) {
+	var t Task
+	if err := c.do(ctx, http.MethodPost, "/tasks/"+url.PathEscape(id)+"/restore", nil, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RetryTask перезапускает упавшие файлы задачи; возвращает их число.
+func (c *Client) RetryTask(ctx context.Context, id string) (int, error) {
+	var resp struct {
+		Retried int `json:"retried"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/tasks/"+url.PathEscape(id)+"/retry", nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Retried, nil
+}
+
+// VerifyTask перепроверяет скачанные файлы задачи на диске сервиса (размер,
+// контрольные суммы) и возвращает пропавшие и повреждённые в очередь.
+// dryRun=true — только отчёт.
+func (c *Client) VerifyTask(ctx context.Context, id string, dryRun bool) (*VerifyResult, error) {
+	path := "/tasks/" + url.PathEscape(id) + "/verify"
+	if dryRun {
+		path += "?dry_run=true"
+	}
+	var res VerifyResult
+	if err := c.do(ctx, http.MethodPost, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RetryTasks перезапускает упавшие файлы во всех задачах, подходящих под filter.
+func (c *Client) RetryTasks(ctx context.Context, filter TaskFilter) (*BulkResult, error) {
+	var res BulkResult
+	if err := c.do(ctx, http.MethodPost, "/tasks/retry-failed", filter, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CancelTasks отменяет незавершённые файлы во всех задачах, подходящих под filter.
+func (c *Client) CancelTasks(ctx context.Context, filter TaskFilter) (*BulkResult, error) {
+	var res BulkResult
+	if err := c.do(ctx, http.MethodPost, "/tasks/cancel", filter, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// do выполняет запрос с повторами и декодирует JSON-ответ в out (если out != nil).
+//
+// Повторяются:
+//   - сетевые ошибки — только для GET (POST мог дойти до сервера: повтор создал бы дубликат);
+//   - ответы 429 и 503 (сервис перегружен/не готов — запрос не обработан) — для любых методов;
+//   - ответы 502 и 504 — только для GET.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	wait := c.retryWait
+	for attempt := 0; ; attempt++ {
+		err := c.doOnce(ctx, method, path, payload, out)
+		if err == nil || attempt >= c.maxRetries || !retryable(method, err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) doOnce(ctx context.Context, method, path string, payload []byte, out any) error {
+	var rd io.Reader
+	if payload != nil {
+		rd = bytes.NewReader(payload)
+	}
+	req, err := c.newRequest(ctx, method, path, rd)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if c.lang != "" {
+		req.Header.Set("Accept-Language", c.lang)
+	}
+	if id, _ := ctx.Value(requestIDKey{}).(string); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	return req, nil
+}
+
+// checkResponse превращает ответ не 2xx в *APIError с текстом тела. Если тело —
+// JSON с "code" и "message" (ошибки создания и изменения задач), Message —
+// это сообщение, а Code — код ошибки.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg)), RequestID: resp.Header.Get("X-Request-ID")}
+	var body struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(msg, &body) == nil && body.Message != "" {
+		apiErr.Message, apiErr.Code = body.Message, body.Code
+	}
+	return apiErr
+}
+
+// retryable решает, стоит ли повторять запрос method, завершившийся ошибкой err.
+func retryable(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return method == http.MethodGet
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method == http.MethodGet
+	}
+	return false
+}
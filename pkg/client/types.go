@@ -0,0 +1,395 @@
+package client
+
+import "time"
+
+// TaskStatus — агрегированный статус задачи (как в JSON API).
+type TaskStatus string
+
+const (
+	TaskPending   TaskStatus = "PENDING"
+	TaskRunning   TaskStatus = "RUNNING"
+	TaskComplete  TaskStatus = "COMPLETE"
+	TaskFailed    TaskStatus = "FAILED"
+	TaskPartial   TaskStatus = "PARTIAL"
+	TaskCancelled TaskStatus = "CANCELLED"
+	TaskPaused    TaskStatus = "PAUSED" // не конечный: задачу можно возобновить
+)
+
+// Terminal сообщает, что статус конечный (задача больше не изменится сама).
+func (s TaskStatus) Terminal() bool {
+	return s == TaskComplete || s == TaskFailed || s == TaskPartial || s == TaskCancelled
+}
+
+// FileState — статус одного файла задачи.
+type FileState string
+
+const (
+	FilePending   FileState = "PENDING"
+	FileRunning   FileState = "RUNNING"
+	FileDone      FileState = "DONE"
+	FileFailed    FileState = "FAILED"
+	FileCancelled FileState = "CANCELLED"
+	FileSkipped   FileState = "SKIPPED"
+	FileExpired   FileState = "EXPIRED" // не начат к сроку задачи (ExpiresAt)
+)
+
+// FileEvent — переход файла между состояниями (File.History).
+type FileEvent struct {
+	At     time.Time `json:"at"`
+	From   FileState `json:"from"`
+	To     FileState `json:"to"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// FileAttempt — HTTP-попытка загрузки файла (File.AttemptLog).
+type FileAttempt struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Bytes      int64     `json:"bytes"`
+	HTTPStatus int       `json:"http_status,omitempty"` // 0 — ответа не было
+	Error      string    `json:"error,omitempty"`
+	RemoteIP   string    `json:"remote_ip,omitempty"`
+}
+
+// FileResponse — ответ источника, из которого сохранён файл (File.Response).
+type FileResponse struct {
+	FinalURL     string     `json:"final_url"` // после редиректов
+	StatusCode   int        `json:"status_code"`
+	ContentType  string     `json:"content_type,omitempty"` // заголовок Content-Type
+	ETag         string     `json:"etag,omitempty"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+	RemoteIP     string     `json:"remote_ip,omitempty"`
+}
+
+// File — файл задачи в ответах API.
+type File struct {
+	ID              string            `json:"id"`
+	URL             string            `json:"url"`
+	Filename        string            `json:"filename"`
+	DestSubpath     string            `json:"dest_subpath,omitempty"`
+	Checksum        string            `json:"checksum,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"` // значения скрыты сервисом ("***")
+	HostHeader      string            `json:"host_header,omitempty"`
+	ConnectTo       string            `json:"connect_to,omitempty"`
+	Signature       string            `json:"signature,omitempty"`
+	SignatureCheck  *SignatureCheck   `json:"signature_check,omitempty"` // результат проверки подписи
+	Range           *ByteRange        `json:"range,omitempty"`
+	State           FileState         `json:"state"`
+	Error           string            `json:"error,omitempty"`
+	Attempts        int               `json:"attempts"`
+	MaxAttempts     int               `json:"max_attempts"`
+	BytesDownloaded int64             `json:"bytes_downloaded"`
+	SizeHint        int64             `json:"size_hint,omitempty"`
+	ProgressPercent float64           `json:"progress_percent"`
+	StartedAt       *time.Time        `json:"started_at,omitempty"`
+	FinishedAt      *time.Time        `json:"finished_at,omitempty"`
+	NextAttemptAt   *time.Time        `json:"next_attempt_at,omitempty"` // автоповтор отложен до этого момента
+	Host            string            `json:"host"`
+	Path            string            `json:"path,omitempty"`         // путь на диске сервиса (после загрузки)
+	Blob            string            `json:"blob,omitempty"`         // sha256 содержимого, если у сервиса включён BLOB_DIR
+	ContentType     string            `json:"content_type,omitempty"` // тип по первым байтам файла, если у сервиса включён FIX_EXTENSIONS
+	Upload          *FileUpload       `json:"upload,omitempty"`       // выгрузка в хранилище задачи (Task.Sink)
+	Aliases         []string          `json:"aliases,omitempty"`      // другие имена того же URL (политика dedupe)
+	DuplicateOf     string            `json:"duplicate_of,omitempty"` // "<taskID>/<fileID>": файл пропущен как повтор недавней задачи
+	History         []FileEvent       `json:"history,omitempty"`
+	AttemptLog      []FileAttempt     `json:"attempt_log,omitempty"` // последние HTTP-попытки загрузки
+	Response        *FileResponse     `json:"response,omitempty"`    // ответ источника на успешную загрузку
+}
+
+// FileUpload — выгрузка файла во внешнее хранилище (File.Upload).
+type FileUpload struct {
+	Sink         string     `json:"sink"`
+	State        string     `json:"state"` // "pending", "done" или "failed"
+	Attempts     int        `json:"attempts"`
+	Error        string     `json:"error,omitempty"`
+	Location     string     `json:"location,omitempty"` // адрес объекта в хранилище
+	UploadedAt   *time.Time `json:"uploaded_at,omitempty"`
+	LocalDeleted bool       `json:"local_deleted,omitempty"` // локальная копия удалена сервисом
+}
+
+// Notification — доставка сводки по завершении задачи в один канал.
+type Notification struct {
+	Channel  string     `json:"channel"` // "email" или имя уведомителя (секция notifiers сервиса)
+	Status   TaskStatus `json:"status"`  // статус задачи, о котором сводка
+	State    string     `json:"state"`   // "pending", "sent" или "failed"
+	Attempts int        `json:"attempts"`
+	Error    string     `json:"error,omitempty"`
+	QueuedAt time.Time  `json:"queued_at"`
+	SentAt   *time.Time `json:"sent_at,omitempty"`
+}
+
+// Task — задача в ответах API.
+type Task struct {
+	ID          string     `json:"id"`
+	Label       string     `json:"label,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Priority    int        `json:"priority,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DestDir     string     `json:"dest_dir"`
+	Layout      string     `json:"layout,omitempty"`
+	Sink        string     `json:"sink,omitempty"`
+	ClonedFrom  string     `json:"cloned_from,omitempty"`
+	Tenant      string     `json:"tenant,omitempty"`
+	APIKey      string     `json:"api_key,omitempty"`    // отпечаток ключа API создателя (учёт потребления)
+	RequestID   string     `json:"request_id,omitempty"` // X-Request-ID запроса, создавшего задачу
+	Status      TaskStatus `json:"status"`
+	Version     uint64     `json:"version"` // растёт при каждом изменении задачи
+	Paused      bool       `json:"paused,omitempty"`
+	DependsOn   []string   `json:"depends_on,omitempty"`
+	Waiting     bool       `json:"waiting,omitempty"` // ждёт зависимостей DependsOn
+	Sequential  bool       `json:"sequential,omitempty"`
+	Login       *Login     `json:"login,omitempty"`
+	NotifyEmail string     `json:"notify_email,omitempty"`
+	Notify      []string   `json:"notify,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // срок: не начатые к нему файлы — EXPIRED
+	TrashedAt   *time.Time `json:"trashed_at,omitempty"` // в корзине: скрыта из списков, не качается
+	Files       []*File    `json:"files"`
+
+	Notifications []*Notification `json:"notifications,omitempty"` // доставка сводок по завершении задачи
+	PartialRetry  *PartialRetry   `json:"partial_retry,omitempty"` // политика автоповтора PARTIAL и счётчик повторов
+
+	Total     int `json:"total"`
+	Done      int `json:"done"`
+	Failed    int `json:"failed"`
+	Pending   int `json:"pending"`
+	Running   int `json:"running"`
+	Cancelled int `json:"cancelled,omitempty"`
+	Skipped   int `json:"skipped,omitempty"`
+	Expired   int `json:"expired,omitempty"`
+	Retries   int `json:"retries_total"`
+
+	TotalBytesExpected   int64   `json:"total_bytes_expected"`
+	TotalBytesDownloaded int64   `json:"total_bytes_downloaded"`
+	ProgressPercent      float64 `json:"progress_percent"`
+}
+
+// SignedURL — подписанная ссылка на содержимое файла (SignFileURL).
+// URL открывается без учётных данных API до ExpiresAt.
+type SignedURL struct {
+	URL       string    `json:"url"`  // от SIGNED_URL_BASE сервиса, без него — от адреса клиента (SignFileURL)
+	Path      string    `json:"path"` // то же без схемы и хоста — если сервис за прокси
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TaskEvent — событие в истории задачи (Type: "created", "status_changed",
+// "file_started", "file_finished", "file_retry", "file_uploaded", "retry", "patched", "recovered", "expired").
+type TaskEvent struct {
+	At      time.Time `json:"at"`
+	Type    string    `json:"type"`
+	FileID  string    `json:"file_id,omitempty"`
+	Status  string    `json:"status,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// TaskHistory — история задачи (GET /tasks/{id}/history).
+type TaskHistory struct {
+	TaskID  string      `json:"task_id"`
+	Events  []TaskEvent `json:"events"`
+	Dropped int         `json:"dropped,omitempty"` // старые события, не поместившиеся в лимит сервиса
+}
+
+// SignatureCheck — результат проверки подписи файла.
+type SignatureCheck struct {
+	Valid     bool      `json:"valid"`
+	KeyID     string    `json:"key_id,omitempty"`
+	Signer    string    `json:"signer,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// ByteRange — часть объекта: Length байт с позиции Offset (Length 0 — до конца).
+type ByteRange struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length,omitempty"`
+}
+
+// Link — ссылка в запросе создания задачи. Пустые поля — умолчания сервиса.
+type Link struct {
+	URL         string            `json:"url"`
+	Filename    string            `json:"filename,omitempty"`     // имя файла вместо взятого из URL
+	DestSubpath string            `json:"dest_subpath,omitempty"` // подкаталог внутри каталога задачи
+	Checksum    string            `json:"checksum,omitempty"`     // "sha256:<hex>", "md5:<hex>", …
+	Headers     map[string]string `json:"headers,omitempty"`      // доп. заголовки запроса к источнику
+	HostHeader  string            `json:"host_header,omitempty"`  // заголовок Host вместо хоста из URL
+	ConnectTo   string            `json:"connect_to,omitempty"`   // IP[:порт] для соединения вместо DNS
+	Signature   string            `json:"signature,omitempty"`    // ссылка на отделённую подпись OpenPGP (.asc/.sig)
+	Range       *ByteRange        `json:"range,omitempty"`        // качать только часть объекта
+	MaxAttempts int               `json:"max_attempts,omitempty"`
+}
+
+// URLs превращает список URL в ссылки без дополнительных параметров.
+func URLs(urls ...string) []Link {
+	links := make([]Link, len(urls))
+	for i, u := range urls {
+		links[i] = Link{URL: u}
+	}
+	return links
+}
+
+// CreateTaskRequest — параметры создания задачи (тело POST /tasks).
+type CreateTaskRequest struct {
+	Links       []Link   `json:"links"`
+	Label       string   `json:"label,omitempty"`
+	DestDir     string   `json:"dest_dir,omitempty"` // подкаталог под DOWNLOAD_DIR сервиса
+	Layout      string   `json:"layout,omitempty"`   // LayoutPreservePath или пусто
+	Tags        []string `json:"tags,omitempty"`
+	Priority    int      `json:"priority,omitempty"`     // -100..100, больше — раньше в очереди
+	Sink        string   `json:"sink,omitempty"`         // хранилище для выгрузки файлов (секция sinks сервиса)
+	DependsOn   []string `json:"depends_on,omitempty"`   // файлы встают в очередь после завершения этих задач
+	Sequential  bool     `json:"sequential,omitempty"`   // качать файлы строго по порядку, по одному
+	Duplicates  string   `json:"duplicates,omitempty"`   // политика повторов URL: allow, dedupe, reject; пусто — настройка сервиса
+	Login       *Login   `json:"login,omitempty"`        // вход на сайт-источник перед скачиванием файлов
+	NotifyEmail string   `json:"notify_email,omitempty"` // адреса через запятую: сводка по завершении задачи (нужен SMTP_URL сервиса)
+	Notify      []string `json:"notify,omitempty"`       // уведомители (секция notifiers сервиса) для сводки по завершении
+
+	ExpiresAt    *time.Time    `json:"expires_at,omitempty"`    // срок: файлы, не начатые к нему, — EXPIRED и убираются из очереди
+	PartialRetry *PartialRetry `json:"partial_retry,omitempty"` // автоповтор упавших файлов, если задача завершится PARTIAL
+}
+
+// PartialRetry — политика автоповтора PARTIAL-задачи: через After после
+// завершения задачи упавшие файлы снова встают в очередь, не больше Max раз.
+type PartialRetry struct {
+	After string `json:"after"`           // длительность Go: "30m", "12h" (не меньше 1m)
+	Max   int    `json:"max"`             // 1..100
+	Count int    `json:"count,omitempty"` // сделано повторов (только в ответах)
+}
+
+// Login — вход на сайт-источник: запрос выполняется один раз перед загрузками
+// задачи, полученные cookie уходят со всеми её файлами.
+type Login struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`  // GET или POST (по умолчанию)
+	Form    map[string]string `json:"form,omitempty"`    // поля формы
+	Headers map[string]string `json:"headers,omitempty"` // доп. заголовки запроса входа
+	Cookie  string            `json:"cookie,omitempty"`  // cookie, которую выставляет успешный вход
+}
+
+// TaskPatch — изменение задачи (тело PATCH /tasks/{id}). nil-поле — «не менять».
+type TaskPatch struct {
+	Label       *string   `json:"label,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"` // пустой срез очищает теги
+	Priority    *int      `json:"priority,omitempty"`
+	MaxAttempts *int      `json:"max_attempts,omitempty"` // только для ещё не начатых файлов
+}
+
+// Раскладка файлов задачи (CreateTaskRequest.Layout).
+const (
+	LayoutFlat         = "flat"
+	LayoutPreservePath = "preserve_path" // <host>/<путь из URL>/<имя файла>
+)
+
+// TaskFilter — отбор задач для RetryTasks/CancelTasks; условия объединяются по «И».
+// Нужно хотя бы одно условие.
+type TaskFilter struct {
+	IDs    []string `json:"ids,omitempty"`
+	Status string   `json:"status,omitempty"`
+	Tag    string   `json:"tag,omitempty"`
+	Host   string   `json:"host,omitempty"` // только файлы с этого хоста
+}
+
+// BulkResult — итог массовой операции.
+type BulkResult struct {
+	Tasks   int      `json:"tasks"`
+	Files   int      `json:"files"`
+	TaskIDs []string `json:"task_ids"`
+}
+
+// DeleteResult — итог удаления задачи.
+type DeleteResult struct {
+	TaskID       string `json:"task_id"`
+	FilesRemoved int    `json:"files_removed"`
+	BytesFreed   int64  `json:"bytes_freed"`
+	BlobsKept    int    `json:"blobs_kept"` // общие с другими задачами blob'ы, оставленные на диске
+
+	Trashed bool       `json:"trashed,omitempty"`  // задача перенесена в корзину (TRASH_RETENTION), ничего не удалено
+	PurgeAt *time.Time `json:"purge_at,omitempty"` // когда задача будет удалена из корзины
+}
+
+// Stats — сводка по всем задачам (GET /stats).
+type Stats struct {
+	Tasks          int            `json:"tasks"`
+	ByStatus       map[string]int `json:"by_status"`
+	BytesToday     int64          `json:"bytes_today"`
+	BytesWeek      int64          `json:"bytes_week"`
+	WeekStart      string         `json:"week_start"`
+	TopHosts       []HostVolume   `json:"top_hosts"` // с момента старта сервиса
+	Downloads      int64          `json:"downloads"`
+	AvgDuration    float64        `json:"avg_duration_seconds"`
+	ThroughputRate float64        `json:"throughput_bytes_per_sec"`
+	Since          time.Time      `json:"since"`
+}
+
+// HostVolume — скачанный с хоста объём (Stats.TopHosts).
+type HostVolume struct {
+	Host  string `json:"host"`
+	Bytes int64  `json:"bytes"`
+	Files int64  `json:"files"`
+}
+
+// FieldError — ошибка валидации поля задачи или ссылки.
+type FieldError struct {
+	Field string `json:"field"`
+	Index *int   `json:"index,omitempty"`
+	Value string `json:"value,omitempty"`
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error"`
+}
+
+// Вердикты ссылок пробного прогона (LinkVerdict.Verdict).
+const (
+	VerdictOK          = "ok"
+	VerdictRenamed     = "renamed"     // путь занят — имя получит суффикс "-N"
+	VerdictDuplicate   = "duplicate"   // тот же URL, что у ссылки DuplicateOf
+	VerdictUnreachable = "unreachable" // пробный запрос не получил ответ 2xx
+	VerdictInvalid     = "invalid"     // ссылка не прошла валидацию
+)
+
+// LinkVerdict — что стало бы со ссылкой задачи при создании.
+type LinkVerdict struct {
+	Index       int    `json:"index"`
+	URL         string `json:"url"`
+	Verdict     string `json:"verdict"`
+	Code        string `json:"code,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DuplicateOf *int   `json:"duplicate_of,omitempty"`
+	DestPath    string `json:"dest_path,omitempty"`
+	File        *File  `json:"file,omitempty"`
+	Probe       *struct {
+		Status       int    `json:"status,omitempty"`
+		Size         int64  `json:"size,omitempty"`
+		ContentType  string `json:"content_type,omitempty"`
+		AcceptRanges bool   `json:"accept_ranges,omitempty"`
+		Error        string `json:"error,omitempty"`
+	} `json:"probe,omitempty"`
+}
+
+// DryRun — результат пробного прогона (ValidateTask). Valid — задача была бы создана.
+type DryRun struct {
+	Valid   bool           `json:"valid"`
+	DestDir string         `json:"dest_dir,omitempty"`
+	Errors  []FieldError   `json:"errors,omitempty"`
+	Links   []LinkVerdict  `json:"links"`
+	Summary map[string]int `json:"summary"`
+}
+
+// VerifyResult — итог перепроверки файлов задачи (VerifyTask).
+type VerifyResult struct {
+	TaskID   string `json:"task_id"`
+	Checked  int    `json:"checked"`
+	OK       int    `json:"ok"`
+	Bad      int    `json:"bad"`
+	Requeued int    `json:"requeued"`
+	Files    []struct {
+		FileID   string `json:"file_id"`
+		Path     string `json:"path"`
+		Result   string `json:"result"` // ok, missing, size_mismatch, checksum_mismatch, unreadable, skipped
+		Detail   string `json:"detail,omitempty"`
+		Requeued bool   `json:"requeued,omitempty"`
+	} `json:"files"`
+}
+
+// ListOptions — пагинация GET /tasks. Нулевые значения — умолчания сервера.
+type ListOptions struct {
+	Limit  int
+	Offset int
+}
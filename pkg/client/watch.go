@@ -0,0 +1,124 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrStopWatch — fn в WatchTask может вернуть её, чтобы досрочно
+// прекратить наблюдение без ошибки.
+var ErrStopWatch = errors.New("stop watch")
+
+// WatchTask подписывается на изменения задачи (GET /tasks/{id}/events, SSE)
+// и вызывает fn с каждым новым состоянием — первый раз сразу после подключения.
+//
+// Возвращает:
+//   - nil — задача пришла в конечный статус (последнее состояние уже передано в fn)
+//     или fn вернула ErrStopWatch;
+//   - ошибку fn, если она иная;
+//   - ctx.Err() при отмене контекста;
+//   - *APIError (например, 404) или сетевую ошибку, если переподключиться не удалось.
+//
+// Обрыв потока (перезапуск сервиса, прокси) не считается ошибкой: клиент
+// переподключается с паузой, как в WithRetries; счётчик попыток сбрасывается
+// после каждого полученного события.
+func (c *Client) WatchTask(ctx context.Context, id string, fn func(*Task) error) error {
+	wait := c.retryWait
+	failures := 0
+	for {
+		got, cbErr, err := c.watchOnce(ctx, id, fn)
+		switch {
+		case errors.Is(cbErr, ErrStopWatch):
+			return nil
+		case cbErr != nil:
+			return cbErr
+		case err == nil:
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		}
+		if got {
+			failures, wait = 0, c.retryWait
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !retryable(http.MethodGet, err) {
+			return err
+		}
+		if failures >= c.maxRetries {
+			return err
+		}
+		failures++
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// watchOnce читает один SSE-поток до события done, ошибки или обрыва.
+// got — было ли получено хотя бы одно событие task; cbErr — ошибка, которой
+// fn прервала чтение; err — сбой потока (обрыв без done — io.ErrUnexpectedEOF).
+func (c *Client) watchOnce(ctx context.Context, id string, fn func(*Task) error) (got bool, cbErr, err error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/tasks/"+url.PathEscape(id)+"/events", nil)
+	if err != nil {
+		return false, nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return false, nil, err
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var event string
+	var data strings.Builder
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			// конец события
+			switch event {
+			case "done":
+				return got, nil, nil
+			case "task":
+				var t Task
+				if err := json.Unmarshal([]byte(data.String()), &t); err != nil {
+					return got, nil, err
+				}
+				got = true
+				if err := fn(&t); err != nil {
+					return got, err, nil
+				}
+			}
+			event = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+			// комментарий (ping)
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return got, nil, err
+	}
+	return got, nil, io.ErrUnexpectedEOF
+}